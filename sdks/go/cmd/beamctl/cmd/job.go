@@ -0,0 +1,467 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/metricsx"
+	jobpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/jobmanagement_v1"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/universal/runnerlib"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	jobCmd = &cobra.Command{
+		Use:   "job",
+		Short: "Job commands for a portable job service",
+	}
+
+	jobListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List jobs",
+		RunE:  jobListFn,
+		Args:  cobra.NoArgs,
+	}
+
+	jobStateCmd = &cobra.Command{
+		Use:   "state <job id>",
+		Short: "Show the state of a job",
+		RunE:  jobStateFn,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	jobGraphCmd = &cobra.Command{
+		Use:   "graph <job id>",
+		Short: "Show the transforms of the pipeline of a job",
+		RunE:  jobGraphFn,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	jobMessagesCmd = &cobra.Command{
+		Use:   "messages <job id>",
+		Short: "Stream the messages and state changes of a job until it terminates",
+		RunE:  jobMessagesFn,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	jobMetricsCmd = &cobra.Command{
+		Use:   "metrics <job id>",
+		Short: "Show the metrics of a job",
+		RunE:  jobMetricsFn,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	jobCancelCmd = &cobra.Command{
+		Use:   "cancel <job id>",
+		Short: "Cancel a job",
+		Long: `Cancel a job. The portable job API has no drain operation, so runners
+that support draining report it through the DRAINING and DRAINED states.`,
+		RunE: jobCancelFn,
+		Args: cobra.ExactArgs(1),
+	}
+
+	jobDrainCmd = &cobra.Command{
+		Use:   "drain <job id>",
+		Short: "Drain a job (not supported)",
+		Long: `Drain a job. The portable job API has no drain operation, so this always
+fails. Use cancel instead, or the runner's own tools to drain a job.`,
+		RunE: jobDrainFn,
+		Args: cobra.ExactArgs(1),
+	}
+
+	jobLaunchCmd = &cobra.Command{
+		Use:   "launch <template location>",
+		Short: "Launch a job from a pipeline template",
+		Long: `Launch a job from a pipeline template saved with --template_location,
+binding its runtime parameters to the values given with --param.`,
+		RunE: jobLaunchFn,
+		Args: cobra.ExactArgs(1),
+	}
+
+	jobOutput    string
+	cancelWait   bool
+	messageOnly  bool
+	launchParams map[string]string
+	launchWait   bool
+)
+
+func init() {
+	jobCmd.AddCommand(jobListCmd, jobStateCmd, jobGraphCmd, jobMessagesCmd, jobMetricsCmd, jobCancelCmd, jobDrainCmd, jobLaunchCmd)
+	jobCmd.PersistentFlags().StringVarP(&jobOutput, "output", "o", "text", "Output format: text or json")
+	jobCancelCmd.Flags().BoolVar(&cancelWait, "wait", false, "Wait until the job reaches a terminal state")
+	jobMessagesCmd.Flags().BoolVar(&messageOnly, "messages_only", false, "Don't print state changes")
+	jobLaunchCmd.Flags().StringToStringVar(&launchParams, "param", nil, "Runtime parameter value, as name=value. May be repeated")
+	jobLaunchCmd.Flags().BoolVar(&launchWait, "wait", false, "Wait until the job completes")
+}
+
+// jobClient connects to the job service at the endpoint, and returns the
+// context to use, the client and a function to close the connection.
+// Overridden in tests.
+var jobClient = func() (context.Context, jobpb.JobServiceClient, func() error, error) {
+	ctx, cc, err := dial()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return ctx, jobpb.NewJobServiceClient(cc), cc.Close, nil
+}
+
+// jsonOutput returns whether to print JSON, and validates the output flag.
+func jsonOutput() (bool, error) {
+	switch jobOutput {
+	case "text":
+		return false, nil
+	case "json":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid output format %q, want text or json", jobOutput)
+	}
+}
+
+// printProto prints the message as a single line of JSON.
+func printProto(w io.Writer, m proto.Message) error {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func jobListFn(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput()
+	if err != nil {
+		return err
+	}
+	ctx, client, closeFn, err := jobClient()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	resp, err := client.GetJobs(ctx, &jobpb.GetJobsRequest{})
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return printProto(cmd.OutOrStdout(), resp)
+	}
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOB ID\tNAME\tSTATE")
+	for _, info := range resp.GetJobInfo() {
+		fmt.Fprintf(tw, "%v\t%v\t%v\n", info.GetJobId(), info.GetJobName(), info.GetState())
+	}
+	return tw.Flush()
+}
+
+func jobStateFn(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput()
+	if err != nil {
+		return err
+	}
+	ctx, client, closeFn, err := jobClient()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	event, err := client.GetState(ctx, &jobpb.GetJobStateRequest{JobId: args[0]})
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return printProto(cmd.OutOrStdout(), event)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%v\t%v\n", event.GetState(), event.GetTimestamp().AsTime())
+	return nil
+}
+
+func jobGraphFn(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput()
+	if err != nil {
+		return err
+	}
+	ctx, client, closeFn, err := jobClient()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	resp, err := client.GetPipeline(ctx, &jobpb.GetJobPipelineRequest{JobId: args[0]})
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return printProto(cmd.OutOrStdout(), resp.GetPipeline())
+	}
+	printGraph(cmd.OutOrStdout(), resp.GetPipeline())
+	return nil
+}
+
+// printGraph prints the transform hierarchy of the pipeline, with the
+// PCollections read and written by each leaf transform.
+func printGraph(w io.Writer, p *pipepb.Pipeline) {
+	xforms := p.GetComponents().GetTransforms()
+	var visit func(id string, depth int)
+	visit = func(id string, depth int) {
+		t := xforms[id]
+		indent := strings.Repeat("  ", depth)
+		if len(t.GetSubtransforms()) > 0 {
+			fmt.Fprintf(w, "%v%v\n", indent, t.GetUniqueName())
+			for _, sub := range t.GetSubtransforms() {
+				visit(sub, depth+1)
+			}
+			return
+		}
+		fmt.Fprintf(w, "%v%v [%v] %v -> %v\n", indent, t.GetUniqueName(), t.GetSpec().GetUrn(),
+			sortedValues(t.GetInputs()), sortedValues(t.GetOutputs()))
+	}
+	for _, id := range p.GetRootTransformIds() {
+		visit(id, 0)
+	}
+}
+
+func sortedValues(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ret := make([]string, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, m[k])
+	}
+	return ret
+}
+
+func jobMessagesFn(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput()
+	if err != nil {
+		return err
+	}
+	ctx, client, closeFn, err := jobClient()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	stream, err := client.GetMessageStream(ctx, &jobpb.JobMessagesRequest{JobId: args[0]})
+	if err != nil {
+		return err
+	}
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		state := msg.GetStateResponse()
+		switch {
+		case state != nil && messageOnly:
+			// Skip printing the state, but still stop at a terminal state.
+		case asJSON:
+			if err := printProto(cmd.OutOrStdout(), msg); err != nil {
+				return err
+			}
+		case state != nil:
+			fmt.Fprintf(cmd.OutOrStdout(), "%v\tstate\t%v\n", state.GetTimestamp().AsTime(), state.GetState())
+		default:
+			m := msg.GetMessageResponse()
+			fmt.Fprintf(cmd.OutOrStdout(), "%v\t%v\t%v\n", m.GetTime(), m.GetImportance(), m.GetMessageText())
+		}
+		if state != nil && isTerminal(state.GetState()) {
+			return nil
+		}
+	}
+}
+
+// isTerminal returns whether a job in the given state will no longer change.
+func isTerminal(s jobpb.JobState_Enum) bool {
+	switch s {
+	case jobpb.JobState_DONE, jobpb.JobState_FAILED, jobpb.JobState_CANCELLED,
+		jobpb.JobState_DRAINED, jobpb.JobState_UPDATED:
+		return true
+	}
+	return false
+}
+
+// metricRow is a single metric of a job, for output.
+type metricRow struct {
+	Type      string      `json:"type"`
+	Step      string      `json:"step"`
+	Namespace string      `json:"namespace,omitempty"`
+	Name      string      `json:"name,omitempty"`
+	Value     interface{} `json:"value"`
+}
+
+func jobMetricsFn(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput()
+	if err != nil {
+		return err
+	}
+	ctx, client, closeFn, err := jobClient()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	resp, err := client.GetJobMetrics(ctx, &jobpb.GetJobMetricsRequest{JobId: args[0]})
+	if err != nil {
+		return err
+	}
+	// The pipeline attributes PCollection metrics to the PTransforms writing
+	// them, but isn't essential.
+	var p *pipepb.Pipeline
+	if pr, err := client.GetPipeline(ctx, &jobpb.GetJobPipelineRequest{JobId: args[0]}); err == nil {
+		p = pr.GetPipeline()
+	}
+	results := metricsx.FromMonitoringInfos(p, resp.GetMetrics().GetAttempted(), resp.GetMetrics().GetCommitted())
+	rows := metricRows(results.AllMetrics())
+
+	if asJSON {
+		return printJSON(cmd.OutOrStdout(), rows)
+	}
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tSTEP\tNAME\tVALUE")
+	for _, r := range rows {
+		name := r.Name
+		if r.Namespace != "" {
+			name = r.Namespace + "." + r.Name
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%+v\n", r.Type, r.Step, name, r.Value)
+	}
+	return tw.Flush()
+}
+
+func metricRows(qr metrics.QueryResults) []metricRow {
+	var rows []metricRow
+	add := func(typ string, k metrics.StepKey, v interface{}) {
+		rows = append(rows, metricRow{Type: typ, Step: k.Step, Namespace: k.Namespace, Name: k.Name, Value: v})
+	}
+	for _, r := range qr.Counters() {
+		add("counter", r.Key, r.Result())
+	}
+	for _, r := range qr.Distributions() {
+		add("distribution", r.Key, r.Result())
+	}
+	for _, r := range qr.Gauges() {
+		add("gauge", r.Key, r.Result())
+	}
+	for _, r := range qr.Histograms() {
+		add("histogram", r.Key, r.Result())
+	}
+	for _, r := range qr.StringSets() {
+		add("stringset", r.Key, r.Result())
+	}
+	for _, r := range qr.Msecs() {
+		add("msecs", r.Key, r.Result())
+	}
+	for _, r := range qr.PCols() {
+		add("pcollection", r.Key, r.Result())
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Step != rows[j].Step {
+			return rows[i].Step < rows[j].Step
+		}
+		return rows[i].Namespace+"."+rows[i].Name < rows[j].Namespace+"."+rows[j].Name
+	})
+	return rows
+}
+
+func jobCancelFn(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput()
+	if err != nil {
+		return err
+	}
+	ctx, client, closeFn, err := jobClient()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	resp, err := client.Cancel(ctx, &jobpb.CancelJobRequest{JobId: args[0]})
+	if err != nil {
+		return err
+	}
+	state := resp.GetState()
+	if cancelWait && !isTerminal(state) {
+		stream, err := client.GetStateStream(ctx, &jobpb.GetJobStateRequest{JobId: args[0]})
+		if err != nil {
+			return err
+		}
+		for !isTerminal(state) {
+			event, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			state = event.GetState()
+		}
+	}
+	if asJSON {
+		return printJSON(cmd.OutOrStdout(), map[string]string{"job_id": args[0], "state": state.String()})
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), state)
+	return nil
+}
+
+// errDrainUnsupported is returned by the drain command.
+var errDrainUnsupported = errors.New("drain not supported by the portable JobService, use cancel instead")
+
+func jobDrainFn(cmd *cobra.Command, args []string) error {
+	return errDrainUnsupported
+}
+
+func jobLaunchFn(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput()
+	if err != nil {
+		return err
+	}
+	if endpoint == "" {
+		return errors.New("endpoint not defined")
+	}
+
+	res, err := runnerlib.LaunchTemplate(context.Background(), args[0], endpoint, launchParams, !launchWait)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return printJSON(cmd.OutOrStdout(), map[string]string{"job_id": res.JobID()})
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), res.JobID())
+	return nil
+}
@@ -0,0 +1,500 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/metricsx"
+	jobpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/jobmanagement_v1"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeJobClient is a job service client with a fixed set of jobs. Methods
+// the commands don't use panic.
+type fakeJobClient struct {
+	jobpb.JobServiceClient
+
+	jobs []*jobpb.JobInfo
+	// states are the states of the job: the first is returned by GetState
+	// and Cancel, and the rest are streamed by GetStateStream.
+	states    []jobpb.JobState_Enum
+	err       error // returned by all calls
+	streamErr error // returned by GetStateStream after the states
+	cancelled []string
+	closed    bool
+
+	pipeline *pipepb.Pipeline
+	messages []*jobpb.JobMessagesResponse
+	metrics  []*pipepb.MonitoringInfo
+}
+
+var testTime = time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func (f *fakeJobClient) GetJobs(ctx context.Context, in *jobpb.GetJobsRequest, opts ...grpc.CallOption) (*jobpb.GetJobsResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &jobpb.GetJobsResponse{JobInfo: f.jobs}, nil
+}
+
+func (f *fakeJobClient) GetState(ctx context.Context, in *jobpb.GetJobStateRequest, opts ...grpc.CallOption) (*jobpb.JobStateEvent, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &jobpb.JobStateEvent{State: f.states[0], Timestamp: timestamppb.New(testTime)}, nil
+}
+
+func (f *fakeJobClient) Cancel(ctx context.Context, in *jobpb.CancelJobRequest, opts ...grpc.CallOption) (*jobpb.CancelJobResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.cancelled = append(f.cancelled, in.GetJobId())
+	return &jobpb.CancelJobResponse{State: f.states[0]}, nil
+}
+
+func (f *fakeJobClient) GetStateStream(ctx context.Context, in *jobpb.GetJobStateRequest, opts ...grpc.CallOption) (jobpb.JobService_GetStateStreamClient, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &fakeStateStream{states: f.states[1:], err: f.streamErr}, nil
+}
+
+func (f *fakeJobClient) GetPipeline(ctx context.Context, in *jobpb.GetJobPipelineRequest, opts ...grpc.CallOption) (*jobpb.GetJobPipelineResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &jobpb.GetJobPipelineResponse{Pipeline: f.pipeline}, nil
+}
+
+func (f *fakeJobClient) GetMessageStream(ctx context.Context, in *jobpb.JobMessagesRequest, opts ...grpc.CallOption) (jobpb.JobService_GetMessageStreamClient, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &fakeMessageStream{msgs: f.messages, err: f.streamErr}, nil
+}
+
+func (f *fakeJobClient) GetJobMetrics(ctx context.Context, in *jobpb.GetJobMetricsRequest, opts ...grpc.CallOption) (*jobpb.GetJobMetricsResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &jobpb.GetJobMetricsResponse{Metrics: &jobpb.MetricResults{Attempted: f.metrics}}, nil
+}
+
+// fakeMessageStream streams the given messages, and then returns err, or
+// io.EOF if err is nil.
+type fakeMessageStream struct {
+	grpc.ClientStream
+
+	msgs []*jobpb.JobMessagesResponse
+	err  error
+}
+
+func (s *fakeMessageStream) Recv() (*jobpb.JobMessagesResponse, error) {
+	if len(s.msgs) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	msg := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return msg, nil
+}
+
+// fakeStateStream streams the given states, and then returns err, or io.EOF
+// if err is nil.
+type fakeStateStream struct {
+	grpc.ClientStream
+
+	states []jobpb.JobState_Enum
+	err    error
+}
+
+func (s *fakeStateStream) Recv() (*jobpb.JobStateEvent, error) {
+	if len(s.states) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	state := s.states[0]
+	s.states = s.states[1:]
+	return &jobpb.JobStateEvent{State: state}, nil
+}
+
+// setupJobClient makes the commands use the fake client with the given
+// output format, and restores the flags afterwards.
+func setupJobClient(t *testing.T, f *fakeJobClient, output string) {
+	t.Helper()
+	prevClient, prevOutput, prevWait, prevMessageOnly := jobClient, jobOutput, cancelWait, messageOnly
+	t.Cleanup(func() {
+		jobClient, jobOutput, cancelWait, messageOnly = prevClient, prevOutput, prevWait, prevMessageOnly
+	})
+	jobClient = func() (context.Context, jobpb.JobServiceClient, func() error, error) {
+		return context.Background(), f, func() error {
+			f.closed = true
+			return nil
+		}, nil
+	}
+	jobOutput = output
+}
+
+// runJobCmd runs the command function and returns what it printed.
+func runJobCmd(fn func(*cobra.Command, []string) error, args ...string) (string, error) {
+	var buf bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&buf)
+	err := fn(cmd, args)
+	return buf.String(), err
+}
+
+func TestJobList(t *testing.T) {
+	f := &fakeJobClient{jobs: []*jobpb.JobInfo{
+		{JobId: "job1", JobName: "wordcount", State: jobpb.JobState_RUNNING},
+		{JobId: "job2", JobName: "join", State: jobpb.JobState_DONE},
+	}}
+	setupJobClient(t, f, "text")
+
+	got, err := runJobCmd(jobListFn)
+	if err != nil {
+		t.Fatalf("jobListFn() failed: %v", err)
+	}
+	want := "JOB ID  NAME       STATE\n" +
+		"job1    wordcount  RUNNING\n" +
+		"job2    join       DONE\n"
+	if got != want {
+		t.Errorf("jobListFn() printed %q, want %q", got, want)
+	}
+	if !f.closed {
+		t.Errorf("jobListFn() didn't close the connection")
+	}
+}
+
+func TestJobList_json(t *testing.T) {
+	f := &fakeJobClient{jobs: []*jobpb.JobInfo{
+		{JobId: "job1", JobName: "wordcount", State: jobpb.JobState_RUNNING},
+	}}
+	setupJobClient(t, f, "json")
+
+	out, err := runJobCmd(jobListFn)
+	if err != nil {
+		t.Fatalf("jobListFn() failed: %v", err)
+	}
+	var got jobpb.GetJobsResponse
+	if err := protojson.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("jobListFn() printed %q, not a GetJobsResponse: %v", out, err)
+	}
+	if want := (&jobpb.GetJobsResponse{JobInfo: f.jobs}); !proto.Equal(&got, want) {
+		t.Errorf("jobListFn() printed %v, want %v", &got, want)
+	}
+}
+
+func TestJobState(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"text", "RUNNING\t2022-01-02 03:04:05 +0000 UTC\n"},
+		{"json", `{"state":"RUNNING","timestamp":"2022-01-02T03:04:05Z"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.output, func(t *testing.T) {
+			setupJobClient(t, &fakeJobClient{states: []jobpb.JobState_Enum{jobpb.JobState_RUNNING}}, test.output)
+
+			got, err := runJobCmd(jobStateFn, "job1")
+			if err != nil {
+				t.Fatalf("jobStateFn() failed: %v", err)
+			}
+			if test.output == "json" {
+				// protojson output isn't stable, so compare the messages.
+				var gotEvent, wantEvent jobpb.JobStateEvent
+				if err := protojson.Unmarshal([]byte(got), &gotEvent); err != nil {
+					t.Fatalf("jobStateFn() printed %q, not a JobStateEvent: %v", got, err)
+				}
+				if err := protojson.Unmarshal([]byte(test.want), &wantEvent); err != nil {
+					t.Fatal(err)
+				}
+				if !proto.Equal(&gotEvent, &wantEvent) {
+					t.Errorf("jobStateFn() printed %v, want %v", &gotEvent, &wantEvent)
+				}
+				return
+			}
+			if got != test.want {
+				t.Errorf("jobStateFn() printed %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	tests := []struct {
+		name   string
+		wait   bool
+		output string
+		states []jobpb.JobState_Enum
+		want   string
+	}{
+		{
+			name:   "noWait",
+			output: "text",
+			states: []jobpb.JobState_Enum{jobpb.JobState_CANCELLING, jobpb.JobState_CANCELLED},
+			want:   "CANCELLING\n",
+		}, {
+			name:   "wait",
+			wait:   true,
+			output: "text",
+			states: []jobpb.JobState_Enum{jobpb.JobState_CANCELLING, jobpb.JobState_RUNNING, jobpb.JobState_CANCELLED},
+			want:   "CANCELLED\n",
+		}, {
+			name:   "waitTerminal",
+			wait:   true,
+			output: "text",
+			states: []jobpb.JobState_Enum{jobpb.JobState_DONE},
+			want:   "DONE\n",
+		}, {
+			name:   "waitStreamEnds",
+			wait:   true,
+			output: "text",
+			states: []jobpb.JobState_Enum{jobpb.JobState_CANCELLING, jobpb.JobState_DRAINING},
+			want:   "DRAINING\n",
+		}, {
+			name:   "json",
+			wait:   true,
+			output: "json",
+			states: []jobpb.JobState_Enum{jobpb.JobState_CANCELLING, jobpb.JobState_CANCELLED},
+			want:   "{\n  \"job_id\": \"job1\",\n  \"state\": \"CANCELLED\"\n}\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := &fakeJobClient{states: test.states}
+			setupJobClient(t, f, test.output)
+			cancelWait = test.wait
+
+			got, err := runJobCmd(jobCancelFn, "job1")
+			if err != nil {
+				t.Fatalf("jobCancelFn() failed: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("jobCancelFn() printed %q, want %q", got, test.want)
+			}
+			if len(f.cancelled) != 1 || f.cancelled[0] != "job1" {
+				t.Errorf("jobCancelFn() cancelled %v, want [job1]", f.cancelled)
+			}
+		})
+	}
+}
+
+// testPipeline returns a pipeline with a composite transform "Root" around
+// the ParDo "Root/ParDo" with ID t1, reading p0 and writing p1.
+func testPipeline() *pipepb.Pipeline {
+	return &pipepb.Pipeline{
+		Components: &pipepb.Components{
+			Transforms: map[string]*pipepb.PTransform{
+				"root": {UniqueName: "Root", Subtransforms: []string{"t1"}},
+				"t1": {
+					UniqueName: "Root/ParDo",
+					Spec:       &pipepb.FunctionSpec{Urn: "beam:transform:pardo:v1"},
+					Inputs:     map[string]string{"i0": "p0"},
+					Outputs:    map[string]string{"o0": "p1"},
+				},
+			},
+		},
+		RootTransformIds: []string{"root"},
+	}
+}
+
+func TestJobGraph(t *testing.T) {
+	setupJobClient(t, &fakeJobClient{pipeline: testPipeline()}, "text")
+
+	got, err := runJobCmd(jobGraphFn, "job1")
+	if err != nil {
+		t.Fatalf("jobGraphFn() failed: %v", err)
+	}
+	want := "Root\n" +
+		"  Root/ParDo [beam:transform:pardo:v1] [p0] -> [p1]\n"
+	if got != want {
+		t.Errorf("jobGraphFn() printed %q, want %q", got, want)
+	}
+}
+
+func TestJobMessages(t *testing.T) {
+	ts := timestamppb.New(testTime)
+	message := func(text string) *jobpb.JobMessagesResponse {
+		return &jobpb.JobMessagesResponse{Response: &jobpb.JobMessagesResponse_MessageResponse{
+			MessageResponse: &jobpb.JobMessage{Time: "12:00", Importance: jobpb.JobMessage_JOB_MESSAGE_BASIC, MessageText: text},
+		}}
+	}
+	state := func(s jobpb.JobState_Enum) *jobpb.JobMessagesResponse {
+		return &jobpb.JobMessagesResponse{Response: &jobpb.JobMessagesResponse_StateResponse{
+			StateResponse: &jobpb.JobStateEvent{State: s, Timestamp: ts},
+		}}
+	}
+	// Messages after the terminal state aren't read.
+	msgs := []*jobpb.JobMessagesResponse{message("starting"), state(jobpb.JobState_RUNNING), message("done"), state(jobpb.JobState_DONE), message("unread")}
+
+	tests := []struct {
+		name        string
+		messageOnly bool
+		want        string
+	}{
+		{
+			name: "all",
+			want: "12:00\tJOB_MESSAGE_BASIC\tstarting\n" +
+				"2022-01-02 03:04:05 +0000 UTC\tstate\tRUNNING\n" +
+				"12:00\tJOB_MESSAGE_BASIC\tdone\n" +
+				"2022-01-02 03:04:05 +0000 UTC\tstate\tDONE\n",
+		}, {
+			name:        "messagesOnly",
+			messageOnly: true,
+			want: "12:00\tJOB_MESSAGE_BASIC\tstarting\n" +
+				"12:00\tJOB_MESSAGE_BASIC\tdone\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupJobClient(t, &fakeJobClient{messages: msgs}, "text")
+			messageOnly = test.messageOnly
+
+			got, err := runJobCmd(jobMessagesFn, "job1")
+			if err != nil {
+				t.Fatalf("jobMessagesFn() failed: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("jobMessagesFn() printed %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJobMetrics(t *testing.T) {
+	payload, err := metricsx.Int64Counter(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeJobClient{
+		pipeline: testPipeline(),
+		metrics: []*pipepb.MonitoringInfo{{
+			Urn:     metricsx.UrnToString(metricsx.UrnUserSumInt64),
+			Type:    metricsx.UrnToType(metricsx.UrnUserSumInt64),
+			Labels:  map[string]string{"PTRANSFORM": "t1", "NAMESPACE": "ns", "NAME": "count"},
+			Payload: payload,
+		}},
+	}
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"text", "TYPE     STEP  NAME      VALUE\n" +
+			"counter  t1    ns.count  42\n"},
+		{"json", `[
+  {
+    "type": "counter",
+    "step": "t1",
+    "namespace": "ns",
+    "name": "count",
+    "value": 42
+  }
+]
+`},
+	}
+	for _, test := range tests {
+		t.Run(test.output, func(t *testing.T) {
+			setupJobClient(t, f, test.output)
+
+			got, err := runJobCmd(jobMetricsFn, "job1")
+			if err != nil {
+				t.Fatalf("jobMetricsFn() failed: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("jobMetricsFn() printed %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJobDrain(t *testing.T) {
+	f := &fakeJobClient{states: []jobpb.JobState_Enum{jobpb.JobState_RUNNING}}
+	setupJobClient(t, f, "text")
+
+	if _, err := runJobCmd(jobDrainFn, "job1"); !errors.Is(err, errDrainUnsupported) {
+		t.Errorf("jobDrainFn() = %v, want %v", err, errDrainUnsupported)
+	}
+	if len(f.cancelled) != 0 {
+		t.Errorf("jobDrainFn() cancelled %v, want none", f.cancelled)
+	}
+}
+
+func TestJobCommands_errors(t *testing.T) {
+	errFake := errors.New("fake job service error")
+	running := []jobpb.JobState_Enum{jobpb.JobState_CANCELLING, jobpb.JobState_RUNNING}
+	tests := []struct {
+		name   string
+		fn     func(*cobra.Command, []string) error
+		client *fakeJobClient
+		output string
+		wait   bool
+		want   error
+	}{
+		{"list", jobListFn, &fakeJobClient{err: errFake}, "text", false, errFake},
+		{"state", jobStateFn, &fakeJobClient{err: errFake}, "text", false, errFake},
+		{"cancel", jobCancelFn, &fakeJobClient{err: errFake}, "text", false, errFake},
+		{"cancelWait", jobCancelFn, &fakeJobClient{states: running, streamErr: errFake}, "text", true, errFake},
+		{"graph", jobGraphFn, &fakeJobClient{err: errFake}, "text", false, errFake},
+		{"messages", jobMessagesFn, &fakeJobClient{err: errFake}, "text", false, errFake},
+		{"messagesStream", jobMessagesFn, &fakeJobClient{streamErr: errFake}, "text", false, errFake},
+		{"metrics", jobMetricsFn, &fakeJobClient{err: errFake}, "text", false, errFake},
+		{"badOutput", jobListFn, &fakeJobClient{}, "yaml", false, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupJobClient(t, test.client, test.output)
+			cancelWait = test.wait
+
+			out, err := runJobCmd(test.fn, "job1")
+			if err == nil {
+				t.Fatalf("%v command succeeded, want error", test.name)
+			}
+			if test.want != nil && !errors.Is(err, test.want) {
+				t.Errorf("%v command failed with %v, want %v", test.name, err, test.want)
+			}
+			if out != "" {
+				t.Errorf("%v command printed %q, want nothing", test.name, out)
+			}
+		})
+	}
+}
+
+func TestJobClient_noEndpoint(t *testing.T) {
+	prev := endpoint
+	defer func() { endpoint = prev }()
+	endpoint = ""
+
+	if _, err := runJobCmd(jobListFn); err == nil {
+		t.Errorf("jobListFn() with no endpoint succeeded, want error")
+	}
+}
@@ -0,0 +1,88 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/pipelinex"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	pipelineCmd = &cobra.Command{
+		Use:   "pipeline",
+		Short: "Pipeline commands",
+	}
+
+	diffCmd = &cobra.Command{
+		Use:   "diff <before> <after>",
+		Short: "Diff two pipeline protos and check update compatibility",
+		Long: `Diff two pipeline protos, in binary or text format, and report the
+changed transforms. Fails if the after pipeline can't update a running job of
+the before pipeline in place.`,
+		RunE: diffFn,
+		Args: cobra.ExactArgs(2),
+	}
+
+	nameMapping map[string]string
+)
+
+func init() {
+	pipelineCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringToStringVar(&nameMapping, "name_mapping", nil, "Mapping of transform names in the before pipeline to those in the after pipeline, such as Old=New. Map to the empty name to remove a transform.")
+}
+
+func diffFn(cmd *cobra.Command, args []string) error {
+	before, err := readPipeline(args[0])
+	if err != nil {
+		return err
+	}
+	after, err := readPipeline(args[1])
+	if err != nil {
+		return err
+	}
+	d, err := pipelinex.Diff(before, after, nameMapping)
+	if err != nil {
+		return err
+	}
+	for _, c := range d.Changes {
+		cmd.Println(c)
+	}
+	return d.Compatible()
+}
+
+// readPipeline reads a pipeline proto from the file, in binary or text format.
+func readPipeline(filename string) (*pipepb.Pipeline, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	// Binary protos are rarely valid text protos, but the reverse doesn't hold.
+	p := &pipepb.Pipeline{}
+	if err := prototext.Unmarshal(data, p); err == nil {
+		return p, nil
+	}
+	p = &pipepb.Pipeline{}
+	if err := proto.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("reading pipeline %v: not a binary or text pipeline proto: %v", filename, err)
+	}
+	return p, nil
+}
@@ -0,0 +1,44 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// localjobserver serves a Beam job service that executes portable pipelines
+// with the local runner. Pipelines are submitted with the universal runner,
+// and must run their SDK harnesses in loopback or external worker pools:
+//
+//	localjobserver --port=8073
+//	my_pipeline --runner=universal --endpoint=localhost:8073 --environment_type=LOOPBACK
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/local"
+)
+
+var port = flag.Int("port", 8073, "Port of the job service.")
+
+func main() {
+	flag.Parse()
+
+	s, err := local.NewServer(*port)
+	if err != nil {
+		log.Fatalf("Failed to start job service: %v", err)
+	}
+	log.Printf("Serving job service at %v", s.Endpoint())
+	if err := s.Serve(); err != nil {
+		log.Fatalf("Job service failed: %v", err)
+	}
+}
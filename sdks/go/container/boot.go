@@ -75,28 +75,16 @@ func main() {
 		log.Fatalf("Failed to convert pipeline options: %v", err)
 	}
 
-	// (2) Retrieve the staged files.
+	// (2) Retrieve the staged files, unless the container holds the worker
+	// binary itself.
 	//
 	// The Go SDK harness downloads the worker binary and invokes
 	// it. The binary is required to be keyed as "worker", if there
 	// are more than one artifact.
 
-	dir := filepath.Join(*semiPersistDir, "staged")
-	artifacts, err := artifact.Materialize(ctx, *artifactEndpoint, info.GetDependencies(), info.GetRetrievalToken(), dir)
+	prog, err := workerBinary(ctx, info)
 	if err != nil {
-		log.Fatalf("Failed to retrieve staged files: %v", err)
-	}
-
-	name, err := getGoWorkerArtifactName(artifacts)
-	if err != nil {
-		log.Fatalf("Failed to get Go Worker Artifact Name: %v", err)
-	}
-
-	// (3) The persist dir may be on a noexec volume, so we must
-	// copy the binary to a different location to execute.
-	const prog = "/bin/worker"
-	if err := copyExe(filepath.Join(dir, name), prog); err != nil {
-		log.Fatalf("Failed to copy worker binary: %v", err)
+		log.Fatal(err)
 	}
 
 	args := []string{
@@ -118,6 +106,65 @@ func main() {
 	log.Fatalf("User program exited: %v", execx.Execute(prog, args...))
 }
 
+// embeddedWorker is the location of the worker binary in worker container
+// images built by the runnerlib package.
+const embeddedWorker = "/opt/apache/beam/worker"
+
+// workerBinary retrieves the staged files, and returns the path of the worker
+// binary to execute. It prefers a worker binary embedded in the container to
+// the staged one, which is logged and not retrieved.
+func workerBinary(ctx context.Context, info *fnpb.ProvisionInfo) (string, error) {
+	dir := filepath.Join(*semiPersistDir, "staged")
+	os.Setenv(artifact.DirEnv, dir)
+
+	if _, err := os.Stat(embeddedWorker); err == nil {
+		log.Printf("Using embedded worker binary %v", embeddedWorker)
+		workers, deps := splitWorkerArtifacts(info.GetDependencies())
+		for _, w := range workers {
+			name, _ := artifact.MustExtractFilePayload(w)
+			log.Printf("Skipping staged worker binary %v in favor of the embedded worker binary", name)
+		}
+		// Retrieve any other staged files, such as declared artifacts.
+		if len(deps) > 0 {
+			if _, err := artifact.Materialize(ctx, *artifactEndpoint, deps, info.GetRetrievalToken(), dir); err != nil {
+				return "", fmt.Errorf("failed to retrieve staged files: %v", err)
+			}
+		}
+		return embeddedWorker, nil
+	}
+
+	artifacts, err := artifact.Materialize(ctx, *artifactEndpoint, info.GetDependencies(), info.GetRetrievalToken(), dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve staged files: %v", err)
+	}
+
+	name, err := getGoWorkerArtifactName(artifacts)
+	if err != nil {
+		return "", fmt.Errorf("failed to get Go Worker Artifact Name: %v", err)
+	}
+
+	// The persist dir may be on a noexec volume, so we must
+	// copy the binary to a different location to execute.
+	const prog = "/bin/worker"
+	if err := copyExe(filepath.Join(dir, name), prog); err != nil {
+		return "", fmt.Errorf("failed to copy worker binary: %v", err)
+	}
+	return prog, nil
+}
+
+// splitWorkerArtifacts separates the staged worker binaries from the other
+// artifacts.
+func splitWorkerArtifacts(artifacts []*pipepb.ArtifactInformation) (workers, others []*pipepb.ArtifactInformation) {
+	for _, a := range artifacts {
+		if a.GetRoleUrn() == artifact.URNGoWorkerBinaryRole {
+			workers = append(workers, a)
+		} else {
+			others = append(others, a)
+		}
+	}
+	return workers, others
+}
+
 func getGoWorkerArtifactName(artifacts []*pipepb.ArtifactInformation) (string, error) {
 	const worker = "worker"
 	name := worker
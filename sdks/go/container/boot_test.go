@@ -148,6 +148,20 @@ func TestGetGoWorkerArtifactName_MultipleArtifactsNoneMatch(t *testing.T) {
 	}
 }
 
+func TestSplitWorkerArtifacts(t *testing.T) {
+	artifact1 := constructArtifactInformation(t, "other role", "test/path", "sha")
+	artifact2 := constructArtifactInformation(t, artifact.URNGoWorkerBinaryRole, "test/worker", "sha")
+	artifacts := []*pipepb.ArtifactInformation{&artifact1, &artifact2}
+
+	workers, others := splitWorkerArtifacts(artifacts)
+	if len(workers) != 1 || workers[0] != &artifact2 {
+		t.Errorf("splitWorkerArtifacts() workers = %v, want [%v]", workers, &artifact2)
+	}
+	if len(others) != 1 || others[0] != &artifact1 {
+		t.Errorf("splitWorkerArtifacts() others = %v, want [%v]", others, &artifact1)
+	}
+}
+
 func TestCopyExe(t *testing.T) {
 	testExeContent := []byte("testContent")
 
@@ -0,0 +1,242 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+// DirEnv is the environment variable holding the directory where the
+// container boot program materializes staged artifacts.
+const DirEnv = "BEAM_ARTIFACT_DIR"
+
+const (
+	// declaredOption is the pipeline option holding the declared artifacts.
+	declaredOption = "beam_declared_artifacts"
+	// declaredPrefix is the directory of declared artifacts, relative to the
+	// staging directory.
+	declaredPrefix = "declared"
+)
+
+// declared is a declared artifact.
+type declared struct {
+	// Local is the absolute path of the artifact at submission.
+	Local string `json:"local"`
+	// Staged is the path of the artifact relative to the staging directory.
+	Staged string `json:"staged"`
+}
+
+// stagedFile is a file staged for declared artifacts.
+type stagedFile struct {
+	local  string
+	sha256 string
+}
+
+var (
+	mu sync.Mutex
+	// artifacts are the declared artifacts, keyed by name.
+	artifacts = make(map[string]declared)
+	// files are the files to stage, keyed by staged name.
+	files = make(map[string]stagedFile)
+)
+
+// Declare declares the file or directory at the given path as an artifact,
+// staged alongside the worker binary. The artifact is resolved from DoFns by
+// name with Path. It must be declared before the pipeline is run.
+//
+// Like pipeline options, declared artifacts are process-wide: they're staged
+// with every pipeline subsequently run by the process, through the
+// environments created by graphx.CreateEnvironment. Programs running several
+// pipelines with different artifacts must call Reset in between.
+//
+// Artifacts are staged by content: identical files or directories declared
+// under several names are staged once. Redeclaring a name with different
+// content fails.
+func Declare(name, p string) error {
+	local, err := filepath.Abs(p)
+	if err != nil {
+		return errors.Wrapf(err, "invalid path for artifact %v", name)
+	}
+	staged, toStage, err := hashArtifact(local)
+	if err != nil {
+		return errors.WithContextf(err, "declaring artifact %v", name)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if prev, ok := artifacts[name]; ok && prev.Staged != staged {
+		return errors.Errorf("artifact %v already declared as %v, with different content than %v", name, prev.Local, local)
+	}
+	artifacts[name] = declared{Local: local, Staged: staged}
+	for n, f := range toStage {
+		files[n] = f
+	}
+
+	data, err := json.Marshal(artifacts)
+	if err != nil {
+		return err
+	}
+	runtime.GlobalOptions.Set(declaredOption, string(data))
+	return nil
+}
+
+// Reset forgets all declared artifacts, so they're no longer staged with
+// pipelines run afterwards.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	artifacts = make(map[string]declared)
+	files = make(map[string]stagedFile)
+	runtime.GlobalOptions.Set(declaredOption, "")
+}
+
+// hashArtifact returns the staged path of the file or directory, and the
+// files to stage for it. Both are derived from the content.
+func hashArtifact(local string) (string, map[string]stagedFile, error) {
+	info, err := os.Stat(local)
+	if err != nil {
+		return "", nil, err
+	}
+	if !info.IsDir() {
+		hash, err := hashFile(local)
+		if err != nil {
+			return "", nil, err
+		}
+		staged := path.Join(declaredPrefix, hash, filepath.Base(local))
+		return staged, map[string]stagedFile{staged: {local: local, sha256: hash}}, nil
+	}
+
+	// Directories are identified by the names and hashes of their files.
+	// WalkDir visits them in lexical order, so the hash is deterministic.
+	type entry struct{ rel, hash string }
+	var entries []entry
+	err = filepath.WalkDir(local, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return errors.Errorf("%v is not a regular file", p)
+		}
+		hash, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(local, p)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{filepath.ToSlash(rel), hash})
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	h := sha256.New()
+	for _, e := range entries {
+		fmt.Fprintf(h, "%v\x00%v\n", e.rel, e.hash)
+	}
+	staged := path.Join(declaredPrefix, hex.EncodeToString(h.Sum(nil)))
+	ret := make(map[string]stagedFile)
+	for _, e := range entries {
+		ret[path.Join(staged, e.rel)] = stagedFile{local: filepath.Join(local, filepath.FromSlash(e.rel)), sha256: e.hash}
+	}
+	return staged, ret, nil
+}
+
+func hashFile(filename string) (string, error) {
+	fd, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer fd.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, fd); err != nil {
+		return "", errors.Wrapf(err, "failed to read %v", filename)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Path returns the local path of the declared artifact with the given name.
+// In workers, it is the path of the artifact materialized by the container.
+// Otherwise, such as with the direct runner or in loopback mode, it is the
+// declared path.
+func Path(name string) (string, error) {
+	var artifacts map[string]declared
+	if opt := runtime.GlobalOptions.Get(declaredOption); opt != "" {
+		if err := json.Unmarshal([]byte(opt), &artifacts); err != nil {
+			return "", errors.Wrap(err, "invalid declared artifacts")
+		}
+	}
+	a, ok := artifacts[name]
+	if !ok {
+		return "", errors.Errorf("artifact %v not declared", name)
+	}
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return filepath.Join(dir, filepath.FromSlash(a.Staged)), nil
+	}
+	return a.Local, nil
+}
+
+// Declared returns the dependencies staging the files of the artifacts
+// declared in this process, ordered by staged name.
+func Declared() []*pipepb.ArtifactInformation {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ret []*pipepb.ArtifactInformation
+	for _, name := range names {
+		f := files[name]
+		ret = append(ret, &pipepb.ArtifactInformation{
+			TypeUrn:     URNFileArtifact,
+			TypePayload: mustMarshal(&pipepb.ArtifactFilePayload{Path: f.local, Sha256: f.sha256}),
+			RoleUrn:     URNStagingTo,
+			RolePayload: mustMarshal(&pipepb.ArtifactStagingToRolePayload{StagedName: name}),
+		})
+	}
+	return ret
+}
+
+func mustMarshal(msg proto.Message) []byte {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -0,0 +1,135 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestDeclare(t *testing.T) {
+	prev := runtime.GlobalOptions.Get(declaredOption)
+	defer func() {
+		Reset()
+		runtime.GlobalOptions.Set(declaredOption, prev)
+	}()
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	model := write("model.bin", "weights")
+	copied := write("copy/model.bin", "weights")
+	write("table/a.csv", "a")
+	write("table/sub/b.csv", "b")
+	table := filepath.Join(dir, "table")
+
+	for name, p := range map[string]string{"model": model, "copy": copied, "table": table} {
+		if err := Declare(name, p); err != nil {
+			t.Fatalf("Declare(%v) failed: %v", name, err)
+		}
+	}
+	if err := Declare("model", model); err != nil {
+		t.Errorf("Declare(model) again failed: %v", err)
+	}
+	if err := Declare("model", filepath.Join(table, "a.csv")); err == nil {
+		t.Errorf("Declare(model) with different content succeeded, want error")
+	}
+	if err := Declare("missing", filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("Declare(missing) succeeded, want error")
+	}
+
+	// Identical files are staged once.
+	var staged []string
+	for _, dep := range Declared() {
+		var role pipepb.ArtifactStagingToRolePayload
+		if err := proto.Unmarshal(dep.GetRolePayload(), &role); err != nil {
+			t.Fatal(err)
+		}
+		staged = append(staged, role.GetStagedName())
+	}
+	if len(staged) != 3 {
+		t.Fatalf("Declared() staged %v, want 3 files", staged)
+	}
+
+	if got, err := Path("copy"); err != nil || got != copied {
+		t.Errorf("Path(copy) = %v, %v, want %v", got, err, copied)
+	}
+	if _, err := Path("missing"); err == nil {
+		t.Errorf("Path(missing) succeeded, want error")
+	}
+
+	// In workers, artifacts resolve to the materialized files.
+	t.Setenv(DirEnv, "/staged")
+	m, err := Path("model")
+	if err != nil {
+		t.Fatalf("Path(model) failed: %v", err)
+	}
+	c, _ := Path("copy")
+	if m != c || !strings.HasPrefix(m, "/staged/declared/") || filepath.Base(m) != "model.bin" {
+		t.Errorf("Path(model), Path(copy) = %v, %v, want the same staged file", m, c)
+	}
+	tp, err := Path("table")
+	if err != nil {
+		t.Fatalf("Path(table) failed: %v", err)
+	}
+	prefix := strings.TrimPrefix(tp, "/staged/") + "/"
+	var tableFiles []string
+	for _, s := range staged {
+		if strings.HasPrefix(s, prefix) {
+			tableFiles = append(tableFiles, strings.TrimPrefix(s, prefix))
+		}
+	}
+	if want := []string{"a.csv", "sub/b.csv"}; !reflect.DeepEqual(tableFiles, want) {
+		t.Errorf("staged files of table = %v, want %v", tableFiles, want)
+	}
+}
+
+func TestReset(t *testing.T) {
+	prev := runtime.GlobalOptions.Get(declaredOption)
+	defer runtime.GlobalOptions.Set(declaredOption, prev)
+
+	model := filepath.Join(t.TempDir(), "model.bin")
+	if err := os.WriteFile(model, []byte("weights"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Declare("model", model); err != nil {
+		t.Fatalf("Declare(model) failed: %v", err)
+	}
+	Reset()
+
+	if got := Declared(); len(got) != 0 {
+		t.Errorf("Declared() after Reset() = %v, want none", got)
+	}
+	if _, err := Path("model"); err == nil {
+		t.Errorf("Path(model) after Reset() succeeded, want error")
+	}
+}
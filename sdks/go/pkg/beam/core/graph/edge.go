@@ -23,6 +23,7 @@ import (
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/funcx"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/resource"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
@@ -161,6 +162,7 @@ type MultiEdge struct {
 	External         *ExternalTransform // Current External Transforms API
 	Payload          *Payload           // Legacy External Transforms API
 	WindowFn         *window.Fn         // WindowInto
+	ResourceHints    resource.Hints     // ParDo
 
 	Input  []*Inbound
 	Output []*Outbound
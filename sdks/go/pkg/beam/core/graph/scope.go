@@ -15,6 +15,8 @@
 
 package graph
 
+import "github.com/apache/beam/sdks/v2/go/pkg/beam/core/resource"
+
 // Scope is a syntactic Scope, such as arising from a composite Transform. It
 // has no semantic meaning at execution time. Used by monitoring.
 type Scope struct {
@@ -24,6 +26,8 @@ type Scope struct {
 	Label string
 	// Parent is the parent scope, if nested.
 	Parent *Scope
+	// ResourceHints are the resource hints of the transforms in this scope.
+	ResourceHints resource.Hints
 }
 
 // ID returns the graph-local identifier for the scope.
@@ -74,6 +74,16 @@ func dumperExtractor(store *Store, p func(format string, args ...interface{})) {
 		GaugeInt64: func(l Labels, v int64, t time.Time) {
 			m[l] = &gauge{v: v, t: t}
 		},
+		HistogramInt64: func(l Labels, v HistogramValue) {
+			m[l] = &histogram{boundaries: v.Boundaries, counts: v.Counts, sum: v.Sum}
+		},
+		StringSet: func(l Labels, values []string) {
+			set := make(map[string]struct{}, len(values))
+			for _, v := range values {
+				set[v] = struct{}{}
+			}
+			m[l] = &stringSet{set: set}
+		},
 		MsecsInt64: func(labels string, e *[4]ExecutionState) {},
 	}
 	e.ExtractFrom(store)
@@ -69,6 +69,7 @@ type ctxKey string
 const (
 	counterSetKey ctxKey = "beam:counterset"
 	storeKey      ctxKey = "beam:bundlestore"
+	beamCtxKey    ctxKey = "beam:ctx"
 )
 
 // beamCtx is a caching context for IDs necessary to place metric updates.
@@ -98,6 +99,8 @@ func (ctx *beamCtx) Value(key interface{}) interface{} {
 					counters:      make(map[nameHash]*counter),
 					distributions: make(map[nameHash]*distribution),
 					gauges:        make(map[nameHash]*gauge),
+					histograms:    make(map[nameHash]*histogram),
+					stringSets:    make(map[nameHash]*stringSet),
 				}
 				ctx.store.css = append(ctx.store.css, cs)
 				ctx.cs = cs
@@ -112,6 +115,8 @@ func (ctx *beamCtx) Value(key interface{}) interface{} {
 			}
 		}
 		return ctx.store
+	case beamCtxKey:
+		return ctx
 	}
 	return ctx.Context.Value(key)
 }
@@ -147,6 +152,32 @@ func SetPTransformID(ctx context.Context, id string) context.Context {
 	return &beamCtx{Context: ctx, bundleID: bundleIDUnset, store: newStore(), ptransformID: id}
 }
 
+// GetBundleID returns the id of the current bundle, if set.
+func GetBundleID(ctx context.Context) (string, bool) {
+	if bctx := getBeamCtx(ctx); bctx != nil && bctx.bundleID != bundleIDUnset {
+		return bctx.bundleID, true
+	}
+	return "", false
+}
+
+// GetPTransformID returns the id of the current PTransform, if set.
+func GetPTransformID(ctx context.Context) (string, bool) {
+	if bctx := getBeamCtx(ctx); bctx != nil && bctx.ptransformID != "" {
+		return bctx.ptransformID, true
+	}
+	return "", false
+}
+
+func getBeamCtx(ctx context.Context) *beamCtx {
+	if bctx, ok := ctx.(*beamCtx); ok {
+		return bctx
+	}
+	if v := ctx.Value(beamCtxKey); v != nil {
+		return v.(*beamCtx)
+	}
+	return nil
+}
+
 // GetStore extracts the metrics Store for the given context for a bundle.
 //
 // Returns nil if the context doesn't contain a metric Store.
@@ -185,6 +216,8 @@ const (
 	kindDistribution
 	kindGauge
 	kindDoFnMsec
+	kindHistogram
+	kindStringSet
 )
 
 func (t kind) String() string {
@@ -197,6 +230,10 @@ func (t kind) String() string {
 		return "Gauge"
 	case kindDoFnMsec:
 		return "DoFnMsec"
+	case kindHistogram:
+		return "Histogram"
+	case kindStringSet:
+		return "StringSet"
 	default:
 		panic(fmt.Sprintf("Unknown metric type value: %v", uint8(t)))
 	}
@@ -288,8 +325,11 @@ func (m *Counter) Inc(ctx context.Context, v int64) {
 	c := &counter{
 		value: v,
 	}
+	if stored := GetStore(ctx).storeMetric(cs.pid, m.name, c).(*counter); stored != c {
+		stored.inc(v)
+		c = stored
+	}
 	cs.counters[m.hash] = c
-	GetStore(ctx).storeMetric(cs.pid, m.name, c)
 }
 
 // Dec decrements the counter within the given PTransform context by v.
@@ -353,8 +393,11 @@ func (m *Distribution) Update(ctx context.Context, v int64) {
 		min:   v,
 		max:   v,
 	}
+	if stored := GetStore(ctx).storeMetric(cs.pid, m.name, d).(*distribution); stored != d {
+		stored.update(v)
+		d = stored
+	}
 	cs.distributions[m.hash] = d
-	GetStore(ctx).storeMetric(cs.pid, m.name, d)
 }
 
 // distribution is a metric cell for distribution values.
@@ -431,8 +474,11 @@ func (m *Gauge) Set(ctx context.Context, v int64) {
 		t: now(),
 		v: v,
 	}
+	if stored := GetStore(ctx).storeMetric(cs.pid, m.name, g).(*gauge); stored != g {
+		stored.set(v)
+		g = stored
+	}
 	cs.gauges[m.hash] = g
-	GetStore(ctx).storeMetric(cs.pid, m.name, g)
 }
 
 // gauge is a metric cell for gauge values.
@@ -469,6 +515,259 @@ type GaugeValue struct {
 	Timestamp time.Time
 }
 
+// Histogram counts values in buckets with configurable boundaries, such as
+// for reporting percentile latencies.
+type Histogram struct {
+	name       name
+	hash       nameHash
+	boundaries []int64
+}
+
+func (m *Histogram) String() string {
+	return fmt.Sprintf("Histogram metric %s", m.name)
+}
+
+// NewHistogram returns the Histogram with the given namespace, name and
+// bucket boundaries, which must be strictly increasing. A value v is counted
+// in bucket i if boundaries[i-1] <= v < boundaries[i], so there are
+// len(boundaries)+1 buckets, the first and last of which are unbounded below
+// and above respectively.
+//
+// All uses of a histogram in a PTransform must use the same boundaries.
+func NewHistogram(ns, n string, boundaries []int64) *Histogram {
+	if len(boundaries) == 0 {
+		panic(fmt.Sprintf("histogram %s.%s requires bucket boundaries", ns, n))
+	}
+	for i := 1; i < len(boundaries); i++ {
+		if boundaries[i] <= boundaries[i-1] {
+			panic(fmt.Sprintf("histogram %s.%s boundaries must be strictly increasing, got %v", ns, n, boundaries))
+		}
+	}
+	return &Histogram{
+		name:       newName(ns, n),
+		hash:       hashName(ns, n),
+		boundaries: append([]int64(nil), boundaries...),
+	}
+}
+
+// LinearBuckets returns n bucket boundaries, starting at start, each width
+// apart.
+func LinearBuckets(start, width int64, n int) []int64 {
+	b := make([]int64, n)
+	for i := range b {
+		b[i] = start + int64(i)*width
+	}
+	return b
+}
+
+// ExponentialBuckets returns n bucket boundaries, starting at start, each
+// factor times the previous one. Start must be positive, and factor greater
+// than one.
+func ExponentialBuckets(start int64, factor float64, n int) []int64 {
+	b := make([]int64, n)
+	v := float64(start)
+	for i := range b {
+		b[i] = int64(v)
+		if i > 0 && b[i] <= b[i-1] {
+			b[i] = b[i-1] + 1
+		}
+		v *= factor
+	}
+	return b
+}
+
+// Update counts v within the given PTransform context.
+func (m *Histogram) Update(ctx context.Context, v int64) {
+	cs := getCounterSet(ctx)
+	if cs == nil {
+		return
+	}
+	if h, ok := cs.histograms[m.hash]; ok {
+		h.update(v)
+		return
+	}
+	// We're the first to create this metric!
+	h := &histogram{
+		boundaries: m.boundaries,
+		counts:     make([]int64, len(m.boundaries)+1),
+	}
+	h.update(v)
+	if stored := GetStore(ctx).storeMetric(cs.pid, m.name, h).(*histogram); stored != h {
+		stored.update(v)
+		h = stored
+	}
+	cs.histograms[m.hash] = h
+}
+
+// histogram is a metric cell for histogram values.
+type histogram struct {
+	mu         sync.Mutex
+	boundaries []int64 // immutable, shared with the Histogram.
+	counts     []int64
+	sum        int64
+}
+
+func (m *histogram) update(v int64) {
+	i := sort.Search(len(m.boundaries), func(i int) bool { return v < m.boundaries[i] })
+	m.mu.Lock()
+	m.counts[i]++
+	m.sum += v
+	m.mu.Unlock()
+}
+
+func (m *histogram) String() string {
+	v := m.get()
+	return fmt.Sprintf("boundaries: %v counts: %v sum: %d", v.Boundaries, v.Counts, v.Sum)
+}
+
+func (m *histogram) kind() kind {
+	return kindHistogram
+}
+
+func (m *histogram) get() HistogramValue {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return HistogramValue{
+		Boundaries: m.boundaries,
+		Counts:     append([]int64(nil), m.counts...),
+		Sum:        m.sum,
+	}
+}
+
+// HistogramValue is the value of a Histogram metric. Counts has one more
+// element than Boundaries, as described for NewHistogram.
+type HistogramValue struct {
+	Boundaries []int64
+	Counts     []int64
+	Sum        int64
+}
+
+// Count returns the total number of values in the histogram.
+func (v HistogramValue) Count() int64 {
+	var n int64
+	for _, c := range v.Counts {
+		n += c
+	}
+	return n
+}
+
+// merge returns the combination of two values of the same histogram.
+func (v HistogramValue) merge(o HistogramValue) HistogramValue {
+	if len(v.Counts) == 0 {
+		return o
+	}
+	if len(o.Counts) == 0 {
+		return v
+	}
+	counts := append([]int64(nil), v.Counts...)
+	for i, c := range o.Counts {
+		if i < len(counts) {
+			counts[i] += c
+		}
+	}
+	return HistogramValue{Boundaries: v.Boundaries, Counts: counts, Sum: v.Sum + o.Sum}
+}
+
+// StringSet is a set of distinct string values, such as the error codes
+// that occurred.
+type StringSet struct {
+	name name
+	hash nameHash
+}
+
+func (m *StringSet) String() string {
+	return fmt.Sprintf("StringSet metric %s", m.name)
+}
+
+// NewStringSet returns the StringSet with the given namespace and name.
+func NewStringSet(ns, n string) *StringSet {
+	return &StringSet{
+		name: newName(ns, n),
+		hash: hashName(ns, n),
+	}
+}
+
+// Add adds v to the set within the given PTransform context.
+func (m *StringSet) Add(ctx context.Context, v string) {
+	cs := getCounterSet(ctx)
+	if cs == nil {
+		return
+	}
+	if s, ok := cs.stringSets[m.hash]; ok {
+		s.add(v)
+		return
+	}
+	// We're the first to create this metric!
+	s := &stringSet{
+		set: map[string]struct{}{v: {}},
+	}
+	if stored := GetStore(ctx).storeMetric(cs.pid, m.name, s).(*stringSet); stored != s {
+		stored.add(v)
+		s = stored
+	}
+	cs.stringSets[m.hash] = s
+}
+
+// stringSet is a metric cell for string set values.
+type stringSet struct {
+	mu  sync.Mutex
+	set map[string]struct{}
+}
+
+func (m *stringSet) add(v string) {
+	m.mu.Lock()
+	m.set[v] = struct{}{}
+	m.mu.Unlock()
+}
+
+func (m *stringSet) String() string {
+	return fmt.Sprintf("values: %q", m.get())
+}
+
+func (m *stringSet) kind() kind {
+	return kindStringSet
+}
+
+// get returns the values of the set in sorted order.
+func (m *stringSet) get() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	vs := make([]string, 0, len(m.set))
+	for v := range m.set {
+		vs = append(vs, v)
+	}
+	sort.Strings(vs)
+	return vs
+}
+
+// mergeStringSets returns the sorted union of two sorted string sets.
+func mergeStringSets(a, b []string) []string {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+	vs := make([]string, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			vs = append(vs, a[i])
+			i++
+		case a[i] > b[j]:
+			vs = append(vs, b[j])
+			j++
+		default:
+			vs = append(vs, a[i])
+			i++
+			j++
+		}
+	}
+	vs = append(vs, a[i:]...)
+	return append(vs, b[j:]...)
+}
+
 type executionState struct {
 	state *[4]ExecutionState
 }
@@ -500,6 +799,8 @@ type Results struct {
 	gauges        []GaugeResult
 	msecs         []MsecResult
 	pCols         []PColResult
+	histograms    []HistogramResult
+	stringSets    []StringSetResult
 }
 
 // NewResults creates a new Results.
@@ -508,8 +809,10 @@ func NewResults(
 	distributions []DistributionResult,
 	gauges []GaugeResult,
 	msecs []MsecResult,
-	pCols []PColResult) *Results {
-	return &Results{counters, distributions, gauges, msecs, pCols}
+	pCols []PColResult,
+	histograms []HistogramResult,
+	stringSets []StringSetResult) *Results {
+	return &Results{counters, distributions, gauges, msecs, pCols, histograms, stringSets}
 }
 
 // AllMetrics returns all metrics from a Results instance.
@@ -534,6 +837,8 @@ func (mr Results) Query(f func(SingleResult) bool) QueryResults {
 	gauges := []GaugeResult{}
 	msecs := []MsecResult{}
 	pCols := []PColResult{}
+	histograms := []HistogramResult{}
+	stringSets := []StringSetResult{}
 
 	for _, counter := range mr.counters {
 		if f(counter) {
@@ -560,7 +865,17 @@ func (mr Results) Query(f func(SingleResult) bool) QueryResults {
 			pCols = append(pCols, pCol)
 		}
 	}
-	return QueryResults{counters: counters, distributions: distributions, gauges: gauges, msecs: msecs, pCols: pCols}
+	for _, histogram := range mr.histograms {
+		if f(histogram) {
+			histograms = append(histograms, histogram)
+		}
+	}
+	for _, stringSet := range mr.stringSets {
+		if f(stringSet) {
+			stringSets = append(stringSets, stringSet)
+		}
+	}
+	return QueryResults{counters: counters, distributions: distributions, gauges: gauges, msecs: msecs, pCols: pCols, histograms: histograms, stringSets: stringSets}
 }
 
 // QueryResults is the result of a query. Allows accessing all of the
@@ -571,6 +886,8 @@ type QueryResults struct {
 	gauges        []GaugeResult
 	msecs         []MsecResult
 	pCols         []PColResult
+	histograms    []HistogramResult
+	stringSets    []StringSetResult
 }
 
 // Counters returns a slice of counter metrics.
@@ -608,6 +925,20 @@ func (qr QueryResults) PCols() []PColResult {
 	return out
 }
 
+// Histograms returns a slice of histogram metrics.
+func (qr QueryResults) Histograms() []HistogramResult {
+	out := make([]HistogramResult, len(qr.histograms))
+	copy(out, qr.histograms)
+	return out
+}
+
+// StringSets returns a slice of string set metrics.
+func (qr QueryResults) StringSets() []StringSetResult {
+	out := make([]StringSetResult, len(qr.stringSets))
+	copy(out, qr.stringSets)
+	return out
+}
+
 // CounterResult is an attempted and a commited value of a counter metric plus
 // key.
 type CounterResult struct {
@@ -885,6 +1216,114 @@ func MergeMsecs(
 	return res
 }
 
+// HistogramResult is an attempted and a commited value of a histogram
+// metric plus key.
+type HistogramResult struct {
+	Attempted, Committed HistogramValue
+	Key                  StepKey
+}
+
+// Result returns committed metrics. Falls back to attempted metrics if committed
+// are not populated (e.g. due to not being supported on a given runner).
+func (r HistogramResult) Result() HistogramValue {
+	if len(r.Committed.Counts) != 0 {
+		return r.Committed
+	}
+	return r.Attempted
+}
+
+// Name returns the Name of this Histogram.
+func (r HistogramResult) Name() string {
+	return r.Key.Name
+}
+
+// Namespace returns the Namespace of this Histogram.
+func (r HistogramResult) Namespace() string {
+	return r.Key.Namespace
+}
+
+// Transform returns the Transform step for this HistogramResult.
+func (r HistogramResult) Transform() string { return r.Key.Step }
+
+// MergeHistograms combines histogram metrics that share a common key.
+func MergeHistograms(
+	attempted map[StepKey]HistogramValue,
+	committed map[StepKey]HistogramValue) []HistogramResult {
+	res := make([]HistogramResult, 0)
+	merged := map[StepKey]HistogramResult{}
+
+	for k, v := range attempted {
+		merged[k] = HistogramResult{Attempted: v, Key: k}
+	}
+	for k, v := range committed {
+		m, ok := merged[k]
+		if ok {
+			merged[k] = HistogramResult{Attempted: m.Attempted, Committed: v, Key: k}
+		} else {
+			merged[k] = HistogramResult{Committed: v, Key: k}
+		}
+	}
+
+	for _, v := range merged {
+		res = append(res, v)
+	}
+	return res
+}
+
+// StringSetResult is an attempted and a commited value of a string set
+// metric plus key. Values are in sorted order.
+type StringSetResult struct {
+	Attempted, Committed []string
+	Key                  StepKey
+}
+
+// Result returns committed metrics. Falls back to attempted metrics if committed
+// are not populated (e.g. due to not being supported on a given runner).
+func (r StringSetResult) Result() []string {
+	if len(r.Committed) != 0 {
+		return r.Committed
+	}
+	return r.Attempted
+}
+
+// Name returns the Name of this StringSet.
+func (r StringSetResult) Name() string {
+	return r.Key.Name
+}
+
+// Namespace returns the Namespace of this StringSet.
+func (r StringSetResult) Namespace() string {
+	return r.Key.Namespace
+}
+
+// Transform returns the Transform step for this StringSetResult.
+func (r StringSetResult) Transform() string { return r.Key.Step }
+
+// MergeStringSets combines string set metrics that share a common key.
+func MergeStringSets(
+	attempted map[StepKey][]string,
+	committed map[StepKey][]string) []StringSetResult {
+	res := make([]StringSetResult, 0)
+	merged := map[StepKey]StringSetResult{}
+
+	for k, v := range attempted {
+		merged[k] = StringSetResult{Attempted: v, Key: k}
+	}
+	for k, v := range committed {
+		m, ok := merged[k]
+		if ok {
+			merged[k] = StringSetResult{Attempted: m.Attempted, Committed: v, Key: k}
+		} else {
+			merged[k] = StringSetResult{Committed: v, Key: k}
+		}
+	}
+
+	for _, v := range merged {
+		res = append(res, v)
+	}
+	return res
+}
+
 // ResultsExtractor extracts the metrics.Results from Store using ctx.
 // This is same as what metrics.dumperExtractor and metrics.dumpTo would do together.
 func ResultsExtractor(ctx context.Context) Results {
@@ -900,6 +1339,12 @@ func ResultsExtractor(ctx context.Context) Results {
 		GaugeInt64: func(l Labels, v int64, t time.Time) {
 			m[l] = &gauge{v: v, t: t}
 		},
+		HistogramInt64: func(l Labels, v HistogramValue) {
+			m[l] = v
+		},
+		StringSet: func(l Labels, values []string) {
+			m[l] = values
+		},
 		MsecsInt64: func(labels string, e *[4]ExecutionState) {
 			m[PTransformLabels(labels)] = &executionState{state: e}
 		},
@@ -926,7 +1371,7 @@ func ResultsExtractor(ctx context.Context) Results {
 		return false
 	})
 
-	r := Results{counters: []CounterResult{}, distributions: []DistributionResult{}, gauges: []GaugeResult{}, msecs: []MsecResult{}}
+	r := Results{counters: []CounterResult{}, distributions: []DistributionResult{}, gauges: []GaugeResult{}, msecs: []MsecResult{}, histograms: []HistogramResult{}, stringSets: []StringSetResult{}}
 	for _, l := range ls {
 		key := StepKey{Step: l.transform, Name: l.name, Namespace: l.namespace}
 		switch opt := m[l]; opt.(type) {
@@ -955,6 +1400,12 @@ func ResultsExtractor(ctx context.Context) Results {
 			es := opt.(*executionState).state
 			committed[key] = MsecValue{Start: es[0].TotalTime, Process: es[1].TotalTime, Finish: es[2].TotalTime, Total: es[3].TotalTime}
 			r.msecs = append(r.msecs, MergeMsecs(attempted, committed)...)
+		case HistogramValue:
+			committed := map[StepKey]HistogramValue{key: opt.(HistogramValue)}
+			r.histograms = append(r.histograms, MergeHistograms(nil, committed)...)
+		case []string:
+			committed := map[StepKey][]string{key: opt.([]string)}
+			r.stringSets = append(r.stringSets, MergeStringSets(nil, committed)...)
 		}
 	}
 	return r
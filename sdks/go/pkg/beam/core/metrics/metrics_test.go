@@ -133,6 +133,23 @@ func TestCounter_Dec(t *testing.T) {
 	}
 }
 
+// TestCounter_SharedStore validates that contexts of a PTransform sharing a
+// Store, such as for successive bundles, update the same counter.
+func TestCounter_SharedStore(t *testing.T) {
+	ctx := SetBundleID(context.Background(), bID)
+	m := NewCounter("shared", "count")
+	m.Inc(SetPTransformID(ctx, "A"), 1)
+	m.Inc(SetPTransformID(ctx, "A"), 2)
+
+	qr := ResultsExtractor(ctx).Query(func(sr SingleResult) bool { return sr.Namespace() == "shared" })
+	if got, want := len(qr.Counters()), 1; got != want {
+		t.Fatalf("len(Counters()) = %v, want %v", got, want)
+	}
+	if got, want := qr.Counters()[0].Committed, int64(3); got != want {
+		t.Errorf("Counters()[0].Committed = %v, want %v", got, want)
+	}
+}
+
 func TestDistribution_Update(t *testing.T) {
 	ctxA := ctxWith(bID, "A")
 	ctxB := ctxWith(bID, "B")
@@ -239,6 +256,88 @@ func TestGauge_Set(t *testing.T) {
 	}
 }
 
+func TestHistogram_Update(t *testing.T) {
+	ctx := ctxWith(bID, "A")
+	m := NewHistogram("hist", "latency", []int64{10, 100})
+	for _, v := range []int64{-5, 9, 10, 99, 100, 1000} {
+		m.Update(ctx, v)
+	}
+	got := getCounterSet(ctx).histograms[m.hash].get()
+	want := HistogramValue{Boundaries: []int64{10, 100}, Counts: []int64{2, 2, 2}, Sum: 1213}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("histogram value diff (-want, +got):\n%v", d)
+	}
+	if got, want := got.Count(), int64(6); got != want {
+		t.Errorf("Count() = %v, want %v", got, want)
+	}
+}
+
+func TestNewHistogram_BadBoundaries(t *testing.T) {
+	for _, b := range [][]int64{nil, {10, 10}, {10, 5}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewHistogram with boundaries %v didn't panic", b)
+				}
+			}()
+			NewHistogram("hist", "bad", b)
+		}()
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	if got, want := LinearBuckets(5, 10, 3), []int64{5, 15, 25}; !cmp.Equal(got, want) {
+		t.Errorf("LinearBuckets(5, 10, 3) = %v, want %v", got, want)
+	}
+	if got, want := ExponentialBuckets(1, 1.5, 5), []int64{1, 2, 3, 4, 5}; !cmp.Equal(got, want) {
+		t.Errorf("ExponentialBuckets(1, 1.5, 5) = %v, want %v", got, want)
+	}
+	if got, want := ExponentialBuckets(1, 10, 4), []int64{1, 10, 100, 1000}; !cmp.Equal(got, want) {
+		t.Errorf("ExponentialBuckets(1, 10, 4) = %v, want %v", got, want)
+	}
+}
+
+func TestStringSet_Add(t *testing.T) {
+	ctxA := ctxWith(bID, "A")
+	ctxB := ctxWith(bID, "B")
+	m := NewStringSet("set", "codes")
+	m.Add(ctxA, "NOT_FOUND")
+	m.Add(ctxA, "INTERNAL")
+	m.Add(ctxA, "NOT_FOUND")
+	m.Add(ctxB, "OK")
+
+	if got, want := getCounterSet(ctxA).stringSets[m.hash].get(), []string{"INTERNAL", "NOT_FOUND"}; !cmp.Equal(got, want) {
+		t.Errorf("string set in A = %v, want %v", got, want)
+	}
+	if got, want := getCounterSet(ctxB).stringSets[m.hash].get(), []string{"OK"}; !cmp.Equal(got, want) {
+		t.Errorf("string set in B = %v, want %v", got, want)
+	}
+}
+
+func TestResultsExtractor_HistogramsAndStringSets(t *testing.T) {
+	ctx := ctxWith(bID, "A")
+	NewHistogram("ns", "hist", []int64{10}).Update(ctx, 3)
+	NewStringSet("ns", "set").Add(ctx, "x")
+
+	qr := ResultsExtractor(ctx).Query(func(sr SingleResult) bool { return sr.Namespace() == "ns" })
+	key := StepKey{Step: "A", Name: "hist", Namespace: "ns"}
+	wantH := []HistogramResult{{Committed: HistogramValue{Boundaries: []int64{10}, Counts: []int64{1, 0}, Sum: 3}, Key: key}}
+	if d := cmp.Diff(wantH, qr.Histograms()); d != "" {
+		t.Errorf("histograms diff (-want, +got):\n%v", d)
+	}
+	key.Name = "set"
+	wantS := []StringSetResult{{Committed: []string{"x"}, Key: key}}
+	if d := cmp.Diff(wantS, qr.StringSets()); d != "" {
+		t.Errorf("string sets diff (-want, +got):\n%v", d)
+	}
+}
+
+func TestMergeStringSets(t *testing.T) {
+	if got, want := mergeStringSets([]string{"a", "c"}, []string{"b", "c", "d"}), []string{"a", "b", "c", "d"}; !cmp.Equal(got, want) {
+		t.Errorf("mergeStringSets = %v, want %v", got, want)
+	}
+}
+
 func TestNameCollisions(t *testing.T) {
 	ns, c, d, g := "collisions", "counter", "distribution", "gauge"
 	// Checks that user code panics if a counter attempts to be defined in the same PTransform
@@ -576,3 +675,23 @@ func BenchmarkMetrics(b *testing.B) {
 		})
 	}
 }
+
+func TestGetIDs(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := GetBundleID(ctx); ok {
+		t.Error("GetBundleID() on a plain context succeeded, want failure")
+	}
+	bctx := SetBundleID(ctx, "bundle")
+	if _, ok := GetPTransformID(bctx); ok {
+		t.Error("GetPTransformID() without a PTransform succeeded, want failure")
+	}
+	// IDs are found through contexts derived by users.
+	pctx, cancel := context.WithCancel(SetPTransformID(bctx, "pt"))
+	defer cancel()
+	if id, ok := GetBundleID(pctx); !ok || id != "bundle" {
+		t.Errorf("GetBundleID() = %v, %v, want bundle, true", id, ok)
+	}
+	if id, ok := GetPTransformID(pctx); !ok || id != "pt" {
+		t.Errorf("GetPTransformID() = %v, %v, want pt, true", id, ok)
+	}
+}
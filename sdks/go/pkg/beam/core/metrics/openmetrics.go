@@ -0,0 +1,325 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenMetricsContentType is the content type of the OpenMetrics text format.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Accumulator sums the metrics of many bundles, so they may be reported
+// as cumulative values over the lifetime of a process, such as a worker.
+// Counters, distributions, histograms, execution times and element counts
+// are summed, string sets are unioned, and gauges retain their most recent
+// value.
+//
+// An Accumulator is safe for concurrent use.
+type Accumulator struct {
+	mu            sync.Mutex
+	counters      map[StepKey]int64
+	distributions map[StepKey]DistributionValue
+	gauges        map[StepKey]GaugeValue
+	msecs         map[StepKey]MsecValue
+	pcols         map[StepKey]PColValue
+	histograms    map[StepKey]HistogramValue
+	stringSets    map[StepKey][]string
+}
+
+// NewAccumulator returns an empty Accumulator.
+func NewAccumulator() *Accumulator {
+	return &Accumulator{
+		counters:      make(map[StepKey]int64),
+		distributions: make(map[StepKey]DistributionValue),
+		gauges:        make(map[StepKey]GaugeValue),
+		msecs:         make(map[StepKey]MsecValue),
+		pcols:         make(map[StepKey]PColValue),
+		histograms:    make(map[StepKey]HistogramValue),
+		stringSets:    make(map[StepKey][]string),
+	}
+}
+
+// Clone returns a copy of the Accumulator, which may be added to without
+// affecting the original.
+func (a *Accumulator) Clone() *Accumulator {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c := NewAccumulator()
+	for k, v := range a.counters {
+		c.counters[k] = v
+	}
+	for k, v := range a.distributions {
+		c.distributions[k] = v
+	}
+	for k, v := range a.gauges {
+		c.gauges[k] = v
+	}
+	for k, v := range a.msecs {
+		c.msecs[k] = v
+	}
+	for k, v := range a.pcols {
+		c.pcols[k] = v
+	}
+	for k, v := range a.histograms {
+		c.histograms[k] = v
+	}
+	for k, v := range a.stringSets {
+		c.stringSets[k] = v
+	}
+	return c
+}
+
+// AddStore adds the user metrics and execution times of a bundle's Store.
+func (a *Accumulator) AddStore(store *Store) {
+	if store == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	Extractor{
+		SumInt64: func(l Labels, v int64) {
+			a.counters[l.stepKey()] += v
+		},
+		DistributionInt64: func(l Labels, count, sum, min, max int64) {
+			k := l.stepKey()
+			a.distributions[k] = mergeDistributionValues(a.distributions[k], DistributionValue{count, sum, min, max})
+		},
+		GaugeInt64: func(l Labels, v int64, t time.Time) {
+			k := l.stepKey()
+			if g, ok := a.gauges[k]; !ok || !t.Before(g.Timestamp) {
+				a.gauges[k] = GaugeValue{Value: v, Timestamp: t}
+			}
+		},
+		HistogramInt64: func(l Labels, v HistogramValue) {
+			k := l.stepKey()
+			a.histograms[k] = a.histograms[k].merge(v)
+		},
+		StringSet: func(l Labels, values []string) {
+			k := l.stepKey()
+			a.stringSets[k] = mergeStringSets(a.stringSets[k], values)
+		},
+		MsecsInt64: func(pid string, es *[4]ExecutionState) {
+			k := StepKey{Step: pid}
+			v := a.msecs[k]
+			v.Start += es[StartBundle].TotalTime
+			v.Process += es[ProcessBundle].TotalTime
+			v.Finish += es[FinishBundle].TotalTime
+			v.Total += es[TotalBundle].TotalTime
+			a.msecs[k] = v
+		},
+	}.ExtractFrom(store)
+}
+
+// AddElementCount adds count elements to the given PCollection.
+func (a *Accumulator) AddElementCount(pcol string, count int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	k := StepKey{Step: pcol}
+	v := a.pcols[k]
+	v.ElementCount += count
+	a.pcols[k] = v
+}
+
+// Results returns the accumulated metrics. PCollection results are keyed
+// by the PCollection ID.
+func (a *Accumulator) Results() Results {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return Results{
+		counters:      MergeCounters(nil, a.counters),
+		distributions: MergeDistributions(nil, a.distributions),
+		gauges:        MergeGauges(nil, a.gauges),
+		msecs:         MergeMsecs(nil, a.msecs),
+		pCols:         MergePCols(nil, a.pcols),
+		histograms:    MergeHistograms(nil, a.histograms),
+		stringSets:    MergeStringSets(nil, a.stringSets),
+	}
+}
+
+func (l Labels) stepKey() StepKey {
+	return StepKey{Step: l.transform, Name: l.name, Namespace: l.namespace}
+}
+
+func mergeDistributionValues(a, b DistributionValue) DistributionValue {
+	if a.Count == 0 {
+		return b
+	}
+	if b.Count == 0 {
+		return a
+	}
+	v := DistributionValue{Count: a.Count + b.Count, Sum: a.Sum + b.Sum, Min: a.Min, Max: a.Max}
+	if b.Min < v.Min {
+		v.Min = b.Min
+	}
+	if b.Max > v.Max {
+		v.Max = b.Max
+	}
+	return v
+}
+
+// WriteOpenMetrics writes the metrics in qr to w in the OpenMetrics text
+// exposition format, for scraping by Prometheus and compatible systems.
+//
+// User metrics are reported in the beam_user_counter, beam_user_distribution,
+// beam_user_gauge, beam_user_histogram and beam_user_string_set families,
+// labelled with their namespace, name and ptransform. String sets are
+// reported as state sets, with one sample per value. Execution times are
+// reported in milliseconds in beam_ptransform_execution_msecs, labelled with
+// the ptransform and the bundle processing state, and element counts in
+// beam_pcollection_elements, labelled with the pcollection.
+func WriteOpenMetrics(w io.Writer, qr QueryResults) error {
+	bw := bufio.NewWriter(w)
+
+	counters := qr.Counters()
+	sortResults(counters, func(i int) StepKey { return counters[i].Key })
+	family(bw, "beam_user_counter", "counter", "User counter metrics.")
+	for _, c := range counters {
+		sample(bw, "beam_user_counter_total", userLabels(c.Key), c.Result())
+	}
+
+	dists := qr.Distributions()
+	sortResults(dists, func(i int) StepKey { return dists[i].Key })
+	family(bw, "beam_user_distribution", "summary", "User distribution metrics.")
+	for _, d := range dists {
+		v := d.Result()
+		sample(bw, "beam_user_distribution_count", userLabels(d.Key), v.Count)
+		sample(bw, "beam_user_distribution_sum", userLabels(d.Key), v.Sum)
+	}
+	family(bw, "beam_user_distribution_min", "gauge", "Minimum values of user distribution metrics.")
+	for _, d := range dists {
+		sample(bw, "beam_user_distribution_min", userLabels(d.Key), d.Result().Min)
+	}
+	family(bw, "beam_user_distribution_max", "gauge", "Maximum values of user distribution metrics.")
+	for _, d := range dists {
+		sample(bw, "beam_user_distribution_max", userLabels(d.Key), d.Result().Max)
+	}
+
+	gauges := qr.Gauges()
+	sortResults(gauges, func(i int) StepKey { return gauges[i].Key })
+	family(bw, "beam_user_gauge", "gauge", "User gauge metrics.")
+	for _, g := range gauges {
+		sample(bw, "beam_user_gauge", userLabels(g.Key), g.Result().Value)
+	}
+
+	hists := qr.Histograms()
+	sortResults(hists, func(i int) StepKey { return hists[i].Key })
+	family(bw, "beam_user_histogram", "histogram", "User histogram metrics.")
+	for _, h := range hists {
+		v := h.Result()
+		var cumulative int64
+		for i, c := range v.Counts {
+			cumulative += c
+			le := "+Inf"
+			if i < len(v.Boundaries) {
+				// Buckets include their lower boundary, so the upper bound
+				// of integer values is one less than the next boundary.
+				le = strconv.FormatInt(v.Boundaries[i]-1, 10)
+			}
+			sample(bw, "beam_user_histogram_bucket", append(userLabels(h.Key), [2]string{"le", le}), cumulative)
+		}
+		sample(bw, "beam_user_histogram_count", userLabels(h.Key), cumulative)
+		sample(bw, "beam_user_histogram_sum", userLabels(h.Key), v.Sum)
+	}
+
+	sets := qr.StringSets()
+	sortResults(sets, func(i int) StepKey { return sets[i].Key })
+	family(bw, "beam_user_string_set", "stateset", "User string set metrics.")
+	for _, ss := range sets {
+		for _, v := range ss.Result() {
+			sample(bw, "beam_user_string_set", append(userLabels(ss.Key), [2]string{"beam_user_string_set", v}), 1)
+		}
+	}
+
+	msecs := qr.Msecs()
+	sortResults(msecs, func(i int) StepKey { return msecs[i].Key })
+	family(bw, "beam_ptransform_execution_msecs", "counter", "Time spent by each PTransform in each bundle processing state, in milliseconds.")
+	for _, m := range msecs {
+		v := m.Result()
+		for _, s := range []struct {
+			state bundleProcState
+			d     time.Duration
+		}{{StartBundle, v.Start}, {ProcessBundle, v.Process}, {FinishBundle, v.Finish}} {
+			labels := [][2]string{{"ptransform", m.Key.Step}, {"state", strings.ToLower(s.state.String())}}
+			sample(bw, "beam_ptransform_execution_msecs_total", labels, s.d.Milliseconds())
+		}
+	}
+
+	pcols := qr.PCols()
+	sortResults(pcols, func(i int) StepKey { return pcols[i].Key })
+	family(bw, "beam_pcollection_elements", "counter", "Number of elements in each PCollection.")
+	for _, p := range pcols {
+		sample(bw, "beam_pcollection_elements_total", [][2]string{{"pcollection", p.Key.Step}}, p.Result().ElementCount)
+	}
+
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
+
+// NewOpenMetricsHandler returns an http.Handler that serves the metrics
+// returned by results in the OpenMetrics text exposition format.
+func NewOpenMetricsHandler(results func() Results) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", OpenMetricsContentType)
+		if err := WriteOpenMetrics(w, results().AllMetrics()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func sortResults(rs interface{}, key func(i int) StepKey) {
+	sort.SliceStable(rs, func(i, j int) bool {
+		a, b := key(i), key(j)
+		if a.Step != b.Step {
+			return a.Step < b.Step
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+}
+
+func userLabels(k StepKey) [][2]string {
+	return [][2]string{{"namespace", k.Namespace}, {"name", k.Name}, {"ptransform", k.Step}}
+}
+
+func family(w *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# TYPE %s %s\n# HELP %s %s\n", name, typ, name, help)
+}
+
+func sample(w *bufio.Writer, name string, labels [][2]string, v int64) {
+	w.WriteString(name)
+	w.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		fmt.Fprintf(w, "%s=\"%s\"", l[0], labelValueEscaper.Replace(l[1]))
+	}
+	fmt.Fprintf(w, "} %d\n", v)
+}
+
+// labelValueEscaper escapes label values as required by the exposition format.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,152 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAccumulator(t *testing.T) {
+	now = func() time.Time { return time.Unix(1000, 0) }
+	defer func() { now = time.Now }()
+
+	acc := NewAccumulator()
+	for i, b := range []string{"bundle1", "bundle2"} {
+		ctx := ctxWith(b, "A")
+		NewCounter("ns", "count").Inc(ctx, 2)
+		NewDistribution("ns", "dist").Update(ctx, int64(10*(i+1)))
+		NewGauge("ns", "gauge").Set(ctx, int64(i))
+		NewHistogram("ns", "hist", []int64{10, 20}).Update(ctx, int64(10*(i+1)))
+		NewStringSet("ns", "set").Add(ctx, b)
+		store := GetStore(ctx)
+		store.stateRegistry["A"][ProcessBundle].TotalTime = time.Second
+		acc.AddStore(store)
+		acc.AddElementCount("pcol", 5)
+	}
+
+	// Clones don't affect the original.
+	acc.Clone().AddElementCount("pcol", 100)
+
+	qr := acc.Results().AllMetrics()
+	if got, want := qr.Counters()[0].Result(), int64(4); got != want {
+		t.Errorf("counter = %v, want %v", got, want)
+	}
+	if got, want := qr.Distributions()[0].Result(), (DistributionValue{Count: 2, Sum: 30, Min: 10, Max: 20}); got != want {
+		t.Errorf("distribution = %v, want %v", got, want)
+	}
+	if got, want := qr.Gauges()[0].Result().Value, int64(1); got != want {
+		t.Errorf("gauge = %v, want %v", got, want)
+	}
+	if got, want := qr.Histograms()[0].Result(), (HistogramValue{Boundaries: []int64{10, 20}, Counts: []int64{0, 1, 1}, Sum: 30}); !cmp.Equal(got, want) {
+		t.Errorf("histogram = %v, want %v", got, want)
+	}
+	if got, want := qr.StringSets()[0].Result(), []string{"bundle1", "bundle2"}; !cmp.Equal(got, want) {
+		t.Errorf("string set = %v, want %v", got, want)
+	}
+	if got, want := qr.Msecs()[0].Result().Process, 2*time.Second; got != want {
+		t.Errorf("process msecs = %v, want %v", got, want)
+	}
+	if got, want := qr.PCols()[0].Result().ElementCount, int64(10); got != want {
+		t.Errorf("element count = %v, want %v", got, want)
+	}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	acc := NewAccumulator()
+	acc.counters[StepKey{Step: "B", Namespace: "ns", Name: "count"}] = 3
+	acc.counters[StepKey{Step: "A", Namespace: "ns", Name: `a "quoted" name`}] = 7
+	acc.distributions[StepKey{Step: "A", Namespace: "ns", Name: "dist"}] = DistributionValue{Count: 2, Sum: 30, Min: 10, Max: 20}
+	acc.gauges[StepKey{Step: "A", Namespace: "ns", Name: "gauge"}] = GaugeValue{Value: 5, Timestamp: time.Unix(1000, 0)}
+	acc.msecs[StepKey{Step: "A"}] = MsecValue{Start: time.Millisecond, Process: 2 * time.Second, Finish: 3 * time.Millisecond}
+	acc.pcols[StepKey{Step: "n1"}] = PColValue{ElementCount: 42}
+	acc.histograms[StepKey{Step: "A", Namespace: "ns", Name: "hist"}] = HistogramValue{Boundaries: []int64{10, 100}, Counts: []int64{1, 2, 3}, Sum: 500}
+	acc.stringSets[StepKey{Step: "A", Namespace: "ns", Name: "set"}] = []string{"a", "b"}
+
+	var b strings.Builder
+	if err := WriteOpenMetrics(&b, acc.Results().AllMetrics()); err != nil {
+		t.Fatalf("WriteOpenMetrics failed: %v", err)
+	}
+	want := `# TYPE beam_user_counter counter
+# HELP beam_user_counter User counter metrics.
+beam_user_counter_total{namespace="ns",name="a \"quoted\" name",ptransform="A"} 7
+beam_user_counter_total{namespace="ns",name="count",ptransform="B"} 3
+# TYPE beam_user_distribution summary
+# HELP beam_user_distribution User distribution metrics.
+beam_user_distribution_count{namespace="ns",name="dist",ptransform="A"} 2
+beam_user_distribution_sum{namespace="ns",name="dist",ptransform="A"} 30
+# TYPE beam_user_distribution_min gauge
+# HELP beam_user_distribution_min Minimum values of user distribution metrics.
+beam_user_distribution_min{namespace="ns",name="dist",ptransform="A"} 10
+# TYPE beam_user_distribution_max gauge
+# HELP beam_user_distribution_max Maximum values of user distribution metrics.
+beam_user_distribution_max{namespace="ns",name="dist",ptransform="A"} 20
+# TYPE beam_user_gauge gauge
+# HELP beam_user_gauge User gauge metrics.
+beam_user_gauge{namespace="ns",name="gauge",ptransform="A"} 5
+# TYPE beam_user_histogram histogram
+# HELP beam_user_histogram User histogram metrics.
+beam_user_histogram_bucket{namespace="ns",name="hist",ptransform="A",le="9"} 1
+beam_user_histogram_bucket{namespace="ns",name="hist",ptransform="A",le="99"} 3
+beam_user_histogram_bucket{namespace="ns",name="hist",ptransform="A",le="+Inf"} 6
+beam_user_histogram_count{namespace="ns",name="hist",ptransform="A"} 6
+beam_user_histogram_sum{namespace="ns",name="hist",ptransform="A"} 500
+# TYPE beam_user_string_set stateset
+# HELP beam_user_string_set User string set metrics.
+beam_user_string_set{namespace="ns",name="set",ptransform="A",beam_user_string_set="a"} 1
+beam_user_string_set{namespace="ns",name="set",ptransform="A",beam_user_string_set="b"} 1
+# TYPE beam_ptransform_execution_msecs counter
+# HELP beam_ptransform_execution_msecs Time spent by each PTransform in each bundle processing state, in milliseconds.
+beam_ptransform_execution_msecs_total{ptransform="A",state="start_bundle"} 1
+beam_ptransform_execution_msecs_total{ptransform="A",state="process_bundle"} 2000
+beam_ptransform_execution_msecs_total{ptransform="A",state="finish_bundle"} 3
+# TYPE beam_pcollection_elements counter
+# HELP beam_pcollection_elements Number of elements in each PCollection.
+beam_pcollection_elements_total{pcollection="n1"} 42
+# EOF
+`
+	if d := cmp.Diff(want, b.String()); d != "" {
+		t.Errorf("WriteOpenMetrics diff (-want, +got):\n%v", d)
+	}
+}
+
+func TestNewOpenMetricsHandler(t *testing.T) {
+	acc := NewAccumulator()
+	acc.AddElementCount("n1", 1)
+	srv := httptest.NewServer(NewOpenMetricsHandler(acc.Results))
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.Header.Get("Content-Type"), OpenMetricsContentType; got != want {
+		t.Errorf("Content-Type = %q, want %q", got, want)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `beam_pcollection_elements_total{pcollection="n1"} 1`) {
+		t.Errorf("response doesn't contain the element count:\n%s", body)
+	}
+}
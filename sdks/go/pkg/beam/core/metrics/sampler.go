@@ -17,6 +17,7 @@ package metrics
 
 import (
 	"context"
+	"fmt"
 	"sync/atomic"
 	"time"
 	"unsafe"
@@ -31,6 +32,20 @@ type StateSampler struct {
 	transitionsAtLastSample   int64
 	nextLogTime               time.Duration
 	logInterval               time.Duration
+	lullHandler               func(context.Context, Lull)
+}
+
+// Lull describes a PTransform that has been in the same processing state,
+// without outputting or completing, for longer than the lull threshold.
+type Lull struct {
+	PTransformID string
+	State        string
+	Duration     time.Duration
+}
+
+// String implements the Stringer interface.
+func (l Lull) String() string {
+	return fmt.Sprintf("Operation ongoing in transform %v for at least %v without outputting or completing in state %v", l.PTransformID, l.Duration, l.State)
 }
 
 // NewSampler creates a new state sampler.
@@ -40,9 +55,21 @@ func NewSampler(store *Store) StateSampler {
 
 // Sample checks for state transition in processing a DoFn
 func (s *StateSampler) Sample(ctx context.Context, t time.Duration) {
+	if lull, ok := s.sample(t); ok {
+		if s.lullHandler != nil {
+			s.lullHandler(ctx, lull)
+		} else {
+			log.Info(ctx, lull)
+		}
+	}
+}
+
+// sample records the time spent in the current state, and returns a lull
+// if one is due to be reported.
+func (s *StateSampler) sample(t time.Duration) (Lull, bool) {
 	ps := loadCurrentState(s)
 	if ps.pid == "" {
-		return
+		return Lull{}, false
 	}
 	s.store.mu.Lock()
 	defer s.store.mu.Unlock()
@@ -61,15 +88,25 @@ func (s *StateSampler) Sample(ctx context.Context, t time.Duration) {
 		}
 
 		if s.millisSinceLastTransition > s.nextLogTime {
-			log.Infof(ctx, "Operation ongoing in transform %v for at least %v ms without outputting or completing in state %v", ps.pid, s.millisSinceLastTransition, getState(ps.state))
 			s.nextLogTime += s.logInterval
+			return Lull{PTransformID: ps.pid, State: getState(ps.state), Duration: s.millisSinceLastTransition}, true
 		}
 	}
+	return Lull{}, false
 }
 
-// SetLogInterval sets the logging interval for lull reporting.
+// SetLogInterval sets the logging interval for lull reporting, which is
+// also the threshold for the first report.
 func (s *StateSampler) SetLogInterval(t time.Duration) {
 	s.logInterval = t
+	s.nextLogTime = t
+}
+
+// SetLullHandler sets a function to call with detected lulls, instead of
+// logging them. It's called from the goroutine calling Sample, without
+// holding any locks.
+func (s *StateSampler) SetLullHandler(f func(context.Context, Lull)) {
+	s.lullHandler = f
 }
 
 func loadCurrentState(s *StateSampler) currentStateVal {
@@ -209,3 +209,45 @@ func BenchmarkMsec_Combined(b *testing.B) {
 	}
 	close(done)
 }
+
+func TestSampler_Lull(t *testing.T) {
+	ctx := context.Background()
+	bctx := SetBundleID(ctx, "test")
+	interval := 200 * time.Millisecond
+	s := NewSampler(GetStore(bctx))
+	s.SetLogInterval(500 * time.Millisecond)
+	var lulls []Lull
+	s.SetLullHandler(func(_ context.Context, l Lull) {
+		lulls = append(lulls, l)
+	})
+
+	pctx := SetPTransformID(bctx, "transform")
+	pt := NewPTransformState("transform")
+	pt.Set(pctx, ProcessBundle)
+	s.Sample(bctx, interval) // Transition.
+	for i := 0; i < 6; i++ {
+		s.Sample(bctx, interval)
+	}
+	want := []Lull{
+		{PTransformID: "transform", State: "PROCESS_BUNDLE", Duration: 600 * time.Millisecond},
+		{PTransformID: "transform", State: "PROCESS_BUNDLE", Duration: 1200 * time.Millisecond},
+	}
+	if len(lulls) != len(want) {
+		t.Fatalf("got lulls %v, want %v", lulls, want)
+	}
+	for i := range want {
+		if lulls[i] != want[i] {
+			t.Errorf("lull %d = %+v, want %+v", i, lulls[i], want[i])
+		}
+	}
+
+	// A transition ends the lull, so the next is only reported after the
+	// full log interval.
+	pt.Set(pctx, FinishBundle)
+	s.Sample(bctx, interval) // Transition.
+	s.Sample(bctx, interval)
+	s.Sample(bctx, interval)
+	if len(lulls) != len(want) {
+		t.Errorf("got lulls %v after a transition, want none", lulls[len(want):])
+	}
+}
@@ -94,6 +94,10 @@ type Extractor struct {
 	DistributionInt64 func(labels Labels, count, sum, min, max int64)
 	// GaugeInt64 extracts data from Gauge Int64 counters.
 	GaugeInt64 func(labels Labels, v int64, t time.Time)
+	// HistogramInt64 extracts data from Histogram Int64 metrics.
+	HistogramInt64 func(labels Labels, v HistogramValue)
+	// StringSet extracts data from StringSet metrics, with values in sorted order.
+	StringSet func(labels Labels, values []string)
 
 	// MsecsInt64 extracts data from StateRegistry of ExecutionState.
 	// Extraction of Msec counters is experimental and subject to change.
@@ -107,7 +111,7 @@ func (e Extractor) ExtractFrom(store *Store) error {
 	store.mu.RLock()
 	defer store.mu.RUnlock()
 
-	if e.SumInt64 == nil && e.DistributionInt64 == nil && e.GaugeInt64 == nil {
+	if e.SumInt64 == nil && e.DistributionInt64 == nil && e.GaugeInt64 == nil && e.HistogramInt64 == nil && e.StringSet == nil {
 		return fmt.Errorf("no Extractor fields were set")
 	}
 
@@ -128,6 +132,14 @@ func (e Extractor) ExtractFrom(store *Store) error {
 				v, t := um.(*gauge).get()
 				e.GaugeInt64(l, v, t)
 			}
+		case kindHistogram:
+			if e.HistogramInt64 != nil {
+				e.HistogramInt64(l, um.(*histogram).get())
+			}
+		case kindStringSet:
+			if e.StringSet != nil {
+				e.StringSet(l, um.(*stringSet).get())
+			}
 		}
 	}
 	if e.MsecsInt64 != nil {
@@ -155,6 +167,8 @@ type ptCounterSet struct {
 	counters      map[nameHash]*counter
 	distributions map[nameHash]*distribution
 	gauges        map[nameHash]*gauge
+	histograms    map[nameHash]*histogram
+	stringSets    map[nameHash]*stringSet
 }
 
 type bundleProcState int
@@ -233,9 +247,11 @@ func newStore() *Store {
 }
 
 // storeMetric stores a metric away on its first use so it may be retrieved later on.
-// In the event of a name collision, storeMetric can panic, so it's prudent to release
-// locks if they are no longer required.
-func (b *Store) storeMetric(pid string, n name, m userMetric) {
+// It returns the stored metric, which is a previously stored one if the metric was
+// already used in another context of the same PTransform, such as by another bundle
+// sharing the Store. In the event of a name collision, storeMetric can panic, so it's
+// prudent to release locks if they are no longer required.
+func (b *Store) storeMetric(pid string, n name, m userMetric) userMetric {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	l := Labels{transform: pid, namespace: n.namespace, name: n.name}
@@ -243,9 +259,10 @@ func (b *Store) storeMetric(pid string, n name, m userMetric) {
 		if ms.kind() != m.kind() {
 			panic(fmt.Sprintf("metric name %s being reused for a different metric type in a single PTransform", n))
 		}
-		return
+		return ms
 	}
 	b.store[l] = m
+	return m
 }
 
 // BundleState returns the bundle state.
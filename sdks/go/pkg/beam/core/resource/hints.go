@@ -0,0 +1,317 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resource contains resource hints, which advise runners about the
+// resources needed by transforms, such as memory or accelerators. Runners are
+// free to ignore hints they don't understand.
+package resource
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+// Standard resource hint URNs.
+const (
+	URNMinRAMBytes = "beam:resources:min_ram_bytes:v1"
+	URNAccelerator = "beam:resources:accelerator:v1"
+	URNCPUCount    = "beam:resources:cpu_count:v1"
+)
+
+// Hint is a resource hint for transforms.
+type Hint interface {
+	// URN identifies the kind of hint.
+	URN() string
+	// Payload is the encoded value of the hint.
+	Payload() []byte
+	// MergeWithOuter returns the hint applying to a transform with this hint,
+	// nested in a composite with the given outer hint of the same URN.
+	MergeWithOuter(outer Hint) Hint
+}
+
+// Hints is an immutable set of resource hints, with at most one hint per URN.
+// The zero value is the empty set.
+type Hints struct {
+	h map[string]Hint
+}
+
+// NewHints returns the set of the given hints. Later hints replace earlier
+// ones with the same URN.
+func NewHints(hints ...Hint) Hints {
+	if len(hints) == 0 {
+		return Hints{}
+	}
+	h := make(map[string]Hint, len(hints))
+	for _, hint := range hints {
+		h[hint.URN()] = hint
+	}
+	return Hints{h: h}
+}
+
+// With returns the set with the given hints added, replacing hints with the
+// same URN.
+func (hs Hints) With(hints ...Hint) Hints {
+	if len(hints) == 0 {
+		return hs
+	}
+	h := make(map[string]Hint, len(hs.h)+len(hints))
+	for urn, hint := range hs.h {
+		h[urn] = hint
+	}
+	for _, hint := range hints {
+		h[hint.URN()] = hint
+	}
+	return Hints{h: h}
+}
+
+// Len returns the number of hints in the set.
+func (hs Hints) Len() int {
+	return len(hs.h)
+}
+
+// MergeWithOuter returns the hints applying to a transform with these hints,
+// nested in a composite with the given outer hints. Hints present in only one
+// set are kept, and hints present in both are merged by their MergeWithOuter
+// method.
+func (hs Hints) MergeWithOuter(outer Hints) Hints {
+	if outer.Len() == 0 {
+		return hs
+	}
+	if hs.Len() == 0 {
+		return outer
+	}
+	h := make(map[string]Hint, len(hs.h)+len(outer.h))
+	for urn, hint := range outer.h {
+		h[urn] = hint
+	}
+	for urn, hint := range hs.h {
+		if o, ok := outer.h[urn]; ok {
+			hint = hint.MergeWithOuter(o)
+		}
+		h[urn] = hint
+	}
+	return Hints{h: h}
+}
+
+// Payloads returns the encoded hints by URN.
+func (hs Hints) Payloads() map[string][]byte {
+	if hs.Len() == 0 {
+		return nil
+	}
+	ret := make(map[string][]byte, len(hs.h))
+	for urn, hint := range hs.h {
+		ret[urn] = hint.Payload()
+	}
+	return ret
+}
+
+// Equal returns true iff both sets have the same encoded hints.
+func (hs Hints) Equal(other Hints) bool {
+	return hs.String() == other.String()
+}
+
+// String returns a canonical representation of the hints.
+func (hs Hints) String() string {
+	var parts []string
+	for urn, hint := range hs.h {
+		parts = append(parts, fmt.Sprintf("%v=%q", urn, hint.Payload()))
+	}
+	sort.Strings(parts)
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// MinRAMBytes hints the minimum amount of memory, in bytes, needed by a
+// transform. Nested hints use the largest minimum.
+func MinRAMBytes(bytes uint64) Hint {
+	return minRAMHint{bytes}
+}
+
+// ParseMinRAM returns a MinRAMBytes hint from a size with an optional unit,
+// such as "1000", "512MB" or "2.5GiB".
+func ParseMinRAM(size string) (Hint, error) {
+	bytes, err := parseBytes(size)
+	if err != nil {
+		return nil, err
+	}
+	return MinRAMBytes(bytes), nil
+}
+
+type minRAMHint struct {
+	bytes uint64
+}
+
+func (h minRAMHint) URN() string {
+	return URNMinRAMBytes
+}
+
+func (h minRAMHint) Payload() []byte {
+	// Encoded as a decimal string, as in the other SDKs.
+	return []byte(strconv.FormatUint(h.bytes, 10))
+}
+
+func (h minRAMHint) MergeWithOuter(outer Hint) Hint {
+	if o, ok := outer.(minRAMHint); ok && o.bytes > h.bytes {
+		return o
+	}
+	return h
+}
+
+func (h minRAMHint) String() string {
+	return fmt.Sprintf("min_ram=%v", h.bytes)
+}
+
+// CPUCount hints the number of CPUs needed by a transform. Nested hints use
+// the largest count.
+func CPUCount(cpus uint64) Hint {
+	return cpuCountHint{cpus}
+}
+
+type cpuCountHint struct {
+	cpus uint64
+}
+
+func (h cpuCountHint) URN() string {
+	return URNCPUCount
+}
+
+func (h cpuCountHint) Payload() []byte {
+	return []byte(strconv.FormatUint(h.cpus, 10))
+}
+
+func (h cpuCountHint) MergeWithOuter(outer Hint) Hint {
+	if o, ok := outer.(cpuCountHint); ok && o.cpus > h.cpus {
+		return o
+	}
+	return h
+}
+
+func (h cpuCountHint) String() string {
+	return fmt.Sprintf("cpu_count=%v", h.cpus)
+}
+
+// Accelerator hints the accelerator needed by a transform, in a runner
+// specific format such as "type:nvidia-tesla-t4;count:1". Nested hints
+// replace outer ones.
+func Accelerator(spec string) Hint {
+	return acceleratorHint{spec}
+}
+
+type acceleratorHint struct {
+	spec string
+}
+
+func (h acceleratorHint) URN() string {
+	return URNAccelerator
+}
+
+func (h acceleratorHint) Payload() []byte {
+	return []byte(h.spec)
+}
+
+func (h acceleratorHint) MergeWithOuter(outer Hint) Hint {
+	return h
+}
+
+func (h acceleratorHint) String() string {
+	return fmt.Sprintf("accelerator=%v", h.spec)
+}
+
+// ParseHint returns the hint described by a "name=value" string, where the
+// name is one of min_ram, accelerator or cpu_count, or a hint URN. The
+// standard URNs parse to the same hints as their names. Hints with other
+// beam:resources URNs are not merged: nested hints replace outer ones.
+func ParseHint(s string) (Hint, error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return nil, errors.Errorf("invalid resource hint %q: want name=value", s)
+	}
+	switch name {
+	case "min_ram", "min_ram_bytes", URNMinRAMBytes:
+		return ParseMinRAM(value)
+	case "cpu_count", URNCPUCount:
+		cpus, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid cpu_count hint %q", value)
+		}
+		return CPUCount(cpus), nil
+	case "accelerator", URNAccelerator:
+		return Accelerator(value), nil
+	default:
+		if strings.HasPrefix(name, "beam:resources:") {
+			return customHint{urn: name, value: value}, nil
+		}
+		return nil, errors.Errorf("unknown resource hint %q", name)
+	}
+}
+
+// customHint is a hint with a URN not known to the SDK.
+type customHint struct {
+	urn, value string
+}
+
+func (h customHint) URN() string {
+	return h.urn
+}
+
+func (h customHint) Payload() []byte {
+	return []byte(h.value)
+}
+
+func (h customHint) MergeWithOuter(outer Hint) Hint {
+	return h
+}
+
+func (h customHint) String() string {
+	return fmt.Sprintf("%v=%v", h.urn, h.value)
+}
+
+var byteUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+	"TIB": 1 << 40,
+}
+
+// parseBytes parses a size with an optional unit, such as "2.5GiB".
+func parseBytes(size string) (uint64, error) {
+	s := strings.TrimSpace(size)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r == '.')
+	})
+	num, unit := s, ""
+	if i >= 0 {
+		num, unit = s[:i], strings.TrimSpace(s[i:])
+	}
+	mult, ok := byteUnits[strings.ToUpper(unit)]
+	if !ok {
+		return 0, errors.Errorf("invalid size %q: unknown unit %q", size, unit)
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 || v*mult >= math.MaxUint64 {
+		return 0, errors.Errorf("invalid size %q", size)
+	}
+	return uint64(v * mult), nil
+}
@@ -0,0 +1,88 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"testing"
+)
+
+func TestParseHint(t *testing.T) {
+	tests := []struct {
+		hint    string
+		urn     string
+		payload string
+	}{
+		{"min_ram=1000", URNMinRAMBytes, "1000"},
+		{"min_ram=512MB", URNMinRAMBytes, "512000000"},
+		{"min_ram=2GiB", URNMinRAMBytes, "2147483648"},
+		{"min_ram=2.5 gb", URNMinRAMBytes, "2500000000"},
+		{"min_ram_bytes=10B", URNMinRAMBytes, "10"},
+		{URNMinRAMBytes + "=1KiB", URNMinRAMBytes, "1024"},
+		{"cpu_count=4", URNCPUCount, "4"},
+		{"accelerator=type:nvidia-tesla-t4;count:1", URNAccelerator, "type:nvidia-tesla-t4;count:1"},
+		{"beam:resources:custom:v1=x=y", "beam:resources:custom:v1", "x=y"},
+	}
+	for _, test := range tests {
+		h, err := ParseHint(test.hint)
+		if err != nil {
+			t.Errorf("ParseHint(%q) failed: %v", test.hint, err)
+			continue
+		}
+		if got, want := h.URN(), test.urn; got != want {
+			t.Errorf("ParseHint(%q).URN() = %v, want %v", test.hint, got, want)
+		}
+		if got, want := string(h.Payload()), test.payload; got != want {
+			t.Errorf("ParseHint(%q).Payload() = %v, want %v", test.hint, got, want)
+		}
+	}
+
+	for _, hint := range []string{"min_ram", "min_ram=1XB", "min_ram=-1", "min_ram=GB", "cpu_count=1.5", "gpu=1"} {
+		if h, err := ParseHint(hint); err == nil {
+			t.Errorf("ParseHint(%q) = %v, want error", hint, h)
+		}
+	}
+}
+
+func TestHints_MergeWithOuter(t *testing.T) {
+	outer := NewHints(MinRAMBytes(100), CPUCount(1), Accelerator("outer"))
+	tests := []struct {
+		inner, want Hints
+	}{
+		{Hints{}, outer},
+		{NewHints(MinRAMBytes(10)), outer},
+		{NewHints(MinRAMBytes(1000), CPUCount(4)), NewHints(MinRAMBytes(1000), CPUCount(4), Accelerator("outer"))},
+		{NewHints(Accelerator("inner")), NewHints(MinRAMBytes(100), CPUCount(1), Accelerator("inner"))},
+	}
+	for _, test := range tests {
+		if got := test.inner.MergeWithOuter(outer); !got.Equal(test.want) {
+			t.Errorf("%v.MergeWithOuter(%v) = %v, want %v", test.inner, outer, got, test.want)
+		}
+	}
+	if got := outer.MergeWithOuter(Hints{}); !got.Equal(outer) {
+		t.Errorf("%v.MergeWithOuter([]) = %v, want %v", outer, got, outer)
+	}
+}
+
+func TestHints_With(t *testing.T) {
+	hs := NewHints(MinRAMBytes(100))
+	with := hs.With(MinRAMBytes(10), CPUCount(2))
+	if got, want := with, NewHints(MinRAMBytes(10), CPUCount(2)); !got.Equal(want) {
+		t.Errorf("With() = %v, want %v", got, want)
+	}
+	if got, want := hs, NewHints(MinRAMBytes(100)); !got.Equal(want) {
+		t.Errorf("With() modified the receiver: %v, want %v", got, want)
+	}
+}
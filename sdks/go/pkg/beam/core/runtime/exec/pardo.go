@@ -20,9 +20,12 @@ import (
 	"fmt"
 	"path"
 	"reflect"
+	"sync/atomic"
+	"time"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/funcx"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/mtime"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
@@ -40,6 +43,10 @@ type ParDo struct {
 	Side    []SideInputAdapter
 	Out     []Node
 
+	// KeyCoder encodes the keys of main input elements, or the elements
+	// themselves if they aren't KVs, for reporting stuck elements. Optional.
+	KeyCoder *coder.Coder
+
 	PID      string
 	emitters []ReusableEmitter
 	ctx      context.Context
@@ -54,6 +61,12 @@ type ParDo struct {
 	err    errorx.GuardedError
 
 	states *metrics.PTransformState
+
+	keyEnc ElementEncoder
+	// sampleDue is set when the next element should be sampled into sample,
+	// which holds the latest *ElementSample for the bundle.
+	sampleDue int32
+	sample    atomic.Value
 }
 
 // GetPID returns the PTransformID for this ParDo.
@@ -82,6 +95,9 @@ func (n *ParDo) Up(ctx context.Context) error {
 	n.inv = newInvoker(n.Fn.ProcessElementFn())
 
 	n.states = metrics.NewPTransformState(n.PID)
+	if n.KeyCoder != nil {
+		n.keyEnc = MakeElementEncoder(n.KeyCoder)
+	}
 
 	// We can't cache the context during Setup since it runs only once per bundle.
 	// Subsequent bundles might run this same node, and the context here would be
@@ -114,6 +130,8 @@ func (n *ParDo) StartBundle(ctx context.Context, id string, data DataContext) er
 	// and never accept modified contexts from users, so we will cache them per-bundle
 	// per-unit, to avoid the constant allocation overhead.
 	n.ctx = metrics.SetPTransformID(ctx, n.PID)
+	n.sample.Store((*ElementSample)(nil))
+	atomic.StoreInt32(&n.sampleDue, 1)
 
 	n.states.Set(n.ctx, metrics.StartBundle)
 
@@ -136,10 +154,43 @@ func (n *ParDo) ProcessElement(_ context.Context, elm *FullValue, values ...ReSt
 	}
 
 	n.states.Set(n.ctx, metrics.ProcessBundle)
+	n.maybeSample(elm)
 
 	return n.processMainInput(&MainInput{Key: *elm, Values: values})
 }
 
+// maybeSample samples the element if a sample is due. It must be called on
+// the processing goroutine.
+func (n *ParDo) maybeSample(elm *FullValue) {
+	if atomic.LoadInt32(&n.sampleDue) != 0 {
+		n.sampleElement(elm)
+	}
+}
+
+// sampleElement encodes the key of the element and publishes it as the
+// latest sample. Only the encoded bytes are shared with other goroutines.
+func (n *ParDo) sampleElement(elm *FullValue) {
+	atomic.StoreInt32(&n.sampleDue, 0)
+	s := &ElementSample{Timestamp: elm.Timestamp, Sampled: time.Now()}
+	if n.keyEnc != nil {
+		s.Key, s.Truncated, s.Err = encodeSampleKey(n.keyEnc, elm.Elm)
+	}
+	n.sample.Store(s)
+}
+
+// RequestSample requests that the next element processed by the ParDo be
+// sampled. It may be called concurrently with processing.
+func (n *ParDo) RequestSample() {
+	atomic.StoreInt32(&n.sampleDue, 1)
+}
+
+// LastSample returns the latest element sample of the current bundle, or nil
+// if there isn't one. It may be called concurrently with processing.
+func (n *ParDo) LastSample() *ElementSample {
+	s, _ := n.sample.Load().(*ElementSample)
+	return s
+}
+
 // processMainInput processes an element that has been converted into a
 // MainInput. Splitting this away from ProcessElement allows other nodes to wrap
 // a ParDo's ProcessElement functionality with their own construction of
@@ -238,6 +289,7 @@ func (n *ParDo) FinishBundle(_ context.Context) error {
 	n.inv.Reset()
 
 	n.states.Set(n.ctx, metrics.FinishBundle)
+	n.sample.Store((*ElementSample)(nil))
 
 	if _, err := n.invokeDataFn(n.ctx, typex.NoFiringPane(), window.SingleGlobalWindow, mtime.ZeroTimestamp, n.Fn.FinishBundleFn(), nil); err != nil {
 		return n.fail(err)
@@ -19,10 +19,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/mtime"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
@@ -102,6 +104,81 @@ func TestParDo(t *testing.T) {
 	}
 }
 
+// TestParDo_Sample verifies that elements are sampled as encoded keys at the
+// start of the bundle and when requested, for stuck element detection.
+func TestParDo_Sample(t *testing.T) {
+	var p *Plan
+	var seen [][]byte
+	fn, err := graph.NewDoFn(func(v int64) int64 {
+		seen = append(seen, p.LastSample("pardo").Key)
+		if v == 2 {
+			p.SampleElements()
+		}
+		return v
+	})
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+
+	g := graph.New()
+	nN := g.NewNode(typex.New(reflectx.Int64), window.DefaultWindowingStrategy(), true)
+	edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{nN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+
+	out := &CaptureNode{UID: 1}
+	pardo := &ParDo{UID: 2, PID: "pardo", Fn: edge.DoFn, Inbound: edge.Input, Out: []Node{out}, KeyCoder: coder.NewVarInt()}
+	n := &FixedRoot{UID: 3, Elements: makeInput(int64(1), int64(2), int64(3)), Out: pardo}
+
+	p, err = NewPlan("a", []Unit{n, pardo, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if got := p.LastSample("pardo"); got != nil {
+		t.Errorf("LastSample() before execution = %v, want nil", got)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if got, want := seen, [][]byte{{1}, {1}, {3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LastSample().Key during processing = %v, want %v", got, want)
+	}
+	if got := p.LastSample("pardo"); got != nil {
+		t.Errorf("LastSample() after the bundle = %v, want nil", got)
+	}
+	if got := p.LastSample("unknown"); got != nil {
+		t.Errorf("LastSample(unknown) = %v, want nil", got)
+	}
+}
+
+func TestEncodeSampleKey(t *testing.T) {
+	long := strings.Repeat("k", 2*MaxSampleKeyLen)
+	tests := []struct {
+		name          string
+		c             *coder.Coder
+		key           interface{}
+		want          []byte
+		wantTruncated bool
+		wantErr       bool
+	}{
+		{name: "varint", c: coder.NewVarInt(), key: int64(5), want: []byte{5}},
+		{name: "truncated", c: coder.NewString(), key: long, want: append([]byte{0x80, 0x04}, long[:MaxSampleKeyLen-2]...), wantTruncated: true},
+		{name: "wrongType", c: coder.NewVarInt(), key: "five", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, truncated, err := encodeSampleKey(MakeElementEncoder(test.c), test.key)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("encodeSampleKey() err = %v, want error %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) || truncated != test.wantTruncated {
+				t.Errorf("encodeSampleKey() = %v, %v, want %v, %v", got, truncated, test.want, test.wantTruncated)
+			}
+		})
+	}
+}
+
 func windowObserverFn(w typex.Window, word string) string {
 	if _, ok := w.(window.GlobalWindow); ok {
 		return fmt.Sprintf("%v-%v", word, "global")
@@ -182,6 +182,37 @@ func (p *Plan) GetExpirationTime() time.Time {
 	return p.bf.lastValidCallback
 }
 
+// SampleElements requests that the next element processed by each ParDo in
+// the plan be sampled. It may be called concurrently with execution.
+func (p *Plan) SampleElements() {
+	for _, u := range p.units {
+		switch n := u.(type) {
+		case *ParDo:
+			n.RequestSample()
+		case *ProcessSizedElementsAndRestrictions:
+			n.PDo.RequestSample()
+		}
+	}
+}
+
+// LastSample returns the latest element sample of the DoFn of the given
+// PTransform in the plan, or nil if unknown.
+func (p *Plan) LastSample(pid string) *ElementSample {
+	for _, u := range p.units {
+		switch n := u.(type) {
+		case *ParDo:
+			if n.PID == pid {
+				return n.LastSample()
+			}
+		case *ProcessSizedElementsAndRestrictions:
+			if n.PDo.PID == pid {
+				return n.PDo.LastSample()
+			}
+		}
+	}
+	return nil
+}
+
 // Down takes the plan and associated units down. Does not panic.
 func (p *Plan) Down(ctx context.Context) error {
 	if p.status == Down {
@@ -0,0 +1,62 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+// MaxSampleKeyLen is the maximum length of encoded keys in element samples.
+const MaxSampleKeyLen = 256
+
+// ElementSample is a sample of an element processed by a ParDo, for reporting
+// stuck elements. It's taken on the processing goroutine and isn't modified
+// once published, so it may be read concurrently.
+type ElementSample struct {
+	// Key is the encoded key of the element, or the element itself if it isn't
+	// a KV, or nil if the ParDo has no KeyCoder.
+	Key []byte
+	// Truncated is set if Key was truncated to MaxSampleKeyLen bytes.
+	Truncated bool
+	// Err is the error encoding the key, if any.
+	Err error
+
+	Timestamp typex.EventTime
+	// Sampled is the time the element started processing.
+	Sampled time.Time
+}
+
+// encodeSampleKey encodes the key for an element sample. Failures, including
+// panics in user coders, are returned as errors so sampling never fails the
+// bundle.
+func encodeSampleKey(enc ElementEncoder, key interface{}) (b []byte, truncated bool, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			b, truncated, err = nil, false, errors.Errorf("panic encoding key: %v", p)
+		}
+	}()
+	b, err = EncodeElement(enc, key)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(b) > MaxSampleKeyLen {
+		return append([]byte(nil), b[:MaxSampleKeyLen]...), true, nil
+	}
+	return b, false, nil
+}
@@ -504,6 +504,8 @@ func (n *ProcessSizedElementsAndRestrictions) ProcessElement(_ context.Context,
 		}
 	}
 
+	n.PDo.maybeSample(&mainIn.Key)
+
 	if n.cweInv != nil {
 		n.PDo.we = n.cweInv.Invoke(elm.Elm.(*FullValue).Elm2.(*FullValue).Elm2)
 	}
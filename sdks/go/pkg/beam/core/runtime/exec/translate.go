@@ -463,6 +463,13 @@ func (b *builder) makeLink(from string, id linkID) (Node, error) {
 					n.PID = transform.GetUniqueName()
 
 					input := unmarshalKeyedValues(transform.GetInputs())
+					if len(input) > 0 {
+						ec, _, err := b.makeCoderForPCollection(input[0])
+						if err != nil {
+							return nil, err
+						}
+						n.KeyCoder = sampleKeyCoder(ec, urn == urnProcessSizedElementsAndRestrictions)
+					}
 					for i := 1; i < len(input); i++ {
 						// TODO(https://github.com/apache/beam/issues/18602) Handle ViewFns for side inputs
 
@@ -752,3 +759,21 @@ func unmarshalPort(data []byte) (Port, string, error) {
 		URL: port.GetApiServiceDescriptor().GetUrl(),
 	}, port.CoderId, nil
 }
+
+// sampleKeyCoder returns the coder for the keys of the main input elements of
+// a ParDo, or the elements themselves if they aren't KVs or grouped, or nil
+// if unknown.
+func sampleKeyCoder(c *coder.Coder, sized bool) *coder.Coder {
+	if sized {
+		// The input is KV<KV<element, KV<restriction, watermark state>>, size>.
+		if !coder.IsKV(c) || !coder.IsKV(c.Components[0]) {
+			return nil
+		}
+		c = c.Components[0].Components[0]
+	}
+	switch c.Kind {
+	case coder.KV, coder.CoGBK:
+		return c.Components[0]
+	}
+	return c
+}
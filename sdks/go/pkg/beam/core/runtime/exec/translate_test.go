@@ -90,6 +90,29 @@ func TestUnmarshalReshuffleCoders(t *testing.T) {
 	}
 }
 
+func TestSampleKeyCoder(t *testing.T) {
+	str, vint := coder.NewString(), coder.NewVarInt()
+	kv := coder.NewKV([]*coder.Coder{str, vint})
+	tests := []struct {
+		name  string
+		in    *coder.Coder
+		sized bool
+		want  *coder.Coder
+	}{
+		{"element", vint, false, vint},
+		{"kv", kv, false, str},
+		{"cogbk", coder.NewCoGBK([]*coder.Coder{str, vint}), false, str},
+		{"sized", coder.NewKV([]*coder.Coder{coder.NewKV([]*coder.Coder{kv, vint}), coder.NewDouble()}), true, str},
+		{"sizedNotKV", vint, true, nil},
+	}
+	for _, test := range tests {
+		got := sampleKeyCoder(test.in, test.sized)
+		if (got == nil) != (test.want == nil) || got != nil && !got.Equals(test.want) {
+			t.Errorf("sampleKeyCoder(%v, %v) = %v, want %v", test.in, test.sized, got, test.want)
+		}
+	}
+}
+
 func TestUnmarshallWindowFn(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -20,10 +20,12 @@ import (
 	"fmt"
 	"sort"
 
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/artifact"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window/trigger"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/resource"
 	v1pb "github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx/v1"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/pipelinex"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/protox"
@@ -70,6 +72,7 @@ const (
 
 	URNRequiresSplittableDoFn     = "beam:requirement:pardo:splittable_dofn:v1"
 	URNRequiresBundleFinalization = "beam:requirement:pardo:finalization:v1"
+	URNRequiresStatefulProcessing = "beam:requirement:pardo:stateful:v1"
 	URNTruncate                   = "beam:transform:sdf_truncate_sized_restrictions:v1"
 
 	// Deprecated: Determine worker binary based on GoWorkerBinary Role instead.
@@ -99,6 +102,8 @@ func goCapabilities() []string {
 }
 
 // CreateEnvironment produces the appropriate payload for the type of environment.
+// The environment depends on the worker binary and the artifacts declared in
+// this process with artifact.Declare.
 func CreateEnvironment(ctx context.Context, urn string, extractEnvironmentConfig func(context.Context) string) (*pipepb.Environment, error) {
 	var serializedPayload []byte
 	switch urn {
@@ -120,13 +125,13 @@ func CreateEnvironment(ctx context.Context, urn string, extractEnvironmentConfig
 		Urn:          urn,
 		Payload:      serializedPayload,
 		Capabilities: goCapabilities(),
-		Dependencies: []*pipepb.ArtifactInformation{
+		Dependencies: append([]*pipepb.ArtifactInformation{
 			{
 				TypeUrn:     URNArtifactFileType,
 				TypePayload: protox.MustEncode(&pipepb.ArtifactFilePayload{}),
 				RoleUrn:     URNArtifactGoWorkerRole,
 			},
-		},
+		}, artifact.Declared()...),
 	}, nil
 }
 
@@ -136,6 +141,8 @@ func CreateEnvironment(ctx context.Context, urn string, extractEnvironmentConfig
 type Options struct {
 	// Environment used to run the user code.
 	Environment *pipepb.Environment
+	// ResourceHints are the default resource hints of all transforms.
+	ResourceHints resource.Hints
 }
 
 // Marshal converts a graph to a model pipeline.
@@ -195,6 +202,7 @@ type marshaller struct {
 	coders *CoderMarshaller
 
 	windowing2id map[string]string
+	hints2env    map[string]string
 
 	needsExpansion bool // Indicates external transforms need to be expanded.
 }
@@ -209,6 +217,7 @@ func newMarshaller(opt *Options) *marshaller {
 		requirements: make(map[string]bool),
 		coders:       NewCoderMarshaller(),
 		windowing2id: make(map[string]string),
+		hints2env:    make(map[string]string),
 	}
 }
 
@@ -258,7 +267,7 @@ func (m *marshaller) addScopeTree(s *ScopeTree) (string, error) {
 	transform := &pipepb.PTransform{
 		UniqueName:    s.Scope.Name,
 		Subtransforms: subtransforms,
-		EnvironmentId: m.addDefaultEnv(),
+		EnvironmentId: m.addEnv(m.scopeHints(s.Scope.Scope, resource.Hints{})),
 	}
 
 	if err := m.updateIfCombineComposite(s, transform); err != nil {
@@ -526,7 +535,7 @@ func (m *marshaller) addMultiEdge(edge NamedEdge) ([]string, error) {
 
 	var transformEnvID = ""
 	if !(spec.Urn == URNGBK || spec.Urn == URNImpulse) {
-		transformEnvID = m.addDefaultEnv()
+		transformEnvID = m.addEnv(m.scopeHints(edge.Edge.Scope(), edge.Edge.ResourceHints))
 	}
 
 	transform := &pipepb.PTransform{
@@ -1002,6 +1011,34 @@ func (m *marshaller) addDefaultEnv() string {
 	return defaultEnvId
 }
 
+// scopeHints returns the given hints of a transform in the scope, merged with
+// the hints of the enclosing scopes and the default hints.
+func (m *marshaller) scopeHints(s *graph.Scope, hints resource.Hints) resource.Hints {
+	for ; s != nil; s = s.Parent {
+		hints = hints.MergeWithOuter(s.ResourceHints)
+	}
+	return hints.MergeWithOuter(m.opt.ResourceHints)
+}
+
+// addEnv returns the ID of the Go environment with the given resource hints.
+// Transforms with hints run in copies of the default environment, one for
+// each distinct set of hints.
+func (m *marshaller) addEnv(hints resource.Hints) string {
+	if hints.Len() == 0 {
+		return m.addDefaultEnv()
+	}
+	key := hints.String()
+	if id, ok := m.hints2env[key]; ok {
+		return id
+	}
+	env := proto.Clone(m.opt.Environment).(*pipepb.Environment)
+	env.ResourceHints = hints.Payloads()
+	id := fmt.Sprintf("%v-%d", defaultEnvId, len(m.hints2env)+1)
+	m.environments[id] = env
+	m.hints2env[key] = id
+	return id
+}
+
 func (m *marshaller) addWindowingStrategy(w *window.WindowingStrategy) (string, error) {
 	ws, err := MarshalWindowingStrategy(m.coders, w)
 	if err != nil {
@@ -1284,14 +1321,43 @@ func scopeID(s *graph.Scope) string {
 }
 
 // UpdateDefaultEnvWorkerType is so runners can update the pipeline's default environment
-// with the correct artifact type and payload for the Go worker binary.
+// with the correct artifact type and payload for the Go worker binary. The copies of the
+// default environment made for transforms with resource hints are updated as well.
 func UpdateDefaultEnvWorkerType(typeUrn string, pyld []byte, p *pipepb.Pipeline) error {
 	// Get the Go environment out.
 	envs := p.GetComponents().GetEnvironments()
-	env, ok := envs[defaultEnvId]
+	def, ok := envs[defaultEnvId]
 	if !ok {
 		return errors.Errorf("unable to find default Go environment with ID %q", defaultEnvId)
 	}
+	// Find the copies before updating, since the default environment changes.
+	var ids []string
+	for id, env := range envs {
+		if SameWorkerEnvironment(env, def) {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range ids {
+		if err := updateEnvWorkerType(id, envs[id], typeUrn, pyld); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SameWorkerEnvironment returns true iff the environments differ at most in
+// their resource hints, so transforms of both can run on the same workers.
+func SameWorkerEnvironment(a, b *pipepb.Environment) bool {
+	if a == b {
+		return true
+	}
+	a = proto.Clone(a).(*pipepb.Environment)
+	b = proto.Clone(b).(*pipepb.Environment)
+	a.ResourceHints, b.ResourceHints = nil, nil
+	return proto.Equal(a, b)
+}
+
+func updateEnvWorkerType(id string, env *pipepb.Environment, typeUrn string, pyld []byte) error {
 	for _, dep := range env.GetDependencies() {
 		if dep.RoleUrn != URNArtifactGoWorkerRole {
 			continue
@@ -1300,5 +1366,5 @@ func UpdateDefaultEnvWorkerType(typeUrn string, pyld []byte, p *pipepb.Pipeline)
 		dep.TypePayload = pyld
 		return nil
 	}
-	return errors.Errorf("unable to find dependency with %q role in environment with ID %q,", URNArtifactGoWorkerRole, defaultEnvId)
+	return errors.Errorf("unable to find dependency with %q role in environment with ID %q,", URNArtifactGoWorkerRole, id)
 }
@@ -27,6 +27,7 @@ import (
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/resource"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
@@ -192,6 +193,74 @@ func TestMarshal(t *testing.T) {
 	}
 }
 
+func TestMarshal_resourceHints(t *testing.T) {
+	g := graph.New()
+	sub := g.NewScope(g.Root(), "sub")
+	sub.ResourceHints = resource.NewHints(resource.MinRAMBytes(8e9), resource.Accelerator("outer"))
+
+	addDoFn(t, g, pickFn, sub, []*graph.Node{newIntInput(g)}, []*coder.Coder{intCoder(), intCoder()}, nil)
+	addDoFn(t, g, pickFn, sub, []*graph.Node{newIntInput(g)}, []*coder.Coder{intCoder(), intCoder()}, nil)
+	addDoFn(t, g, pickFn, g.Root(), []*graph.Node{newIntInput(g)}, []*coder.Coder{intCoder(), intCoder()}, nil)
+	edges, _, err := g.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	edges[0].ResourceHints = resource.NewHints(resource.MinRAMBytes(4e9), resource.Accelerator("inner"))
+
+	env := &pipepb.Environment{
+		Urn: "beam:env:docker:v1",
+		Dependencies: []*pipepb.ArtifactInformation{
+			{TypeUrn: graphx.URNArtifactFileType, RoleUrn: graphx.URNArtifactGoWorkerRole},
+		},
+	}
+	opts := &graphx.Options{Environment: env, ResourceHints: resource.NewHints(resource.CPUCount(2))}
+	p, err := graphx.Marshal(edges, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hints := func(name string) map[string]string {
+		for _, transform := range p.GetComponents().GetTransforms() {
+			if transform.GetUniqueName() != name {
+				continue
+			}
+			ret := make(map[string]string)
+			for urn, v := range p.GetComponents().GetEnvironments()[transform.GetEnvironmentId()].GetResourceHints() {
+				ret[urn] = string(v)
+			}
+			return ret
+		}
+		t.Fatalf("no transform %v in %v", name, proto.MarshalTextString(p))
+		return nil
+	}
+	tests := []struct {
+		transform string
+		want      map[string]string
+	}{
+		{"sub/graphx_test.pickFn", map[string]string{resource.URNMinRAMBytes: "8000000000", resource.URNAccelerator: "inner", resource.URNCPUCount: "2"}},
+		{"sub/graphx_test.pickFn'1", map[string]string{resource.URNMinRAMBytes: "8000000000", resource.URNAccelerator: "outer", resource.URNCPUCount: "2"}},
+		{"sub", map[string]string{resource.URNMinRAMBytes: "8000000000", resource.URNAccelerator: "outer", resource.URNCPUCount: "2"}},
+		{"graphx_test.pickFn", map[string]string{resource.URNCPUCount: "2"}},
+	}
+	for _, test := range tests {
+		if got := hints(test.transform); !cmp.Equal(got, test.want) {
+			t.Errorf("hints of %v = %v, want %v", test.transform, got, test.want)
+		}
+	}
+	if got, want := len(p.GetComponents().GetEnvironments()), 4; got != want {
+		t.Errorf("got %d environments, want %d: %v", got, want, proto.MarshalTextString(p))
+	}
+
+	if err := graphx.UpdateDefaultEnvWorkerType(graphx.URNArtifactURLType, []byte("worker"), p); err != nil {
+		t.Fatalf("UpdateDefaultEnvWorkerType() failed: %v", err)
+	}
+	for id, env := range p.GetComponents().GetEnvironments() {
+		if got := env.GetDependencies()[0].GetTypeUrn(); got != graphx.URNArtifactURLType {
+			t.Errorf("worker artifact type of environment %v = %v, want %v", id, got, graphx.URNArtifactURLType)
+		}
+	}
+}
+
 // testRT's methods can all be no-ops, we just need it to implement sdf.RTracker.
 type testRT struct {
 }
@@ -379,5 +448,59 @@ func TestUpdateDefaultEnvWorkerType(t *testing.T) {
 			t.Errorf("UpdateDefaultEnvWorkerType(<goEnv>) diff (-want, +got):\n%v", d)
 		}
 	})
+	t.Run("hintedEnvs", func(t *testing.T) {
+		goEnv := func(worker string, hints map[string][]byte) *pipepb.Environment {
+			return &pipepb.Environment{
+				Urn: "test",
+				Dependencies: []*pipepb.ArtifactInformation{
+					{TypeUrn: worker, RoleUrn: graphx.URNArtifactGoWorkerRole},
+				},
+				ResourceHints: hints,
+			}
+		}
+		envs := map[string]*pipepb.Environment{
+			"go":        goEnv("local", nil),
+			"hinted":    goEnv("local", map[string][]byte{"beam:resources:min_ram_bytes:v1": []byte("1000")}),
+			"go-xlang":  goEnv("expansion", nil),
+			"go-xlang2": goEnv("expansion", map[string][]byte{"beam:resources:min_ram_bytes:v1": []byte("1000")}),
+		}
+		p := &pipepb.Pipeline{Components: &pipepb.Components{Environments: envs}}
+		if err := graphx.UpdateDefaultEnvWorkerType(graphx.URNArtifactURLType, nil, p); err != nil {
+			t.Fatalf("UpdateDefaultEnvWorkerType(<hintedEnvs>) = %v, want nil", err)
+		}
+		want := map[string]string{
+			"go":        graphx.URNArtifactURLType,
+			"hinted":    graphx.URNArtifactURLType,
+			"go-xlang":  "expansion",
+			"go-xlang2": "expansion",
+		}
+		for id, env := range envs {
+			if got := env.GetDependencies()[0].GetTypeUrn(); got != want[id] {
+				t.Errorf("worker artifact type of environment %v = %v, want %v", id, got, want[id])
+			}
+		}
+	})
+}
 
+func TestSameWorkerEnvironment(t *testing.T) {
+	env := &pipepb.Environment{Urn: "test", Payload: []byte("test")}
+	tests := []struct {
+		name string
+		b    *pipepb.Environment
+		want bool
+	}{
+		{"same", env, true},
+		{"equal", &pipepb.Environment{Urn: "test", Payload: []byte("test")}, true},
+		{"hints", &pipepb.Environment{Urn: "test", Payload: []byte("test"), ResourceHints: map[string][]byte{"hint": []byte("1")}}, true},
+		{"payload", &pipepb.Environment{Urn: "test", Payload: []byte("other")}, false},
+		{"dependencies", &pipepb.Environment{Urn: "test", Payload: []byte("test"), Dependencies: []*pipepb.ArtifactInformation{{RoleUrn: "role"}}}, false},
+	}
+	for _, test := range tests {
+		if got := graphx.SameWorkerEnvironment(env, test.b); got != test.want {
+			t.Errorf("SameWorkerEnvironment(%v) = %v, want %v", test.name, got, test.want)
+		}
+	}
+	if hinted := tests[2].b; len(hinted.GetResourceHints()) != 1 {
+		t.Errorf("SameWorkerEnvironment modified its argument: %v", hinted)
+	}
 }
@@ -132,6 +132,18 @@ func (m *DataChannelManager) closeInstruction(instID instructionID) {
 	}
 }
 
+// backlog returns the number of received data chunks that are yet to be read,
+// for each PTransform of the instruction.
+func (m *DataChannelManager) backlog(instID instructionID) map[string]int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[string]int64)
+	for _, ch := range m.ports {
+		ch.backlog(instID, counts)
+	}
+	return counts
+}
+
 // clientID identifies a client of a connected channel.
 type clientID struct {
 	ptransformID string
@@ -376,6 +388,16 @@ func (c *DataChannel) removeReader(id clientID) {
 	c.mu.Unlock()
 }
 
+// backlog adds the number of buffered data chunks of each reader of the
+// instruction to counts.
+func (c *DataChannel) backlog(instID instructionID, counts map[string]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ptransformID, r := range c.readers[instID] {
+		counts[ptransformID] += int64(len(r.buf))
+	}
+}
+
 const endedInstructionCap = 32
 
 // removeInstruction closes all readers and writers registered for the instruction
@@ -372,3 +372,31 @@ func BenchmarkDataWriter(b *testing.B) {
 		})
 	}
 }
+
+func TestDataChannelManager_backlog(t *testing.T) {
+	done := make(chan bool, 1)
+	client := &fakeDataClient{t: t, done: done}
+	client.blocked.Lock()
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	c := makeDataChannel(ctx, "id", client, cancelFn)
+	m := &DataChannelManager{ports: map[string]*DataChannel{"port": c}}
+
+	c.OpenRead(ctx, "ptr", "inst_ref")
+	if got := m.backlog("inst_ref"); got["ptr"] != 0 {
+		t.Errorf("backlog before data = %v, want 0", got)
+	}
+	client.blocked.Unlock()
+
+	// The reader's buffer fills up, since nothing reads from it.
+	deadline := time.Now().Add(10 * time.Second)
+	for m.backlog("inst_ref")["ptr"] != bufElements {
+		if time.Now().After(deadline) {
+			t.Fatalf("backlog = %v, want %v", m.backlog("inst_ref"), bufElements)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := m.backlog("other_inst"); len(got) != 0 {
+		t.Errorf("backlog of another instruction = %v, want empty", got)
+	}
+}
@@ -128,6 +128,8 @@ func Main(ctx context.Context, loggingEndpoint, controlEndpoint string) error {
 		awaitingFinalization: make(map[instructionID]awaitingFinalization),
 		inactive:             newCircleBuffer(),
 		metStore:             make(map[instructionID]*metrics.Store),
+		lulls:                make(map[instructionID]string),
+		started:              make(map[instructionID]time.Time),
 		failed:               make(map[instructionID]error),
 		data:                 &DataChannelManager{},
 		state:                &StateChannelManager{},
@@ -137,7 +139,7 @@ func Main(ctx context.Context, loggingEndpoint, controlEndpoint string) error {
 
 	// if the runner supports worker status api then expose SDK harness status
 	if statusEndpoint != "" {
-		statusHandler, err := newWorkerStatusHandler(ctx, statusEndpoint, ctrl.cache, func(statusInfo *strings.Builder) { ctrl.metStoreToString(statusInfo) })
+		statusHandler, err := newWorkerStatusHandler(ctx, statusEndpoint, ctrl.cache, ctrl.metStoreToString, ctrl.lullsToString, ctrl.workerStatus)
 		if err != nil {
 			log.Errorf(ctx, "error establishing connection to worker status API: %v", err)
 		} else {
@@ -147,6 +149,15 @@ func Main(ctx context.Context, loggingEndpoint, controlEndpoint string) error {
 		}
 	}
 
+	if openMetricsAddress != "" {
+		srv, err := ctrl.serveOpenMetrics(ctx, openMetricsAddress)
+		if err != nil {
+			log.Errorf(ctx, "error serving worker metrics: %v", err)
+		} else {
+			defer srv.Close()
+		}
+	}
+
 	// gRPC requires all readers of a stream be the same goroutine, so this goroutine
 	// is responsible for managing the network data. All it does is pull data from
 	// the stream, and hand off the message to a goroutine to actually be handled,
@@ -178,6 +189,9 @@ func Main(ctx context.Context, loggingEndpoint, controlEndpoint string) error {
 			hooks.RunResponseHooks(ctx, req, resp)
 
 			recordInstructionResponse(resp)
+			if resp.GetError() != "" {
+				ctrl.recordError(instructionID(resp.GetInstructionId()), resp.GetError())
+			}
 			if resp != nil && atomic.LoadInt32(&shutdown) == 0 {
 				respc <- resp
 			}
@@ -275,6 +289,14 @@ type control struct {
 	inactive circleBuffer // protected by mu
 	// metric stores for active plans.
 	metStore map[instructionID]*metrics.Store // protected by mu
+	// metrics of completed bundles, if served to scrapers.
+	metAcc *metrics.Accumulator // protected by mu
+	// lull reports for active plans that are stuck.
+	lulls map[instructionID]string // protected by mu
+	// start times of active plans.
+	started map[instructionID]time.Time // protected by mu
+	// the most recent instruction failures, oldest first.
+	recentErrors []errorStatus // protected by mu
 	// plans that have failed during execution
 	failed map[instructionID]error // protected by mu
 	mu     sync.Mutex
@@ -320,6 +342,7 @@ func (c *control) getOrCreatePlan(bdID bundleDescriptorID) (*exec.Plan, error) {
 			c.mu.Unlock()
 			return nil, errors.WithContextf(err, "invalid bundle desc: %v\n%v\n", bdID, desc.String())
 		}
+		logLevels.register(desc)
 		plan = newPlan
 	}
 	c.mu.Unlock()
@@ -360,6 +383,7 @@ func (c *control) handleInstruction(ctx context.Context, req *fnpb.InstructionRe
 		c.mu.Lock()
 		c.inactive.Remove(instID)
 		c.active[instID] = plan
+		c.started[instID] = time.Now()
 		// Get the user metrics store for this bundle.
 		ctx = metrics.SetBundleID(ctx, string(instID))
 		store := metrics.GetStore(ctx)
@@ -377,6 +401,9 @@ func (c *control) handleInstruction(ctx context.Context, req *fnpb.InstructionRe
 		state := NewScopedStateReaderWithCache(c.state, instID, c.cache)
 
 		sampler := newSampler(store)
+		sampler.sampler.SetLogInterval(lullTimeout)
+		sampler.sampler.SetLullHandler(c.lullHandler(instID, plan))
+		sampler.onSample = plan.SampleElements
 		go sampler.start(ctx, samplePeriod)
 
 		err = plan.Execute(ctx, string(instID), exec.DataContext{Data: data, State: state})
@@ -441,11 +468,16 @@ func (c *control) handleInstruction(ctx context.Context, req *fnpb.InstructionRe
 			}
 		}
 
+		if c.metAcc != nil {
+			accumulateMetrics(c.metAcc, plan, store)
+		}
 		delete(c.active, instID)
 		if removed, ok := c.inactive.Insert(instID); ok {
 			delete(c.failed, removed) // Also GC old failed bundles.
 		}
 		delete(c.metStore, instID)
+		delete(c.lulls, instID)
+		delete(c.started, instID)
 
 		c.mu.Unlock()
 
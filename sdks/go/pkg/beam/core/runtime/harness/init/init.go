@@ -31,6 +31,7 @@ import (
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/harness"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/typedopts"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/util/grpcx"
 )
 
@@ -79,13 +80,35 @@ func hook() {
 	// will be captured by the framework -- which may not be functional if
 	// harness.Main returns. We want to be sure any error makes it out.
 
+	// Since Init() is hijacking main, it's appropriate to do as main
+	// does, and establish the background context here.
+	ctx := context.Background()
+
 	if *options != "" {
 		var opt runtime.RawOptionsWrapper
 		if err := json.Unmarshal([]byte(*options), &opt); err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to parse pipeline options '%v': %v\n", *options, err)
 			os.Exit(1)
 		}
+		params, err := runtime.TemplateParams([]byte(*options))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to parse template parameters: %v\n", err)
+			os.Exit(1)
+		}
+		if opt.Options.Options == nil {
+			opt.Options.Options = make(map[string]string)
+		}
+		for k, v := range params {
+			if _, ok := opt.Options.Options[k]; !ok {
+				opt.Options.Options[k] = v
+			}
+		}
 		runtime.GlobalOptions.Import(opt.Options)
+		if err := typedopts.Import(opt.TypedOptions); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to import typed pipeline options: %v\n", err)
+			os.Exit(1)
+		}
+		ctx = typedopts.NewContext(ctx, opt.TypedOptions)
 	}
 
 	defer func() {
@@ -103,10 +126,7 @@ func hook() {
 		}
 	}()
 
-	// Since Init() is hijacking main, it's appropriate to do as main
-	// does, and establish the background context here.
-
-	ctx := grpcx.WriteWorkerID(context.Background(), *id)
+	ctx = grpcx.WriteWorkerID(ctx, *id)
 	if err := harness.Main(ctx, *loggingEndpoint, *controlEndpoint); err != nil {
 		fmt.Fprintf(os.Stderr, "Worker failed: %v\n", err)
 		switch ShutdownMode {
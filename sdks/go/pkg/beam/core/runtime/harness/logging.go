@@ -23,10 +23,12 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/hooks"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
 	fnpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/fnexecution_v1"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -56,12 +58,23 @@ type logger struct {
 }
 
 func (l *logger) Log(ctx context.Context, sev log.Severity, calldepth int, msg string) {
+	l.LogAttrs(ctx, sev, calldepth+1, msg, nil)
+}
+
+// LogAttrs logs the message with the attributes in the custom data of the
+// log entry, along with the bundle ID.
+func (l *logger) LogAttrs(ctx context.Context, sev log.Severity, calldepth int, msg string, attrs []log.Attr) {
+	ptransformID, _ := metrics.GetPTransformID(ctx)
+	if !logLevels.enabled(ptransformID, sev) {
+		return
+	}
 	now := timestamppb.New(time.Now())
 
 	entry := &fnpb.LogEntry{
-		Timestamp: now,
-		Severity:  convertSeverity(sev),
-		Message:   msg,
+		Timestamp:   now,
+		Severity:    convertSeverity(sev),
+		Message:     msg,
+		TransformId: ptransformID,
 	}
 	if _, file, line, ok := runtime.Caller(calldepth + 1); ok {
 		entry.LogLocation = fmt.Sprintf("%v:%v", file, line)
@@ -69,14 +82,35 @@ func (l *logger) Log(ctx context.Context, sev log.Severity, calldepth int, msg s
 	if id, ok := tryGetInstID(ctx); ok {
 		entry.InstructionId = id
 	}
+	if id, ok := metrics.GetBundleID(ctx); ok {
+		attrs = append(attrs, log.Attr{Key: "bundle_id", Value: id})
+	}
+	if len(attrs) > 0 {
+		setCustomData(entry, attrs)
+	}
 
 	select {
 	case l.out <- entry:
 		// ok
 	default:
 		// buffer full: drop to stderr.
-		fmt.Fprintln(os.Stderr, msg)
+		fmt.Fprintln(os.Stderr, log.FormatAttrs(msg, attrs))
+	}
+}
+
+// setCustomData sets the custom data of the entry to the attributes. Values
+// that can't be represented in a google.protobuf.Struct are converted to
+// strings.
+func setCustomData(entry *fnpb.LogEntry, attrs []log.Attr) {
+	data := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(attrs))}
+	for _, a := range attrs {
+		v, err := structpb.NewValue(a.Value)
+		if err != nil {
+			v = structpb.NewStringValue(fmt.Sprint(a.Value))
+		}
+		data.Fields[a.Key] = v
 	}
+	entry.CustomData = data
 }
 
 func convertSeverity(sev log.Severity) fnpb.LogEntry_Severity_Enum {
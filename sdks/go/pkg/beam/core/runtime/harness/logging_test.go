@@ -19,9 +19,13 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
 	fnpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/fnexecution_v1"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestLogger(t *testing.T) {
@@ -42,10 +46,88 @@ func TestLogger(t *testing.T) {
 		t.Errorf("incorrect Message: got %v, want %v", got, want)
 	}
 	// This check will fail if the imports change.
-	if got, want := e.GetLogLocation(), "logging_test.go:34"; !strings.HasSuffix(got, want) {
+	if got, want := e.GetLogLocation(), "logging_test.go:38"; !strings.HasSuffix(got, want) {
 		t.Errorf("incorrect LogLocation: got %v, want suffix %v", got, want)
 	}
 	if got, want := e.GetSeverity(), fnpb.LogEntry_Severity_INFO; got != want {
 		t.Errorf("incorrect Severity: got %v, want %v", got, want)
 	}
 }
+
+func TestLogger_attrs(t *testing.T) {
+	ch := make(chan *fnpb.LogEntry, 1)
+	l := logger{out: ch}
+
+	ctx := setInstID(context.Background(), "INST")
+	ctx = metrics.SetPTransformID(metrics.SetBundleID(ctx, "INST"), "PT")
+	l.LogAttrs(ctx, log.SevWarn, 0, "msg", []log.Attr{
+		{Key: "user", Value: "alice"},
+		{Key: "count", Value: 3},
+		{Key: "elapsed", Value: time.Second},
+	})
+
+	e := <-ch
+	if got, want := e.GetTransformId(), "PT"; got != want {
+		t.Errorf("incorrect TransformID: got %v, want %v", got, want)
+	}
+	if got, want := e.GetMessage(), "msg"; got != want {
+		t.Errorf("incorrect Message: got %v, want %v", got, want)
+	}
+	want := map[string]interface{}{
+		"user":      "alice",
+		"count":     3.0,
+		"elapsed":   "1s",
+		"bundle_id": "INST",
+	}
+	if d := cmp.Diff(want, e.GetCustomData().AsMap()); d != "" {
+		t.Errorf("incorrect custom data (-want, +got):\n%v", d)
+	}
+}
+
+func TestLogger_levels(t *testing.T) {
+	defer logLevels.set(nil)
+	logLevels.set(map[string]log.Severity{
+		defaultLogLevelKey: log.SevWarn,
+		"Composite":        log.SevDebug,
+		"Composite/Noisy":  log.SevError,
+		"e3":               log.SevInfo,
+	})
+	logLevels.register(&fnpb.ProcessBundleDescriptor{
+		Transforms: map[string]*pipepb.PTransform{
+			"e1": {UniqueName: "Composite/Inner"},
+			"e2": {UniqueName: "Composite/Noisy"},
+			"e3": {UniqueName: "Composite/ByID"},
+			"e4": {UniqueName: "CompositeOther"},
+		},
+	})
+
+	tests := []struct {
+		ptransformID string
+		sev          log.Severity
+		want         bool
+	}{
+		{"", log.SevInfo, false},
+		{"", log.SevWarn, true},
+		{"e1", log.SevDebug, true},
+		{"e2", log.SevWarn, false},
+		{"e2", log.SevError, true},
+		{"e3", log.SevDebug, false},
+		{"e3", log.SevInfo, true},
+		{"e4", log.SevInfo, false},
+		{"unregistered", log.SevWarn, true},
+	}
+	for _, test := range tests {
+		if got := logLevels.enabled(test.ptransformID, test.sev); got != test.want {
+			t.Errorf("enabled(%q, %v) = %v, want %v", test.ptransformID, test.sev, got, test.want)
+		}
+	}
+
+	ch := make(chan *fnpb.LogEntry, 1)
+	l := logger{out: ch}
+	ctx := metrics.SetPTransformID(metrics.SetBundleID(context.Background(), "INST"), "e2")
+	l.Log(ctx, log.SevWarn, 0, "dropped")
+	l.Log(ctx, log.SevError, 0, "kept")
+	if got, want := (<-ch).GetMessage(), "kept"; got != want {
+		t.Errorf("logged message = %v, want %v", got, want)
+	}
+}
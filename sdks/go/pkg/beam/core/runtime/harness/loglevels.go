@@ -0,0 +1,125 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/hooks"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	fnpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/fnexecution_v1"
+)
+
+// defaultLogLevelKey is the log level override key for all transforms.
+const defaultLogLevelKey = "*"
+
+// logLevels holds the minimum severities of messages logged by the harness.
+var logLevels = &levelOverrides{}
+
+func init() {
+	hf := func(opts []string) hooks.Hook {
+		return hooks.Hook{
+			Init: func(ctx context.Context) (context.Context, error) {
+				overrides := make(map[string]log.Severity)
+				for _, opt := range opts {
+					i := strings.LastIndex(opt, "=")
+					if i < 0 {
+						return ctx, fmt.Errorf("invalid log level %q, want <transform>=<severity>", opt)
+					}
+					sev, err := log.ParseSeverity(opt[i+1:])
+					if err != nil {
+						return ctx, err
+					}
+					overrides[opt[:i]] = sev
+				}
+				logLevels.set(overrides)
+				return ctx, nil
+			},
+		}
+	}
+	hooks.RegisterHook("beam:go:hook:logging:levels", hf)
+}
+
+// levelOverrides filters log messages by severity, per PTransform. Overrides
+// are keyed by PTransform ID or unique name, where the name of a composite
+// transform applies to all transforms it contains.
+type levelOverrides struct {
+	mu        sync.RWMutex
+	overrides map[string]log.Severity // by PTransform ID or unique name.
+	resolved  map[string]log.Severity // by PTransform ID.
+}
+
+// set replaces the overrides, and forgets previously resolved transforms.
+func (l *levelOverrides) set(overrides map[string]log.Severity) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.overrides = overrides
+	l.resolved = make(map[string]log.Severity)
+}
+
+// register resolves the severities of the transforms of the descriptor from
+// their unique names.
+func (l *levelOverrides) register(desc *fnpb.ProcessBundleDescriptor) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.overrides) == 0 {
+		return
+	}
+	for id, t := range desc.GetTransforms() {
+		if sev, ok := l.match(id, t.GetUniqueName()); ok {
+			l.resolved[id] = sev
+		}
+	}
+}
+
+// match returns the override for the transform with the most specific key.
+// It expects to be called while l.mu is held.
+func (l *levelOverrides) match(id, name string) (log.Severity, bool) {
+	if sev, ok := l.overrides[id]; ok {
+		return sev, true
+	}
+	best := -1
+	var sev log.Severity
+	for key, s := range l.overrides {
+		if key == defaultLogLevelKey || len(key) <= best {
+			continue
+		}
+		if name == key || strings.HasPrefix(name, key+"/") {
+			best, sev = len(key), s
+		}
+	}
+	return sev, best >= 0
+}
+
+// enabled returns whether messages with the given severity are logged for
+// the given PTransform, which may be empty.
+func (l *levelOverrides) enabled(ptransformID string, sev log.Severity) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if len(l.overrides) == 0 {
+		return true
+	}
+	if min, ok := l.resolved[ptransformID]; ok && ptransformID != "" {
+		return sev >= min
+	}
+	if min, ok := l.overrides[ptransformID]; ok && ptransformID != "" {
+		return sev >= min
+	}
+	return sev >= l.overrides[defaultLogLevelKey]
+}
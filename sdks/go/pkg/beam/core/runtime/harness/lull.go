@@ -0,0 +1,134 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/hooks"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+)
+
+var (
+	// lullTimeout is how long a bundle may stay in the same processing state
+	// before it's reported as stuck.
+	lullTimeout time.Duration = 5 * time.Minute
+)
+
+func init() {
+	hf := func(opts []string) hooks.Hook {
+		return hooks.Hook{
+			Init: func(ctx context.Context) (context.Context, error) {
+				if len(opts) == 0 {
+					return ctx, nil
+				}
+				if len(opts) > 1 {
+					return ctx, fmt.Errorf("expected 1 option, got %v: %v", len(opts), opts)
+				}
+
+				timeout, err := time.ParseDuration(opts[0])
+				if err != nil {
+					return ctx, err
+				}
+				lullTimeout = timeout
+				return ctx, nil
+			},
+		}
+	}
+	hooks.RegisterHook("beam:go:hook:lull:timeout", hf)
+}
+
+// lullHandler returns a function that reports lulls in the given bundle, by
+// logging the last sampled element and the goroutine stacks, and keeping the
+// report for the worker status page until the bundle completes.
+func (c *control) lullHandler(instID instructionID, plan *exec.Plan) func(context.Context, metrics.Lull) {
+	return func(ctx context.Context, lull metrics.Lull) {
+		report := lullReport(instID, lull, plan.LastSample(lull.PTransformID), time.Now(), goroutineStacks())
+		log.Warn(ctx, report)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if _, ok := c.active[instID]; !ok {
+			return // The bundle completed while the report was built.
+		}
+		c.lulls[instID] = report
+	}
+}
+
+// lullsToString writes the reports of all active bundles currently in a lull.
+func (c *control) lullsToString(statusInfo *strings.Builder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ids []string
+	for instID := range c.lulls {
+		ids = append(ids, string(instID))
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		statusInfo.WriteString(c.lulls[instructionID(id)])
+		statusInfo.WriteString("\n")
+	}
+}
+
+// lullReport formats a report for a bundle that has been stuck, with the
+// element last sampled by the stuck transform. Samples are taken periodically,
+// so the sample age tells whether it's likely the stuck element: one sampled
+// about when the lull began.
+func lullReport(instID instructionID, lull metrics.Lull, sample *exec.ElementSample, now time.Time, stacks string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Bundle %v: %v\n", instID, lull)
+	if sample != nil {
+		fmt.Fprintf(&b, "Last sampled element: key %v, timestamp %v, sampled %v ago\n", formatKey(sample), sample.Timestamp, now.Sub(sample.Sampled))
+	} else {
+		b.WriteString("Last sampled element: unknown\n")
+	}
+	b.WriteString("Goroutine stacks:\n")
+	b.WriteString(stacks)
+	return b.String()
+}
+
+// formatKey returns the quoted encoded key of the sampled element.
+func formatKey(sample *exec.ElementSample) string {
+	switch {
+	case sample.Err != nil:
+		return fmt.Sprintf("unknown (%v)", sample.Err)
+	case sample.Key == nil:
+		return "unknown"
+	case sample.Truncated:
+		return fmt.Sprintf("%q...", sample.Key)
+	default:
+		return fmt.Sprintf("%q", sample.Key)
+	}
+}
+
+// goroutineStacks returns the stacks of all goroutines.
+func goroutineStacks() string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
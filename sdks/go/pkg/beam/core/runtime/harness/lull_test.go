@@ -0,0 +1,93 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
+)
+
+func TestFormatKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample *exec.ElementSample
+		want   string
+	}{
+		{"key", &exec.ElementSample{Key: []byte("\x03a\nb")}, `"\x03a\nb"`},
+		{"truncated", &exec.ElementSample{Key: []byte("xx"), Truncated: true}, `"xx"...`},
+		{"noCoder", &exec.ElementSample{}, "unknown"},
+		{"encodeError", &exec.ElementSample{Err: errors.New("boom")}, "unknown (boom)"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := formatKey(test.sample); got != test.want {
+				t.Errorf("formatKey() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLullReport(t *testing.T) {
+	lull := metrics.Lull{PTransformID: "myPT", State: "PROCESS_BUNDLE", Duration: 10 * time.Minute}
+	now := time.Now()
+	sample := &exec.ElementSample{Key: []byte("key"), Timestamp: mtime.FromMilliseconds(1000), Sampled: now.Add(-10 * time.Minute)}
+	got := lullReport("inst1", lull, sample, now, "goroutine 1 [running]:\n")
+	want := "Bundle inst1: Operation ongoing in transform myPT for at least 10m0s without outputting or completing in state PROCESS_BUNDLE\n" +
+		"Last sampled element: key \"key\", timestamp 1000, sampled 10m0s ago\n" +
+		"Goroutine stacks:\ngoroutine 1 [running]:\n"
+	if got != want {
+		t.Errorf("lullReport() = %q, want %q", got, want)
+	}
+
+	got = lullReport("inst1", lull, nil, now, "")
+	if !strings.Contains(got, "Last sampled element: unknown\n") {
+		t.Errorf("lullReport() without sample = %q, want unknown element", got)
+	}
+}
+
+func TestLullHandler(t *testing.T) {
+	c := &control{
+		active: map[instructionID]*exec.Plan{"inst1": nil},
+		lulls:  make(map[instructionID]string),
+	}
+	plan, err := exec.NewPlan("plan", []exec.Unit{&exec.DataSource{UID: 1, Out: &exec.Discard{UID: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.active["inst1"] = plan
+
+	lull := metrics.Lull{PTransformID: "myPT", State: "PROCESS_BUNDLE", Duration: 10 * time.Minute}
+	c.lullHandler("inst1", plan)(context.Background(), lull)
+	// Lulls for completed bundles aren't kept.
+	c.lullHandler("inst2", plan)(context.Background(), lull)
+
+	var b strings.Builder
+	c.lullsToString(&b)
+	got := b.String()
+	if !strings.HasPrefix(got, "Bundle inst1: ") || strings.Contains(got, "Bundle inst2") {
+		t.Errorf("lullsToString() = %q, want only the inst1 report", got)
+	}
+	if !strings.Contains(got, "TestLullHandler") {
+		t.Errorf("lullsToString() doesn't contain the goroutine stacks: %q", got)
+	}
+}
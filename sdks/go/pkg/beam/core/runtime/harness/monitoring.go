@@ -16,6 +16,7 @@
 package harness
 
 import (
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -24,6 +25,7 @@ import (
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/metricsx"
+	fnpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/fnexecution_v1"
 	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
 )
 
@@ -102,6 +104,36 @@ func shortIdsToInfos(shortids []string) map[string]*pipepb.MonitoringInfo {
 	return defaultShortIDCache.shortIdsToInfos(shortids)
 }
 
+// MonitoringInfos returns the monitoring infos of a ProcessBundleResponse
+// produced by this harness. If the runner supports short ids, the response
+// only has monitoring data keyed by short id, which is resolved to the full
+// monitoring infos.
+func MonitoringInfos(resp *fnpb.ProcessBundleResponse) []*pipepb.MonitoringInfo {
+	if len(resp.GetMonitoringInfos()) > 0 || len(resp.GetMonitoringData()) == 0 {
+		return resp.GetMonitoringInfos()
+	}
+	shortids := make([]string, 0, len(resp.GetMonitoringData()))
+	for s := range resp.GetMonitoringData() {
+		shortids = append(shortids, s)
+	}
+	sort.Strings(shortids)
+	infos := shortIdsToInfos(shortids)
+	mons := make([]*pipepb.MonitoringInfo, 0, len(shortids))
+	for _, s := range shortids {
+		info, ok := infos[s]
+		if !ok || info == nil {
+			continue
+		}
+		mons = append(mons, &pipepb.MonitoringInfo{
+			Urn:     info.GetUrn(),
+			Type:    info.GetType(),
+			Labels:  info.GetLabels(),
+			Payload: resp.GetMonitoringData()[s],
+		})
+	}
+	return mons
+}
+
 func monitoring(p *exec.Plan, store *metrics.Store, supportShortID bool) ([]*pipepb.MonitoringInfo, map[string][]byte) {
 	if store == nil {
 		return nil, nil
@@ -160,6 +192,38 @@ func monitoring(p *exec.Plan, store *metrics.Store, supportShortID bool) ([]*pip
 					})
 			}
 		},
+		HistogramInt64: func(l metrics.Labels, v metrics.HistogramValue) {
+			payload, err := metricsx.Int64Histogram(v.Boundaries, v.Counts, v.Sum)
+			if err != nil {
+				panic(err)
+			}
+			payloads[getShortID(l, metricsx.UrnUserHistogramInt64)] = payload
+			if !supportShortID {
+				monitoringInfo = append(monitoringInfo,
+					&pipepb.MonitoringInfo{
+						Urn:     metricsx.UrnToString(metricsx.UrnUserHistogramInt64),
+						Type:    metricsx.UrnToType(metricsx.UrnUserHistogramInt64),
+						Labels:  l.Map(),
+						Payload: payload,
+					})
+			}
+		},
+		StringSet: func(l metrics.Labels, values []string) {
+			payload, err := metricsx.StringSet(values)
+			if err != nil {
+				panic(err)
+			}
+			payloads[getShortID(l, metricsx.UrnUserStringSet)] = payload
+			if !supportShortID {
+				monitoringInfo = append(monitoringInfo,
+					&pipepb.MonitoringInfo{
+						Urn:     metricsx.UrnToString(metricsx.UrnUserStringSet),
+						Type:    metricsx.UrnToType(metricsx.UrnUserStringSet),
+						Labels:  l.Map(),
+						Payload: payload,
+					})
+			}
+		},
 		MsecsInt64: func(l string, states *[4]metrics.ExecutionState) {
 			label := map[string]string{"PTRANSFORM": l}
 			for i, v := range states {
@@ -21,6 +21,7 @@ import (
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/metricsx"
+	fnpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/fnexecution_v1"
 )
 
 func TestGetShortID(t *testing.T) {
@@ -146,6 +147,29 @@ func TestShortIdCache_Default(t *testing.T) {
 	}
 }
 
+func TestMonitoringInfos_shortIDs(t *testing.T) {
+	defaultShortIDCache.mu.Lock()
+	s := getShortID(metrics.PTransformLabels("ptransform"), metricsx.UrnProcessBundle)
+	defaultShortIDCache.mu.Unlock()
+
+	resp := &fnpb.ProcessBundleResponse{
+		MonitoringData: map[string][]byte{s: {42}},
+	}
+	mons := MonitoringInfos(resp)
+	if len(mons) != 1 {
+		t.Fatalf("MonitoringInfos() returned %v infos, want 1: %v", len(mons), mons)
+	}
+	if got, want := mons[0].GetUrn(), metricsx.UrnToString(metricsx.UrnProcessBundle); got != want {
+		t.Errorf("urn got %v, want %v", got, want)
+	}
+	if got, want := mons[0].GetLabels()["PTRANSFORM"], "ptransform"; got != want {
+		t.Errorf("PTRANSFORM label got %v, want %v", got, want)
+	}
+	if got, want := mons[0].GetPayload(), []byte{42}; string(got) != string(want) {
+		t.Errorf("payload got %v, want %v", got, want)
+	}
+}
+
 func BenchmarkGetShortID(b *testing.B) {
 	b.Run("new", func(b *testing.B) {
 		l := metrics.UserLabels("this", "doesn't", strconv.FormatInt(-1, 36))
@@ -0,0 +1,105 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/hooks"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+)
+
+var (
+	// openMetricsAddress is the address to serve worker metrics on in the
+	// OpenMetrics format. Metrics aren't served if empty.
+	openMetricsAddress string
+)
+
+func init() {
+	hf := func(opts []string) hooks.Hook {
+		return hooks.Hook{
+			Init: func(ctx context.Context) (context.Context, error) {
+				if len(opts) == 0 {
+					return ctx, nil
+				}
+				if len(opts) > 1 {
+					return ctx, fmt.Errorf("expected 1 option, got %v: %v", len(opts), opts)
+				}
+				openMetricsAddress = opts[0]
+				return ctx, nil
+			},
+		}
+	}
+	hooks.RegisterHook("beam:go:hook:metrics:openmetrics", hf)
+}
+
+// serveOpenMetrics serves the metrics of all bundles processed by the
+// worker at http://<addr>/metrics, until the returned server is closed.
+func (c *control) serveOpenMetrics(ctx context.Context, addr string) (*http.Server, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen for metrics scrapes on %v", addr)
+	}
+	return c.serveOpenMetricsOn(ctx, lis), nil
+}
+
+func (c *control) serveOpenMetricsOn(ctx context.Context, lis net.Listener) *http.Server {
+	c.mu.Lock()
+	c.metAcc = metrics.NewAccumulator()
+	c.mu.Unlock()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.NewOpenMetricsHandler(c.openMetricsResults))
+	srv := &http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+			log.Errorf(ctx, "metrics server failed: %v", err)
+		}
+	}()
+	log.Infof(ctx, "Serving worker metrics at http://%v/metrics", lis.Addr())
+	return srv
+}
+
+// openMetricsResults returns the metrics of all completed bundles, and the
+// current values for active bundles.
+func (c *control) openMetricsResults() metrics.Results {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	acc := c.metAcc.Clone()
+	for instID, plan := range c.active {
+		accumulateMetrics(acc, plan, c.metStore[instID])
+	}
+	return acc.Results()
+}
+
+// accumulateMetrics adds the user metrics, execution times and PCollection
+// element counts of a bundle to acc.
+func accumulateMetrics(acc *metrics.Accumulator, p *exec.Plan, store *metrics.Store) {
+	acc.AddStore(store)
+	if p == nil {
+		return
+	}
+	snapshot, _ := p.Progress()
+	for _, pcol := range snapshot.PCols {
+		acc.AddElementCount(pcol.ID, pcol.ElementCount)
+	}
+}
@@ -0,0 +1,68 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
+)
+
+func TestServeOpenMetrics(t *testing.T) {
+	ctx := context.Background()
+	c := &control{
+		active:   make(map[instructionID]*exec.Plan),
+		metStore: make(map[instructionID]*metrics.Store),
+	}
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := c.serveOpenMetricsOn(ctx, lis)
+	defer srv.Close()
+
+	counter := metrics.NewCounter("ns", "count")
+
+	// A completed bundle.
+	done := metrics.SetPTransformID(metrics.SetBundleID(ctx, "done"), "pt")
+	counter.Inc(done, 3)
+	accumulateMetrics(c.metAcc, nil, metrics.GetStore(done))
+
+	// An active bundle.
+	active := metrics.SetPTransformID(metrics.SetBundleID(ctx, "active"), "pt")
+	counter.Inc(active, 4)
+	c.active["active"] = nil
+	c.metStore["active"] = metrics.GetStore(active)
+
+	resp, err := http.Get("http://" + lis.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `beam_user_counter_total{namespace="ns",name="count",ptransform="pt"} 7`; !strings.Contains(string(body), want) {
+		t.Errorf("scrape doesn't contain %q:\n%s", want, body)
+	}
+}
@@ -25,6 +25,8 @@ import (
 type stateSampler struct {
 	done    chan (int)
 	sampler metrics.StateSampler
+	// onSample, if set, is called every sampling period.
+	onSample func()
 }
 
 func newSampler(store *metrics.Store) *stateSampler {
@@ -37,6 +39,9 @@ func (s *stateSampler) start(ctx context.Context, t time.Duration) {
 		case <-s.done:
 			return
 		default:
+			if s.onSample != nil {
+				s.onSample()
+			}
 			s.sampler.Sample(ctx, t)
 			time.Sleep(t)
 		}
@@ -17,17 +17,21 @@ package harness
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/harness/statecache"
 	"io"
 	"runtime"
 	"runtime/debug"
 	"runtime/pprof"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
 	fnpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/fnexecution_v1"
@@ -41,14 +45,48 @@ type workerStatusHandler struct {
 	wg               sync.WaitGroup
 	cache            *statecache.SideInputCache
 	metStoreToString func(*strings.Builder)
+	lullsToString    func(*strings.Builder)
+	status           func() workerStatus
 }
 
-func newWorkerStatusHandler(ctx context.Context, endpoint string, cache *statecache.SideInputCache, metStoreToString func(*strings.Builder)) (*workerStatusHandler, error) {
+// workerStatus is the structured part of the worker status, rendered both
+// as text and as JSON.
+type workerStatus struct {
+	ActiveBundles  []bundleStatus          `json:"activeBundles"`
+	SideInputCache statecache.CacheMetrics `json:"sideInputCache"`
+	RecentErrors   []errorStatus           `json:"recentErrors"`
+}
+
+// bundleStatus describes the progress of an active bundle.
+type bundleStatus struct {
+	InstructionID      string    `json:"instructionId"`
+	BundleDescriptorID string    `json:"bundleDescriptorId"`
+	StartTime          time.Time `json:"startTime"`
+	ElapsedMsecs       int64     `json:"elapsedMsecs"`
+	// PCollectionElements is the number of elements processed so far by
+	// the consumers of each PCollection.
+	PCollectionElements map[string]int64 `json:"pcollectionElements,omitempty"`
+	// PTransformMsecs is the time spent so far by each PTransform, in any
+	// bundle processing state.
+	PTransformMsecs map[string]int64 `json:"ptransformMsecs,omitempty"`
+	// DataBacklog is the number of received data chunks that are yet to be
+	// read by each PTransform.
+	DataBacklog map[string]int64 `json:"dataBacklog,omitempty"`
+}
+
+// errorStatus describes a recently failed instruction.
+type errorStatus struct {
+	InstructionID string    `json:"instructionId"`
+	Time          time.Time `json:"time"`
+	Error         string    `json:"error"`
+}
+
+func newWorkerStatusHandler(ctx context.Context, endpoint string, cache *statecache.SideInputCache, metStoreToString, lullsToString func(*strings.Builder), status func() workerStatus) (*workerStatusHandler, error) {
 	sconn, err := dial(ctx, endpoint, 60*time.Second)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to connect: %v\n", endpoint)
 	}
-	return &workerStatusHandler{conn: sconn, shouldShutdown: 0, cache: cache, metStoreToString: metStoreToString}, nil
+	return &workerStatusHandler{conn: sconn, shouldShutdown: 0, cache: cache, metStoreToString: metStoreToString, lullsToString: lullsToString, status: status}, nil
 }
 
 func (w *workerStatusHandler) isAlive() bool {
@@ -88,9 +126,60 @@ func (w *workerStatusHandler) activeProcessBundleStates(statusInfo *strings.Buil
 	w.metStoreToString(statusInfo)
 }
 
-func (w *workerStatusHandler) cacheStats(statusInfo *strings.Builder) {
+func (w *workerStatusHandler) stuckBundles(statusInfo *strings.Builder) {
+	statusInfo.WriteString("\n============Stuck Bundles============\n")
+	w.lullsToString(statusInfo)
+}
+
+func (w *workerStatusHandler) cacheStats(statusInfo *strings.Builder, m statecache.CacheMetrics) {
 	statusInfo.WriteString("\n============Cache Stats============\n")
-	statusInfo.WriteString(fmt.Sprintf("State Cache:\n%+v\n", w.cache.CacheMetrics()))
+	statusInfo.WriteString(fmt.Sprintf("State Cache:\n%+v\n", m))
+	if total := m.Hits + m.Misses; total > 0 {
+		statusInfo.WriteString(fmt.Sprintf("Hit ratio: %.2f %%\n", float64(m.Hits)*100/float64(total)))
+	}
+}
+
+func activeBundles(statusInfo *strings.Builder, bundles []bundleStatus) {
+	statusInfo.WriteString("\n============Active Bundles============\n")
+	for _, b := range bundles {
+		statusInfo.WriteString(fmt.Sprintf("Instruction %v: bundle descriptor %v, elapsed %v\n", b.InstructionID, b.BundleDescriptorID, time.Duration(b.ElapsedMsecs)*time.Millisecond))
+		for _, id := range sortedKeys(b.PCollectionElements) {
+			statusInfo.WriteString(fmt.Sprintf("\tPCollection %v: %d elements\n", id, b.PCollectionElements[id]))
+		}
+		for _, id := range sortedKeys(b.PTransformMsecs) {
+			statusInfo.WriteString(fmt.Sprintf("\tPTransform %v: %d msecs\n", id, b.PTransformMsecs[id]))
+		}
+		for _, id := range sortedKeys(b.DataBacklog) {
+			statusInfo.WriteString(fmt.Sprintf("\tPTransform %v: %d data chunks awaiting processing\n", id, b.DataBacklog[id]))
+		}
+	}
+}
+
+func recentErrors(statusInfo *strings.Builder, errs []errorStatus) {
+	statusInfo.WriteString("\n============Recent Errors============\n")
+	for _, e := range errs {
+		statusInfo.WriteString(fmt.Sprintf("%v Instruction %v: %v\n", e.Time.Format(time.RFC3339), e.InstructionID, e.Error))
+	}
+}
+
+func statusJSON(statusInfo *strings.Builder, status workerStatus) {
+	statusInfo.WriteString("\n============Status JSON============\n")
+	b, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		statusInfo.WriteString(fmt.Sprintf("error encoding status: %v\n", err))
+		return
+	}
+	statusInfo.Write(b)
+	statusInfo.WriteString("\n")
+}
+
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func goroutineDump(statusInfo *strings.Builder) {
@@ -121,10 +210,17 @@ func (w *workerStatusHandler) reader(ctx context.Context, stub fnpb.BeamFnWorker
 		}
 		log.Debugf(ctx, "RECV-status: %v", req.GetId())
 
+		status := w.status()
+		status.SideInputCache = w.cache.CacheMetrics()
+
 		statusInfo := &strings.Builder{}
 		memoryUsage(statusInfo)
+		activeBundles(statusInfo, status.ActiveBundles)
 		w.activeProcessBundleStates(statusInfo)
-		w.cacheStats(statusInfo)
+		w.stuckBundles(statusInfo)
+		w.cacheStats(statusInfo, status.SideInputCache)
+		recentErrors(statusInfo, status.RecentErrors)
+		statusJSON(statusInfo, status)
 		goroutineDump(statusInfo)
 		buildInfo(statusInfo)
 
@@ -145,3 +241,71 @@ func (w *workerStatusHandler) stop(ctx context.Context) error {
 	}
 	return nil
 }
+
+// maxRecentErrors is the number of instruction failures kept for the worker
+// status.
+const maxRecentErrors = 10
+
+// recordError keeps the failure of the instruction for the worker status.
+func (c *control) recordError(instID instructionID, msg string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.recentErrors) >= maxRecentErrors {
+		c.recentErrors = c.recentErrors[1:]
+	}
+	c.recentErrors = append(c.recentErrors, errorStatus{InstructionID: string(instID), Time: time.Now(), Error: msg})
+}
+
+// workerStatus returns the status of the active bundles, and the recent
+// instruction failures. The bundles are copied under c.mu, and queried for
+// data backlogs and metrics after releasing it, so that a status request
+// doesn't block instruction handling on the data channel locks.
+func (c *control) workerStatus() workerStatus {
+	type activeBundle struct {
+		instID instructionID
+		plan   *exec.Plan
+		start  time.Time
+		store  *metrics.Store
+	}
+	var status workerStatus
+	c.mu.Lock()
+	active := make([]activeBundle, 0, len(c.active))
+	for instID, plan := range c.active {
+		active = append(active, activeBundle{instID: instID, plan: plan, start: c.started[instID], store: c.metStore[instID]})
+	}
+	status.RecentErrors = append([]errorStatus(nil), c.recentErrors...)
+	c.mu.Unlock()
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].instID < active[j].instID
+	})
+	now := time.Now()
+	for _, a := range active {
+		b := bundleStatus{
+			InstructionID: string(a.instID),
+			StartTime:     a.start,
+			ElapsedMsecs:  now.Sub(a.start).Milliseconds(),
+			DataBacklog:   c.data.backlog(a.instID),
+		}
+		if a.plan != nil {
+			b.BundleDescriptorID = a.plan.ID()
+		}
+		acc := metrics.NewAccumulator()
+		accumulateMetrics(acc, a.plan, a.store)
+		qr := acc.Results().AllMetrics()
+		for _, pcol := range qr.PCols() {
+			if b.PCollectionElements == nil {
+				b.PCollectionElements = make(map[string]int64)
+			}
+			b.PCollectionElements[pcol.Key.Step] = pcol.Result().ElementCount
+		}
+		for _, msec := range qr.Msecs() {
+			if b.PTransformMsecs == nil {
+				b.PTransformMsecs = make(map[string]int64)
+			}
+			b.PTransformMsecs[msec.Key.Step] = msec.Result().Total.Milliseconds()
+		}
+		status.ActiveBundles = append(status.ActiveBundles, b)
+	}
+	return status
+}
@@ -23,7 +23,10 @@ import (
 	"net"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
 	fnpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/fnexecution_v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -79,6 +82,19 @@ func TestSendStatusResponse(t *testing.T) {
 
 	statusHandler := workerStatusHandler{conn: conn, cache: &statecache.SideInputCache{}, metStoreToString: func(builder *strings.Builder) {
 		builder.WriteString("metStore metadata")
+	}, lullsToString: func(builder *strings.Builder) {
+		builder.WriteString("lull report")
+	}, status: func() workerStatus {
+		return workerStatus{
+			ActiveBundles: []bundleStatus{{
+				InstructionID:       "inst1",
+				BundleDescriptorID:  "bd1",
+				ElapsedMsecs:        1500,
+				PCollectionElements: map[string]int64{"n1": 42},
+				DataBacklog:         map[string]int64{"source": 3},
+			}},
+			RecentErrors: []errorStatus{{InstructionID: "inst0", Time: time.Unix(0, 0).UTC(), Error: "boom"}},
+		}
 	}}
 	if err := statusHandler.start(ctx); err != nil {
 		t.Fatal(err)
@@ -89,8 +105,58 @@ func TestSendStatusResponse(t *testing.T) {
 	if len(response) == 0 {
 		t.Errorf("no response received: %v", response)
 	}
+	if !strings.Contains(response[0], "Stuck Bundles============\nlull report") {
+		t.Errorf("response doesn't contain the lull report: %v", response[0])
+	}
+	for _, want := range []string{
+		"Instruction inst1: bundle descriptor bd1, elapsed 1.5s\n\tPCollection n1: 42 elements\n\tPTransform source: 3 data chunks awaiting processing\n",
+		"1970-01-01T00:00:00Z Instruction inst0: boom\n",
+		`"instructionId": "inst1"`,
+		`"dataBacklog": {
+        "source": 3
+      }`,
+	} {
+		if !strings.Contains(response[0], want) {
+			t.Errorf("response doesn't contain %q: %v", want, response[0])
+		}
+	}
 
 	if err := statusHandler.stop(ctx); err != nil {
 		t.Error(err)
 	}
 }
+
+func TestControlWorkerStatus(t *testing.T) {
+	plan, err := exec.NewPlan("bd1", []exec.Unit{&exec.DataSource{UID: 1, Out: &exec.Discard{UID: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := metrics.SetBundleID(context.Background(), "inst1")
+	c := &control{
+		active:   map[instructionID]*exec.Plan{"inst1": plan},
+		started:  map[instructionID]time.Time{"inst1": time.Now().Add(-time.Minute)},
+		metStore: map[instructionID]*metrics.Store{"inst1": metrics.GetStore(ctx)},
+		data:     &DataChannelManager{},
+	}
+	for i := 0; i < maxRecentErrors+2; i++ {
+		c.recordError(instructionID(fmt.Sprintf("failed%d", i)), "boom")
+	}
+
+	status := c.workerStatus()
+	if got, want := len(status.ActiveBundles), 1; got != want {
+		t.Fatalf("len(ActiveBundles) = %v, want %v", got, want)
+	}
+	b := status.ActiveBundles[0]
+	if b.InstructionID != "inst1" || b.BundleDescriptorID != "bd1" {
+		t.Errorf("ActiveBundles[0] = %+v, want instruction inst1 of bundle descriptor bd1", b)
+	}
+	if b.ElapsedMsecs < time.Minute.Milliseconds() {
+		t.Errorf("ElapsedMsecs = %v, want at least a minute", b.ElapsedMsecs)
+	}
+	if got, want := len(status.RecentErrors), maxRecentErrors; got != want {
+		t.Fatalf("len(RecentErrors) = %v, want %v", got, want)
+	}
+	if got, want := status.RecentErrors[0].InstructionID, "failed2"; got != want {
+		t.Errorf("oldest recent error = %v, want %v", got, want)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
@@ -29,23 +30,38 @@ import (
 // FromMonitoringInfos extracts metrics from monitored states and
 // groups them into counters, distributions and gauges.
 func FromMonitoringInfos(p *pipepb.Pipeline, attempted []*pipepb.MonitoringInfo, committed []*pipepb.MonitoringInfo) *metrics.Results {
-	ac, ad, ag, am, ap := groupByType(p, attempted)
-	cc, cd, cg, cm, cp := groupByType(p, committed)
+	a := groupByType(p, attempted)
+	c := groupByType(p, committed)
 
-	return metrics.NewResults(metrics.MergeCounters(ac, cc), metrics.MergeDistributions(ad, cd), metrics.MergeGauges(ag, cg), metrics.MergeMsecs(am, cm), metrics.MergePCols(ap, cp))
+	return metrics.NewResults(
+		metrics.MergeCounters(a.counters, c.counters),
+		metrics.MergeDistributions(a.distributions, c.distributions),
+		metrics.MergeGauges(a.gauges, c.gauges),
+		metrics.MergeMsecs(a.msecs, c.msecs),
+		metrics.MergePCols(a.pcols, c.pcols),
+		metrics.MergeHistograms(a.histograms, c.histograms),
+		metrics.MergeStringSets(a.stringSets, c.stringSets))
 }
 
-func groupByType(p *pipepb.Pipeline, minfos []*pipepb.MonitoringInfo) (
-	map[metrics.StepKey]int64,
-	map[metrics.StepKey]metrics.DistributionValue,
-	map[metrics.StepKey]metrics.GaugeValue,
-	map[metrics.StepKey]metrics.MsecValue,
-	map[metrics.StepKey]metrics.PColValue) {
+// groupedMetrics holds the values of monitoring infos, grouped by type.
+type groupedMetrics struct {
+	counters      map[metrics.StepKey]int64
+	distributions map[metrics.StepKey]metrics.DistributionValue
+	gauges        map[metrics.StepKey]metrics.GaugeValue
+	msecs         map[metrics.StepKey]metrics.MsecValue
+	pcols         map[metrics.StepKey]metrics.PColValue
+	histograms    map[metrics.StepKey]metrics.HistogramValue
+	stringSets    map[metrics.StepKey][]string
+}
+
+func groupByType(p *pipepb.Pipeline, minfos []*pipepb.MonitoringInfo) groupedMetrics {
 	counters := make(map[metrics.StepKey]int64)
 	distributions := make(map[metrics.StepKey]metrics.DistributionValue)
 	gauges := make(map[metrics.StepKey]metrics.GaugeValue)
 	msecs := make(map[metrics.StepKey]metrics.MsecValue)
 	pcols := make(map[metrics.StepKey]metrics.PColValue)
+	histograms := make(map[metrics.StepKey]metrics.HistogramValue)
+	stringSets := make(map[metrics.StepKey][]string)
 
 	// extract pcol for a PTransform into a map from pipeline proto.
 	pcolToTransform := make(map[string]string)
@@ -92,6 +108,20 @@ func groupByType(p *pipepb.Pipeline, minfos []*pipepb.MonitoringInfo) (
 				continue
 			}
 			gauges[key] = value
+		case UrnToString(UrnUserHistogramInt64):
+			value, err := extractHistogramValue(r)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			histograms[key] = value
+		case UrnToString(UrnUserStringSet):
+			value, err := extractStringSetValue(r)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			stringSets[key] = value
 		case
 			UrnToString(UrnStartBundle),
 			UrnToString(UrnProcessBundle),
@@ -141,7 +171,15 @@ func groupByType(p *pipepb.Pipeline, minfos []*pipepb.MonitoringInfo) (
 	if len(errs) > 0 {
 		log.Printf("Warning: %v errors during metrics processing: %v\n", len(errs), errs)
 	}
-	return counters, distributions, gauges, msecs, pcols
+	return groupedMetrics{
+		counters:      counters,
+		distributions: distributions,
+		gauges:        gauges,
+		msecs:         msecs,
+		pcols:         pcols,
+		histograms:    histograms,
+		stringSets:    stringSets,
+	}
 }
 
 func extractKey(mi *pipepb.MonitoringInfo, pcolToTransform map[string]string) (metrics.StepKey, error) {
@@ -189,6 +227,39 @@ func extractGaugeValue(reader *bytes.Reader) (metrics.GaugeValue, error) {
 	return metrics.GaugeValue{Timestamp: time.Unix(0, values[0]*int64(time.Millisecond)), Value: values[1]}, nil
 }
 
+func extractHistogramValue(reader *bytes.Reader) (metrics.HistogramValue, error) {
+	n, err := coder.DecodeVarInt(reader)
+	if err != nil {
+		return metrics.HistogramValue{}, err
+	}
+	if n < 0 || n > int64(reader.Len()) {
+		return metrics.HistogramValue{}, fmt.Errorf("invalid histogram boundary count: %d", n)
+	}
+	values, err := decodeMany(reader, int(2*n+2))
+	if err != nil {
+		return metrics.HistogramValue{}, err
+	}
+	return metrics.HistogramValue{Boundaries: values[:n:n], Counts: values[n : 2*n+1 : 2*n+1], Sum: values[2*n+1]}, nil
+}
+
+func extractStringSetValue(reader *bytes.Reader) ([]string, error) {
+	n, err := coder.DecodeInt32(reader)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || int(n) > reader.Len() {
+		return nil, fmt.Errorf("invalid string set size: %d", n)
+	}
+	values := make([]string, n)
+	for i := range values {
+		if values[i], err = coder.DecodeStringUTF8(reader); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(values)
+	return values, nil
+}
+
 func newLabels(miLabels map[string]string) *metrics.Labels {
 	if miLabels["PTRANSFORM"] != "" {
 		labels := metrics.UserLabels(miLabels["PTRANSFORM"], miLabels["NAMESPACE"], miLabels["NAME"])
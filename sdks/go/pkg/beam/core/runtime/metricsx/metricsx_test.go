@@ -345,3 +345,60 @@ func TestFromMonitoringInfos_Gauges(t *testing.T) {
 			got[0], want, d)
 	}
 }
+
+func TestFromMonitoringInfos_HistogramsAndStringSets(t *testing.T) {
+	labels := map[string]string{
+		"PTRANSFORM": "main.customDoFn",
+		"NAMESPACE":  "customDoFn",
+	}
+	key := metrics.StepKey{Step: "main.customDoFn", Namespace: "customDoFn"}
+
+	hist := metrics.HistogramValue{Boundaries: []int64{10, 100}, Counts: []int64{1, 2, 3}, Sum: 500}
+	histPayload, err := Int64Histogram(hist.Boundaries, hist.Counts, hist.Sum)
+	if err != nil {
+		t.Fatalf("Failed to encode Int64Histogram: %v", err)
+	}
+	setPayload, err := StringSet([]string{"b", "a"})
+	if err != nil {
+		t.Fatalf("Failed to encode StringSet: %v", err)
+	}
+
+	histLabels := map[string]string{"NAME": "customHistogram"}
+	setLabels := map[string]string{"NAME": "customSet"}
+	for k, v := range labels {
+		histLabels[k] = v
+		setLabels[k] = v
+	}
+	committed := []*pipepb.MonitoringInfo{
+		{
+			Urn:     UrnToString(UrnUserHistogramInt64),
+			Type:    UrnToType(UrnUserHistogramInt64),
+			Labels:  histLabels,
+			Payload: histPayload,
+		}, {
+			Urn:     UrnToString(UrnUserStringSet),
+			Type:    UrnToType(UrnUserStringSet),
+			Labels:  setLabels,
+			Payload: setPayload,
+		},
+	}
+
+	qr := FromMonitoringInfos(&pipepb.Pipeline{}, nil, committed).AllMetrics()
+
+	key.Name = "customHistogram"
+	wantH := []metrics.HistogramResult{{Committed: hist, Key: key}}
+	if d := cmp.Diff(wantH, qr.Histograms()); d != "" {
+		t.Errorf("Invalid histograms, diff(-want,+got):\n %v", d)
+	}
+	key.Name = "customSet"
+	wantS := []metrics.StringSetResult{{Committed: []string{"a", "b"}, Key: key}}
+	if d := cmp.Diff(wantS, qr.StringSets()); d != "" {
+		t.Errorf("Invalid string sets, diff(-want,+got):\n %v", d)
+	}
+}
+
+func TestInt64Histogram_BadCounts(t *testing.T) {
+	if _, err := Int64Histogram([]int64{10}, []int64{1}, 1); err == nil {
+		t.Error("Int64Histogram with too few counts succeeded, want error")
+	}
+}
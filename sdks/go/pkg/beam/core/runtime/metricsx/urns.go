@@ -39,6 +39,8 @@ var sUrns = [...]string{
 	"beam:metric:user:top_n_double:v1",
 	"beam:metric:user:bottom_n_int64:v1",
 	"beam:metric:user:bottom_n_double:v1",
+	"beam:metric:user:histogram_int64:v1",
+	"beam:metric:user:set_string:v1",
 
 	"beam:metric:element_count:v1",
 	"beam:metric:sampled_byte_size:v1",
@@ -67,6 +69,8 @@ const (
 	UrnUserTopNFloat64
 	UrnUserBottomNInt64
 	UrnUserBottomNFloat64
+	UrnUserHistogramInt64
+	UrnUserStringSet
 
 	UrnElementCount
 	UrnSampledByteSize
@@ -112,6 +116,10 @@ func UrnToType(u Urn) string {
 		return "beam:metrics:bottom_n_int64:v1"
 	case UrnUserBottomNFloat64:
 		return "beam:metrics:bottom_n_double:v1"
+	case UrnUserHistogramInt64:
+		return "beam:metrics:histogram_int64:v1"
+	case UrnUserStringSet:
+		return "beam:metrics:set_string:v1"
 
 	case UrnProgressRemaining, UrnProgressCompleted:
 		return "beam:metrics:progress:v1"
@@ -170,6 +178,49 @@ func Int64Distribution(count, sum, min, max int64) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// Int64Histogram returns an encoded payload of the histogram of an integer
+// value: the number of boundaries, the boundaries, the count of each of the
+// len(boundaries)+1 buckets, and the sum of the values, all as varints.
+func Int64Histogram(boundaries, counts []int64, sum int64) ([]byte, error) {
+	if len(counts) != len(boundaries)+1 {
+		return nil, fmt.Errorf("histogram with %d boundaries must have %d bucket counts, got %d", len(boundaries), len(boundaries)+1, len(counts))
+	}
+	var buf bytes.Buffer
+	if err := coder.EncodeVarInt(int64(len(boundaries)), &buf); err != nil {
+		return nil, err
+	}
+	for _, v := range boundaries {
+		if err := coder.EncodeVarInt(v, &buf); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range counts {
+		if err := coder.EncodeVarInt(v, &buf); err != nil {
+			return nil, err
+		}
+	}
+	if err := coder.EncodeVarInt(sum, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// StringSet returns an encoded payload of a set of strings, as an iterable
+// of UTF-8 strings: a big-endian int32 count followed by each length prefixed
+// string.
+func StringSet(values []string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := coder.EncodeInt32(int32(len(values)), &buf); err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		if err := coder.EncodeStringUTF8(v, &buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
 // ExecutionMsecUrn returns the Urn for the bundle state
 func ExecutionMsecUrn(i int) Urn {
 	switch i {
@@ -16,7 +16,9 @@
 package runtime
 
 import (
+	"encoding/json"
 	"flag"
+	"strings"
 	"sync"
 )
 
@@ -75,6 +77,39 @@ type RawOptionsWrapper struct {
 	Experiments  []string   `json:"beam:option:experiments:v1"`
 	RetainDocker bool       `json:"beam:option:retain_docker_containers:v1"`
 	Parallelism  int        `json:"beam:option:parallelism:v1"`
+
+	// TypedOptions holds the values of typed options structs, keyed by the
+	// qualified name of their type.
+	TypedOptions map[string]json.RawMessage `json:"beam:option:go_typed_options:v1,omitempty"`
+}
+
+// TemplateParams returns the runtime parameter values of a template launch
+// from the JSON-encoded pipeline options. Runners such as Dataflow supply them
+// as plain string values next to the Beam options, either at the top level or
+// within the nested "options" object.
+func TemplateParams(data []byte) (map[string]string, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return nil, err
+	}
+	ret := make(map[string]string)
+	addStrings := func(m map[string]json.RawMessage) {
+		for k, v := range m {
+			var s string
+			if strings.HasPrefix(k, "beam:option:") || json.Unmarshal(v, &s) != nil {
+				continue
+			}
+			ret[k] = s
+		}
+	}
+	if nested, ok := top["options"]; ok {
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(nested, &m); err == nil {
+			addStrings(m)
+		}
+	}
+	addStrings(top)
+	return ret, nil
 }
 
 // Import imports the options from previously exported data and makes the
@@ -118,8 +153,33 @@ func (o *Options) Export() RawOptions {
 	return RawOptions{Options: copyMap(o.opt)}
 }
 
+var (
+	localFlags   = make(map[string]bool)
+	localFlagsMu sync.Mutex
+)
+
+// RegisterLocalFlags marks the named flags as local to the launching program.
+// Local flags, such as credentials, are never loaded into options, because
+// options are exported to workers and visible in job specifications.
+func RegisterLocalFlags(names ...string) {
+	localFlagsMu.Lock()
+	defer localFlagsMu.Unlock()
+
+	for _, name := range names {
+		localFlags[name] = true
+	}
+}
+
+func isLocalFlag(name string) bool {
+	localFlagsMu.Lock()
+	defer localFlagsMu.Unlock()
+
+	return localFlags[name]
+}
+
 // LoadOptionsFromFlags adds any flags not defined in excludeFlags to the options.
-// If the key is already defnined, it ignores that flag
+// If the key is already defnined, it ignores that flag. Local flags are always
+// excluded.
 func (o *Options) LoadOptionsFromFlags(excludeFlags map[string]bool) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
@@ -129,7 +189,7 @@ func (o *Options) LoadOptionsFromFlags(excludeFlags map[string]bool) {
 	}
 
 	flag.Visit(func(f *flag.Flag) {
-		if !excludeFlags[f.Name] && o.opt[f.Name] == "" {
+		if !excludeFlags[f.Name] && !isLocalFlag(f.Name) && o.opt[f.Name] == "" {
 			o.opt[f.Name] = f.Value.String()
 		}
 	})
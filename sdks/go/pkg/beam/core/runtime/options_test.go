@@ -56,7 +56,9 @@ func TestLoadOptionsFromFlags(t *testing.T) {
 	flag.String("A", "", "Flag for testing.")
 	flag.String("B", "", "Flag for testing.")
 	flag.String("C", "", "Flag for testing.")
-	flag.CommandLine.Parse([]string{"--A=123", "--B=456", "--C=789"})
+	flag.String("E", "", "Flag for testing.")
+	flag.CommandLine.Parse([]string{"--A=123", "--B=456", "--C=789", "--E=secret"})
+	RegisterLocalFlags("E")
 
 	var flagFilter = map[string]bool{
 		"C": true,
@@ -77,4 +79,29 @@ func TestLoadOptionsFromFlags(t *testing.T) {
 	if got, want := opt.Get("D"), ""; got != want {
 		t.Errorf("opt.Get(\"D\") = %v, want %v", got, want)
 	}
+	if got, want := opt.Get("E"), ""; got != want {
+		t.Errorf("opt.Get(\"E\") = %v, want %v", got, want)
+	}
+}
+
+func TestTemplateParams(t *testing.T) {
+	data := `{
+		"beam:option:go_options:v1": {"options": {"a": "1"}},
+		"beam:option:experiments:v1": ["beam_fn_api"],
+		"options": {"input": "gs://bucket/in", "numWorkers": 3},
+		"output": "gs://bucket/out"
+	}`
+	params, err := TemplateParams([]byte(data))
+	if err != nil {
+		t.Fatalf("TemplateParams failed: %v", err)
+	}
+	want := map[string]string{"input": "gs://bucket/in", "output": "gs://bucket/out"}
+	if len(params) != len(want) {
+		t.Errorf("TemplateParams = %v, want %v", params, want)
+	}
+	for k, v := range want {
+		if got := params[k]; got != v {
+			t.Errorf("TemplateParams[%v] = %v, want %v", k, got, v)
+		}
+	}
 }
@@ -0,0 +1,399 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipelinex
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+// URNs of the transforms whose changes affect update compatibility. They
+// mirror the graphx constants, which can't be imported here.
+const (
+	urnGBK   = "beam:transform:group_by_key:v1"
+	urnParDo = "beam:transform:pardo:v1"
+)
+
+// ChangeKind is the kind of a change between two pipelines.
+type ChangeKind string
+
+// Kinds of changes between two pipelines.
+const (
+	TransformAdded   ChangeKind = "transform_added"
+	TransformRemoved ChangeKind = "transform_removed"
+	TransformRenamed ChangeKind = "transform_renamed"
+	TransformChanged ChangeKind = "transform_changed"
+	CoderChanged     ChangeKind = "coder_changed"
+	WindowingChanged ChangeKind = "windowing_changed"
+	SideInputChanged ChangeKind = "side_input_changed"
+)
+
+// Change is a single difference between two pipelines.
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	// Transform is the unique name of the changed transform in the new
+	// pipeline, or in the old pipeline if it was removed.
+	Transform string `json:"transform"`
+	Detail    string `json:"detail"`
+	// Breaking is whether the change prevents updating a running job of the
+	// old pipeline in place with the new pipeline.
+	Breaking bool `json:"breaking"`
+}
+
+func (c Change) String() string {
+	prefix := ""
+	if c.Breaking {
+		prefix = "BREAKING "
+	}
+	return fmt.Sprintf("%v%v %v: %v", prefix, c.Kind, c.Transform, c.Detail)
+}
+
+// PipelineDiff is the difference between two pipelines.
+type PipelineDiff struct {
+	Changes []Change `json:"changes"`
+}
+
+// Breaking returns the changes that prevent an in-place update.
+func (d *PipelineDiff) Breaking() []Change {
+	var ret []Change
+	for _, c := range d.Changes {
+		if c.Breaking {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// Compatible returns an error listing the breaking changes, if any.
+func (d *PipelineDiff) Compatible() error {
+	breaking := d.Breaking()
+	if len(breaking) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "pipeline isn't update compatible, found %d breaking changes:", len(breaking))
+	for _, c := range breaking {
+		b.WriteString("\n\t")
+		b.WriteString(c.String())
+	}
+	return errors.New(b.String())
+}
+
+func (d *PipelineDiff) String() string {
+	var b strings.Builder
+	for _, c := range d.Changes {
+		b.WriteString(c.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Diff returns the changes between the leaf transforms of two pipelines,
+// matched by unique name, and flags those that break an in-place update of a
+// running job of the before pipeline.
+//
+// The name mapping maps the unique names of transforms in the before
+// pipeline, or their enclosing composites, to their names in the after
+// pipeline, as for a Dataflow update. Mapping a transform to the empty string
+// marks it as intentionally removed.
+func Diff(before, after *pipepb.Pipeline, nameMapping map[string]string) (*PipelineDiff, error) {
+	before, err := Normalize(before)
+	if err != nil {
+		return nil, errors.WithContext(err, "normalizing before pipeline")
+	}
+	after, err = Normalize(after)
+	if err != nil {
+		return nil, errors.WithContext(err, "normalizing after pipeline")
+	}
+	d := &differ{
+		before: before.GetComponents(),
+		after:  after.GetComponents(),
+		diff:   &PipelineDiff{},
+	}
+	d.consumers = consumers(d.after)
+
+	oldLeaves, newLeaves := leavesByName(d.before), leavesByName(d.after)
+	var removed []string
+	matched := make(map[string]bool)
+	for _, name := range sortedNames(oldLeaves) {
+		mapped, ok := mapName(nameMapping, name)
+		if ok && mapped == "" {
+			d.add(TransformRemoved, name, false, "removed, as mapped to the empty name")
+			continue
+		}
+		nt, ok := newLeaves[mapped]
+		if !ok {
+			removed = append(removed, name)
+			continue
+		}
+		matched[mapped] = true
+		d.compare(oldLeaves[name], nt)
+	}
+
+	var added []string
+	for _, name := range sortedNames(newLeaves) {
+		if !matched[name] {
+			added = append(added, name)
+		}
+	}
+
+	// Unmapped removed transforms with the same spec as an added transform
+	// were most likely renamed, which loses their state without a mapping.
+	renamed := make(map[string]bool)
+	for _, name := range removed {
+		ot := oldLeaves[name]
+		to := ""
+		for _, cand := range added {
+			if !renamed[cand] && sameSpec(ot.GetSpec(), newLeaves[cand].GetSpec()) {
+				to = cand
+				break
+			}
+		}
+		if to == "" {
+			d.add(TransformRemoved, name, true, "removed; map it to the empty name if intentional")
+			continue
+		}
+		renamed[to] = true
+		d.add(TransformRenamed, to, true, fmt.Sprintf("renamed from %q; add a name mapping to keep its state", name))
+		d.compare(ot, newLeaves[to])
+	}
+	for _, name := range added {
+		if !renamed[name] {
+			d.add(TransformAdded, name, false, "added")
+		}
+	}
+	return d.diff, nil
+}
+
+type differ struct {
+	before, after *pipepb.Components
+	consumers     map[string][]*pipepb.PTransform // by PCollection ID in after.
+	diff          *PipelineDiff
+}
+
+func (d *differ) add(kind ChangeKind, transform string, breaking bool, detail string) {
+	d.diff.Changes = append(d.diff.Changes, Change{Kind: kind, Transform: transform, Detail: detail, Breaking: breaking})
+}
+
+// compare adds the changes between two matched leaf transforms.
+func (d *differ) compare(ot, nt *pipepb.PTransform) {
+	name := nt.GetUniqueName()
+	if o, n := ot.GetSpec().GetUrn(), nt.GetSpec().GetUrn(); o != n {
+		d.add(TransformChanged, name, true, fmt.Sprintf("changed from %v to %v", o, n))
+		return
+	}
+	for _, tag := range sortedNames(ot.GetOutputs()) {
+		nid, ok := nt.GetOutputs()[tag]
+		if !ok {
+			d.add(TransformChanged, name, true, fmt.Sprintf("output %v removed", tag))
+			continue
+		}
+		d.comparePCollections(name, tag, ot.GetOutputs()[tag], nid)
+	}
+	if nt.GetSpec().GetUrn() == urnParDo {
+		d.compareSideInputs(name, ot, nt)
+	}
+}
+
+// comparePCollections adds the coder and windowing changes of an output.
+func (d *differ) comparePCollections(name, tag, oid, nid string) {
+	op, np := d.before.GetPcollections()[oid], d.after.GetPcollections()[nid]
+	if o, n := coderKey(d.before, op.GetCoderId(), 0), coderKey(d.after, np.GetCoderId(), 0); o != n {
+		d.add(CoderChanged, name, true, fmt.Sprintf("coder of output %v changed from %v to %v",
+			tag, coderDesc(d.before, op.GetCoderId(), 0), coderDesc(d.after, np.GetCoderId(), 0)))
+	}
+	ows, nws := d.before.GetWindowingStrategies()[op.GetWindowingStrategyId()], d.after.GetWindowingStrategies()[np.GetWindowingStrategyId()]
+	if o, n := windowingKey(ows), windowingKey(nws); o != n {
+		// Grouped state is keyed by window, so changing the windowing of
+		// grouped data can't be done in place.
+		grouped := false
+		for _, c := range d.consumers[nid] {
+			grouped = grouped || c.GetSpec().GetUrn() == urnGBK
+		}
+		d.add(WindowingChanged, name, grouped, fmt.Sprintf("windowing of output %v changed from %v to %v",
+			tag, windowingDesc(ows), windowingDesc(nws)))
+	}
+}
+
+// compareSideInputs adds the side input changes of a ParDo.
+func (d *differ) compareSideInputs(name string, ot, nt *pipepb.PTransform) {
+	var op, np pipepb.ParDoPayload
+	if err := proto.Unmarshal(ot.GetSpec().GetPayload(), &op); err != nil {
+		return
+	}
+	if err := proto.Unmarshal(nt.GetSpec().GetPayload(), &np); err != nil {
+		return
+	}
+	for _, local := range sortedNames(op.GetSideInputs()) {
+		n, ok := np.GetSideInputs()[local]
+		if !ok {
+			d.add(SideInputChanged, name, true, fmt.Sprintf("side input %v removed", local))
+			continue
+		}
+		if o, n := op.GetSideInputs()[local].GetAccessPattern().GetUrn(), n.GetAccessPattern().GetUrn(); o != n {
+			d.add(SideInputChanged, name, true, fmt.Sprintf("access pattern of side input %v changed from %v to %v", local, o, n))
+		}
+	}
+	for _, local := range sortedNames(np.GetSideInputs()) {
+		if _, ok := op.GetSideInputs()[local]; !ok {
+			d.add(SideInputChanged, name, true, fmt.Sprintf("side input %v added", local))
+		}
+	}
+}
+
+// mapName returns the name of the transform after applying the most
+// specific mapping of it or an enclosing composite, and whether any applied.
+func mapName(nameMapping map[string]string, name string) (string, bool) {
+	best := ""
+	found := false
+	for from := range nameMapping {
+		if (name == from || strings.HasPrefix(name, from+"/")) && len(from) >= len(best) {
+			best, found = from, true
+		}
+	}
+	if !found {
+		return name, false
+	}
+	to := nameMapping[best]
+	if to == "" {
+		return "", true
+	}
+	return to + strings.TrimPrefix(name, best), true
+}
+
+func leavesByName(comps *pipepb.Components) map[string]*pipepb.PTransform {
+	ret := make(map[string]*pipepb.PTransform)
+	for _, t := range comps.GetTransforms() {
+		if len(t.GetSubtransforms()) == 0 {
+			ret[t.GetUniqueName()] = t
+		}
+	}
+	return ret
+}
+
+func consumers(comps *pipepb.Components) map[string][]*pipepb.PTransform {
+	ret := make(map[string][]*pipepb.PTransform)
+	for _, t := range comps.GetTransforms() {
+		if len(t.GetSubtransforms()) != 0 {
+			continue
+		}
+		for _, in := range t.GetInputs() {
+			ret[in] = append(ret[in], t)
+		}
+	}
+	return ret
+}
+
+func sameSpec(a, b *pipepb.FunctionSpec) bool {
+	return a.GetUrn() == b.GetUrn() && bytes.Equal(a.GetPayload(), b.GetPayload())
+}
+
+// maxCoderDepth bounds the recursion into malformed, cyclic coders.
+const maxCoderDepth = 32
+
+// coderKey returns a key of the coder that is independent of coder IDs, so
+// coders can be compared across pipelines.
+func coderKey(comps *pipepb.Components, id string, depth int) string {
+	c, ok := comps.GetCoders()[id]
+	if !ok || depth > maxCoderDepth {
+		return "?"
+	}
+	key := c.GetSpec().GetUrn() + payloadKey(c.GetSpec().GetPayload())
+	if len(c.GetComponentCoderIds()) == 0 {
+		return key
+	}
+	var parts []string
+	for _, cid := range c.GetComponentCoderIds() {
+		parts = append(parts, coderKey(comps, cid, depth+1))
+	}
+	return key + "<" + strings.Join(parts, ",") + ">"
+}
+
+// coderDesc returns a short readable description of the coder.
+func coderDesc(comps *pipepb.Components, id string, depth int) string {
+	c, ok := comps.GetCoders()[id]
+	if !ok || depth > maxCoderDepth {
+		return "?"
+	}
+	desc := shortURN(c.GetSpec().GetUrn())
+	if len(c.GetComponentCoderIds()) == 0 {
+		return desc
+	}
+	var parts []string
+	for _, cid := range c.GetComponentCoderIds() {
+		parts = append(parts, coderDesc(comps, cid, depth+1))
+	}
+	return desc + "<" + strings.Join(parts, ",") + ">"
+}
+
+// windowingKey returns a key of the windowing strategy that is independent
+// of coder and environment IDs.
+func windowingKey(ws *pipepb.WindowingStrategy) string {
+	if ws == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v%v|%v|%v|%v|%v|%v|%v|%v",
+		ws.GetWindowFn().GetUrn(), payloadKey(ws.GetWindowFn().GetPayload()),
+		ws.GetMergeStatus(), proto.CompactTextString(ws.GetTrigger()), ws.GetAccumulationMode(),
+		ws.GetAllowedLateness(), ws.GetClosingBehavior(), ws.GetOnTimeBehavior(), ws.GetOutputTime())
+}
+
+func windowingDesc(ws *pipepb.WindowingStrategy) string {
+	if ws == nil {
+		return "?"
+	}
+	return fmt.Sprintf("%v (trigger %v, %v, allowed lateness %vms)",
+		shortURN(ws.GetWindowFn().GetUrn()), proto.CompactTextString(ws.GetTrigger()),
+		ws.GetAccumulationMode(), ws.GetAllowedLateness())
+}
+
+func payloadKey(payload []byte) string {
+	if len(payload) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(payload)
+	return fmt.Sprintf("[%x]", sum[:8])
+}
+
+// shortURN returns the last non-version segment of the URN, such as "kv"
+// for "beam:coder:kv:v1".
+func shortURN(urn string) string {
+	parts := strings.Split(urn, ":")
+	for i := len(parts) - 1; i >= 0; i-- {
+		p := parts[i]
+		if len(p) > 1 && p[0] == 'v' && strings.Trim(p[1:], "0123456789") == "" {
+			continue
+		}
+		return p
+	}
+	return urn
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	var ret []string
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,209 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipelinex
+
+import (
+	"strings"
+	"testing"
+
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+// diffPipeline describes a test pipeline of
+// Impulse -> Parse -> Group, with an optional Log transform reading the
+// output of Parse.
+type diffPipeline struct {
+	parse      string
+	valueCoder string
+	windowFn   string
+	sideInput  bool
+	log        bool
+}
+
+func (dp diffPipeline) build(t *testing.T) *pipepb.Pipeline {
+	t.Helper()
+	if dp.parse == "" {
+		dp.parse = "Parse"
+	}
+	if dp.valueCoder == "" {
+		dp.valueCoder = "beam:coder:varint:v1"
+	}
+	if dp.windowFn == "" {
+		dp.windowFn = "beam:window_fn:global_windows:v1"
+	}
+	parDo := &pipepb.ParDoPayload{DoFn: &pipepb.FunctionSpec{Urn: "beam:go:transform:dofn:v1", Payload: []byte("parse")}}
+	parseIns := map[string]string{"i0": "p0"}
+	if dp.sideInput {
+		parDo.SideInputs = map[string]*pipepb.SideInput{"i1": {AccessPattern: &pipepb.FunctionSpec{Urn: "beam:side_input:iterable:v1"}}}
+		parseIns["i1"] = "p0"
+	}
+	payload, err := proto.Marshal(parDo)
+	if err != nil {
+		t.Fatalf("marshalling ParDoPayload failed: %v", err)
+	}
+	comps := &pipepb.Components{
+		Transforms: map[string]*pipepb.PTransform{
+			"t0": {UniqueName: "Impulse", Spec: &pipepb.FunctionSpec{Urn: "beam:transform:impulse:v1"}, Outputs: map[string]string{"o0": "p0"}},
+			"t1": {UniqueName: dp.parse, Spec: &pipepb.FunctionSpec{Urn: urnParDo, Payload: payload}, Inputs: parseIns, Outputs: map[string]string{"o0": "p1"}},
+			"t2": {UniqueName: "Group", Spec: &pipepb.FunctionSpec{Urn: urnGBK}, Inputs: map[string]string{"i0": "p1"}, Outputs: map[string]string{"o0": "p2"}},
+		},
+		Pcollections: map[string]*pipepb.PCollection{
+			"p0": {CoderId: "c0", WindowingStrategyId: "w0"},
+			"p1": {CoderId: "c1", WindowingStrategyId: "w1"},
+			"p2": {CoderId: "c3", WindowingStrategyId: "w1"},
+		},
+		WindowingStrategies: map[string]*pipepb.WindowingStrategy{
+			"w0": {WindowFn: &pipepb.FunctionSpec{Urn: "beam:window_fn:global_windows:v1"}},
+			"w1": {WindowFn: &pipepb.FunctionSpec{Urn: dp.windowFn}},
+		},
+		Coders: map[string]*pipepb.Coder{
+			"c0": {Spec: &pipepb.FunctionSpec{Urn: "beam:coder:bytes:v1"}},
+			"c1": {Spec: &pipepb.FunctionSpec{Urn: "beam:coder:kv:v1"}, ComponentCoderIds: []string{"c0", "c2"}},
+			"c2": {Spec: &pipepb.FunctionSpec{Urn: dp.valueCoder}},
+			"c3": {Spec: &pipepb.FunctionSpec{Urn: "beam:coder:kv:v1"}, ComponentCoderIds: []string{"c0", "c4"}},
+			"c4": {Spec: &pipepb.FunctionSpec{Urn: "beam:coder:iterable:v1"}, ComponentCoderIds: []string{"c2"}},
+		},
+	}
+	if dp.log {
+		comps.Transforms["t3"] = &pipepb.PTransform{UniqueName: "Log", Spec: &pipepb.FunctionSpec{Urn: urnParDo, Payload: []byte("log")}, Inputs: map[string]string{"i0": "p1"}}
+	}
+	return &pipepb.Pipeline{Components: comps}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after diffPipeline
+		mapping       map[string]string
+		want          []Change
+	}{
+		{
+			name: "identical",
+		}, {
+			name:  "added",
+			after: diffPipeline{log: true},
+			want:  []Change{{Kind: TransformAdded, Transform: "Log", Detail: "added"}},
+		}, {
+			name:    "removed with mapping",
+			before:  diffPipeline{log: true},
+			mapping: map[string]string{"Log": ""},
+			want:    []Change{{Kind: TransformRemoved, Transform: "Log", Detail: "removed, as mapped to the empty name"}},
+		}, {
+			name:   "removed",
+			before: diffPipeline{log: true},
+			want:   []Change{{Kind: TransformRemoved, Transform: "Log", Breaking: true, Detail: "removed; map it to the empty name if intentional"}},
+		}, {
+			name:  "renamed",
+			after: diffPipeline{parse: "ParseV2"},
+			want:  []Change{{Kind: TransformRenamed, Transform: "ParseV2", Breaking: true, Detail: `renamed from "Parse"; add a name mapping to keep its state`}},
+		}, {
+			name:    "renamed with mapping",
+			after:   diffPipeline{parse: "ParseV2"},
+			mapping: map[string]string{"Parse": "ParseV2"},
+		}, {
+			name:  "coder",
+			after: diffPipeline{valueCoder: "beam:coder:string_utf8:v1"},
+			want: []Change{
+				{Kind: CoderChanged, Transform: "Group", Breaking: true, Detail: "coder of output o0 changed from kv<bytes,iterable<varint>> to kv<bytes,iterable<string_utf8>>"},
+				{Kind: CoderChanged, Transform: "Parse", Breaking: true, Detail: "coder of output o0 changed from kv<bytes,varint> to kv<bytes,string_utf8>"},
+			},
+		}, {
+			name:  "side input",
+			after: diffPipeline{sideInput: true},
+			want:  []Change{{Kind: SideInputChanged, Transform: "Parse", Breaking: true, Detail: "side input i1 added"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := Diff(test.before.build(t), test.after.build(t), test.mapping)
+			if err != nil {
+				t.Fatalf("Diff failed: %v", err)
+			}
+			if len(d.Changes) != len(test.want) {
+				t.Fatalf("Diff() = %v, want %v", d.Changes, test.want)
+			}
+			for i, got := range d.Changes {
+				if got != test.want[i] {
+					t.Errorf("Diff()[%d] = %v, want %v", i, got, test.want[i])
+				}
+			}
+			if err := d.Compatible(); (err == nil) != (len(d.Breaking()) == 0) {
+				t.Errorf("Compatible() = %v, want error iff breaking changes %v", err, d.Breaking())
+			}
+		})
+	}
+}
+
+func TestDiff_windowing(t *testing.T) {
+	before := diffPipeline{}.build(t)
+	after := diffPipeline{windowFn: "beam:window_fn:fixed_windows:v1"}.build(t)
+	d, err := Diff(before, after, nil)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	var grouped, ungrouped bool
+	for _, c := range d.Changes {
+		if c.Kind != WindowingChanged {
+			t.Errorf("unexpected change %v", c)
+			continue
+		}
+		if !strings.Contains(c.Detail, "global_windows") || !strings.Contains(c.Detail, "fixed_windows") {
+			t.Errorf("change %v doesn't describe the windowing", c)
+		}
+		switch c.Transform {
+		case "Parse":
+			grouped = c.Breaking
+		case "Group":
+			ungrouped = !c.Breaking
+		}
+	}
+	if !grouped {
+		t.Errorf("Diff() = %v, want breaking windowing change of grouped output of Parse", d.Changes)
+	}
+	if !ungrouped {
+		t.Errorf("Diff() = %v, want non-breaking windowing change of ungrouped output of Group", d.Changes)
+	}
+	if err := d.Compatible(); err == nil {
+		t.Error("Compatible() = nil, want error")
+	}
+}
+
+func TestMapName(t *testing.T) {
+	mapping := map[string]string{
+		"A":     "X",
+		"A/B":   "Y/Z",
+		"Gone":  "",
+		"Other": "O",
+	}
+	tests := []struct {
+		name, want string
+		ok         bool
+	}{
+		{"A", "X", true},
+		{"A/C", "X/C", true},
+		{"A/B", "Y/Z", true},
+		{"A/B/C", "Y/Z/C", true},
+		{"AB", "AB", false},
+		{"Gone/Inner", "", true},
+		{"Kept", "Kept", false},
+	}
+	for _, test := range tests {
+		if got, ok := mapName(mapping, test.name); got != test.want || ok != test.ok {
+			t.Errorf("mapName(%q) = %q, %v, want %q, %v", test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
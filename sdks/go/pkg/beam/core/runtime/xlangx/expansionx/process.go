@@ -19,6 +19,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"os"
 	"os/exec"
 	"time"
 
@@ -32,6 +33,7 @@ type ExpansionServiceRunner struct {
 	jarPath        string
 	servicePort    string
 	serviceCommand *exec.Cmd
+	startTimeout   time.Duration
 }
 
 func findOpenPort() (int, error) {
@@ -55,7 +57,27 @@ func NewExpansionServiceRunner(jarPath, servicePort string) (*ExpansionServiceRu
 		servicePort = fmt.Sprintf("%d", port)
 	}
 	serviceCommand := exec.Command("java", "-jar", jarPath, servicePort)
-	return &ExpansionServiceRunner{jarPath: jarPath, servicePort: servicePort, serviceCommand: serviceCommand}, nil
+	return &ExpansionServiceRunner{jarPath: jarPath, servicePort: servicePort, serviceCommand: serviceCommand, startTimeout: connectionTimeout}, nil
+}
+
+// NewJobServerRunner builds an ExpansionServiceRunner that starts a Beam job server JAR, such
+// as the Flink or Spark job server, with its job service on the given port. Passing an empty
+// string as jobPort will request an open port to be assigned to the job service. The artifact
+// and expansion services of the job server use arbitrary open ports. Additional arguments are
+// passed to the job server.
+func NewJobServerRunner(jarPath, jobPort string, args ...string) (*ExpansionServiceRunner, error) {
+	if jobPort == "" {
+		port, err := findOpenPort()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find open port for job server, got %v", err)
+		}
+		jobPort = fmt.Sprintf("%d", port)
+	}
+	cmdArgs := append([]string{"-jar", jarPath, "--job-port", jobPort, "--artifact-port", "0", "--expansion-port", "0"}, args...)
+	serviceCommand := exec.Command("java", cmdArgs...)
+	serviceCommand.Stdout = os.Stderr
+	serviceCommand.Stderr = os.Stderr
+	return &ExpansionServiceRunner{jarPath: jarPath, servicePort: jobPort, serviceCommand: serviceCommand, startTimeout: jobServerTimeout}, nil
 }
 
 func (e *ExpansionServiceRunner) String() string {
@@ -79,7 +101,10 @@ func (e *ExpansionServiceRunner) pingEndpoint(timeout time.Duration) error {
 	return nil
 }
 
-const connectionTimeout = 15 * time.Second
+const (
+	connectionTimeout = 15 * time.Second
+	jobServerTimeout  = 2 * time.Minute
+)
 
 // StartService starts the expansion service for a given ExpansionServiceRunner. If this is
 // called and does not return an error, the expansion service will be running in the background
@@ -90,7 +115,7 @@ func (e *ExpansionServiceRunner) StartService() error {
 		return err
 	}
 
-	err = e.pingEndpoint(connectionTimeout)
+	err = e.pingEndpoint(e.startTimeout)
 	if err != nil {
 		return err
 	}
@@ -61,6 +61,22 @@ func TestNewExpansionServiceRunner(t *testing.T) {
 	}
 }
 
+func TestNewJobServerRunner(t *testing.T) {
+	testPath := "path/to/job-server.jar"
+	serviceRunner, err := NewJobServerRunner(testPath, "", "--flink-master", "[local]")
+	if err != nil {
+		t.Fatalf("NewJobServerRunner failed, got %v", err)
+	}
+	if serviceRunner.servicePort == "" {
+		t.Errorf("no open port assigned to job server")
+	}
+	commandString := strings.Join(serviceRunner.serviceCommand.Args, " ")
+	want := "java -jar " + testPath + " --job-port " + serviceRunner.servicePort + " --artifact-port 0 --expansion-port 0 --flink-master [local]"
+	if commandString != want {
+		t.Errorf("got command %v, want %v", commandString, want)
+	}
+}
+
 func TestEndpoint(t *testing.T) {
 	testPort := "8097"
 	serviceRunner, err := NewExpansionServiceRunner("", testPort)
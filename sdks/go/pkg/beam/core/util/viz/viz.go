@@ -0,0 +1,407 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package viz renders pipeline protos as self-contained HTML pages, with an
+// SVG graph of the primitive transforms and a collapsible tree of the
+// composite transforms, their PCollections, coders, windowing strategies and
+// side inputs. Metrics of a pipeline run can be overlaid on the graph, to
+// spot slow stages at a glance.
+package viz
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Layout of the graph, in pixels.
+const (
+	nodeWidth  = 220
+	nodeHeight = 48
+	colWidth   = nodeWidth + 80
+	rowHeight  = nodeHeight + 40
+	margin     = 20
+)
+
+// Render writes an HTML page visualizing the pipeline to w. Metrics from
+// the results of running the pipeline, such as from
+// beam.PipelineResult.Metrics().AllMetrics(), are shown on the transforms
+// and PCollections they belong to. Metrics are matched by PTransform ID,
+// unique name, or the last component of the unique name.
+func Render(w io.Writer, p *pipepb.Pipeline, qr metrics.QueryResults) error {
+	page, err := newPage(p, qr)
+	if err != nil {
+		return err
+	}
+	if err := pageTmpl.Execute(w, page); err != nil {
+		return errors.Wrap(err, "render HTML failed")
+	}
+	return nil
+}
+
+// page is the data of the HTML template.
+type page struct {
+	Width, Height int
+	Nodes         []*node
+	Edges         []*edge
+	Roots         []*composite
+	HasMetrics    bool
+}
+
+// node is a primitive transform in the graph.
+type node struct {
+	ID, Name, Label, URN string
+	X, Y                 int
+	Msecs                int64
+	Fill                 string
+	Slowest              bool
+
+	layer int
+}
+
+// edge is a PCollection flowing between primitive transforms.
+type edge struct {
+	PCollection string
+	Label       string
+	Side        bool
+	Path        string
+	LabelX      int
+	LabelY      int
+}
+
+// composite is a transform in the collapsible tree.
+type composite struct {
+	ID, Name, URN string
+	Msecs         string
+	Inputs        []*pcollection
+	Outputs       []*pcollection
+	Children      []*composite
+}
+
+// pcollection describes an input or output of a transform.
+type pcollection struct {
+	Local, ID string
+	Coder     string
+	Windowing string
+	Elements  string
+	Side      bool
+}
+
+type builder struct {
+	p        *pipepb.Pipeline
+	comps    *pipepb.Components
+	msecs    map[string]time.Duration
+	elements map[string]int64
+}
+
+func newPage(p *pipepb.Pipeline, qr metrics.QueryResults) (*page, error) {
+	b := &builder{
+		p:        p,
+		comps:    p.GetComponents(),
+		msecs:    make(map[string]time.Duration),
+		elements: make(map[string]int64),
+	}
+	for _, m := range qr.Msecs() {
+		b.msecs[m.Key.Step] += m.Result().Total
+	}
+	for _, c := range qr.PCols() {
+		b.elements[c.Key.Step] += c.Result().ElementCount
+	}
+	pg := &page{HasMetrics: len(b.msecs)+len(b.elements) > 0}
+
+	var leaves []string
+	for _, id := range p.GetRootTransformIds() {
+		c, err := b.composite(id, &leaves)
+		if err != nil {
+			return nil, err
+		}
+		pg.Roots = append(pg.Roots, c)
+	}
+	b.layout(pg, leaves)
+	return pg, nil
+}
+
+// composite builds the tree of the transform, and appends the IDs of its
+// primitive transforms to leaves.
+func (b *builder) composite(id string, leaves *[]string) (*composite, error) {
+	t, ok := b.comps.GetTransforms()[id]
+	if !ok {
+		return nil, errors.Errorf("transform %v not found in pipeline", id)
+	}
+	sides, err := sideInputs(t)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid transform %v", id)
+	}
+	c := &composite{ID: id, Name: t.GetUniqueName(), URN: t.GetSpec().GetUrn()}
+	if d, ok := b.transformMsecs(id, t); ok {
+		c.Msecs = d.String()
+	}
+	for _, local := range sortedKeys(t.GetInputs()) {
+		c.Inputs = append(c.Inputs, b.pcollection(local, t.GetInputs()[local], sides[local]))
+	}
+	for _, local := range sortedKeys(t.GetOutputs()) {
+		c.Outputs = append(c.Outputs, b.pcollection(local, t.GetOutputs()[local], false))
+	}
+	if len(t.GetSubtransforms()) == 0 {
+		*leaves = append(*leaves, id)
+	}
+	for _, sub := range t.GetSubtransforms() {
+		child, err := b.composite(sub, leaves)
+		if err != nil {
+			return nil, err
+		}
+		c.Children = append(c.Children, child)
+	}
+	return c, nil
+}
+
+func (b *builder) pcollection(local, id string, side bool) *pcollection {
+	pc := b.comps.GetPcollections()[id]
+	ret := &pcollection{
+		Local:     local,
+		ID:        id,
+		Coder:     b.coder(pc.GetCoderId(), 0),
+		Windowing: b.windowing(pc.GetWindowingStrategyId()),
+		Side:      side,
+	}
+	if n, ok := b.elements[id]; ok {
+		ret.Elements = fmt.Sprint(n)
+	}
+	return ret
+}
+
+// transformMsecs returns the execution time of the transform, if known.
+func (b *builder) transformMsecs(id string, t *pipepb.PTransform) (time.Duration, bool) {
+	for _, key := range []string{id, t.GetUniqueName(), path.Base(t.GetUniqueName())} {
+		if d, ok := b.msecs[key]; ok {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// coder returns a short description of the coder, such as kv<bytes,varint>.
+func (b *builder) coder(id string, depth int) string {
+	c, ok := b.comps.GetCoders()[id]
+	if !ok {
+		return id
+	}
+	name := shortURN(c.GetSpec().GetUrn(), "beam:coder:")
+	if depth > 8 || len(c.GetComponentCoderIds()) == 0 {
+		return name
+	}
+	var comps []string
+	for _, cid := range c.GetComponentCoderIds() {
+		comps = append(comps, b.coder(cid, depth+1))
+	}
+	return fmt.Sprintf("%v<%v>", name, strings.Join(comps, ","))
+}
+
+// windowing returns a short description of the windowing strategy.
+func (b *builder) windowing(id string) string {
+	ws, ok := b.comps.GetWindowingStrategies()[id]
+	if !ok {
+		return id
+	}
+	trigger := strings.TrimPrefix(fmt.Sprintf("%T", ws.GetTrigger().GetTrigger()), "*pipeline_v1.Trigger_")
+	return fmt.Sprintf("%v, trigger %v, %v", shortURN(ws.GetWindowFn().GetUrn(), "beam:window_fn:"), strings.TrimSuffix(trigger, "_"), strings.ToLower(ws.GetAccumulationMode().String()))
+}
+
+// layout places the primitive transforms in columns by their depth in the
+// graph, and connects them by their PCollections.
+func (b *builder) layout(pg *page, leaves []string) {
+	producers := make(map[string]*node)
+	nodes := make(map[string]*node)
+	for _, id := range leaves {
+		t := b.comps.GetTransforms()[id]
+		n := &node{ID: id, Name: t.GetUniqueName(), Label: path.Base(t.GetUniqueName()), URN: t.GetSpec().GetUrn()}
+		if d, ok := b.transformMsecs(id, t); ok {
+			n.Msecs = d.Milliseconds()
+		}
+		nodes[id] = n
+		pg.Nodes = append(pg.Nodes, n)
+		for _, pcol := range t.GetOutputs() {
+			producers[pcol] = n
+		}
+	}
+
+	// Assign layers by longest path from the sources. Leaves are in
+	// topological order in well formed pipelines, but don't rely on it.
+	type link struct {
+		from, to *node
+		pcol     string
+		side     bool
+	}
+	var links []link
+	for _, id := range leaves {
+		t := b.comps.GetTransforms()[id]
+		sides, _ := sideInputs(t)
+		for _, local := range sortedKeys(t.GetInputs()) {
+			pcol := t.GetInputs()[local]
+			if from, ok := producers[pcol]; ok && from != nodes[id] {
+				links = append(links, link{from: from, to: nodes[id], pcol: pcol, side: sides[local]})
+			}
+		}
+	}
+	for i := 0; i < len(pg.Nodes); i++ {
+		changed := false
+		for _, l := range links {
+			if l.to.layer <= l.from.layer {
+				l.to.layer = l.from.layer + 1
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	rows := make(map[int]int)
+	var maxMsecs int64
+	var slowest *node
+	for _, n := range pg.Nodes {
+		n.X = margin + n.layer*colWidth
+		n.Y = margin + rows[n.layer]*rowHeight
+		rows[n.layer]++
+		if x := n.X + nodeWidth + margin; x > pg.Width {
+			pg.Width = x
+		}
+		if y := n.Y + nodeHeight + margin; y > pg.Height {
+			pg.Height = y
+		}
+		if n.Msecs > maxMsecs {
+			maxMsecs, slowest = n.Msecs, n
+		}
+	}
+	for _, n := range pg.Nodes {
+		n.Fill = "#eef3fb"
+		if maxMsecs > 0 {
+			// Shade from white to red by the share of the slowest time.
+			lightness := 97 - 45*float64(n.Msecs)/float64(maxMsecs)
+			n.Fill = fmt.Sprintf("hsl(0,75%%,%.0f%%)", lightness)
+		}
+	}
+	if slowest != nil {
+		slowest.Slowest = true
+	}
+
+	for _, l := range links {
+		x1, y1 := l.from.X+nodeWidth, l.from.Y+nodeHeight/2
+		x2, y2 := l.to.X, l.to.Y+nodeHeight/2
+		e := &edge{
+			PCollection: l.pcol,
+			Side:        l.side,
+			Path:        fmt.Sprintf("M%d,%d C%d,%d %d,%d %d,%d", x1, y1, (x1+x2)/2, y1, (x1+x2)/2, y2, x2, y2),
+			LabelX:      (x1 + x2) / 2,
+			LabelY:      (y1+y2)/2 - 4,
+		}
+		if n, ok := b.elements[l.pcol]; ok {
+			e.Label = fmt.Sprint(n)
+		}
+		pg.Edges = append(pg.Edges, e)
+	}
+}
+
+// sideInputs returns the local names of the side inputs of the transform.
+func sideInputs(t *pipepb.PTransform) (map[string]bool, error) {
+	if t.GetSpec().GetUrn() != graphx.URNParDo {
+		return nil, nil
+	}
+	var payload pipepb.ParDoPayload
+	if err := proto.Unmarshal(t.GetSpec().GetPayload(), &payload); err != nil {
+		return nil, errors.Wrap(err, "invalid ParDo payload")
+	}
+	sides := make(map[string]bool)
+	for local := range payload.GetSideInputs() {
+		sides[local] = true
+	}
+	return sides, nil
+}
+
+// shortURN strips the common prefix and version of the URN.
+func shortURN(urn, prefix string) string {
+	s := strings.TrimPrefix(urn, prefix)
+	if i := strings.LastIndex(s, ":v"); i > 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var pageTmpl = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Pipeline</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; margin: 16px; }
+svg text { font-size: 11px; }
+.node rect { stroke: #4a6fa5; stroke-width: 1; }
+.node.slowest rect { stroke: #b2182b; stroke-width: 3; }
+.edge { fill: none; stroke: #555; stroke-width: 1.2; }
+.edge.side { stroke-dasharray: 4,3; stroke: #888; }
+details { margin-left: 16px; }
+summary { cursor: pointer; }
+table { border-collapse: collapse; margin: 4px 0 4px 16px; }
+td, th { border: 1px solid #ccc; padding: 2px 6px; text-align: left; }
+.urn, .id { color: #666; }
+</style>
+</head>
+<body>
+<h2>Pipeline graph</h2>
+{{if .HasMetrics}}<p>Transforms are shaded by execution time; the slowest is outlined in red. Edges are labeled with element counts.</p>{{end}}
+<svg width="{{.Width}}" height="{{.Height}}" xmlns="http://www.w3.org/2000/svg">
+<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto"><path d="M0,0 L10,5 L0,10 z" fill="#555"/></marker></defs>
+{{range .Edges}}<path class="edge{{if .Side}} side{{end}}" d="{{.Path}}" marker-end="url(#arrow)"><title>{{.PCollection}}{{if .Side}} (side input){{end}}</title></path>
+{{if .Label}}<text x="{{.LabelX}}" y="{{.LabelY}}" text-anchor="middle">{{.Label}}</text>
+{{end}}{{end}}{{range .Nodes}}<g class="node{{if .Slowest}} slowest{{end}}" id="node-{{.ID}}">
+<title>{{.Name}} ({{.ID}})
+{{.URN}}</title>
+<rect x="{{.X}}" y="{{.Y}}" width="` + fmt.Sprint(nodeWidth) + `" height="` + fmt.Sprint(nodeHeight) + `" rx="4" fill="{{.Fill}}"/>
+<text x="{{.X}}" y="{{.Y}}" dx="8" dy="18">{{.Label}}</text>
+{{if .Msecs}}<text x="{{.X}}" y="{{.Y}}" dx="8" dy="36">{{.Msecs}} ms</text>
+{{end}}</g>
+{{end}}</svg>
+<h2>Transforms</h2>
+{{range .Roots}}{{template "composite" .}}{{end}}
+</body>
+</html>
+{{define "composite"}}<details{{if .Children}} open{{end}}>
+<summary><b>{{.Name}}</b> <span class="id">{{.ID}}</span> <span class="urn">{{.URN}}</span>{{if .Msecs}} &mdash; {{.Msecs}}{{end}}</summary>
+{{if or .Inputs .Outputs}}<table>
+<tr><th></th><th>Name</th><th>PCollection</th><th>Coder</th><th>Windowing</th><th>Elements</th></tr>
+{{range .Inputs}}<tr><td>{{if .Side}}side input{{else}}input{{end}}</td><td>{{.Local}}</td><td>{{.ID}}</td><td>{{.Coder}}</td><td>{{.Windowing}}</td><td>{{.Elements}}</td></tr>
+{{end}}{{range .Outputs}}<tr><td>output</td><td>{{.Local}}</td><td>{{.ID}}</td><td>{{.Coder}}</td><td>{{.Windowing}}</td><td>{{.Elements}}</td></tr>
+{{end}}</table>
+{{end}}{{range .Children}}{{template "composite" .}}{{end}}</details>
+{{end}}`))
@@ -0,0 +1,146 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package viz
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/protox"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+)
+
+func testPipeline() *pipepb.Pipeline {
+	pardo := func(sides ...string) *pipepb.FunctionSpec {
+		payload := &pipepb.ParDoPayload{SideInputs: make(map[string]*pipepb.SideInput)}
+		for _, s := range sides {
+			payload.SideInputs[s] = &pipepb.SideInput{}
+		}
+		return &pipepb.FunctionSpec{Urn: graphx.URNParDo, Payload: protox.MustEncode(payload)}
+	}
+	return &pipepb.Pipeline{
+		RootTransformIds: []string{"read", "count"},
+		Components: &pipepb.Components{
+			Transforms: map[string]*pipepb.PTransform{
+				"read": {
+					UniqueName: "Read",
+					Spec:       &pipepb.FunctionSpec{Urn: graphx.URNImpulse},
+					Outputs:    map[string]string{"o0": "n1"},
+				},
+				"count": {
+					UniqueName:    "Count",
+					Subtransforms: []string{"extract", "sum"},
+					Inputs:        map[string]string{"i0": "n1"},
+					Outputs:       map[string]string{"o0": "n3"},
+				},
+				"extract": {
+					UniqueName: "Count/main.extractFn",
+					Spec:       pardo(),
+					Inputs:     map[string]string{"i0": "n1"},
+					Outputs:    map[string]string{"o0": "n2"},
+				},
+				"sum": {
+					UniqueName: "Count/<sum>",
+					Spec:       pardo("i1"),
+					Inputs:     map[string]string{"i0": "n2", "i1": "n1"},
+					Outputs:    map[string]string{"o0": "n3"},
+				},
+			},
+			Pcollections: map[string]*pipepb.PCollection{
+				"n1": {CoderId: "c0", WindowingStrategyId: "w0"},
+				"n2": {CoderId: "c2", WindowingStrategyId: "w0"},
+				"n3": {CoderId: "c1", WindowingStrategyId: "w0"},
+			},
+			Coders: map[string]*pipepb.Coder{
+				"c0": {Spec: &pipepb.FunctionSpec{Urn: "beam:coder:bytes:v1"}},
+				"c1": {Spec: &pipepb.FunctionSpec{Urn: "beam:coder:varint:v1"}},
+				"c2": {Spec: &pipepb.FunctionSpec{Urn: "beam:coder:kv:v1"}, ComponentCoderIds: []string{"c0", "c1"}},
+			},
+			WindowingStrategies: map[string]*pipepb.WindowingStrategy{
+				"w0": {
+					WindowFn:         &pipepb.FunctionSpec{Urn: "beam:window_fn:global_windows:v1"},
+					Trigger:          &pipepb.Trigger{Trigger: &pipepb.Trigger_Default_{Default: &pipepb.Trigger_Default{}}},
+					AccumulationMode: pipepb.AccumulationMode_DISCARDING,
+				},
+			},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	qr := metrics.NewResults(nil, nil, nil,
+		[]metrics.MsecResult{
+			{Attempted: metrics.MsecValue{Total: 3 * time.Second}, Key: metrics.StepKey{Step: "main.extractFn"}},
+			{Attempted: metrics.MsecValue{Total: time.Second}, Key: metrics.StepKey{Step: "sum"}},
+		},
+		[]metrics.PColResult{
+			{Attempted: metrics.PColValue{ElementCount: 1234}, Key: metrics.StepKey{Step: "n2"}},
+		}, nil, nil).AllMetrics()
+
+	var b strings.Builder
+	if err := Render(&b, testPipeline(), qr); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		// The slowest transform is highlighted, matched by its short name.
+		`<g class="node slowest" id="node-extract">`,
+		`3000 ms`,
+		`<g class="node" id="node-sum">`,
+		// Element counts label their PCollections.
+		`>1234</text>`,
+		`<path class="edge side"`,
+		// The composite tree.
+		`<summary><b>Count</b> <span class="id">count</span>`,
+		`<td>side input</td><td>i1</td><td>n1</td><td>bytes</td><td>global_windows, trigger Default, discarding</td>`,
+		`<td>kv&lt;bytes,varint&gt;</td>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render() output doesn't contain %q:\n%v", want, got)
+		}
+	}
+}
+
+func TestRender_layout(t *testing.T) {
+	pg, err := newPage(testPipeline(), metrics.QueryResults{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := make(map[string]int)
+	for _, n := range pg.Nodes {
+		layers[n.ID] = n.layer
+	}
+	if layers["read"] != 0 || layers["extract"] != 1 || layers["sum"] != 2 {
+		t.Errorf("layers = %v, want read, extract and sum in consecutive layers", layers)
+	}
+	if len(pg.Edges) != 3 {
+		t.Errorf("got %v edges, want 3", len(pg.Edges))
+	}
+	if pg.HasMetrics {
+		t.Error("HasMetrics = true without metrics")
+	}
+}
+
+func TestRender_missingTransform(t *testing.T) {
+	p := testPipeline()
+	p.RootTransformIds = append(p.RootTransformIds, "missing")
+	if err := Render(&strings.Builder{}, p, metrics.QueryResults{}); err == nil {
+		t.Error("Render succeeded with a missing transform, want error")
+	}
+}
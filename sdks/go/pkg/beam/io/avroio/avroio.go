@@ -17,19 +17,25 @@
 package avroio
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"reflect"
 	"strings"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
 	"github.com/linkedin/goavro"
 )
 
 func init() {
 	beam.RegisterFunction(expandFn)
+	beam.RegisterFunction(sizeFn)
 	beam.RegisterType(reflect.TypeOf((*avroReadFn)(nil)).Elem())
 	beam.RegisterType(reflect.TypeOf((*writeAvroFn)(nil)).Elem())
 }
@@ -39,6 +45,9 @@ func init() {
 // A type - reflect.TypeOf( YourType{} ) -  with
 // JSON tags can be defined or if you wish to return the raw JSON string,
 // use - reflect.TypeOf("") -
+//
+// Files are split at block boundaries, so blocks of a single file may be
+// read in parallel.
 func Read(s beam.Scope, glob string, t reflect.Type) beam.PCollection {
 	s = s.Scope("avroio.Read")
 	filesystem.ValidateScheme(glob)
@@ -47,9 +56,10 @@ func Read(s beam.Scope, glob string, t reflect.Type) beam.PCollection {
 
 func read(s beam.Scope, t reflect.Type, col beam.PCollection) beam.PCollection {
 	files := beam.ParDo(s, expandFn, col)
+	sized := beam.ParDo(s, sizeFn, files)
 	return beam.ParDo(s,
 		&avroReadFn{Type: beam.EncodedType{T: t}},
-		files,
+		sized,
 		beam.TypeDefinition{Var: beam.XType, T: t},
 	)
 }
@@ -75,12 +85,75 @@ func expandFn(ctx context.Context, glob string, emit func(string)) error {
 	return nil
 }
 
+// sizeFn pairs a filename with the size of that file in bytes.
+func sizeFn(ctx context.Context, filename string) (string, int64, error) {
+	fs, err := filesystem.New(ctx, filename)
+	if err != nil {
+		return "", -1, err
+	}
+	defer fs.Close()
+
+	size, err := fs.Size(ctx, filename)
+	if err != nil {
+		return "", -1, err
+	}
+	return filename, size, nil
+}
+
 type avroReadFn struct {
 	// Avro schema type
 	Type beam.EncodedType
 }
 
-func (f *avroReadFn) ProcessElement(ctx context.Context, filename string, emit func(beam.X)) (err error) {
+// CreateInitialRestriction creates an offset range restriction representing
+// the file, using the paired size rather than fetching the file's size.
+func (f *avroReadFn) CreateInitialRestriction(_ string, size int64) offsetrange.Restriction {
+	return offsetrange.Restriction{
+		Start: 0,
+		End:   size,
+	}
+}
+
+const (
+	// blockSize is the desired size of each block for initial splits.
+	blockSize int64 = 64 * 1024 * 1024 // 64 MB
+	// tooSmall is the size limit for a block. If the last block is smaller than
+	// this, it gets merged with the previous block.
+	tooSmall = blockSize / 4
+)
+
+// SplitRestriction splits each file restriction into blocks of a predeterined
+// size, with some checks to avoid having small remainders.
+func (f *avroReadFn) SplitRestriction(_ string, _ int64, rest offsetrange.Restriction) []offsetrange.Restriction {
+	splits := rest.SizedSplits(blockSize)
+	numSplits := len(splits)
+	if numSplits > 1 {
+		last := splits[numSplits-1]
+		if last.End-last.Start <= tooSmall {
+			// Last restriction is too small, so merge it with previous one.
+			splits[numSplits-2].End = last.End
+			splits = splits[:numSplits-1]
+		}
+	}
+	return splits
+}
+
+// RestrictionSize returns the size of each restriction as its range.
+func (f *avroReadFn) RestrictionSize(_ string, _ int64, rest offsetrange.Restriction) float64 {
+	return rest.Size()
+}
+
+// CreateTracker creates sdf.LockRTrackers wrapping offsetRange.Trackers for
+// each restriction.
+func (f *avroReadFn) CreateTracker(rest offsetrange.Restriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(offsetrange.NewTracker(rest))
+}
+
+// ProcessElement outputs the records of all blocks in the file that begin
+// within the paired restriction. A block begins right after the sync marker
+// that precedes it, so the first block in a restriction is found by
+// searching for the sync marker that ends at or after the restriction start.
+func (f *avroReadFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, filename string, _ int64, emit func(beam.X)) (err error) {
 	log.Infof(ctx, "Reading AVRO from %v", filename)
 
 	fs, err := filesystem.New(ctx, filename)
@@ -89,18 +162,69 @@ func (f *avroReadFn) ProcessElement(ctx context.Context, filename string, emit f
 	}
 	defer fs.Close()
 
-	fd, err := fs.OpenRead(ctx, filename)
+	header, sync, err := f.readHeader(ctx, fs, filename)
+	if err != nil {
+		return
+	}
+
+	rest := rt.GetRestriction().(offsetrange.Restriction)
+	offset := rest.Start - syncSize
+	if offset < 0 {
+		offset = 0
+	}
+	fd, err := filesystem.OpenReadRange(ctx, fs, filename, offset, -1)
 	if err != nil {
 		return
 	}
 	defer fd.Close()
+	rd := bufio.NewReader(fd)
 
-	ar, err := goavro.NewOCFReader(fd)
+	pos, err := skipToSync(rd, sync, offset)
+	if err == io.EOF {
+		// No blocks start in the restriction but it's still valid, so
+		// finish claiming before returning to avoid errors.
+		rt.TryClaim(rest.End)
+		return nil
+	}
 	if err != nil {
-		log.Errorf(ctx, "error reading avro: %v", err)
 		return
 	}
+	for rt.TryClaim(pos) {
+		var block []byte
+		block, err = readBlock(rd, sync)
+		if err == io.EOF {
+			// Finish claiming restriction before returning to avoid errors.
+			rt.TryClaim(rest.End)
+			return nil
+		}
+		if err != nil {
+			return
+		}
+		// Decode the block as a single block container file, so the codec
+		// and compression from the header apply.
+		ar, err := goavro.NewOCFReader(io.MultiReader(bytes.NewReader(header), bytes.NewReader(block)))
+		if err != nil {
+			log.Errorf(ctx, "error reading avro: %v", err)
+			return err
+		}
+		if err := f.emitRecords(ctx, ar, emit); err != nil {
+			return err
+		}
+		pos += int64(len(block))
+	}
+	return nil
+}
+
+func (f *avroReadFn) readHeader(ctx context.Context, fs filesystem.Interface, filename string) ([]byte, [syncSize]byte, error) {
+	fd, err := fs.OpenRead(ctx, filename)
+	if err != nil {
+		return nil, [syncSize]byte{}, err
+	}
+	defer fd.Close()
+	return readHeader(fd)
+}
 
+func (f *avroReadFn) emitRecords(ctx context.Context, ar *goavro.OCFReader, emit func(beam.X)) (err error) {
 	val := reflect.New(f.Type.T).Interface()
 	for ar.Scan() {
 		var i interface{}
@@ -17,10 +17,13 @@ package avroio
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -123,3 +126,63 @@ func TestWrite(t *testing.T) {
 		t.Fatalf("User.User=%v, want %v", got, want)
 	}
 }
+
+func TestReadFn_splits(t *testing.T) {
+	avroFile := filepath.Join(t.TempDir(), "users.avro")
+	fd, err := os.Create(avroFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec, err := goavro.NewCodec(userSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ocfw, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		Codec:           codec,
+		CompressionName: goavro.CompressionSnappyLabel,
+		Schema:          userSchema,
+		W:               fd,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const numUsers = 50
+	var want []string
+	for i := 0; i < numUsers; i++ {
+		user := map[string]interface{}{"username": fmt.Sprintf("user%d", i), "info": "info"}
+		// Each Append writes a separate block.
+		if err := ocfw.Append([]interface{}{user}); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, fmt.Sprintf(`{"info":"info","username":"user%d"}`, i))
+	}
+	fd.Close()
+
+	info, err := os.Stat(avroFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := &avroReadFn{Type: beam.EncodedType{T: reflect.TypeOf("")}}
+	ctx := context.Background()
+	// Read the file in small restrictions, which split blocks and the
+	// header, and check every record is read exactly once.
+	for _, splitSize := range []int64{1, 7, 37, 100, info.Size()} {
+		var got []string
+		rest := fn.CreateInitialRestriction(avroFile, info.Size())
+		for _, split := range rest.SizedSplits(splitSize) {
+			rt := fn.CreateTracker(split)
+			err := fn.ProcessElement(ctx, rt, avroFile, info.Size(), func(x beam.X) {
+				got = append(got, x.(string))
+			})
+			if err != nil {
+				t.Fatalf("ProcessElement(%v) failed: %v", split, err)
+			}
+			if !rt.IsDone() {
+				t.Errorf("restriction %v not fully claimed", split)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("split size %d read %v records, want %v: got %v", splitSize, len(got), len(want), got)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avroio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+// Avro object container files consist of a header followed by blocks of
+// records. The header and each block end with the same randomly generated
+// sync marker, which allows a reader to start at an arbitrary offset and find
+// the next block boundary.
+//
+// See https://avro.apache.org/docs/current/spec.html#Object+Container+Files.
+
+const syncSize = 16
+
+var ocfMagic = []byte{'O', 'b', 'j', 1}
+
+// recordingReader records all bytes read through it.
+type recordingReader struct {
+	r   *bufio.Reader
+	rec []byte
+}
+
+func (r *recordingReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.rec = append(r.rec, b)
+	}
+	return b, err
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.rec = append(r.rec, p[:n]...)
+	return n, err
+}
+
+// readHeader reads the header of an object container file, returning the
+// raw header bytes, which end with the sync marker, and the sync marker.
+func readHeader(r io.Reader) ([]byte, [syncSize]byte, error) {
+	var sync [syncSize]byte
+	rr := &recordingReader{r: bufio.NewReader(r)}
+
+	magic := make([]byte, len(ocfMagic))
+	if _, err := io.ReadFull(rr, magic); err != nil {
+		return nil, sync, errors.Wrap(err, "failed to read avro header")
+	}
+	if !bytes.Equal(magic, ocfMagic) {
+		return nil, sync, errors.Errorf("not an avro object container file: magic %q", magic)
+	}
+	// The metadata is a map<bytes>, encoded as blocks of key value pairs
+	// terminated by an empty block.
+	for {
+		count, err := binary.ReadVarint(rr)
+		if err != nil {
+			return nil, sync, errors.Wrap(err, "failed to read avro header metadata")
+		}
+		if count == 0 {
+			break
+		}
+		if count < 0 {
+			// A negative count is followed by the block size in bytes.
+			count = -count
+			if _, err := binary.ReadVarint(rr); err != nil {
+				return nil, sync, errors.Wrap(err, "failed to read avro header metadata")
+			}
+		}
+		for i := int64(0); i < 2*count; i++ { // A key and a value per entry.
+			if err := skipBytes(rr); err != nil {
+				return nil, sync, errors.Wrap(err, "failed to read avro header metadata")
+			}
+		}
+	}
+	if _, err := io.ReadFull(rr, sync[:]); err != nil {
+		return nil, sync, errors.Wrap(err, "failed to read avro sync marker")
+	}
+	return rr.rec, sync, nil
+}
+
+// skipBytes skips a length prefixed avro string or bytes value.
+func skipBytes(rr *recordingReader) error {
+	n, err := binary.ReadVarint(rr)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return errors.Errorf("negative length %d", n)
+	}
+	_, err = io.CopyN(io.Discard, rr, n)
+	return err
+}
+
+// skipToSync reads from r, which is positioned at offset in the file, until
+// after the next sync marker. It returns the offset just after the sync
+// marker, which is the start of a block or the end of the file.
+func skipToSync(r io.ByteReader, sync [syncSize]byte, offset int64) (int64, error) {
+	var window [syncSize]byte
+	for n := 1; ; n++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return offset, err
+		}
+		offset++
+		copy(window[:], window[1:])
+		window[syncSize-1] = b
+		if n >= syncSize && window == sync {
+			return offset, nil
+		}
+	}
+}
+
+// readBlock reads the block at the current position of r, which must be just
+// after a sync marker. It returns the raw block including its trailing sync
+// marker, or io.EOF if there are no more blocks.
+func readBlock(r *bufio.Reader, sync [syncSize]byte) ([]byte, error) {
+	count, err := binary.ReadVarint(r)
+	if err != nil {
+		return nil, err // Includes io.EOF at the end of the file.
+	}
+	size, err := binary.ReadVarint(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read avro block size")
+	}
+	if count < 0 || size < 0 {
+		return nil, errors.Errorf("invalid avro block with %d records of %d bytes", count, size)
+	}
+	block := make([]byte, 2*binary.MaxVarintLen64+int(size)+syncSize)
+	start := binary.PutVarint(block, count)
+	start += binary.PutVarint(block[start:], size)
+	block = block[:start+int(size)+syncSize]
+	if _, err := io.ReadFull(r, block[start:]); err != nil {
+		return nil, errors.Wrap(err, "failed to read avro block")
+	}
+	if !bytes.Equal(block[len(block)-syncSize:], sync[:]) {
+		return nil, errors.New("avro block does not end with the sync marker; file is corrupt")
+	}
+	return block, nil
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/valueprovider"
 	bq "google.golang.org/api/bigquery/v2"
 	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
@@ -93,6 +94,20 @@ func Read(s beam.Scope, project, table string, t reflect.Type) beam.PCollection
 	return query(s, project, fmt.Sprintf("SELECT * from [%v]", table), t)
 }
 
+// ReadValue is a variation of Read where the table may be a runtime parameter,
+// resolved when the pipeline is executed.
+func ReadValue(s beam.Scope, project string, table valueprovider.Value, t reflect.Type) beam.PCollection {
+	if !table.IsRuntime() {
+		return Read(s, project, table.Static, t)
+	}
+	mustInferSchema(t)
+
+	s = s.Scope("bigquery.ReadValue")
+
+	imp := beam.Impulse(s)
+	return beam.ParDo(s, &queryFn{Project: project, Table: table, Type: beam.EncodedType{T: t}}, imp, beam.TypeDefinition{Var: beam.XType, T: t})
+}
+
 // QueryOptions represents additional options for executing a query.
 type QueryOptions struct {
 	// UseStandardSQL enables BigQuery's Standard SQL dialect when executing a query.
@@ -133,6 +148,8 @@ type queryFn struct {
 	Project string `json:"project"`
 	// Table is the table identifier.
 	Query string `json:"query"`
+	// Table is the table read in full, if the table is a runtime parameter.
+	Table valueprovider.Value `json:"table,omitempty"`
 	// Type is the encoded schema type.
 	Type beam.EncodedType `json:"type"`
 	// Options specifies additional query execution options.
@@ -140,13 +157,22 @@ type queryFn struct {
 }
 
 func (f *queryFn) ProcessElement(ctx context.Context, _ []byte, emit func(beam.X)) error {
+	query := f.Query
+	if f.Table.IsRuntime() {
+		qn, err := resolveTable(f.Table)
+		if err != nil {
+			return err
+		}
+		query = fmt.Sprintf("SELECT * from [%v]", qn)
+	}
+
 	client, err := bigquery.NewClient(ctx, f.Project)
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
-	q := client.Query(f.Query)
+	q := client.Query(query)
 	if !f.Options.UseStandardSQL {
 		q.UseLegacySQL = true
 	}
@@ -181,6 +207,14 @@ func mustInferSchema(t reflect.Type) bigquery.Schema {
 	return schema
 }
 
+func resolveTable(table valueprovider.Value) (QualifiedTableName, error) {
+	name, err := table.Get()
+	if err != nil {
+		return QualifiedTableName{}, err
+	}
+	return NewQualifiedTableName(name)
+}
+
 func mustParseTable(table string) QualifiedTableName {
 	qn, err := NewQualifiedTableName(table)
 	if err != nil {
@@ -208,11 +242,30 @@ func Write(s beam.Scope, project, table string, col beam.PCollection) {
 	beam.ParDo0(s, &writeFn{Project: project, Table: qn, Type: beam.EncodedType{T: t}}, post)
 }
 
+// WriteValue is a variation of Write where the table may be a runtime
+// parameter, resolved when the pipeline is executed.
+func WriteValue(s beam.Scope, project string, table valueprovider.Value, col beam.PCollection) {
+	if !table.IsRuntime() {
+		Write(s, project, table.Static, col)
+		return
+	}
+	t := col.Type().Type()
+	mustInferSchema(t)
+
+	s = s.Scope("bigquery.WriteValue")
+
+	pre := beam.AddFixedKey(s, col)
+	post := beam.GroupByKey(s, pre)
+	beam.ParDo0(s, &writeFn{Project: project, TableValue: table, Type: beam.EncodedType{T: t}}, post)
+}
+
 type writeFn struct {
 	// Project is the project
 	Project string `json:"project"`
 	// Table is the qualified table identifier.
 	Table QualifiedTableName `json:"table"`
+	// TableValue is the table identifier, if the table is a runtime parameter.
+	TableValue valueprovider.Value `json:"tableValue,omitempty"`
 	// Type is the encoded schema type.
 	Type beam.EncodedType `json:"type"`
 }
@@ -245,6 +298,14 @@ func getInsertSize(v interface{}, schema bigquery.Schema) (int, error) {
 }
 
 func (f *writeFn) ProcessElement(ctx context.Context, _ int, iter func(*beam.X) bool) error {
+	if f.TableValue.IsRuntime() {
+		qn, err := resolveTable(f.TableValue)
+		if err != nil {
+			return err
+		}
+		f.Table = qn
+	}
+
 	client, err := bigquery.NewClient(ctx, f.Project)
 	if err != nil {
 		return err
@@ -24,13 +24,16 @@ import (
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/valueprovider"
 	"reflect"
 	"strings"
+	"time"
 )
 
 func init() {
 	beam.RegisterType(reflect.TypeOf((*queryFn)(nil)).Elem())
 	beam.RegisterType(reflect.TypeOf((*writeFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*tryWriteFn)(nil)).Elem())
 }
 
 // writeSizeLimit is the maximum number of rows allowed to a write.
@@ -40,6 +43,13 @@ const writeRowLimit = 1000
 // compatible with the given type, t, and Read returns a PCollection<t>. If the
 // table has more rows than t, then Read is implicitly a projection.
 func Read(s beam.Scope, driver, dsn, table string, t reflect.Type) beam.PCollection {
+	s = s.Scope(driver + ".Read")
+	return query(s, driver, valueprovider.Static(dsn), fmt.Sprintf("SELECT * from %v", table), t)
+}
+
+// ReadValue is a variation of Read where the DSN may be a runtime parameter,
+// resolved when the pipeline is executed.
+func ReadValue(s beam.Scope, driver string, dsn valueprovider.Value, table string, t reflect.Type) beam.PCollection {
 	s = s.Scope(driver + ".Read")
 	return query(s, driver, dsn, fmt.Sprintf("SELECT * from %v", table), t)
 }
@@ -47,11 +57,18 @@ func Read(s beam.Scope, driver, dsn, table string, t reflect.Type) beam.PCollect
 // Query executes a query. The output must have a schema compatible with the given
 // type, t. It returns a PCollection<t>.
 func Query(s beam.Scope, driver, dsn, q string, t reflect.Type) beam.PCollection {
+	s = s.Scope(driver + ".Query")
+	return query(s, driver, valueprovider.Static(dsn), q, t)
+}
+
+// QueryValue is a variation of Query where the DSN may be a runtime
+// parameter, resolved when the pipeline is executed.
+func QueryValue(s beam.Scope, driver string, dsn valueprovider.Value, q string, t reflect.Type) beam.PCollection {
 	s = s.Scope(driver + ".Query")
 	return query(s, driver, dsn, q, t)
 }
 
-func query(s beam.Scope, driver, dsn, query string, t reflect.Type) beam.PCollection {
+func query(s beam.Scope, driver string, dsn valueprovider.Value, query string, t reflect.Type) beam.PCollection {
 	imp := beam.Impulse(s)
 	return beam.ParDo(s, &queryFn{Driver: driver, Dsn: dsn, Query: query, Type: beam.EncodedType{T: t}}, imp, beam.TypeDefinition{Var: beam.XType, T: t})
 }
@@ -60,7 +77,7 @@ type queryFn struct {
 	// Project is the project
 	Driver string `json:"driver"`
 	// Project is the project
-	Dsn string `json:"dsn"`
+	Dsn valueprovider.Value `json:"dsn"`
 	// Table is the table identifier.
 	Query string `json:"query"`
 	// Type is the encoded schema type.
@@ -69,7 +86,11 @@ type queryFn struct {
 
 func (f *queryFn) ProcessElement(ctx context.Context, _ []byte, emit func(beam.X)) error {
 	//TODO move DB Open and Close to Setup and Teardown methods or StartBundle and FinishBundle
-	db, err := sql.Open(f.Driver, f.Dsn)
+	dsn, err := f.Dsn.Get()
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(f.Driver, dsn)
 	if err != nil {
 		return errors.Wrapf(err, "failed to open database: %v", f.Driver)
 	}
@@ -121,40 +142,207 @@ func (f *queryFn) ProcessElement(ctx context.Context, _ []byte, emit func(beam.X
 
 // Write writes the elements of the given PCollection<T> to database, if columns left empty all table columns are used to insert into, otherwise selected
 func Write(s beam.Scope, driver, dsn, table string, columns []string, col beam.PCollection) {
+	WriteWithOptions(s, driver, dsn, table, columns, col)
+}
+
+// WriteWithBatchSize writes the elements of the given PCollection<T> to database with custom batch size. Batch size control number of elements in the batch INSERT statement.
+func WriteWithBatchSize(s beam.Scope, batchSize int, driver, dsn, table string, columns []string, col beam.PCollection) {
+	WriteWithOptions(s, driver, dsn, table, columns, col, WithBatchSize(batchSize))
+}
+
+// WriteMode determines the statement used to write rows.
+type WriteMode int
+
+const (
+	// InsertMode writes rows with multi-row INSERT statements.
+	InsertMode WriteMode = iota
+	// UpsertMode writes rows with multi-row INSERT statements that update
+	// existing rows on key conflicts, using the dialect specific syntax.
+	UpsertMode
+	// StatementMode executes a user supplied statement once per row, with
+	// the row's column values bound in order. Each batch is written in a
+	// single transaction.
+	StatementMode
+)
+
+// WriteOptions represents additional options for writing rows.
+type WriteOptions struct {
+	// BatchSize is the maximum number of rows written by one statement or
+	// transaction.
+	BatchSize int
+	// Mode is the write mode. Defaults to InsertMode.
+	Mode WriteMode
+	// Dialect is the SQL dialect. Defaults to one inferred from the driver.
+	Dialect Dialect
+	// KeyColumns are the conflict target columns in UpsertMode.
+	KeyColumns []string
+	// Statement is the statement executed per row in StatementMode.
+	Statement string
+	// Transactional writes each batch in its own transaction.
+	Transactional bool
+	// MaxRetries is the number of times a batch is retried on transient
+	// errors, such as deadlocks. Dropped connections are only retried for
+	// transactional writes, which cannot have written part of the batch.
+	MaxRetries int
+	// RetryBackoff is the initial delay between retries. It doubles on
+	// every attempt.
+	RetryBackoff time.Duration
+}
+
+// WithBatchSize sets the maximum number of rows written at once.
+func WithBatchSize(batchSize int) func(*WriteOptions) error {
+	return func(wo *WriteOptions) error {
+		if batchSize <= 0 {
+			return errors.Errorf("batch size must be positive, got %v", batchSize)
+		}
+		wo.BatchSize = batchSize
+		return nil
+	}
+}
+
+// WithUpsert writes rows with upserts that update all non-key columns of
+// existing rows whose keyColumns conflict with a written row.
+func WithUpsert(keyColumns ...string) func(*WriteOptions) error {
+	return func(wo *WriteOptions) error {
+		if len(keyColumns) == 0 {
+			return errors.New("upsert requires at least one key column")
+		}
+		wo.Mode = UpsertMode
+		wo.KeyColumns = keyColumns
+		return nil
+	}
+}
+
+// WithStatement executes statement once per row instead of an INSERT. The
+// statement's placeholders are bound to the row's columns in order, for
+// example "UPDATE t SET b = ? WHERE a = ?" with columns []string{"b", "a"}.
+func WithStatement(statement string) func(*WriteOptions) error {
+	return func(wo *WriteOptions) error {
+		if statement == "" {
+			return errors.New("statement must not be empty")
+		}
+		wo.Mode = StatementMode
+		wo.Statement = statement
+		return nil
+	}
+}
+
+// WithDialect overrides the SQL dialect inferred from the driver name.
+func WithDialect(dialect Dialect) func(*WriteOptions) error {
+	return func(wo *WriteOptions) error {
+		wo.Dialect = dialect
+		return nil
+	}
+}
+
+// WithTransactions writes each batch in its own transaction.
+func WithTransactions() func(*WriteOptions) error {
+	return func(wo *WriteOptions) error {
+		wo.Transactional = true
+		return nil
+	}
+}
+
+// WithRetries retries batches that fail with transient errors up to
+// maxRetries times, waiting backoff before the first retry and doubling it
+// on each subsequent one.
+func WithRetries(maxRetries int, backoff time.Duration) func(*WriteOptions) error {
+	return func(wo *WriteOptions) error {
+		if maxRetries < 0 || backoff < 0 {
+			return errors.Errorf("invalid retries %v with backoff %v", maxRetries, backoff)
+		}
+		wo.MaxRetries = maxRetries
+		wo.RetryBackoff = backoff
+		return nil
+	}
+}
+
+func newWriteOptions(driver string, options ...func(*WriteOptions) error) WriteOptions {
+	writeOptions := WriteOptions{BatchSize: writeRowLimit, Dialect: dialectForDriver(driver)}
+	for _, opt := range options {
+		if err := opt(&writeOptions); err != nil {
+			panic(err)
+		}
+	}
+	if writeOptions.Mode == UpsertMode {
+		if _, err := writeOptions.Dialect.upsertClause(nil, writeOptions.KeyColumns); err != nil {
+			panic(err)
+		}
+	}
+	return writeOptions
+}
+
+// WriteWithOptions writes the elements of the given PCollection<T> to
+// database as configured by options. If columns is empty, all table columns
+// are written. Any row that cannot be written fails the bundle.
+func WriteWithOptions(s beam.Scope, driver, dsn, table string, columns []string, col beam.PCollection, options ...func(*WriteOptions) error) {
+	WriteValue(s, driver, valueprovider.Static(dsn), table, columns, col, options...)
+}
+
+// WriteValue is a variation of WriteWithOptions where the DSN may be a runtime
+// parameter, resolved when the pipeline is executed.
+func WriteValue(s beam.Scope, driver string, dsn valueprovider.Value, table string, columns []string, col beam.PCollection, options ...func(*WriteOptions) error) {
 	t := col.Type().Type()
 	s = s.Scope(driver + ".Write")
+	opts := newWriteOptions(driver, options...)
 	pre := beam.AddFixedKey(s, col)
 	post := beam.GroupByKey(s, pre)
-	beam.ParDo0(s, &writeFn{Driver: driver, Dsn: dsn, Table: table, Columns: columns, BatchSize: writeRowLimit, Type: beam.EncodedType{T: t}}, post)
+	beam.ParDo0(s, &writeFn{Driver: driver, Dsn: dsn, Table: table, Columns: columns, Type: beam.EncodedType{T: t}, Options: opts}, post)
 }
 
-// WriteWithBatchSize writes the elements of the given PCollection<T> to database with custom batch size. Batch size control number of elements in the batch INSERT statement.
-func WriteWithBatchSize(s beam.Scope, batchSize int, driver, dsn, table string, columns []string, col beam.PCollection) {
+// TryWrite writes the elements of the given PCollection<T> to database like
+// WriteWithOptions, but does not fail on rows that cannot be written. When a
+// batch fails, its rows are retried one by one and those that still fail are
+// returned as a PCollection<KV<T,string>> of the row and the SQL error.
+func TryWrite(s beam.Scope, driver, dsn, table string, columns []string, col beam.PCollection, options ...func(*WriteOptions) error) beam.PCollection {
+	return TryWriteValue(s, driver, valueprovider.Static(dsn), table, columns, col, options...)
+}
+
+// TryWriteValue is a variation of TryWrite where the DSN may be a runtime
+// parameter, resolved when the pipeline is executed.
+func TryWriteValue(s beam.Scope, driver string, dsn valueprovider.Value, table string, columns []string, col beam.PCollection, options ...func(*WriteOptions) error) beam.PCollection {
 	t := col.Type().Type()
-	s = s.Scope(driver + ".Write")
+	s = s.Scope(driver + ".TryWrite")
+	opts := newWriteOptions(driver, options...)
 	pre := beam.AddFixedKey(s, col)
 	post := beam.GroupByKey(s, pre)
-	beam.ParDo0(s, &writeFn{Driver: driver, Dsn: dsn, Table: table, Columns: columns, BatchSize: batchSize, Type: beam.EncodedType{T: t}}, post)
+	return beam.ParDo(s, &tryWriteFn{writeFn{Driver: driver, Dsn: dsn, Table: table, Columns: columns, Type: beam.EncodedType{T: t}, Options: opts}}, post)
 }
 
 type writeFn struct {
 	// Project is the project
 	Driver string `json:"driver"`
 	// Project is the project
-	Dsn string `json:"dsn"`
+	Dsn valueprovider.Value `json:"dsn"`
 	// Table is the table identifier.
 	Table string `json:"table"`
 	// Columns to inserts, if empty then all columns
 	Columns []string `json:"columns"`
-	//BatchSize size
-	BatchSize int `json:"batchSize"`
 	// Type is the encoded schema type.
 	Type beam.EncodedType `json:"type"`
+	// Options are the write options.
+	Options WriteOptions `json:"options"`
 }
 
 func (f *writeFn) ProcessElement(ctx context.Context, _ int, iter func(*beam.X) bool) error {
+	return f.write(ctx, iter, nil)
+}
+
+type tryWriteFn struct {
+	writeFn
+}
+
+func (f *tryWriteFn) ProcessElement(ctx context.Context, _ int, iter func(*beam.X) bool, emit func(beam.X, string)) error {
+	return f.write(ctx, iter, func(row interface{}, msg string) { emit(row, msg) })
+}
+
+func (f *writeFn) write(ctx context.Context, iter func(*beam.X) bool, emitFailed func(interface{}, string)) error {
 	//TODO move DB Open and Close to Setup and Teardown methods or StartBundle and FinishBundle
-	db, err := sql.Open(f.Driver, f.Dsn)
+	dsn, err := f.Dsn.Get()
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(f.Driver, dsn)
 	if err != nil {
 		return errors.Wrapf(err, "failed to open database: %v", f.Driver)
 	}
@@ -182,10 +370,15 @@ func (f *writeFn) ProcessElement(ctx context.Context, _ int, iter func(*beam.X)
 	if err != nil {
 		return errors.WithContext(err, "creating row mapper")
 	}
-	writer, err := newWriter(f.BatchSize, f.Table, columns)
+	opts := f.Options
+	if opts.Dialect == "" {
+		opts.Dialect = dialectForDriver(f.Driver)
+	}
+	writer, err := newWriter(f.Table, columns, opts)
 	if err != nil {
 		return err
 	}
+	writer.emitFailed = emitFailed
 	var val beam.X
 	for iter(&val) {
 		var row []interface{}
@@ -203,7 +396,7 @@ func (f *writeFn) ProcessElement(ctx context.Context, _ int, iter func(*beam.X)
 		if err != nil {
 			return errors.Wrapf(err, "failed to map row %T", val)
 		}
-		if err = writer.add(row); err != nil {
+		if err = writer.add(val, row); err != nil {
 			return err
 		}
 		if err := writer.writeBatchIfNeeded(ctx, db); err != nil {
@@ -215,6 +408,9 @@ func (f *writeFn) ProcessElement(ctx context.Context, _ int, iter func(*beam.X)
 		return err
 	}
 
-	log.Infof(ctx, "written %v row(s) into %v", writer.totalCount, f.Table)
+	if writer.failedCount > 0 {
+		log.Warnf(ctx, "failed to write %v of %v row(s) into %v", writer.failedCount, writer.totalCount, f.Table)
+	}
+	log.Infof(ctx, "written %v row(s) into %v", writer.totalCount-writer.failedCount, f.Table)
 	return nil
 }
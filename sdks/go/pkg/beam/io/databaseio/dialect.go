@@ -0,0 +1,122 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package databaseio
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+// Dialect identifies the SQL flavour used to build write statements. It
+// controls bind placeholders and the syntax of upserts.
+type Dialect string
+
+const (
+	// DialectGeneric uses "?" placeholders and does not support upserts.
+	DialectGeneric Dialect = "generic"
+	// DialectMySQL uses "?" placeholders and ON DUPLICATE KEY UPDATE upserts.
+	DialectMySQL Dialect = "mysql"
+	// DialectPostgres uses "$n" placeholders and ON CONFLICT upserts.
+	DialectPostgres Dialect = "postgres"
+	// DialectSQLite uses "?" placeholders and ON CONFLICT upserts.
+	DialectSQLite Dialect = "sqlite"
+)
+
+// dialectForDriver infers the dialect from a database/sql driver name.
+func dialectForDriver(driver string) Dialect {
+	switch d := strings.ToLower(driver); {
+	case strings.Contains(d, "mysql"):
+		return DialectMySQL
+	case strings.Contains(d, "postgres"), d == "pgx", d == "cloudsqlpostgres":
+		return DialectPostgres
+	case strings.Contains(d, "sqlite"):
+		return DialectSQLite
+	default:
+		return DialectGeneric
+	}
+}
+
+// placeholder returns the bind placeholder for the 1-based argument position.
+func (d Dialect) placeholder(pos int) string {
+	if d == DialectPostgres {
+		return fmt.Sprintf("$%d", pos)
+	}
+	return "?"
+}
+
+// valuesClause returns the "(?,?),(?,?)" list for rowCount rows of
+// columnCount values each.
+func (d Dialect) valuesClause(rowCount, columnCount int) string {
+	var sb strings.Builder
+	pos := 1
+	for r := 0; r < rowCount; r++ {
+		if r > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("(")
+		for c := 0; c < columnCount; c++ {
+			if c > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString(d.placeholder(pos))
+			pos++
+		}
+		sb.WriteString(")")
+	}
+	return sb.String()
+}
+
+// upsertClause returns the clause appended to a multi-row INSERT to turn it
+// into an upsert on keyColumns, updating all remaining columns.
+func (d Dialect) upsertClause(columns, keyColumns []string) (string, error) {
+	if len(keyColumns) == 0 {
+		return "", errors.New("upsert requires at least one key column")
+	}
+	isKey := make(map[string]bool)
+	for _, k := range keyColumns {
+		isKey[strings.ToLower(k)] = true
+	}
+	var updates []string
+	for _, c := range columns {
+		if isKey[strings.ToLower(c)] {
+			continue
+		}
+		switch d {
+		case DialectMySQL:
+			updates = append(updates, fmt.Sprintf("%v=VALUES(%v)", c, c))
+		default:
+			updates = append(updates, fmt.Sprintf("%v=EXCLUDED.%v", c, c))
+		}
+	}
+	switch d {
+	case DialectMySQL:
+		if len(updates) == 0 {
+			// MySQL has no DO NOTHING; a self-assignment keeps the row as is.
+			updates = append(updates, fmt.Sprintf("%v=%v", keyColumns[0], keyColumns[0]))
+		}
+		return " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ","), nil
+	case DialectPostgres, DialectSQLite:
+		target := strings.Join(keyColumns, ",")
+		if len(updates) == 0 {
+			return fmt.Sprintf(" ON CONFLICT (%v) DO NOTHING", target), nil
+		}
+		return fmt.Sprintf(" ON CONFLICT (%v) DO UPDATE SET %v", target, strings.Join(updates, ",")), nil
+	default:
+		return "", errors.Errorf("upsert is not supported for dialect %q, set one explicitly with WithDialect", d)
+	}
+}
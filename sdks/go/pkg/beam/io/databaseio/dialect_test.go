@@ -0,0 +1,73 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package databaseio
+
+import (
+	"testing"
+)
+
+func TestDialectForDriver(t *testing.T) {
+	tests := map[string]Dialect{
+		"mysql":    DialectMySQL,
+		"postgres": DialectPostgres,
+		"pgx":      DialectPostgres,
+		"sqlite3":  DialectSQLite,
+		"ramsql":   DialectGeneric,
+	}
+	for driver, want := range tests {
+		if got := dialectForDriver(driver); got != want {
+			t.Errorf("dialectForDriver(%q) = %v, want %v", driver, got, want)
+		}
+	}
+}
+
+func TestDialect_valuesClause(t *testing.T) {
+	if got, want := DialectGeneric.valuesClause(2, 3), "(?,?,?),(?,?,?)"; got != want {
+		t.Errorf("generic valuesClause = %v, want %v", got, want)
+	}
+	if got, want := DialectPostgres.valuesClause(2, 2), "($1,$2),($3,$4)"; got != want {
+		t.Errorf("postgres valuesClause = %v, want %v", got, want)
+	}
+}
+
+func TestDialect_upsertClause(t *testing.T) {
+	columns := []string{"id", "name", "total"}
+	tests := []struct {
+		dialect Dialect
+		keys    []string
+		want    string
+	}{
+		{DialectMySQL, []string{"id"}, " ON DUPLICATE KEY UPDATE name=VALUES(name),total=VALUES(total)"},
+		{DialectPostgres, []string{"id"}, " ON CONFLICT (id) DO UPDATE SET name=EXCLUDED.name,total=EXCLUDED.total"},
+		{DialectSQLite, []string{"id", "name", "total"}, " ON CONFLICT (id,name,total) DO NOTHING"},
+		{DialectMySQL, []string{"ID", "name", "total"}, " ON DUPLICATE KEY UPDATE ID=ID"},
+	}
+	for _, test := range tests {
+		got, err := test.dialect.upsertClause(columns, test.keys)
+		if err != nil {
+			t.Fatalf("%v.upsertClause(%v) failed: %v", test.dialect, test.keys, err)
+		}
+		if got != test.want {
+			t.Errorf("%v.upsertClause(%v) = %v, want %v", test.dialect, test.keys, got, test.want)
+		}
+	}
+	if _, err := DialectGeneric.upsertClause(columns, []string{"id"}); err == nil {
+		t.Error("generic upsertClause succeeded, want error")
+	}
+	if _, err := DialectPostgres.upsertClause(columns, nil); err == nil {
+		t.Error("upsertClause without keys succeeded, want error")
+	}
+}
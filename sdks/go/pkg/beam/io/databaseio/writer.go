@@ -18,10 +18,17 @@
 package databaseio
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	goerrors "errors"
 	"fmt"
-	"golang.org/x/net/context"
+	"io"
+	"net"
+	"reflect"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
@@ -32,49 +39,225 @@ type Writer interface {
 	SaveData() (map[string]interface{}, error)
 }
 
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
 type writer struct {
-	batchSize    int
-	table        string
-	sqlTemplate  string
-	valueTempate string
-	binding      []interface{}
-	columnCount  int
-	rowCount     int
-	totalCount   int
+	table       string
+	columns     []string
+	opts        WriteOptions
+	sqlTemplate string
+	upsert      string
+	columnCount int
+	rows        [][]interface{}
+	// elements holds the original element of each buffered row, so that rows
+	// that cannot be written can be emitted as failed rows.
+	elements    []interface{}
+	rowCount    int
+	totalCount  int
+	failedCount int
+	// emitFailed, if set, receives rows that could not be written together
+	// with the error. If nil, write errors fail the bundle.
+	emitFailed func(interface{}, string)
 }
 
-func (w *writer) add(row []interface{}) error {
+func (w *writer) add(element interface{}, row []interface{}) error {
 	w.rowCount++
 	w.totalCount++
 	if len(row) != w.columnCount {
 		return errors.Errorf("expected %v row values, but had: %v", w.columnCount, len(row))
 	}
-	w.binding = append(w.binding, row...)
+	w.rows = append(w.rows, row)
+	w.elements = append(w.elements, element)
 	return nil
 }
 
 func (w *writer) write(ctx context.Context, db *sql.DB) error {
-	values := strings.Repeat(w.valueTempate+",", w.rowCount)
-	if len(values) == 0 {
+	if w.rowCount == 0 {
 		log.Info(ctx, "No value(s) to be written....")
 		return nil
 	}
-	SQL := w.sqlTemplate + string(values[:len(values)-1])
-	resultSet, err := db.ExecContext(ctx, SQL, w.binding...)
+	err := w.withRetries(ctx, func() error { return w.writeRows(ctx, db, w.rows) })
+	if err != nil {
+		if w.emitFailed == nil {
+			return err
+		}
+		// The batch is written atomically, so retry its rows one at a time to
+		// isolate the ones that fail.
+		log.Warnf(ctx, "batch of %v row(s) into %v failed, retrying row by row: %v", w.rowCount, w.table, err)
+		for i := range w.rows {
+			row := w.rows[i : i+1]
+			if err := w.withRetries(ctx, func() error { return w.writeRows(ctx, db, row) }); err != nil {
+				w.failedCount++
+				w.emitFailed(w.elements[i], err.Error())
+			}
+		}
+	}
+	w.rows = nil
+	w.elements = nil
+	w.rowCount = 0
+	return nil
+}
+
+// writeRows writes the given rows with a single statement, or one statement
+// per row in StatementMode. The rows are written in a single transaction if
+// the options ask for it, or if more than one statement is needed.
+func (w *writer) writeRows(ctx context.Context, db *sql.DB, rows [][]interface{}) error {
+	if !w.opts.Transactional && w.opts.Mode != StatementMode {
+		return w.exec(ctx, db, rows)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to begin transaction on %v", w.table)
+	}
+	if err := w.exec(ctx, tx, rows); err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			log.Warnf(ctx, "failed to rollback transaction on %v: %v", w.table, rerr)
+		}
+		return uncommittedError{err}
+	}
+	return tx.Commit()
+}
+
+func (w *writer) exec(ctx context.Context, db execer, rows [][]interface{}) error {
+	if w.opts.Mode == StatementMode {
+		for _, row := range rows {
+			if _, err := db.ExecContext(ctx, w.opts.Statement, row...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	binding := make([]interface{}, 0, len(rows)*w.columnCount)
+	for _, row := range rows {
+		binding = append(binding, row...)
+	}
+	SQL := w.sqlTemplate + w.opts.Dialect.valuesClause(len(rows), w.columnCount) + w.upsert
+	resultSet, err := db.ExecContext(ctx, SQL, binding...)
 	if err != nil {
 		return err
 	}
+	if w.opts.Mode != InsertMode {
+		// Upserts report drivers specific counts for updated rows.
+		return nil
+	}
 	affected, _ := resultSet.RowsAffected()
-	if int(affected) != w.rowCount {
-		return errors.Errorf("expected to write: %v, but written: %v", w.rowCount, affected)
+	if int(affected) != len(rows) {
+		return errors.Errorf("expected to write: %v, but written: %v", len(rows), affected)
 	}
-	w.binding = []interface{}{}
-	w.rowCount = 0
 	return nil
 }
 
+// withRetries calls fn until it succeeds, fails with an error that is not
+// transient, or the configured number of retries is exhausted.
+func (w *writer) withRetries(ctx context.Context, fn func() error) error {
+	backoff := w.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= w.opts.MaxRetries || !isTransient(err) {
+			return err
+		}
+		log.Warnf(ctx, "transient error writing into %v, retrying in %v: %v", w.table, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// uncommittedError is an error of a statement in a transaction that was not
+// committed, so that none of its rows were written.
+type uncommittedError struct {
+	error
+}
+
+func (e uncommittedError) Unwrap() error {
+	return e.error
+}
+
+// isTransient reports whether err is likely to go away on retry, and retrying
+// cannot write rows twice. Lock and serialization failures are identified by
+// the MySQL error numbers or SQLSTATE codes of the drivers. Lost connections
+// are only retried if the rows were written in a transaction that was not
+// committed.
+func isTransient(err error) bool {
+	if goerrors.Is(err, driver.ErrBadConn) {
+		// Drivers only return ErrBadConn if the statement was not sent.
+		return true
+	}
+	if number, ok := mysqlErrorNumber(err); ok {
+		switch number {
+		case 1205, // ER_LOCK_WAIT_TIMEOUT
+			1213: // ER_LOCK_DEADLOCK
+			return true
+		}
+		return false
+	}
+	// Implemented by the lib/pq and pgx errors.
+	var stateErr interface{ SQLState() string }
+	if goerrors.As(err, &stateErr) {
+		switch stateErr.SQLState() {
+		case "40001", // serialization_failure
+			"40P01": // deadlock_detected
+			return true
+		}
+		return false
+	}
+	var uncommitted uncommittedError
+	return goerrors.As(err, &uncommitted) && isConnectionError(err)
+}
+
+// mysqlErrorNumber returns the MySQL server error number of err, if any. The
+// package doesn't depend on a particular MySQL driver, so the number is read
+// from a Number method or, as in the go-sql-driver/mysql MySQLError, from a
+// uint16 Number field of an error in the chain.
+func mysqlErrorNumber(err error) (uint16, bool) {
+	var numErr interface{ Number() uint16 }
+	if goerrors.As(err, &numErr) {
+		return numErr.Number(), true
+	}
+	for ; err != nil; err = goerrors.Unwrap(err) {
+		v := reflect.Indirect(reflect.ValueOf(err))
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		if f := v.FieldByName("Number"); f.IsValid() && f.Kind() == reflect.Uint16 {
+			return uint16(f.Uint()), true
+		}
+	}
+	return 0, false
+}
+
+// errMySQLInvalidConn is the message of the go-sql-driver/mysql error for
+// connections lost after a statement was sent.
+const errMySQLInvalidConn = "invalid connection"
+
+// isConnectionError reports whether err indicates a lost connection.
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return goerrors.Is(err, io.ErrUnexpectedEOF) ||
+		goerrors.Is(err, syscall.ECONNRESET) ||
+		goerrors.Is(err, syscall.EPIPE) ||
+		goerrors.As(err, &netErr) ||
+		hasMessage(err, errMySQLInvalidConn)
+}
+
+// hasMessage reports whether any error in the chain of err has the message.
+func hasMessage(err error, msg string) bool {
+	for ; err != nil; err = goerrors.Unwrap(err) {
+		if err.Error() == msg {
+			return true
+		}
+	}
+	return false
+}
+
 func (w *writer) writeBatchIfNeeded(ctx context.Context, db *sql.DB) error {
-	if w.rowCount >= w.batchSize {
+	if w.rowCount >= w.opts.BatchSize {
 		return w.write(ctx, db)
 	}
 	return nil
@@ -87,17 +270,23 @@ func (w *writer) writeIfNeeded(ctx context.Context, db *sql.DB) error {
 	return nil
 }
 
-func newWriter(batchSize int, table string, columns []string) (*writer, error) {
+func newWriter(table string, columns []string, opts WriteOptions) (*writer, error) {
 	if len(columns) == 0 {
 		return nil, errors.New("columns were empty")
 	}
-	values := strings.Repeat("?,", len(columns))
-	return &writer{
-		batchSize:    batchSize,
-		columnCount:  len(columns),
-		table:        table,
-		binding:      make([]interface{}, 0),
-		sqlTemplate:  fmt.Sprintf("INSERT INTO %v(%v) VALUES", table, strings.Join(columns, ",")),
-		valueTempate: fmt.Sprintf("(%s)", values[:len(values)-1]),
-	}, nil
+	w := &writer{
+		columnCount: len(columns),
+		table:       table,
+		columns:     columns,
+		opts:        opts,
+		sqlTemplate: fmt.Sprintf("INSERT INTO %v(%v) VALUES", table, strings.Join(columns, ",")),
+	}
+	if opts.Mode == UpsertMode {
+		upsert, err := opts.Dialect.upsertClause(columns, opts.KeyColumns)
+		if err != nil {
+			return nil, err
+		}
+		w.upsert = upsert
+	}
+	return w, nil
 }
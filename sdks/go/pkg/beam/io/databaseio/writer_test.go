@@ -0,0 +1,215 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package databaseio
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/valueprovider"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+func init() {
+	sql.Register("fakemysql", fakeDB)
+}
+
+// fakeDB is a database/sql driver that records executed statements. Any
+// statement binding the string "bad" fails, and the first failTransient
+// statements fail with a deadlock error.
+var fakeDB = &fakeDriver{}
+
+type fakeDriver struct {
+	mu            sync.Mutex
+	statements    []string
+	committed     int
+	rolledBack    int
+	failTransient int
+}
+
+func (d *fakeDriver) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements, d.committed, d.rolledBack, d.failTransient = nil, 0, 0, 0
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{c.d, query}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return &fakeTx{c.d}, nil }
+
+type fakeTx struct{ d *fakeDriver }
+
+func (t *fakeTx) Commit() error {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+	t.d.committed++
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+	t.d.rolledBack++
+	return nil
+}
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	if s.d.failTransient > 0 {
+		s.d.failTransient--
+		return nil, &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+	}
+	for _, arg := range args {
+		if arg == "bad" {
+			return nil, &mysql.MySQLError{Number: 1366, Message: "Incorrect value"}
+		}
+	}
+	s.d.statements = append(s.d.statements, s.query)
+	return driver.RowsAffected(len(args) / 2), nil
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	projection := strings.TrimPrefix(s.query, "SELECT ")
+	projection = projection[:strings.Index(projection, " FROM")]
+	return &fakeRows{columns: strings.Split(projection, ",")}, nil
+}
+
+type fakeRows struct{ columns []string }
+
+func (r *fakeRows) Columns() []string         { return r.columns }
+func (r *fakeRows) Close() error              { return nil }
+func (r *fakeRows) Next([]driver.Value) error { return io.EOF }
+
+func TestTryWrite(t *testing.T) {
+	fakeDB.reset()
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, Address{"orchard lane", 1}, Address{"bad", 2}, Address{"morris st", 200})
+	failed := TryWrite(s, "fakemysql", "", "address", []string{"street", "street_number"}, col, WithUpsert("street"))
+	passert.Count(s, failed, "NumFailed", 1)
+	ptest.RunAndValidate(t, p)
+
+	// The failed batch is followed by one attempt per row.
+	if got, want := len(fakeDB.statements), 2; got != want {
+		t.Fatalf("got %v successful statements, want %v: %v", got, want, fakeDB.statements)
+	}
+	if got, want := fakeDB.statements[0], "INSERT INTO address(street,street_number) VALUES(?,?) ON DUPLICATE KEY UPDATE street_number=VALUES(street_number)"; got != want {
+		t.Errorf("got statement %v, want %v", got, want)
+	}
+}
+
+func TestTryWriteValue(t *testing.T) {
+	fakeDB.reset()
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, Address{"orchard lane", 1}, Address{"bad", 2})
+	failed := TryWriteValue(s, "fakemysql", valueprovider.Static(""), "address", []string{"street", "street_number"}, col, WithBatchSize(1))
+	passert.Count(s, failed, "NumFailed", 1)
+	ptest.RunAndValidate(t, p)
+
+	if got, want := len(fakeDB.statements), 1; got != want {
+		t.Errorf("got %v successful statements, want %v: %v", got, want, fakeDB.statements)
+	}
+}
+
+func TestWriteWithOptions_transactionRetries(t *testing.T) {
+	fakeDB.reset()
+	fakeDB.failTransient = 2
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, Address{"orchard lane", 1}, Address{"morris st", 200})
+	WriteWithOptions(s, "fakemysql", "", "address", []string{"street", "street_number"}, col,
+		WithTransactions(), WithRetries(2, 0))
+	ptest.RunAndValidate(t, p)
+
+	if got, want := len(fakeDB.statements), 1; got != want {
+		t.Fatalf("got %v successful statements, want %v: %v", got, want, fakeDB.statements)
+	}
+	if fakeDB.rolledBack != 2 || fakeDB.committed != 1 {
+		t.Errorf("got %v rollbacks and %v commits, want 2 and 1", fakeDB.rolledBack, fakeDB.committed)
+	}
+}
+
+func TestWriteWithOptions_fails(t *testing.T) {
+	fakeDB.reset()
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, Address{"bad", 1})
+	WriteWithOptions(s, "fakemysql", "", "address", []string{"street", "street_number"}, col)
+	if err := ptest.Run(p); err == nil {
+		t.Error("pipeline succeeded, want write error")
+	}
+}
+
+// numberedError is an error of a MySQL driver that reports the error number
+// with a method.
+type numberedError uint16
+
+func (e numberedError) Error() string  { return fmt.Sprintf("Error %d", uint16(e)) }
+func (e numberedError) Number() uint16 { return uint16(e) }
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{driver.ErrBadConn, true},
+		{errors.Wrap(driver.ErrBadConn, "write failed"), true},
+		{&pq.Error{Code: "40001", Message: "could not serialize access due to concurrent update"}, true},
+		{&pq.Error{Code: "40P01", Message: "deadlock detected"}, true},
+		{&pq.Error{Code: "23505", Message: "duplicate key value 40001"}, false},
+		{&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, true},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '12050' for key 'PRIMARY'"}, false},
+		{errors.Wrap(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, "write failed"), true},
+		{numberedError(1213), true},
+		{numberedError(1062), false},
+		{errors.New("Error 1062: Duplicate entry '1213'"), false},
+		{context.Canceled, false},
+		// Lost connections are only retried if the transaction was not committed.
+		{io.ErrUnexpectedEOF, false},
+		{&net.OpError{Op: "write", Err: syscall.EPIPE}, false},
+		{uncommittedError{mysql.ErrInvalidConn}, true},
+		{uncommittedError{&net.OpError{Op: "read", Err: syscall.ECONNRESET}}, true},
+		{uncommittedError{&mysql.MySQLError{Number: 1366, Message: "Incorrect value"}}, false},
+	}
+	for _, test := range tests {
+		if got := isTransient(test.err); got != test.want {
+			t.Errorf("isTransient(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package elasticsearchio contains transforms for reading from and writing
+// to Elasticsearch indices through the REST API.
+//
+// Documents are represented as JSON encoded strings.
+//
+// Experimental.
+package elasticsearchio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+// Config describes how to connect to an Elasticsearch cluster.
+type Config struct {
+	// Addresses are the base URLs of the cluster nodes, such as
+	// "http://localhost:9200". Requests are spread over them at random.
+	Addresses []string `json:"addresses"`
+	// Username and Password are used for basic authentication, if set.
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// client issues requests to the nodes of a cluster.
+type client struct {
+	cfg  Config
+	http *http.Client
+}
+
+func newClient(cfg Config) *client {
+	return &client{cfg: cfg, http: &http.Client{}}
+}
+
+// statusError is returned for responses with an unexpected status code.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("elasticsearch returned status %v: %v", e.code, e.body)
+}
+
+// do sends the request with the given body to a random node and decodes the
+// JSON response into out, if not nil.
+func (c *client) do(ctx context.Context, method, path, contentType string, body []byte, out interface{}) error {
+	if len(c.cfg.Addresses) == 0 {
+		return errors.New("elasticsearchio: no addresses configured")
+	}
+	addr := strings.TrimSuffix(c.cfg.Addresses[rand.Intn(len(c.cfg.Addresses))], "/")
+	req, err := http.NewRequestWithContext(ctx, method, addr+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.cfg.Username != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return &statusError{code: resp.StatusCode, body: string(data)}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return errors.Wrapf(err, "elasticsearchio failed to decode response of %v %v", method, path)
+	}
+	return nil
+}
+
+// doJSON is do with a JSON encoded body.
+func (c *client) doJSON(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	return c.do(ctx, method, path, "application/json", data, out)
+}
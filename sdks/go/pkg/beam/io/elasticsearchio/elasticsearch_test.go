@@ -0,0 +1,191 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearchio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/direct"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+)
+
+// fakeCluster is a minimal Elasticsearch stand-in supporting sliced scrolls
+// and bulk indexing. Documents are assigned to slices by position, and the
+// first rejectFirst indexing operations are rejected with status 429.
+type fakeCluster struct {
+	mu          sync.Mutex
+	docs        []string
+	indexed     map[string]string
+	scrolls     map[string][]string
+	rejectFirst int
+	cleared     int
+}
+
+func newFakeCluster(t *testing.T, docs ...string) (*fakeCluster, Config) {
+	c := &fakeCluster{docs: docs, indexed: make(map[string]string), scrolls: make(map[string][]string)}
+	srv := httptest.NewServer(c)
+	t.Cleanup(srv.Close)
+	return c, Config{Addresses: []string{srv.URL}}
+}
+
+func (c *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.URL.Path == "/_bulk":
+		c.bulk(w, body)
+	case r.URL.Path == "/_search/scroll" && r.Method == "DELETE":
+		c.cleared++
+		fmt.Fprint(w, `{"succeeded": true}`)
+	case r.URL.Path == "/_search/scroll":
+		var req struct {
+			ScrollID string `json:"scroll_id"`
+		}
+		json.Unmarshal(body, &req)
+		c.page(w, req.ScrollID)
+	case strings.HasSuffix(r.URL.Path, "/_search"):
+		var req struct {
+			Size  int `json:"size"`
+			Slice struct {
+				ID  int `json:"id"`
+				Max int `json:"max"`
+			} `json:"slice"`
+		}
+		json.Unmarshal(body, &req)
+		if req.Slice.Max == 0 {
+			req.Slice.Max = 1
+		}
+		var docs []string
+		for i, doc := range c.docs {
+			if i%req.Slice.Max == req.Slice.ID {
+				docs = append(docs, doc)
+			}
+		}
+		id := fmt.Sprintf("scroll-%v", req.Slice.ID)
+		c.scrolls[id] = docs
+		c.scrolls[id+"/size"] = make([]string, req.Size)
+		c.page(w, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// page writes the next page of the scroll.
+func (c *fakeCluster) page(w http.ResponseWriter, id string) {
+	docs := c.scrolls[id]
+	n := len(c.scrolls[id+"/size"])
+	if n > len(docs) {
+		n = len(docs)
+	}
+	var hits []string
+	for _, doc := range docs[:n] {
+		hits = append(hits, fmt.Sprintf(`{"_id": "x", "_source": %v}`, doc))
+	}
+	c.scrolls[id] = docs[n:]
+	fmt.Fprintf(w, `{"_scroll_id": %q, "hits": {"hits": [%v]}}`, id, strings.Join(hits, ","))
+}
+
+func (c *fakeCluster) bulk(w http.ResponseWriter, body []byte) {
+	var items []string
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		var action struct {
+			Index struct {
+				ID string `json:"_id"`
+			} `json:"index"`
+		}
+		json.Unmarshal(sc.Bytes(), &action)
+		sc.Scan()
+		doc := sc.Text()
+		switch {
+		case c.rejectFirst > 0:
+			c.rejectFirst--
+			items = append(items, `{"index": {"status": 429, "error": {"type": "es_rejected_execution_exception"}}}`)
+		case strings.Contains(doc, "bad"):
+			items = append(items, `{"index": {"status": 400, "error": {"type": "mapper_parsing_exception"}}}`)
+		default:
+			c.indexed[action.Index.ID] = doc
+			items = append(items, `{"index": {"status": 201}}`)
+		}
+	}
+	fmt.Fprintf(w, `{"errors": true, "items": [%v]}`, strings.Join(items, ","))
+}
+
+func TestRead(t *testing.T) {
+	var docs []string
+	for i := 0; i < 10; i++ {
+		docs = append(docs, fmt.Sprintf(`{"n":%v}`, i))
+	}
+	c, cfg := newFakeCluster(t, docs...)
+
+	p, s := beam.NewPipelineWithRoot()
+	read := Read(s, cfg, "idx", &ReadOptions{Slices: 3, BatchSize: 2, Query: `{"match_all": {}}`})
+	var want []interface{}
+	for _, doc := range docs {
+		want = append(want, doc)
+	}
+	passert.Equals(s, read, want...)
+	ptest.RunAndValidate(t, p)
+
+	if c.cleared != 3 {
+		t.Errorf("cleared %v scrolls, want 3", c.cleared)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	c, cfg := newFakeCluster(t)
+	c.rejectFirst = 2
+
+	p, s := beam.NewPipelineWithRoot()
+	docs := beam.Create(s, `{"id": "a", "v": 1}`, `{"id": "b", "v": "bad"}`, `{"id": "c", "v": 3}`, `{"v": 4}`, `{"id": "e",`)
+	failed := Write(s, cfg, "idx", docs, &WriteOptions{IDField: "id", RetryBackoff: time.Millisecond})
+	passert.Equals(s, beam.DropValue(s, failed), `{"id": "b", "v": "bad"}`, `{"v": 4}`, `{"id": "e",`)
+	ptest.RunAndValidate(t, p)
+
+	if len(c.indexed) != 2 || c.indexed["a"] == "" || c.indexed["c"] == "" {
+		t.Errorf("indexed %v, want documents a and c", c.indexed)
+	}
+}
+
+func TestWriteFn_bulkEntry(t *testing.T) {
+	fn := &writeFn{Index: "idx", IDField: "id"}
+	got, err := fn.bulkEntry(`{"id": 7, "v": "x"}`)
+	if err != nil {
+		t.Fatalf("bulkEntry failed: %v", err)
+	}
+	want := "{\"index\":{\"_id\":\"7\",\"_index\":\"idx\"}}\n{\"id\":7,\"v\":\"x\"}\n"
+	if string(got) != want {
+		t.Errorf("bulkEntry = %q, want %q", got, want)
+	}
+	for _, doc := range []string{`{"v": "x"}`, `{"id": 1`, `[1]`} {
+		if _, err := fn.bulkEntry(doc); err == nil {
+			t.Errorf("bulkEntry(%v) succeeded, want error", doc)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearchio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*readFn)(nil)).Elem())
+}
+
+const (
+	// defaultSlices is the default number of scroll slices of a read.
+	defaultSlices = 4
+	// defaultBatchSize is the default number of documents per request.
+	defaultBatchSize = 1000
+	// defaultKeepAlive is the default time a scroll context is kept alive
+	// between requests.
+	defaultKeepAlive = 5 * time.Minute
+)
+
+// ReadOptions represents options for reading from Elasticsearch.
+type ReadOptions struct {
+	// Query is a JSON encoded query DSL object, such as
+	// `{"term": {"user": "kimchy"}}`. Defaults to matching all documents.
+	Query string
+	// Slices is the number of sliced scrolls the read is split into. Slices
+	// are read in parallel. Defaults to 4.
+	Slices int
+	// BatchSize is the number of documents fetched per scroll request.
+	// Defaults to 1000.
+	BatchSize int
+	// KeepAlive is how long the scroll context is kept between requests.
+	// Defaults to 5 minutes.
+	KeepAlive time.Duration
+}
+
+// Read reads the documents of an index, or a comma separated list or pattern
+// of indices, and returns their JSON encoded sources as a PCollection<string>.
+// The read is split into sliced scrolls, which can be processed in parallel.
+func Read(s beam.Scope, cfg Config, index string, opts *ReadOptions) beam.PCollection {
+	s = s.Scope("elasticsearchio.Read")
+
+	fn := &readFn{Config: cfg, Index: index, Slices: defaultSlices, BatchSize: defaultBatchSize, KeepAlive: defaultKeepAlive}
+	if opts != nil {
+		fn.Query = opts.Query
+		if opts.Slices > 0 {
+			fn.Slices = opts.Slices
+		}
+		if opts.BatchSize > 0 {
+			fn.BatchSize = opts.BatchSize
+		}
+		if opts.KeepAlive > 0 {
+			fn.KeepAlive = opts.KeepAlive
+		}
+	}
+	if fn.Query != "" && !json.Valid([]byte(fn.Query)) {
+		panic(errors.Errorf("elasticsearchio: query is not valid JSON: %v", fn.Query))
+	}
+	imp := beam.Impulse(s)
+	return beam.ParDo(s, fn, imp)
+}
+
+// readFn reads documents with sliced scrolls. It is implemented as an SDF
+// whose positions are slice ids, so that slices can be read in parallel.
+type readFn struct {
+	Config    Config        `json:"config"`
+	Index     string        `json:"index"`
+	Query     string        `json:"query"`
+	Slices    int           `json:"slices"`
+	BatchSize int           `json:"batchSize"`
+	KeepAlive time.Duration `json:"keepAlive"`
+
+	client *client
+}
+
+// CreateInitialRestriction covers all slice ids.
+func (fn *readFn) CreateInitialRestriction(_ []byte) offsetrange.Restriction {
+	return offsetrange.Restriction{Start: 0, End: int64(fn.Slices)}
+}
+
+// SplitRestriction splits the restriction into one per slice.
+func (fn *readFn) SplitRestriction(_ []byte, rest offsetrange.Restriction) []offsetrange.Restriction {
+	return rest.SizedSplits(1)
+}
+
+// RestrictionSize returns the number of slices in the restriction.
+func (fn *readFn) RestrictionSize(_ []byte, rest offsetrange.Restriction) float64 {
+	return rest.Size()
+}
+
+// CreateTracker creates sdf.LockRTrackers wrapping offsetRange.Trackers for
+// each restriction.
+func (fn *readFn) CreateTracker(rest offsetrange.Restriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(offsetrange.NewTracker(rest))
+}
+
+func (fn *readFn) Setup() {
+	fn.client = newClient(fn.Config)
+}
+
+// ProcessElement claims the slices of the restriction one by one, and emits
+// all documents of each claimed slice.
+func (fn *readFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, _ []byte, emit func(string)) error {
+	for slice := rt.GetRestriction().(offsetrange.Restriction).Start; rt.TryClaim(slice); slice++ {
+		count, err := fn.readSlice(ctx, slice, emit)
+		if err != nil {
+			return err
+		}
+		log.Debugf(ctx, "elasticsearchio read %v documents from slice %v of %v", count, slice, fn.Index)
+	}
+	return nil
+}
+
+type searchResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Hits []struct {
+			ID     string          `json:"_id"`
+			Source json.RawMessage `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// searchRequest returns the body of the initial search request of a slice.
+func (fn *readFn) searchRequest(slice int64) map[string]interface{} {
+	body := map[string]interface{}{
+		"size": fn.BatchSize,
+		// Sorting by _doc is the most efficient order for scrolls.
+		"sort": []string{"_doc"},
+	}
+	if fn.Query != "" {
+		body["query"] = json.RawMessage(fn.Query)
+	}
+	// Elasticsearch rejects slicing into a single slice.
+	if fn.Slices > 1 {
+		body["slice"] = map[string]interface{}{"id": slice, "max": fn.Slices}
+	}
+	return body
+}
+
+func (fn *readFn) readSlice(ctx context.Context, slice int64, emit func(string)) (int, error) {
+	keepAlive := fmt.Sprintf("%ds", int64(fn.KeepAlive/time.Second))
+	var resp searchResponse
+	path := fmt.Sprintf("/%v/_search?scroll=%v", url.PathEscape(fn.Index), keepAlive)
+	if err := fn.client.doJSON(ctx, "POST", path, fn.searchRequest(slice), &resp); err != nil {
+		return 0, errors.Wrapf(err, "elasticsearchio failed to search %v", fn.Index)
+	}
+	defer func() {
+		if resp.ScrollID == "" {
+			return
+		}
+		body := map[string]interface{}{"scroll_id": []string{resp.ScrollID}}
+		if err := fn.client.doJSON(ctx, "DELETE", "/_search/scroll", body, nil); err != nil {
+			log.Warnf(ctx, "elasticsearchio failed to clear scroll: %v", err)
+		}
+	}()
+
+	var count int
+	for len(resp.Hits.Hits) > 0 {
+		for _, hit := range resp.Hits.Hits {
+			emit(string(hit.Source))
+			count++
+		}
+		scrollID := resp.ScrollID
+		resp = searchResponse{}
+		body := map[string]interface{}{"scroll": keepAlive, "scroll_id": scrollID}
+		if err := fn.client.doJSON(ctx, "POST", "/_search/scroll", body, &resp); err != nil {
+			resp.ScrollID = scrollID
+			return count, errors.Wrapf(err, "elasticsearchio failed to scroll %v", fn.Index)
+		}
+	}
+	return count, nil
+}
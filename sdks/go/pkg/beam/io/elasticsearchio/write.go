@@ -0,0 +1,237 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearchio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*writeFn)(nil)).Elem())
+}
+
+var (
+	indexedDocs  = beam.NewCounter("elasticsearchio", "indexed_documents")
+	rejectedDocs = beam.NewCounter("elasticsearchio", "rejected_documents")
+	failedDocs   = beam.NewCounter("elasticsearchio", "failed_documents")
+)
+
+const (
+	// defaultWriteBatchSize is the default number of documents per bulk request.
+	defaultWriteBatchSize = 500
+	// defaultMaxRetries is the default number of retries of rejected documents.
+	defaultMaxRetries = 3
+	// defaultRetryBackoff is the default initial delay between retries.
+	defaultRetryBackoff = time.Second
+)
+
+// WriteOptions represents options for writing to Elasticsearch.
+type WriteOptions struct {
+	// BatchSize is the number of documents per bulk request. Defaults to 500.
+	BatchSize int
+	// IDField is the top level field of documents used as their _id. If
+	// empty, Elasticsearch generates ids and retried writes may create
+	// duplicates.
+	IDField string
+	// MaxRetries is the number of times documents rejected because the
+	// cluster is overloaded, or failed bulk requests, are retried. Defaults
+	// to 3.
+	MaxRetries int
+	// RetryBackoff is the initial delay between retries. It doubles on every
+	// attempt. Defaults to 1 second.
+	RetryBackoff time.Duration
+}
+
+// Write indexes a PCollection<string> of JSON encoded documents into the
+// index with bulk requests. Documents rejected because the cluster is
+// overloaded are retried with exponential backoff. It returns a
+// PCollection<KV<string,string>> of the documents that could not be indexed
+// and their errors.
+func Write(s beam.Scope, cfg Config, index string, col beam.PCollection, opts *WriteOptions) beam.PCollection {
+	s = s.Scope("elasticsearchio.Write")
+
+	fn := &writeFn{Config: cfg, Index: index, BatchSize: defaultWriteBatchSize, MaxRetries: defaultMaxRetries, RetryBackoff: defaultRetryBackoff}
+	if opts != nil {
+		if opts.BatchSize > 0 {
+			fn.BatchSize = opts.BatchSize
+		}
+		fn.IDField = opts.IDField
+		if opts.MaxRetries > 0 {
+			fn.MaxRetries = opts.MaxRetries
+		}
+		if opts.RetryBackoff > 0 {
+			fn.RetryBackoff = opts.RetryBackoff
+		}
+	}
+	return beam.ParDo(s, fn, col)
+}
+
+type writeFn struct {
+	Config       Config        `json:"config"`
+	Index        string        `json:"index"`
+	BatchSize    int           `json:"batchSize"`
+	IDField      string        `json:"idField"`
+	MaxRetries   int           `json:"maxRetries"`
+	RetryBackoff time.Duration `json:"retryBackoff"`
+
+	client *client
+	docs   []bulkDoc
+}
+
+// bulkDoc is a document buffered for indexing, with its bulk request entry.
+type bulkDoc struct {
+	doc   string
+	entry []byte
+}
+
+func (fn *writeFn) Setup() {
+	fn.client = newClient(fn.Config)
+}
+
+func (fn *writeFn) ProcessElement(ctx context.Context, doc string, emit func(string, string)) error {
+	entry, err := fn.bulkEntry(doc)
+	if err != nil {
+		// Invalid documents fail on their own, not with their batch.
+		failedDocs.Inc(ctx, 1)
+		emit(doc, err.Error())
+		return nil
+	}
+	fn.docs = append(fn.docs, bulkDoc{doc: doc, entry: entry})
+	if len(fn.docs) >= fn.BatchSize {
+		return fn.flush(ctx, emit)
+	}
+	return nil
+}
+
+func (fn *writeFn) FinishBundle(ctx context.Context, emit func(string, string)) error {
+	return fn.flush(ctx, emit)
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// flush indexes the buffered documents, retrying rejected ones, and emits
+// those that fail.
+func (fn *writeFn) flush(ctx context.Context, emit func(string, string)) error {
+	docs := fn.docs
+	fn.docs = nil
+	backoff := fn.RetryBackoff
+	for attempt := 0; len(docs) > 0; attempt++ {
+		if attempt > 0 {
+			log.Warnf(ctx, "elasticsearchio retrying %v document(s) in %v", len(docs), backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			backoff *= 2
+		}
+		lastAttempt := attempt >= fn.MaxRetries
+
+		var body []byte
+		for _, d := range docs {
+			body = append(body, d.entry...)
+		}
+		var resp bulkResponse
+		if err := fn.client.do(ctx, "POST", "/_bulk", "application/x-ndjson", body, &resp); err != nil {
+			if !lastAttempt && isRetryable(err) {
+				continue
+			}
+			return errors.Wrapf(err, "elasticsearchio failed to index %v document(s) into %v", len(docs), fn.Index)
+		}
+		if len(resp.Items) != len(docs) {
+			return errors.Errorf("elasticsearchio bulk response has %v items for %v documents", len(resp.Items), len(docs))
+		}
+		var rejected []bulkDoc
+		for i, item := range resp.Items {
+			result := item["index"]
+			switch {
+			case result.Status < 300:
+				indexedDocs.Inc(ctx, 1)
+			case result.Status == http.StatusTooManyRequests && !lastAttempt:
+				rejectedDocs.Inc(ctx, 1)
+				rejected = append(rejected, docs[i])
+			default:
+				failedDocs.Inc(ctx, 1)
+				emit(docs[i].doc, string(result.Error))
+			}
+		}
+		docs = rejected
+	}
+	return nil
+}
+
+// isRetryable reports whether a failed bulk request may succeed if retried.
+func isRetryable(err error) bool {
+	if serr, ok := err.(*statusError); ok {
+		return serr.code == http.StatusTooManyRequests || serr.code >= 500
+	}
+	// Transport errors, such as a dropped connection.
+	return true
+}
+
+// bulkEntry returns the NDJSON lines of a bulk request indexing doc, or an
+// error if doc is not a valid JSON object or has no id field.
+func (fn *writeFn) bulkEntry(doc string) ([]byte, error) {
+	var buf bytes.Buffer
+	action := map[string]string{"_index": fn.Index}
+	if fn.IDField != "" {
+		id, err := documentID(doc, fn.IDField)
+		if err != nil {
+			return nil, err
+		}
+		action["_id"] = id
+	}
+	if err := json.NewEncoder(&buf).Encode(map[string]interface{}{"index": action}); err != nil {
+		return nil, err
+	}
+	if err := json.Compact(&buf, []byte(doc)); err != nil {
+		return nil, errors.Wrapf(err, "elasticsearchio document is not valid JSON: %v", doc)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// documentID returns the value of the top level field of doc as a string.
+func documentID(doc, field string) (string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(doc), &fields); err != nil {
+		return "", errors.Wrapf(err, "elasticsearchio document is not a valid JSON object: %v", doc)
+	}
+	id, ok := fields[field]
+	if !ok {
+		return "", errors.Errorf("elasticsearchio document has no id field %q: %v", field, doc)
+	}
+	if s, ok := id.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(id)
+	return string(b), err
+}
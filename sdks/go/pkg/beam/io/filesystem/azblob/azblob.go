@@ -0,0 +1,443 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package azblob contains an Azure Blob Storage implementation of the Beam
+// file system. Paths have the form azblob://<container>/<blob>.
+//
+// The storage account is configured with the --azblob_connection_string
+// flag, or the --azblob_account and --azblob_key flags, falling back to the
+// AZURE_STORAGE_CONNECTION_STRING, AZURE_STORAGE_ACCOUNT and
+// AZURE_STORAGE_KEY environment variables. The --azblob_endpoint flag
+// overrides the blob service URL, for example to use the Azurite emulator.
+//
+// The connection string and key flags are secrets, and are not exported to
+// workers with the pipeline options. Workers resolve them from the
+// environment variables only.
+package azblob
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+)
+
+var (
+	connectionString = flag.String("azblob_connection_string", "", "Connection string of the Azure storage account.")
+	account          = flag.String("azblob_account", "", "Name of the Azure storage account.")
+	accountKey       = flag.String("azblob_key", "", "Shared key of the Azure storage account.")
+	endpoint         = flag.String("azblob_endpoint", "", "Blob service URL. Defaults to https://<account>.blob.core.windows.net/.")
+)
+
+func init() {
+	filesystem.Register("azblob", New)
+	runtime.RegisterLocalFlags("azblob_connection_string", "azblob_key")
+}
+
+// option returns the value of the flag, if set, or else of the pipeline
+// option with the same name, or else of the environment variable.
+func option(name, value, env string) string {
+	if value != "" {
+		return value
+	}
+	if v := runtime.GlobalOptions.Get(name); v != "" {
+		return v
+	}
+	if env != "" {
+		return os.Getenv(env)
+	}
+	return ""
+}
+
+// secret returns the value of the flag, if set, or else of the environment
+// variable. Secrets are never read from the pipeline options.
+func secret(value, env string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(env)
+}
+
+type fs struct {
+	client *azblob.ServiceClient
+}
+
+// New creates a new Azure Blob Storage filesystem for the configured
+// storage account.
+func New(ctx context.Context) filesystem.Interface {
+	client, err := newServiceClient(
+		secret(*connectionString, "AZURE_STORAGE_CONNECTION_STRING"),
+		option("azblob_account", *account, "AZURE_STORAGE_ACCOUNT"),
+		secret(*accountKey, "AZURE_STORAGE_KEY"),
+		option("azblob_endpoint", *endpoint, ""))
+	if err != nil {
+		panic(errors.Wrap(err, "failed to create Azure blob client"))
+	}
+	return &fs{client: client}
+}
+
+func newServiceClient(connectionString, account, key, endpoint string) (*azblob.ServiceClient, error) {
+	if connectionString != "" {
+		return azblob.NewServiceClientFromConnectionString(connectionString, nil)
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net/", account)
+	}
+	if key == "" {
+		// Only public containers are accessible.
+		return azblob.NewServiceClientWithNoCredential(endpoint, nil)
+	}
+	cred, err := azblob.NewSharedKeyCredential(account, key)
+	if err != nil {
+		return nil, err
+	}
+	return azblob.NewServiceClientWithSharedKey(endpoint, cred, nil)
+}
+
+// parseObject splits an azblob://container/blob path into its container and
+// blob name.
+func parseObject(p string) (container, blob string, err error) {
+	u, err := url.Parse(p)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "azblob" {
+		return "", "", errors.Errorf("path %q is not an azblob:// path", p)
+	}
+	if u.Host == "" {
+		return "", "", errors.Errorf("path %q has no container", p)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+func (f *fs) blobClient(filename string) (*azblob.BlockBlobClient, error) {
+	container, blob, err := parseObject(filename)
+	if err != nil {
+		return nil, err
+	}
+	c, err := f.client.NewContainerClient(container)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewBlockBlobClient(blob)
+}
+
+func (f *fs) Close() error {
+	return nil
+}
+
+// List expands the glob to the matching blobs. Glob patterns follow
+// path.Match and are matched against the blob names.
+func (f *fs) List(ctx context.Context, glob string) ([]string, error) {
+	container, blob, err := parseObject(glob)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	if index := strings.IndexAny(blob, "*?["); index >= 0 {
+		// We handle globs by listing all blobs with the literal prefix of
+		// the pattern and matching them here.
+		c, err := f.client.NewContainerClient(container)
+		if err != nil {
+			return nil, err
+		}
+		pager := c.ListBlobsFlat(&azblob.ContainerListBlobsFlatOptions{Prefix: to.Ptr(blob[:index])})
+		for pager.NextPage(ctx) {
+			for _, item := range pager.PageResponse().Segment.BlobItems {
+				name := deref(item.Name)
+				match, err := path.Match(blob, name)
+				if err != nil {
+					return nil, err
+				}
+				if match {
+					candidates = append(candidates, name)
+				}
+			}
+		}
+		if err := pager.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		// Single blob.
+		candidates = []string{blob}
+	}
+
+	var ret []string
+	for _, name := range candidates {
+		ret = append(ret, fmt.Sprintf("azblob://%v/%v", container, name))
+	}
+	return ret, nil
+}
+
+// OpenRead opens the blob for reading. The returned reader also implements
+// io.Seeker, and seeking issues ranged reads from the new offset.
+func (f *fs) OpenRead(ctx context.Context, filename string) (io.ReadCloser, error) {
+	client, err := f.blobClient(filename)
+	if err != nil {
+		return nil, err
+	}
+	r := &blobReader{ctx: ctx, client: client, size: -1}
+	// Open eagerly to report missing blobs on open, as other filesystems do.
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// OpenWrite opens the blob for writing. The data is streamed as staged
+// blocks, which are committed when the writer is closed.
+func (f *fs) OpenWrite(ctx context.Context, filename string) (io.WriteCloser, error) {
+	client, err := f.blobClient(filename)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	w := &blobWriter{pw: pw, done: make(chan error, 1)}
+	go func() {
+		_, err := client.UploadStream(ctx, pr, azblob.UploadStreamOptions{})
+		// Unblock any pending writes if the upload failed.
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+func (f *fs) Size(ctx context.Context, filename string) (int64, error) {
+	client, err := f.blobClient(filename)
+	if err != nil {
+		return -1, err
+	}
+	props, err := client.GetProperties(ctx, nil)
+	if err != nil {
+		return -1, err
+	}
+	return deref(props.ContentLength), nil
+}
+
+// OpenReadRange opens the blob for reading length bytes starting at offset,
+// using a ranged download.
+func (f *fs) OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
+	client, err := f.blobClient(filename)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+	opts := &azblob.BlobDownloadOptions{Offset: to.Ptr(offset)}
+	if length > 0 {
+		opts.Count = to.Ptr(length)
+	}
+	resp, err := client.Download(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body(nil), nil
+}
+
+// Stat returns the size, modification time and checksum of the blob. The
+// checksum is the base64 encoded Content-MD5 if set, and the ETag otherwise.
+func (f *fs) Stat(ctx context.Context, filename string) (filesystem.Metadata, error) {
+	client, err := f.blobClient(filename)
+	if err != nil {
+		return filesystem.Metadata{}, err
+	}
+	props, err := client.GetProperties(ctx, nil)
+	if err != nil {
+		return filesystem.Metadata{}, err
+	}
+	checksum := strings.Trim(deref(props.ETag), `"`)
+	if len(props.ContentMD5) > 0 {
+		checksum = base64.StdEncoding.EncodeToString(props.ContentMD5)
+	}
+	return filesystem.Metadata{
+		Size:         deref(props.ContentLength),
+		LastModified: deref(props.LastModified),
+		Checksum:     checksum,
+	}, nil
+}
+
+// Remove the named file from the filesystem.
+func (f *fs) Remove(ctx context.Context, filename string) error {
+	client, err := f.blobClient(filename)
+	if err != nil {
+		return err
+	}
+	_, err = client.Delete(ctx, nil)
+	return err
+}
+
+// copyPollInterval is how often the status of a pending copy is checked.
+var copyPollInterval = time.Second
+
+// Copy copies from srcpath to the dstpath, and waits for the copy to
+// complete.
+func (f *fs) Copy(ctx context.Context, srcpath, dstpath string) error {
+	src, err := f.blobClient(srcpath)
+	if err != nil {
+		return err
+	}
+	dst, err := f.blobClient(dstpath)
+	if err != nil {
+		return err
+	}
+	resp, err := dst.StartCopyFromURL(ctx, src.URL(), nil)
+	if err != nil {
+		return err
+	}
+	status := deref(resp.CopyStatus)
+	for status == azblob.CopyStatusTypePending {
+		select {
+		case <-time.After(copyPollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		props, err := dst.GetProperties(ctx, nil)
+		if err != nil {
+			return err
+		}
+		status = deref(props.CopyStatus)
+	}
+	if status != azblob.CopyStatusTypeSuccess {
+		return errors.Errorf("copy of %v to %v finished with status %q", srcpath, dstpath, status)
+	}
+	return nil
+}
+
+// Rename moves oldpath to newpath. Azure Blob Storage has no native rename,
+// so the blob is copied and the original removed.
+func (f *fs) Rename(ctx context.Context, oldpath, newpath string) error {
+	if err := f.Copy(ctx, oldpath, newpath); err != nil {
+		return err
+	}
+	return f.Remove(ctx, oldpath)
+}
+
+// blobReader reads a blob from an offset, reopening the blob with a ranged
+// download after each seek.
+type blobReader struct {
+	ctx    context.Context
+	client *azblob.BlockBlobClient
+
+	offset int64
+	size   int64 // -1 if unknown.
+	body   io.ReadCloser
+}
+
+func (r *blobReader) open() error {
+	resp, err := r.client.Download(r.ctx, &azblob.BlobDownloadOptions{Offset: to.Ptr(r.offset)})
+	if err != nil {
+		return err
+	}
+	if r.offset == 0 && resp.ContentLength != nil {
+		r.size = *resp.ContentLength
+	}
+	r.body = resp.Body(nil)
+	return nil
+}
+
+func (r *blobReader) Read(p []byte) (int, error) {
+	if r.size >= 0 && r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *blobReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		if r.size < 0 {
+			props, err := r.client.GetProperties(r.ctx, nil)
+			if err != nil {
+				return 0, err
+			}
+			r.size = deref(props.ContentLength)
+		}
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.Errorf("seek to negative offset %d in %v", offset, r.client.URL())
+	}
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *blobReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
+
+// blobWriter streams written data to an upload, which completes on Close.
+type blobWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *blobWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *blobWriter) Close() error {
+	w.pw.Close()
+	return <-w.done
+}
+
+// Compile time check for interface implementations.
+var (
+	_ filesystem.Remover     = ((*fs)(nil))
+	_ filesystem.Copier      = ((*fs)(nil))
+	_ filesystem.Renamer     = ((*fs)(nil))
+	_ filesystem.RangeReader = ((*fs)(nil))
+	_ filesystem.Stater      = ((*fs)(nil))
+	_ io.Seeker              = ((*blobReader)(nil))
+)
+
+// deref returns the value p points to, or the zero value if p is nil.
+func deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
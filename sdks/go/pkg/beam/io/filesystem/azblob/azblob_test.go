@@ -0,0 +1,311 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeBlobService is a minimal Azure Blob Storage stand-in for a single
+// container of the devstoreaccount1 account, as used by Azurite.
+type fakeBlobService struct {
+	mu        sync.Mutex
+	container string
+	blobs     map[string][]byte
+	blocks    map[string][]byte
+}
+
+type enumerationResults struct {
+	XMLName xml.Name `xml:"EnumerationResults"`
+	Blobs   struct {
+		Blob []struct {
+			Name       string
+			Properties struct {
+				ContentLength int `xml:"Content-Length"`
+			}
+		}
+	}
+}
+
+func (s *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/devstoreaccount1/"), "/", 2)
+	if parts[0] != s.container {
+		w.Header().Set("x-ms-error-code", "ContainerNotFound")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	if len(parts) == 1 {
+		var res enumerationResults
+		var names []string
+		for name := range s.blobs {
+			if strings.HasPrefix(name, q.Get("prefix")) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			var b struct {
+				Name       string
+				Properties struct {
+					ContentLength int `xml:"Content-Length"`
+				}
+			}
+			b.Name = name
+			b.Properties.ContentLength = len(s.blobs[name])
+			res.Blobs.Blob = append(res.Blobs.Blob, b)
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		xml.NewEncoder(w).Encode(res)
+		return
+	}
+	name, _ := url.PathUnescape(parts[1])
+	switch {
+	case r.Method == http.MethodPut && q.Get("comp") == "block":
+		data, _ := io.ReadAll(r.Body)
+		s.blocks[q.Get("blockid")] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && q.Get("comp") == "blocklist":
+		var list struct {
+			Latest []string
+		}
+		xml.NewDecoder(r.Body).Decode(&list)
+		var data []byte
+		for _, id := range list.Latest {
+			data = append(data, s.blocks[id]...)
+		}
+		s.blobs[name] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && r.Header.Get("x-ms-copy-source") != "":
+		src, _ := url.Parse(r.Header.Get("x-ms-copy-source"))
+		srcName, _ := url.PathUnescape(strings.TrimPrefix(src.Path, "/devstoreaccount1/"+s.container+"/"))
+		s.blobs[name] = s.blobs[srcName]
+		w.Header().Set("x-ms-copy-status", "success")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodDelete:
+		delete(s.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := s.blobs[name]
+		if !ok {
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		start, end := 0, len(data)-1
+		rng := r.Header.Get("x-ms-range")
+		if rng == "" {
+			rng = r.Header.Get("Range")
+		}
+		if rng != "" {
+			fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+			if end >= len(data) {
+				end = len(data) - 1
+			}
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(end+1-start))
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if rng != "" {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		if r.Method == http.MethodGet {
+			w.Write(data[start : end+1])
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newTestFS(t *testing.T) (*fs, *fakeBlobService) {
+	t.Helper()
+	fake := &fakeBlobService{container: "c", blobs: make(map[string][]byte), blocks: make(map[string][]byte)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	// The well known Azurite development account key.
+	key := "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	client, err := newServiceClient("", "devstoreaccount1", key, srv.URL+"/devstoreaccount1/")
+	if err != nil {
+		t.Fatalf("newServiceClient failed: %v", err)
+	}
+	return &fs{client: client}, fake
+}
+
+func TestAzblob_FilesystemNew(t *testing.T) {
+	ctx := context.Background()
+	*account = "devstoreaccount1"
+	defer func() { *account = "" }()
+	path := "azblob://container/"
+	c, err := filesystem.New(ctx, path)
+	if err != nil {
+		t.Errorf("filesystem.New(ctx, %q) = %v, want nil", path, err)
+	}
+	if _, ok := c.(*fs); !ok {
+		t.Errorf("filesystem.New(ctx, %q) type = %T, want *azblob.fs", path, c)
+	}
+}
+
+func TestSecret(t *testing.T) {
+	// Secrets leaked into the pipeline options are ignored.
+	runtime.GlobalOptions.Set("azblob_key", "leaked")
+	defer runtime.GlobalOptions.Set("azblob_key", "")
+
+	os.Unsetenv("AZURE_STORAGE_KEY")
+	if got := secret("", "AZURE_STORAGE_KEY"); got != "" {
+		t.Errorf("secret() = %q, want empty", got)
+	}
+	os.Setenv("AZURE_STORAGE_KEY", "env")
+	defer os.Unsetenv("AZURE_STORAGE_KEY")
+	if got, want := secret("", "AZURE_STORAGE_KEY"), "env"; got != want {
+		t.Errorf("secret() = %q, want %q", got, want)
+	}
+	if got, want := secret("flag", "AZURE_STORAGE_KEY"), "flag"; got != want {
+		t.Errorf("secret() = %q, want %q", got, want)
+	}
+
+	// Secret flags are not exported with the pipeline options.
+	flag.CommandLine.Parse([]string{"--azblob_key=flag", "--azblob_account=devstoreaccount1"})
+	defer func() { *accountKey, *account = "", "" }()
+	opts := runtime.NewOptions()
+	opts.LoadOptionsFromFlags(nil)
+	if got := opts.Get("azblob_key"); got != "" {
+		t.Errorf("exported azblob_key = %q, want empty", got)
+	}
+	if got, want := opts.Get("azblob_account"), "devstoreaccount1"; got != want {
+		t.Errorf("exported azblob_account = %q, want %q", got, want)
+	}
+}
+
+func TestParseObject(t *testing.T) {
+	container, blob, err := parseObject("azblob://data/dir/file.txt")
+	if err != nil || container != "data" || blob != "dir/file.txt" {
+		t.Errorf("parseObject = %q, %q, %v, want data, dir/file.txt", container, blob, err)
+	}
+	for _, p := range []string{"s3://bucket/key", "azblob:///blob"} {
+		if _, _, err := parseObject(p); err == nil {
+			t.Errorf("parseObject(%q) succeeded, want error", p)
+		}
+	}
+}
+
+func TestAzblob_ReadWrite(t *testing.T) {
+	ctx := context.Background()
+	f, fake := newTestFS(t)
+
+	for _, name := range []string{"a/1.txt", "a/2.txt", "a/sub/3.txt", "b/4.txt"} {
+		if err := filesystem.Write(ctx, f, "azblob://c/"+name, []byte("data of "+name)); err != nil {
+			t.Fatalf("Write(%v) failed: %v", name, err)
+		}
+	}
+	if got := string(fake.blobs["a/1.txt"]); got != "data of a/1.txt" {
+		t.Errorf("stored blob = %q, want %q", got, "data of a/1.txt")
+	}
+
+	files, err := f.List(ctx, "azblob://c/a/*.txt")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := []string{"azblob://c/a/1.txt", "azblob://c/a/2.txt"}; !cmp.Equal(files, want) {
+		t.Errorf("List = %v, want %v", files, want)
+	}
+
+	size, err := f.Size(ctx, "azblob://c/a/1.txt")
+	if err != nil || size != 15 {
+		t.Errorf("Size = %v, %v, want 15", size, err)
+	}
+
+	r, err := f.OpenRead(ctx, "azblob://c/a/1.txt")
+	if err != nil {
+		t.Fatalf("OpenRead failed: %v", err)
+	}
+	defer r.Close()
+	if _, err := r.(io.Seeker).Seek(8, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != "a/1.txt" {
+		t.Errorf("read after seek = %q, %v, want %q", data, err, "a/1.txt")
+	}
+
+	if _, err := f.OpenRead(ctx, "azblob://c/missing"); err == nil {
+		t.Error("OpenRead of missing blob succeeded, want error")
+	}
+}
+
+func TestAzblob_Rename(t *testing.T) {
+	ctx := context.Background()
+	f, fake := newTestFS(t)
+	fake.blobs["old"] = []byte("content")
+
+	if err := filesystem.Rename(ctx, f, "azblob://c/old", "azblob://c/new"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if _, ok := fake.blobs["old"]; ok {
+		t.Error("old blob still exists after rename")
+	}
+	if got := string(fake.blobs["new"]); got != "content" {
+		t.Errorf("new blob = %q, want content", got)
+	}
+}
+
+func TestAzblob_RangeAndStat(t *testing.T) {
+	ctx := context.Background()
+	f, fake := newTestFS(t)
+	fake.blobs["blob"] = []byte("0123456789")
+
+	r, err := f.OpenReadRange(ctx, "azblob://c/blob", 2, 5)
+	if err != nil {
+		t.Fatalf("OpenReadRange failed: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "23456" {
+		t.Errorf("OpenReadRange(2, 5) read %q, %v, want %q", data, err, "23456")
+	}
+
+	md, err := f.Stat(ctx, "azblob://c/blob")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	want := filesystem.Metadata{
+		Size:         10,
+		LastModified: time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+		Checksum:     "etag",
+	}
+	if !cmp.Equal(md, want) {
+		t.Errorf("Stat = %+v, want %+v", md, want)
+	}
+}
@@ -18,7 +18,8 @@
 //
 // Registered file systems at minimum implement the Interface abstraction, and
 // can then optionally implement Remover, Renamer, and Copier to support
-// rename operations. Filesystems are only expected to handle their own IO, and
+// rename operations, and RangeReader and Stater to support efficient reads of
+// parts of files. Filesystems are only expected to handle their own IO, and
 // not cross file system IO. Should cross file system IO be required, additional
 // utility methods should be added to this package to support them.
 package filesystem
@@ -28,6 +29,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
 )
@@ -40,6 +42,8 @@ var wellKnownSchemeImportPaths = map[string]string{
 	"memfs":   "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/memfs",
 	"default": "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/local",
 	"gs":      "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/gcs",
+	"s3":      "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/s3",
+	"azblob":  "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/azblob",
 }
 
 // Register registers a file system backend under the given scheme.  For
@@ -106,6 +110,32 @@ type Renamer interface {
 	Rename(ctx context.Context, oldpath, newpath string) error
 }
 
+// RangeReader is an interface for reading part of a file without reading the
+// bytes before it.
+type RangeReader interface {
+	// OpenReadRange opens a file for reading length bytes starting at offset.
+	// A negative length reads until the end of the file.
+	OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error)
+}
+
+// Metadata describes a file.
+type Metadata struct {
+	// Size is the size of the file in bytes.
+	Size int64
+	// LastModified is the time the file was last modified, if known.
+	LastModified time.Time
+	// Checksum is an opaque checksum of the file contents, if the filesystem
+	// provides one without reading the file. Checksums are only comparable
+	// within the same filesystem.
+	Checksum string
+}
+
+// Stater is an interface for fetching the metadata of a file in a single
+// call.
+type Stater interface {
+	Stat(ctx context.Context, filename string) (Metadata, error)
+}
+
 func getScheme(path string) string {
 	if index := strings.Index(path, "://"); index > 0 {
 		return path[:index]
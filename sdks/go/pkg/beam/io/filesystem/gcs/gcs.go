@@ -19,6 +19,8 @@ package gcs
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -140,6 +142,41 @@ func (f *fs) Size(ctx context.Context, filename string) (int64, error) {
 	return attrs.Size, nil
 }
 
+// OpenReadRange opens the object for reading length bytes starting at offset.
+func (f *fs) OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
+	bucket, object, err := gcsx.ParseObject(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if length < 0 {
+		length = -1
+	}
+	return f.client.Bucket(bucket).Object(object).NewRangeReader(ctx, offset, length)
+}
+
+// Stat returns the size, update time and CRC32C checksum of the object. The
+// checksum is base64 encoded in big-endian byte order, as reported by the
+// GCS API.
+func (f *fs) Stat(ctx context.Context, filename string) (filesystem.Metadata, error) {
+	bucket, object, err := gcsx.ParseObject(filename)
+	if err != nil {
+		return filesystem.Metadata{}, err
+	}
+
+	attrs, err := f.client.Bucket(bucket).Object(object).Attrs(ctx)
+	if err != nil {
+		return filesystem.Metadata{}, err
+	}
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], attrs.CRC32C)
+	return filesystem.Metadata{
+		Size:         attrs.Size,
+		LastModified: attrs.Updated,
+		Checksum:     base64.StdEncoding.EncodeToString(crc[:]),
+	}, nil
+}
+
 // Remove the named file from the filesystem.
 func (f *fs) Remove(ctx context.Context, filename string) error {
 	bucket, object, err := gcsx.ParseObject(filename)
@@ -172,6 +209,8 @@ func (f *fs) Copy(ctx context.Context, srcpath, dstpath string) error {
 
 // Compile time check for interface implementations.
 var (
-	_ filesystem.Remover = ((*fs)(nil))
-	_ filesystem.Copier  = ((*fs)(nil))
+	_ filesystem.Remover     = ((*fs)(nil))
+	_ filesystem.Copier      = ((*fs)(nil))
+	_ filesystem.RangeReader = ((*fs)(nil))
+	_ filesystem.Stater      = ((*fs)(nil))
 )
@@ -67,6 +67,41 @@ func (f *fs) Size(_ context.Context, filename string) (int64, error) {
 	return info.Size(), nil
 }
 
+// OpenReadRange opens the file for reading length bytes starting at offset.
+func (f *fs) OpenReadRange(_ context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return file, nil
+	}
+	return &sectionReader{Reader: io.LimitReader(file, length), file: file}, nil
+}
+
+type sectionReader struct {
+	io.Reader
+	file *os.File
+}
+
+func (r *sectionReader) Close() error {
+	return r.file.Close()
+}
+
+// Stat returns the size and modification time of the file. Local files have
+// no checksum.
+func (f *fs) Stat(_ context.Context, filename string) (filesystem.Metadata, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return filesystem.Metadata{}, err
+	}
+	return filesystem.Metadata{Size: info.Size(), LastModified: info.ModTime()}, nil
+}
+
 // Remove the named file from the filesystem.
 func (f *fs) Remove(_ context.Context, filename string) error {
 	return os.Remove(filename)
@@ -79,6 +114,8 @@ func (f *fs) Rename(_ context.Context, oldpath, newpath string) error {
 
 // Compile time check for interface implementations.
 var (
-	_ filesystem.Remover = ((*fs)(nil))
-	_ filesystem.Renamer = ((*fs)(nil))
+	_ filesystem.Remover     = ((*fs)(nil))
+	_ filesystem.Renamer     = ((*fs)(nil))
+	_ filesystem.RangeReader = ((*fs)(nil))
+	_ filesystem.Stater      = ((*fs)(nil))
 )
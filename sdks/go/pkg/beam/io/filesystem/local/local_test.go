@@ -177,3 +177,42 @@ func TestLocal_rename(t *testing.T) {
 		t.Errorf("List(%v) = %v, want []string{%v}", listGlob, files, filePath2)
 	}
 }
+
+func TestLocal_rangeAndStat(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "file.txt")
+	data := []byte("0123456789")
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := New(ctx)
+
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{3, 4, "3456"},
+		{7, -1, "789"},
+		{8, 10, "89"},
+	}
+	for _, test := range tests {
+		r, err := c.(filesystem.RangeReader).OpenReadRange(ctx, filePath, test.offset, test.length)
+		if err != nil {
+			t.Fatalf("OpenReadRange(%v, %v) = %v, want nil", test.offset, test.length, err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || string(got) != test.want {
+			t.Errorf("OpenReadRange(%v, %v) read %q, %v, want %q", test.offset, test.length, got, err, test.want)
+		}
+	}
+
+	md, err := filesystem.Stat(ctx, c, filePath)
+	if err != nil {
+		t.Fatalf("Stat(%q) = %v, want nil", filePath, err)
+	}
+	if md.Size != int64(len(data)) || md.LastModified.IsZero() {
+		t.Errorf("Stat(%q) = %+v, want size %v and a modification time", filePath, md, len(data))
+	}
+}
@@ -19,6 +19,8 @@ package memfs
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -27,6 +29,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
 )
@@ -38,8 +41,9 @@ func init() {
 var instance = &fs{m: make(map[string][]byte)}
 
 type fs struct {
-	m  map[string][]byte
-	mu sync.Mutex
+	m     map[string][]byte
+	mtime map[string]time.Time // lazily initialized.
+	mu    sync.Mutex
 }
 
 // New returns the global memory filesystem.
@@ -102,11 +106,53 @@ func (f *fs) Size(_ context.Context, filename string) (int64, error) {
 	return -1, os.ErrNotExist
 }
 
+// OpenReadRange opens the file for reading length bytes starting at offset.
+func (f *fs) OpenReadRange(_ context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	normalizedKey := normalize(filename)
+	v, ok := f.m[normalizedKey]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	if offset < 0 || offset > int64(len(v)) {
+		return nil, fmt.Errorf("%w: invalid offset %d for file of size %d", errBadSeek, offset, len(v))
+	}
+	r := &bytesReader{instance: f, normalizedKey: normalizedKey, pos: offset}
+	if length < 0 {
+		return r, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(r, length), r}, nil
+}
+
+// Stat returns the size, last write time and MD5 checksum of the file.
+func (f *fs) Stat(_ context.Context, filename string) (filesystem.Metadata, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	normalizedKey := normalize(filename)
+	v, ok := f.m[normalizedKey]
+	if !ok {
+		return filesystem.Metadata{}, os.ErrNotExist
+	}
+	sum := md5.Sum(v)
+	return filesystem.Metadata{
+		Size:         int64(len(v)),
+		LastModified: f.mtime[normalizedKey],
+		Checksum:     hex.EncodeToString(sum[:]),
+	}, nil
+}
+
 // Remove the named file from the filesystem.
 func (f *fs) Remove(_ context.Context, filename string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	delete(f.m, filename)
+	delete(f.mtime, filename)
 	return nil
 }
 
@@ -115,7 +161,9 @@ func (f *fs) Rename(_ context.Context, oldpath, newpath string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.m[newpath] = f.m[oldpath]
+	f.touch(newpath, f.mtime[oldpath])
 	delete(f.m, oldpath)
+	delete(f.mtime, oldpath)
 	return nil
 }
 
@@ -124,14 +172,25 @@ func (f *fs) Copy(_ context.Context, oldpath, newpath string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.m[newpath] = f.m[oldpath]
+	f.touch(newpath, time.Now())
 	return nil
 }
 
+// touch records the modification time of key. Must be called with mu held.
+func (f *fs) touch(key string, t time.Time) {
+	if f.mtime == nil {
+		f.mtime = make(map[string]time.Time)
+	}
+	f.mtime[key] = t
+}
+
 // Compile time check for interface implementations.
 var (
-	_ filesystem.Remover = ((*fs)(nil))
-	_ filesystem.Renamer = ((*fs)(nil))
-	_ filesystem.Copier  = ((*fs)(nil))
+	_ filesystem.Remover     = ((*fs)(nil))
+	_ filesystem.Renamer     = ((*fs)(nil))
+	_ filesystem.Copier      = ((*fs)(nil))
+	_ filesystem.RangeReader = ((*fs)(nil))
+	_ filesystem.Stater      = ((*fs)(nil))
 )
 
 // Copier copies the old path to the new path.
@@ -143,6 +202,7 @@ func (f *fs) write(key string, value []byte) error {
 	copy(cp, value)
 
 	f.m[normalize(key)] = cp
+	f.touch(normalize(key), time.Now())
 	return nil
 }
 
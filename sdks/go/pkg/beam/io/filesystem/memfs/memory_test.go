@@ -433,6 +433,52 @@ func TestSeek(t *testing.T) {
 	}
 }
 
+func TestOpenReadRange(t *testing.T) {
+	ctx := context.Background()
+	fs := &fs{m: make(map[string][]byte)}
+	fs.write("memfs://abc", []byte("0123456789"))
+
+	for _, tt := range []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{2, 3, "234"},
+		{8, 5, "89"},
+		{10, -1, ""},
+	} {
+		r, err := fs.OpenReadRange(ctx, "memfs://abc", tt.offset, tt.length)
+		if err != nil {
+			t.Fatalf("OpenReadRange(%v, %v) failed: %v", tt.offset, tt.length, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("OpenReadRange(%v, %v) read %q, %v, want %q", tt.offset, tt.length, got, err, tt.want)
+		}
+	}
+	if _, err := fs.OpenReadRange(ctx, "memfs://abc", 11, -1); !errors.Is(err, errBadSeek) {
+		t.Errorf("OpenReadRange past EOF got err = %v, want %v", err, errBadSeek)
+	}
+}
+
+func TestStat(t *testing.T) {
+	ctx := context.Background()
+	fs := &fs{m: make(map[string][]byte)}
+	fs.write("memfs://abc", []byte("hello"))
+
+	md, err := fs.Stat(ctx, "memfs://abc")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if md.Size != 5 || md.LastModified.IsZero() || md.Checksum != "5d41402abc4b2a76b9719d911017c592" {
+		t.Errorf("Stat = %+v, want size 5, a modification time and the MD5 of the contents", md)
+	}
+	if _, err := fs.Stat(ctx, "memfs://missing"); err != os.ErrNotExist {
+		t.Errorf("Stat of missing file got err = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
 func reset() {
 	instance.m = map[string][]byte{}
+	instance.mtime = nil
 }
@@ -0,0 +1,387 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package s3 contains an Amazon S3 implementation of the Beam file system.
+// It also supports S3-compatible stores, such as MinIO, through the
+// --s3_endpoint and --s3_path_style flags.
+//
+// Credentials and the region are resolved with the default AWS SDK
+// configuration chain, such as the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+// and AWS_REGION environment variables.
+package s3
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+var (
+	endpoint  = flag.String("s3_endpoint", "", "Endpoint URL of an S3-compatible store, such as MinIO. Defaults to AWS S3.")
+	region    = flag.String("s3_region", "", "Region of the S3 buckets. Defaults to the AWS SDK configuration.")
+	pathStyle = flag.Bool("s3_path_style", false, "Address buckets by path instead of by host name, as most S3-compatible stores require.")
+)
+
+func init() {
+	filesystem.Register("s3", New)
+}
+
+// option returns the value of the flag, if set, or else of the pipeline
+// option with the same name, so that launch time flags apply on workers.
+func option(name, value string) string {
+	if value != "" {
+		return value
+	}
+	return runtime.GlobalOptions.Get(name)
+}
+
+type fs struct {
+	client *s3.Client
+}
+
+// New creates a new S3 filesystem using the default AWS SDK configuration.
+func New(ctx context.Context) filesystem.Interface {
+	var opts []func(*config.LoadOptions) error
+	if r := option("s3_region", *region); r != "" {
+		opts = append(opts, config.WithRegion(r))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to load AWS configuration"))
+	}
+	return newFS(cfg, option("s3_endpoint", *endpoint), usePathStyle())
+}
+
+// usePathStyle returns whether buckets are addressed by path, as set by the
+// flag or else by the pipeline option.
+func usePathStyle() bool {
+	if *pathStyle {
+		return true
+	}
+	v, _ := strconv.ParseBool(runtime.GlobalOptions.Get("s3_path_style"))
+	return v
+}
+
+func newFS(cfg aws.Config, endpoint string, pathStyle bool) *fs {
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(endpoint)
+		}
+		o.UsePathStyle = pathStyle
+	})
+	return &fs{client: client}
+}
+
+// parseObject splits an s3://bucket/key path into its bucket and key.
+func parseObject(p string) (bucket, key string, err error) {
+	u, err := url.Parse(p)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "s3" {
+		return "", "", errors.Errorf("path %q is not an s3:// path", p)
+	}
+	if u.Host == "" {
+		return "", "", errors.Errorf("path %q has no bucket", p)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+func (f *fs) Close() error {
+	return nil
+}
+
+// List expands the glob to the matching objects. Glob patterns follow
+// path.Match and are matched against the object keys.
+func (f *fs) List(ctx context.Context, glob string) ([]string, error) {
+	bucket, key, err := parseObject(glob)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	if index := strings.IndexAny(key, "*?["); index >= 0 {
+		// We handle globs by listing all objects with the literal prefix of
+		// the pattern and matching them here.
+		p := s3.NewListObjectsV2Paginator(f.client, &s3.ListObjectsV2Input{
+			Bucket: aws.String(bucket),
+			Prefix: aws.String(key[:index]),
+		})
+		for p.HasMorePages() {
+			page, err := p.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, obj := range page.Contents {
+				match, err := path.Match(key, aws.ToString(obj.Key))
+				if err != nil {
+					return nil, err
+				}
+				if match {
+					candidates = append(candidates, aws.ToString(obj.Key))
+				}
+			}
+		}
+	} else {
+		// Single object.
+		candidates = []string{key}
+	}
+
+	var ret []string
+	for _, obj := range candidates {
+		ret = append(ret, fmt.Sprintf("s3://%v/%v", bucket, obj))
+	}
+	return ret, nil
+}
+
+// OpenRead opens the object for reading. The returned reader also implements
+// io.Seeker, and seeking issues ranged reads from the new offset.
+func (f *fs) OpenRead(ctx context.Context, filename string) (io.ReadCloser, error) {
+	bucket, key, err := parseObject(filename)
+	if err != nil {
+		return nil, err
+	}
+	r := &objectReader{ctx: ctx, client: f.client, bucket: bucket, key: key, size: -1}
+	// Open eagerly to report missing objects on open, as other filesystems do.
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// OpenWrite opens the object for writing. The data is streamed with a
+// multipart upload, which is completed when the writer is closed.
+func (f *fs) OpenWrite(ctx context.Context, filename string) (io.WriteCloser, error) {
+	bucket, key, err := parseObject(filename)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	w := &objectWriter{pw: pw, done: make(chan error, 1)}
+	uploader := manager.NewUploader(f.client)
+	go func() {
+		_, err := uploader.Upload(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   pr,
+		})
+		// Unblock any pending writes if the upload failed.
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+func (f *fs) Size(ctx context.Context, filename string) (int64, error) {
+	bucket, key, err := parseObject(filename)
+	if err != nil {
+		return -1, err
+	}
+	out, err := f.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return -1, err
+	}
+	return out.ContentLength, nil
+}
+
+// OpenReadRange opens the object for reading length bytes starting at
+// offset, using a ranged GET.
+func (f *fs) OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
+	bucket, key, err := parseObject(filename)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+	rng := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		rng += fmt.Sprint(offset + length - 1)
+	}
+	out, err := f.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key), Range: aws.String(rng)})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// Stat returns the size, modification time and ETag of the object. The ETag
+// is the MD5 of the contents for objects that weren't uploaded in parts.
+func (f *fs) Stat(ctx context.Context, filename string) (filesystem.Metadata, error) {
+	bucket, key, err := parseObject(filename)
+	if err != nil {
+		return filesystem.Metadata{}, err
+	}
+	out, err := f.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return filesystem.Metadata{}, err
+	}
+	return filesystem.Metadata{
+		Size:         out.ContentLength,
+		LastModified: aws.ToTime(out.LastModified),
+		Checksum:     strings.Trim(aws.ToString(out.ETag), `"`),
+	}, nil
+}
+
+// Remove the named file from the filesystem.
+func (f *fs) Remove(ctx context.Context, filename string) error {
+	bucket, key, err := parseObject(filename)
+	if err != nil {
+		return err
+	}
+	_, err = f.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	return err
+}
+
+// Copy copies from srcpath to the dstpath.
+func (f *fs) Copy(ctx context.Context, srcpath, dstpath string) error {
+	srcBucket, src, err := parseObject(srcpath)
+	if err != nil {
+		return err
+	}
+	dstBucket, dst, err := parseObject(dstpath)
+	if err != nil {
+		return err
+	}
+	_, err = f.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(dstBucket),
+		Key:        aws.String(dst),
+		CopySource: aws.String(url.PathEscape(srcBucket) + "/" + (&url.URL{Path: src}).EscapedPath()),
+	})
+	return err
+}
+
+// Rename moves oldpath to newpath. S3 has no native rename, so the object is
+// copied and the original removed.
+func (f *fs) Rename(ctx context.Context, oldpath, newpath string) error {
+	if err := f.Copy(ctx, oldpath, newpath); err != nil {
+		return err
+	}
+	return f.Remove(ctx, oldpath)
+}
+
+// objectReader reads an object from an offset, reopening the object with a
+// ranged GET after each seek.
+type objectReader struct {
+	ctx    context.Context
+	client *s3.Client
+	bucket string
+	key    string
+
+	offset int64
+	size   int64 // -1 if unknown.
+	body   io.ReadCloser
+}
+
+func (r *objectReader) open() error {
+	in := &s3.GetObjectInput{Bucket: aws.String(r.bucket), Key: aws.String(r.key)}
+	if r.offset > 0 {
+		in.Range = aws.String(fmt.Sprintf("bytes=%d-", r.offset))
+	}
+	out, err := r.client.GetObject(r.ctx, in)
+	if err != nil {
+		return err
+	}
+	if r.offset == 0 {
+		r.size = out.ContentLength
+	}
+	r.body = out.Body
+	return nil
+}
+
+func (r *objectReader) Read(p []byte) (int, error) {
+	if r.size >= 0 && r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *objectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		if r.size < 0 {
+			out, err := r.client.HeadObject(r.ctx, &s3.HeadObjectInput{Bucket: aws.String(r.bucket), Key: aws.String(r.key)})
+			if err != nil {
+				return 0, err
+			}
+			r.size = out.ContentLength
+		}
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.Errorf("seek to negative offset %d in s3://%v/%v", offset, r.bucket, r.key)
+	}
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *objectReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
+
+// objectWriter streams written data to an upload, which completes on Close.
+type objectWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *objectWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *objectWriter) Close() error {
+	w.pw.Close()
+	return <-w.done
+}
+
+// Compile time check for interface implementations.
+var (
+	_ filesystem.Remover     = ((*fs)(nil))
+	_ filesystem.Copier      = ((*fs)(nil))
+	_ filesystem.Renamer     = ((*fs)(nil))
+	_ filesystem.RangeReader = ((*fs)(nil))
+	_ filesystem.Stater      = ((*fs)(nil))
+	_ io.Seeker              = ((*objectReader)(nil))
+)
@@ -0,0 +1,268 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeS3 is a minimal path-style S3 stand-in for a single bucket.
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+type listResult struct {
+	XMLName  xml.Name `xml:"ListBucketResult"`
+	Contents []struct {
+		Key  string
+		Size int
+	}
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if parts[0] != s.bucket {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+	if len(parts) == 1 || parts[1] == "" {
+		var res listResult
+		var keys []string
+		for k := range s.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			res.Contents = append(res.Contents, struct {
+				Key  string
+				Size int
+			}{k, len(s.objects[k])})
+		}
+		xml.NewEncoder(w).Encode(res)
+		return
+	}
+	key := parts[1]
+	switch r.Method {
+	case http.MethodPut:
+		if src := r.Header.Get("x-amz-copy-source"); src != "" {
+			s.objects[key] = s.objects[strings.SplitN(src, "/", 2)[1]]
+			fmt.Fprint(w, "<CopyObjectResult></CopyObjectResult>")
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		s.objects[key] = data
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet, http.MethodHead:
+		data, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		start, end := 0, len(data)-1
+		rng := r.Header.Get("Range")
+		if rng != "" {
+			fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+			if end >= len(data) {
+				end = len(data) - 1
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(end+1-start))
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(data)))
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if rng != "" {
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		if r.Method == http.MethodGet {
+			w.Write(data[start : end+1])
+		}
+	}
+}
+
+func newTestFS(t *testing.T) (*fs, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{bucket: "bucket", objects: make(map[string][]byte)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	cfg := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	}
+	return newFS(cfg, srv.URL, true), fake
+}
+
+func TestS3_FilesystemNew(t *testing.T) {
+	ctx := context.Background()
+	path := "s3://bucket/"
+	c, err := filesystem.New(ctx, path)
+	if err != nil {
+		t.Errorf("filesystem.New(ctx, %q) = %v, want nil", path, err)
+	}
+	if _, ok := c.(*fs); !ok {
+		t.Errorf("filesystem.New(ctx, %q) type = %T, want *s3.fs", path, c)
+	}
+}
+
+func TestS3_NewFromPipelineOptions(t *testing.T) {
+	// Workers only get the settings as pipeline options, not as flags.
+	fake := &fakeS3{bucket: "bucket", objects: make(map[string][]byte)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	for k, v := range map[string]string{"s3_endpoint": srv.URL, "s3_region": "us-east-1", "s3_path_style": "true"} {
+		runtime.GlobalOptions.Set(k, v)
+		defer runtime.GlobalOptions.Set(k, "")
+	}
+	os.Setenv("AWS_ACCESS_KEY_ID", "key")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+	defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+
+	ctx := context.Background()
+	f := New(ctx)
+	if err := filesystem.Write(ctx, f, "s3://bucket/file.txt", []byte("data")); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if got, want := string(fake.objects["file.txt"]), "data"; got != want {
+		t.Errorf("object = %q, want %q", got, want)
+	}
+}
+
+func TestParseObject(t *testing.T) {
+	bucket, key, err := parseObject("s3://my-bucket/dir/file.txt")
+	if err != nil || bucket != "my-bucket" || key != "dir/file.txt" {
+		t.Errorf("parseObject = %q, %q, %v, want my-bucket, dir/file.txt", bucket, key, err)
+	}
+	for _, p := range []string{"gs://bucket/key", "s3:///key"} {
+		if _, _, err := parseObject(p); err == nil {
+			t.Errorf("parseObject(%q) succeeded, want error", p)
+		}
+	}
+}
+
+func TestS3_ReadWrite(t *testing.T) {
+	ctx := context.Background()
+	f, fake := newTestFS(t)
+
+	for _, name := range []string{"a/1.txt", "a/2.txt", "a/sub/3.txt", "b/4.txt"} {
+		if err := filesystem.Write(ctx, f, "s3://bucket/"+name, []byte("data of "+name)); err != nil {
+			t.Fatalf("Write(%v) failed: %v", name, err)
+		}
+	}
+	if got := string(fake.objects["a/1.txt"]); got != "data of a/1.txt" {
+		t.Errorf("stored object = %q, want %q", got, "data of a/1.txt")
+	}
+
+	files, err := f.List(ctx, "s3://bucket/a/*.txt")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := []string{"s3://bucket/a/1.txt", "s3://bucket/a/2.txt"}; !cmp.Equal(files, want) {
+		t.Errorf("List = %v, want %v", files, want)
+	}
+
+	size, err := f.Size(ctx, "s3://bucket/a/1.txt")
+	if err != nil || size != 15 {
+		t.Errorf("Size = %v, %v, want 15", size, err)
+	}
+
+	r, err := f.OpenRead(ctx, "s3://bucket/a/1.txt")
+	if err != nil {
+		t.Fatalf("OpenRead failed: %v", err)
+	}
+	defer r.Close()
+	if _, err := r.(io.Seeker).Seek(8, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != "a/1.txt" {
+		t.Errorf("read after seek = %q, %v, want %q", data, err, "a/1.txt")
+	}
+
+	if _, err := f.OpenRead(ctx, "s3://bucket/missing"); err == nil {
+		t.Error("OpenRead of missing object succeeded, want error")
+	}
+}
+
+func TestS3_Rename(t *testing.T) {
+	ctx := context.Background()
+	f, fake := newTestFS(t)
+	fake.objects["old"] = []byte("content")
+
+	if err := filesystem.Rename(ctx, f, "s3://bucket/old", "s3://bucket/new"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if _, ok := fake.objects["old"]; ok {
+		t.Error("old object still exists after rename")
+	}
+	if got := string(fake.objects["new"]); got != "content" {
+		t.Errorf("new object = %q, want content", got)
+	}
+}
+
+func TestS3_RangeAndStat(t *testing.T) {
+	ctx := context.Background()
+	f, fake := newTestFS(t)
+	fake.objects["key"] = []byte("0123456789")
+
+	r, err := f.OpenReadRange(ctx, "s3://bucket/key", 2, 5)
+	if err != nil {
+		t.Fatalf("OpenReadRange failed: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "23456" {
+		t.Errorf("OpenReadRange(2, 5) read %q, %v, want %q", data, err, "23456")
+	}
+
+	md, err := f.Stat(ctx, "s3://bucket/key")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	want := filesystem.Metadata{
+		Size:         10,
+		LastModified: time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+		Checksum:     "781e5e245d69b566979b86e28d23f2c7",
+	}
+	if !cmp.Equal(md, want) {
+		t.Errorf("Stat = %+v, want %+v", md, want)
+	}
+}
@@ -16,10 +16,13 @@
 package filesystem
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
 )
 
 // Read fully reads the given file from the file system.
@@ -102,6 +105,147 @@ func Rename(ctx context.Context, fs Interface, oldpath, newpath string) error {
 	return nil
 }
 
+// OpenReadRange opens the given file for reading length bytes starting at
+// offset. A negative length reads until the end of the file.
+//
+// If the file system implements RangeReader, it uses that. Otherwise it seeks
+// to the offset if the reader returned by OpenRead implements io.Seeker, or
+// reads and discards the bytes before the offset as a last resort.
+func OpenReadRange(ctx context.Context, fs Interface, filename string, offset, length int64) (io.ReadCloser, error) {
+	if rr, ok := fs.(RangeReader); ok {
+		return rr.OpenReadRange(ctx, filename, offset, length)
+	}
+	r, err := fs.OpenRead(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if s, ok := r.(io.Seeker); ok {
+			_, err = s.Seek(offset, io.SeekStart)
+		} else {
+			_, err = io.CopyN(ioutil.Discard, r, offset)
+		}
+		if err != nil {
+			r.Close()
+			return nil, errors.Wrapf(err, "failed to skip to offset %d in %v", offset, filename)
+		}
+	}
+	if length < 0 {
+		return r, nil
+	}
+	return &limitedReadCloser{Reader: io.LimitReader(r, length), Closer: r}, nil
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// OpenReadSeeker opens the given file for random access reads.
+//
+// The reader returned by OpenRead is used directly if it implements
+// io.Seeker. Otherwise, if the file system implements RangeReader, each seek
+// reopens the file at the new offset. As a last resort, the file is read
+// fully into memory.
+func OpenReadSeeker(ctx context.Context, fs Interface, filename string) (io.ReadSeekCloser, error) {
+	r, err := fs.OpenRead(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	if rs, ok := r.(io.ReadSeekCloser); ok {
+		return rs, nil
+	}
+	if rr, ok := fs.(RangeReader); ok {
+		size, err := fs.Size(ctx, filename)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		return &rangeSeeker{ctx: ctx, rr: rr, filename: filename, size: size, r: r}, nil
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return bytesReadCloser{bytes.NewReader(data)}, nil
+}
+
+type bytesReadCloser struct {
+	*bytes.Reader
+}
+
+func (bytesReadCloser) Close() error { return nil }
+
+// rangeSeeker implements io.ReadSeekCloser on top of a RangeReader. The
+// underlying range is only reopened when reading after a seek that changed
+// the offset.
+type rangeSeeker struct {
+	ctx      context.Context
+	rr       RangeReader
+	filename string
+	size     int64
+
+	offset int64
+	r      io.ReadCloser
+}
+
+func (s *rangeSeeker) Read(p []byte) (int, error) {
+	if s.offset >= s.size {
+		return 0, io.EOF
+	}
+	if s.r == nil {
+		r, err := s.rr.OpenReadRange(s.ctx, s.filename, s.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		s.r = r
+	}
+	n, err := s.r.Read(p)
+	s.offset += int64(n)
+	return n, err
+}
+
+func (s *rangeSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	}
+	if offset < 0 {
+		return 0, errors.Errorf("seek to negative offset %d in %v", offset, s.filename)
+	}
+	if offset != s.offset && s.r != nil {
+		s.r.Close()
+		s.r = nil
+	}
+	s.offset = offset
+	return offset, nil
+}
+
+func (s *rangeSeeker) Close() error {
+	if s.r == nil {
+		return nil
+	}
+	return s.r.Close()
+}
+
+// Stat returns the metadata of the given file.
+//
+// If the file system implements Stater, it uses that, otherwise only the
+// size of the file is populated.
+func Stat(ctx context.Context, fs Interface, filename string) (Metadata, error) {
+	if st, ok := fs.(Stater); ok {
+		return st.Stat(ctx, filename)
+	}
+	size, err := fs.Size(ctx, filename)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return Metadata{Size: size}, nil
+}
+
 type unimplementedError struct {
 	fs          Interface
 	iface, mthd string
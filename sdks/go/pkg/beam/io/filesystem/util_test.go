@@ -397,3 +397,117 @@ func TestRename(t *testing.T) {
 		}
 	})
 }
+
+type testRangeImpl struct {
+	*testImpl
+	rangeReads int
+}
+
+func rangeImpl(fs *testImpl) *testRangeImpl {
+	return &testRangeImpl{testImpl: fs}
+}
+
+func (fs *testRangeImpl) OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
+	fs.rangeReads++
+	v := fs.m[filename][offset:]
+	if length >= 0 && length < int64(len(v)) {
+		v = v[:length]
+	}
+	return io.NopCloser(bytes.NewReader(v)), nil
+}
+
+func (fs *testRangeImpl) Size(ctx context.Context, filename string) (int64, error) {
+	return int64(len(fs.m[filename])), nil
+}
+
+var _ RangeReader = (*testRangeImpl)(nil)
+
+func TestOpenReadRange(t *testing.T) {
+	ctx := context.Background()
+	filename := "filename"
+	setup := func() *testImpl {
+		fs := newTestImpl()
+		fs.m[filename] = []byte("0123456789")
+		return fs
+	}
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{3, -1, "3456789"},
+		{3, 4, "3456"},
+		{8, 5, "89"},
+	}
+	for _, test := range tests {
+		for _, fs := range []Interface{setup(), rangeImpl(setup())} {
+			r, err := OpenReadRange(ctx, fs, filename, test.offset, test.length)
+			if err != nil {
+				t.Fatalf("OpenReadRange(%T, %v, %v) = %v, want nil", fs, test.offset, test.length, err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil || string(got) != test.want {
+				t.Errorf("OpenReadRange(%T, %v, %v) read %q, %v, want %q", fs, test.offset, test.length, got, err, test.want)
+			}
+		}
+	}
+	t.Run("pastEOF", func(t *testing.T) {
+		if _, err := OpenReadRange(ctx, setup(), filename, 20, -1); err == nil {
+			t.Error("OpenReadRange past the end of the file succeeded, want error")
+		}
+	})
+}
+
+func TestOpenReadSeeker(t *testing.T) {
+	ctx := context.Background()
+	filename := "filename"
+	setup := func() *testImpl {
+		fs := newTestImpl()
+		fs.m[filename] = []byte("0123456789")
+		return fs
+	}
+	check := func(t *testing.T, fs Interface) {
+		t.Helper()
+		r, err := OpenReadSeeker(ctx, fs, filename)
+		if err != nil {
+			t.Fatalf("OpenReadSeeker() = %v, want nil", err)
+		}
+		defer r.Close()
+		if _, err := r.Seek(-4, io.SeekEnd); err != nil {
+			t.Fatalf("Seek(-4, io.SeekEnd) = %v, want nil", err)
+		}
+		buf := make([]byte, 2)
+		if _, err := io.ReadFull(r, buf); err != nil || string(buf) != "67" {
+			t.Errorf("read after seek = %q, %v, want %q", buf, err, "67")
+		}
+		if _, err := r.Seek(1, io.SeekStart); err != nil {
+			t.Fatalf("Seek(1, io.SeekStart) = %v, want nil", err)
+		}
+		if _, err := io.ReadFull(r, buf); err != nil || string(buf) != "12" {
+			t.Errorf("read after seek = %q, %v, want %q", buf, err, "12")
+		}
+	}
+	t.Run("buffered", func(t *testing.T) {
+		check(t, setup())
+	})
+	t.Run("rangeReader", func(t *testing.T) {
+		fs := rangeImpl(setup())
+		check(t, fs)
+		if got, want := fs.rangeReads, 2; got != want {
+			t.Errorf("OpenReadRange calls = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestStat(t *testing.T) {
+	ctx := context.Background()
+	fs := rangeImpl(newTestImpl())
+	fs.m["filename"] = []byte("data")
+	md, err := Stat(ctx, fs, "filename")
+	if err != nil {
+		t.Fatalf("Stat() = %v, want nil", err)
+	}
+	if got, want := md, (Metadata{Size: 4}); got != want {
+		t.Errorf("Stat() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,64 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mongodbio contains transforms for reading from and writing to
+// MongoDB collections.
+//
+// Documents are converted to and from Go values with the BSON codecs of the
+// MongoDB Go driver, so struct fields may use `bson` tags.
+//
+// Experimental.
+package mongodbio
+
+import (
+	"context"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// mongoCollection is the subset of the *mongo.Collection methods used by the
+// DoFns.
+type mongoCollection interface {
+	Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (*mongo.Cursor, error)
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error)
+	BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error)
+}
+
+// openCollection connects to the deployment at uri, and returns the
+// collection and a function disconnecting from the deployment. It is a
+// variable so that tests can substitute a fake collection.
+var openCollection = func(ctx context.Context, uri, database, collection string) (mongoCollection, func(context.Context) error, error) {
+	client, err := connect(ctx, uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.Database(database).Collection(collection), client.Disconnect, nil
+}
+
+// connect returns a client connected to the deployment at uri.
+func connect(ctx context.Context, uri string) (*mongo.Client, error) {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, errors.Wrap(err, "mongodbio failed to connect")
+	}
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		client.Disconnect(ctx)
+		return nil, errors.Wrap(err, "mongodbio failed to ping deployment")
+	}
+	return client, nil
+}
@@ -0,0 +1,411 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodbio
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*splitFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*readFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*idRange)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*idRanges)(nil)).Elem())
+}
+
+const (
+	// defaultNumSplits is the default number of _id ranges a read is split into.
+	defaultNumSplits = 10
+	// samplesPerSplit is the number of sampled _ids per split when computing
+	// split points by sampling.
+	samplesPerSplit = 10
+)
+
+// ReadOptions represents options for reading from MongoDB.
+type ReadOptions struct {
+	// NumSplits is the number of _id ranges the collection is split into.
+	// Defaults to 10.
+	NumSplits int
+	// BucketAuto computes the _id ranges with a $bucketAuto aggregation,
+	// which yields evenly sized ranges but scans the whole collection.
+	// Otherwise the ranges are computed from a $sample of the _ids.
+	BucketAuto bool
+	// Filter restricts the documents read.
+	Filter bson.M
+}
+
+// WithReadNumSplits sets the number of _id ranges read in parallel.
+func WithReadNumSplits(numSplits int) func(*ReadOptions) error {
+	return func(ro *ReadOptions) error {
+		if numSplits <= 0 {
+			return errors.Errorf("number of splits must be positive, got %v", numSplits)
+		}
+		ro.NumSplits = numSplits
+		return nil
+	}
+}
+
+// WithReadBucketAuto splits the read with a $bucketAuto aggregation instead
+// of sampling.
+func WithReadBucketAuto() func(*ReadOptions) error {
+	return func(ro *ReadOptions) error {
+		ro.BucketAuto = true
+		return nil
+	}
+}
+
+// WithReadFilter only reads the documents matching filter.
+func WithReadFilter(filter bson.M) func(*ReadOptions) error {
+	return func(ro *ReadOptions) error {
+		ro.Filter = filter
+		return nil
+	}
+}
+
+// Read reads all documents of the collection and decodes them into values of
+// type t. It returns a PCollection<t>. The collection is split into _id
+// ranges, which are the positions of a splittable DoFn, so that the ranges
+// can be read in parallel and split dynamically by the runner. For example:
+//
+//	type Event struct {
+//	    ID   primitive.ObjectID `bson:"_id"`
+//	    Name string             `bson:"name"`
+//	}
+//
+//	events := mongodbio.Read(s, "mongodb://localhost:27017", "db", "events",
+//	    reflect.TypeOf(Event{}), mongodbio.WithReadNumSplits(32))
+func Read(s beam.Scope, uri, database, collection string, t reflect.Type, options ...func(*ReadOptions) error) beam.PCollection {
+	s = s.Scope("mongodbio.Read")
+
+	readOptions := ReadOptions{NumSplits: defaultNumSplits}
+	for _, opt := range options {
+		if err := opt(&readOptions); err != nil {
+			panic(err)
+		}
+	}
+	filter, err := encodeFilter(readOptions.Filter)
+	if err != nil {
+		panic(err)
+	}
+	common := collectionFn{URI: uri, Database: database, Collection: collection, Filter: filter}
+
+	imp := beam.Impulse(s)
+	ranges := beam.ParDo(s, &splitFn{collectionFn: common, NumSplits: readOptions.NumSplits, BucketAuto: readOptions.BucketAuto}, imp)
+	return beam.ParDo(s, &readFn{collectionFn: common, Type: beam.EncodedType{T: t}}, ranges, beam.TypeDefinition{Var: beam.XType, T: t})
+}
+
+func encodeFilter(filter bson.M) ([]byte, error) {
+	if filter == nil {
+		return nil, nil
+	}
+	b, err := bson.Marshal(filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "mongodbio failed to encode filter")
+	}
+	return b, nil
+}
+
+// collectionFn holds the connection and collection shared by the DoFns.
+type collectionFn struct {
+	URI        string `json:"uri"`
+	Database   string `json:"database"`
+	Collection string `json:"collection"`
+	// Filter is the BSON encoded filter, if any.
+	Filter []byte `json:"filter"`
+
+	collection mongoCollection
+	disconnect func(context.Context) error
+}
+
+func (fn *collectionFn) Setup(ctx context.Context) error {
+	collection, disconnect, err := openCollection(ctx, fn.URI, fn.Database, fn.Collection)
+	if err != nil {
+		return err
+	}
+	fn.collection = collection
+	fn.disconnect = disconnect
+	return nil
+}
+
+func (fn *collectionFn) Teardown(ctx context.Context) error {
+	if fn.disconnect == nil {
+		return nil
+	}
+	return fn.disconnect(ctx)
+}
+
+// filter returns the user filter, restricted to the given _id range.
+func (fn *collectionFn) filter(rng idRange) (bson.D, error) {
+	var filter bson.D
+	if len(fn.Filter) > 0 {
+		if err := bson.Unmarshal(fn.Filter, &filter); err != nil {
+			return nil, errors.Wrap(err, "mongodbio failed to decode filter")
+		}
+	}
+	idFilter, err := rng.filter()
+	if err != nil {
+		return nil, err
+	}
+	if len(idFilter) == 0 {
+		return filter, nil
+	}
+	if len(filter) == 0 {
+		return bson.D{{Key: "_id", Value: idFilter}}, nil
+	}
+	return bson.D{{Key: "$and", Value: bson.A{filter, bson.D{{Key: "_id", Value: idFilter}}}}}, nil
+}
+
+// idRange is the range of _ids [Min, Max). Each bound is a BSON document
+// {v: <_id>}, or empty if the range is unbounded on that side.
+type idRange struct {
+	Min []byte
+	Max []byte
+}
+
+// idRanges are the ascending _id ranges covering a collection.
+type idRanges struct {
+	Ranges []idRange
+}
+
+func encodeID(id bson.RawValue) ([]byte, error) {
+	return bson.Marshal(bson.D{{Key: "v", Value: id}})
+}
+
+func decodeID(b []byte) (bson.RawValue, error) {
+	v, err := bson.Raw(b).LookupErr("v")
+	if err != nil {
+		return bson.RawValue{}, errors.Wrap(err, "mongodbio failed to decode _id bound")
+	}
+	return v, nil
+}
+
+// filter returns the _id condition for the range, or nil if unbounded.
+func (r idRange) filter() (bson.D, error) {
+	var cond bson.D
+	if len(r.Min) > 0 {
+		min, err := decodeID(r.Min)
+		if err != nil {
+			return nil, err
+		}
+		cond = append(cond, bson.E{Key: "$gte", Value: min})
+	}
+	if len(r.Max) > 0 {
+		max, err := decodeID(r.Max)
+		if err != nil {
+			return nil, err
+		}
+		cond = append(cond, bson.E{Key: "$lt", Value: max})
+	}
+	return cond, nil
+}
+
+// rangesFromSplitPoints returns the ranges covering all _ids, split at the
+// given ascending split points.
+func rangesFromSplitPoints(points []bson.RawValue) ([]idRange, error) {
+	ranges := make([]idRange, 0, len(points)+1)
+	var prev []byte
+	for _, p := range points {
+		b, err := encodeID(p)
+		if err != nil {
+			return nil, err
+		}
+		if prev != nil && bson.Raw(prev).Lookup("v").Equal(p) {
+			continue // Skip duplicate points, which would yield empty ranges.
+		}
+		ranges = append(ranges, idRange{Min: prev, Max: b})
+		prev = b
+	}
+	return append(ranges, idRange{Min: prev}), nil
+}
+
+// splitFn computes the _id ranges the collection is read in, and emits them
+// as a single element.
+type splitFn struct {
+	collectionFn
+	NumSplits  int  `json:"numSplits"`
+	BucketAuto bool `json:"bucketAuto"`
+}
+
+func (fn *splitFn) ProcessElement(ctx context.Context, _ []byte, emit func(idRanges)) error {
+	var points []bson.RawValue
+	var err error
+	if fn.NumSplits > 1 {
+		if fn.BucketAuto {
+			points, err = fn.bucketAutoSplitPoints(ctx)
+		} else {
+			points, err = fn.sampleSplitPoints(ctx)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	ranges, err := rangesFromSplitPoints(points)
+	if err != nil {
+		return err
+	}
+	log.Infof(ctx, "mongodbio reading %v.%v in %v range(s)", fn.Database, fn.Collection, len(ranges))
+	emit(idRanges{Ranges: ranges})
+	return nil
+}
+
+func (fn *splitFn) match() (bson.D, error) {
+	filter, err := fn.filter(idRange{})
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = bson.D{}
+	}
+	return bson.D{{Key: "$match", Value: filter}}, nil
+}
+
+// bucketAutoSplitPoints returns the lower bounds of all but the first of
+// NumSplits evenly sized buckets of _ids.
+func (fn *splitFn) bucketAutoSplitPoints(ctx context.Context) ([]bson.RawValue, error) {
+	match, err := fn.match()
+	if err != nil {
+		return nil, err
+	}
+	pipeline := mongo.Pipeline{
+		match,
+		{{Key: "$bucketAuto", Value: bson.D{{Key: "groupBy", Value: "$_id"}, {Key: "buckets", Value: fn.NumSplits}}}},
+	}
+	cursor, err := fn.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, errors.Wrap(err, "mongodbio failed to compute buckets")
+	}
+	defer cursor.Close(ctx)
+	var points []bson.RawValue
+	for first := true; cursor.Next(ctx); first = false {
+		if first {
+			continue
+		}
+		points = append(points, cursor.Current.Lookup("_id", "min"))
+	}
+	return points, cursor.Err()
+}
+
+// sampleSplitPoints samples _ids and returns NumSplits-1 evenly spaced
+// sampled ones as split points.
+func (fn *splitFn) sampleSplitPoints(ctx context.Context) ([]bson.RawValue, error) {
+	match, err := fn.match()
+	if err != nil {
+		return nil, err
+	}
+	pipeline := mongo.Pipeline{
+		match,
+		{{Key: "$sample", Value: bson.D{{Key: "size", Value: fn.NumSplits * samplesPerSplit}}}},
+		{{Key: "$project", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+	cursor, err := fn.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, errors.Wrap(err, "mongodbio failed to sample _ids")
+	}
+	defer cursor.Close(ctx)
+	var ids []bson.RawValue
+	for cursor.Next(ctx) {
+		ids = append(ids, cursor.Current.Lookup("_id"))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return evenlySpaced(ids, fn.NumSplits), nil
+}
+
+// evenlySpaced picks up to n-1 evenly spaced split points from the sorted ids.
+func evenlySpaced(ids []bson.RawValue, n int) []bson.RawValue {
+	if len(ids) < n {
+		n = len(ids)
+	}
+	var points []bson.RawValue
+	for i := 1; i < n; i++ {
+		points = append(points, ids[i*len(ids)/n])
+	}
+	return points
+}
+
+// readFn reads the documents in _id ranges. It is implemented as an SDF whose
+// positions are the indices of the ranges, as elasticsearchio reads slices.
+type readFn struct {
+	collectionFn
+	Type beam.EncodedType `json:"type"`
+}
+
+// CreateInitialRestriction covers all ranges.
+func (fn *readFn) CreateInitialRestriction(rs idRanges) offsetrange.Restriction {
+	return offsetrange.Restriction{Start: 0, End: int64(len(rs.Ranges))}
+}
+
+// SplitRestriction splits the restriction into one per range.
+func (fn *readFn) SplitRestriction(_ idRanges, rest offsetrange.Restriction) []offsetrange.Restriction {
+	return rest.SizedSplits(1)
+}
+
+// RestrictionSize returns the number of ranges in the restriction.
+func (fn *readFn) RestrictionSize(_ idRanges, rest offsetrange.Restriction) float64 {
+	return rest.Size()
+}
+
+// CreateTracker creates sdf.LockRTrackers wrapping offsetRange.Trackers for
+// each restriction.
+func (fn *readFn) CreateTracker(rest offsetrange.Restriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(offsetrange.NewTracker(rest))
+}
+
+// ProcessElement claims the ranges of the restriction one by one, and emits
+// all documents of each claimed range.
+func (fn *readFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, rs idRanges, emit func(beam.X)) error {
+	for i := rt.GetRestriction().(offsetrange.Restriction).Start; rt.TryClaim(i); i++ {
+		if err := fn.readRange(ctx, rs.Ranges[i], emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRange emits the documents in the _id range.
+func (fn *readFn) readRange(ctx context.Context, rng idRange, emit func(beam.X)) error {
+	filter, err := fn.filter(rng)
+	if err != nil {
+		return err
+	}
+	if filter == nil {
+		filter = bson.D{}
+	}
+	cursor, err := fn.collection.Find(ctx, filter)
+	if err != nil {
+		return errors.Wrapf(err, "mongodbio failed to query %v.%v", fn.Database, fn.Collection)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		doc := reflect.New(fn.Type.T)
+		if err := cursor.Decode(doc.Interface()); err != nil {
+			return errors.Wrapf(err, "mongodbio failed to decode document into %v", fn.Type.T)
+		}
+		emit(doc.Elem().Interface())
+	}
+	return cursor.Err()
+}
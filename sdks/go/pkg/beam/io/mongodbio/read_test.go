@@ -0,0 +1,282 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodbio
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/direct"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// fakeCollection is an in-memory collection of documents with int32 _ids.
+// It supports the filters and aggregations used by Read.
+type fakeCollection struct {
+	docs []bson.D
+}
+
+type event struct {
+	ID   int32  `bson:"_id"`
+	Kind string `bson:"kind"`
+}
+
+func newFakeCollection(t *testing.T, n int) *fakeCollection {
+	c := &fakeCollection{}
+	for i := int32(0); i < int32(n); i++ {
+		kind := "a"
+		if i%3 == 0 {
+			kind = "b"
+		}
+		c.docs = append(c.docs, bson.D{{Key: "_id", Value: i}, {Key: "kind", Value: kind}})
+	}
+	open := openCollection
+	openCollection = func(context.Context, string, string, string) (mongoCollection, func(context.Context) error, error) {
+		return c, nil, nil
+	}
+	t.Cleanup(func() { openCollection = open })
+	return c
+}
+
+// matches evaluates the subset of the query language produced by the DoFns:
+// $and, equality, and $gte and $lt conditions on _id.
+func matches(filter bson.D, doc bson.D) bool {
+	fields := doc.Map()
+	for _, e := range filter {
+		switch {
+		case e.Key == "$and":
+			for _, sub := range e.Value.(bson.A) {
+				if !matches(sub.(bson.D), doc) {
+					return false
+				}
+			}
+		case e.Key == "_id":
+			id := fields["_id"].(int32)
+			for _, cond := range e.Value.(bson.D) {
+				bound := cond.Value.(bson.RawValue).Int32()
+				if cond.Key == "$gte" && id < bound || cond.Key == "$lt" && id >= bound {
+					return false
+				}
+			}
+		default:
+			if fields[e.Key] != e.Value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *fakeCollection) find(filter bson.D) []bson.D {
+	var ret []bson.D
+	for _, doc := range c.docs {
+		if matches(filter, doc) {
+			ret = append(ret, doc)
+		}
+	}
+	return ret
+}
+
+func cursor(docs []interface{}) (*mongo.Cursor, error) {
+	return mongo.NewCursorFromDocuments(docs, nil, nil)
+}
+
+func (c *fakeCollection) Find(_ context.Context, filter interface{}, _ ...*options.FindOptions) (*mongo.Cursor, error) {
+	var docs []interface{}
+	for _, doc := range c.find(filter.(bson.D)) {
+		docs = append(docs, doc)
+	}
+	return cursor(docs)
+}
+
+// Aggregate returns all matching _ids for $sample, and evenly sized buckets
+// for $bucketAuto.
+func (c *fakeCollection) Aggregate(_ context.Context, pipeline interface{}, _ ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	stages := pipeline.(mongo.Pipeline)
+	matched := c.find(stages[0][0].Value.(bson.D))
+	var docs []interface{}
+	switch stages[1][0].Key {
+	case "$sample":
+		for _, doc := range matched {
+			docs = append(docs, bson.D{doc[0]})
+		}
+	case "$bucketAuto":
+		n := stages[1][0].Value.(bson.D).Map()["buckets"].(int)
+		for i := 0; i < n && i < len(matched); i++ {
+			first, last := matched[i*len(matched)/n], matched[(i+1)*len(matched)/n-1]
+			docs = append(docs, bson.D{{Key: "_id", Value: bson.D{{Key: "min", Value: first[0].Value}, {Key: "max", Value: last[0].Value}}}})
+		}
+	}
+	return cursor(docs)
+}
+
+func (c *fakeCollection) BulkWrite(context.Context, []mongo.WriteModel, ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	return &mongo.BulkWriteResult{}, nil
+}
+
+func intID(i int32) bson.RawValue {
+	return bson.RawValue{Type: bsontype.Int32, Value: bsoncore.AppendInt32(nil, i)}
+}
+
+func TestRangesFromSplitPoints(t *testing.T) {
+	ranges, err := rangesFromSplitPoints([]bson.RawValue{intID(10), intID(10), intID(20)})
+	if err != nil {
+		t.Fatalf("rangesFromSplitPoints failed: %v", err)
+	}
+	want := []string{
+		`{"_id": {"$lt": {"$numberInt":"10"}}}`,
+		`{"_id": {"$gte": {"$numberInt":"10"},"$lt": {"$numberInt":"20"}}}`,
+		`{"_id": {"$gte": {"$numberInt":"20"}}}`,
+	}
+	if len(ranges) != len(want) {
+		t.Fatalf("got %v ranges, want %v", len(ranges), len(want))
+	}
+	fn := &collectionFn{}
+	for i, rng := range ranges {
+		filter, err := fn.filter(rng)
+		if err != nil {
+			t.Fatalf("filter(%v) failed: %v", i, err)
+		}
+		b, _ := bson.Marshal(filter)
+		if got := bson.Raw(b).String(); got != want[i] {
+			t.Errorf("range %v filter = %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestCollectionFn_filter(t *testing.T) {
+	userFilter, err := encodeFilter(bson.M{"kind": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := &collectionFn{Filter: userFilter}
+	ranges, _ := rangesFromSplitPoints([]bson.RawValue{intID(5)})
+
+	filter, err := fn.filter(ranges[0])
+	if err != nil {
+		t.Fatalf("filter failed: %v", err)
+	}
+	b, _ := bson.Marshal(filter)
+	if got, want := bson.Raw(b).String(), `{"$and": [{"kind": "a"},{"_id": {"$lt": {"$numberInt":"5"}}}]}`; got != want {
+		t.Errorf("filter = %v, want %v", got, want)
+	}
+}
+
+func TestEvenlySpaced(t *testing.T) {
+	var ids []bson.RawValue
+	for i := int32(0); i < 100; i++ {
+		ids = append(ids, intID(i))
+	}
+	points := evenlySpaced(ids, 4)
+	var got []int32
+	for _, p := range points {
+		got = append(got, p.Int32())
+	}
+	if want := []int32{25, 50, 75}; len(got) != len(want) || got[0] != 25 || got[1] != 50 || got[2] != 75 {
+		t.Errorf("evenlySpaced(0..99, 4) = %v, want %v", got, want)
+	}
+	if got := evenlySpaced(ids[:2], 10); len(got) != 1 {
+		t.Errorf("evenlySpaced of 2 ids into 10 gave %v points, want 1", len(got))
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []func(*ReadOptions) error
+		want    func(i int32) bool
+	}{
+		{"sample", []func(*ReadOptions) error{WithReadNumSplits(4)}, func(int32) bool { return true }},
+		{"bucketAuto", []func(*ReadOptions) error{WithReadNumSplits(4), WithReadBucketAuto()}, func(int32) bool { return true }},
+		{"filter", []func(*ReadOptions) error{WithReadNumSplits(3), WithReadFilter(bson.M{"kind": "b"})}, func(i int32) bool { return i%3 == 0 }},
+		{"unsplit", []func(*ReadOptions) error{WithReadNumSplits(1)}, func(int32) bool { return true }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newFakeCollection(t, 50)
+			p, s := beam.NewPipelineWithRoot()
+			read := Read(s, "mongodb://fake", "db", "events", reflect.TypeOf(event{}), test.options...)
+			var want []interface{}
+			for i := int32(0); i < 50; i++ {
+				if test.want(i) {
+					want = append(want, int(i))
+				}
+			}
+			ids := beam.ParDo(s, func(e event) int { return int(e.ID) }, read)
+			passert.Equals(s, ids, want...)
+			ptest.RunAndValidate(t, p)
+		})
+	}
+}
+
+func TestReadFn_checkpoint(t *testing.T) {
+	newFakeCollection(t, 30)
+	ctx := context.Background()
+	split := &splitFn{collectionFn: collectionFn{}, NumSplits: 3}
+	split.Setup(ctx)
+	var rs idRanges
+	if err := split.ProcessElement(ctx, nil, func(r idRanges) { rs = r }); err != nil {
+		t.Fatalf("splitFn failed: %v", err)
+	}
+	if got, want := len(rs.Ranges), 3; got != want {
+		t.Fatalf("got %v ranges, want %v", got, want)
+	}
+
+	fn := &readFn{Type: beam.EncodedType{T: reflect.TypeOf(event{})}}
+	fn.Setup(ctx)
+	rest := fn.CreateInitialRestriction(rs)
+	if got, want := fn.SplitRestriction(rs, rest), []offsetrange.Restriction{{Start: 0, End: 1}, {Start: 1, End: 2}, {Start: 2, End: 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitRestriction() = %v, want %v", got, want)
+	}
+
+	// Checkpoint after the first document: the claimed range is finished,
+	// and the remaining ones are deferred to the residual.
+	rt := fn.CreateTracker(rest)
+	var ids []int
+	var residual interface{}
+	err := fn.ProcessElement(ctx, rt, rs, func(x beam.X) {
+		ids = append(ids, int(x.(event).ID))
+		if len(ids) == 1 {
+			var err error
+			if _, residual, err = rt.TrySplit(0); err != nil {
+				t.Fatalf("TrySplit failed: %v", err)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatalf("ProcessElement failed: %v", err)
+	}
+	sort.Ints(ids)
+	if len(ids) != 10 || ids[0] != 0 || ids[9] != 9 {
+		t.Errorf("read %v, want 0 to 9", ids)
+	}
+	if got, want := residual, (offsetrange.Restriction{Start: 1, End: 3}); got != want {
+		t.Errorf("residual = %v, want %v", got, want)
+	}
+	if !rt.IsDone() {
+		t.Error("tracker is not done after ProcessElement")
+	}
+}
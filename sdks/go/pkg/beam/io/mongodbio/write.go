@@ -0,0 +1,143 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodbio
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*writeFn)(nil)).Elem())
+}
+
+// defaultBatchSize is the default number of documents per bulk write.
+const defaultBatchSize = 1000
+
+// WriteOptions represents options for writing to MongoDB.
+type WriteOptions struct {
+	// BatchSize is the number of documents per bulk write. Defaults to 1000.
+	BatchSize int
+	// Ordered stops a bulk write at the first failing document. Otherwise the
+	// remaining documents are still written.
+	Ordered bool
+	// Upsert replaces documents with the same _id, or inserts them if they do
+	// not exist. Documents must have an _id. Otherwise documents are inserted.
+	Upsert bool
+}
+
+// WithWriteBatchSize sets the number of documents per bulk write.
+func WithWriteBatchSize(batchSize int) func(*WriteOptions) error {
+	return func(wo *WriteOptions) error {
+		if batchSize <= 0 {
+			return errors.Errorf("batch size must be positive, got %v", batchSize)
+		}
+		wo.BatchSize = batchSize
+		return nil
+	}
+}
+
+// WithWriteOrdered makes bulk writes stop at the first failing document.
+func WithWriteOrdered() func(*WriteOptions) error {
+	return func(wo *WriteOptions) error {
+		wo.Ordered = true
+		return nil
+	}
+}
+
+// WithWriteUpsert replaces documents by _id instead of inserting them.
+func WithWriteUpsert() func(*WriteOptions) error {
+	return func(wo *WriteOptions) error {
+		wo.Upsert = true
+		return nil
+	}
+}
+
+// Write writes the elements of a PCollection<T> as documents into the
+// collection, using bulk writes. Elements are encoded with the BSON codecs of
+// the MongoDB driver, so T may be a struct with `bson` tags, a bson.M or a
+// bson.D. Any failed write fails the bundle.
+func Write(s beam.Scope, uri, database, collection string, col beam.PCollection, options ...func(*WriteOptions) error) {
+	s = s.Scope("mongodbio.Write")
+
+	writeOptions := WriteOptions{BatchSize: defaultBatchSize}
+	for _, opt := range options {
+		if err := opt(&writeOptions); err != nil {
+			panic(err)
+		}
+	}
+	beam.ParDo0(s, &writeFn{collectionFn: collectionFn{URI: uri, Database: database, Collection: collection}, Options: writeOptions}, col)
+}
+
+type writeFn struct {
+	collectionFn
+	Options WriteOptions `json:"options"`
+
+	models []mongo.WriteModel
+}
+
+func (fn *writeFn) ProcessElement(ctx context.Context, elm beam.X) error {
+	model, err := newWriteModel(elm, fn.Options.Upsert)
+	if err != nil {
+		return err
+	}
+	fn.models = append(fn.models, model)
+	if len(fn.models) >= fn.Options.BatchSize {
+		return fn.flush(ctx)
+	}
+	return nil
+}
+
+func (fn *writeFn) FinishBundle(ctx context.Context) error {
+	return fn.flush(ctx)
+}
+
+func (fn *writeFn) flush(ctx context.Context) error {
+	if len(fn.models) == 0 {
+		return nil
+	}
+	opts := options.BulkWrite().SetOrdered(fn.Options.Ordered)
+	if _, err := fn.collection.BulkWrite(ctx, fn.models, opts); err != nil {
+		return errors.Wrapf(err, "mongodbio failed to write %v document(s) into %v.%v", len(fn.models), fn.Database, fn.Collection)
+	}
+	fn.models = nil
+	return nil
+}
+
+// newWriteModel returns the bulk write operation for the element.
+func newWriteModel(elm interface{}, upsert bool) (mongo.WriteModel, error) {
+	doc, err := bson.Marshal(elm)
+	if err != nil {
+		return nil, errors.Wrapf(err, "mongodbio failed to encode %T", elm)
+	}
+	if !upsert {
+		return mongo.NewInsertOneModel().SetDocument(bson.Raw(doc)), nil
+	}
+	id, err := bson.Raw(doc).LookupErr("_id")
+	if err != nil {
+		return nil, errors.Errorf("mongodbio upsert requires an _id in %T", elm)
+	}
+	return mongo.NewReplaceOneModel().
+		SetFilter(bson.D{{Key: "_id", Value: id}}).
+		SetReplacement(bson.Raw(doc)).
+		SetUpsert(true), nil
+}
@@ -0,0 +1,58 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodbio
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type doc struct {
+	ID   string `bson:"_id,omitempty"`
+	Name string `bson:"name"`
+}
+
+func TestNewWriteModel(t *testing.T) {
+	model, err := newWriteModel(doc{ID: "a", Name: "x"}, false)
+	if err != nil {
+		t.Fatalf("newWriteModel failed: %v", err)
+	}
+	if _, ok := model.(*mongo.InsertOneModel); !ok {
+		t.Errorf("newWriteModel without upsert = %T, want *mongo.InsertOneModel", model)
+	}
+
+	model, err = newWriteModel(doc{ID: "a", Name: "x"}, true)
+	if err != nil {
+		t.Fatalf("newWriteModel with upsert failed: %v", err)
+	}
+	replace, ok := model.(*mongo.ReplaceOneModel)
+	if !ok {
+		t.Fatalf("newWriteModel with upsert = %T, want *mongo.ReplaceOneModel", model)
+	}
+	if replace.Upsert == nil || !*replace.Upsert {
+		t.Error("ReplaceOneModel.Upsert not set")
+	}
+	b, _ := bson.Marshal(replace.Filter)
+	if got, want := bson.Raw(b).String(), `{"_id": "a"}`; got != want {
+		t.Errorf("ReplaceOneModel.Filter = %v, want %v", got, want)
+	}
+
+	if _, err := newWriteModel(doc{Name: "no id"}, true); err == nil {
+		t.Error("newWriteModel with upsert and no _id succeeded, want error")
+	}
+}
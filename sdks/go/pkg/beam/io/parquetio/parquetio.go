@@ -18,19 +18,24 @@ package parquetio
 
 import (
 	"context"
-	"io/ioutil"
+	"io"
 	"reflect"
 	"strings"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
 	"github.com/xitongsys/parquet-go-source/buffer"
 	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
 	"github.com/xitongsys/parquet-go/writer"
 )
 
 func init() {
 	beam.RegisterFunction(expandFn)
+	beam.RegisterFunction(rowGroupsFn)
 	beam.RegisterType(reflect.TypeOf((*parquetReadFn)(nil)).Elem())
 	beam.RegisterType(reflect.TypeOf((*parquetWriteFn)(nil)).Elem())
 }
@@ -47,6 +52,9 @@ func init() {
 //   Day     int32   `parquet:"name=day, type=INT32, convertedtype=DATE"`
 //   Ignored int32   //without parquet tag and won't write
 // }
+//
+// Files are split by row group, so row groups of a single file may be read
+// in parallel.
 func Read(s beam.Scope, glob string, t reflect.Type) beam.PCollection {
 	s = s.Scope("parquetio.Read")
 	filesystem.ValidateScheme(glob)
@@ -55,9 +63,10 @@ func Read(s beam.Scope, glob string, t reflect.Type) beam.PCollection {
 
 func read(s beam.Scope, t reflect.Type, col beam.PCollection) beam.PCollection {
 	files := beam.ParDo(s, expandFn, col)
+	groups := beam.ParDo(s, rowGroupsFn, files)
 	return beam.ParDo(s,
 		&parquetReadFn{Type: beam.EncodedType{T: t}},
-		files,
+		groups,
 		beam.TypeDefinition{Var: beam.XType, T: t},
 	)
 }
@@ -83,43 +92,179 @@ func expandFn(ctx context.Context, glob string, emit func(string)) error {
 	return nil
 }
 
+// unknownRowGroups is the number of row groups of files on filesystems
+// without ranged reads. Reading the footer of such files would read them
+// fully, so they are read whole by a single restriction instead.
+const unknownRowGroups = -1
+
+// rowGroupsFn pairs a filename with the number of row groups in that file,
+// read from the file footer, or unknownRowGroups if the filesystem can't read
+// the footer on its own.
+func rowGroupsFn(ctx context.Context, filename string) (string, int64, error) {
+	fs, err := filesystem.New(ctx, filename)
+	if err != nil {
+		return "", -1, err
+	}
+	defer fs.Close()
+
+	if _, ok := fs.(filesystem.RangeReader); !ok {
+		return filename, unknownRowGroups, nil
+	}
+	pf, err := openParquetFile(ctx, fs, filename)
+	if err != nil {
+		return "", -1, err
+	}
+	defer pf.Close()
+
+	// Only the footer is needed, so avoid NewParquetReader which also opens
+	// every column.
+	pr := &reader.ParquetReader{PFile: pf}
+	if err := pr.ReadFooter(); err != nil {
+		return "", -1, errors.Wrapf(err, "failed to read parquet footer of %v", filename)
+	}
+	return filename, int64(len(pr.Footer.GetRowGroups())), nil
+}
+
+// parquetReadFn reads the rows of a parquet file, given a filename and the
+// number of row groups in that file. Implemented as an SDF over row group
+// indices to allow splitting within a file.
 type parquetReadFn struct {
 	Type beam.EncodedType
 }
 
-func (a *parquetReadFn) ProcessElement(ctx context.Context, filename string, emit func(beam.X)) error {
+// CreateInitialRestriction creates an offset range restriction over the row
+// groups of the file, or a single offset for the whole file if the number of
+// row groups is unknown.
+func (a *parquetReadFn) CreateInitialRestriction(_ string, rowGroups int64) offsetrange.Restriction {
+	if rowGroups == unknownRowGroups {
+		return offsetrange.Restriction{Start: 0, End: 1}
+	}
+	return offsetrange.Restriction{
+		Start: 0,
+		End:   rowGroups,
+	}
+}
+
+// SplitRestriction splits each file restriction into one restriction per row
+// group.
+func (a *parquetReadFn) SplitRestriction(_ string, _ int64, rest offsetrange.Restriction) []offsetrange.Restriction {
+	return rest.SizedSplits(1)
+}
+
+// RestrictionSize returns the size of each restriction as its number of row
+// groups.
+func (a *parquetReadFn) RestrictionSize(_ string, _ int64, rest offsetrange.Restriction) float64 {
+	return rest.Size()
+}
+
+// CreateTracker creates sdf.LockRTrackers wrapping offsetRange.Trackers for
+// each restriction.
+func (a *parquetReadFn) CreateTracker(rest offsetrange.Restriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(offsetrange.NewTracker(rest))
+}
+
+// ProcessElement outputs all rows of the row groups in the restriction. Rows
+// of preceding row groups are skipped without being decoded into values.
+func (a *parquetReadFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, filename string, numRowGroups int64, emit func(beam.X)) error {
 	fs, err := filesystem.New(ctx, filename)
 	if err != nil {
 		return err
 	}
 	defer fs.Close()
 
-	fd, err := fs.OpenRead(ctx, filename)
+	pf, err := openParquetFile(ctx, fs, filename)
 	if err != nil {
 		return err
 	}
-	defer fd.Close()
+	defer pf.Close()
 
-	data, err := ioutil.ReadAll(fd)
+	parquetReader, err := reader.NewParquetReader(pf, reflect.New(a.Type.T).Interface(), 4)
 	if err != nil {
 		return err
 	}
+	defer parquetReader.ReadStop()
 
-	bufferReader := buffer.NewBufferFileFromBytes(data)
-	parquetReader, err := reader.NewParquetReader(bufferReader, reflect.New(a.Type.T).Interface(), 4)
-	if err != nil {
+	if numRowGroups == unknownRowGroups {
+		if !rt.TryClaim(int64(0)) {
+			return nil
+		}
+		vals, err := parquetReader.ReadByNumber(int(parquetReader.GetNumRows()))
+		if err != nil {
+			return err
+		}
+		for _, v := range vals {
+			emit(v)
+		}
+		return nil
+	}
+
+	rowGroups := parquetReader.Footer.GetRowGroups()
+	start := rt.GetRestriction().(offsetrange.Restriction).Start
+	var skip int64
+	for _, rg := range rowGroups[:start] {
+		skip += rg.GetNumRows()
+	}
+	if err := parquetReader.SkipRows(skip); err != nil {
 		return err
 	}
 
-	vals, err := parquetReader.ReadByNumber(int(parquetReader.GetNumRows()))
+	for i := start; rt.TryClaim(i); i++ {
+		if i >= int64(len(rowGroups)) {
+			return errors.Errorf("row group %d is outside of %v, which has %d row groups", i, filename, len(rowGroups))
+		}
+		vals, err := parquetReader.ReadByNumber(int(rowGroups[i].GetNumRows()))
+		if err != nil {
+			return err
+		}
+		for _, v := range vals {
+			emit(v)
+		}
+	}
+	return nil
+}
+
+// openParquetFile opens the file for random access reads by the parquet
+// reader. Filesystems without ranged reads have the file read into memory
+// once, rather than once per column, so such files are only opened by the
+// single restriction that reads them whole.
+func openParquetFile(ctx context.Context, fs filesystem.Interface, filename string) (source.ParquetFile, error) {
+	if _, ok := fs.(filesystem.RangeReader); !ok {
+		data, err := filesystem.Read(ctx, fs, filename)
+		if err != nil {
+			return nil, err
+		}
+		return buffer.NewBufferFileFromBytes(data), nil
+	}
+	r, err := filesystem.OpenReadSeeker(ctx, fs, filename)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, v := range vals {
-		emit(v)
+	return &parquetFile{ctx: ctx, fs: fs, filename: filename, ReadSeekCloser: r}, nil
+}
+
+// parquetFile adapts a read-only file in a Beam filesystem to the
+// source.ParquetFile interface. The parquet reader opens the file once per
+// column, with each column reader seeking independently.
+type parquetFile struct {
+	io.ReadSeekCloser
+	ctx      context.Context
+	fs       filesystem.Interface
+	filename string
+}
+
+func (f *parquetFile) Open(name string) (source.ParquetFile, error) {
+	if name == "" {
+		name = f.filename
 	}
+	return openParquetFile(f.ctx, f.fs, name)
+}
 
-	return nil
+func (f *parquetFile) Create(string) (source.ParquetFile, error) {
+	return nil, errors.Errorf("parquet file %v is read only", f.filename)
+}
+
+func (f *parquetFile) Write([]byte) (int, error) {
+	return 0, errors.Errorf("parquet file %v is read only", f.filename)
 }
 
 // Write writes a PCollection<parquetStruct> to .parquet file.
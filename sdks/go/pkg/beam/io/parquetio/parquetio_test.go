@@ -16,17 +16,24 @@
 package parquetio
 
 import (
+	"context"
 	"errors"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
 	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/local"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
 	"github.com/xitongsys/parquet-go-source/local"
 	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
 )
 
 type Student struct {
@@ -120,3 +127,121 @@ func TestWrite(t *testing.T) {
 		t.Fatalf("students differs from studentList. got %+v, expected %+v", students, studentList)
 	}
 }
+
+// writeStudents writes a parquet file of 1000 students in 10 row groups, and
+// returns the students.
+func writeStudents(t *testing.T, parquetFile string) []interface{} {
+	t.Helper()
+	fw, err := local.NewLocalFileWriter(parquetFile)
+	if err != nil {
+		t.Fatalf("Failed to create file %v. err: %v", parquetFile, err)
+	}
+	pw, err := writer.NewParquetWriter(fw, new(Student), 1)
+	if err != nil {
+		t.Fatalf("Failed to create parquet writer. err: %v", err)
+	}
+	const numStudents = 1000
+	var want []interface{}
+	for i := 0; i < numStudents; i++ {
+		s := Student{Name: "StudentName", Age: int32(i % 30), Id: int64(i), Day: 19089}
+		if err := pw.Write(s); err != nil {
+			t.Fatalf("Failed to write student %d. err: %v", i, err)
+		}
+		want = append(want, s)
+		if i%100 == 99 {
+			// Close the current row group.
+			if err := pw.Flush(true); err != nil {
+				t.Fatalf("Failed to flush row group. err: %v", err)
+			}
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		t.Fatalf("Failed to finish parquet file. err: %v", err)
+	}
+	fw.Close()
+	return want
+}
+
+func TestRead_multipleRowGroups(t *testing.T) {
+	parquetFile := filepath.Join(t.TempDir(), "students.parquet")
+	want := writeStudents(t, parquetFile)
+
+	_, rowGroups, err := rowGroupsFn(context.Background(), parquetFile)
+	if err != nil {
+		t.Fatalf("rowGroupsFn(%v) failed: %v", parquetFile, err)
+	}
+	if rowGroups != 10 {
+		t.Fatalf("rowGroupsFn(%v) = %v row groups, want 10", parquetFile, rowGroups)
+	}
+
+	p := beam.NewPipeline()
+	s := p.Root()
+	students := Read(s, parquetFile, reflect.TypeOf(Student{}))
+	passert.Equals(s, students, want...)
+	ptest.RunAndValidate(t, p)
+}
+
+func init() {
+	filesystem.Register(noRangeScheme, func(context.Context) filesystem.Interface { return noRangeFS{} })
+}
+
+const noRangeScheme = "norange"
+
+// noRangeOpens counts the files opened by noRangeFS.
+var noRangeOpens int32
+
+// noRangeFS is a local filesystem whose files can only be read sequentially.
+type noRangeFS struct{}
+
+func (noRangeFS) path(filename string) string {
+	return strings.TrimPrefix(filename, noRangeScheme+"://")
+}
+
+func (noRangeFS) Close() error { return nil }
+
+func (f noRangeFS) List(_ context.Context, glob string) ([]string, error) {
+	files, err := filepath.Glob(f.path(glob))
+	for i := range files {
+		files[i] = noRangeScheme + "://" + files[i]
+	}
+	return files, err
+}
+
+func (f noRangeFS) OpenRead(_ context.Context, filename string) (io.ReadCloser, error) {
+	atomic.AddInt32(&noRangeOpens, 1)
+	fd, err := os.Open(f.path(filename))
+	if err != nil {
+		return nil, err
+	}
+	// Hide the io.Seeker implementation of the file.
+	return struct{ io.ReadCloser }{fd}, nil
+}
+
+func (f noRangeFS) OpenWrite(_ context.Context, filename string) (io.WriteCloser, error) {
+	return nil, errors.New("read only")
+}
+
+func (f noRangeFS) Size(_ context.Context, filename string) (int64, error) {
+	info, err := os.Stat(f.path(filename))
+	if err != nil {
+		return -1, err
+	}
+	return info.Size(), nil
+}
+
+func TestRead_noRangeReader(t *testing.T) {
+	parquetFile := noRangeScheme + "://" + filepath.Join(t.TempDir(), "students.parquet")
+	want := writeStudents(t, noRangeFS{}.path(parquetFile))
+	atomic.StoreInt32(&noRangeOpens, 0)
+
+	p := beam.NewPipeline()
+	s := p.Root()
+	students := Read(s, parquetFile, reflect.TypeOf(Student{}))
+	passert.Equals(s, students, want...)
+	ptest.RunAndValidate(t, p)
+
+	// Files without ranged reads are only read once, by a single restriction.
+	if got := atomic.LoadInt32(&noRangeOpens); got != 1 {
+		t.Errorf("Read(%v) opened the file %v times, want 1", parquetFile, got)
+	}
+}
@@ -0,0 +1,218 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisio
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/funcx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*lookupFn)(nil)).Elem())
+}
+
+var (
+	keySig = &funcx.Signature{Args: []reflect.Type{beam.TType}, Return: []reflect.Type{reflectx.String}} // T -> string
+
+	cacheHits   = beam.NewCounter("redisio", "lookup_cache_hits")
+	cacheMisses = beam.NewCounter("redisio", "lookup_cache_misses")
+)
+
+// LookupOptions represents options for enriching elements with Redis values.
+type LookupOptions struct {
+	// CacheSize is the maximum number of keys cached per worker. Defaults to
+	// 10000. A negative size disables caching.
+	CacheSize int
+	// CacheTTL, if positive, is how long a cached value, or the absence of
+	// one, is used before it is looked up again.
+	CacheTTL time.Duration
+	// KeepMissing emits elements whose key does not exist with an empty
+	// value, instead of dropping them.
+	KeepMissing bool
+}
+
+// defaultCacheSize is the default number of keys cached per worker.
+const defaultCacheSize = 10000
+
+// Lookup enriches the elements of a PCollection<T> with the string values of
+// Redis keys. The key of each element is computed by keyFn, which must be of
+// the form T -> string. It returns a PCollection<KV<T,string>> of elements
+// and values. For example:
+//
+//	enriched := redisio.Lookup(s, cfg, events, func(e Event) string {
+//	    return "user:" + e.UserID
+//	}, nil)
+//
+// Values are cached in a least recently used cache shared by all lookups on
+// a worker with the same configuration.
+func Lookup(s beam.Scope, cfg Config, col beam.PCollection, keyFn interface{}, opts *LookupOptions) beam.PCollection {
+	s = s.Scope("redisio.Lookup")
+
+	funcx.MustSatisfy(keyFn, funcx.Replace(keySig, beam.TType, col.Type().Type()))
+	fn := &lookupFn{Config: cfg, KeyFn: beam.EncodedFunc{Fn: reflectx.MakeFunc(keyFn)}, CacheSize: defaultCacheSize}
+	if opts != nil {
+		if opts.CacheSize != 0 {
+			fn.CacheSize = opts.CacheSize
+		}
+		fn.CacheTTL = opts.CacheTTL
+		fn.KeepMissing = opts.KeepMissing
+	}
+	return beam.ParDo(s, fn, col)
+}
+
+type lookupFn struct {
+	Config      Config           `json:"config"`
+	KeyFn       beam.EncodedFunc `json:"keyFn"`
+	CacheSize   int              `json:"cacheSize"`
+	CacheTTL    time.Duration    `json:"cacheTTL"`
+	KeepMissing bool             `json:"keepMissing"`
+
+	fn     reflectx.Func1x1
+	client redis.UniversalClient
+	cache  *lruCache
+}
+
+func (f *lookupFn) Setup() {
+	f.fn = reflectx.ToFunc1x1(f.KeyFn.Fn)
+	f.client = f.Config.newClient()
+	if f.CacheSize > 0 {
+		f.cache = sharedCache(fmt.Sprintf("%v/%v/%v/%v", f.Config, f.Config.DB, f.CacheSize, f.CacheTTL), f.CacheSize, f.CacheTTL)
+	}
+}
+
+func (f *lookupFn) Teardown() error {
+	return f.client.Close()
+}
+
+func (f *lookupFn) ProcessElement(ctx context.Context, elm beam.T, emit func(beam.T, string)) error {
+	key := f.fn.Call1x1(elm).(string)
+	value, ok, err := f.lookup(ctx, key)
+	if err != nil {
+		return err
+	}
+	if ok || f.KeepMissing {
+		emit(elm, value)
+	}
+	return nil
+}
+
+func (f *lookupFn) lookup(ctx context.Context, key string) (string, bool, error) {
+	if f.cache != nil {
+		if value, ok, hit := f.cache.get(key); hit {
+			cacheHits.Inc(ctx, 1)
+			return value, ok, nil
+		}
+		cacheMisses.Inc(ctx, 1)
+	}
+	value, err := f.client.Get(ctx, key).Result()
+	ok := true
+	if err == redis.Nil {
+		value, ok, err = "", false, nil
+	}
+	if err != nil {
+		return "", false, errors.Wrapf(err, "redisio failed to look up %q", key)
+	}
+	if f.cache != nil {
+		f.cache.put(key, value, ok)
+	}
+	return value, ok, nil
+}
+
+var (
+	cachesMu sync.Mutex
+	caches   = make(map[string]*lruCache)
+)
+
+// sharedCache returns the worker wide cache with the given id, creating it
+// if needed.
+func sharedCache(id string, size int, ttl time.Duration) *lruCache {
+	cachesMu.Lock()
+	defer cachesMu.Unlock()
+	if c, ok := caches[id]; ok {
+		return c
+	}
+	c := newLRUCache(size, ttl)
+	caches[id] = c
+	return c
+}
+
+// lruCache is a size bounded cache of lookup results, evicting the least
+// recently used key first. It is safe for concurrent use.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List // of *cacheEntry, most recently used first.
+	items map[string]*list.Element
+	now   func() time.Time
+}
+
+type cacheEntry struct {
+	key     string
+	value   string
+	ok      bool
+	expires time.Time
+}
+
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{size: size, ttl: ttl, order: list.New(), items: make(map[string]*list.Element), now: time.Now}
+}
+
+// get returns the cached value for key, whether the key existed, and whether
+// the cache held a live entry at all.
+func (c *lruCache) get(key string) (value string, ok, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elm, found := c.items[key]
+	if !found {
+		return "", false, false
+	}
+	entry := elm.Value.(*cacheEntry)
+	if c.ttl > 0 && c.now().After(entry.expires) {
+		c.order.Remove(elm)
+		delete(c.items, key)
+		return "", false, false
+	}
+	c.order.MoveToFront(elm)
+	return entry.value, entry.ok, true
+}
+
+func (c *lruCache) put(key, value string, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &cacheEntry{key: key, value: value, ok: ok, expires: c.now().Add(c.ttl)}
+	if elm, found := c.items[key]; found {
+		elm.Value = entry
+		c.order.MoveToFront(elm)
+		return
+	}
+	c.items[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,245 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisio
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"github.com/go-redis/redis/v8"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*readFn)(nil)).Elem())
+}
+
+// defaultBatchSize is the default number of keys fetched or written per round
+// trip.
+const defaultBatchSize = 1000
+
+// ReadOptions represents options for reading from Redis.
+type ReadOptions struct {
+	// BatchSize is the number of keys scanned and fetched per round trip.
+	// Defaults to 1000.
+	BatchSize int
+}
+
+// Read reads all string keys matching the glob-style pattern, as accepted by
+// SCAN MATCH, and returns their values as a PCollection<KV<string,string>>.
+// Keys holding other types than strings are skipped.
+//
+// The read is split by the Redis Cluster hash slot ranges owned by each master
+// node, so that nodes are read in parallel and every node is scanned once.
+// Single servers are read in one split.
+func Read(s beam.Scope, cfg Config, pattern string, opts *ReadOptions) beam.PCollection {
+	s = s.Scope("redisio.Read")
+
+	fn := &readFn{Config: cfg, Pattern: pattern, BatchSize: defaultBatchSize}
+	if opts != nil {
+		if opts.BatchSize > 0 {
+			fn.BatchSize = opts.BatchSize
+		}
+	}
+	imp := beam.Impulse(s)
+	return beam.ParDo(s, fn, imp)
+}
+
+// readFn scans and fetches the keys matching a pattern. It is implemented as
+// an SDF over the range of hash slots.
+type readFn struct {
+	Config    Config `json:"config"`
+	Pattern   string `json:"pattern"`
+	BatchSize int    `json:"batchSize"`
+
+	client redis.UniversalClient
+}
+
+// CreateInitialRestriction covers all hash slots.
+func (fn *readFn) CreateInitialRestriction(_ []byte) offsetrange.Restriction {
+	return offsetrange.Restriction{Start: 0, End: numSlots}
+}
+
+// SplitRestriction splits the slots into the ranges owned by each master
+// node, so that each node is scanned by a single split.
+func (fn *readFn) SplitRestriction(_ []byte, rest offsetrange.Restriction) []offsetrange.Restriction {
+	ranges, err := slotRanges(context.Background(), fn.client)
+	if err != nil {
+		log.Warnf(context.Background(), "redisio failed to get the slots of %v, reading in one split: %v", fn.Config, err)
+		return []offsetrange.Restriction{rest}
+	}
+	var splits []offsetrange.Restriction
+	for _, r := range ranges {
+		if split, ok := intersect(rest, r); ok {
+			splits = append(splits, split)
+		}
+	}
+	return splits
+}
+
+// intersect returns the slots of the restriction owned by the node.
+func intersect(rest offsetrange.Restriction, r slotRange) (offsetrange.Restriction, bool) {
+	if r.Start > rest.Start {
+		rest.Start = r.Start
+	}
+	if r.End < rest.End {
+		rest.End = r.End
+	}
+	return rest, rest.Start < rest.End
+}
+
+// RestrictionSize returns the number of slots in the restriction.
+func (fn *readFn) RestrictionSize(_ []byte, rest offsetrange.Restriction) float64 {
+	return rest.Size()
+}
+
+// CreateTracker creates sdf.LockRTrackers wrapping offsetRange.Trackers for
+// each restriction.
+func (fn *readFn) CreateTracker(rest offsetrange.Restriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(offsetrange.NewTracker(rest))
+}
+
+func (fn *readFn) Setup() {
+	fn.client = fn.Config.newClient()
+}
+
+func (fn *readFn) Teardown() error {
+	return fn.client.Close()
+}
+
+// ProcessElement scans the keyspace for keys in the restriction's slots, then
+// claims the slots in order, emitting the values of their keys.
+func (fn *readFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, _ []byte, emit func(string, string)) error {
+	rest := rt.GetRestriction().(offsetrange.Restriction)
+	bySlot, err := fn.scan(ctx, rest)
+	if err != nil {
+		return err
+	}
+	slots := make([]int64, 0, len(bySlot))
+	for slot := range bySlot {
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+
+	var count int
+	for _, slot := range slots {
+		if !rt.TryClaim(slot) {
+			return nil
+		}
+		keys := bySlot[slot]
+		for len(keys) > 0 {
+			n := fn.BatchSize
+			if n > len(keys) {
+				n = len(keys)
+			}
+			emitted, err := fn.fetch(ctx, keys[:n], emit)
+			if err != nil {
+				return err
+			}
+			count += emitted
+			keys = keys[n:]
+		}
+	}
+	// Finish claiming restriction before returning to avoid errors.
+	rt.TryClaim(rest.End)
+	log.Debugf(ctx, "redisio read %v keys in slots [%v, %v) of %v", count, rest.Start, rest.End, fn.Config)
+	return nil
+}
+
+// scan returns the keys matching the pattern in the restriction's slots,
+// grouped by slot. Only the nodes owning slots of the restriction are
+// scanned.
+func (fn *readFn) scan(ctx context.Context, rest offsetrange.Restriction) (map[int64][]string, error) {
+	ranges, err := slotRanges(ctx, fn.client)
+	if err != nil {
+		return nil, errors.Wrapf(err, "redisio failed to get the slots of %v", fn.Config)
+	}
+	owners := make(map[string]bool)
+	for _, r := range ranges {
+		if _, ok := intersect(rest, r); ok {
+			owners[r.Addr] = true
+		}
+	}
+
+	ch := make(chan []string)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- forEachMaster(ctx, fn.client, func(ctx context.Context, addr string, c redis.Cmdable) error {
+			if !owners[addr] {
+				return nil
+			}
+			iter := c.Scan(ctx, 0, fn.Pattern, int64(fn.BatchSize)).Iterator()
+			var keys []string
+			for iter.Next(ctx) {
+				keys = append(keys, iter.Val())
+			}
+			if err := iter.Err(); err != nil {
+				return err
+			}
+			ch <- keys
+			return nil
+		})
+		close(ch)
+	}()
+	bySlot := make(map[int64][]string)
+	for keys := range ch {
+		for _, key := range keys {
+			if slot := keySlot(key); slot >= rest.Start && slot < rest.End {
+				bySlot[slot] = append(bySlot[slot], key)
+			}
+		}
+	}
+	if err := <-errCh; err != nil {
+		return nil, errors.Wrapf(err, "redisio failed to scan %v for %q", fn.Config, fn.Pattern)
+	}
+	return bySlot, nil
+}
+
+// fetch emits the values of the given keys, which must share a slot, and
+// returns the number of values emitted.
+func (fn *readFn) fetch(ctx context.Context, keys []string, emit func(string, string)) (int, error) {
+	pipe := fn.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, key)
+	}
+	// Errors of individual commands are inspected below.
+	pipe.Exec(ctx)
+	var count int
+	for i, cmd := range cmds {
+		val, err := cmd.Result()
+		if err == redis.Nil || isWrongType(err) {
+			// The key was deleted since the scan, or is not a string.
+			continue
+		}
+		if err != nil {
+			return count, errors.Wrapf(err, "redisio failed to get %q", keys[i])
+		}
+		emit(keys[i], val)
+		count++
+	}
+	return count, nil
+}
+
+func isWrongType(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE")
+}
@@ -0,0 +1,126 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redisio contains transforms for reading from, writing to and
+// enriching elements with data from Redis. Both single servers and Redis
+// Cluster deployments are supported.
+//
+// Experimental.
+package redisio
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Config describes how to connect to Redis.
+type Config struct {
+	// Addrs is the host:port of a single server, or a seed list of cluster
+	// nodes. A cluster client is used if more than one address is given.
+	Addrs []string `json:"addrs"`
+	// Username and Password are used for AUTH, if set.
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// DB is the database selected on single servers.
+	DB int `json:"db"`
+}
+
+func (c Config) String() string {
+	return strings.Join(c.Addrs, ",")
+}
+
+func (c Config) newClient() redis.UniversalClient {
+	return redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:    c.Addrs,
+		Username: c.Username,
+		Password: c.Password,
+		DB:       c.DB,
+	})
+}
+
+// forEachMaster calls fn with the address of and a client for every node
+// holding a share of the keyspace: the server itself, with an empty address,
+// or each master node of a cluster.
+func forEachMaster(ctx context.Context, client redis.UniversalClient, fn func(ctx context.Context, addr string, client redis.Cmdable) error) error {
+	if cc, ok := client.(*redis.ClusterClient); ok {
+		return cc.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
+			return fn(ctx, c.Options().Addr, c)
+		})
+	}
+	return fn(ctx, "", client)
+}
+
+// slotRange is a range of hash slots [Start, End) owned by a node.
+type slotRange struct {
+	Addr       string
+	Start, End int64
+}
+
+// slotRanges returns the ranges of hash slots owned by the master nodes of a
+// cluster, ordered by slot. A single server owns all slots.
+func slotRanges(ctx context.Context, client redis.UniversalClient) ([]slotRange, error) {
+	cc, ok := client.(*redis.ClusterClient)
+	if !ok {
+		return []slotRange{{Start: 0, End: numSlots}}, nil
+	}
+	slots, err := cc.ClusterSlots(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+	var ret []slotRange
+	for _, s := range slots {
+		if len(s.Nodes) == 0 {
+			continue
+		}
+		// The first node is the master, and the end slot is inclusive.
+		ret = append(ret, slotRange{Addr: s.Nodes[0].Addr, Start: int64(s.Start), End: int64(s.End) + 1})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Start < ret[j].Start })
+	return ret, nil
+}
+
+// numSlots is the number of Redis Cluster hash slots. Keys are assigned to
+// slots in the same way regardless of whether a cluster is used, so reads
+// can always be split into slot ranges.
+const numSlots = 16384
+
+// keySlot returns the Redis Cluster hash slot of key, honoring {hash tags}.
+func keySlot(key string) int64 {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int64(crc16(key) % numSlots)
+}
+
+// crc16 implements CRC16-CCITT (XMODEM), as used for Redis Cluster slots.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
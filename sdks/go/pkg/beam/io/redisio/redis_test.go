@@ -0,0 +1,203 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/direct"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func init() {
+	beam.RegisterFunction(eventKey)
+}
+
+func newServer(t *testing.T) (*miniredis.Miniredis, Config) {
+	t.Helper()
+	srv := miniredis.RunT(t)
+	return srv, Config{Addrs: []string{srv.Addr()}}
+}
+
+func TestKeySlot(t *testing.T) {
+	tests := map[string]int64{
+		"123456789":     12739, // CRC16 XMODEM check value 0x31C3, mod 16384.
+		"foo":           12182,
+		"{user1000}.a":  keySlot("user1000"),
+		"{}.a":          keySlot("{}.a"),
+		"user:{1}:name": keySlot("1"),
+	}
+	for key, want := range tests {
+		if got := keySlot(key); got != want {
+			t.Errorf("keySlot(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	srv, cfg := newServer(t)
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		srv.Set("user:"+k, "v"+k)
+	}
+	srv.Set("other", "x")
+	srv.Lpush("user:list", "not a string")
+
+	// Two addresses make a cluster client, for a single node owning all slots.
+	for _, cfg := range []Config{cfg, {Addrs: []string{srv.Addr(), srv.Addr()}}} {
+		p, s := beam.NewPipelineWithRoot()
+		kvs := Read(s, cfg, "user:*", &ReadOptions{BatchSize: 2})
+		keys := beam.DropValue(s, kvs)
+		passert.Equals(s, keys, "user:a", "user:b", "user:c", "user:d", "user:e")
+		ptest.RunAndValidate(t, p)
+	}
+}
+
+func TestReadFn_SplitRestriction(t *testing.T) {
+	srv, cfg := newServer(t)
+	for _, cfg := range []Config{cfg, {Addrs: []string{srv.Addr(), srv.Addr()}}} {
+		fn := &readFn{Config: cfg}
+		fn.Setup()
+		// Every node is scanned by a single split.
+		rest := fn.CreateInitialRestriction(nil)
+		if got, want := fn.SplitRestriction(nil, rest), []offsetrange.Restriction{rest}; !cmp.Equal(got, want) {
+			t.Errorf("SplitRestriction(%v) = %v, want %v", rest, got, want)
+		}
+		fn.Teardown()
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	rest := offsetrange.Restriction{Start: 100, End: 200}
+	tests := []struct {
+		r    slotRange
+		want offsetrange.Restriction
+		ok   bool
+	}{
+		{slotRange{Start: 0, End: 150}, offsetrange.Restriction{Start: 100, End: 150}, true},
+		{slotRange{Start: 120, End: 130}, offsetrange.Restriction{Start: 120, End: 130}, true},
+		{slotRange{Start: 150, End: numSlots}, offsetrange.Restriction{Start: 150, End: 200}, true},
+		{slotRange{Start: 200, End: numSlots}, offsetrange.Restriction{}, false},
+	}
+	for _, test := range tests {
+		got, ok := intersect(rest, test.r)
+		if ok != test.ok || ok && got != test.want {
+			t.Errorf("intersect(%v, %v) = %v, %v, want %v, %v", rest, test.r, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	srv, cfg := newServer(t)
+
+	p, s := beam.NewPipelineWithRoot()
+	sets := beam.ParDo(s, func(k string) (string, string) { return k, "v" + k }, beam.Create(s, "a", "b", "c"))
+	Write(s, cfg, sets, &WriteOptions{TTL: time.Hour, BatchSize: 2})
+	incrs := beam.ParDo(s, func(n string) (string, string) { return "counter", n }, beam.Create(s, "1", "2", "3"))
+	Write(s, cfg, incrs, &WriteOptions{Method: IncrBy})
+	pushes := beam.ParDo(s, func(v string) (string, string) { return "list", v }, beam.Create(s, "x"))
+	Write(s, cfg, pushes, &WriteOptions{Method: RPush})
+	WriteHash(s, cfg, beam.Create(s, HashEntry{Key: "h", Field: "f1", Value: "v1"}, HashEntry{Key: "h", Field: "f2", Value: "v2"}), nil)
+	ptest.RunAndValidate(t, p)
+
+	for _, k := range []string{"a", "b", "c"} {
+		if got, err := srv.Get(k); err != nil || got != "v"+k {
+			t.Errorf("Get(%q) = %q, %v, want %q", k, got, err, "v"+k)
+		}
+		if got := srv.TTL(k); got != time.Hour {
+			t.Errorf("TTL(%q) = %v, want %v", k, got, time.Hour)
+		}
+	}
+	if got, _ := srv.Get("counter"); got != "6" {
+		t.Errorf("Get(counter) = %q, want 6", got)
+	}
+	if got, _ := srv.List("list"); !cmp.Equal(got, []string{"x"}) {
+		t.Errorf("List(list) = %v, want [x]", got)
+	}
+	if got := srv.HGet("h", "f2"); got != "v2" {
+		t.Errorf("HGet(h, f2) = %q, want v2", got)
+	}
+}
+
+type event struct {
+	User string
+}
+
+func eventKey(e event) string {
+	return "user:" + e.User
+}
+
+func TestLookup(t *testing.T) {
+	srv, cfg := newServer(t)
+	srv.Set("user:1", "alice")
+	srv.Set("user:2", "bob")
+
+	p, s := beam.NewPipelineWithRoot()
+	events := beam.Create(s, event{"1"}, event{"2"}, event{"1"}, event{"3"})
+	enriched := Lookup(s, cfg, events, eventKey, nil)
+	passert.Equals(s, beam.DropKey(s, enriched), "alice", "bob", "alice")
+	withMissing := Lookup(s, cfg, events, eventKey, &LookupOptions{KeepMissing: true, CacheSize: -1})
+	passert.Count(s, withMissing, "withMissing", 4)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestLookupFn_cache(t *testing.T) {
+	srv, cfg := newServer(t)
+	srv.Set("user:1", "alice")
+
+	fn := &lookupFn{Config: cfg, KeyFn: beam.EncodedFunc{Fn: reflectx.MakeFunc(eventKey)}, CacheSize: 10}
+	fn.Setup()
+	defer fn.Teardown()
+	ctx := context.Background()
+	if got, ok, err := fn.lookup(ctx, "user:1"); err != nil || !ok || got != "alice" {
+		t.Fatalf("lookup(user:1) = %q, %v, %v, want alice", got, ok, err)
+	}
+	// Cached values are served even after the key changes.
+	srv.Set("user:1", "carol")
+	if got, _, _ := fn.lookup(ctx, "user:1"); got != "alice" {
+		t.Errorf("cached lookup(user:1) = %q, want alice", got)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := newLRUCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.put("a", "1", true)
+	c.put("b", "", false)
+	if _, _, hit := c.get("a"); !hit {
+		t.Fatal("get(a) missed, want hit")
+	}
+	c.put("c", "3", true) // Evicts b, the least recently used.
+	if _, _, hit := c.get("b"); hit {
+		t.Error("get(b) hit, want eviction")
+	}
+	if v, ok, hit := c.get("c"); !hit || !ok || v != "3" {
+		t.Errorf("get(c) = %q, %v, %v, want 3, true, true", v, ok, hit)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, _, hit := c.get("a"); hit {
+		t.Error("get(a) hit after TTL, want miss")
+	}
+}
@@ -0,0 +1,184 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisio
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*writeFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*writeHashFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*HashEntry)(nil)).Elem())
+}
+
+// Method is the Redis command used to write a key and value.
+type Method string
+
+const (
+	// Set sets the key to the value. This is the default.
+	Set Method = "SET"
+	// RPush appends the value to the list at the key.
+	RPush Method = "RPUSH"
+	// IncrBy increments the integer at the key by the value, which must be
+	// the decimal representation of an integer.
+	IncrBy Method = "INCRBY"
+)
+
+// WriteOptions represents options for writing to Redis.
+type WriteOptions struct {
+	// Method is the command used by Write. Defaults to Set.
+	Method Method
+	// TTL, if positive, expires written keys after the given duration.
+	TTL time.Duration
+	// BatchSize is the number of commands sent per pipelined round trip.
+	// Defaults to 1000.
+	BatchSize int
+}
+
+// HashEntry is a single field of a Redis hash.
+type HashEntry struct {
+	Key   string
+	Field string
+	Value string
+}
+
+// Write writes a PCollection<KV<string,string>> of keys and values to Redis
+// using the configured method. Commands are pipelined in batches.
+func Write(s beam.Scope, cfg Config, col beam.PCollection, opts *WriteOptions) {
+	s = s.Scope("redisio.Write")
+	beam.ParDo0(s, &writeFn{writer: newWriter(cfg, opts)}, col)
+}
+
+// WriteHash writes a PCollection<HashEntry> to Redis with HSET. Commands are
+// pipelined in batches, and the TTL, if set, applies to the whole hash.
+func WriteHash(s beam.Scope, cfg Config, col beam.PCollection, opts *WriteOptions) {
+	s = s.Scope("redisio.WriteHash")
+	beam.ParDo0(s, &writeHashFn{writer: newWriter(cfg, opts)}, col)
+}
+
+func newWriter(cfg Config, opts *WriteOptions) writer {
+	w := writer{Config: cfg, Method: Set, BatchSize: defaultBatchSize}
+	if opts != nil {
+		if opts.Method != "" {
+			w.Method = opts.Method
+		}
+		w.TTL = opts.TTL
+		if opts.BatchSize > 0 {
+			w.BatchSize = opts.BatchSize
+		}
+	}
+	switch w.Method {
+	case Set, RPush, IncrBy:
+	default:
+		panic(errors.Errorf("redisio: unsupported write method %q", w.Method))
+	}
+	return w
+}
+
+// writer holds the configuration and pipeline shared by the write DoFns.
+type writer struct {
+	Config    Config        `json:"config"`
+	Method    Method        `json:"method"`
+	TTL       time.Duration `json:"ttl"`
+	BatchSize int           `json:"batchSize"`
+
+	client  redis.UniversalClient
+	pipe    redis.Pipeliner
+	pending int
+}
+
+func (w *writer) Setup() {
+	w.client = w.Config.newClient()
+}
+
+func (w *writer) StartBundle() {
+	w.pipe = w.client.Pipeline()
+	w.pending = 0
+}
+
+// flushIfNeeded executes the pipeline once it holds a full batch.
+func (w *writer) flushIfNeeded(ctx context.Context) error {
+	w.pending++
+	if w.pending < w.BatchSize {
+		return nil
+	}
+	return w.flush(ctx)
+}
+
+func (w *writer) flush(ctx context.Context) error {
+	if w.pending == 0 {
+		return nil
+	}
+	w.pending = 0
+	if _, err := w.pipe.Exec(ctx); err != nil {
+		return errors.Wrapf(err, "redisio failed to write to %v", w.Config)
+	}
+	return nil
+}
+
+func (w *writer) FinishBundle(ctx context.Context) error {
+	return w.flush(ctx)
+}
+
+func (w *writer) Teardown() error {
+	return w.client.Close()
+}
+
+func (w *writer) expire(ctx context.Context, key string) {
+	if w.TTL > 0 {
+		w.pipe.Expire(ctx, key, w.TTL)
+	}
+}
+
+type writeFn struct {
+	writer
+}
+
+func (fn *writeFn) ProcessElement(ctx context.Context, key, value string) error {
+	switch fn.Method {
+	case Set:
+		fn.pipe.Set(ctx, key, value, fn.TTL)
+	case RPush:
+		fn.pipe.RPush(ctx, key, value)
+		fn.expire(ctx, key)
+	case IncrBy:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "redisio INCRBY value for %q is not an integer", key)
+		}
+		fn.pipe.IncrBy(ctx, key, n)
+		fn.expire(ctx, key)
+	}
+	return fn.flushIfNeeded(ctx)
+}
+
+type writeHashFn struct {
+	writer
+}
+
+func (fn *writeHashFn) ProcessElement(ctx context.Context, entry HashEntry) error {
+	fn.pipe.HSet(ctx, entry.Key, entry.Field, entry.Value)
+	fn.expire(ctx, entry.Key)
+	return fn.flushIfNeeded(ctx)
+}
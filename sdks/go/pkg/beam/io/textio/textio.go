@@ -30,6 +30,7 @@ import (
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/valueprovider"
 )
 
 func init() {
@@ -37,6 +38,7 @@ func init() {
 	beam.RegisterFunction(sizeFn)
 	beam.RegisterType(reflect.TypeOf((*writeFileFn)(nil)).Elem())
 	beam.RegisterFunction(expandFn)
+	beam.RegisterType(reflect.TypeOf((*valueFn)(nil)).Elem())
 }
 
 // Read reads a set of files indicated by the glob pattern and returns
@@ -49,6 +51,31 @@ func Read(s beam.Scope, glob string) beam.PCollection {
 	return read(s, beam.Create(s, glob))
 }
 
+// ReadValue is a variation of Read where the glob pattern may be a runtime
+// parameter, resolved when the pipeline is executed.
+func ReadValue(s beam.Scope, glob valueprovider.Value) beam.PCollection {
+	s = s.Scope("textio.ReadValue")
+
+	if !glob.IsRuntime() {
+		filesystem.ValidateScheme(glob.Static)
+	}
+	return read(s, beam.ParDo(s, &valueFn{Value: glob}, beam.Impulse(s)))
+}
+
+// valueFn emits the resolved value of a parameter.
+type valueFn struct {
+	Value valueprovider.Value `json:"value"`
+}
+
+func (fn *valueFn) ProcessElement(_ []byte, emit func(string)) error {
+	v, err := fn.Value.Get()
+	if err != nil {
+		return err
+	}
+	emit(v)
+	return nil
+}
+
 // ReadAll expands and reads the filename given as globs by the incoming
 // PCollection<string>. It returns the lines of all files as a single
 // PCollection<string>. The newlines are not part of the lines.
@@ -192,40 +219,25 @@ func (fn *readFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, file
 	}
 	defer fs.Close()
 
-	fd, err := fs.OpenRead(ctx, filename)
+	i := rt.GetRestriction().(offsetrange.Restriction).Start
+	if i > 0 {
+		// If restriction's starts after 0, we cannot assume a new line starts
+		// at the beginning of the restriction, so we must search for the first
+		// line beginning at or after restriction.Start. This is done by
+		// opening the file at the byte just before the restriction and then
+		// reading until the next newline, leaving the reader at the start of a
+		// new line past restriction.Start.
+		i--
+	}
+	fd, err := filesystem.OpenReadRange(ctx, fs, filename, i, -1)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "TextIO failed to open %q at offset %d; restriction probably lies outside the file being read", filename, i)
 	}
 	defer fd.Close()
 
-	seeker, isSeekable := fd.(io.Seeker)
-
 	rd := bufio.NewReader(fd)
 
-	i := rt.GetRestriction().(offsetrange.Restriction).Start
 	if i > 0 {
-		// If restriction's starts after 0, we cannot assume a new line starts
-		// at the beginning of the restriction, so we must search for the first
-		// line beginning at or after restriction.Start. This is done by
-		// scanning to the byte just before the restriction and then reading
-		// until the next newline, leaving the reader at the start of a new
-		// line past restriction.Start.
-		i--
-		if isSeekable {
-			if _, err := seeker.Seek(i, io.SeekStart); err != nil {
-				return errors.Errorf("TextIO failed to seek to offset %d within file %q; restriction probably lies outside the file being read: %w", i, filename, err)
-			}
-			rd = bufio.NewReader(fd)
-		} else {
-			n, err := rd.Discard(int(i)) // Scan to just before restriction.
-			if err == io.EOF {
-				return errors.Errorf("TextIO restriction lies outside the file being read. "+
-					"Restriction begins at %v bytes, but file is only %v bytes.", i+1, n)
-			}
-			if err != nil {
-				return err
-			}
-		}
 		line, err := rd.ReadString('\n') // Read until the first line within the restriction.
 		if err == io.EOF {
 			// No lines start in the restriction but it's still valid, so
@@ -268,7 +280,21 @@ func Write(s beam.Scope, filename string, col beam.PCollection) {
 	s = s.Scope("textio.Write")
 
 	filesystem.ValidateScheme(filename)
+	write(s, valueprovider.Static(filename), col)
+}
+
+// WriteValue is a variation of Write where the filename may be a runtime
+// parameter, resolved when the pipeline is executed.
+func WriteValue(s beam.Scope, filename valueprovider.Value, col beam.PCollection) {
+	s = s.Scope("textio.WriteValue")
+
+	if !filename.IsRuntime() {
+		filesystem.ValidateScheme(filename.Static)
+	}
+	write(s, filename, col)
+}
 
+func write(s beam.Scope, filename valueprovider.Value, col beam.PCollection) {
 	// NOTE(BEAM-3579): We may never call Teardown for non-local runners and
 	// FinishBundle doesn't have the right granularity. We therefore
 	// perform a GBK with a fixed key to get all values in a single invocation.
@@ -281,23 +307,27 @@ func Write(s beam.Scope, filename string, col beam.PCollection) {
 }
 
 type writeFileFn struct {
-	Filename string `json:"filename"`
+	Filename valueprovider.Value `json:"filename"`
 }
 
 func (w *writeFileFn) ProcessElement(ctx context.Context, _ int, lines func(*string) bool) error {
-	fs, err := filesystem.New(ctx, w.Filename)
+	filename, err := w.Filename.Get()
+	if err != nil {
+		return err
+	}
+	fs, err := filesystem.New(ctx, filename)
 	if err != nil {
 		return err
 	}
 	defer fs.Close()
 
-	fd, err := fs.OpenWrite(ctx, w.Filename)
+	fd, err := fs.OpenWrite(ctx, filename)
 	if err != nil {
 		return err
 	}
 	buf := bufio.NewWriterSize(fd, 1<<20) // use 1MB buffer
 
-	log.Infof(ctx, "Writing to %v", w.Filename)
+	log.Infof(ctx, "Writing to %v", filename)
 
 	var line string
 	for lines(&line) {
@@ -30,6 +30,7 @@ import (
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
 	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/local"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/valueprovider"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
 )
@@ -321,3 +322,27 @@ func (r *seekableReader) Seek(offset int64, whence int) (int64, error) {
 }
 
 var errBadSeek = errors.New("bad seek")
+
+func TestReadWriteValue(t *testing.T) {
+	out := "text_value.txt"
+	beam.PipelineOptions.Set("textio_test_input", testFilePath)
+	beam.PipelineOptions.Set("textio_test_output", out)
+
+	p, s := beam.NewPipelineWithRoot()
+	lines := ReadValue(s, valueprovider.Runtime("textio_test_input", ""))
+	WriteValue(s, valueprovider.Runtime("textio_test_output", ""), lines)
+
+	ptest.RunAndValidate(t, p)
+	t.Cleanup(func() {
+		os.Remove(out)
+	})
+
+	outfileContents, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read %v: %v", out, err)
+	}
+	infileContents, _ := os.ReadFile(testFilePath)
+	if got, want := string(outfileContents), string(infileContents); got != want {
+		t.Fatalf("WriteValue() wrote the wrong contents. Got: %v Want: %v", got, want)
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
 )
 
 // Severity is the severity of the log message.
@@ -36,6 +38,27 @@ const (
 	SevFatal
 )
 
+var sevNames = []string{"UNSPECIFIED", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// String implements the Stringer interface.
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(sevNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return sevNames[s]
+}
+
+// ParseSeverity returns the severity with the given case-insensitive name,
+// such as "debug" or "WARN".
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range sevNames {
+		if strings.EqualFold(name, n) {
+			return Severity(i), nil
+		}
+	}
+	return SevUnspecified, fmt.Errorf("unknown severity %q, want one of %v", name, sevNames[1:])
+}
+
 // Logger is a context-aware logging backend. The richer context allows for
 // more sophisticated logging setups. Must be concurrency safe.
 type Logger interface {
@@ -45,22 +68,33 @@ type Logger interface {
 }
 
 var (
+	mu     sync.RWMutex
 	logger Logger = &Standard{}
 )
 
 // SetLogger sets the global Logger. Intended to be called during initialization
-// only.
+// only, but safe to call while other goroutines log, such as when SDK harnesses
+// run in the launching process.
 func SetLogger(l Logger) {
 	if l == nil {
 		panic("Logger cannot be nil")
 	}
+	mu.Lock()
+	defer mu.Unlock()
 	logger = l
 }
 
+// getLogger returns the global Logger.
+func getLogger() Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return logger
+}
+
 // Output logs the given message to the global logger. Calldepth is the count
 // of the number of frames to skip when computing the file name and line number.
 func Output(ctx context.Context, sev Severity, calldepth int, msg string) {
-	logger.Log(ctx, sev, calldepth+1, msg) // +1 for this frame
+	getLogger().Log(ctx, sev, calldepth+1, msg) // +1 for this frame
 }
 
 // User-facing logging functions.
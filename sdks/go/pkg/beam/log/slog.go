@@ -0,0 +1,111 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogHandler returns a slog.Handler that writes records to the global
+// logger, so DoFns can log with slog:
+//
+//	logger := slog.New(log.NewSlogHandler())
+//	logger.InfoContext(ctx, "processed", "user", id)
+//
+// The context passed to the slog methods determines the bundle and PTransform
+// the message is attributed to, as for the other logging functions.
+func NewSlogHandler() slog.Handler {
+	return &slogHandler{}
+}
+
+type slogHandler struct {
+	attrs  []Attr
+	prefix string // prefix of attribute keys, for groups.
+}
+
+// slogCalldepth is the number of frames between the slog logging call and
+// the handler.
+const slogCalldepth = 2
+
+// Enabled reports whether the handler handles records at the given level.
+// Filtering by severity is left to the global logger.
+func (h *slogHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// Handle writes the record to the global logger.
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := append([]Attr(nil), h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendSlogAttr(attrs, h.prefix, a)
+		return true
+	})
+	OutputAttrs(ctx, slogSeverity(r.Level), slogCalldepth+1, r.Message, attrs)
+	return nil
+}
+
+// WithAttrs returns a handler that includes the given attributes in every
+// record.
+func (h *slogHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	attrs := append([]Attr(nil), h.attrs...)
+	for _, a := range as {
+		attrs = appendSlogAttr(attrs, h.prefix, a)
+	}
+	return &slogHandler{attrs: attrs, prefix: h.prefix}
+}
+
+// WithGroup returns a handler that qualifies the keys of subsequent
+// attributes with the group name.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{attrs: h.attrs, prefix: h.prefix + name + "."}
+}
+
+// appendSlogAttr appends the attribute, flattening groups into dotted keys.
+func appendSlogAttr(attrs []Attr, prefix string, a slog.Attr) []Attr {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			attrs = appendSlogAttr(attrs, prefix, ga)
+		}
+		return attrs
+	}
+	if a.Key == "" {
+		return attrs
+	}
+	return append(attrs, Attr{Key: prefix + a.Key, Value: v.Any()})
+}
+
+func slogSeverity(l slog.Level) Severity {
+	switch {
+	case l >= slog.LevelError:
+		return SevError
+	case l >= slog.LevelWarn:
+		return SevWarn
+	case l >= slog.LevelInfo:
+		return SevInfo
+	default:
+		return SevDebug
+	}
+}
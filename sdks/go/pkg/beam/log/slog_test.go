@@ -0,0 +1,41 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+
+package log
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	sl := &fakeStructuredLogger{}
+	setTestLogger(t, sl)
+
+	logger := slog.New(NewSlogHandler()).With("a", 1).WithGroup("g")
+	logger.WarnContext(context.Background(), "msg", "b", "x", slog.Group("h", "c", true))
+
+	if got, want := sl.msgs, []string{"msg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %v, want %v", got, want)
+	}
+	want := [][]Attr{{{"a", int64(1)}, {"g.b", "x"}, {"g.h.c", true}}}
+	if got := sl.attrs; !reflect.DeepEqual(got, want) {
+		t.Errorf("attrs = %v, want %v", got, want)
+	}
+}
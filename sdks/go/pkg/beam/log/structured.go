@@ -0,0 +1,127 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Attr is a key-value attribute of a structured log message. It mirrors
+// slog.Attr, so attributes can be passed between both packages.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// String implements the Stringer interface.
+func (a Attr) String() string {
+	return fmt.Sprintf("%v=%v", a.Key, a.Value)
+}
+
+// badKey is the key used for values without a key, as in slog.
+const badKey = "!BADKEY"
+
+// StructuredLogger is a Logger that also accepts key-value attributes for
+// log messages. Must be concurrency safe.
+type StructuredLogger interface {
+	Logger
+
+	// LogAttrs logs the message with the given attributes in some
+	// implementation-dependent way. LogAttrs should always return
+	// regardless of the severity.
+	LogAttrs(ctx context.Context, sev Severity, calldepth int, msg string, attrs []Attr)
+}
+
+// OutputAttrs logs the given message and attributes to the global logger.
+// Calldepth is the count of the number of frames to skip when computing the
+// file name and line number. If the global logger isn't a StructuredLogger,
+// the attributes are appended to the message as key=value pairs.
+func OutputAttrs(ctx context.Context, sev Severity, calldepth int, msg string, attrs []Attr) {
+	l := getLogger()
+	if sl, ok := l.(StructuredLogger); ok {
+		sl.LogAttrs(ctx, sev, calldepth+1, msg, attrs) // +1 for this frame
+		return
+	}
+	l.Log(ctx, sev, calldepth+1, FormatAttrs(msg, attrs))
+}
+
+// FormatAttrs returns the message followed by the attributes as key=value
+// pairs, for loggers that can't log attributes separately.
+func FormatAttrs(msg string, attrs []Attr) string {
+	if len(attrs) == 0 {
+		return msg
+	}
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, a := range attrs {
+		b.WriteString(" ")
+		b.WriteString(a.String())
+	}
+	return b.String()
+}
+
+// Attrs converts the arguments of the structured logging functions into
+// attributes. As in slog, each argument is either an Attr, or a string key
+// followed by its value. A final key without a value, or any other value
+// without a key, is given the key "!BADKEY".
+func Attrs(args ...interface{}) []Attr {
+	var attrs []Attr
+	for len(args) > 0 {
+		switch a := args[0].(type) {
+		case Attr:
+			attrs = append(attrs, a)
+			args = args[1:]
+		case string:
+			if len(args) == 1 {
+				attrs = append(attrs, Attr{Key: badKey, Value: a})
+				args = nil
+			} else {
+				attrs = append(attrs, Attr{Key: a, Value: args[1]})
+				args = args[2:]
+			}
+		default:
+			attrs = append(attrs, Attr{Key: badKey, Value: a})
+			args = args[1:]
+		}
+	}
+	return attrs
+}
+
+// DebugAttrs writes the message and the key-value attributes to the global
+// logger with debug severity.
+func DebugAttrs(ctx context.Context, msg string, args ...interface{}) {
+	OutputAttrs(ctx, SevDebug, 1, msg, Attrs(args...))
+}
+
+// InfoAttrs writes the message and the key-value attributes to the global
+// logger with info severity.
+func InfoAttrs(ctx context.Context, msg string, args ...interface{}) {
+	OutputAttrs(ctx, SevInfo, 1, msg, Attrs(args...))
+}
+
+// WarnAttrs writes the message and the key-value attributes to the global
+// logger with warn severity.
+func WarnAttrs(ctx context.Context, msg string, args ...interface{}) {
+	OutputAttrs(ctx, SevWarn, 1, msg, Attrs(args...))
+}
+
+// ErrorAttrs writes the message and the key-value attributes to the global
+// logger with error severity.
+func ErrorAttrs(ctx context.Context, msg string, args ...interface{}) {
+	OutputAttrs(ctx, SevError, 1, msg, Attrs(args...))
+}
@@ -0,0 +1,92 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// fakeLogger records log calls, optionally as a StructuredLogger.
+type fakeLogger struct {
+	msgs  []string
+	attrs [][]Attr
+}
+
+func (l *fakeLogger) Log(_ context.Context, _ Severity, _ int, msg string) {
+	l.msgs = append(l.msgs, msg)
+}
+
+type fakeStructuredLogger struct {
+	fakeLogger
+}
+
+func (l *fakeStructuredLogger) LogAttrs(_ context.Context, _ Severity, _ int, msg string, attrs []Attr) {
+	l.msgs = append(l.msgs, msg)
+	l.attrs = append(l.attrs, attrs)
+}
+
+func setTestLogger(t *testing.T, l Logger) {
+	t.Helper()
+	old := getLogger()
+	SetLogger(l)
+	t.Cleanup(func() { SetLogger(old) })
+}
+
+func TestAttrs(t *testing.T) {
+	got := Attrs("a", 1, Attr{Key: "b", Value: "x"}, 42, "c")
+	want := []Attr{{"a", 1}, {"b", "x"}, {badKey, 42}, {badKey, "c"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Attrs() = %v, want %v", got, want)
+	}
+}
+
+func TestInfoAttrs(t *testing.T) {
+	ctx := context.Background()
+
+	l := &fakeLogger{}
+	setTestLogger(t, l)
+	InfoAttrs(ctx, "msg", "a", 1, "b", "x")
+	if got, want := l.msgs, []string{"msg a=1 b=x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unstructured logger messages = %v, want %v", got, want)
+	}
+
+	sl := &fakeStructuredLogger{}
+	setTestLogger(t, sl)
+	InfoAttrs(ctx, "msg", "a", 1)
+	if got, want := sl.msgs, []string{"msg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("structured logger messages = %v, want %v", got, want)
+	}
+	if got, want := sl.attrs, [][]Attr{{{"a", 1}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("structured logger attrs = %v, want %v", got, want)
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, sev := range []Severity{SevDebug, SevInfo, SevWarn, SevError, SevFatal} {
+		got, err := ParseSeverity(sev.String())
+		if err != nil || got != sev {
+			t.Errorf("ParseSeverity(%v) = %v, %v, want %v", sev.String(), got, err, sev)
+		}
+	}
+	if got, err := ParseSeverity("warn"); err != nil || got != SevWarn {
+		t.Errorf("ParseSeverity(warn) = %v, %v, want WARN", got, err)
+	}
+	if _, err := ParseSeverity("loud"); err == nil {
+		t.Error("ParseSeverity(loud) succeeded, want error")
+	}
+}
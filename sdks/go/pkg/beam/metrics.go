@@ -92,3 +92,49 @@ func (c Gauge) Set(ctx context.Context, v int64) {
 func NewGauge(namespace, name string) Gauge {
 	return Gauge{metrics.NewGauge(namespace, name)}
 }
+
+// Histogram is a metric that counts reported values in buckets with configurable
+// boundaries, such as for percentile latencies, and is aggregated by summing the
+// bucket counts.
+//
+// Histograms are safe to use in multiple bundles simultaneously, but
+// not generally threadsafe. Your DoFn needs to manage the thread
+// safety of Beam metrics for any additional concurrency it uses.
+type Histogram struct {
+	*metrics.Histogram
+}
+
+// Update adds an observation to this histogram. The context must be
+// provided by the framework, or the value will not be recorded.
+func (c Histogram) Update(ctx context.Context, v int64) {
+	c.Histogram.Update(ctx, v)
+}
+
+// NewHistogram returns the Histogram with the given namespace, name and strictly
+// increasing bucket boundaries. A value v is counted in bucket i if
+// boundaries[i-1] <= v < boundaries[i], with the first and last buckets unbounded.
+// metrics.LinearBuckets and metrics.ExponentialBuckets generate common boundaries.
+func NewHistogram(namespace, name string, boundaries []int64) Histogram {
+	return Histogram{metrics.NewHistogram(namespace, name, boundaries)}
+}
+
+// StringSet is a metric that records the distinct string values reported, such
+// as the error codes that occurred, and is aggregated by the union.
+//
+// StringSets are safe to use in multiple bundles simultaneously, but
+// not generally threadsafe. Your DoFn needs to manage the thread
+// safety of Beam metrics for any additional concurrency it uses.
+type StringSet struct {
+	*metrics.StringSet
+}
+
+// Add adds a value to this set. The context must be
+// provided by the framework, or the value will not be recorded.
+func (c StringSet) Add(ctx context.Context, v string) {
+	c.StringSet.Add(ctx, v)
+}
+
+// NewStringSet returns the StringSet with the given namespace and name.
+func NewStringSet(namespace, name string) StringSet {
+	return StringSet{metrics.NewStringSet(namespace, name)}
+}
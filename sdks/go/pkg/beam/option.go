@@ -18,6 +18,8 @@ package beam
 import (
 	"fmt"
 	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/resource"
 )
 
 // Option is an optional value or context to a transformation, used at pipeline
@@ -49,6 +51,35 @@ type TypeDefinition struct {
 
 func (s TypeDefinition) private() {}
 
+// WithResourceHints attaches resource hints to a ParDo, such as the minimum
+// amount of memory or the accelerator it needs. Hints of the enclosing scopes
+// also apply, and nested hints take precedence as defined by each hint.
+// Memory hints are given in bytes with resource.MinRAMBytes, or as a size
+// with a unit, such as "16GiB", with resource.ParseMinRAM.
+//
+//	out := beam.ParDo(s, inferFn, in, beam.WithResourceHints(resource.MinRAMBytes(16<<30), resource.Accelerator("type:nvidia-tesla-t4;count:1")))
+func WithResourceHints(hints ...resource.Hint) Option {
+	return resourceHints{hints}
+}
+
+type resourceHints struct {
+	hints []resource.Hint
+}
+
+func (r resourceHints) private() {}
+
+// parseResourceHints returns the resource hints of the options. Later hints
+// replace earlier ones with the same URN.
+func parseResourceHints(opts []Option) resource.Hints {
+	var hints []resource.Hint
+	for _, opt := range opts {
+		if r, ok := opt.(resourceHints); ok {
+			hints = append(hints, r.hints...)
+		}
+	}
+	return resource.NewHints(hints...)
+}
+
 func parseOpts(opts []Option) ([]SideInput, []TypeDefinition) {
 	var side []SideInput
 	var infer []TypeDefinition
@@ -59,6 +90,8 @@ func parseOpts(opts []Option) ([]SideInput, []TypeDefinition) {
 			side = append(side, opt)
 		case TypeDefinition:
 			infer = append(infer, opt)
+		case resourceHints:
+			// Handled by parseResourceHints.
 		default:
 			panic(fmt.Sprintf("Unexpected opt: %v", opt))
 		}
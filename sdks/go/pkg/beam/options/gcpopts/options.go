@@ -18,24 +18,37 @@ package gcpopts
 
 import (
 	"context"
-	"flag"
 	"os"
 	"os/exec"
 	"strings"
 
 	"cloud.google.com/go/storage"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/typedopts"
 	"golang.org/x/oauth2/google"
 )
 
+// Options are the shared Google Cloud Platform options. They are registered
+// as typed options, so DoFns can read them with typedopts.FromContext.
+type Options struct {
+	Project string `help:"Google Cloud Platform project ID."`
+	Region  string `help:"GCP Region (required)"`
+}
+
+var opts = &Options{}
+
 var (
 	// Project is the Google Cloud Platform project ID.
-	Project = flag.String("project", "", "Google Cloud Platform project ID.")
+	Project = &opts.Project
 
 	// Region is the GCP region where the job should be run.
-	Region = flag.String("region", "", "GCP Region (required)")
+	Region = &opts.Region
 )
 
+func init() {
+	typedopts.Register(opts)
+}
+
 // GetProject returns the project, if non empty and exits otherwise.
 // Convenience function.
 func GetProject(ctx context.Context) string {
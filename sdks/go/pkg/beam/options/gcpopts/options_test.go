@@ -17,10 +17,13 @@ package gcpopts
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/typedopts"
 )
 
 func TestGetProjectFromFlagOrEnvironment_Unset(t *testing.T) {
@@ -86,6 +89,29 @@ func TestGetRegion_BothSet(t *testing.T) {
 	}
 }
 
+func TestOptions_typed(t *testing.T) {
+	defer func(prev Options) { *opts = prev }(*opts)
+	if err := flag.Set("project", "flagProject"); err != nil {
+		t.Fatalf("flag.Set(project) failed: %v", err)
+	}
+	if got, want := *Project, "flagProject"; got != want {
+		t.Errorf("*Project = %q, want %q", got, want)
+	}
+
+	*Region = "exportedRegion"
+	values, err := typedopts.Export()
+	if err != nil {
+		t.Fatalf("typedopts.Export() failed: %v", err)
+	}
+	var got Options
+	if err := typedopts.FromContext(typedopts.NewContext(context.Background(), values), &got); err != nil {
+		t.Fatalf("typedopts.FromContext() failed: %v", err)
+	}
+	if want := (Options{Project: "flagProject", Region: "exportedRegion"}); got != want {
+		t.Errorf("typedopts.FromContext() = %+v, want %+v", got, want)
+	}
+}
+
 // Set up fake credential file to read project from with the passed in projectId.
 func setupFakeCredentialFile(t *testing.T, projectID string) {
 	t.Helper()
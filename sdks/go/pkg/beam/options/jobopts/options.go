@@ -28,11 +28,45 @@ import (
 	"sync/atomic"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/resource"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/typedopts"
 )
 
+// Options are the shared options for job submission. They are registered as
+// typed options, so DoFns can read them with typedopts.FromContext.
+type Options struct {
+	Endpoint          string `help:"Job service endpoint (required)."`
+	JobName           string `help:"Job name (optional)."`
+	EnvironmentType   string `default:"DOCKER" help:"Environment Type. Possible options are DOCKER, and LOOPBACK."`
+	EnvironmentConfig string `help:"Set environment configuration for running the user code.\nFor DOCKER: Url for the docker image.\nFor PROCESS: json of the form {\"os\": \"<OS>\", \"arch\": \"<ARCHITECTURE>\", \"command\": \"<process to execute>\", \"env\":{\"<Environment variables 1>\": \"<ENV_VAL>\"} }. All fields in the json are optional except command."`
+
+	WorkerBinary      string `help:"Worker binary (optional)"`
+	WorkerArch        string `default:"amd64" help:"Architecture of the compiled worker binary, such as amd64 or arm64."`
+	WorkerStatic      bool   `help:"Compile a statically linked worker binary, with cgo disabled."`
+	WorkerBuildTags   string `help:"Comma-separated list of build tags for compiling the worker binary (optional)."`
+	WorkerBuildFlags  string `help:"Space-separated list of go build flags for compiling the worker binary, such as -trimpath (optional)."`
+	WorkerCacheDir    string `help:"Directory caching compiled worker binaries by content hash (optional)."`
+	WorkerImageBase   string `help:"Boot container image in OCI image layout, as a directory or tar archive, to build a worker container image from (optional)."`
+	WorkerImageOutput string `help:"Path to write a worker container image as an OCI image layout tar archive. Requires --worker_image_base (optional)."`
+	WorkerImageName   string `help:"Reference name of the worker container image, such as beam_go_worker:latest (optional)."`
+
+	Experiments            string `help:"Comma-separated list of experiments (optional)."`
+	Async                  bool   `help:"Do not wait for job completion."`
+	Strict                 bool   `beam:"beam_strict" help:"Apply additional validation to pipelines."`
+	RetainDockerContainers bool   `help:"Retain Docker containers created by the runner."`
+	Parallelism            int    `default:"-1" help:"The degree of parallelism to be used when distributing operations onto Flink workers."`
+	TemplateLocation       string `help:"Location to save the pipeline as a template. If set, the job is not submitted (optional)."`
+}
+
+var opts = &Options{}
+
 func init() {
+	typedopts.Register(opts)
+
+	// Repeated flags stay untyped: their entries may contain commas, which
+	// separate the values of typed list options.
 	flag.Var(&SdkHarnessContainerImageOverrides,
 		"sdk_harness_container_image_override",
 		"Overrides for SDK harness container images. Could be for the "+
@@ -45,53 +79,88 @@ func init() {
 			"have no more than 1 override applied to it. If multiple "+
 			"overrides match a container image it is arbitrary which "+
 			"will be applied.")
+	flag.Var(&ResourceHints,
+		"resource_hints",
+		"Default resource hints of all transforms, as name=value, such as "+
+			"min_ram=16GB, cpu_count=4 or accelerator=<spec>. Hints of "+
+			"transforms and composites take precedence as defined by each "+
+			"hint. Multiple hints can be specified by using this flag "+
+			"multiple times.")
 }
 
 var (
 	// Endpoint is the job service endpoint.
-	Endpoint = flag.String("endpoint", "", "Job service endpoint (required).")
+	Endpoint = &opts.Endpoint
 
 	// JobName is the name of the job.
-	JobName = flag.String("job_name", "", "Job name (optional).")
+	JobName = &opts.JobName
 
 	// EnvironmentType is the environment type to run the user code.
-	EnvironmentType = flag.String("environment_type", "DOCKER",
-		"Environment Type. Possible options are DOCKER, and LOOPBACK.")
+	EnvironmentType = &opts.EnvironmentType
 
 	// EnvironmentConfig is the environment configuration for running the user code.
-	EnvironmentConfig = flag.String("environment_config",
-		"",
-		"Set environment configuration for running the user code.\n"+
-			"For DOCKER: Url for the docker image.\n"+
-			"For PROCESS: json of the form {\"os\": \"<OS>\", "+
-			"\"arch\": \"<ARCHITECTURE>\", \"command\": \"<process to execute>\", "+
-			"\"env\":{\"<Environment variables 1>\": \"<ENV_VAL>\"} }. "+
-			"All fields in the json are optional except command.")
+	EnvironmentConfig = &opts.EnvironmentConfig
 
 	// SdkHarnessContainerImageOverrides contains patterns for overriding
 	// container image names in a pipeline.
 	SdkHarnessContainerImageOverrides stringSlice
 
+	// ResourceHints are the default resource hints of all transforms.
+	ResourceHints stringSlice
+
 	// WorkerBinary is the location of the compiled worker binary. If not
 	// specified, the binary is produced via go build.
-	WorkerBinary = flag.String("worker_binary", "", "Worker binary (optional)")
+	WorkerBinary = &opts.WorkerBinary
+
+	// WorkerArch is the GOARCH the worker binary is cross-compiled for,
+	// such as arm64. Defaults to amd64.
+	WorkerArch = &opts.WorkerArch
+
+	// WorkerStatic disables cgo when compiling the worker binary, to produce
+	// a statically linked binary.
+	WorkerStatic = &opts.WorkerStatic
+
+	// WorkerBuildTags are additional build tags for compiling the worker binary.
+	WorkerBuildTags = &opts.WorkerBuildTags
+
+	// WorkerBuildFlags are additional go build flags for compiling the worker
+	// binary.
+	WorkerBuildFlags = &opts.WorkerBuildFlags
+
+	// WorkerCacheDir is the directory caching compiled worker binaries.
+	WorkerCacheDir = &opts.WorkerCacheDir
+
+	// WorkerImageBase is the SDK boot container image, in OCI image layout,
+	// that worker container images are built from.
+	WorkerImageBase = &opts.WorkerImageBase
+
+	// WorkerImageOutput is the path of the worker container image tar archive
+	// to produce.
+	WorkerImageOutput = &opts.WorkerImageOutput
+
+	// WorkerImageName is the reference name of the worker container image.
+	WorkerImageName = &opts.WorkerImageName
 
 	// Experiments toggle experimental features in the runner.
-	Experiments = flag.String("experiments", "", "Comma-separated list of experiments (optional).")
+	Experiments = &opts.Experiments
 
 	// Async determines whether to wait for job completion.
-	Async = flag.Bool("async", false, "Do not wait for job completion.")
+	Async = &opts.Async
 
 	// Strict mode applies additional validation to user pipelines before
 	// executing them and fails early if the pipelines don't pass.
-	Strict = flag.Bool("beam_strict", false, "Apply additional validation to pipelines.")
+	Strict = &opts.Strict
 
 	// Flag to retain docker containers created by the runner. If false, then
 	// containers are deleted once the job ends, even if it failed.
-	RetainDockerContainers = flag.Bool("retain_docker_containers", false, "Retain Docker containers created by the runner.")
+	RetainDockerContainers = &opts.RetainDockerContainers
 
 	// Flag to set the degree of parallelism. If not set, the configured Flink default is used, or 1 if none can be found.
-	Parallelism = flag.Int("parallelism", -1, "The degree of parallelism to be used when distributing operations onto Flink workers.")
+	Parallelism = &opts.Parallelism
+
+	// TemplateLocation is the location to save the pipeline as a template,
+	// instead of submitting it.
+	TemplateLocation = &opts.TemplateLocation
 )
 
 type missingFlagError error
@@ -169,3 +238,29 @@ func GetExperiments() []string {
 	}
 	return strings.Split(*Experiments, ",")
 }
+
+// GetWorkerBuildTags returns the build tags of the worker binary.
+func GetWorkerBuildTags() []string {
+	if *WorkerBuildTags == "" {
+		return nil
+	}
+	return strings.Split(*WorkerBuildTags, ",")
+}
+
+// GetWorkerBuildFlags returns the go build flags of the worker binary.
+func GetWorkerBuildFlags() []string {
+	return strings.Fields(*WorkerBuildFlags)
+}
+
+// GetResourceHints returns the default resource hints of all transforms.
+func GetResourceHints() (resource.Hints, error) {
+	var hints []resource.Hint
+	for _, s := range ResourceHints {
+		hint, err := resource.ParseHint(s)
+		if err != nil {
+			return resource.Hints{}, errors.WithContext(err, "parsing --resource_hints")
+		}
+		hints = append(hints, hint)
+	}
+	return resource.NewHints(hints...), nil
+}
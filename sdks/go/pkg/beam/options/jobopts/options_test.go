@@ -17,11 +17,13 @@ package jobopts
 
 import (
 	"context"
+	"flag"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/typedopts"
 )
 
 func TestGetEndpoint(t *testing.T) {
@@ -129,3 +131,29 @@ func TestGetSdkImageOverrides(t *testing.T) {
 		t.Errorf("GetSdkImageOverrides() = %v, want %v", got, want)
 	}
 }
+
+func TestOptions_typed(t *testing.T) {
+	defer func(prev Options) { *opts = prev }(*opts)
+	if got, want := *Parallelism, -1; got != want {
+		t.Errorf("default *Parallelism = %v, want %v", got, want)
+	}
+	if err := flag.Set("worker_arch", "arm64"); err != nil {
+		t.Fatalf("flag.Set(worker_arch) failed: %v", err)
+	}
+	if got, want := *WorkerArch, "arm64"; got != want {
+		t.Errorf("*WorkerArch = %q, want %q", got, want)
+	}
+
+	*Experiments = "a,b"
+	values, err := typedopts.Export()
+	if err != nil {
+		t.Fatalf("typedopts.Export() failed: %v", err)
+	}
+	var got Options
+	if err := typedopts.FromContext(typedopts.NewContext(context.Background(), values), &got); err != nil {
+		t.Fatalf("typedopts.FromContext() failed: %v", err)
+	}
+	if got.WorkerArch != "arm64" || got.Experiments != "a,b" || got.Parallelism != -1 {
+		t.Errorf("typedopts.FromContext() = %+v, want worker_arch arm64, experiments a,b and parallelism -1", got)
+	}
+}
@@ -0,0 +1,516 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package typedopts provides typed pipeline options, declared as structs.
+//
+// Each exported field of a registered options struct is a pipeline option,
+// exposed as a flag. Fields are described with tags:
+//
+//	type Options struct {
+//		Input  string        `beam:"input" required:"true" help:"Files to read."`
+//		Shards int           `beam:"shards" default:"10" min:"1" help:"Number of output shards."`
+//		Mode   string        `default:"fast" enum:"fast,exact" help:"Counting mode."`
+//		Window time.Duration `default:"1m"`
+//	}
+//
+// The beam tag names the option, and defaults to the snake-cased field name.
+// Fields tagged with beam:"-" aren't options. Supported field types are
+// string, bool, int, int64, uint, uint64, float64, time.Duration and
+// []string, given as comma-separated values on the command line.
+//
+// Options structs are registered before flags are parsed, typically in init:
+//
+//	var opts = &Options{}
+//
+//	func init() {
+//		typedopts.Register(opts)
+//	}
+//
+// After flag parsing, the registered struct holds the option values. beam.Run
+// validates them, and runners serialize them into the pipeline options of the
+// job, from which workers recover them. DoFns read them from their context,
+// independently of the flags of the process:
+//
+//	func (fn *countFn) ProcessElement(ctx context.Context, line string, emit func(string, int)) error {
+//		var opts Options
+//		if err := typedopts.FromContext(ctx, &opts); err != nil {
+//			return err
+//		}
+//		...
+//	}
+package typedopts
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+var (
+	mu     sync.Mutex
+	groups = make(map[reflect.Type]*group) // options struct type -> group
+)
+
+// field is an option declared by a struct field.
+type field struct {
+	name     string
+	help     string
+	def      string
+	required bool
+	enum     []string
+	min, max *float64
+	index    int
+}
+
+// group is a registered options struct.
+type group struct {
+	name   string        // qualified type name
+	ptr    reflect.Value // registered struct pointer
+	fields []*field
+}
+
+// Register registers the options struct pointed to by ptr, and defines a flag
+// for each of its options on the command line flag set. Defaults are applied
+// to the struct immediately. Register panics if the struct declares invalid
+// options, or options already defined as flags.
+func Register(ptr interface{}) {
+	g, err := newGroup(ptr)
+	if err != nil {
+		panic(fmt.Sprintf("typedopts.Register: %v", err))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	t := g.ptr.Type().Elem()
+	if _, ok := groups[t]; ok {
+		panic(fmt.Sprintf("typedopts.Register: options %v already registered", g.name))
+	}
+	for _, f := range g.fields {
+		flag.Var(&value{v: g.ptr.Elem().Field(f.index), f: f}, f.name, f.usage())
+	}
+	groups[t] = g
+}
+
+func newGroup(ptr interface{}) (*group, error) {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, errors.Errorf("options must be a non-nil pointer to a struct, got %T", ptr)
+	}
+	t := v.Elem().Type()
+	g := &group{name: t.PkgPath() + "." + t.Name(), ptr: v}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" || sf.Tag.Get("beam") == "-" {
+			continue // unexported or excluded
+		}
+		f, err := newField(sf, i)
+		if err != nil {
+			return nil, errors.WithContextf(err, "declaring option %v of %v", sf.Name, g.name)
+		}
+		if f.def != "" {
+			if err := set(v.Elem().Field(i), f.def); err != nil {
+				return nil, errors.Wrapf(err, "invalid default for option %v", f.name)
+			}
+		}
+		g.fields = append(g.fields, f)
+	}
+	return g, nil
+}
+
+func newField(sf reflect.StructField, index int) (*field, error) {
+	if !supported(sf.Type) {
+		return nil, errors.Errorf("unsupported option type %v", sf.Type)
+	}
+	f := &field{
+		name:  sf.Tag.Get("beam"),
+		help:  sf.Tag.Get("help"),
+		def:   sf.Tag.Get("default"),
+		index: index,
+	}
+	if f.name == "" {
+		f.name = snakeCase(sf.Name)
+	}
+	if r := sf.Tag.Get("required"); r != "" {
+		required, err := strconv.ParseBool(r)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid required tag %q", r)
+		}
+		f.required = required
+	}
+	if e := sf.Tag.Get("enum"); e != "" {
+		if sf.Type.Kind() != reflect.String {
+			return nil, errors.Errorf("enum tag on non-string option of type %v", sf.Type)
+		}
+		f.enum = strings.Split(e, ",")
+	}
+	var err error
+	if f.min, err = parseBound(sf, "min"); err != nil {
+		return nil, err
+	}
+	if f.max, err = parseBound(sf, "max"); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func parseBound(sf reflect.StructField, tag string) (*float64, error) {
+	s := sf.Tag.Get(tag)
+	if s == "" {
+		return nil, nil
+	}
+	if _, ok := number(reflect.Zero(sf.Type)); !ok {
+		return nil, errors.Errorf("%v tag on non-numeric option of type %v", tag, sf.Type)
+	}
+	b, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %v tag %q", tag, s)
+	}
+	return &b, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func supported(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	default:
+		return false
+	}
+}
+
+// set parses the string into the option value.
+func set(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 0, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var ss []string
+		if s != "" {
+			ss = strings.Split(s, ",")
+		}
+		v.Set(reflect.ValueOf(ss).Convert(v.Type()))
+	default:
+		return errors.Errorf("unsupported option type %v", v.Type())
+	}
+	return nil
+}
+
+// format returns the option value as given on the command line.
+func format(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Convert(reflect.TypeOf([]string(nil))).Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// number returns the option value as a float, if numeric.
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// snakeCase converts a Go field name, such as MaxNumWorkers or InputURL, to
+// an option name, such as max_num_workers or input_url.
+func snakeCase(s string) string {
+	rs := []rune(s)
+	var b strings.Builder
+	for i, r := range rs {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := rs[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && i+1 < len(rs) && unicode.IsLower(rs[i+1])) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+func (f *field) usage() string {
+	var parts []string
+	if f.help != "" {
+		parts = append(parts, f.help)
+	}
+	if len(f.enum) > 0 {
+		parts = append(parts, fmt.Sprintf("One of: %v.", strings.Join(f.enum, ", ")))
+	}
+	if f.required {
+		parts = append(parts, "Required.")
+	}
+	return strings.Join(parts, " ")
+}
+
+// value is the flag.Value of an option.
+type value struct {
+	v reflect.Value
+	f *field
+}
+
+func (o *value) String() string {
+	if o == nil || !o.v.IsValid() {
+		return ""
+	}
+	return format(o.v)
+}
+
+func (o *value) Set(s string) error {
+	return set(o.v, s)
+}
+
+func (o *value) IsBoolFlag() bool {
+	return o.v.Kind() == reflect.Bool
+}
+
+// Validate returns an error describing the invalid values of all registered
+// options: missing required options, values not among the enumerated ones,
+// and numbers out of range.
+func Validate() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var msgs []string
+	for _, g := range sortedGroups() {
+		for _, f := range g.fields {
+			v := g.ptr.Elem().Field(f.index)
+			if v.IsZero() {
+				if f.required {
+					msgs = append(msgs, fmt.Sprintf("--%v is required", f.name))
+				}
+				continue
+			}
+			if len(f.enum) > 0 && !contains(f.enum, v.String()) {
+				msgs = append(msgs, fmt.Sprintf("--%v=%v isn't one of: %v", f.name, v.String(), strings.Join(f.enum, ", ")))
+			}
+			if n, ok := number(v); ok {
+				if f.min != nil && n < *f.min {
+					msgs = append(msgs, fmt.Sprintf("--%v=%v is less than %v", f.name, format(v), *f.min))
+				}
+				if f.max != nil && n > *f.max {
+					msgs = append(msgs, fmt.Sprintf("--%v=%v is greater than %v", f.name, format(v), *f.max))
+				}
+			}
+		}
+	}
+	if len(msgs) > 0 {
+		return errors.Errorf("invalid options: %v", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Usage writes the help text of all registered options.
+func Usage(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, g := range sortedGroups() {
+		fmt.Fprintf(w, "%v:\n", g.name)
+		for _, f := range g.fields {
+			t := g.ptr.Elem().Field(f.index).Type()
+			fmt.Fprintf(w, "  --%v (%v", f.name, t)
+			if f.def != "" {
+				fmt.Fprintf(w, ", default %q", f.def)
+			}
+			fmt.Fprint(w, ")\n")
+			if u := f.usage(); u != "" {
+				fmt.Fprintf(w, "    \t%v\n", u)
+			}
+		}
+	}
+}
+
+func sortedGroups() []*group {
+	var ret []*group
+	for _, g := range groups {
+		ret = append(ret, g)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].name < ret[j].name })
+	return ret
+}
+
+// Export returns the values of all registered options, as JSON objects keyed
+// by the qualified name of their struct type, for serialization into the
+// pipeline options.
+func Export() (map[string]json.RawMessage, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(groups) == 0 {
+		return nil, nil
+	}
+	ret := make(map[string]json.RawMessage)
+	for _, g := range groups {
+		data, err := g.encode(g.ptr)
+		if err != nil {
+			return nil, err
+		}
+		ret[g.name] = data
+	}
+	return ret, nil
+}
+
+// Import sets the registered options from exported values, such as on
+// workers. Values of unregistered options are ignored.
+func Import(values map[string]json.RawMessage) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, g := range groups {
+		if data, ok := values[g.name]; ok {
+			if err := g.decode(data, g.ptr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *group) encode(ptr reflect.Value) (json.RawMessage, error) {
+	m := make(map[string]interface{})
+	for _, f := range g.fields {
+		m[f.name] = ptr.Elem().Field(f.index).Interface()
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, errors.Wrapf(err, "encoding options %v", g.name)
+	}
+	return data, nil
+}
+
+// decode sets the options struct to the defaults, overridden by the encoded
+// values.
+func (g *group) decode(data json.RawMessage, ptr reflect.Value) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return errors.Wrapf(err, "decoding options %v", g.name)
+	}
+	for _, f := range g.fields {
+		v := ptr.Elem().Field(f.index)
+		v.Set(reflect.Zero(v.Type()))
+		if f.def != "" {
+			if err := set(v, f.def); err != nil {
+				return err
+			}
+		}
+		if raw, ok := m[f.name]; ok {
+			if err := json.Unmarshal(raw, v.Addr().Interface()); err != nil {
+				return errors.Wrapf(err, "decoding option %v of %v", f.name, g.name)
+			}
+		}
+	}
+	return nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the exported option values, for
+// FromContext.
+func NewContext(ctx context.Context, values map[string]json.RawMessage) context.Context {
+	return context.WithValue(ctx, contextKey{}, values)
+}
+
+// FromContext sets the options struct pointed to by ptr, of a registered
+// type, to the option values carried by the context. If the context carries
+// none, such as when the pipeline runs in the launching process, it's set to
+// the values of the registered struct instead. The struct is a copy, safe to
+// use across goroutines.
+func FromContext(ctx context.Context, ptr interface{}) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.Errorf("options must be a non-nil pointer to a struct, got %T", ptr)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	g, ok := groups[v.Type().Elem()]
+	if !ok {
+		return errors.Errorf("options %T not registered. Forgot to call typedopts.Register?", ptr)
+	}
+	if values, ok := ctx.Value(contextKey{}).(map[string]json.RawMessage); ok {
+		if data, ok := values[g.name]; ok {
+			return g.decode(data, v)
+		}
+	}
+	data, err := g.encode(g.ptr)
+	if err != nil {
+		return err
+	}
+	return g.decode(data, v)
+}
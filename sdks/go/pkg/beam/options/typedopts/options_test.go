@@ -0,0 +1,263 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package typedopts
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/provision"
+)
+
+type testOptions struct {
+	Input     string        `beam:"typed_input" required:"true" help:"Files to read."`
+	Shards    int           `beam:"typed_shards" default:"10" min:"1" max:"100"`
+	Mode      string        `beam:"typed_mode" default:"fast" enum:"fast,exact"`
+	Window    time.Duration `beam:"typed_window" default:"1m"`
+	Verbose   bool          `beam:"typed_verbose"`
+	Labels    []string      `beam:"typed_labels" default:"a,b"`
+	Ignored   string        `beam:"-"`
+	unexposed string
+}
+
+var opts = &testOptions{}
+
+func init() {
+	Register(opts)
+}
+
+// reset restores the registered options to their defaults.
+func reset(t *testing.T) {
+	t.Helper()
+	if err := Import(map[string]json.RawMessage{groupName(): json.RawMessage("{}")}); err != nil {
+		t.Fatalf("resetting options failed: %v", err)
+	}
+}
+
+func groupName() string {
+	return "github.com/apache/beam/sdks/v2/go/pkg/beam/options/typedopts.testOptions"
+}
+
+func TestRegister_Defaults(t *testing.T) {
+	reset(t)
+	want := testOptions{
+		Shards: 10,
+		Mode:   "fast",
+		Window: time.Minute,
+		Labels: []string{"a", "b"},
+	}
+	if !reflect.DeepEqual(*opts, want) {
+		t.Errorf("registered options = %+v, want %+v", *opts, want)
+	}
+}
+
+func TestRegister_Flags(t *testing.T) {
+	reset(t)
+	defer reset(t)
+
+	sets := map[string]string{
+		"typed_input":   "gs://bucket/*.txt",
+		"typed_shards":  "42",
+		"typed_window":  "5s",
+		"typed_verbose": "true",
+		"typed_labels":  "x,y,z",
+	}
+	for name, v := range sets {
+		if err := flag.Set(name, v); err != nil {
+			t.Fatalf("flag.Set(%v, %v) failed: %v", name, v, err)
+		}
+	}
+	want := testOptions{
+		Input:   "gs://bucket/*.txt",
+		Shards:  42,
+		Mode:    "fast",
+		Window:  5 * time.Second,
+		Verbose: true,
+		Labels:  []string{"x", "y", "z"},
+	}
+	if !reflect.DeepEqual(*opts, want) {
+		t.Errorf("options after flag.Set = %+v, want %+v", *opts, want)
+	}
+	if f := flag.Lookup("typed_shards"); f == nil || f.DefValue != "10" {
+		t.Errorf("flag typed_shards = %+v, want default 10", f)
+	}
+	if f := flag.Lookup("ignored"); f != nil {
+		t.Errorf("flag ignored defined, want excluded field")
+	}
+	if err := flag.Set("typed_shards", "many"); err == nil {
+		t.Errorf("flag.Set(typed_shards, many) succeeded, want error")
+	}
+}
+
+func TestRegister_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		ptr  interface{}
+	}{
+		{"not_pointer", testOptions{}},
+		{"unsupported_type", &struct{ M map[string]string }{}},
+		{"enum_on_int", &struct {
+			N int `enum:"1,2"`
+		}{}},
+		{"min_on_string", &struct {
+			S string `min:"1"`
+		}{}},
+		{"bad_default", &struct {
+			N int `default:"ten"`
+		}{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := newGroup(test.ptr); err == nil {
+				t.Errorf("newGroup(%T) succeeded, want error", test.ptr)
+			}
+		})
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"Input":         "input",
+		"MaxNumWorkers": "max_num_workers",
+		"InputURL":      "input_url",
+		"URLPrefix":     "url_prefix",
+		"Shard2Count":   "shard2_count",
+	}
+	for in, want := range tests {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%v) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(o *testOptions)
+		want string
+	}{
+		{"valid", func(o *testOptions) { o.Input = "in" }, ""},
+		{"required", func(o *testOptions) {}, "--typed_input is required"},
+		{"enum", func(o *testOptions) { o.Input, o.Mode = "in", "slow" }, "--typed_mode=slow isn't one of: fast, exact"},
+		{"min", func(o *testOptions) { o.Input, o.Shards = "in", -1 }, "--typed_shards=-1 is less than 1"},
+		{"max", func(o *testOptions) { o.Input, o.Shards = "in", 101 }, "--typed_shards=101 is greater than 100"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reset(t)
+			defer reset(t)
+			test.set(opts)
+
+			err := Validate()
+			switch {
+			case test.want == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)):
+				t.Errorf("Validate() = %v, want error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	reset(t)
+	defer reset(t)
+	opts.Input = "gs://bucket/*.txt"
+	opts.Shards = 3
+	opts.Labels = []string{"z"}
+	want := *opts
+
+	// Serialize the options as a runner would, then recover them as a worker
+	// does.
+	typed, err := Export()
+	if err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+	pb, err := provision.OptionsToProto(runtime.RawOptionsWrapper{TypedOptions: typed})
+	if err != nil {
+		t.Fatalf("OptionsToProto failed: %v", err)
+	}
+	data, err := provision.ProtoToJSON(pb)
+	if err != nil {
+		t.Fatalf("ProtoToJSON failed: %v", err)
+	}
+	var raw runtime.RawOptionsWrapper
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		t.Fatalf("unmarshalling options failed: %v", err)
+	}
+
+	reset(t)
+	ctx := NewContext(context.Background(), raw.TypedOptions)
+	var got testOptions
+	if err := FromContext(ctx, &got); err != nil {
+		t.Fatalf("FromContext failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromContext() = %+v, want %+v", got, want)
+	}
+
+	if err := Import(raw.TypedOptions); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if !reflect.DeepEqual(*opts, want) {
+		t.Errorf("options after Import = %+v, want %+v", *opts, want)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	reset(t)
+	defer reset(t)
+	opts.Input = "local"
+
+	var got testOptions
+	if err := FromContext(context.Background(), &got); err != nil {
+		t.Fatalf("FromContext failed: %v", err)
+	}
+	if got.Input != "local" || got.Shards != 10 {
+		t.Errorf("FromContext() = %+v, want the registered values", got)
+	}
+	got.Labels[0] = "changed"
+	if opts.Labels[0] != "a" {
+		t.Errorf("FromContext() shares state with the registered options")
+	}
+
+	var unregistered struct{ Input string }
+	if err := FromContext(context.Background(), &unregistered); err == nil {
+		t.Errorf("FromContext(unregistered) succeeded, want error")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	var b strings.Builder
+	Usage(&b)
+	for _, want := range []string{
+		groupName() + ":",
+		"--typed_input (string)",
+		"Files to read. Required.",
+		`--typed_mode (string, default "fast")`,
+		"One of: fast, exact.",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Usage() = %v, want it to contain %q", b.String(), want)
+		}
+	}
+}
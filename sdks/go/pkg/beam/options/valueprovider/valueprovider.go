@@ -0,0 +1,145 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package valueprovider provides transform parameters whose values may be
+// deferred until the pipeline runs.
+//
+// A Value is either static, and known at pipeline construction time, or a
+// runtime parameter, which is read from the pipeline options on the worker.
+// Runtime parameters allow a pipeline to be built once, saved as a template
+// and launched many times with different parameter values:
+//
+//	input := valueprovider.Runtime("input", "")
+//	lines := textio.ReadValue(s, input)
+//
+// Runtime parameter values are supplied when a template is launched. When a
+// pipeline is run directly, they are taken from the pipeline options, such
+// as flags of the same name or values set with beam.PipelineOptions.Set.
+package valueprovider
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+var (
+	mu     sync.Mutex
+	params = make(map[string]Param) // name -> declared runtime parameter
+)
+
+// Param is a declared runtime parameter.
+type Param struct {
+	// Name is the pipeline option holding the parameter value.
+	Name string `json:"name"`
+	// Default is the value used if no value is supplied. The parameter is
+	// required if empty.
+	Default string `json:"default,omitempty"`
+}
+
+// Value is a string parameter of a transform. Values are JSON serializable
+// and may be used as fields of DoFns.
+type Value struct {
+	// Static is the value of a static parameter.
+	Static string `json:"static,omitempty"`
+	// Param is the runtime parameter, if the value is deferred.
+	Param *Param `json:"param,omitempty"`
+}
+
+// Static returns a Value known at construction time.
+func Static(v string) Value {
+	return Value{Static: v}
+}
+
+// Runtime returns a Value read from the pipeline option name on the worker.
+// If no value is supplied, def is used, unless empty, in which case the
+// parameter is required. Runtime panics if name is empty or was previously
+// declared with a different default.
+func Runtime(name, def string) Value {
+	if name == "" {
+		panic("valueprovider.Runtime: empty parameter name")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if p, ok := params[name]; ok && p.Default != def {
+		panic(fmt.Sprintf("valueprovider.Runtime: parameter %v redeclared with default %q, was %q", name, def, p.Default))
+	}
+	p := Param{Name: name, Default: def}
+	params[name] = p
+	return Value{Param: &p}
+}
+
+// IsRuntime returns true iff the value is a runtime parameter.
+func (v Value) IsRuntime() bool {
+	return v.Param != nil
+}
+
+// Get returns the value. For runtime parameters, it must only be called
+// during pipeline execution, such as from within a DoFn.
+func (v Value) Get() (string, error) {
+	if v.Param == nil {
+		return v.Static, nil
+	}
+	if val := runtime.GlobalOptions.Get(v.Param.Name); val != "" {
+		return val, nil
+	}
+	if v.Param.Default != "" {
+		return v.Param.Default, nil
+	}
+	return "", errors.Errorf("no value for runtime parameter %v", v.Param.Name)
+}
+
+func (v Value) String() string {
+	if v.Param == nil {
+		return v.Static
+	}
+	return fmt.Sprintf("RuntimeValue[%v]", v.Param.Name)
+}
+
+// Params returns the runtime parameters declared in this process, ordered by
+// name.
+func Params() []Param {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var ret []Param
+	for _, p := range params {
+		ret = append(ret, p)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// Validate checks values against the declared parameters ps. It returns an
+// error if a value is supplied for an undeclared parameter, or if a required
+// parameter has no value.
+func Validate(ps []Param, values map[string]string) error {
+	declared := make(map[string]bool)
+	for _, p := range ps {
+		declared[p.Name] = true
+		if p.Default == "" && values[p.Name] == "" {
+			return errors.Errorf("missing value for required parameter %v", p.Name)
+		}
+	}
+	for name := range values {
+		if !declared[name] {
+			return errors.Errorf("unknown parameter %v", name)
+		}
+	}
+	return nil
+}
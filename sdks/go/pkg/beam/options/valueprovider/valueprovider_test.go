@@ -0,0 +1,88 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package valueprovider
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
+)
+
+func TestGet(t *testing.T) {
+	runtime.GlobalOptions.Set("vp_test_set", "supplied")
+
+	tests := []struct {
+		name    string
+		v       Value
+		want    string
+		wantErr bool
+	}{
+		{"static", Static("x"), "x", false},
+		{"supplied", Runtime("vp_test_set", "def"), "supplied", false},
+		{"default", Runtime("vp_test_unset", "def"), "def", false},
+		{"required", Runtime("vp_test_required", ""), "", true},
+	}
+	for _, test := range tests {
+		// Values are carried in DoFns as JSON.
+		data, err := json.Marshal(test.v)
+		if err != nil {
+			t.Fatalf("%v: json.Marshal failed: %v", test.name, err)
+		}
+		var v Value
+		if err := json.Unmarshal(data, &v); err != nil {
+			t.Fatalf("%v: json.Unmarshal failed: %v", test.name, err)
+		}
+
+		got, err := v.Get()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: Get() error = %v, want error %v", test.name, err, test.wantErr)
+		}
+		if got != test.want {
+			t.Errorf("%v: Get() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRuntime_redeclared(t *testing.T) {
+	Runtime("vp_test_redeclared", "a")
+	Runtime("vp_test_redeclared", "a")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Runtime with a different default didn't panic")
+		}
+	}()
+	Runtime("vp_test_redeclared", "b")
+}
+
+func TestValidate(t *testing.T) {
+	ps := []Param{{Name: "input"}, {Name: "shards", Default: "10"}}
+
+	tests := []struct {
+		values  map[string]string
+		wantErr bool
+	}{
+		{map[string]string{"input": "a"}, false},
+		{map[string]string{"input": "a", "shards": "5"}, false},
+		{map[string]string{"shards": "5"}, true},
+		{map[string]string{"input": "a", "output": "b"}, true},
+	}
+	for _, test := range tests {
+		if err := Validate(ps, test.values); (err != nil) != test.wantErr {
+			t.Errorf("Validate(%v) = %v, want error %v", test.values, err, test.wantErr)
+		}
+	}
+}
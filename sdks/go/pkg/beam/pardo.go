@@ -91,6 +91,7 @@ func TryParDo(s Scope, dofn interface{}, col PCollection, opts ...Option) ([]PCo
 	if err != nil {
 		return nil, addParDoCtx(err, s)
 	}
+	edge.ResourceHints = parseResourceHints(opts)
 
 	var ret []PCollection
 	for _, out := range edge.Output {
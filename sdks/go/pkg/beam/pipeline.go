@@ -16,8 +16,12 @@
 package beam
 
 import (
+	"context"
+	"time"
+
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/resource"
 )
 
 // Scope is a hierarchical grouping for composite transforms. Scopes can be
@@ -47,6 +51,20 @@ func (s Scope) Scope(name string) Scope {
 	return Scope{scope: scope, real: s.real}
 }
 
+// WithResourceHints adds resource hints to all transforms in the scope,
+// including those already inserted, and returns the scope. The hints replace
+// earlier hints of the scope with the same URN. Hints of the root scope apply
+// to the whole pipeline.
+//
+//	s = s.Scope("Inference").WithResourceHints(resource.MinRAMBytes(16 << 30))
+func (s Scope) WithResourceHints(hints ...resource.Hint) Scope {
+	if !s.IsValid() {
+		panic("Invalid Scope")
+	}
+	s.scope.ResourceHints = s.scope.ResourceHints.With(hints...)
+	return s
+}
+
 func (s Scope) String() string {
 	if !s.IsValid() {
 		return "<invalid>"
@@ -92,3 +110,64 @@ type PipelineResult interface {
 	Metrics() metrics.Results
 	JobID() string
 }
+
+// JobState is the state of a job executing a pipeline. The states match those
+// of the portable job API.
+type JobState int
+
+// Job states.
+const (
+	JobStateUnspecified JobState = iota
+	JobStateStopped
+	JobStateRunning
+	JobStateDone
+	JobStateFailed
+	JobStateCancelled
+	JobStateUpdated
+	JobStateDraining
+	JobStateDrained
+	JobStateStarting
+	JobStateCancelling
+	JobStateUpdating
+)
+
+var jobStateNames = []string{
+	"UNSPECIFIED", "STOPPED", "RUNNING", "DONE", "FAILED", "CANCELLED",
+	"UPDATED", "DRAINING", "DRAINED", "STARTING", "CANCELLING", "UPDATING",
+}
+
+func (s JobState) String() string {
+	if s < 0 || int(s) >= len(jobStateNames) {
+		return "UNSPECIFIED"
+	}
+	return jobStateNames[s]
+}
+
+// IsTerminal returns true iff the job can no longer change state.
+func (s JobState) IsTerminal() bool {
+	switch s {
+	case JobStateDone, JobStateFailed, JobStateCancelled, JobStateUpdated, JobStateDrained:
+		return true
+	default:
+		return false
+	}
+}
+
+// ControllablePipelineResult is a PipelineResult of a job that can be
+// monitored and controlled while it runs. Runners that submit jobs to a
+// service, such as Dataflow and universal runners, return results that
+// implement it, also for asynchronous execution.
+type ControllablePipelineResult interface {
+	PipelineResult
+
+	// State returns the current state of the job.
+	State(ctx context.Context) (JobState, error)
+	// Cancel requests the job to stop immediately.
+	Cancel(ctx context.Context) error
+	// Drain requests a streaming job to stop reading input and to finish
+	// processing buffered data before stopping.
+	Drain(ctx context.Context) error
+	// WaitUntilFinish waits until the job reaches a terminal state, or until
+	// the timeout expires if positive. It returns the last known state.
+	WaitUntilFinish(ctx context.Context, timeout time.Duration) (JobState, error)
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/typedopts"
 )
 
 // TODO(herohde) 7/6/2017: do we want to make the selected runner visible to
@@ -41,11 +42,15 @@ func RegisterRunner(name string, fn func(ctx context.Context, p *Pipeline) (Pipe
 
 // Run executes the pipeline using the selected registred runner. It is customary
 // to define a "runner" with no default as a flag to let users control runner
-// selection.
+// selection. Registered typed options are validated before the pipeline is
+// handed to the runner.
 func Run(ctx context.Context, runner string, p *Pipeline) (PipelineResult, error) {
 	fn, ok := runners[runner]
 	if !ok {
 		log.Exitf(ctx, "Runner %v not registered. Forgot to _ import it?", runner)
 	}
+	if err := typedopts.Validate(); err != nil {
+		return nil, err
+	}
 	return fn(ctx, p)
 }
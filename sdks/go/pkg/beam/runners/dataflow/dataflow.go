@@ -42,7 +42,9 @@ import (
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/gcpopts"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/jobopts"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/typedopts"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/dataflow/dataflowlib"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/universal/runnerlib"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/util/gcsx"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/x/hooks/perf"
 	"github.com/golang/protobuf/proto"
@@ -50,48 +52,88 @@ import (
 
 // TODO(herohde) 5/16/2017: the Dataflow flags should match the other SDKs.
 
-var (
-	endpoint               = flag.String("dataflow_endpoint", "", "Dataflow endpoint (optional).")
-	stagingLocation        = flag.String("staging_location", "", "GCS staging location (required).")
-	workerHarnessImage     = flag.String("worker_harness_container_image", "", "Worker harness container image (optional). Deprecated in favor of the sdk_container_image flag.")
-	image                  = flag.String("sdk_container_image", "", "Worker harness container image (optional).")
-	labels                 = flag.String("labels", "", "JSON-formatted map[string]string of job labels (optional).")
-	serviceAccountEmail    = flag.String("service_account_email", "", "Service account email (optional).")
-	numWorkers             = flag.Int64("num_workers", 0, "Number of workers (optional).")
-	workerHarnessThreads   = flag.Int64("number_of_worker_harness_threads", 0, "The number of threads per each worker harness process (optional).")
-	maxNumWorkers          = flag.Int64("max_num_workers", 0, "Maximum number of workers during scaling (optional).")
-	diskSizeGb             = flag.Int64("disk_size_gb", 0, "Size of root disk for VMs, in GB (optional).")
-	diskType               = flag.String("disk_type", "", "Type of root disk for VMs (optional).")
-	autoscalingAlgorithm   = flag.String("autoscaling_algorithm", "", "Autoscaling mode to use (optional).")
-	zone                   = flag.String("zone", "", "GCP zone (optional)")
-	kmsKey                 = flag.String("dataflow_kms_key", "", "The Cloud KMS key identifier used to encrypt data at rest (optional).")
-	network                = flag.String("network", "", "GCP network (optional)")
-	subnetwork             = flag.String("subnetwork", "", "GCP subnetwork (optional)")
-	noUsePublicIPs         = flag.Bool("no_use_public_ips", false, "Workers must not use public IP addresses (optional)")
-	tempLocation           = flag.String("temp_location", "", "Temp location (optional)")
-	machineType            = flag.String("worker_machine_type", "", "GCE machine type (optional)")
-	minCPUPlatform         = flag.String("min_cpu_platform", "", "GCE minimum cpu platform (optional)")
-	workerJar              = flag.String("dataflow_worker_jar", "", "Dataflow worker jar (optional)")
-	workerRegion           = flag.String("worker_region", "", "Dataflow worker region (optional)")
-	workerZone             = flag.String("worker_zone", "", "Dataflow worker zone (optional)")
-	dataflowServiceOptions = flag.String("dataflow_service_options", "", "Comma separated list of additional job modes and configurations (optional)")
-	flexRSGoal             = flag.String("flexrs_goal", "", "Which Flexible Resource Scheduling mode to run in (optional)")
+// Options are the options of the Dataflow runner. They are registered as typed
+// options, so DoFns can read them with typedopts.FromContext.
+type Options struct {
+	Endpoint               string `beam:"dataflow_endpoint" help:"Dataflow endpoint (optional)."`
+	StagingLocation        string `help:"GCS staging location (required)."`
+	WorkerHarnessImage     string `beam:"worker_harness_container_image" help:"Worker harness container image (optional). Deprecated in favor of the sdk_container_image flag."`
+	Image                  string `beam:"sdk_container_image" help:"Worker harness container image (optional)."`
+	Labels                 string `help:"JSON-formatted map[string]string of job labels (optional)."`
+	ServiceAccountEmail    string `help:"Service account email (optional)."`
+	NumWorkers             int64  `help:"Number of workers (optional)."`
+	WorkerHarnessThreads   int64  `beam:"number_of_worker_harness_threads" help:"The number of threads per each worker harness process (optional)."`
+	MaxNumWorkers          int64  `help:"Maximum number of workers during scaling (optional)."`
+	DiskSizeGb             int64  `help:"Size of root disk for VMs, in GB (optional)."`
+	DiskType               string `help:"Type of root disk for VMs (optional)."`
+	AutoscalingAlgorithm   string `help:"Autoscaling mode to use (optional)."`
+	Zone                   string `help:"GCP zone (optional)"`
+	KmsKey                 string `beam:"dataflow_kms_key" help:"The Cloud KMS key identifier used to encrypt data at rest (optional)."`
+	Network                string `help:"GCP network (optional)"`
+	Subnetwork             string `help:"GCP subnetwork (optional)"`
+	NoUsePublicIPs         bool   `beam:"no_use_public_ips" help:"Workers must not use public IP addresses (optional)"`
+	TempLocation           string `help:"Temp location (optional)"`
+	MachineType            string `beam:"worker_machine_type" help:"GCE machine type (optional)"`
+	MinCPUPlatform         string `help:"GCE minimum cpu platform (optional)"`
+	WorkerJar              string `beam:"dataflow_worker_jar" help:"Dataflow worker jar (optional)"`
+	WorkerRegion           string `help:"Dataflow worker region (optional)"`
+	WorkerZone             string `help:"Dataflow worker zone (optional)"`
+	DataflowServiceOptions string `help:"Comma separated list of additional job modes and configurations (optional)"`
+	FlexRSGoal             string `beam:"flexrs_goal" help:"Which Flexible Resource Scheduling mode to run in (optional)"`
 	// TODO(https://github.com/apache/beam/issues/21604) Turn this on once TO_STRING is implemented
-	// enableHotKeyLogging    = flag.Bool("enable_hot_key_logging", false, "Specifies that when a hot key is detected in the pipeline, the literal, human-readable key is printed in the user's Cloud Logging project (optional).")
+	// EnableHotKeyLogging bool `help:"Specifies that when a hot key is detected in the pipeline, the literal, human-readable key is printed in the user's Cloud Logging project (optional)."`
 
 	// Streaming update flags
-	update           = flag.Bool("update", false, "Submit this job as an update to an existing Dataflow job (optional); the job name must match the existing job to update")
-	transformMapping = flag.String("transform_name_mapping", "", "JSON-formatted mapping of old transform names to new transform names for pipeline updates (optional)")
+	Update           bool   `help:"Submit this job as an update to an existing Dataflow job (optional); the job name must match the existing job to update"`
+	TransformMapping string `beam:"transform_name_mapping" help:"JSON-formatted mapping of old transform names to new transform names for pipeline updates (optional)"`
 
-	dryRun           = flag.Bool("dry_run", false, "Dry run. Just print the job, but don't submit it.")
-	teardownPolicy   = flag.String("teardown_policy", "", "Job teardown policy (internal only).")
-	templateLocation = flag.String("template_location", "", "GCS location to save the job graph. If set, the job is not submitted to Dataflow (optional.)")
+	DryRun         bool   `help:"Dry run. Just print the job, but don't submit it."`
+	TeardownPolicy string `help:"Job teardown policy (internal only)."`
 
 	// SDK options
-	cpuProfiling = flag.String("cpu_profiling", "", "Job records CPU profiles to this GCS location (optional)")
+	CPUProfiling string `help:"Job records CPU profiles to this GCS location (optional)"`
+}
+
+var dataflowOpts = &Options{}
+
+var (
+	endpoint               = &dataflowOpts.Endpoint
+	stagingLocation        = &dataflowOpts.StagingLocation
+	workerHarnessImage     = &dataflowOpts.WorkerHarnessImage
+	image                  = &dataflowOpts.Image
+	labels                 = &dataflowOpts.Labels
+	serviceAccountEmail    = &dataflowOpts.ServiceAccountEmail
+	numWorkers             = &dataflowOpts.NumWorkers
+	workerHarnessThreads   = &dataflowOpts.WorkerHarnessThreads
+	maxNumWorkers          = &dataflowOpts.MaxNumWorkers
+	diskSizeGb             = &dataflowOpts.DiskSizeGb
+	diskType               = &dataflowOpts.DiskType
+	autoscalingAlgorithm   = &dataflowOpts.AutoscalingAlgorithm
+	zone                   = &dataflowOpts.Zone
+	kmsKey                 = &dataflowOpts.KmsKey
+	network                = &dataflowOpts.Network
+	subnetwork             = &dataflowOpts.Subnetwork
+	noUsePublicIPs         = &dataflowOpts.NoUsePublicIPs
+	tempLocation           = &dataflowOpts.TempLocation
+	machineType            = &dataflowOpts.MachineType
+	minCPUPlatform         = &dataflowOpts.MinCPUPlatform
+	workerJar              = &dataflowOpts.WorkerJar
+	workerRegion           = &dataflowOpts.WorkerRegion
+	workerZone             = &dataflowOpts.WorkerZone
+	dataflowServiceOptions = &dataflowOpts.DataflowServiceOptions
+	flexRSGoal             = &dataflowOpts.FlexRSGoal
+
+	update           = &dataflowOpts.Update
+	transformMapping = &dataflowOpts.TransformMapping
+
+	dryRun         = &dataflowOpts.DryRun
+	teardownPolicy = &dataflowOpts.TeardownPolicy
+
+	cpuProfiling = &dataflowOpts.CPUProfiling
 )
 
 func init() {
+	typedopts.Register(dataflowOpts)
 	flag.BoolVar(jobopts.Async, "execute_async", false, "Asynchronous execution. Submit the job and return immediately. Alias of --async.")
 }
 
@@ -205,7 +247,11 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 	if err != nil {
 		return nil, errors.WithContext(err, "creating environment for model pipeline")
 	}
-	model, err := graphx.Marshal(edges, &graphx.Options{Environment: environment})
+	hints, err := jobopts.GetResourceHints()
+	if err != nil {
+		return nil, err
+	}
+	model, err := graphx.Marshal(edges, &graphx.Options{Environment: environment, ResourceHints: hints})
 	if err != nil {
 		return nil, errors.WithContext(err, "generating model pipeline")
 	}
@@ -226,9 +272,35 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 		return nil, nil
 	}
 
+	if err := dataflowlib.StageDeclaredArtifacts(ctx, opts.Project, gcsx.Join(*stagingLocation, "artifacts"), model); err != nil {
+		return nil, errors.WithContext(err, "staging declared artifacts")
+	}
 	return dataflowlib.Execute(ctx, model, opts, workerURL, jarURL, modelURL, *endpoint, *jobopts.Async)
 }
 
+// LaunchTemplate launches a job from the template staged at location, with
+// the given runtime parameter values. The project, region and job name are
+// taken from flags. It returns the job id without waiting for completion.
+func LaunchTemplate(ctx context.Context, location string, params map[string]string) (string, error) {
+	project := gcpopts.GetProjectFromFlagOrEnvironment(ctx)
+	if project == "" {
+		return "", errors.New("no Google Cloud project specified. Use --project=<project>")
+	}
+	region := gcpopts.GetRegion(ctx)
+	if region == "" {
+		return "", errors.New("No Google Cloud region specified. Use --region=<region>. See https://cloud.google.com/dataflow/docs/concepts/regional-endpoints")
+	}
+	client, err := dataflowlib.NewClient(ctx, *endpoint)
+	if err != nil {
+		return "", err
+	}
+	job, err := dataflowlib.LaunchTemplate(ctx, client, project, region, location, jobopts.GetJobName(), params)
+	if err != nil {
+		return "", err
+	}
+	return job.Id, nil
+}
+
 func getJobOptions(ctx context.Context) (*dataflowlib.JobOptions, error) {
 	project := gcpopts.GetProjectFromFlagOrEnvironment(ctx)
 	if project == "" {
@@ -307,11 +379,16 @@ func getJobOptions(ctx context.Context) (*dataflowlib.JobOptions, error) {
 	}
 
 	beam.PipelineOptions.LoadOptionsFromFlags(flagFilter)
+	typed, err := typedopts.Export()
+	if err != nil {
+		return nil, errors.WithContext(err, "producing typed pipeline options")
+	}
 	opts := &dataflowlib.JobOptions{
 		Name:                   jobopts.GetJobName(),
 		Experiments:            experiments,
 		DataflowServiceOptions: dfServiceOptions,
 		Options:                beam.PipelineOptions.Export(),
+		TypedOptions:           typed,
 		Project:                project,
 		Region:                 region,
 		Zone:                   *zone,
@@ -330,8 +407,9 @@ func getJobOptions(ctx context.Context) (*dataflowlib.JobOptions, error) {
 		Labels:                 jobLabels,
 		ServiceAccountEmail:    *serviceAccountEmail,
 		TempLocation:           *tempLocation,
-		TemplateLocation:       *templateLocation,
+		TemplateLocation:       *jobopts.TemplateLocation,
 		Worker:                 *jobopts.WorkerBinary,
+		WorkerBuild:            workerBuildOptions(),
 		WorkerJar:              *workerJar,
 		WorkerRegion:           *workerRegion,
 		WorkerZone:             *workerZone,
@@ -378,3 +456,14 @@ func getContainerImage(ctx context.Context) string {
 	}
 	panic(fmt.Sprintf("Unsupported environment %v", urn))
 }
+
+// workerBuildOptions returns the options for cross-compiling the worker binary.
+func workerBuildOptions() runnerlib.BuildOptions {
+	return runnerlib.BuildOptions{
+		GOARCH:   *jobopts.WorkerArch,
+		Static:   *jobopts.WorkerStatic,
+		Tags:     jobopts.GetWorkerBuildTags(),
+		Flags:    jobopts.GetWorkerBuildFlags(),
+		CacheDir: *jobopts.WorkerCacheDir,
+	}
+}
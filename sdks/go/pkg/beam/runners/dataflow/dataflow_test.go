@@ -17,11 +17,13 @@ package dataflow
 
 import (
 	"context"
+	"flag"
 	"sort"
 	"testing"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/gcpopts"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/jobopts"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/typedopts"
 )
 
 func TestDontUseFlagAsPipelineOption(t *testing.T) {
@@ -250,6 +252,35 @@ func TestGetJobOptions_InvalidMapping(t *testing.T) {
 	}
 }
 
+func TestOptions_typed(t *testing.T) {
+	defer func(prev Options) { *dataflowOpts = prev }(*dataflowOpts)
+	for name, value := range map[string]string{
+		"dataflow_endpoint": "https://dataflow.example.com",
+		"num_workers":       "3",
+		"no_use_public_ips": "true",
+		"flexrs_goal":       "FLEXRS_COST_OPTIMIZED",
+	} {
+		if err := flag.Set(name, value); err != nil {
+			t.Fatalf("flag.Set(%v) failed: %v", name, err)
+		}
+	}
+	if *endpoint != "https://dataflow.example.com" || *numWorkers != 3 || !*noUsePublicIPs || *flexRSGoal != "FLEXRS_COST_OPTIMIZED" {
+		t.Errorf("flags = %+v, want them set", *dataflowOpts)
+	}
+
+	values, err := typedopts.Export()
+	if err != nil {
+		t.Fatalf("typedopts.Export() failed: %v", err)
+	}
+	var got Options
+	if err := typedopts.FromContext(typedopts.NewContext(context.Background(), values), &got); err != nil {
+		t.Fatalf("typedopts.FromContext() failed: %v", err)
+	}
+	if got != *dataflowOpts {
+		t.Errorf("typedopts.FromContext() = %+v, want %+v", got, *dataflowOpts)
+	}
+}
+
 func resetGlobals() {
 	*autoscalingAlgorithm = ""
 	*dataflowServiceOptions = ""
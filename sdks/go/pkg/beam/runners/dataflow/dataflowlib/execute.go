@@ -21,13 +21,16 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"time"
 
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/protox"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
 	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/valueprovider"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/universal/runnerlib"
 	"github.com/golang/protobuf/proto"
 	df "google.golang.org/api/dataflow/v1b3"
@@ -47,7 +50,7 @@ func Execute(ctx context.Context, raw *pipepb.Pipeline, opts *JobOptions, worker
 		} else {
 			// Cross-compile as last resort.
 
-			worker, err := runnerlib.BuildTempWorkerBinary(ctx)
+			worker, err := runnerlib.BuildTempWorkerBinaryWithOptions(ctx, opts.WorkerBuild)
 			if err != nil {
 				return presult, err
 			}
@@ -113,6 +116,13 @@ func Execute(ctx context.Context, raw *pipepb.Pipeline, opts *JobOptions, worker
 		if err := StageModel(ctx, opts.Project, opts.TemplateLocation, marshalled); err != nil {
 			return presult, err
 		}
+		metadata, err := json.Marshal(newTemplateMetadata(job.Name, valueprovider.Params()))
+		if err != nil {
+			return presult, err
+		}
+		if err := StageModel(ctx, opts.Project, opts.TemplateLocation+"_metadata", metadata); err != nil {
+			return presult, err
+		}
 		log.Infof(ctx, "Template staged to %v", opts.TemplateLocation)
 		return nil, nil
 	}
@@ -138,6 +148,9 @@ func Execute(ctx context.Context, raw *pipepb.Pipeline, opts *JobOptions, worker
 	log.Infof(ctx, "Logs: https://console.cloud.google.com/logs/viewer?project=%v&resource=dataflow_step%%2Fjob_id%%2F%v", opts.Project, upd.Id)
 
 	presult.jobID = upd.Id
+	presult.client = client
+	presult.project = opts.Project
+	presult.region = opts.Region
 
 	if async {
 		return presult, nil
@@ -168,14 +181,19 @@ func PrintJob(ctx context.Context, job *df.Job) {
 type dataflowPipelineResult struct {
 	jobID   string
 	metrics *metrics.Results
+
+	client          *df.Service
+	project, region string
 }
 
 func newDataflowPipelineResult(ctx context.Context, client *df.Service, p *pipepb.Pipeline, project, region, jobID string) (*dataflowPipelineResult, error) {
+	ret := &dataflowPipelineResult{jobID: jobID, client: client, project: project, region: region}
 	res, err := GetMetrics(ctx, client, project, region, jobID)
 	if err != nil {
-		return &dataflowPipelineResult{jobID, nil}, errors.Wrap(err, "failed to get metrics")
+		return ret, errors.Wrap(err, "failed to get metrics")
 	}
-	return &dataflowPipelineResult{jobID, FromMetricUpdates(res.Metrics, p)}, nil
+	ret.metrics = FromMetricUpdates(res.Metrics, p)
+	return ret, nil
 }
 
 func (pr dataflowPipelineResult) Metrics() metrics.Results {
@@ -185,3 +203,31 @@ func (pr dataflowPipelineResult) Metrics() metrics.Results {
 func (pr dataflowPipelineResult) JobID() string {
 	return pr.jobID
 }
+
+func (pr dataflowPipelineResult) State(ctx context.Context) (beam.JobState, error) {
+	if pr.client == nil {
+		return beam.JobStateUnspecified, errors.New("job was not submitted")
+	}
+	return GetJobState(ctx, pr.client, pr.project, pr.region, pr.jobID)
+}
+
+func (pr dataflowPipelineResult) Cancel(ctx context.Context) error {
+	if pr.client == nil {
+		return errors.New("job was not submitted")
+	}
+	return Cancel(ctx, pr.client, pr.project, pr.region, pr.jobID)
+}
+
+func (pr dataflowPipelineResult) Drain(ctx context.Context) error {
+	if pr.client == nil {
+		return errors.New("job was not submitted")
+	}
+	return Drain(ctx, pr.client, pr.project, pr.region, pr.jobID)
+}
+
+func (pr dataflowPipelineResult) WaitUntilFinish(ctx context.Context, timeout time.Duration) (beam.JobState, error) {
+	if pr.client == nil {
+		return beam.JobStateUnspecified, errors.New("job was not submitted")
+	}
+	return WaitUntilFinish(ctx, pr.client, pr.project, pr.region, pr.jobID, timeout)
+}
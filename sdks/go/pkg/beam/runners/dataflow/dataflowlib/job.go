@@ -17,6 +17,7 @@ package dataflowlib
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -30,6 +31,7 @@ import (
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
 	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/universal/runnerlib"
 	"golang.org/x/oauth2/google"
 	df "google.golang.org/api/dataflow/v1b3"
 )
@@ -45,6 +47,8 @@ type JobOptions struct {
 	DataflowServiceOptions []string
 	// Pipeline options
 	Options runtime.RawOptions
+	// Typed pipeline options, keyed by the qualified name of their type.
+	TypedOptions map[string]json.RawMessage
 
 	Project             string
 	Region              string
@@ -80,6 +84,9 @@ type JobOptions struct {
 
 	// Worker is the worker binary override.
 	Worker string
+	// WorkerBuild are the options for cross-compiling the worker binary, if
+	// not overridden.
+	WorkerBuild runnerlib.BuildOptions
 	// WorkerJar is a custom worker jar.
 	WorkerJar string
 
@@ -187,7 +194,8 @@ func Translate(ctx context.Context, p *pipepb.Pipeline, opts *JobOptions, worker
 					Experiments:  experiments,
 					TempLocation: opts.TempLocation,
 				},
-				GoOptions: opts.Options,
+				GoOptions:      opts.Options,
+				GoTypedOptions: opts.TypedOptions,
 			}),
 			ServiceOptions:    opts.DataflowServiceOptions,
 			ServiceKmsKeyName: opts.KmsKey,
@@ -246,7 +254,7 @@ func Translate(ctx context.Context, p *pipepb.Pipeline, opts *JobOptions, worker
 // Submit submits a prepared job to Cloud Dataflow.
 func Submit(ctx context.Context, client *df.Service, project, region string, job *df.Job, updateJob bool) (*df.Job, error) {
 	if updateJob {
-		runningJob, err := GetRunningJobByName(client, project, region, job.Name)
+		runningJob, err := ValidateUpdate(ctx, client, project, region, job)
 		if err != nil {
 			return nil, err
 		}
@@ -259,6 +267,21 @@ func Submit(ctx context.Context, client *df.Service, project, region string, job
 	return upd, err
 }
 
+// LaunchTemplate launches a job from the template staged at gcsPath, with the
+// given runtime parameter values.
+func LaunchTemplate(ctx context.Context, client *df.Service, project, region, gcsPath, jobName string, params map[string]string) (*df.Job, error) {
+	req := &df.LaunchTemplateParameters{
+		JobName:    jobName,
+		Parameters: params,
+	}
+	resp, err := client.Projects.Locations.Templates.Launch(project, region, req).GcsPath(gcsPath).Context(ctx).Do()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to launch template %v", gcsPath)
+	}
+	log.Infof(ctx, "Launched job: %v", resp.Job.Id)
+	return resp.Job, nil
+}
+
 // WaitForCompletion monitors the given job until completion. It logs any messages
 // and state changes received.
 func WaitForCompletion(ctx context.Context, client *df.Service, project, region, jobID string) error {
@@ -277,7 +300,7 @@ func WaitForCompletion(ctx context.Context, client *df.Service, project, region,
 			return nil
 		}
 
-		time.Sleep(30 * time.Second)
+		time.Sleep(pollInterval)
 	}
 }
 
@@ -332,18 +355,24 @@ func GetRunningJobByName(client *df.Service, project, region string, name string
 	jobsListCall := client.Projects.Locations.Jobs.List(project, region)
 	jobsListCall.Filter("ACTIVE")
 	jobsResponse, err := jobsListCall.Do()
+	if err != nil {
+		return nil, err
+	}
 	for len(jobsResponse.Jobs) > 0 {
-		if err != nil {
-			return nil, err
-		}
 		for _, job := range jobsResponse.Jobs {
 			if job.Name == name {
 				return job, nil
 			}
 		}
 
+		if jobsResponse.NextPageToken == "" {
+			break
+		}
 		jobsListCall.PageToken(jobsResponse.NextPageToken)
 		jobsResponse, err = jobsListCall.Do()
+		if err != nil {
+			return nil, err
+		}
 	}
 	return nil, errors.New(fmt.Sprintf("Unable to find running job with name %s", name))
 }
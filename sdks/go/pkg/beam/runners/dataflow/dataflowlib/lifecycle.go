@@ -0,0 +1,105 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataflowlib
+
+import (
+	"context"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	df "google.golang.org/api/dataflow/v1b3"
+)
+
+// pollInterval is the time between job state requests while waiting.
+var pollInterval = 30 * time.Second
+
+var jobStates = map[string]beam.JobState{
+	"JOB_STATE_STOPPED":              beam.JobStateStopped,
+	"JOB_STATE_RUNNING":              beam.JobStateRunning,
+	"JOB_STATE_DONE":                 beam.JobStateDone,
+	"JOB_STATE_FAILED":               beam.JobStateFailed,
+	"JOB_STATE_CANCELLED":            beam.JobStateCancelled,
+	"JOB_STATE_UPDATED":              beam.JobStateUpdated,
+	"JOB_STATE_DRAINING":             beam.JobStateDraining,
+	"JOB_STATE_DRAINED":              beam.JobStateDrained,
+	"JOB_STATE_PENDING":              beam.JobStateStarting,
+	"JOB_STATE_QUEUED":               beam.JobStateStarting,
+	"JOB_STATE_CANCELLING":           beam.JobStateCancelling,
+	"JOB_STATE_RESOURCE_CLEANING_UP": beam.JobStateCancelling,
+}
+
+// jobState converts a Dataflow job state into a Beam job state. Unknown
+// states are unspecified.
+func jobState(s string) beam.JobState {
+	return jobStates[s]
+}
+
+// GetJobState returns the current state of the given job.
+func GetJobState(ctx context.Context, client *df.Service, project, region, jobID string) (beam.JobState, error) {
+	j, err := client.Projects.Locations.Jobs.Get(project, region, jobID).Context(ctx).Do()
+	if err != nil {
+		return beam.JobStateUnspecified, errors.Wrapf(err, "failed to get job %v", jobID)
+	}
+	return jobState(j.CurrentState), nil
+}
+
+// Cancel requests the given job to be cancelled.
+func Cancel(ctx context.Context, client *df.Service, project, region, jobID string) error {
+	return requestState(ctx, client, project, region, jobID, "JOB_STATE_CANCELLED")
+}
+
+// Drain requests the given streaming job to be drained.
+func Drain(ctx context.Context, client *df.Service, project, region, jobID string) error {
+	return requestState(ctx, client, project, region, jobID, "JOB_STATE_DRAINED")
+}
+
+func requestState(ctx context.Context, client *df.Service, project, region, jobID, state string) error {
+	update := &df.Job{RequestedState: state}
+	if _, err := client.Projects.Locations.Jobs.Update(project, region, jobID, update).Context(ctx).Do(); err != nil {
+		return errors.Wrapf(err, "failed to request state %v for job %v", state, jobID)
+	}
+	log.Infof(ctx, "Requested state %v for job %v", state, jobID)
+	return nil
+}
+
+// WaitUntilFinish polls the given job until it reaches a terminal state, or
+// until the timeout expires if positive. It returns the last known state.
+func WaitUntilFinish(ctx context.Context, client *df.Service, project, region, jobID string, timeout time.Duration) (beam.JobState, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	last := beam.JobStateUnspecified
+	for {
+		state, err := GetJobState(ctx, client, project, region, jobID)
+		if err != nil {
+			return last, err
+		}
+		if state.IsTerminal() {
+			return state, nil
+		}
+		last = state
+
+		select {
+		case <-ctx.Done():
+			return last, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
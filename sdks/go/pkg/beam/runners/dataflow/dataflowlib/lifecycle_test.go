@@ -0,0 +1,214 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataflowlib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	df "google.golang.org/api/dataflow/v1b3"
+	"google.golang.org/api/option"
+)
+
+// fakeDataflow serves the parts of the Dataflow jobs API used by the runner
+// for a single job.
+type fakeDataflow struct {
+	mu        sync.Mutex
+	job       *df.Job
+	states    []string // successive states returned by get requests
+	requested []string
+	created   []*df.Job
+}
+
+func (f *fakeDataflow) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	const prefix = "/v1b3/projects/p/locations/r/jobs"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == prefix:
+		json.NewEncoder(w).Encode(&df.ListJobsResponse{Jobs: []*df.Job{f.job}})
+	case r.Method == http.MethodPost && r.URL.Path == prefix:
+		var j df.Job
+		json.NewDecoder(r.Body).Decode(&j)
+		f.created = append(f.created, &j)
+		j.Id = "new"
+		json.NewEncoder(w).Encode(&j)
+	case r.Method == http.MethodGet && r.URL.Path == prefix+"/"+f.job.Id:
+		if len(f.states) > 0 {
+			f.job.CurrentState = f.states[0]
+			if len(f.states) > 1 {
+				f.states = f.states[1:]
+			}
+		}
+		ret := *f.job
+		if r.URL.Query().Get("view") != "JOB_VIEW_ALL" {
+			ret.Steps = nil
+		}
+		json.NewEncoder(w).Encode(&ret)
+	case r.Method == http.MethodPut && r.URL.Path == prefix+"/"+f.job.Id:
+		var j df.Job
+		json.NewDecoder(r.Body).Decode(&j)
+		f.requested = append(f.requested, j.RequestedState)
+		json.NewEncoder(w).Encode(f.job)
+	default:
+		http.Error(w, "unexpected request "+r.Method+" "+r.URL.String(), http.StatusNotFound)
+	}
+}
+
+func newFakeClient(t *testing.T, f *fakeDataflow) *df.Service {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	client, err := df.NewService(context.Background(), option.WithHTTPClient(srv.Client()), option.WithEndpoint(srv.URL+"/"))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+func newStep(kind, userName string) *df.Step {
+	return &df.Step{Name: userName, Kind: kind, Properties: newMsg(properties{UserName: userName})}
+}
+
+func TestDataflowPipelineResult(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	ctx := context.Background()
+	f := &fakeDataflow{
+		job:    &df.Job{Id: "j", Name: "job"},
+		states: []string{"JOB_STATE_RUNNING", "JOB_STATE_RUNNING", "JOB_STATE_CANCELLING", "JOB_STATE_CANCELLED"},
+	}
+	var pr beam.ControllablePipelineResult = dataflowPipelineResult{jobID: "j", client: newFakeClient(t, f), project: "p", region: "r"}
+
+	if state, err := pr.State(ctx); err != nil || state != beam.JobStateRunning {
+		t.Errorf("State() = %v, %v, want %v", state, err, beam.JobStateRunning)
+	}
+	if err := pr.Cancel(ctx); err != nil {
+		t.Fatalf("Cancel() failed: %v", err)
+	}
+	if err := pr.Drain(ctx); err != nil {
+		t.Fatalf("Drain() failed: %v", err)
+	}
+	if got, want := strings.Join(f.requested, ","), "JOB_STATE_CANCELLED,JOB_STATE_DRAINED"; got != want {
+		t.Errorf("requested states = %v, want %v", got, want)
+	}
+	if state, err := pr.WaitUntilFinish(ctx, time.Minute); err != nil || state != beam.JobStateCancelled {
+		t.Errorf("WaitUntilFinish() = %v, %v, want %v", state, err, beam.JobStateCancelled)
+	}
+}
+
+func TestWaitUntilFinish_timeout(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	f := &fakeDataflow{job: &df.Job{Id: "j", Name: "job"}, states: []string{"JOB_STATE_RUNNING"}}
+	client := newFakeClient(t, f)
+
+	state, err := WaitUntilFinish(context.Background(), client, "p", "r", "j", 20*time.Millisecond)
+	if err == nil {
+		t.Errorf("WaitUntilFinish() succeeded, want timeout error")
+	}
+	if state != beam.JobStateRunning {
+		t.Errorf("WaitUntilFinish() = %v, want %v", state, beam.JobStateRunning)
+	}
+}
+
+func TestSubmit_update(t *testing.T) {
+	running := []*df.Step{
+		newStep(impulseKind, "Impulse"),
+		newStep(parDoKind, "Parse/Split"),
+		newStep(parDoKind, "Count"),
+	}
+
+	tests := []struct {
+		name    string
+		steps   []*df.Step
+		mapping map[string]string
+		errs    []string
+	}{
+		{
+			name:  "unchanged",
+			steps: running,
+		},
+		{
+			name:    "renamed composite",
+			steps:   []*df.Step{running[0], newStep(parDoKind, "Tokenize/Split"), running[2]},
+			mapping: map[string]string{"Parse": "Tokenize"},
+		},
+		{
+			name:    "removed",
+			steps:   running[:2],
+			mapping: map[string]string{"Count": ""},
+		},
+		{
+			name:  "missing",
+			steps: running[:2],
+			errs:  []string{`transform "Count" of the running job is missing`},
+		},
+		{
+			name:  "changed kind",
+			steps: []*df.Step{running[0], running[1], newStep(gbkKind, "Count")},
+			errs:  []string{`transform "Count" changed from ParallelDo to GroupByKey`},
+		},
+		{
+			name:    "bad mapping",
+			steps:   running,
+			mapping: map[string]string{"Bogus": "Count", "Count": "Other"},
+			errs: []string{
+				`refers to "Bogus", which isn't a transform of the running job`,
+				`maps "Count" to "Other", which isn't a transform of the new job`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := &fakeDataflow{job: &df.Job{Id: "old", Name: "job", CurrentState: "JOB_STATE_RUNNING", Steps: running}}
+			client := newFakeClient(t, f)
+
+			job := &df.Job{Name: "job", Steps: test.steps, TransformNameMapping: test.mapping}
+			_, err := Submit(context.Background(), client, "p", "r", job, true)
+			if len(test.errs) == 0 {
+				if err != nil {
+					t.Fatalf("Submit() failed: %v", err)
+				}
+				if len(f.created) != 1 || f.created[0].ReplaceJobId != "old" {
+					t.Errorf("Submit() created %v, want a job replacing old", f.created)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Submit() succeeded, want error")
+			}
+			for _, want := range test.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Submit() error = %v, want it to contain %q", err, want)
+				}
+			}
+			if len(f.created) != 0 {
+				t.Errorf("Submit() created a job despite validation errors")
+			}
+		})
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/valueprovider"
 	"google.golang.org/api/googleapi"
 )
 
@@ -37,9 +38,41 @@ func newMsg(msg interface{}) googleapi.RawMessage {
 
 // pipelineOptions models Job/Environment/SdkPipelineOptions
 type pipelineOptions struct {
-	DisplayData []*displayData     `json:"display_data,omitempty"`
-	Options     interface{}        `json:"options,omitempty"`
-	GoOptions   runtime.RawOptions `json:"beam:option:go_options:v1,omitempty"`
+	DisplayData    []*displayData             `json:"display_data,omitempty"`
+	Options        interface{}                `json:"options,omitempty"`
+	GoOptions      runtime.RawOptions         `json:"beam:option:go_options:v1,omitempty"`
+	GoTypedOptions map[string]json.RawMessage `json:"beam:option:go_typed_options:v1,omitempty"`
+}
+
+// templateMetadata models the metadata file of a classic template, which
+// declares its runtime parameters.
+type templateMetadata struct {
+	Name       string               `json:"name"`
+	Parameters []*templateParameter `json:"parameters"`
+}
+
+type templateParameter struct {
+	Name       string `json:"name"`
+	Label      string `json:"label"`
+	HelpText   string `json:"helpText"`
+	IsOptional bool   `json:"isOptional,omitempty"`
+}
+
+func newTemplateMetadata(name string, params []valueprovider.Param) *templateMetadata {
+	ret := &templateMetadata{Name: name, Parameters: []*templateParameter{}}
+	for _, p := range params {
+		help := fmt.Sprintf("Runtime parameter %v.", p.Name)
+		if p.Default != "" {
+			help = fmt.Sprintf("Runtime parameter %v. Defaults to %q.", p.Name, p.Default)
+		}
+		ret.Parameters = append(ret.Parameters, &templateParameter{
+			Name:       p.Name,
+			Label:      p.Name,
+			HelpText:   help,
+			IsOptional: p.Default != "",
+		})
+	}
+	return ret
 }
 
 // NOTE(herohde) 2/9/2017: most of the v1b3 messages are weakly-typed json
@@ -24,10 +24,14 @@ import (
 	"os"
 
 	"cloud.google.com/go/storage"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/artifact"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/xlangx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/protox"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/util/gcsx"
+	"github.com/golang/protobuf/proto"
 )
 
 // StageModel uploads the pipeline model to GCS as a unique object.
@@ -90,3 +94,42 @@ func ResolveXLangArtifacts(ctx context.Context, edges []*graph.MultiEdge, projec
 	}
 	return urls, nil
 }
+
+// StageDeclaredArtifacts uploads the files of declared artifacts in the
+// pipeline environments to the given GCS URL, and changes their dependencies
+// to refer to the uploaded objects. Objects are named by their staged names,
+// which are content-addressed, so identical files are uploaded to the same
+// object.
+func StageDeclaredArtifacts(ctx context.Context, project, url string, p *pipepb.Pipeline) error {
+	// Environments with resource hints share the artifacts of the default one.
+	hashes := make(map[string]string)
+	for _, env := range p.GetComponents().GetEnvironments() {
+		for _, dep := range env.GetDependencies() {
+			if dep.GetTypeUrn() != artifact.URNFileArtifact || dep.GetRoleUrn() != artifact.URNStagingTo {
+				continue
+			}
+			var file pipepb.ArtifactFilePayload
+			if err := proto.Unmarshal(dep.GetTypePayload(), &file); err != nil {
+				return errors.Wrap(err, "invalid artifact file payload")
+			}
+			var role pipepb.ArtifactStagingToRolePayload
+			if err := proto.Unmarshal(dep.GetRolePayload(), &role); err != nil {
+				return errors.Wrap(err, "invalid artifact staging payload")
+			}
+
+			remote := gcsx.Join(url, role.GetStagedName())
+			hash, ok := hashes[remote]
+			if !ok {
+				var err error
+				hash, err = stageFile(ctx, project, remote, file.GetPath())
+				if err != nil {
+					return errors.WithContextf(err, "staging artifact %v", role.GetStagedName())
+				}
+				hashes[remote] = hash
+			}
+			dep.TypeUrn = artifact.URNUrlArtifact
+			dep.TypePayload = protox.MustEncode(&pipepb.ArtifactUrlPayload{Url: remote, Sha256: hash})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataflowlib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	df "google.golang.org/api/dataflow/v1b3"
+)
+
+// ValidateUpdate checks that the running job with the same name as the given
+// job can be updated in place by it. It fetches the graph of the running job
+// and verifies that every one of its steps maps to a step of the same kind in
+// the new job, by name or through the transform name mapping, and that the
+// mapping refers to existing steps. It returns the running job.
+//
+// Jobs without steps, such as portable jobs, are not validated.
+func ValidateUpdate(ctx context.Context, client *df.Service, project, region string, job *df.Job) (*df.Job, error) {
+	running, err := GetRunningJobByName(client, project, region, job.Name)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := client.Projects.Locations.Jobs.Get(project, region, running.Id).View("JOB_VIEW_ALL").Context(ctx).Do()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get graph of running job %v", running.Id)
+	}
+	if len(prev.Steps) == 0 || len(job.Steps) == 0 {
+		log.Infof(ctx, "Not validating update of job %v: no steps to compare", running.Id)
+		return running, nil
+	}
+	if err := checkUpdateSteps(prev.Steps, job.Steps, job.TransformNameMapping); err != nil {
+		return nil, errors.WithContextf(err, "validating update of job %v", running.Id)
+	}
+	return running, nil
+}
+
+// checkUpdateSteps returns an error listing all incompatibilities between the
+// steps of a running job and those replacing them.
+func checkUpdateSteps(prev, next []*df.Step, mapping map[string]string) error {
+	prevKinds, err := stepKinds(prev)
+	if err != nil {
+		return err
+	}
+	nextKinds, err := stepKinds(next)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, from := range sortedKeys(mapping) {
+		if !hasName(prevKinds, from) {
+			problems = append(problems, fmt.Sprintf("transform name mapping refers to %q, which isn't a transform of the running job", from))
+		}
+		if to := mapping[from]; to != "" && !hasName(nextKinds, to) {
+			problems = append(problems, fmt.Sprintf("transform name mapping maps %q to %q, which isn't a transform of the new job", from, to))
+		}
+	}
+	for _, name := range sortedKeys(prevKinds) {
+		mapped, ok := mapName(mapping, name)
+		if !ok {
+			continue // removed through the mapping
+		}
+		kind, ok := nextKinds[mapped]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("transform %q of the running job is missing from the new job; add it to the transform name mapping", name))
+			continue
+		}
+		if prevKind := prevKinds[name]; kind != prevKind {
+			problems = append(problems, fmt.Sprintf("transform %q changed from %v to %v", name, prevKind, kind))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("job can't be updated:\n\t%v", strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// stepKinds returns the kinds of the steps, keyed by user name.
+func stepKinds(steps []*df.Step) (map[string]string, error) {
+	ret := make(map[string]string)
+	for _, s := range steps {
+		var prop struct {
+			UserName string `json:"user_name"`
+		}
+		if err := json.Unmarshal(s.Properties, &prop); err != nil {
+			return nil, errors.Wrapf(err, "invalid properties of step %v", s.Name)
+		}
+		if prop.UserName == "" {
+			continue
+		}
+		ret[prop.UserName] = s.Kind
+	}
+	return ret, nil
+}
+
+// mapName returns the name of a transform after applying the mapping to it or
+// its longest mapped enclosing composite. It returns false if the transform is
+// mapped to the empty name, and thus removed.
+func mapName(mapping map[string]string, name string) (string, bool) {
+	if to, ok := mapping[name]; ok {
+		return to, to != ""
+	}
+	best := ""
+	for from := range mapping {
+		if strings.HasPrefix(name, from+"/") && len(from) > len(best) {
+			best = from
+		}
+	}
+	if best == "" {
+		return name, true
+	}
+	if mapping[best] == "" {
+		return "", false
+	}
+	return mapping[best] + strings.TrimPrefix(name, best), true
+}
+
+// hasName returns true iff the name is a step, or a composite enclosing one.
+func hasName(kinds map[string]string, name string) bool {
+	if _, ok := kinds[name]; ok {
+		return true
+	}
+	for n := range kinds {
+		if strings.HasPrefix(n, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	var ret []string
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -19,156 +19,142 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
-	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
 )
 
-// buffer buffers all input and notifies on FinishBundle. It is also a SideInputAdapter.
-// It is used as a guard for the wait node to buffer data used as side input.
-type buffer struct {
-	uid    exec.UnitID
-	next   exec.UnitID // debug only
-	read   exec.UnitID // debug only
-	notify func(ctx context.Context) error
+// bufElement is a materialized element, with its values if the result of a
+// GroupByKey.
+type bufElement struct {
+	elm    exec.FullValue
+	values []exec.ReStream
+}
 
-	buf  []exec.FullValue
-	done bool
+// source is the root of a stage. It emits the elements of the current bundle
+// of the materialized input of the stage.
+type source struct {
+	uid  exec.UnitID
+	root int // node ID
+	Out  exec.Node
+
+	elms []bufElement
 }
 
-func (n *buffer) ID() exec.UnitID {
+func (n *source) ID() exec.UnitID {
 	return n.uid
 }
 
-func (n *buffer) Up(ctx context.Context) error {
+func (n *source) Up(ctx context.Context) error {
 	return nil
 }
 
-func (n *buffer) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
-	n.buf = nil
-	n.done = false
-	return nil
+func (n *source) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
+	return n.Out.StartBundle(ctx, id, data)
 }
 
-func (n *buffer) ProcessElement(ctx context.Context, elm *exec.FullValue, values ...exec.ReStream) error {
-	n.buf = append(n.buf, *elm)
+func (n *source) Process(ctx context.Context) error {
+	for i := range n.elms {
+		if err := n.Out.ProcessElement(ctx, &n.elms[i].elm, n.elms[i].values...); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (n *buffer) FinishBundle(ctx context.Context) error {
-	n.done = true
-	return n.notify(ctx)
+func (n *source) FinishBundle(ctx context.Context) error {
+	return n.Out.FinishBundle(ctx)
 }
 
-func (n *buffer) Down(ctx context.Context) error {
+func (n *source) Down(ctx context.Context) error {
 	return nil
 }
 
-func (n *buffer) NewIterable(ctx context.Context, reader exec.StateReader, w typex.Window) (exec.ReStream, error) {
-	if !n.done {
-		panic(fmt.Sprintf("buffer[%v] incomplete: %v", n.uid, len(n.buf)))
-	}
-	return &exec.FixedReStream{Buf: n.buf}, nil
+func (n *source) String() string {
+	return fmt.Sprintf("source[%v]. Out:%v", n.root, n.Out.ID())
 }
 
-func (n *buffer) NewKeyedIterable(ctx context.Context, reader exec.StateReader, w typex.Window, iterKey interface{}) (exec.ReStream, error) {
-	return n.NewIterable(ctx, reader, w)
-}
+// collect buffers its input and materializes it on FinishBundle, for later
+// operations of the plan to read.
+type collect struct {
+	uid   exec.UnitID
+	node  int // node ID
+	ex    *executor
+	check *checker // nil if not checking
 
-func (n *buffer) String() string {
-	return fmt.Sprintf("buffer[%v]. wait:%v Out:%v", n.uid, n.next, n.read)
+	buf []bufElement
 }
 
-// wait buffers all input until the guard condition is triggered. It then
-// proceeds normally. The main purpose is to delay bundle processing until side input
-// is ready.
-type wait struct {
-	UID  exec.UnitID
-	need int // guards needed
-	next exec.Node
-
-	instID string
-	mgr    exec.DataContext
-
-	buf   []bufElement
-	ready int  // guards ready
-	done  bool // FinishBundle called for main input?
+func newCollect(uid exec.UnitID, ex *executor, node *graph.Node, producer *graph.MultiEdge) *collect {
+	return &collect{uid: uid, node: node.ID(), ex: ex, check: newChecker(node, producer)}
 }
 
-type bufElement struct {
-	elm    exec.FullValue
-	values []exec.ReStream
+func (n *collect) ID() exec.UnitID {
+	return n.uid
 }
 
-func (w *wait) ID() exec.UnitID {
-	return w.UID
+func (n *collect) Up(ctx context.Context) error {
+	return nil
 }
 
-func (w *wait) notify(ctx context.Context) error {
-	if w.ready == w.need {
-		panic("Too many notify")
-	}
-	w.ready++
-	if w.ready < w.need {
-		return nil
-	}
-
-	// All ready: continue the processing. We may or may not have buffered
-	// all the data. If not, wait is a pass-through going forward.
-
-	log.Debugf(ctx, "wait[%v] unblocked w/ %v [%v]", w.UID, len(w.buf), w.done)
+func (n *collect) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
+	n.buf = nil
+	return nil
+}
 
-	if err := w.next.StartBundle(ctx, w.instID, w.mgr); err != nil {
-		return err
-	}
-	for _, element := range w.buf {
-		if err := w.next.ProcessElement(ctx, &element.elm, element.values...); err != nil {
+func (n *collect) ProcessElement(ctx context.Context, elm *exec.FullValue, values ...exec.ReStream) error {
+	v := *elm
+	if n.check != nil {
+		var err error
+		if v, err = n.check.check(elm); err != nil {
 			return err
 		}
 	}
-	w.buf = nil
-	if w.done {
-		if err := w.next.FinishBundle(ctx); err != nil {
+	n.buf = append(n.buf, bufElement{elm: v, values: values})
+	return nil
+}
+
+func (n *collect) FinishBundle(ctx context.Context) error {
+	if n.check != nil {
+		if err := n.check.finish(); err != nil {
 			return err
 		}
 	}
-
-	log.Debugf(ctx, "wait[%v] done", w.UID)
+	n.ex.write(n.node, n.buf)
+	n.buf = nil
 	return nil
 }
 
-func (w *wait) Up(ctx context.Context) error {
+func (n *collect) Down(ctx context.Context) error {
 	return nil
 }
 
-func (w *wait) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
-	return nil // done in notify
+func (n *collect) String() string {
+	return fmt.Sprintf("collect[%v]", n.node)
 }
 
-func (w *wait) ProcessElement(ctx context.Context, elm *exec.FullValue, values ...exec.ReStream) error {
-	if w.ready < w.need {
-		// log.Printf("buffer[%v]: %v", w.UID, elm)
-		w.buf = append(w.buf, bufElement{elm: *elm, values: values})
-		return nil
-	}
-
-	// log.Printf("NOT buffer[%v]: %v", w.UID, elm)
-	return w.next.ProcessElement(ctx, elm, values...)
+// sideInput is a SideInputAdapter for a materialized PCollection.
+type sideInput struct {
+	node int // node ID
+	buf  []exec.FullValue
 }
 
-func (w *wait) FinishBundle(ctx context.Context) error {
-	if w.ready < w.need || w.done {
-		w.done = true
-		return nil
+func newSideInput(node int, elms []bufElement) *sideInput {
+	buf := make([]exec.FullValue, len(elms))
+	for i, e := range elms {
+		buf[i] = e.elm
 	}
-	w.done = true
-	return w.next.FinishBundle(ctx)
+	return &sideInput{node: node, buf: buf}
 }
 
-func (w *wait) Down(ctx context.Context) error {
-	return nil
+func (n *sideInput) NewIterable(ctx context.Context, reader exec.StateReader, w typex.Window) (exec.ReStream, error) {
+	return &exec.FixedReStream{Buf: n.buf}, nil
+}
+
+func (n *sideInput) NewKeyedIterable(ctx context.Context, reader exec.StateReader, w typex.Window, iterKey interface{}) (exec.ReStream, error) {
+	return n.NewIterable(ctx, reader, w)
 }
 
-func (w *wait) String() string {
-	return fmt.Sprintf("wait[%v] Out:%v", w.need, w.next.ID())
+func (n *sideInput) String() string {
+	return fmt.Sprintf("sideInput[%v]: %v", n.node, len(n.buf))
 }
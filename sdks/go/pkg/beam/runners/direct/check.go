@@ -0,0 +1,309 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+var (
+	checkCoders       = flag.Bool("direct_check_coders", false, "Whether the direct runner round-trips elements through the coders of their PCollections between stages, and checks that GroupByKey keys are deterministically encoded.")
+	checkImmutability = flag.Bool("direct_check_immutability", false, "Whether the direct runner checks that transforms don't mutate their input elements or side input values, and that elements aren't mutated after being emitted.")
+)
+
+// encodeElement encodes the element with the encoder, ignoring its windows.
+func encodeElement(enc exec.ElementEncoder, elm *exec.FullValue) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := enc.Encode(elm, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encoded is an element and its encoding when first seen, to detect
+// mutations.
+type encoded struct {
+	elm exec.FullValue
+	enc []byte
+}
+
+// verify returns whether the element still encodes as when first seen.
+func (e *encoded) verify(enc exec.ElementEncoder) (bool, error) {
+	b, err := encodeElement(enc, &e.elm)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(b, e.enc), nil
+}
+
+// checkable returns whether elements of the node can be encoded and decoded
+// on their own, unlike the results of GroupByKey.
+func checkable(n *graph.Node) bool {
+	return n.Coder != nil && n.Coder.Kind != coder.CoGBK
+}
+
+// guard checks that the transform it feeds, or the transforms fused
+// downstream of it, don't mutate their input elements, while processing them
+// or later in the bundle. It also detects elements mutated by their producer
+// after being emitted.
+type guard struct {
+	uid  exec.UnitID
+	node *graph.Node
+	edge *graph.MultiEdge // transform fed by the guard
+	Out  exec.Node
+
+	enc  exec.ElementEncoder
+	seen []encoded
+}
+
+func (n *guard) ID() exec.UnitID {
+	return n.uid
+}
+
+func (n *guard) Up(ctx context.Context) error {
+	n.enc = exec.MakeElementEncoder(n.node.Coder)
+	return nil
+}
+
+func (n *guard) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
+	n.seen = nil
+	return n.Out.StartBundle(ctx, id, data)
+}
+
+func (n *guard) ProcessElement(ctx context.Context, elm *exec.FullValue, values ...exec.ReStream) error {
+	before, err := encodeElement(n.enc, elm)
+	if err != nil {
+		return errors.Wrapf(err, "encoding input element %v of %v", elm, n.edge.Name())
+	}
+	if err := n.Out.ProcessElement(ctx, elm, values...); err != nil {
+		return err
+	}
+	e := encoded{elm: *elm, enc: before}
+	ok, err := e.verify(n.enc)
+	if err != nil {
+		return errors.Wrapf(err, "encoding input element %v of %v after processing it", elm, n.edge.Name())
+	}
+	if !ok {
+		return errors.Errorf("%v, or a transform fused downstream of it, mutated its input element %v of PCollection %v", n.edge.Name(), elm, n.node)
+	}
+	n.seen = append(n.seen, e)
+	return nil
+}
+
+func (n *guard) FinishBundle(ctx context.Context) error {
+	if err := n.Out.FinishBundle(ctx); err != nil {
+		return err
+	}
+	for _, e := range n.seen {
+		ok, err := e.verify(n.enc)
+		if err != nil {
+			return errors.Wrapf(err, "encoding input element %v of %v at the end of the bundle", e.elm, n.edge.Name())
+		}
+		if !ok {
+			return errors.Errorf("input element %v of %v in PCollection %v was mutated after it was processed, by the transform or the producer of the element", e.elm, n.edge.Name(), n.node)
+		}
+	}
+	n.seen = nil
+	return nil
+}
+
+func (n *guard) Down(ctx context.Context) error {
+	return nil
+}
+
+func (n *guard) String() string {
+	return fmt.Sprintf("guard[%v]. Out:%v", n.node.ID(), n.Out.ID())
+}
+
+// checker round-trips elements of a materialized node through the coder of
+// the node, and detects elements mutated after being emitted by their
+// producer.
+type checker struct {
+	node     *graph.Node
+	producer *graph.MultiEdge
+
+	enc     exec.ElementEncoder
+	dec     exec.ElementDecoder // nil if not round-tripping elements
+	emitted []encoded           // nil if not checking immutability
+}
+
+// newChecker returns a checker for the node, or nil if no check applies.
+func newChecker(node *graph.Node, producer *graph.MultiEdge) *checker {
+	if (!*checkCoders && !*checkImmutability) || !checkable(node) {
+		return nil
+	}
+	c := &checker{node: node, producer: producer, enc: exec.MakeElementEncoder(node.Coder)}
+	if *checkCoders {
+		c.dec = exec.MakeElementDecoder(node.Coder)
+	}
+	return c
+}
+
+// check returns the element to materialize: the element itself, or the
+// element round-tripped through the coder of the node.
+func (c *checker) check(elm *exec.FullValue) (exec.FullValue, error) {
+	b, err := encodeElement(c.enc, elm)
+	if err != nil {
+		return exec.FullValue{}, errors.Wrapf(err, "encoding element %v of PCollection %v emitted by %v with coder %v", elm, c.node, c.producer.Name(), c.node.Coder)
+	}
+	if *checkImmutability {
+		c.emitted = append(c.emitted, encoded{elm: *elm, enc: b})
+	}
+	if c.dec == nil {
+		return *elm, nil
+	}
+	v, err := c.dec.Decode(bytes.NewReader(b))
+	if err != nil {
+		return exec.FullValue{}, errors.Wrapf(err, "decoding element %v of PCollection %v emitted by %v with coder %v", elm, c.node, c.producer.Name(), c.node.Coder)
+	}
+	v.Windows, v.Timestamp, v.Pane = elm.Windows, elm.Timestamp, elm.Pane
+	return *v, nil
+}
+
+// finish verifies that no element was mutated after being emitted.
+func (c *checker) finish() error {
+	for _, e := range c.emitted {
+		ok, err := e.verify(c.enc)
+		if err != nil {
+			return errors.Wrapf(err, "encoding element %v of PCollection %v emitted by %v at the end of the bundle", e.elm, c.node, c.producer.Name())
+		}
+		if !ok {
+			return errors.Errorf("element %v of PCollection %v was mutated after being emitted by %v", e.elm, c.node, c.producer.Name())
+		}
+	}
+	c.emitted = nil
+	return nil
+}
+
+// sideInputs records the encoding of the side inputs of a stage, to detect
+// mutations of side input values by its transforms.
+type sideInputs struct {
+	values map[int][]encoded // nodeID -> values
+}
+
+func newSideInputs(s *stage, ex *executor) (*sideInputs, error) {
+	si := &sideInputs{values: make(map[int][]encoded)}
+	for _, edge := range s.edges {
+		for _, in := range edge.Input[1:] {
+			node := in.From
+			if _, ok := si.values[node.ID()]; ok || !checkable(node) {
+				continue
+			}
+			enc := exec.MakeElementEncoder(node.Coder)
+			var values []encoded
+			for _, e := range ex.read(node.ID()) {
+				b, err := encodeElement(enc, &e.elm)
+				if err != nil {
+					return nil, errors.Wrapf(err, "encoding side input value %v of %v", e.elm, edge.Name())
+				}
+				values = append(values, encoded{elm: e.elm, enc: b})
+			}
+			si.values[node.ID()] = values
+		}
+	}
+	return si, nil
+}
+
+// verify returns an error naming the transforms reading a mutated side
+// input.
+func (si *sideInputs) verify(s *stage) error {
+	for _, edge := range s.edges {
+		for _, in := range edge.Input[1:] {
+			enc := exec.MakeElementEncoder(in.From.Coder)
+			for _, e := range si.values[in.From.ID()] {
+				ok, err := e.verify(enc)
+				if err != nil {
+					return errors.Wrapf(err, "encoding side input value %v of %v after processing", e.elm, edge.Name())
+				}
+				if !ok {
+					return errors.Errorf("side input value %v of PCollection %v was mutated by %v, or a transform fused with it", e.elm, in.From, edge.Name())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkKeys returns an error if the keys of the GroupByKey aren't
+// deterministically encoded: if their type contains maps and they use a
+// default coder, or if a key doesn't encode to the same bytes after a
+// round-trip through its coder.
+func checkKeys(edge *graph.MultiEdge, in *graph.Node, elms []bufElement) error {
+	c := in.Coder
+	if c.Kind != coder.KV {
+		return nil
+	}
+	kc := c.Components[0]
+	if t := kc.T.Type(); isDefaultCoder(kc) && hasMap(t, make(map[reflect.Type]bool)) {
+		return errors.Errorf("key type %v of %v contains a map, whose encoding isn't deterministic", t, edge.Name())
+	}
+	enc, dec := exec.MakeElementEncoder(kc), exec.MakeElementDecoder(kc)
+	for _, e := range elms {
+		b1, err := encodeElement(enc, &exec.FullValue{Elm: e.elm.Elm})
+		if err != nil {
+			return errors.Wrapf(err, "encoding key %v of %v", e.elm.Elm, edge.Name())
+		}
+		k, err := dec.Decode(bytes.NewReader(b1))
+		if err != nil {
+			return errors.Wrapf(err, "decoding key %v of %v", e.elm.Elm, edge.Name())
+		}
+		b2, err := encodeElement(enc, k)
+		if err != nil {
+			return errors.Wrapf(err, "encoding key %v of %v", k.Elm, edge.Name())
+		}
+		if !bytes.Equal(b1, b2) {
+			return errors.Errorf("key coder %v of %v isn't deterministic: key %v encodes as %x, and as %x after a round-trip", kc, edge.Name(), e.elm.Elm, b1, b2)
+		}
+	}
+	return nil
+}
+
+// isDefaultCoder returns whether the coder is one inferred for types without a
+// registered coder: the JSON or the schema coder, which encode maps in
+// iteration order.
+func isDefaultCoder(c *coder.Coder) bool {
+	return c.Kind == coder.Row || (c.Kind == coder.Custom && c.Custom.Name == "json")
+}
+
+// hasMap returns whether values of the type may contain maps.
+func hasMap(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Map:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return hasMap(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasMap(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
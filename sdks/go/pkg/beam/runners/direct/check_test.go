@@ -0,0 +1,243 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*unencodable)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*mapKey)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*codedMapKey)(nil)).Elem())
+	beam.RegisterCoder(reflect.TypeOf((*unencodable)(nil)).Elem(), encUnencodable, decUnencodable)
+	beam.RegisterCoder(reflect.TypeOf((*codedMapKey)(nil)).Elem(), encCodedMapKey, decCodedMapKey)
+	beam.RegisterFunction(emitUnencodable)
+	beam.RegisterFunction(emitSlice)
+	beam.RegisterFunction(emitThenMutate)
+	beam.RegisterFunction(mutateInput)
+	beam.RegisterFunction(mutateSide)
+	beam.RegisterFunction(emitMapKeys)
+	beam.RegisterFunction(emitCodedMapKeys)
+	beam.RegisterFunction(dropSlice)
+}
+
+type unencodable struct {
+	V int
+}
+
+func encUnencodable(unencodable) ([]byte, error) {
+	return nil, errors.New("unencodable")
+}
+
+func decUnencodable([]byte) (unencodable, error) {
+	return unencodable{}, nil
+}
+
+type mapKey struct {
+	M map[string]int
+}
+
+// codedMapKey contains a map, but has a deterministic coder.
+type codedMapKey struct {
+	M map[string]int
+}
+
+func encCodedMapKey(k codedMapKey) ([]byte, error) {
+	var keys []string
+	for key := range k.M {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b []byte
+	for _, key := range keys {
+		b = append(b, fmt.Sprintf("%v=%v;", key, k.M[key])...)
+	}
+	return b, nil
+}
+
+func decCodedMapKey(b []byte) (codedMapKey, error) {
+	k := codedMapKey{M: make(map[string]int)}
+	for _, kv := range strings.Split(strings.TrimSuffix(string(b), ";"), ";") {
+		key, v, _ := strings.Cut(kv, "=")
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return codedMapKey{}, err
+		}
+		k.M[key] = n
+	}
+	return k, nil
+}
+
+func emitUnencodable(_ []byte, emit func(unencodable)) {
+	emit(unencodable{V: 1})
+}
+
+func emitSlice(_ []byte, emit func([]int)) {
+	emit([]int{1, 2})
+}
+
+func emitThenMutate(_ []byte, emit func([]int)) {
+	buf := []int{1}
+	emit(buf)
+	buf[0] = 2
+	emit(buf)
+}
+
+func mutateInput(v []int) []int {
+	v[0] = 0
+	return v
+}
+
+func mutateSide(_ []byte, side func(*[]int) bool) {
+	var v []int
+	for side(&v) {
+		v[0] = 0
+	}
+}
+
+func emitMapKeys(_ []byte, emit func(mapKey, int)) {
+	emit(mapKey{M: map[string]int{"a": 1}}, 1)
+}
+
+func emitCodedMapKeys(_ []byte, emit func(codedMapKey, int)) {
+	emit(codedMapKey{M: map[string]int{"a": 1, "b": 2, "c": 3}}, 1)
+	emit(codedMapKey{M: map[string]int{"c": 3, "b": 2, "a": 1}}, 2)
+}
+
+func dropSlice(_ []int) {}
+
+func TestRunner_Checks(t *testing.T) {
+	tests := []struct {
+		name    string
+		flag    *bool
+		build   func(s beam.Scope)
+		wantErr string
+	}{
+		{"unencodable", checkCoders, func(s beam.Scope) {
+			beam.Reshuffle(s, beam.ParDo(s, emitUnencodable, beam.Impulse(s)))
+		}, "encoding element"},
+		{"map_key", checkCoders, func(s beam.Scope) {
+			beam.GroupByKey(s, beam.ParDo(s, emitMapKeys, beam.Impulse(s)))
+		}, "contains a map"},
+		{"mutated_input", checkImmutability, func(s beam.Scope) {
+			beam.ParDo(s, mutateInput, beam.ParDo(s, emitSlice, beam.Impulse(s)))
+		}, "mutateInput, or a transform fused downstream of it, mutated its input element"},
+		{"mutated_after_emission", checkImmutability, func(s beam.Scope) {
+			beam.Reshuffle(s, beam.ParDo(s, emitThenMutate, beam.Impulse(s)))
+		}, "mutated after being emitted by"},
+		{"mutated_side_input", checkImmutability, func(s beam.Scope) {
+			imp := beam.Impulse(s)
+			beam.ParDo0(s, mutateSide, imp, beam.SideInput{Input: beam.ParDo(s, emitSlice, imp)})
+		}, "was mutated by"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, s := beam.NewPipelineWithRoot()
+			test.build(s)
+			if _, err := executeWithT(context.Background(), t, p); err != nil {
+				t.Fatalf("Execute without checks failed: %v", err)
+			}
+
+			defer setBoolFlag(test.flag, true)()
+			_, err := executeWithT(context.Background(), t, p)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Execute with checks = %v, want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunner_ChecksPass(t *testing.T) {
+	defer setBoolFlag(checkCoders, true)()
+	defer setBoolFlag(checkImmutability, true)()
+
+	p, s := beam.NewPipelineWithRoot()
+	imp := beam.Impulse(s)
+	beam.ParDo0(s, dropSlice, beam.Reshuffle(s, beam.ParDo(s, emitSlice, imp)))
+	col := beam.ParDo(s, dofnKV, imp)
+	beam.Seq(s, beam.GroupByKey(s, col), dofnGBK, &int64Check{Name: "gbk", Want: []int{9, 12}})
+	beam.GroupByKey(s, beam.ParDo(s, emitCodedMapKeys, imp))
+	if _, err := executeWithT(context.Background(), t, p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// failingEncoder fails to encode after the given number of calls.
+type failingEncoder struct {
+	calls, ok int
+}
+
+func (e *failingEncoder) Encode(_ *exec.FullValue, w io.Writer) error {
+	e.calls++
+	if e.calls > e.ok {
+		return errors.New("encoder failure")
+	}
+	_, err := w.Write([]byte{1})
+	return err
+}
+
+func TestChecks_encodeErrors(t *testing.T) {
+	edge := &graph.MultiEdge{Op: graph.ParDo}
+	elm := &exec.FullValue{Elm: 1}
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"guard_process", func() error {
+			g := &guard{uid: 1, node: &graph.Node{}, edge: edge, Out: &exec.Discard{UID: 2}, enc: &failingEncoder{ok: 1}}
+			return g.ProcessElement(context.Background(), elm)
+		}},
+		{"guard_finish", func() error {
+			g := &guard{uid: 1, node: &graph.Node{}, edge: edge, Out: &exec.Discard{UID: 2}, enc: &failingEncoder{ok: 2}}
+			if err := g.ProcessElement(context.Background(), elm); err != nil {
+				t.Fatalf("ProcessElement failed: %v", err)
+			}
+			return g.FinishBundle(context.Background())
+		}},
+		{"checker_finish", func() error {
+			c := &checker{node: &graph.Node{}, producer: edge, enc: &failingEncoder{ok: 0}, emitted: []encoded{{elm: *elm}}}
+			return c.finish()
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.run()
+			if err == nil || !strings.Contains(err.Error(), "encoder failure") || strings.Contains(err.Error(), "mutated") {
+				t.Errorf("check = %v, want the encoder failure", err)
+			}
+		})
+	}
+}
+
+// setBoolFlag sets the flag, and returns a function restoring it.
+func setBoolFlag(f *bool, v bool) func() {
+	prev := *f
+	*f = v
+	return func() { *f = prev }
+}
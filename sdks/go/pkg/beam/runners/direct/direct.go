@@ -13,19 +13,30 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package direct contains the direct runner for running single-bundle
-// pipelines in the current process. Useful for testing.
+// Package direct contains the direct runner for running pipelines in the
+// current process. Useful for testing.
+//
+// The direct runner materializes the inputs and outputs of GroupByKey,
+// Flatten and Reshuffle, and side inputs, and fuses the remaining transforms
+// into stages. The input of each stage is split into bundles, processed by
+// --direct_parallelism goroutines, each with its own DoFn instances. Bundle
+// boundaries can be randomized with --direct_randomize_bundles to expose
+// DoFns relying on bundling or element order.
+//
+// Bugs that otherwise only surface on distributed runners can be detected
+// with --direct_check_coders, which round-trips elements through their coders
+// between stages and checks that GroupByKey keys encode deterministically, and
+// --direct_check_immutability, which reports transforms mutating their input
+// elements or side input values, or elements after emitting them.
 package direct
 
 import (
 	"context"
-	"path"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
-	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/jobopts"
@@ -61,18 +72,22 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid pipeline")
 	}
-	plan, err := Compile(edges)
+	plan, err := CompilePlan(edges)
 	if err != nil {
 		return nil, errors.Wrap(err, "translation failed")
 	}
 	beam.PipelineOptions.LoadOptionsFromFlags(nil)
 	log.Info(ctx, plan)
 
-	if err = plan.Execute(ctx, "", exec.DataContext{}); err != nil {
-		plan.Down(ctx) // ignore any teardown errors
+	ms, err := maybeServeMetrics(ctx)
+	if err != nil {
 		return nil, err
 	}
-	if err = plan.Down(ctx); err != nil {
+	if ms != nil {
+		defer ms.stop()
+	}
+
+	if err = plan.Execute(ctx); err != nil {
 		return nil, err
 	}
 
@@ -97,57 +112,81 @@ func (pr directPipelineResult) JobID() string {
 	return pr.jobID
 }
 
-// Compile translates a pipeline to a multi-bundle execution plan.
+// Compile translates a pipeline to an execution plan, which runs the stages
+// of the multi-bundle plan of CompilePlan when executed.
+//
+// Deprecated: Use CompilePlan, which exposes the stages of the plan.
 func Compile(edges []*graph.MultiEdge) (*exec.Plan, error) {
-	// (1) Preprocess graph structure to allow insertion of Multiplex,
-	// Flatten and Discard.
+	p, err := CompilePlan(edges)
+	if err != nil {
+		return nil, err
+	}
+	return exec.NewPlan("plan", []exec.Unit{&planRoot{UID: 1, plan: p}})
+}
 
-	prev := make(map[int]int)      // nodeID -> #incoming
-	succ := make(map[int][]linkID) // nodeID -> []linkID
-	edgeMap := make(map[int]*graph.MultiEdge)
+// CompilePlan translates a pipeline to a multi-bundle execution plan.
+func CompilePlan(edges []*graph.MultiEdge) (*Plan, error) {
+	// (1) Determine the materialized nodes: the inputs and outputs of runner
+	// operations and side inputs. Other transforms are fused with the
+	// producers of their main input.
 
+	p := &Plan{
+		succ:         make(map[int][]linkID),
+		edges:        make(map[int]*graph.MultiEdge),
+		materialized: make(map[int]bool),
+	}
 	for _, edge := range edges {
-		edgeMap[edge.ID()] = edge
+		p.edges[edge.ID()] = edge
 		for i, in := range edge.Input {
 			from := in.From.ID()
-			succ[from] = append(succ[from], linkID{edge.ID(), i})
-		}
-		for _, out := range edge.Output {
-			to := out.To.ID()
-			prev[to]++
+			p.succ[from] = append(p.succ[from], linkID{edge.ID(), i})
 		}
-	}
-
-	// (2) Constructs the plan units recursively.
 
-	b := &builder{
-		prev:  prev,
-		succ:  succ,
-		edges: edgeMap,
-		nodes: make(map[int]exec.Node),
-		links: make(map[linkID]exec.Node),
-		idgen: &exec.GenID{},
+		switch edge.Op {
+		case graph.Impulse, graph.CoGBK, graph.Flatten, graph.Reshuffle:
+			for _, in := range edge.Input {
+				p.materialized[in.From.ID()] = true
+			}
+			for _, out := range edge.Output {
+				p.materialized[out.To.ID()] = true
+			}
+		case graph.ParDo:
+			if len(edge.Input) == 1 {
+				break
+			}
+			// ParDos with side inputs head stages of their own, to be run
+			// once the side inputs are complete.
+			for _, in := range edge.Input {
+				p.materialized[in.From.ID()] = true
+			}
+		case graph.Combine, graph.WindowInto:
+			// fused
+		default:
+			return nil, errors.Errorf("unexpected edge: %v", edge)
+		}
 	}
 
-	var roots []exec.Unit
+	// (2) Construct the operations in topological order: runner operations,
+	// and the stages reading each materialized node.
 
 	for _, edge := range edges {
 		switch edge.Op {
 		case graph.Impulse:
-			out, err := b.makeNode(edge.Output[0].To.ID())
-			if err != nil {
-				return nil, err
+			p.ops = append(p.ops, &impulse{edge: edge})
+		case graph.CoGBK:
+			p.ops = append(p.ops, &cogbk{edge: edge})
+		case graph.Flatten:
+			p.ops = append(p.ops, &flatten{edge: edge})
+		case graph.Reshuffle:
+			p.ops = append(p.ops, &reshuffle{edge: edge})
+		}
+		for _, out := range edge.Output {
+			if id := out.To.ID(); p.materialized[id] {
+				p.ops = append(p.ops, p.newStages(len(p.ops), id)...)
 			}
-
-			u := &Impulse{UID: b.idgen.New(), Value: edge.Value, Out: out}
-			roots = append(roots, u)
-
-		default:
-			// skip non-roots
 		}
 	}
-
-	return exec.NewPlan("plan", append(roots, b.units...))
+	return p, nil
 }
 
 // linkID represents an incoming data link to an Edge.
@@ -155,189 +194,3 @@ type linkID struct {
 	to    int // graph.MultiEdge
 	input int // input index. If > 0, it's a side or CoGBK input.
 }
-
-// builder is the recursive builder for non-root execution nodes.
-type builder struct {
-	prev  map[int]int              // nodeID -> #incoming
-	succ  map[int][]linkID         // nodeID -> []linkID
-	edges map[int]*graph.MultiEdge // edgeID -> Edge
-
-	nodes map[int]exec.Node    // nodeID -> Node (cache)
-	links map[linkID]exec.Node // linkID -> Node (cache)
-
-	units []exec.Unit // result
-	idgen *exec.GenID
-}
-
-func (b *builder) makeNodes(out []*graph.Outbound) ([]exec.Node, error) {
-	var ret []exec.Node
-	for _, o := range out {
-		n, err := b.makeNode(o.To.ID())
-		if err != nil {
-			return nil, err
-		}
-		ret = append(ret, n)
-	}
-	return ret, nil
-}
-
-func (b *builder) makeNode(id int) (exec.Node, error) {
-	if n, ok := b.nodes[id]; ok {
-		return n, nil
-	}
-
-	list := b.succ[id]
-
-	var u exec.Node
-	switch len(list) {
-	case 0:
-		// Discard.
-
-		u = &exec.Discard{UID: b.idgen.New()}
-
-	case 1:
-		return b.makeLink(list[0])
-
-	default:
-		// Multiplex.
-
-		out, err := b.makeLinks(list)
-		if err != nil {
-			return nil, err
-		}
-		u = &exec.Multiplex{UID: b.idgen.New(), Out: out}
-	}
-
-	if count := b.prev[id]; count > 1 {
-		// Guard node with Flatten, if needed.
-
-		b.units = append(b.units, u)
-		u = &exec.Flatten{UID: b.idgen.New(), N: count, Out: u}
-	}
-
-	b.nodes[id] = u
-	b.units = append(b.units, u)
-	return u, nil
-}
-
-func (b *builder) makeLinks(ids []linkID) ([]exec.Node, error) {
-	var ret []exec.Node
-	for _, id := range ids {
-		n, err := b.makeLink(id)
-		if err != nil {
-			return nil, err
-		}
-		ret = append(ret, n)
-	}
-	return ret, nil
-}
-
-func (b *builder) makeLink(id linkID) (exec.Node, error) {
-	if n, ok := b.links[id]; ok {
-		return n, nil
-	}
-
-	// Process all incoming links for the edge and cache them. It thus doesn't matter
-	// which exact link triggers the Node generation. The link caching is only needed
-	// to process ParDo side inputs and CoGBK.
-
-	edge := b.edges[id.to]
-
-	out, err := b.makeNodes(edge.Output)
-	if err != nil {
-		return nil, err
-	}
-
-	var u exec.Node
-	switch edge.Op {
-	case graph.ParDo:
-		pardo := &exec.ParDo{
-			UID:     b.idgen.New(),
-			Fn:      edge.DoFn,
-			Inbound: edge.Input,
-			Out:     out,
-			PID:     path.Base(edge.DoFn.Name()),
-		}
-		u = pardo
-		if edge.DoFn.IsSplittable() {
-			u = &exec.SdfFallback{PDo: pardo}
-		}
-		if len(edge.Input) == 1 {
-			break
-		}
-
-		// ParDo w/ side input. We need to insert buffering and wait. We also need to
-		// ensure that we return the correct link node.
-
-		b.units = append(b.units, u)
-
-		w := &wait{UID: b.idgen.New(), need: len(edge.Input) - 1, next: u}
-		b.units = append(b.units, w)
-		b.links[linkID{edge.ID(), 0}] = w
-
-		for i := 1; i < len(edge.Input); i++ {
-			n := &buffer{uid: b.idgen.New(), next: w.ID(), read: pardo.ID(), notify: w.notify}
-			pardo.Side = append(pardo.Side, n)
-
-			b.units = append(b.units, n)
-			b.links[linkID{edge.ID(), i}] = n
-		}
-
-		return b.links[id], nil
-
-	case graph.Combine:
-		usesKey := typex.IsKV(edge.Input[0].Type)
-
-		u = &exec.Combine{
-			UID:     b.idgen.New(),
-			Fn:      edge.CombineFn,
-			UsesKey: usesKey,
-			Out:     out[0],
-			PID:     path.Base(edge.CombineFn.Name()),
-		}
-
-	case graph.CoGBK:
-		u = &CoGBK{UID: b.idgen.New(), Edge: edge, Out: out[0]}
-		b.units = append(b.units, u)
-
-		// CoGBK needs injection of each incoming index. If > 1 incoming,
-		// insert Flatten as well.
-
-		if len(edge.Input) > 1 {
-			u = &exec.Flatten{UID: b.idgen.New(), N: len(edge.Input), Out: u}
-			b.units = append(b.units, u)
-		}
-
-		for i := 0; i < len(edge.Input); i++ {
-			n := &Inject{UID: b.idgen.New(), N: i, Out: u}
-
-			b.units = append(b.units, n)
-			b.links[linkID{edge.ID(), i}] = n
-		}
-
-		return b.links[id], nil
-
-	case graph.Reshuffle:
-		// Reshuffle is a no-op in the direct runner, as there's only a single bundle
-		// on a single worker. Hoist the next node up in the cache.
-		b.links[id] = out[0]
-		return b.links[id], nil
-
-	case graph.Flatten:
-		u = &exec.Flatten{UID: b.idgen.New(), N: len(edge.Input), Out: out[0]}
-
-		for i := 0; i < len(edge.Input); i++ {
-			b.links[linkID{edge.ID(), i}] = u
-		}
-
-	case graph.WindowInto:
-		u = &exec.WindowInto{UID: b.idgen.New(), Fn: edge.WindowFn, Out: out[0]}
-
-	default:
-		return nil, errors.Errorf("unexpected edge: %v", edge)
-	}
-
-	b.links[id] = u
-	b.units = append(b.units, u)
-	return u, nil
-}
@@ -26,6 +26,8 @@ import (
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -55,6 +57,10 @@ func init() {
 
 	beam.RegisterFunction(dofn1Counter)
 	beam.RegisterFunction(dofnSink)
+	beam.RegisterFunction(dofn1HistogramAndSet)
+	beam.RegisterType(reflect.TypeOf((*bundleCounter)(nil)))
+	beam.RegisterFunction(dofnMod3)
+	beam.RegisterFunction(dofnGBKCount)
 }
 
 func dofn1(imp []byte, emit func(int64)) {
@@ -221,6 +227,37 @@ func dofn1Counter(ctx context.Context, _ []byte, emit func(int64)) {
 	beam.NewCounter(ns, "count").Inc(ctx, 1)
 }
 
+func dofn1HistogramAndSet(ctx context.Context, _ []byte, emit func(int64)) {
+	h := beam.NewHistogram(ns, "latency", []int64{10, 100})
+	s := beam.NewStringSet(ns, "codes")
+	for _, v := range []int64{5, 50, 500} {
+		h.Update(ctx, v)
+		s.Add(ctx, fmt.Sprintf("code%d", v))
+	}
+}
+
+type bundleCounter struct{}
+
+func (fn *bundleCounter) StartBundle(ctx context.Context) {
+	beam.NewCounter(ns, "bundles").Inc(ctx, 1)
+}
+
+func (fn *bundleCounter) ProcessElement(v int) int {
+	return v
+}
+
+func dofnMod3(v int) (int, int) {
+	return v % 3, v
+}
+
+func dofnGBKCount(k int, vs func(*int) bool) string {
+	n, v := 0, 0
+	for vs(&v) {
+		n++
+	}
+	return fmt.Sprintf("%v:%v", k, n)
+}
+
 func TestRunner_Pipelines(t *testing.T) {
 	t.Run("simple", func(t *testing.T) {
 		p, s := beam.NewPipelineWithRoot()
@@ -409,7 +446,7 @@ func TestRunner_Pipelines(t *testing.T) {
 			t.Fatal(err)
 		}
 	})
-	// Validates the waiting on side input readiness in buffer.
+	// Validates the ordering of stages on side input readiness.
 	t.Run("sideinput_2iterable", func(t *testing.T) {
 		p, s := beam.NewPipelineWithRoot()
 		imp := beam.Impulse(s)
@@ -443,6 +480,104 @@ func TestRunner_Metrics(t *testing.T) {
 			t.Errorf("pr.Metrics.Query(Name = \"count\")).Committed = %v, want %v", got, want)
 		}
 	})
+	t.Run("histogram_and_string_set", func(t *testing.T) {
+		p, s := beam.NewPipelineWithRoot()
+		imp := beam.Impulse(s)
+		beam.ParDo(s, dofn1HistogramAndSet, imp)
+		pr, err := executeWithT(context.Background(), t, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qr := pr.Metrics().Query(func(sr metrics.SingleResult) bool {
+			return sr.Namespace() == ns
+		})
+		if got, want := qr.Histograms()[0].Result().Counts, []int64{1, 1, 1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("pr.Metrics.Query(Name = \"latency\")).Result().Counts = %v, want %v", got, want)
+		}
+		if got, want := qr.StringSets()[0].Result(), []string{"code5", "code50", "code500"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("pr.Metrics.Query(Name = \"codes\")).Result() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestRunner_Parallel(t *testing.T) {
+	tests := []struct {
+		name        string
+		bundleSize  int
+		randomize   bool
+		wantBundles int64
+	}{
+		{"even", 0, false, 4},
+		{"sized", 10, false, 10},
+		{"randomized", 10, true, 10},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer setFlags(4, test.bundleSize, test.randomize)()
+
+			p, s := beam.NewPipelineWithRoot()
+			var in []interface{}
+			for i := 1; i <= 99; i++ {
+				in = append(in, i)
+			}
+			col := beam.ParDo(s, &bundleCounter{}, beam.Reshuffle(s, beam.Create(s, in...)))
+			passert.Sum(s, col, "sum", 99, 4950)
+			counts := beam.ParDo(s, dofnGBKCount, beam.GroupByKey(s, beam.ParDo(s, dofnMod3, col)))
+			passert.Equals(s, counts, "0:33", "1:33", "2:33")
+
+			pr, err := executeWithT(context.Background(), t, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			qr := pr.Metrics().Query(func(sr metrics.SingleResult) bool {
+				return sr.Name() == "bundles"
+			})
+			if got := qr.Counters()[0].Committed; got < test.wantBundles {
+				t.Errorf("pr.Metrics.Query(Name = \"bundles\")).Committed = %v, want at least %v", got, test.wantBundles)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{"pass", []interface{}{int64(1), int64(2), int64(3)}, false},
+		{"fail", []interface{}{int64(1), int64(2), int64(4)}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, s := beam.NewPipelineWithRoot()
+			col := beam.ParDo(s, dofn1, beam.Impulse(s))
+			passert.Equals(s, col, test.want...)
+
+			edges, _, err := p.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			plan, err := Compile(edges)
+			if err != nil {
+				t.Fatalf("Compile() failed: %v", err)
+			}
+			ctx := metrics.SetBundleID(context.Background(), "direct")
+			if err := plan.Execute(ctx, "1", exec.DataContext{}); (err != nil) != test.wantErr {
+				t.Errorf("Compile().Execute() = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// setFlags sets the parallel execution flags, and returns a function
+// restoring them.
+func setFlags(n, size int, randomize bool) func() {
+	prevN, prevSize, prevRandomize := *parallelism, *bundleSize, *randomizeBundles
+	*parallelism, *bundleSize, *randomizeBundles = n, size, randomize
+	return func() {
+		*parallelism, *bundleSize, *randomizeBundles = prevN, prevSize, prevRandomize
+	}
 }
 
 func TestMain(m *testing.M) {
@@ -33,7 +33,7 @@ type group struct {
 	values [][]exec.FullValue
 }
 
-// CoGBK buffers all input and continues on FinishBundle. Use with small single-bundle data only.
+// CoGBK buffers all input and continues on FinishBundle. Use with small data only.
 type CoGBK struct {
 	UID  exec.UnitID
 	Edge *graph.MultiEdge
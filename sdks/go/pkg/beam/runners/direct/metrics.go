@@ -0,0 +1,87 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"context"
+	"flag"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+)
+
+var metricsAddress = flag.String("direct_metrics_address", "", "Address, such as :9090, to serve the pipeline's metrics on at /metrics in the OpenMetrics format while it runs (optional).")
+
+// samplePeriod is the period for sampling PTransform execution times.
+const samplePeriod = 200 * time.Millisecond
+
+// metricsServer serves the metrics of a running pipeline for scraping.
+type metricsServer struct {
+	srv  *http.Server
+	done chan struct{}
+}
+
+// serveMetrics serves the metrics in ctx's Store on lis, and samples the
+// execution times of PTransforms, until stopped.
+func serveMetrics(ctx context.Context, lis net.Listener) *metricsServer {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.NewOpenMetricsHandler(func() metrics.Results {
+		return metrics.ResultsExtractor(ctx)
+	}))
+	s := &metricsServer{srv: &http.Server{Handler: mux}, done: make(chan struct{})}
+	go func() {
+		if err := s.srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+			log.Errorf(ctx, "metrics server failed: %v", err)
+		}
+	}()
+	go func() {
+		sampler := metrics.NewSampler(metrics.GetStore(ctx))
+		ticker := time.NewTicker(samplePeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				sampler.Sample(ctx, samplePeriod)
+			}
+		}
+	}()
+	log.Infof(ctx, "Serving pipeline metrics at http://%v/metrics", lis.Addr())
+	return s
+}
+
+func (s *metricsServer) stop() {
+	close(s.done)
+	s.srv.Close()
+}
+
+// maybeServeMetrics serves the pipeline's metrics if requested by the
+// --direct_metrics_address flag. It returns nil otherwise.
+func maybeServeMetrics(ctx context.Context) (*metricsServer, error) {
+	if *metricsAddress == "" {
+		return nil, nil
+	}
+	lis, err := net.Listen("tcp", *metricsAddress)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen for metrics scrapes on %v", *metricsAddress)
+	}
+	return serveMetrics(ctx, lis), nil
+}
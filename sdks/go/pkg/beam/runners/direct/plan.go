@@ -0,0 +1,672 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+)
+
+var (
+	parallelism      = flag.Int("direct_parallelism", 1, "Number of goroutines processing the bundles of each stage in the direct runner.")
+	bundleSize       = flag.Int("direct_bundle_size", 0, "Maximum number of elements of a bundle in the direct runner. If zero, the input of each stage is split evenly across the goroutines.")
+	randomizeBundles = flag.Bool("direct_randomize_bundles", false, "Whether the direct runner shuffles the input of each stage and splits it into bundles of random sizes.")
+	bundleSeed       = flag.Int64("direct_seed", 0, "Seed for randomized bundles in the direct runner. If zero, a seed is chosen and logged.")
+)
+
+// Plan is a multi-bundle execution plan. It consists of runner operations,
+// which read and write materialized nodes in full, and stages of fused
+// transforms, which process a materialized node in parallel bundles.
+type Plan struct {
+	ops []op
+
+	succ         map[int][]linkID         // nodeID -> []linkID
+	edges        map[int]*graph.MultiEdge // edgeID -> Edge
+	materialized map[int]bool             // nodeID -> materialized?
+}
+
+// op is an operation of the plan. It runs once all the materialized nodes
+// it reads have been written.
+type op interface {
+	// reads returns the materialized nodes the operation reads.
+	reads() []int
+	// writes returns the materialized nodes the operation writes.
+	writes() []int
+	run(ctx context.Context, ex *executor) error
+	String() string
+}
+
+// fused returns the links of the node to fused transforms, except ParDos
+// with side inputs, which head stages of their own.
+func (p *Plan) fused(id int) []linkID {
+	var ret []linkID
+	for _, l := range p.succ[id] {
+		edge := p.edges[l.to]
+		switch edge.Op {
+		case graph.ParDo, graph.Combine, graph.WindowInto:
+			if l.input == 0 && len(edge.Input) == 1 {
+				ret = append(ret, l)
+			}
+		}
+	}
+	return ret
+}
+
+// newStages returns the stages reading the materialized node: one for the
+// transforms fused downstream of it, and one for each ParDo with side inputs
+// downstream of it.
+func (p *Plan) newStages(id, root int) []op {
+	var ret []op
+	if list := p.fused(root); len(list) > 0 {
+		ret = append(ret, p.newStage(id, root, list))
+	}
+	for _, l := range p.succ[root] {
+		if edge := p.edges[l.to]; edge.Op == graph.ParDo && l.input == 0 && len(edge.Input) > 1 {
+			ret = append(ret, p.newStage(id+len(ret), root, []linkID{l}))
+		}
+	}
+	return ret
+}
+
+// newStage returns the stage of the given transforms reading the
+// materialized root node, and the transforms fused downstream of them.
+func (p *Plan) newStage(id, root int, links []linkID) *stage {
+	s := &stage{id: id, plan: p, root: root, links: links}
+	var walk func(list []linkID)
+	walk = func(list []linkID) {
+		for _, l := range list {
+			edge := p.edges[l.to]
+			s.edges = append(s.edges, edge)
+			for _, in := range edge.Input[1:] {
+				s.sides = append(s.sides, in.From.ID())
+			}
+			for _, out := range edge.Output {
+				if to := out.To.ID(); p.materialized[to] {
+					s.outputs = append(s.outputs, to)
+				} else {
+					walk(p.fused(to))
+				}
+			}
+		}
+	}
+	walk(links)
+	return s
+}
+
+// Execute runs the operations of the plan as their inputs become available.
+func (p *Plan) Execute(ctx context.Context) error {
+	seed := *bundleSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if *randomizeBundles {
+		log.Infof(ctx, "Randomizing bundles with --direct_seed=%v", seed)
+	}
+	ex := &executor{data: make(map[int][]bufElement), written: make(map[int]bool), rand: rand.New(rand.NewSource(seed))}
+
+	pending := p.ops
+	for len(pending) > 0 {
+		var blocked []op
+		for _, o := range pending {
+			if !ex.ready(o) {
+				blocked = append(blocked, o)
+				continue
+			}
+			log.Debugf(ctx, "Running %v", o)
+			if err := o.run(ctx, ex); err != nil {
+				return err
+			}
+			for _, id := range o.writes() {
+				ex.written[id] = true
+			}
+		}
+		if len(blocked) == len(pending) {
+			return errors.Errorf("no runnable operation among %v", blocked)
+		}
+		pending = blocked
+	}
+	return nil
+}
+
+func (p *Plan) String() string {
+	var ops []string
+	for _, o := range p.ops {
+		ops = append(ops, o.String())
+	}
+	return fmt.Sprintf("Plan[%v]:\n%v", len(ops), strings.Join(ops, "\n"))
+}
+
+// planRoot runs a multi-bundle plan as the root of a single bundle plan.
+type planRoot struct {
+	UID  exec.UnitID
+	plan *Plan
+}
+
+func (n *planRoot) ID() exec.UnitID {
+	return n.UID
+}
+
+func (n *planRoot) Up(ctx context.Context) error {
+	return nil
+}
+
+func (n *planRoot) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
+	return nil
+}
+
+func (n *planRoot) Process(ctx context.Context) error {
+	return n.plan.Execute(ctx)
+}
+
+func (n *planRoot) FinishBundle(ctx context.Context) error {
+	return nil
+}
+
+func (n *planRoot) Down(ctx context.Context) error {
+	return nil
+}
+
+func (n *planRoot) String() string {
+	return n.plan.String()
+}
+
+// executor holds the materialized nodes of a running plan.
+type executor struct {
+	mu      sync.Mutex
+	data    map[int][]bufElement // nodeID -> elements
+	written map[int]bool         // nodeID -> complete?
+	rand    *rand.Rand
+}
+
+func (ex *executor) ready(o op) bool {
+	for _, id := range o.reads() {
+		if !ex.written[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func (ex *executor) read(id int) []bufElement {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	return ex.data[id]
+}
+
+func (ex *executor) write(id int, elms []bufElement) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	ex.data[id] = append(ex.data[id], elms...)
+}
+
+// bundles splits the elements into bundles: evenly across the goroutines, or
+// in bundles of at most --direct_bundle_size elements. If randomized, the
+// elements are shuffled first, and bundles are of random sizes up to the
+// maximum. There is always at least one bundle, to start and finish bundles
+// of DoFns even without input.
+func (ex *executor) bundles(elms []bufElement) [][]bufElement {
+	if len(elms) == 0 {
+		return [][]bufElement{nil}
+	}
+	if *randomizeBundles {
+		elms = append([]bufElement(nil), elms...)
+		ex.rand.Shuffle(len(elms), func(i, j int) { elms[i], elms[j] = elms[j], elms[i] })
+	}
+	size := *bundleSize
+	if size <= 0 {
+		size = (len(elms) + workers() - 1) / workers()
+	}
+	var ret [][]bufElement
+	for len(elms) > 0 {
+		n := size
+		if *randomizeBundles {
+			n = 1 + ex.rand.Intn(size)
+		}
+		if n > len(elms) {
+			n = len(elms)
+		}
+		ret = append(ret, elms[:n])
+		elms = elms[n:]
+	}
+	return ret
+}
+
+func workers() int {
+	if *parallelism < 1 {
+		return 1
+	}
+	return *parallelism
+}
+
+// stage is a set of transforms fused downstream of a materialized node. Its
+// bundles are processed in parallel, by goroutines with separate execution
+// plans.
+type stage struct {
+	id      int
+	plan    *Plan
+	root    int                // materialized input node
+	links   []linkID           // transforms reading the root
+	edges   []*graph.MultiEdge // fused transforms
+	sides   []int              // materialized side input nodes
+	outputs []int              // materialized output nodes
+}
+
+func (s *stage) reads() []int {
+	return append([]int{s.root}, s.sides...)
+}
+
+func (s *stage) writes() []int {
+	return s.outputs
+}
+
+func (s *stage) run(ctx context.Context, ex *executor) error {
+	var sides *sideInputs
+	if *checkImmutability {
+		var err error
+		if sides, err = newSideInputs(s, ex); err != nil {
+			return err
+		}
+	}
+
+	bundles := ex.bundles(ex.read(s.root))
+	n := workers()
+	if n > len(bundles) {
+		n = len(bundles)
+	}
+	queue := make(chan []bufElement, len(bundles))
+	for _, b := range bundles {
+		queue <- b
+	}
+	close(queue)
+
+	// The context isn't wrapped for cancellation, as it must remain the
+	// metrics context of the pipeline.
+	stop := make(chan struct{})
+	var once sync.Once
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if errs[i] = s.work(ctx, ex, i, queue, stop); errs[i] != nil {
+				once.Do(func() { close(stop) })
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	if sides != nil {
+		return sides.verify(s)
+	}
+	return nil
+}
+
+// work processes bundles from the queue until it's empty or stopped.
+func (s *stage) work(ctx context.Context, ex *executor, worker int, queue <-chan []bufElement, stop <-chan struct{}) error {
+	b := &builder{stage: s, plan: s.plan, ex: ex, idgen: &exec.GenID{}, decode: worker > 0}
+	src, plan, err := b.build()
+	if err != nil {
+		return errors.WithContextf(err, "building %v", s)
+	}
+	i := 0
+	for bundle := range queue {
+		select {
+		case <-stop:
+			return plan.Down(ctx)
+		default:
+		}
+		src.elms = bundle
+		if err := plan.Execute(ctx, fmt.Sprintf("%v-%v-%v", s.id, worker, i), exec.DataContext{}); err != nil {
+			plan.Down(ctx) // ignore any teardown errors
+			return err
+		}
+		i++
+	}
+	return plan.Down(ctx)
+}
+
+func (s *stage) String() string {
+	var names []string
+	for _, edge := range s.edges {
+		names = append(names, edge.Name())
+	}
+	return fmt.Sprintf("Stage[%v] %v -> %v. Sides:%v Edges:%v", s.id, s.root, s.outputs, s.sides, names)
+}
+
+// builder constructs the execution plan of a stage for a goroutine.
+type builder struct {
+	stage *stage
+	plan  *Plan
+	ex    *executor
+
+	// decode is whether to decode new instances of structural Fns from the
+	// serialized edges, as on distributed runners, instead of sharing the
+	// pipeline's instances across goroutines.
+	decode bool
+
+	units []exec.Unit // result
+	idgen *exec.GenID
+}
+
+// build returns the source and execution plan of the stage.
+func (b *builder) build() (*source, *exec.Plan, error) {
+	out, err := b.makeLinks(b.stage.links)
+	if err != nil {
+		return nil, nil, err
+	}
+	src := &source{uid: b.idgen.New(), root: b.stage.root, Out: out}
+	plan, err := exec.NewPlan(fmt.Sprintf("stage%v", b.stage.id), append([]exec.Unit{src}, b.units...))
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, plan, nil
+}
+
+func (b *builder) makeNodes(edge *graph.MultiEdge) ([]exec.Node, error) {
+	var ret []exec.Node
+	for _, o := range edge.Output {
+		n, err := b.makeNode(edge, o.To)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, n)
+	}
+	return ret, nil
+}
+
+// makeNode returns the node for the output of the edge.
+func (b *builder) makeNode(edge *graph.MultiEdge, node *graph.Node) (exec.Node, error) {
+	if b.plan.materialized[node.ID()] {
+		u := newCollect(b.idgen.New(), b.ex, node, edge)
+		b.units = append(b.units, u)
+		return u, nil
+	}
+	return b.makeLinks(b.plan.fused(node.ID()))
+}
+
+// makeLinks returns the node feeding the given links.
+func (b *builder) makeLinks(list []linkID) (exec.Node, error) {
+	var u exec.Node
+	switch len(list) {
+	case 0:
+		// Discard.
+
+		u = &exec.Discard{UID: b.idgen.New()}
+
+	case 1:
+		return b.makeLink(list[0])
+
+	default:
+		// Multiplex.
+
+		var out []exec.Node
+		for _, l := range list {
+			n, err := b.makeLink(l)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, n)
+		}
+		u = &exec.Multiplex{UID: b.idgen.New(), Out: out}
+	}
+
+	b.units = append(b.units, u)
+	return u, nil
+}
+
+func (b *builder) makeLink(id linkID) (exec.Node, error) {
+	edge := b.plan.edges[id.to]
+
+	out, err := b.makeNodes(edge)
+	if err != nil {
+		return nil, err
+	}
+
+	var u exec.Node
+	switch edge.Op {
+	case graph.ParDo:
+		fn, err := b.doFn(edge)
+		if err != nil {
+			return nil, err
+		}
+		pardo := &exec.ParDo{
+			UID:     b.idgen.New(),
+			Fn:      fn,
+			Inbound: edge.Input,
+			Out:     out,
+			PID:     path.Base(fn.Name()),
+		}
+		for _, in := range edge.Input[1:] {
+			pardo.Side = append(pardo.Side, newSideInput(in.From.ID(), b.ex.read(in.From.ID())))
+		}
+		u = pardo
+		if fn.IsSplittable() {
+			u = &exec.SdfFallback{PDo: pardo}
+		}
+
+	case graph.Combine:
+		fn, err := b.combineFn(edge)
+		if err != nil {
+			return nil, err
+		}
+		u = &exec.Combine{
+			UID:     b.idgen.New(),
+			Fn:      fn,
+			UsesKey: typex.IsKV(edge.Input[0].Type),
+			Out:     out[0],
+			PID:     path.Base(fn.Name()),
+		}
+
+	case graph.WindowInto:
+		u = &exec.WindowInto{UID: b.idgen.New(), Fn: edge.WindowFn, Out: out[0]}
+
+	default:
+		return nil, errors.Errorf("unexpected edge: %v", edge)
+	}
+	b.units = append(b.units, u)
+
+	if in := edge.Input[0].From; *checkImmutability && checkable(in) {
+		u = &guard{uid: b.idgen.New(), node: in, edge: edge, Out: u}
+		b.units = append(b.units, u)
+	}
+	return u, nil
+}
+
+func (b *builder) doFn(edge *graph.MultiEdge) (*graph.DoFn, error) {
+	if !b.decode || edge.DoFn.Recv == nil {
+		return edge.DoFn, nil
+	}
+	fn, err := decodeFn(edge, edge.DoFn.Recv)
+	if err != nil {
+		return nil, err
+	}
+	return graph.AsDoFn(fn, graph.MainUnknown)
+}
+
+func (b *builder) combineFn(edge *graph.MultiEdge) (*graph.CombineFn, error) {
+	if !b.decode || edge.CombineFn.Recv == nil {
+		return edge.CombineFn, nil
+	}
+	fn, err := decodeFn(edge, edge.CombineFn.Recv)
+	if err != nil {
+		return nil, err
+	}
+	return graph.AsCombineFn(fn)
+}
+
+// decodeFn returns a new instance of the structural Fn of the edge, decoded
+// from the serialized edge.
+func decodeFn(edge *graph.MultiEdge, recv interface{}) (*graph.Fn, error) {
+	me, err := graphx.EncodeMultiEdge(edge)
+	if err != nil {
+		return nil, errors.WithContextf(err, "encoding %v for parallel execution", edge.Name())
+	}
+	_, fn, _, _, _, err := graphx.DecodeMultiEdge(me)
+	if err != nil {
+		err = errors.WithContextf(err, "decoding %v for parallel execution", edge.Name())
+		return nil, errors.SetTopLevelMsgf(err, "%v must be serializable, as on distributed runners, to run with --direct_parallelism > 1: register its type %T and any functions it references", edge.Name(), recv)
+	}
+	return fn, nil
+}
+
+// impulse writes the impulse element.
+type impulse struct {
+	edge *graph.MultiEdge
+}
+
+func (o *impulse) reads() []int {
+	return nil
+}
+
+func (o *impulse) writes() []int {
+	return []int{o.edge.Output[0].To.ID()}
+}
+
+func (o *impulse) run(ctx context.Context, ex *executor) error {
+	idgen := &exec.GenID{}
+	out := newCollect(idgen.New(), ex, o.edge.Output[0].To, o.edge)
+	imp := &Impulse{UID: idgen.New(), Value: o.edge.Value, Out: out}
+	return runOnce(ctx, "impulse", imp, out)
+}
+
+func (o *impulse) String() string {
+	return fmt.Sprintf("Impulse -> %v", o.writes())
+}
+
+// cogbk groups its materialized inputs by key and window.
+type cogbk struct {
+	edge *graph.MultiEdge
+}
+
+func (o *cogbk) reads() []int {
+	var ret []int
+	for _, in := range o.edge.Input {
+		ret = append(ret, in.From.ID())
+	}
+	return ret
+}
+
+func (o *cogbk) writes() []int {
+	return []int{o.edge.Output[0].To.ID()}
+}
+
+func (o *cogbk) run(ctx context.Context, ex *executor) error {
+	if *checkCoders {
+		for _, in := range o.edge.Input {
+			if err := checkKeys(o.edge, in.From, ex.read(in.From.ID())); err != nil {
+				return err
+			}
+		}
+	}
+
+	idgen := &exec.GenID{}
+	out := newCollect(idgen.New(), ex, o.edge.Output[0].To, o.edge)
+	gbk := &CoGBK{UID: idgen.New(), Edge: o.edge, Out: out}
+	flatten := &exec.Flatten{UID: idgen.New(), N: len(o.edge.Input), Out: gbk}
+	units := []exec.Unit{out, gbk, flatten}
+	for i, id := range o.reads() {
+		inject := &Inject{UID: idgen.New(), N: i, Out: flatten}
+		units = append(units, inject, &source{uid: idgen.New(), root: id, Out: inject, elms: ex.read(id)})
+	}
+	return runOnce(ctx, "cogbk", units...)
+}
+
+func (o *cogbk) String() string {
+	return fmt.Sprintf("CoGBK %v -> %v", o.reads(), o.writes())
+}
+
+// flatten concatenates its materialized inputs.
+type flatten struct {
+	edge *graph.MultiEdge
+}
+
+func (o *flatten) reads() []int {
+	var ret []int
+	for _, in := range o.edge.Input {
+		ret = append(ret, in.From.ID())
+	}
+	return ret
+}
+
+func (o *flatten) writes() []int {
+	return []int{o.edge.Output[0].To.ID()}
+}
+
+func (o *flatten) run(ctx context.Context, ex *executor) error {
+	for _, id := range o.reads() {
+		ex.write(o.writes()[0], ex.read(id))
+	}
+	return nil
+}
+
+func (o *flatten) String() string {
+	return fmt.Sprintf("Flatten %v -> %v", o.reads(), o.writes())
+}
+
+// reshuffle passes its materialized input through. Its consumers are thus
+// bundled independently of its producers.
+type reshuffle struct {
+	edge *graph.MultiEdge
+}
+
+func (o *reshuffle) reads() []int {
+	return []int{o.edge.Input[0].From.ID()}
+}
+
+func (o *reshuffle) writes() []int {
+	return []int{o.edge.Output[0].To.ID()}
+}
+
+func (o *reshuffle) run(ctx context.Context, ex *executor) error {
+	ex.write(o.writes()[0], ex.read(o.reads()[0]))
+	return nil
+}
+
+func (o *reshuffle) String() string {
+	return fmt.Sprintf("Reshuffle %v -> %v", o.reads(), o.writes())
+}
+
+// runOnce executes the units as a single bundle.
+func runOnce(ctx context.Context, id string, units ...exec.Unit) error {
+	plan, err := exec.NewPlan(id, units)
+	if err != nil {
+		return err
+	}
+	if err := plan.Execute(ctx, id, exec.DataContext{}); err != nil {
+		plan.Down(ctx) // ignore any teardown errors
+		return err
+	}
+	return plan.Down(ctx)
+}
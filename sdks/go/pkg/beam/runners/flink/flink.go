@@ -14,13 +14,25 @@
 // limitations under the License.
 
 // Package flink contains the Flink runner.
+//
+// With --flink_job_server=auto and no job service endpoint, the runner
+// downloads the Flink job server JAR matching the SDK version, launches it,
+// submits the pipeline and stops the job server once the job completes, so the
+// pipeline result can't be used to control the job. The job server runs the
+// job on the Flink cluster given by --flink_master, or on an embedded Flink
+// cluster with --flink_master=[local], which is convenient for local testing.
 package flink
 
 import (
 	"context"
+	"flag"
+	"fmt"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/jobopts"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/universal"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/universal/runnerlib"
 )
 
 func init() {
@@ -28,8 +40,30 @@ func init() {
 	beam.RegisterRunner("FlinkRunner", Execute)
 }
 
+var (
+	launchJobServer = flag.String("flink_job_server", "", "Set to auto to download and launch a Flink job server when no --endpoint is given. The job server is stopped once the job completes (optional).")
+	flinkMaster     = flag.String("flink_master", "[auto]", "Flink master address for a launched job server: host:port of a Flink cluster, [local] for an embedded cluster, or [auto] to use the configured cluster, if any, or an embedded one (optional).")
+	flinkVersion    = flag.String("flink_version", "1.14", "Flink version of a launched job server (optional).")
+	jobServerJar    = flag.String("flink_job_server_jar", "", "Local Flink job server JAR to launch instead of the released one (optional).")
+)
+
 // Execute runs the given pipeline on Flink. Convenience wrapper over the
 // universal runner.
 func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error) {
+	launch, err := runnerlib.LaunchJobServer(*launchJobServer, *jobopts.Endpoint)
+	if err != nil {
+		return nil, errors.WithContext(err, "parsing --flink_job_server")
+	}
+	if launch && *jobopts.TemplateLocation == "" {
+		return universal.ExecuteWithJobServer(ctx, p, jobServer())
+	}
 	return universal.Execute(ctx, p)
 }
+
+func jobServer() *runnerlib.JobServer {
+	return &runnerlib.JobServer{
+		GradleTarget: fmt.Sprintf(":runners:flink:%v:job-server:shadowJar", *flinkVersion),
+		Jar:          *jobServerJar,
+		Args:         []string{"--flink-master", *flinkMaster},
+	}
+}
@@ -0,0 +1,43 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flink
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+)
+
+func TestExecute_noEndpoint(t *testing.T) {
+	// Without --flink_job_server=auto, no job server is launched.
+	_, err := Execute(context.Background(), beam.NewPipeline())
+	if err == nil || !strings.Contains(err.Error(), "no job service endpoint specified") {
+		t.Errorf("Execute() = %v, want missing endpoint error", err)
+	}
+}
+
+func TestExecute_badJobServerMode(t *testing.T) {
+	prev := *launchJobServer
+	*launchJobServer = "always"
+	defer func() { *launchJobServer = prev }()
+
+	_, err := Execute(context.Background(), beam.NewPipeline())
+	if err == nil || !strings.Contains(err.Error(), "invalid job server mode") {
+		t.Errorf("Execute() = %v, want invalid mode error", err)
+	}
+}
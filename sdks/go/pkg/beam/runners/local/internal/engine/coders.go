@@ -0,0 +1,292 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+)
+
+const (
+	urnBytesCoder         = "beam:coder:bytes:v1"
+	urnStringCoder        = "beam:coder:string_utf8:v1"
+	urnVarIntCoder        = "beam:coder:varint:v1"
+	urnBoolCoder          = "beam:coder:bool:v1"
+	urnDoubleCoder        = "beam:coder:double:v1"
+	urnKVCoder            = "beam:coder:kv:v1"
+	urnIterableCoder      = "beam:coder:iterable:v1"
+	urnLengthPrefixCoder  = "beam:coder:length_prefix:v1"
+	urnNullableCoder      = "beam:coder:nullable:v1"
+	urnWindowedValueCoder = "beam:coder:windowed_value:v1"
+	urnGlobalWindow       = "beam:coder:global_window:v1"
+	urnIntervalWindow     = "beam:coder:interval_window:v1"
+)
+
+// coders holds the coders of the pipeline, and the coders added by the
+// runner. The runner only understands the structure of standard coders, so
+// any other coder is length prefixed before use.
+type coders struct {
+	protos map[string]*pipepb.Coder
+	um     *graphx.CoderUnmarshaller
+}
+
+func newCoders(protos map[string]*pipepb.Coder) *coders {
+	cs := &coders{protos: make(map[string]*pipepb.Coder)}
+	for id, c := range protos {
+		cs.protos[id] = c
+	}
+	cs.um = graphx.NewCoderUnmarshaller(cs.protos)
+	return cs
+}
+
+// add adds the coder under a new ID derived from the given one.
+func (cs *coders) add(id string, c *pipepb.Coder) string {
+	for i := 0; ; i++ {
+		if _, ok := cs.protos[id]; !ok {
+			break
+		}
+		id = id + "_"
+	}
+	cs.protos[id] = c
+	return id
+}
+
+// lengthPrefixUnknown returns the ID of an equivalent coder whose components
+// the runner can parse, length prefixing unknown coders as needed.
+func (cs *coders) lengthPrefixUnknown(id string) (string, error) {
+	c, ok := cs.protos[id]
+	if !ok {
+		return "", errors.Errorf("unknown coder %v", id)
+	}
+	switch c.GetSpec().GetUrn() {
+	case urnBytesCoder, urnStringCoder, urnVarIntCoder, urnBoolCoder, urnDoubleCoder,
+		urnLengthPrefixCoder, urnGlobalWindow, urnIntervalWindow:
+		return id, nil
+	case urnKVCoder, urnIterableCoder, urnNullableCoder:
+		changed := false
+		var comps []string
+		for _, cid := range c.GetComponentCoderIds() {
+			fixed, err := cs.lengthPrefixUnknown(cid)
+			if err != nil {
+				return "", err
+			}
+			changed = changed || fixed != cid
+			comps = append(comps, fixed)
+		}
+		if !changed {
+			return id, nil
+		}
+		return cs.add(id+"_lp", &pipepb.Coder{Spec: c.GetSpec(), ComponentCoderIds: comps}), nil
+	default:
+		return cs.add(id+"_lp", &pipepb.Coder{
+			Spec:              &pipepb.FunctionSpec{Urn: urnLengthPrefixCoder},
+			ComponentCoderIds: []string{id},
+		}), nil
+	}
+}
+
+// windowedValue returns the ID of a windowed value coder of the given element
+// and window coders, as used on the data channel.
+func (cs *coders) windowedValue(elmID, windowID string) string {
+	return cs.add(elmID+"_wv", &pipepb.Coder{
+		Spec:              &pipepb.FunctionSpec{Urn: urnWindowedValueCoder},
+		ComponentCoderIds: []string{elmID, windowID},
+	})
+}
+
+// components returns the component coder IDs of the coder.
+func (cs *coders) components(id string) []string {
+	return cs.protos[id].GetComponentCoderIds()
+}
+
+// skip reads past a value encoded with the coder.
+func (cs *coders) skip(id string, r *bytes.Reader) error {
+	c, ok := cs.protos[id]
+	if !ok {
+		return errors.Errorf("unknown coder %v", id)
+	}
+	switch urn := c.GetSpec().GetUrn(); urn {
+	case urnBytesCoder, urnStringCoder, urnLengthPrefixCoder:
+		n, err := coder.DecodeVarInt(r)
+		if err != nil {
+			return err
+		}
+		return discard(r, n)
+	case urnVarIntCoder:
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if b&0x80 == 0 {
+				return nil
+			}
+		}
+	case urnBoolCoder:
+		return discard(r, 1)
+	case urnDoubleCoder:
+		return discard(r, 8)
+	case urnGlobalWindow:
+		return nil
+	case urnIntervalWindow:
+		if err := discard(r, 8); err != nil {
+			return err
+		}
+		_, err := coder.DecodeVarInt(r)
+		return err
+	case urnKVCoder:
+		for _, cid := range c.GetComponentCoderIds() {
+			if err := cs.skip(cid, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case urnNullableCoder:
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == 0 {
+			return nil
+		}
+		return cs.skip(c.GetComponentCoderIds()[0], r)
+	case urnIterableCoder:
+		n, err := coder.DecodeInt32(r)
+		if err != nil {
+			return err
+		}
+		if n >= 0 {
+			return cs.skipN(c.GetComponentCoderIds()[0], int64(n), r)
+		}
+		// Blocks of elements with varint counts, terminated by a zero count.
+		for {
+			n, err := coder.DecodeVarInt(r)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return nil
+			}
+			if err := cs.skipN(c.GetComponentCoderIds()[0], n, r); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.Errorf("can't parse values of coder %v with urn %v", id, urn)
+	}
+}
+
+func (cs *coders) skipN(id string, n int64, r *bytes.Reader) error {
+	for i := int64(0); i < n; i++ {
+		if err := cs.skip(id, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func discard(r *bytes.Reader, n int64) error {
+	if int64(r.Len()) < n {
+		return io.ErrUnexpectedEOF
+	}
+	_, err := r.Seek(n, io.SeekCurrent)
+	return err
+}
+
+// element is a windowed value on the data channel, with its value still
+// encoded.
+type element struct {
+	windows   []typex.Window
+	timestamp typex.EventTime
+	pane      typex.PaneInfo
+	value     []byte
+}
+
+// pcolCoder decodes and encodes the windowed values of a PCollection.
+type pcolCoder struct {
+	cs     *coders
+	elmID  string
+	wireID string // windowed value coder of the element and window coders.
+	wc     *coder.WindowCoder
+}
+
+// decode splits the raw data channel bytes into elements.
+func (pc *pcolCoder) decode(data []byte) ([]element, error) {
+	dec := exec.MakeWindowDecoder(pc.wc)
+	r := bytes.NewReader(data)
+	var ret []element
+	for r.Len() > 0 {
+		ws, t, pn, err := exec.DecodeWindowedValueHeader(dec, r)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding windowed value header")
+		}
+		start := len(data) - r.Len()
+		if err := pc.cs.skip(pc.elmID, r); err != nil {
+			return nil, errors.Wrapf(err, "decoding element of coder %v", pc.elmID)
+		}
+		ret = append(ret, element{windows: ws, timestamp: t, pane: pn, value: data[start : len(data)-r.Len()]})
+	}
+	return ret, nil
+}
+
+// encode encodes the element for the data channel.
+func (pc *pcolCoder) encode(e element) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := exec.EncodeWindowedValueHeader(exec.MakeWindowEncoder(pc.wc), e.windows, e.timestamp, e.pane, &buf); err != nil {
+		return nil, err
+	}
+	buf.Write(e.value)
+	return buf.Bytes(), nil
+}
+
+// encodeWindow encodes the window, as used in state keys.
+func (pc *pcolCoder) encodeWindow(w typex.Window) ([]byte, error) {
+	return exec.EncodeWindow(exec.MakeWindowEncoder(pc.wc), w)
+}
+
+// splitKV splits an encoded KV into its encoded key and value.
+func (pc *pcolCoder) splitKV(value []byte) (key, val []byte, err error) {
+	comps := pc.cs.components(pc.elmID)
+	if len(comps) != 2 {
+		return nil, nil, errors.Errorf("coder %v isn't a KV coder", pc.elmID)
+	}
+	r := bytes.NewReader(value)
+	if err := pc.cs.skip(comps[0], r); err != nil {
+		return nil, nil, err
+	}
+	n := len(value) - r.Len()
+	return value[:n], value[n:], nil
+}
+
+// encodeIterable encodes the values as an iterable of known length.
+func encodeIterable(values [][]byte) []byte {
+	var buf bytes.Buffer
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(values)))
+	buf.Write(n[:])
+	for _, v := range values {
+		buf.Write(v)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,403 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package engine executes portable pipelines on SDK harnesses over the Fn API.
+// Transforms executed by the SDK are fused into stages, while impulses,
+// flattens and group by keys are executed by the runner between stages.
+// Pipelines are executed in batch: each stage runs once all its inputs are
+// complete.
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/protox"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	fnpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/fnexecution_v1"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/local/internal/jobservices"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/local/internal/worker"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/util/grpcx"
+	"github.com/golang/protobuf/proto"
+)
+
+// Execute runs the pipeline of the job to completion. SDK harnesses must be
+// provided by external worker pools, such as the loopback pool of the
+// universal runner.
+func Execute(ctx context.Context, j *jobservices.Job) error {
+	pl, err := newPlan(j.Pipeline)
+	if err != nil {
+		return errors.WithContext(err, "planning pipeline")
+	}
+	env, err := externalEnvironment(pl)
+	if err != nil {
+		return err
+	}
+
+	wk, err := worker.New(j.ID)
+	if err != nil {
+		return err
+	}
+	defer wk.Stop()
+	// Harness logs aren't sent as job messages: loopback harnesses capture
+	// the logs of their process, which include the job messages.
+	wk.Logger = func(e *fnpb.LogEntry) {
+		if e.GetSeverity() >= fnpb.LogEntry_Severity_INFO {
+			fmt.Fprintf(os.Stderr, "%v %v: %v\n", e.GetSeverity(), e.GetLogLocation(), e.GetMessage())
+		}
+	}
+	stop, err := startWorker(ctx, env, wk)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	ex := &executor{
+		job:    j,
+		plan:   pl,
+		wk:     wk,
+		data:   make(map[string][]element),
+		pcoder: make(map[string]*pcolCoder),
+	}
+	for _, s := range pl.stages {
+		if err := ex.run(ctx, s); err != nil {
+			return errors.WithContextf(err, "executing %v", s)
+		}
+	}
+	return nil
+}
+
+// externalEnvironment returns the single environment of the SDK stages,
+// which must be provided by an external worker pool.
+func externalEnvironment(pl *plan) (*pipepb.Environment, error) {
+	var envID string
+	for _, s := range pl.stages {
+		if s.kind != sdkStage {
+			continue
+		}
+		if envID != "" && s.envID != envID {
+			return nil, errors.Errorf("local runner supports a single environment, got %v and %v", envID, s.envID)
+		}
+		envID = s.envID
+	}
+	env, ok := pl.comps.GetEnvironments()[envID]
+	if !ok {
+		return nil, errors.Errorf("unknown environment %q", envID)
+	}
+	if env.GetUrn() != graphx.URNEnvExternal {
+		return nil, errors.Errorf("local runner requires an external environment, such as with --environment_type=LOOPBACK, got %v", env.GetUrn())
+	}
+	return env, nil
+}
+
+// startWorker asks the worker pool of the environment to start an SDK
+// harness connected to the worker. It returns a function that stops the
+// harness.
+func startWorker(ctx context.Context, env *pipepb.Environment, wk *worker.W) (func(), error) {
+	var payload pipepb.ExternalPayload
+	if err := proto.Unmarshal(env.GetPayload(), &payload); err != nil {
+		return nil, errors.Wrap(err, "invalid external environment payload")
+	}
+	cc, err := grpcx.Dial(ctx, payload.GetEndpoint().GetUrl(), time.Minute)
+	if err != nil {
+		return nil, errors.WithContext(err, "connecting to worker pool")
+	}
+	pool := fnpb.NewBeamFnExternalWorkerPoolClient(cc)
+	endpoint := &pipepb.ApiServiceDescriptor{Url: wk.Endpoint()}
+	resp, err := pool.StartWorker(ctx, &fnpb.StartWorkerRequest{
+		WorkerId:        wk.ID,
+		ControlEndpoint: endpoint,
+		LoggingEndpoint: endpoint,
+		Params:          payload.GetParams(),
+	})
+	if err == nil && resp.GetError() != "" {
+		err = errors.New(resp.GetError())
+	}
+	if err != nil {
+		cc.Close()
+		return nil, errors.Wrap(err, "starting worker")
+	}
+	return func() {
+		pool.StopWorker(context.Background(), &fnpb.StopWorkerRequest{WorkerId: wk.ID})
+		cc.Close()
+	}, nil
+}
+
+// executor runs the stages of a plan, holding the elements of the
+// PCollections passed between stages.
+type executor struct {
+	job  *jobservices.Job
+	plan *plan
+	wk   *worker.W
+
+	data   map[string][]element // by PCollection ID.
+	pcoder map[string]*pcolCoder
+}
+
+func (ex *executor) run(ctx context.Context, s *stage) error {
+	switch s.kind {
+	case impulseStage:
+		ex.data[s.output] = []element{impulseElement()}
+		return nil
+	case flattenStage:
+		var out []element
+		for _, in := range s.inputs {
+			out = append(out, ex.data[in]...)
+		}
+		ex.data[s.output] = out
+		return nil
+	case gbkStage:
+		return ex.groupByKey(s)
+	default:
+		return ex.runSDK(ctx, s)
+	}
+}
+
+// coder returns the coder of the elements of the PCollection.
+func (ex *executor) coder(pcol string) (*pcolCoder, error) {
+	if pc, ok := ex.pcoder[pcol]; ok {
+		return pc, nil
+	}
+	comps := ex.plan.comps
+	col, ok := comps.GetPcollections()[pcol]
+	if !ok {
+		return nil, errors.Errorf("unknown PCollection %v", pcol)
+	}
+	ws, ok := comps.GetWindowingStrategies()[col.GetWindowingStrategyId()]
+	if !ok {
+		return nil, errors.Errorf("unknown windowing strategy %v of PCollection %v", col.GetWindowingStrategyId(), pcol)
+	}
+	wc, err := ex.plan.coders.um.WindowCoder(ws.GetWindowCoderId())
+	if err != nil {
+		return nil, errors.WithContextf(err, "window coder of PCollection %v", pcol)
+	}
+	pc := &pcolCoder{
+		cs:     ex.plan.coders,
+		elmID:  col.GetCoderId(),
+		wireID: ex.plan.coders.windowedValue(col.GetCoderId(), ws.GetWindowCoderId()),
+		wc:     wc,
+	}
+	ex.pcoder[pcol] = pc
+	return pc, nil
+}
+
+// runSDK executes the stage on the SDK harness in a single bundle, followed
+// by bundles of any residuals returned by splittable DoFns. Stages rooted at
+// a splittable DoFn process each element and residual in its own bundle.
+func (ex *executor) runSDK(ctx context.Context, s *stage) error {
+	desc, err := ex.descriptor(s)
+	if err != nil {
+		return err
+	}
+	if err := ex.wk.Register(ctx, desc); err != nil {
+		return err
+	}
+	sides, err := ex.sideInputs(s)
+	if err != nil {
+		return err
+	}
+	in, err := ex.coder(s.inputs[0])
+	if err != nil {
+		return err
+	}
+	var input [][]byte
+	for _, e := range ex.data[s.inputs[0]] {
+		b, err := in.encode(e)
+		if err != nil {
+			return err
+		}
+		input = append(input, b)
+	}
+	var sinkIDs []string
+	for id := range s.sinks {
+		sinkIDs = append(sinkIDs, id)
+	}
+
+	// The SDK checkpoints only the element processed last in a bundle, so
+	// splittable DoFns process one element per bundle.
+	splittable := ex.plan.comps.GetTransforms()[s.transforms[0]].GetSpec().GetUrn() == urnProcessSizedElements
+	for len(input) > 0 {
+		n := len(input)
+		if splittable {
+			n = 1
+		}
+		b := &worker.B{
+			InstID:           ex.wk.NextInstruction(),
+			PBDID:            desc.GetId(),
+			InputTransformID: sourceID,
+			Input:            input[:n],
+			SinkIDs:          sinkIDs,
+			SideInputs:       sides,
+		}
+		input = input[n:]
+		resp, err := ex.wk.Process(ctx, b)
+		if err != nil {
+			return err
+		}
+		ex.job.AddMetrics(resp.GetMonitoringInfos())
+		for id, pcol := range s.sinks {
+			pc, err := ex.coder(pcol)
+			if err != nil {
+				return err
+			}
+			elms, err := pc.decode(b.Output(id))
+			if err != nil {
+				return errors.WithContextf(err, "decoding output %v", pcol)
+			}
+			ex.data[pcol] = append(ex.data[pcol], elms...)
+		}
+
+		// Residuals resume in a later bundle, after their requested delay.
+		var delay time.Duration
+		for _, r := range resp.GetResidualRoots() {
+			if err := ex.checkResidual(s, r.GetApplication()); err != nil {
+				return err
+			}
+			input = append(input, r.GetApplication().GetElement())
+			if d := r.GetRequestedTimeDelay().AsDuration(); d > delay {
+				delay = d
+			}
+		}
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// checkResidual fails unless the residual is of the stage input. Residuals
+// are fed to the DataSource of the stage, so they must be of the transform at
+// the root of the stage. Splittable DoFns are always stage roots, since fuse
+// starts a stage for each of them.
+func (ex *executor) checkResidual(s *stage, app *fnpb.BundleApplication) error {
+	if app.GetTransformId() != s.transforms[0] {
+		return errors.Errorf("residual of transform %v, want residuals of the stage root %v", app.GetTransformId(), s.transforms[0])
+	}
+	t := ex.plan.comps.GetTransforms()[app.GetTransformId()]
+	if pcol := t.GetInputs()[app.GetInputId()]; pcol != s.inputs[0] {
+		return errors.Errorf("residual of input %v of %v, want a residual of the stage input %v", app.GetInputId(), app.GetTransformId(), s.inputs[0])
+	}
+	return nil
+}
+
+// descriptor returns the bundle descriptor of the SDK stage, reading its
+// input from a DataSource, and writing the PCollections consumed by other
+// stages to DataSinks.
+func (ex *executor) descriptor(s *stage) (*fnpb.ProcessBundleDescriptor, error) {
+	comps := ex.plan.comps
+	desc := &fnpb.ProcessBundleDescriptor{
+		Id:                        s.id,
+		Transforms:                make(map[string]*pipepb.PTransform),
+		Pcollections:              make(map[string]*pipepb.PCollection),
+		WindowingStrategies:       comps.GetWindowingStrategies(),
+		Coders:                    comps.GetCoders(),
+		Environments:              comps.GetEnvironments(),
+		StateApiServiceDescriptor: &pipepb.ApiServiceDescriptor{Url: ex.wk.Endpoint()},
+	}
+	addPCollection := func(id string) {
+		desc.Pcollections[id] = comps.GetPcollections()[id]
+	}
+	for _, id := range s.transforms {
+		t := comps.GetTransforms()[id]
+		desc.Transforms[id] = t
+		for _, pcol := range t.GetInputs() {
+			addPCollection(pcol)
+		}
+		for _, pcol := range t.GetOutputs() {
+			addPCollection(pcol)
+		}
+	}
+
+	port := func(pcol string) ([]byte, error) {
+		pc, err := ex.coder(pcol)
+		if err != nil {
+			return nil, err
+		}
+		return protox.MustEncode(&fnpb.RemoteGrpcPort{
+			ApiServiceDescriptor: &pipepb.ApiServiceDescriptor{Url: ex.wk.Endpoint()},
+			CoderId:              pc.wireID,
+		}), nil
+	}
+	payload, err := port(s.inputs[0])
+	if err != nil {
+		return nil, err
+	}
+	desc.Transforms[sourceID] = &pipepb.PTransform{
+		UniqueName: sourceID,
+		Spec:       &pipepb.FunctionSpec{Urn: urnDataSource, Payload: payload},
+		Outputs:    map[string]string{"i0": s.inputs[0]},
+	}
+	for id, pcol := range s.sinks {
+		payload, err := port(pcol)
+		if err != nil {
+			return nil, err
+		}
+		desc.Transforms[id] = &pipepb.PTransform{
+			UniqueName: id,
+			Spec:       &pipepb.FunctionSpec{Urn: urnDataSink, Payload: payload},
+			Inputs:     map[string]string{"i0": pcol},
+		}
+	}
+	return desc, nil
+}
+
+// sideInputs materializes the side inputs of the stage by encoded window,
+// as requested over the state channel.
+func (ex *executor) sideInputs(s *stage) (map[worker.SideInputKey]*worker.SideInput, error) {
+	ret := make(map[worker.SideInputKey]*worker.SideInput)
+	for _, side := range s.sides {
+		pc, err := ex.coder(side.pcol)
+		if err != nil {
+			return nil, err
+		}
+		si := &worker.SideInput{
+			Iterable: make(map[string][][]byte),
+			Multimap: make(map[string]map[string][][]byte),
+		}
+		for _, e := range ex.data[side.pcol] {
+			for _, w := range e.windows {
+				wb, err := pc.encodeWindow(w)
+				if err != nil {
+					return nil, err
+				}
+				switch side.urn {
+				case graphx.URNMultimapSideInput:
+					k, v, err := pc.splitKV(e.value)
+					if err != nil {
+						return nil, errors.WithContextf(err, "side input %v of %v", side.localID, side.transformID)
+					}
+					m, ok := si.Multimap[string(wb)]
+					if !ok {
+						m = make(map[string][][]byte)
+						si.Multimap[string(wb)] = m
+					}
+					m[string(k)] = append(m[string(k)], v)
+				default:
+					si.Iterable[string(wb)] = append(si.Iterable[string(wb)], e.value)
+				}
+			}
+		}
+		ret[worker.SideInputKey{TransformID: side.transformID, SideInputID: side.localID}] = si
+	}
+	return ret, nil
+}
@@ -0,0 +1,143 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"sort"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+// impulseElement returns the single element of an impulse: an empty byte
+// array in the global window.
+func impulseElement() element {
+	return element{
+		windows:   window.SingleGlobalWindow,
+		timestamp: mtime.MinTimestamp,
+		pane:      typex.NoFiringPane(),
+		value:     []byte{0}, // Length prefix of the empty byte array.
+	}
+}
+
+// keyed is a value of a key in a single window.
+type keyed struct {
+	w        typex.Window
+	key, val []byte
+}
+
+// groupByKey groups the values of the input by window and key. Session
+// windows are merged per key first. Each group is emitted at the end of its
+// window, as the only pane.
+func (ex *executor) groupByKey(s *stage) error {
+	in, err := ex.coder(s.inputs[0])
+	if err != nil {
+		return err
+	}
+	var kvs []keyed
+	for _, e := range ex.data[s.inputs[0]] {
+		k, v, err := in.splitKV(e.value)
+		if err != nil {
+			return err
+		}
+		for _, w := range e.windows {
+			kvs = append(kvs, keyed{w: w, key: k, val: v})
+		}
+	}
+	col := ex.plan.comps.GetPcollections()[s.inputs[0]]
+	ws := ex.plan.comps.GetWindowingStrategies()[col.GetWindowingStrategyId()]
+	if ws.GetWindowFn().GetUrn() == graphx.URNSessionsWindowFn {
+		if err := mergeSessions(kvs); err != nil {
+			return err
+		}
+	}
+
+	type group struct {
+		w      typex.Window
+		key    []byte
+		values [][]byte
+	}
+	var groups []*group
+	index := make(map[string]*group)
+	for _, kv := range kvs {
+		wb, err := in.encodeWindow(kv.w)
+		if err != nil {
+			return err
+		}
+		id := string(wb) + string(kv.key)
+		g, ok := index[id]
+		if !ok {
+			g = &group{w: kv.w, key: kv.key}
+			index[id] = g
+			groups = append(groups, g)
+		}
+		g.values = append(g.values, kv.val)
+	}
+
+	var ret []element
+	for _, g := range groups {
+		ret = append(ret, element{
+			windows:   []typex.Window{g.w},
+			timestamp: g.w.MaxTimestamp(),
+			pane:      typex.PaneInfo{Timing: typex.PaneOnTime, IsFirst: true, IsLast: true},
+			value:     append(append([]byte{}, g.key...), encodeIterable(g.values)...),
+		})
+	}
+	ex.data[s.output] = ret
+	return nil
+}
+
+// mergeSessions replaces the session windows of each key with the union of
+// the overlapping windows of that key.
+func mergeSessions(kvs []keyed) error {
+	byKey := make(map[string][]int)
+	for i, kv := range kvs {
+		if _, ok := kv.w.(window.IntervalWindow); !ok {
+			return errors.Errorf("can't merge window %v, want an interval window", kv.w)
+		}
+		byKey[string(kv.key)] = append(byKey[string(kv.key)], i)
+	}
+	for _, idx := range byKey {
+		sort.Slice(idx, func(i, j int) bool {
+			return kvs[idx[i]].w.(window.IntervalWindow).Start < kvs[idx[j]].w.(window.IntervalWindow).Start
+		})
+		var merged window.IntervalWindow
+		var members []int
+		flush := func() {
+			for _, i := range members {
+				kvs[i].w = merged
+			}
+			members = members[:0]
+		}
+		for _, i := range idx {
+			w := kvs[i].w.(window.IntervalWindow)
+			if len(members) > 0 && w.Start < merged.End {
+				if w.End > merged.End {
+					merged.End = w.End
+				}
+			} else {
+				flush()
+				merged = w
+			}
+			members = append(members, i)
+		}
+		flush()
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+)
+
+// gbkExecutor returns an executor for a GBK of the PCollection "in" of KVs
+// of strings and varints, with the given window function, into "out".
+func gbkExecutor(windowFn, windowCoder string, in []element) *executor {
+	comps := &pipepb.Components{
+		Pcollections: map[string]*pipepb.PCollection{
+			"in":  {CoderId: "kv", WindowingStrategyId: "ws"},
+			"out": {CoderId: "kv_iter", WindowingStrategyId: "ws"},
+		},
+		WindowingStrategies: map[string]*pipepb.WindowingStrategy{
+			"ws": {
+				WindowFn:      &pipepb.FunctionSpec{Urn: windowFn},
+				WindowCoderId: windowCoder,
+			},
+		},
+	}
+	cs := newCoders(map[string]*pipepb.Coder{
+		"str":     {Spec: &pipepb.FunctionSpec{Urn: urnStringCoder}},
+		"int":     {Spec: &pipepb.FunctionSpec{Urn: urnVarIntCoder}},
+		"iter":    {Spec: &pipepb.FunctionSpec{Urn: urnIterableCoder}, ComponentCoderIds: []string{"int"}},
+		"kv":      {Spec: &pipepb.FunctionSpec{Urn: urnKVCoder}, ComponentCoderIds: []string{"str", "int"}},
+		"kv_iter": {Spec: &pipepb.FunctionSpec{Urn: urnKVCoder}, ComponentCoderIds: []string{"str", "iter"}},
+		"global":  {Spec: &pipepb.FunctionSpec{Urn: urnGlobalWindow}},
+		"window":  {Spec: &pipepb.FunctionSpec{Urn: urnIntervalWindow}},
+	})
+	return &executor{
+		plan:   &plan{comps: comps, coders: cs},
+		data:   map[string][]element{"in": in},
+		pcoder: make(map[string]*pcolCoder),
+	}
+}
+
+// kv returns the encoded KV of a single byte string and a small varint.
+func kv(k string, v byte) []byte {
+	return append([]byte{byte(len(k))}, append([]byte(k), v)...)
+}
+
+// group returns the encoded KV of a single byte string and an iterable of
+// small varints.
+func group(k string, vs ...byte) []byte {
+	return append([]byte{byte(len(k))}, append([]byte(k), encodeIterable(bytesOf(vs))...)...)
+}
+
+func bytesOf(vs []byte) [][]byte {
+	var ret [][]byte
+	for _, v := range vs {
+		ret = append(ret, []byte{v})
+	}
+	return ret
+}
+
+func iw(start, end int64) window.IntervalWindow {
+	return window.IntervalWindow{Start: mtime.Time(start), End: mtime.Time(end)}
+}
+
+func TestGroupByKey(t *testing.T) {
+	global := func(value []byte) element {
+		return element{windows: window.SingleGlobalWindow, timestamp: mtime.ZeroTimestamp, value: value}
+	}
+	in := []element{
+		global(kv("a", 1)),
+		global(kv("b", 2)),
+		global(kv("a", 3)),
+		// Elements in several windows are grouped in each of them.
+		{windows: []typex.Window{window.GlobalWindow{}, window.GlobalWindow{}}, value: kv("c", 4)},
+	}
+	ex := gbkExecutor(graphx.URNGlobalWindowsWindowFn, "global", in)
+	if err := ex.groupByKey(&stage{kind: gbkStage, inputs: []string{"in"}, output: "out"}); err != nil {
+		t.Fatalf("groupByKey failed: %v", err)
+	}
+
+	onTime := typex.PaneInfo{Timing: typex.PaneOnTime, IsFirst: true, IsLast: true}
+	var want []element
+	for _, value := range [][]byte{group("a", 1, 3), group("b", 2), group("c", 4, 4)} {
+		want = append(want, element{
+			windows:   window.SingleGlobalWindow,
+			timestamp: window.GlobalWindow{}.MaxTimestamp(),
+			pane:      onTime,
+			value:     value,
+		})
+	}
+	if got := ex.data["out"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("groupByKey() = %v, want %v", got, want)
+	}
+}
+
+func TestGroupByKey_sessions(t *testing.T) {
+	in := []element{
+		{windows: []typex.Window{iw(0, 5)}, value: kv("a", 1)},
+		{windows: []typex.Window{iw(10, 15)}, value: kv("a", 2)},
+		{windows: []typex.Window{iw(3, 8)}, value: kv("a", 3)},
+		{windows: []typex.Window{iw(3, 8)}, value: kv("b", 4)},
+	}
+	ex := gbkExecutor(graphx.URNSessionsWindowFn, "window", in)
+	if err := ex.groupByKey(&stage{kind: gbkStage, inputs: []string{"in"}, output: "out"}); err != nil {
+		t.Fatalf("groupByKey failed: %v", err)
+	}
+
+	type result struct {
+		w     typex.Window
+		t     typex.EventTime
+		value []byte
+	}
+	var got []result
+	for _, e := range ex.data["out"] {
+		if len(e.windows) != 1 {
+			t.Fatalf("groupByKey() element in windows %v, want one", e.windows)
+		}
+		got = append(got, result{e.windows[0], e.timestamp, e.value})
+	}
+	want := []result{
+		{iw(0, 8), iw(0, 8).MaxTimestamp(), group("a", 1, 3)},
+		{iw(10, 15), iw(10, 15).MaxTimestamp(), group("a", 2)},
+		{iw(3, 8), iw(3, 8).MaxTimestamp(), group("b", 4)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groupByKey() = %v, want %v", got, want)
+	}
+}
+
+func TestMergeSessions(t *testing.T) {
+	kvs := []keyed{
+		{w: iw(10, 15), key: []byte("a")},
+		{w: iw(0, 5), key: []byte("a")},
+		{w: iw(4, 9), key: []byte("b")},
+		{w: iw(3, 8), key: []byte("a")},
+		{w: iw(7, 12), key: []byte("a")}, // Overlaps the session merged from [0, 5) and [3, 8).
+		{w: iw(20, 25), key: []byte("a")},
+		{w: iw(25, 30), key: []byte("a")}, // Adjacent windows don't merge.
+	}
+	if err := mergeSessions(kvs); err != nil {
+		t.Fatalf("mergeSessions failed: %v", err)
+	}
+	var got []typex.Window
+	for _, kv := range kvs {
+		got = append(got, kv.w)
+	}
+	want := []typex.Window{iw(0, 15), iw(0, 15), iw(4, 9), iw(0, 15), iw(0, 15), iw(20, 25), iw(25, 30)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeSessions() windows = %v, want %v", got, want)
+	}
+
+	if err := mergeSessions([]keyed{{w: window.GlobalWindow{}}}); err == nil {
+		t.Error("mergeSessions(global window) succeeded, want error")
+	}
+}
+
+func TestImpulseElement(t *testing.T) {
+	e := impulseElement()
+	if !bytes.Equal(e.value, []byte{0}) {
+		t.Errorf("impulseElement() value = %v, want the empty byte array", e.value)
+	}
+	if !reflect.DeepEqual(e.windows, window.SingleGlobalWindow) {
+		t.Errorf("impulseElement() windows = %v, want %v", e.windows, window.SingleGlobalWindow)
+	}
+}
@@ -0,0 +1,416 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/pipelinex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	urnPairWithRestriction  = "beam:transform:sdf_pair_with_restriction:v1"
+	urnSplitAndSize         = "beam:transform:sdf_split_and_size_restrictions:v1"
+	urnProcessSizedElements = "beam:transform:sdf_process_sized_element_and_restrictions:v1"
+	urnDataSource           = "beam:runner:source:v1"
+	urnDataSink             = "beam:runner:sink:v1"
+
+	// sourceID is the transform ID of the DataSource of SDK stages.
+	sourceID = "source"
+	// sinkPrefix prefixes the PCollection ID in transform IDs of DataSinks.
+	sinkPrefix = "sink_"
+)
+
+// stageKind is the kind of work done by a stage.
+type stageKind int
+
+const (
+	sdkStage     stageKind = iota // Fused transforms executed by the SDK harness.
+	impulseStage                  // An impulse, executed by the runner.
+	gbkStage                      // A group by key, executed by the runner.
+	flattenStage                  // A flatten, executed by the runner.
+)
+
+// sideInput is a side input of a transform in an SDK stage.
+type sideInput struct {
+	transformID, localID, pcol, urn string
+}
+
+// stage is a unit of execution. SDK stages have a single input PCollection,
+// and execute as bundles on the SDK harness.
+type stage struct {
+	id         string
+	kind       stageKind
+	transforms []string // leaf transform IDs.
+	inputs     []string // PCollection IDs. Only flattens have several.
+	output     string   // PCollection ID produced by runner stages.
+	envID      string
+	sides      []sideInput
+	sinks      map[string]string // DataSink transform ID to PCollection ID.
+}
+
+func (s *stage) String() string {
+	return fmt.Sprintf("stage[%v]%v", s.id, s.transforms)
+}
+
+// plan holds the stages of a pipeline, in execution order, and the
+// components used to execute them.
+type plan struct {
+	comps  *pipepb.Components
+	coders *coders
+	stages []*stage
+}
+
+// newPlan expands splittable DoFns and fuses the leaf transforms of the
+// pipeline into stages.
+func newPlan(p *pipepb.Pipeline) (*plan, error) {
+	if err := checkRequirements(p); err != nil {
+		return nil, err
+	}
+	src := p.GetComponents()
+	comps := &pipepb.Components{
+		Transforms:          make(map[string]*pipepb.PTransform),
+		Pcollections:        make(map[string]*pipepb.PCollection),
+		WindowingStrategies: src.GetWindowingStrategies(),
+		Environments:        src.GetEnvironments(),
+	}
+	// Transforms with resource hints run on the workers of their environment
+	// without hints, since the runner doesn't act on hints.
+	envs := workerEnvs(src.GetEnvironments())
+	for id, t := range src.GetTransforms() {
+		if env, ok := envs[t.GetEnvironmentId()]; ok && env != t.GetEnvironmentId() {
+			t = proto.Clone(t).(*pipepb.PTransform)
+			t.EnvironmentId = env
+		}
+		comps.Transforms[id] = t
+	}
+	cs := newCoders(src.GetCoders())
+	comps.Coders = cs.protos
+
+	// Rewrite PCollection coders to ones the runner can parse.
+	for id, pc := range src.GetPcollections() {
+		cid, err := cs.lengthPrefixUnknown(pc.GetCoderId())
+		if err != nil {
+			return nil, errors.WithContextf(err, "PCollection %v", id)
+		}
+		if cid != pc.GetCoderId() {
+			pc = proto.Clone(pc).(*pipepb.PCollection)
+			pc.CoderId = cid
+		}
+		comps.Pcollections[id] = pc
+	}
+
+	var leaves []string
+	for id, t := range comps.GetTransforms() {
+		if len(t.GetSubtransforms()) == 0 {
+			leaves = append(leaves, id)
+		}
+	}
+	sort.Strings(leaves)
+	leaves, err := expandSplittable(comps, cs, leaves)
+	if err != nil {
+		return nil, err
+	}
+	stages, err := fuse(comps, pipelinex.TopologicalSort(comps.GetTransforms(), leaves))
+	if err != nil {
+		return nil, err
+	}
+	return &plan{comps: comps, coders: cs, stages: stages}, nil
+}
+
+// workerEnvs maps the IDs of environments that differ only in their resource
+// hints to the same ID.
+func workerEnvs(envs map[string]*pipepb.Environment) map[string]string {
+	var ids []string
+	for id := range envs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	ret := make(map[string]string)
+	for i, id := range ids {
+		if _, ok := ret[id]; ok {
+			continue
+		}
+		ret[id] = id
+		for _, other := range ids[i+1:] {
+			if _, ok := ret[other]; !ok && graphx.SameWorkerEnvironment(envs[id], envs[other]) {
+				ret[other] = id
+			}
+		}
+	}
+	return ret
+}
+
+// checkRequirements fails for pipelines that need unsupported features.
+func checkRequirements(p *pipepb.Pipeline) error {
+	for _, req := range p.GetRequirements() {
+		switch req {
+		case graphx.URNRequiresSplittableDoFn, graphx.URNRequiresBundleFinalization, graphx.URNRequiresStatefulProcessing:
+		default:
+			return errors.Errorf("local runner doesn't support requirement %v", req)
+		}
+	}
+	return nil
+}
+
+// expandSplittable replaces splittable ParDos with the pair, split and
+// process transforms executed by the SDK harness.
+func expandSplittable(comps *pipepb.Components, cs *coders, leaves []string) ([]string, error) {
+	var ret []string
+	for _, id := range leaves {
+		t := comps.GetTransforms()[id]
+		if t.GetSpec().GetUrn() != graphx.URNParDo {
+			ret = append(ret, id)
+			continue
+		}
+		var pardo pipepb.ParDoPayload
+		if err := proto.Unmarshal(t.GetSpec().GetPayload(), &pardo); err != nil {
+			return nil, errors.Wrapf(err, "invalid ParDo payload for %v", id)
+		}
+		if pardo.GetRestrictionCoderId() == "" {
+			ret = append(ret, id)
+			continue
+		}
+		mainLocal, err := mainInput(t, &pardo)
+		if err != nil {
+			return nil, err
+		}
+		in := comps.GetPcollections()[t.GetInputs()[mainLocal]]
+		rc, err := cs.lengthPrefixUnknown(pardo.GetRestrictionCoderId())
+		if err != nil {
+			return nil, errors.WithContextf(err, "restriction coder of %v", id)
+		}
+		pairCoder := cs.add(id+"_pair", &pipepb.Coder{
+			Spec:              &pipepb.FunctionSpec{Urn: urnKVCoder},
+			ComponentCoderIds: []string{in.GetCoderId(), rc},
+		})
+		double := cs.add("double", &pipepb.Coder{Spec: &pipepb.FunctionSpec{Urn: urnDoubleCoder}})
+		sizedCoder := cs.add(id+"_sized", &pipepb.Coder{
+			Spec:              &pipepb.FunctionSpec{Urn: urnKVCoder},
+			ComponentCoderIds: []string{pairCoder, double},
+		})
+		pairOut, splitOut := id+"_paired", id+"_split"
+		comps.Pcollections[pairOut] = &pipepb.PCollection{
+			UniqueName:          pairOut,
+			CoderId:             pairCoder,
+			IsBounded:           in.GetIsBounded(),
+			WindowingStrategyId: in.GetWindowingStrategyId(),
+		}
+		comps.Pcollections[splitOut] = &pipepb.PCollection{
+			UniqueName:          splitOut,
+			CoderId:             sizedCoder,
+			IsBounded:           in.GetIsBounded(),
+			WindowingStrategyId: in.GetWindowingStrategyId(),
+		}
+
+		spec := func(urn string) *pipepb.FunctionSpec {
+			return &pipepb.FunctionSpec{Urn: urn, Payload: t.GetSpec().GetPayload()}
+		}
+		pairID, splitID, processID := id+"/PairWithRestriction", id+"/SplitAndSizeRestrictions", id+"/Process"
+		comps.Transforms[pairID] = &pipepb.PTransform{
+			UniqueName:    t.GetUniqueName() + "/PairWithRestriction",
+			Spec:          spec(urnPairWithRestriction),
+			Inputs:        map[string]string{mainLocal: t.GetInputs()[mainLocal]},
+			Outputs:       map[string]string{"i0": pairOut},
+			EnvironmentId: t.GetEnvironmentId(),
+		}
+		comps.Transforms[splitID] = &pipepb.PTransform{
+			UniqueName:    t.GetUniqueName() + "/SplitAndSizeRestrictions",
+			Spec:          spec(urnSplitAndSize),
+			Inputs:        map[string]string{mainLocal: pairOut},
+			Outputs:       map[string]string{"i0": splitOut},
+			EnvironmentId: t.GetEnvironmentId(),
+		}
+		inputs := make(map[string]string)
+		for local, pcol := range t.GetInputs() {
+			inputs[local] = pcol
+		}
+		inputs[mainLocal] = splitOut
+		comps.Transforms[processID] = &pipepb.PTransform{
+			UniqueName:    t.GetUniqueName() + "/Process",
+			Spec:          spec(urnProcessSizedElements),
+			Inputs:        inputs,
+			Outputs:       t.GetOutputs(),
+			EnvironmentId: t.GetEnvironmentId(),
+		}
+		delete(comps.Transforms, id)
+		ret = append(ret, pairID, splitID, processID)
+	}
+	return ret, nil
+}
+
+// mainInput returns the local name of the main input of the ParDo.
+func mainInput(t *pipepb.PTransform, pardo *pipepb.ParDoPayload) (string, error) {
+	var ret []string
+	for local := range t.GetInputs() {
+		if _, ok := pardo.GetSideInputs()[local]; !ok {
+			ret = append(ret, local)
+		}
+	}
+	if len(ret) != 1 {
+		return "", errors.Errorf("transform %v has %d main inputs, want 1", t.GetUniqueName(), len(ret))
+	}
+	return ret[0], nil
+}
+
+// fuse groups the topologically sorted leaf transforms into stages. A
+// transform executed by the SDK joins the stage producing its main input,
+// unless it reads side inputs or processes restrictions, which need their
+// own stage.
+func fuse(comps *pipepb.Components, leaves []string) ([]*stage, error) {
+	var stages []*stage
+	producers := make(map[string]*stage) // by PCollection ID.
+	newStage := func(kind stageKind, id string, inputs ...string) *stage {
+		s := &stage{
+			id:         fmt.Sprintf("stage-%03d", len(stages)),
+			kind:       kind,
+			transforms: []string{id},
+			inputs:     inputs,
+			sinks:      make(map[string]string),
+		}
+		stages = append(stages, s)
+		return s
+	}
+
+	for _, id := range leaves {
+		t := comps.GetTransforms()[id]
+		var s *stage
+		switch urn := t.GetSpec().GetUrn(); urn {
+		case graphx.URNImpulse:
+			s = newStage(impulseStage, id)
+		case graphx.URNGBK:
+			s = newStage(gbkStage, id, sortedValues(t.GetInputs())...)
+		case graphx.URNFlatten:
+			s = newStage(flattenStage, id, sortedValues(t.GetInputs())...)
+		default:
+			main, sides, err := sdkInputs(t)
+			if err != nil {
+				return nil, err
+			}
+			if main == "" {
+				return nil, errors.Errorf("unsupported root transform %v with urn %v", t.GetUniqueName(), urn)
+			}
+			if prod := producers[main]; prod != nil && prod.kind == sdkStage && prod.envID == t.GetEnvironmentId() &&
+				len(sides) == 0 && urn != urnProcessSizedElements {
+				s = prod
+				s.transforms = append(s.transforms, id)
+			} else {
+				s = newStage(sdkStage, id, main)
+				s.envID = t.GetEnvironmentId()
+			}
+			for _, side := range sides {
+				side.transformID = id
+				s.sides = append(s.sides, side)
+			}
+		}
+		if s.kind != sdkStage {
+			outs := sortedValues(t.GetOutputs())
+			if len(outs) != 1 {
+				return nil, errors.Errorf("transform %v has %d outputs, want 1", t.GetUniqueName(), len(outs))
+			}
+			s.output = outs[0]
+		}
+		for _, out := range t.GetOutputs() {
+			producers[out] = s
+		}
+	}
+
+	// SDK stages send the PCollections consumed by other stages to the runner.
+	consumers := make(map[string][]*stage)
+	for _, s := range stages {
+		for _, in := range s.inputs {
+			consumers[in] = append(consumers[in], s)
+		}
+		for _, side := range s.sides {
+			consumers[side.pcol] = append(consumers[side.pcol], s)
+		}
+	}
+	for _, s := range stages {
+		if s.kind != sdkStage {
+			continue
+		}
+		for _, id := range s.transforms {
+			for _, out := range comps.GetTransforms()[id].GetOutputs() {
+				for _, c := range consumers[out] {
+					if c != s {
+						s.sinks[sinkPrefix+out] = out
+					}
+				}
+			}
+		}
+	}
+	return stages, nil
+}
+
+// sdkInputs returns the main input and side inputs of a transform executed
+// by the SDK harness.
+func sdkInputs(t *pipepb.PTransform) (string, []sideInput, error) {
+	switch t.GetSpec().GetUrn() {
+	case graphx.URNParDo, urnPairWithRestriction, urnSplitAndSize, urnProcessSizedElements:
+		var pardo pipepb.ParDoPayload
+		if err := proto.Unmarshal(t.GetSpec().GetPayload(), &pardo); err != nil {
+			return "", nil, errors.Wrapf(err, "invalid ParDo payload for %v", t.GetUniqueName())
+		}
+		if len(t.GetInputs()) == 0 {
+			return "", nil, nil
+		}
+		local, err := mainInput(t, &pardo)
+		if err != nil {
+			return "", nil, err
+		}
+		var sides []sideInput
+		for _, l := range sortedKeys(pardo.GetSideInputs()) {
+			sides = append(sides, sideInput{
+				localID: l,
+				pcol:    t.GetInputs()[l],
+				urn:     pardo.GetSideInputs()[l].GetAccessPattern().GetUrn(),
+			})
+		}
+		return t.GetInputs()[local], sides, nil
+	default:
+		ins := sortedValues(t.GetInputs())
+		switch len(ins) {
+		case 0:
+			return "", nil, nil
+		case 1:
+			return ins[0], nil, nil
+		default:
+			return "", nil, errors.Errorf("transform %v has %d inputs, want 1", t.GetUniqueName(), len(ins))
+		}
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func sortedValues(m map[string]string) []string {
+	var ret []string
+	for _, k := range sortedKeys(m) {
+		ret = append(ret, m[k])
+	}
+	return ret
+}
@@ -0,0 +1,244 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+func transform(urn, env string, inputs, outputs map[string]string) *pipepb.PTransform {
+	return &pipepb.PTransform{
+		UniqueName:    urn,
+		Spec:          &pipepb.FunctionSpec{Urn: urn},
+		Inputs:        inputs,
+		Outputs:       outputs,
+		EnvironmentId: env,
+	}
+}
+
+func pardo(t *testing.T, env string, payload *pipepb.ParDoPayload, inputs, outputs map[string]string) *pipepb.PTransform {
+	t.Helper()
+	data, err := proto.Marshal(payload)
+	if err != nil {
+		t.Fatalf("proto.Marshal(%v) failed: %v", payload, err)
+	}
+	ret := transform(graphx.URNParDo, env, inputs, outputs)
+	ret.Spec.Payload = data
+	return ret
+}
+
+func in(pcol string) map[string]string  { return map[string]string{"i0": pcol} }
+func out(pcol string) map[string]string { return map[string]string{"i0": pcol} }
+
+// stageSummary is the part of a stage compared by the tests.
+type stageSummary struct {
+	kind       stageKind
+	transforms []string
+	inputs     []string
+	output     string
+	envID      string
+	sides      []sideInput
+	sinks      map[string]string
+}
+
+func summarize(stages []*stage) []stageSummary {
+	var ret []stageSummary
+	for _, s := range stages {
+		ret = append(ret, stageSummary{s.kind, s.transforms, s.inputs, s.output, s.envID, s.sides, s.sinks})
+	}
+	return ret
+}
+
+func TestFuse(t *testing.T) {
+	sides := &pipepb.ParDoPayload{
+		SideInputs: map[string]*pipepb.SideInput{
+			"side": {AccessPattern: &pipepb.FunctionSpec{Urn: graphx.URNIterableSideInput}},
+		},
+	}
+	comps := &pipepb.Components{
+		Transforms: map[string]*pipepb.PTransform{
+			"impulse": transform(graphx.URNImpulse, "", nil, out("p0")),
+			"a":       pardo(t, "go", &pipepb.ParDoPayload{}, in("p0"), out("p1")),
+			"b":       pardo(t, "go", &pipepb.ParDoPayload{}, in("p1"), out("p2")),
+			"other":   pardo(t, "py", &pipepb.ParDoPayload{}, in("p1"), out("p3")),
+			"gbk":     transform(graphx.URNGBK, "", in("p2"), out("p4")),
+			"c":       pardo(t, "go", &pipepb.ParDoPayload{}, in("p4"), out("p5")),
+			"d":       pardo(t, "go", sides, map[string]string{"i0": "p5", "side": "p1"}, out("p6")),
+			"flatten": transform(graphx.URNFlatten, "", map[string]string{"i0": "p5", "i1": "p6"}, out("p7")),
+		},
+	}
+	stages, err := fuse(comps, []string{"impulse", "a", "b", "other", "gbk", "c", "d", "flatten"})
+	if err != nil {
+		t.Fatalf("fuse failed: %v", err)
+	}
+	none := map[string]string{}
+	want := []stageSummary{
+		{kind: impulseStage, transforms: []string{"impulse"}, output: "p0", sinks: none},
+		// The ParDos of an environment fuse with the stage of their main input,
+		// and send the PCollections consumed by other stages to the runner.
+		{kind: sdkStage, transforms: []string{"a", "b"}, inputs: []string{"p0"}, envID: "go",
+			sinks: map[string]string{"sink_p1": "p1", "sink_p2": "p2"}},
+		{kind: sdkStage, transforms: []string{"other"}, inputs: []string{"p1"}, envID: "py", sinks: none},
+		{kind: gbkStage, transforms: []string{"gbk"}, inputs: []string{"p2"}, output: "p4", sinks: none},
+		{kind: sdkStage, transforms: []string{"c"}, inputs: []string{"p4"}, envID: "go",
+			sinks: map[string]string{"sink_p5": "p5"}},
+		// Transforms reading side inputs start a new stage.
+		{kind: sdkStage, transforms: []string{"d"}, inputs: []string{"p5"}, envID: "go",
+			sides: []sideInput{{transformID: "d", localID: "side", pcol: "p1", urn: graphx.URNIterableSideInput}},
+			sinks: map[string]string{"sink_p6": "p6"}},
+		{kind: flattenStage, transforms: []string{"flatten"}, inputs: []string{"p5", "p6"}, output: "p7", sinks: none},
+	}
+	if got := summarize(stages); !reflect.DeepEqual(got, want) {
+		t.Errorf("fuse() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFuse_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		t    *pipepb.PTransform
+	}{
+		{"unsupportedRoot", transform("beam:transform:read:v1", "go", nil, out("p0"))},
+		{"severalInputs", transform("beam:transform:unknown:v1", "go", map[string]string{"i0": "a", "i1": "b"}, out("p0"))},
+		{"gbkOutputs", transform(graphx.URNGBK, "", in("a"), map[string]string{"i0": "b", "i1": "c"})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			comps := &pipepb.Components{Transforms: map[string]*pipepb.PTransform{"t": test.t}}
+			if _, err := fuse(comps, []string{"t"}); err == nil {
+				t.Errorf("fuse(%v) succeeded, want error", test.t)
+			}
+		})
+	}
+}
+
+func TestNewPlan(t *testing.T) {
+	env := &pipepb.Environment{Urn: graphx.URNEnvDocker}
+	hinted := proto.Clone(env).(*pipepb.Environment)
+	hinted.ResourceHints = map[string][]byte{"beam:resources:min_ram_bytes:v1": []byte("1024")}
+	pcol := func(coderID string) *pipepb.PCollection {
+		return &pipepb.PCollection{CoderId: coderID, WindowingStrategyId: "ws"}
+	}
+	p := &pipepb.Pipeline{
+		Components: &pipepb.Components{
+			Transforms: map[string]*pipepb.PTransform{
+				"impulse": transform(graphx.URNImpulse, "", nil, out("p0")),
+				"sdf":     pardo(t, "hinted", &pipepb.ParDoPayload{RestrictionCoderId: "rest"}, in("p0"), out("p1")),
+				"after":   pardo(t, "go", &pipepb.ParDoPayload{}, in("p1"), out("p2")),
+			},
+			Pcollections: map[string]*pipepb.PCollection{
+				"p0": pcol("bytes"),
+				"p1": pcol("custom"),
+				"p2": pcol("bytes"),
+			},
+			Coders: map[string]*pipepb.Coder{
+				"bytes":  {Spec: &pipepb.FunctionSpec{Urn: urnBytesCoder}},
+				"rest":   {Spec: &pipepb.FunctionSpec{Urn: "beam:go:coder:custom:v1"}},
+				"custom": {Spec: &pipepb.FunctionSpec{Urn: "beam:go:coder:custom:v1"}},
+			},
+			WindowingStrategies: map[string]*pipepb.WindowingStrategy{"ws": {}},
+			Environments:        map[string]*pipepb.Environment{"go": env, "hinted": hinted},
+		},
+		Requirements: []string{graphx.URNRequiresSplittableDoFn},
+	}
+	pl, err := newPlan(p)
+	if err != nil {
+		t.Fatalf("newPlan failed: %v", err)
+	}
+
+	var got [][]string
+	for _, s := range pl.stages {
+		got = append(got, s.transforms)
+		if s.kind == sdkStage && s.envID != "go" {
+			t.Errorf("%v runs in environment %v, want go", s, s.envID)
+		}
+	}
+	// Splittable DoFns expand into the restriction transforms, with the
+	// processing of restrictions in its own stage. The hinted environment
+	// runs on the same worker, so the next ParDo fuses with it.
+	want := [][]string{
+		{"impulse"},
+		{"sdf/PairWithRestriction", "sdf/SplitAndSizeRestrictions"},
+		{"sdf/Process", "after"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("newPlan() stages = %v, want %v", got, want)
+	}
+	if _, ok := pl.comps.GetTransforms()["sdf"]; ok {
+		t.Error("newPlan() kept the splittable ParDo, want it replaced")
+	}
+	if got, want := pl.comps.GetTransforms()["sdf/Process"].GetOutputs(), out("p1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("sdf/Process outputs = %v, want %v", got, want)
+	}
+	if got := p.GetComponents().GetTransforms()["sdf"].GetEnvironmentId(); got != "hinted" {
+		t.Errorf("newPlan() changed the pipeline: environment of sdf = %v, want hinted", got)
+	}
+
+	// Unknown coders are length prefixed, so the runner can split elements.
+	c := pl.coders.protos[pl.comps.GetPcollections()["p1"].GetCoderId()]
+	if got, want := c.GetSpec().GetUrn(), urnLengthPrefixCoder; got != want {
+		t.Errorf("coder of p1 = %v, want %v", got, want)
+	}
+	split := pl.comps.GetPcollections()["sdf_split"]
+	sized := pl.coders.protos[split.GetCoderId()]
+	pair := pl.coders.protos[sized.GetComponentCoderIds()[0]]
+	rest := pl.coders.protos[pair.GetComponentCoderIds()[1]]
+	if got, want := rest.GetSpec().GetUrn(), urnLengthPrefixCoder; got != want {
+		t.Errorf("restriction coder = %v, want %v", got, want)
+	}
+}
+
+func TestWorkerEnvs(t *testing.T) {
+	docker := &pipepb.Environment{Urn: graphx.URNEnvDocker}
+	withHints := func(e *pipepb.Environment, ram string) *pipepb.Environment {
+		e = proto.Clone(e).(*pipepb.Environment)
+		e.ResourceHints = map[string][]byte{"beam:resources:min_ram_bytes:v1": []byte(ram)}
+		return e
+	}
+	envs := map[string]*pipepb.Environment{
+		"b":     docker,
+		"a":     withHints(docker, "1024"),
+		"c":     withHints(docker, "2048"),
+		"other": {Urn: graphx.URNEnvExternal},
+	}
+	want := map[string]string{"a": "a", "b": "a", "c": "a", "other": "other"}
+	if got := workerEnvs(envs); !reflect.DeepEqual(got, want) {
+		t.Errorf("workerEnvs() = %v, want %v", got, want)
+	}
+}
+
+func TestCheckRequirements(t *testing.T) {
+	tests := []struct {
+		reqs    []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{graphx.URNRequiresSplittableDoFn, graphx.URNRequiresBundleFinalization}, false},
+		{[]string{graphx.URNRequiresStatefulProcessing}, false},
+		{[]string{"beam:requirement:pardo:on_window_expiration:v1"}, true},
+	}
+	for _, test := range tests {
+		err := checkRequirements(&pipepb.Pipeline{Requirements: test.reqs})
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("checkRequirements(%v) = %v, want error %v", test.reqs, err, test.wantErr)
+		}
+	}
+}
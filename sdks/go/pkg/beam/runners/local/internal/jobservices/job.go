@@ -0,0 +1,177 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobservices
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	jobpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/jobmanagement_v1"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Job is a job submitted to the job service. It records the state changes,
+// messages and metrics of the job for its clients.
+type Job struct {
+	// ID is the unique ID of the job.
+	ID string
+	// Name is the job name given by the client.
+	Name string
+	// Pipeline is the pipeline of the job.
+	Pipeline *pipepb.Pipeline
+	// Options are the pipeline options of the job.
+	Options *structpb.Struct
+
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	state   jobpb.JobState_Enum
+	events  []*jobpb.JobMessagesResponse
+	changed chan struct{} // closed and replaced on every new event.
+	metrics metricSet
+}
+
+func newJob(id, name string, p *pipepb.Pipeline, opts *structpb.Struct) *Job {
+	return &Job{
+		ID:       id,
+		Name:     name,
+		Pipeline: p,
+		Options:  opts,
+		state:    jobpb.JobState_STOPPED,
+		changed:  make(chan struct{}),
+		metrics:  make(metricSet),
+	}
+}
+
+func (j *Job) String() string {
+	return fmt.Sprintf("job[%v]", j.ID)
+}
+
+// State returns the current state of the job.
+func (j *Job) State() jobpb.JobState_Enum {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+// SetState moves the job to the given state. Terminal states are final.
+func (j *Job) SetState(state jobpb.JobState_Enum) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if isTerminal(j.state) || j.state == state {
+		return
+	}
+	j.state = state
+	j.publish(&jobpb.JobMessagesResponse{
+		Response: &jobpb.JobMessagesResponse_StateResponse{
+			StateResponse: &jobpb.JobStateEvent{State: state, Timestamp: timestamppb.Now()},
+		},
+	})
+}
+
+// SendMessage records a message for the clients of the job.
+func (j *Job) SendMessage(importance jobpb.JobMessage_MessageImportance, text string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.publish(&jobpb.JobMessagesResponse{
+		Response: &jobpb.JobMessagesResponse_MessageResponse{
+			MessageResponse: &jobpb.JobMessage{
+				MessageId:   fmt.Sprintf("%v-%d", j.ID, len(j.events)),
+				Time:        time.Now().Format(time.RFC3339Nano),
+				Importance:  importance,
+				MessageText: text,
+			},
+		},
+	})
+}
+
+// Logf records a formatted message with basic importance.
+func (j *Job) Logf(format string, args ...interface{}) {
+	j.SendMessage(jobpb.JobMessage_JOB_MESSAGE_BASIC, fmt.Sprintf(format, args...))
+}
+
+// AddMetrics merges the monitoring infos of a completed bundle into the
+// metrics of the job.
+func (j *Job) AddMetrics(infos []*pipepb.MonitoringInfo) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, mi := range infos {
+		j.metrics.add(mi)
+	}
+}
+
+// Metrics returns the accumulated monitoring infos of the job.
+func (j *Job) Metrics() []*pipepb.MonitoringInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.metrics.list()
+}
+
+// Cancel requests cancellation of the running job.
+func (j *Job) Cancel() {
+	j.SetState(jobpb.JobState_CANCELLING)
+	if j.cancel != nil {
+		j.cancel()
+	}
+}
+
+// publish appends the event and wakes up the subscribers. Requires j.mu.
+func (j *Job) publish(event *jobpb.JobMessagesResponse) {
+	j.events = append(j.events, event)
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// subscribe calls send for every event of the job, in order, until the job
+// reaches a terminal state or the context is done.
+func (j *Job) subscribe(ctx context.Context, send func(*jobpb.JobMessagesResponse) error) error {
+	next := 0
+	for {
+		j.mu.Lock()
+		events := j.events[next:]
+		done := isTerminal(j.state)
+		changed := j.changed
+		j.mu.Unlock()
+
+		for _, event := range events {
+			if err := send(event); err != nil {
+				return err
+			}
+		}
+		next += len(events)
+		if done {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func isTerminal(state jobpb.JobState_Enum) bool {
+	switch state {
+	case jobpb.JobState_DONE, jobpb.JobState_FAILED, jobpb.JobState_CANCELLED, jobpb.JobState_DRAINED, jobpb.JobState_UPDATED:
+		return true
+	default:
+		return false
+	}
+}
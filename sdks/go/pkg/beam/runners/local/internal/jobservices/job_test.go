@@ -0,0 +1,170 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobservices
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
+	jobpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/jobmanagement_v1"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+)
+
+func TestJob_SetState(t *testing.T) {
+	tests := []struct {
+		name   string
+		states []jobpb.JobState_Enum
+		want   jobpb.JobState_Enum
+		events int
+	}{
+		{"initial", nil, jobpb.JobState_STOPPED, 0},
+		{"running", []jobpb.JobState_Enum{jobpb.JobState_RUNNING}, jobpb.JobState_RUNNING, 1},
+		{"unchanged", []jobpb.JobState_Enum{jobpb.JobState_RUNNING, jobpb.JobState_RUNNING}, jobpb.JobState_RUNNING, 1},
+		{"done", []jobpb.JobState_Enum{jobpb.JobState_RUNNING, jobpb.JobState_DONE}, jobpb.JobState_DONE, 2},
+		{"terminalIsFinal", []jobpb.JobState_Enum{jobpb.JobState_FAILED, jobpb.JobState_RUNNING, jobpb.JobState_DONE}, jobpb.JobState_FAILED, 1},
+		{"cancelling", []jobpb.JobState_Enum{jobpb.JobState_CANCELLING, jobpb.JobState_CANCELLED}, jobpb.JobState_CANCELLED, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			j := newJob("job1", "test", nil, nil)
+			for _, s := range test.states {
+				j.SetState(s)
+			}
+			if got := j.State(); got != test.want {
+				t.Errorf("State() = %v, want %v", got, test.want)
+			}
+			if got := len(j.events); got != test.events {
+				t.Errorf("got %d events, want %d", got, test.events)
+			}
+		})
+	}
+}
+
+func TestJob_Cancel(t *testing.T) {
+	j := newJob("job1", "test", nil, nil)
+	canceled := false
+	j.cancel = func() { canceled = true }
+	j.SetState(jobpb.JobState_RUNNING)
+	j.Cancel()
+	if got, want := j.State(), jobpb.JobState_CANCELLING; got != want {
+		t.Errorf("State() = %v, want %v", got, want)
+	}
+	if !canceled {
+		t.Error("Cancel() didn't cancel the job context")
+	}
+}
+
+func TestJob_subscribe(t *testing.T) {
+	j := newJob("job1", "test", nil, nil)
+	j.SetState(jobpb.JobState_RUNNING)
+	j.Logf("hello %v", "world")
+
+	events := make(chan *jobpb.JobMessagesResponse, 10)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- j.subscribe(context.Background(), func(e *jobpb.JobMessagesResponse) error {
+			events <- e
+			return nil
+		})
+	}()
+	// Events published while subscribed are sent as well.
+	<-events
+	<-events
+	j.SetState(jobpb.JobState_DONE)
+	if got, want := (<-events).GetStateResponse().GetState(), jobpb.JobState_DONE; got != want {
+		t.Errorf("last event state = %v, want %v", got, want)
+	}
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("subscribe() failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("subscribe() didn't return after the job was done")
+	}
+
+	// Subscribing to a finished job replays its events.
+	var got []string
+	err := j.subscribe(context.Background(), func(e *jobpb.JobMessagesResponse) error {
+		if s := e.GetStateResponse(); s != nil {
+			got = append(got, s.GetState().String())
+		} else {
+			got = append(got, e.GetMessageResponse().GetMessageText())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subscribe() failed: %v", err)
+	}
+	if want := []string{"RUNNING", "hello world", "DONE"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("subscribe() events = %v, want %v", got, want)
+	}
+}
+
+func TestJob_subscribe_canceled(t *testing.T) {
+	j := newJob("job1", "test", nil, nil)
+	j.SetState(jobpb.JobState_RUNNING)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := j.subscribe(ctx, func(*jobpb.JobMessagesResponse) error { return nil }); err != context.Canceled {
+		t.Errorf("subscribe() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func encodeInt64s(t *testing.T, vs ...int64) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, v := range vs {
+		if err := coder.EncodeVarInt(v, &buf); err != nil {
+			t.Fatalf("EncodeVarInt(%v) failed: %v", v, err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestJob_AddMetrics(t *testing.T) {
+	labels := map[string]string{"NAMESPACE": "ns", "NAME": "c"}
+	sum := func(v int64) *pipepb.MonitoringInfo {
+		return &pipepb.MonitoringInfo{Urn: "beam:metric:user:sum_int64:v1", Type: typeSumInt64, Labels: labels, Payload: encodeInt64s(t, v)}
+	}
+	dist := func(count, total, min, max int64) *pipepb.MonitoringInfo {
+		return &pipepb.MonitoringInfo{Urn: "beam:metric:user:distribution_int64:v1", Type: typeDistributionInt64, Labels: labels, Payload: encodeInt64s(t, count, total, min, max)}
+	}
+	gauge := func(v int64) *pipepb.MonitoringInfo {
+		return &pipepb.MonitoringInfo{Urn: "beam:metric:user:latest_int64:v1", Type: "beam:metrics:latest_int64:v1", Labels: labels, Payload: encodeInt64s(t, v)}
+	}
+
+	j := newJob("job1", "test", nil, nil)
+	j.AddMetrics([]*pipepb.MonitoringInfo{sum(2), dist(1, 5, 5, 5), gauge(7)})
+	j.AddMetrics([]*pipepb.MonitoringInfo{sum(3), dist(2, 4, 1, 3), gauge(4)})
+
+	got := make(map[string][]byte)
+	for _, mi := range j.Metrics() {
+		got[mi.GetType()] = mi.GetPayload()
+	}
+	want := map[string][]byte{
+		typeSumInt64:                   encodeInt64s(t, 5),
+		typeDistributionInt64:          encodeInt64s(t, 3, 9, 1, 5),
+		"beam:metrics:latest_int64:v1": encodeInt64s(t, 4),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Metrics() payloads = %v, want %v", got, want)
+	}
+}
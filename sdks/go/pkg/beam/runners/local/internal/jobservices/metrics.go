@@ -0,0 +1,129 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobservices
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+)
+
+const (
+	typeSumInt64          = "beam:metrics:sum_int64:v1"
+	typeDistributionInt64 = "beam:metrics:distribution_int64:v1"
+)
+
+// metricSet accumulates monitoring infos across bundles, keyed by URN and
+// labels.
+type metricSet map[string]*pipepb.MonitoringInfo
+
+func (s metricSet) add(mi *pipepb.MonitoringInfo) {
+	key := metricKey(mi)
+	prev, ok := s[key]
+	if !ok {
+		s[key] = mi
+		return
+	}
+	switch mi.GetType() {
+	case typeSumInt64, typeDistributionInt64:
+		if merged, err := mergeInt64s(mi.GetType(), prev.GetPayload(), mi.GetPayload()); err == nil {
+			s[key] = &pipepb.MonitoringInfo{Urn: mi.GetUrn(), Type: mi.GetType(), Labels: mi.GetLabels(), Payload: merged}
+			return
+		}
+	}
+	// Gauges and other values keep the latest report.
+	s[key] = mi
+}
+
+func (s metricSet) list() []*pipepb.MonitoringInfo {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ret := make([]*pipepb.MonitoringInfo, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, s[k])
+	}
+	return ret
+}
+
+func metricKey(mi *pipepb.MonitoringInfo) string {
+	var labels []string
+	for k, v := range mi.GetLabels() {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+	return mi.GetUrn() + "|" + strings.Join(labels, ",")
+}
+
+// mergeInt64s merges two encoded sums, or two encoded distributions of
+// count, sum, min and max.
+func mergeInt64s(typ string, a, b []byte) ([]byte, error) {
+	n := 1
+	if typ == typeDistributionInt64 {
+		n = 4
+	}
+	av, err := decodeVarInts(a, n)
+	if err != nil {
+		return nil, err
+	}
+	bv, err := decodeVarInts(b, n)
+	if err != nil {
+		return nil, err
+	}
+	merged := []int64{av[0] + bv[0]}
+	if n == 4 {
+		merged = append(merged, av[1]+bv[1], min64(av[2], bv[2]), max64(av[3], bv[3]))
+	}
+	var buf bytes.Buffer
+	for _, v := range merged {
+		if err := coder.EncodeVarInt(v, &buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeVarInts(data []byte, n int) ([]int64, error) {
+	r := bytes.NewReader(data)
+	ret := make([]int64, n)
+	for i := range ret {
+		v, err := coder.DecodeVarInt(r)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = v
+	}
+	return ret, nil
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
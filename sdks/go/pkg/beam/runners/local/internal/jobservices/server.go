@@ -0,0 +1,261 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jobservices implements the Beam job management and artifact
+// staging services for the local runner.
+package jobservices
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	jobpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/jobmanagement_v1"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ExecuteFn runs the pipeline of the job to completion.
+type ExecuteFn func(ctx context.Context, j *Job) error
+
+// Server serves the job management and artifact staging services, and runs
+// submitted jobs with its ExecuteFn.
+type Server struct {
+	jobpb.UnimplementedJobServiceServer
+	jobpb.UnimplementedArtifactStagingServiceServer
+
+	lis     net.Listener
+	server  *grpc.Server
+	execute ExecuteFn
+
+	mu       sync.Mutex
+	index    int
+	prepared map[string]*Job // by preparation ID.
+	jobs     map[string]*Job // by job ID.
+}
+
+// NewServer returns a server listening on the given local port, or on a
+// free port if zero.
+func NewServer(port int, execute ExecuteFn) (*Server, error) {
+	lis, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return nil, errors.Wrap(err, "listening for job service")
+	}
+	s := &Server{
+		lis:      lis,
+		server:   grpc.NewServer(),
+		execute:  execute,
+		prepared: make(map[string]*Job),
+		jobs:     make(map[string]*Job),
+	}
+	jobpb.RegisterJobServiceServer(s.server, s)
+	jobpb.RegisterArtifactStagingServiceServer(s.server, s)
+	return s, nil
+}
+
+// Endpoint returns the address of the job service.
+func (s *Server) Endpoint() string {
+	return s.lis.Addr().String()
+}
+
+// Serve serves the job service until it is stopped.
+func (s *Server) Serve() error {
+	return s.server.Serve(s.lis)
+}
+
+// Stop stops the job service, cancelling all running jobs.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		j.Cancel()
+	}
+	s.mu.Unlock()
+	s.server.Stop()
+}
+
+// Prepare records the job, and returns the server itself as its artifact
+// staging endpoint.
+func (s *Server) Prepare(ctx context.Context, req *jobpb.PrepareJobRequest) (*jobpb.PrepareJobResponse, error) {
+	if req.GetPipeline() == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing pipeline")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index++
+	id := fmt.Sprintf("job-%03d", s.index)
+	s.prepared[id] = newJob(id, req.GetJobName(), req.GetPipeline(), req.GetPipelineOptions())
+	return &jobpb.PrepareJobResponse{
+		PreparationId:           id,
+		ArtifactStagingEndpoint: &pipepb.ApiServiceDescriptor{Url: s.Endpoint()},
+		StagingSessionToken:     id,
+	}, nil
+}
+
+// ReverseArtifactRetrievalService accepts the staging session of a prepared
+// job. Artifacts aren't retrieved, since workers run in the client process.
+func (s *Server) ReverseArtifactRetrievalService(stream jobpb.ArtifactStagingService_ReverseArtifactRetrievalServiceServer) error {
+	in, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	_, ok := s.prepared[in.GetStagingToken()]
+	s.mu.Unlock()
+	if !ok {
+		return status.Errorf(codes.NotFound, "unknown staging token %q", in.GetStagingToken())
+	}
+	return nil
+}
+
+// Run starts executing the prepared job.
+func (s *Server) Run(ctx context.Context, req *jobpb.RunJobRequest) (*jobpb.RunJobResponse, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	j, ok := s.prepared[req.GetPreparationId()]
+	if ok {
+		delete(s.prepared, req.GetPreparationId())
+		j.cancel = cancel
+		s.jobs[j.ID] = j
+	}
+	s.mu.Unlock()
+	if !ok {
+		cancel()
+		return nil, status.Errorf(codes.NotFound, "unknown preparation %q", req.GetPreparationId())
+	}
+
+	j.SetState(jobpb.JobState_STARTING)
+	go func() {
+		defer cancel()
+		j.SetState(jobpb.JobState_RUNNING)
+		err := s.execute(ctx, j)
+		switch {
+		case ctx.Err() != nil:
+			j.SetState(jobpb.JobState_CANCELLED)
+		case err != nil:
+			log.Errorf(ctx, "%v failed: %v", j, err)
+			j.SendMessage(jobpb.JobMessage_JOB_MESSAGE_ERROR, err.Error())
+			j.SetState(jobpb.JobState_FAILED)
+		default:
+			j.SetState(jobpb.JobState_DONE)
+		}
+	}()
+	return &jobpb.RunJobResponse{JobId: j.ID}, nil
+}
+
+func (s *Server) job(id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown job %q", id)
+	}
+	return j, nil
+}
+
+// GetJobs returns all submitted jobs, ordered by ID.
+func (s *Server) GetJobs(ctx context.Context, req *jobpb.GetJobsRequest) (*jobpb.GetJobsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &jobpb.GetJobsResponse{}
+	for _, j := range s.jobs {
+		resp.JobInfo = append(resp.JobInfo, &jobpb.JobInfo{
+			JobId:           j.ID,
+			JobName:         j.Name,
+			PipelineOptions: j.Options,
+			State:           j.State(),
+		})
+	}
+	sort.Slice(resp.JobInfo, func(i, k int) bool {
+		return resp.JobInfo[i].GetJobId() < resp.JobInfo[k].GetJobId()
+	})
+	return resp, nil
+}
+
+// GetState returns the current state of the job.
+func (s *Server) GetState(ctx context.Context, req *jobpb.GetJobStateRequest) (*jobpb.JobStateEvent, error) {
+	j, err := s.job(req.GetJobId())
+	if err != nil {
+		return nil, err
+	}
+	return &jobpb.JobStateEvent{State: j.State()}, nil
+}
+
+// GetPipeline returns the pipeline of the job.
+func (s *Server) GetPipeline(ctx context.Context, req *jobpb.GetJobPipelineRequest) (*jobpb.GetJobPipelineResponse, error) {
+	j, err := s.job(req.GetJobId())
+	if err != nil {
+		return nil, err
+	}
+	return &jobpb.GetJobPipelineResponse{Pipeline: j.Pipeline}, nil
+}
+
+// Cancel cancels the job.
+func (s *Server) Cancel(ctx context.Context, req *jobpb.CancelJobRequest) (*jobpb.CancelJobResponse, error) {
+	j, err := s.job(req.GetJobId())
+	if err != nil {
+		return nil, err
+	}
+	j.Cancel()
+	return &jobpb.CancelJobResponse{State: j.State()}, nil
+}
+
+// GetStateStream streams the state changes of the job, until it terminates.
+func (s *Server) GetStateStream(req *jobpb.GetJobStateRequest, stream jobpb.JobService_GetStateStreamServer) error {
+	j, err := s.job(req.GetJobId())
+	if err != nil {
+		return err
+	}
+	return j.subscribe(stream.Context(), func(event *jobpb.JobMessagesResponse) error {
+		if event.GetStateResponse() == nil {
+			return nil
+		}
+		return stream.Send(event.GetStateResponse())
+	})
+}
+
+// GetMessageStream streams the state changes and messages of the job, until
+// it terminates.
+func (s *Server) GetMessageStream(req *jobpb.JobMessagesRequest, stream jobpb.JobService_GetMessageStreamServer) error {
+	j, err := s.job(req.GetJobId())
+	if err != nil {
+		return err
+	}
+	return j.subscribe(stream.Context(), stream.Send)
+}
+
+// GetJobMetrics returns the metrics of the job. All metrics are committed,
+// since bundles are never retried.
+func (s *Server) GetJobMetrics(ctx context.Context, req *jobpb.GetJobMetricsRequest) (*jobpb.GetJobMetricsResponse, error) {
+	j, err := s.job(req.GetJobId())
+	if err != nil {
+		return nil, err
+	}
+	infos := j.Metrics()
+	return &jobpb.GetJobMetricsResponse{
+		Metrics: &jobpb.MetricResults{Attempted: infos, Committed: infos},
+	}, nil
+}
+
+// DescribePipelineOptions returns no options, since the runner has none of
+// its own.
+func (s *Server) DescribePipelineOptions(ctx context.Context, req *jobpb.DescribePipelineOptionsRequest) (*jobpb.DescribePipelineOptionsResponse, error) {
+	return &jobpb.DescribePipelineOptionsResponse{}, nil
+}
@@ -0,0 +1,199 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"fmt"
+	"sync"
+
+	fnpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/fnexecution_v1"
+)
+
+// SideInputKey identifies a side input of a transform in a bundle descriptor.
+type SideInputKey struct {
+	TransformID, SideInputID string
+}
+
+// SideInput is the materialized data of a side input, keyed by encoded window.
+// Values are encoded with the side input's element coder.
+type SideInput struct {
+	// Iterable holds the encoded elements of each window.
+	Iterable map[string][][]byte
+	// Multimap holds the encoded values of each encoded key of each window,
+	// for side inputs of KVs.
+	Multimap map[string]map[string][][]byte
+}
+
+// userStateKey identifies a bag of user state.
+type userStateKey struct {
+	transformID, stateID, window, key string
+}
+
+// userState holds the bag user state of a job. Unlike side inputs, it
+// outlives the bundles that write it.
+type userState struct {
+	mu   sync.Mutex
+	bags map[userStateKey][]byte
+}
+
+func (us *userState) get(k userStateKey) []byte {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	return us.bags[k]
+}
+
+func (us *userState) append(k userStateKey, data []byte) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if us.bags == nil {
+		us.bags = make(map[userStateKey][]byte)
+	}
+	// Copy, so that later appends never share the backing array of a
+	// previous Get response.
+	bag := make([]byte, 0, len(us.bags[k])+len(data))
+	us.bags[k] = append(append(bag, us.bags[k]...), data...)
+}
+
+func (us *userState) clear(k userStateKey) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	delete(us.bags, k)
+}
+
+// B is a bundle to be processed by the SDK harness.
+type B struct {
+	// InstID is the instruction ID of the bundle.
+	InstID string
+	// PBDID is the ID of the registered bundle descriptor.
+	PBDID string
+
+	// InputTransformID is the ID of the DataSource transform of the bundle.
+	InputTransformID string
+	// Input holds the encoded windowed values sent to the DataSource.
+	Input [][]byte
+	// SinkIDs are the IDs of the DataSink transforms of the bundle.
+	SinkIDs []string
+	// SideInputs holds the side input data available to the bundle.
+	SideInputs map[SideInputKey]*SideInput
+
+	userState *userState
+
+	mu      sync.Mutex
+	outputs map[string][]byte // by DataSink transform ID.
+	pending map[string]bool
+	done    chan struct{}
+}
+
+func (b *B) init(us *userState) {
+	b.userState = us
+	b.outputs = make(map[string][]byte)
+	b.pending = make(map[string]bool)
+	for _, id := range b.SinkIDs {
+		b.pending[id] = true
+	}
+	b.done = make(chan struct{})
+	if len(b.pending) == 0 {
+		close(b.done)
+	}
+}
+
+// Output returns the raw encoded windowed values sent to the DataSink.
+func (b *B) Output(sinkID string) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.outputs[sinkID]
+}
+
+func (b *B) output(transformID string, data []byte, last bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.pending[transformID] {
+		return
+	}
+	b.outputs[transformID] = append(b.outputs[transformID], data...)
+	if last {
+		delete(b.pending, transformID)
+		if len(b.pending) == 0 {
+			close(b.done)
+		}
+	}
+}
+
+// state serves the state request. Side inputs are returned whole, without
+// continuation tokens. Bag user state can also be appended to and cleared.
+func (b *B) state(req *fnpb.StateRequest) *fnpb.StateResponse {
+	if k := req.GetStateKey().GetBagUserState(); k != nil {
+		return b.bagUserState(req, userStateKey{k.GetTransformId(), k.GetUserStateId(), string(k.GetWindow()), string(k.GetKey())})
+	}
+	if req.GetGet() == nil {
+		return &fnpb.StateResponse{Error: fmt.Sprintf("unsupported state request %T", req.GetRequest())}
+	}
+	var values [][]byte
+	switch key := req.GetStateKey().GetType().(type) {
+	case *fnpb.StateKey_IterableSideInput_:
+		k := key.IterableSideInput
+		si, ok := b.SideInputs[SideInputKey{k.GetTransformId(), k.GetSideInputId()}]
+		if !ok {
+			return &fnpb.StateResponse{Error: fmt.Sprintf("unknown side input %v of %v", k.GetSideInputId(), k.GetTransformId())}
+		}
+		values = si.Iterable[string(k.GetWindow())]
+	case *fnpb.StateKey_MultimapSideInput_:
+		k := key.MultimapSideInput
+		si, ok := b.SideInputs[SideInputKey{k.GetTransformId(), k.GetSideInputId()}]
+		if !ok {
+			return &fnpb.StateResponse{Error: fmt.Sprintf("unknown side input %v of %v", k.GetSideInputId(), k.GetTransformId())}
+		}
+		values = si.Multimap[string(k.GetWindow())][string(k.GetKey())]
+	default:
+		return &fnpb.StateResponse{Error: fmt.Sprintf("unsupported state key %T", key)}
+	}
+	var data []byte
+	for _, v := range values {
+		data = append(data, v...)
+	}
+	return getResponse(data)
+}
+
+// bagUserState serves a request for the bag of user state with the given key.
+func (b *B) bagUserState(req *fnpb.StateRequest, k userStateKey) *fnpb.StateResponse {
+	if b.userState == nil {
+		return &fnpb.StateResponse{Error: fmt.Sprintf("no user state for bundle %v", b.InstID)}
+	}
+	switch r := req.GetRequest().(type) {
+	case *fnpb.StateRequest_Get:
+		return getResponse(b.userState.get(k))
+	case *fnpb.StateRequest_Append:
+		b.userState.append(k, r.Append.GetData())
+		return &fnpb.StateResponse{
+			Response: &fnpb.StateResponse_Append{Append: &fnpb.StateAppendResponse{}},
+		}
+	case *fnpb.StateRequest_Clear:
+		b.userState.clear(k)
+		return &fnpb.StateResponse{
+			Response: &fnpb.StateResponse_Clear{Clear: &fnpb.StateClearResponse{}},
+		}
+	default:
+		return &fnpb.StateResponse{Error: fmt.Sprintf("unsupported state request %T", r)}
+	}
+}
+
+func getResponse(data []byte) *fnpb.StateResponse {
+	return &fnpb.StateResponse{
+		Response: &fnpb.StateResponse_Get{
+			Get: &fnpb.StateGetResponse{Data: data},
+		},
+	}
+}
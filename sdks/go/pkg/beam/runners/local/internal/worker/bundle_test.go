@@ -0,0 +1,261 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"context"
+	"testing"
+
+	fnpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/fnexecution_v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestB_output(t *testing.T) {
+	b := &B{InstID: "inst1", SinkIDs: []string{"sink1", "sink2"}}
+	b.init(nil)
+
+	b.output("sink1", []byte("a"), false)
+	b.output("sink1", []byte("b"), true)
+	b.output("sink1", []byte("c"), false) // After the last chunk: dropped.
+	b.output("unknown", []byte("d"), true)
+	select {
+	case <-b.done:
+		t.Fatal("bundle done before all sinks finished")
+	default:
+	}
+	b.output("sink2", nil, true)
+	select {
+	case <-b.done:
+	default:
+		t.Fatal("bundle not done after all sinks finished")
+	}
+	if got, want := string(b.Output("sink1")), "ab"; got != want {
+		t.Errorf("Output(sink1) = %q, want %q", got, want)
+	}
+	if got := b.Output("unknown"); got != nil {
+		t.Errorf("Output(unknown) = %q, want nil", got)
+	}
+}
+
+func TestB_output_noSinks(t *testing.T) {
+	b := &B{InstID: "inst1"}
+	b.init(nil)
+	select {
+	case <-b.done:
+	default:
+		t.Error("bundle without sinks not done")
+	}
+}
+
+func getRequest(key *fnpb.StateKey) *fnpb.StateRequest {
+	return &fnpb.StateRequest{
+		StateKey: key,
+		Request:  &fnpb.StateRequest_Get{Get: &fnpb.StateGetRequest{}},
+	}
+}
+
+func appendRequest(key *fnpb.StateKey, data string) *fnpb.StateRequest {
+	return &fnpb.StateRequest{
+		StateKey: key,
+		Request:  &fnpb.StateRequest_Append{Append: &fnpb.StateAppendRequest{Data: []byte(data)}},
+	}
+}
+
+func clearRequest(key *fnpb.StateKey) *fnpb.StateRequest {
+	return &fnpb.StateRequest{
+		StateKey: key,
+		Request:  &fnpb.StateRequest_Clear{Clear: &fnpb.StateClearRequest{}},
+	}
+}
+
+func bagKey(transformID, stateID, window, key string) *fnpb.StateKey {
+	return &fnpb.StateKey{
+		Type: &fnpb.StateKey_BagUserState_{
+			BagUserState: &fnpb.StateKey_BagUserState{
+				TransformId: transformID,
+				UserStateId: stateID,
+				Window:      []byte(window),
+				Key:         []byte(key),
+			},
+		},
+	}
+}
+
+func TestB_state_sideInputs(t *testing.T) {
+	b := &B{
+		InstID: "inst1",
+		SideInputs: map[SideInputKey]*SideInput{
+			{"t1", "i0"}: {Iterable: map[string][][]byte{"w": {[]byte("a"), []byte("b")}}},
+			{"t1", "i1"}: {Multimap: map[string]map[string][][]byte{"w": {"k": {[]byte("c"), []byte("d")}}}},
+		},
+	}
+	b.init(nil)
+
+	iterable := func(sideInputID, window string) *fnpb.StateKey {
+		return &fnpb.StateKey{
+			Type: &fnpb.StateKey_IterableSideInput_{
+				IterableSideInput: &fnpb.StateKey_IterableSideInput{
+					TransformId: "t1",
+					SideInputId: sideInputID,
+					Window:      []byte(window),
+				},
+			},
+		}
+	}
+	multimap := func(sideInputID, window, key string) *fnpb.StateKey {
+		return &fnpb.StateKey{
+			Type: &fnpb.StateKey_MultimapSideInput_{
+				MultimapSideInput: &fnpb.StateKey_MultimapSideInput{
+					TransformId: "t1",
+					SideInputId: sideInputID,
+					Window:      []byte(window),
+					Key:         []byte(key),
+				},
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		req     *fnpb.StateRequest
+		want    string
+		wantErr bool
+	}{
+		{"iterable", getRequest(iterable("i0", "w")), "ab", false},
+		{"iterable_otherWindow", getRequest(iterable("i0", "v")), "", false},
+		{"multimap", getRequest(multimap("i1", "w", "k")), "cd", false},
+		{"multimap_otherKey", getRequest(multimap("i1", "w", "j")), "", false},
+		{"unknown", getRequest(iterable("i2", "w")), "", true},
+		{"append", appendRequest(iterable("i0", "w"), "x"), "", true},
+		{"runner", getRequest(&fnpb.StateKey{Type: &fnpb.StateKey_Runner_{Runner: &fnpb.StateKey_Runner{}}}), "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := b.state(test.req)
+			if gotErr := resp.GetError() != ""; gotErr != test.wantErr {
+				t.Fatalf("state() error = %q, want error %v", resp.GetError(), test.wantErr)
+			}
+			if got := string(resp.GetGet().GetData()); got != test.want {
+				t.Errorf("state() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestB_state_bagUserState(t *testing.T) {
+	us := &userState{}
+	b := &B{InstID: "inst1"}
+	b.init(us)
+
+	k := bagKey("t1", "s1", "w", "k")
+	steps := []struct {
+		req  *fnpb.StateRequest
+		want string // for gets.
+	}{
+		{getRequest(k), ""},
+		{appendRequest(k, "a"), ""},
+		{appendRequest(k, "b"), ""},
+		{getRequest(k), "ab"},
+		// Each of the transform, state ID, window and key scope the bag.
+		{appendRequest(bagKey("t2", "s1", "w", "k"), "c"), ""},
+		{appendRequest(bagKey("t1", "s2", "w", "k"), "d"), ""},
+		{appendRequest(bagKey("t1", "s1", "v", "k"), "e"), ""},
+		{appendRequest(bagKey("t1", "s1", "w", "j"), "f"), ""},
+		{getRequest(k), "ab"},
+		{getRequest(bagKey("t1", "s1", "w", "j")), "f"},
+		{clearRequest(k), ""},
+		{getRequest(k), ""},
+		{getRequest(bagKey("t2", "s1", "w", "k")), "c"},
+		{appendRequest(k, "g"), ""},
+	}
+	for i, step := range steps {
+		resp := b.state(step.req)
+		if resp.GetError() != "" {
+			t.Fatalf("step %d: state(%v) failed: %v", i, step.req, resp.GetError())
+		}
+		if step.req.GetGet() != nil {
+			if got := string(resp.GetGet().GetData()); got != step.want {
+				t.Errorf("step %d: state(%v) = %q, want %q", i, step.req, got, step.want)
+			}
+		}
+	}
+
+	// State outlives the bundle.
+	next := &B{InstID: "inst2"}
+	next.init(us)
+	if got, want := string(next.state(getRequest(k)).GetGet().GetData()), "g"; got != want {
+		t.Errorf("state() in next bundle = %q, want %q", got, want)
+	}
+
+	noState := &B{InstID: "inst3"}
+	noState.init(nil)
+	if resp := noState.state(getRequest(k)); resp.GetError() == "" {
+		t.Errorf("state() without user state = %v, want error", resp)
+	}
+}
+
+func TestW_State(t *testing.T) {
+	wk, err := New("test")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer wk.Stop()
+	conn, err := grpc.Dial(wk.Endpoint(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	stream, err := fnpb.NewBeamFnStateClient(conn).State(context.Background())
+	if err != nil {
+		t.Fatalf("State failed: %v", err)
+	}
+	call := func(instID string, req *fnpb.StateRequest) *fnpb.StateResponse {
+		t.Helper()
+		req.Id, req.InstructionId = "req-"+instID, instID
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		if resp.GetId() != req.GetId() {
+			t.Errorf("response ID = %v, want %v", resp.GetId(), req.GetId())
+		}
+		return resp
+	}
+	// Bundles are registered by Process, so register them directly here.
+	bundle := func(instID string) {
+		b := &B{InstID: instID}
+		b.init(&wk.userState)
+		wk.mu.Lock()
+		wk.bundles[instID] = b
+		wk.mu.Unlock()
+	}
+
+	k := bagKey("t1", "s1", "w", "k")
+	bundle("inst1")
+	if resp := call("inst1", appendRequest(k, "a")); resp.GetError() != "" {
+		t.Fatalf("append failed: %v", resp.GetError())
+	}
+	bundle("inst2")
+	if got, want := string(call("inst2", getRequest(k)).GetGet().GetData()), "a"; got != want {
+		t.Errorf("get in later bundle = %q, want %q", got, want)
+	}
+	if resp := call("unknown", getRequest(k)); resp.GetError() == "" {
+		t.Errorf("get for unknown instruction = %v, want error", resp)
+	}
+}
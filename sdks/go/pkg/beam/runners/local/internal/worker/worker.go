@@ -0,0 +1,388 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package worker serves the Fn API control, data, state and logging services
+// for a single SDK harness, and executes bundles on it.
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	fnpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/fnexecution_v1"
+	"google.golang.org/grpc"
+)
+
+// chunkSize is the maximum number of bytes of elements sent in a single data
+// message.
+const chunkSize = 1 << 20
+
+// W is a worker that serves the Fn API for one SDK harness.
+type W struct {
+	fnpb.UnimplementedBeamFnControlServer
+	fnpb.UnimplementedBeamFnDataServer
+	fnpb.UnimplementedBeamFnStateServer
+	fnpb.UnimplementedBeamFnLoggingServer
+
+	// ID is the worker ID of the harness.
+	ID string
+
+	// Logger receives the log entries of the harness, if set.
+	Logger func(*fnpb.LogEntry)
+
+	lis    net.Listener
+	server *grpc.Server
+
+	instReqs chan *fnpb.InstructionRequest
+	dataReqs chan *fnpb.Elements
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	mu          sync.Mutex
+	nextInst    int
+	descriptors map[string]*fnpb.ProcessBundleDescriptor
+	bundles     map[string]*B                             // by instruction ID.
+	responses   map[string]chan *fnpb.InstructionResponse // by instruction ID.
+
+	userState userState
+}
+
+// New starts serving the Fn API for the worker with the given ID on a local
+// port.
+func New(id string) (*W, error) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, errors.Wrap(err, "listening for Fn API")
+	}
+	wk := &W{
+		ID:          id,
+		lis:         lis,
+		server:      grpc.NewServer(),
+		instReqs:    make(chan *fnpb.InstructionRequest, 10),
+		dataReqs:    make(chan *fnpb.Elements, 10),
+		stopped:     make(chan struct{}),
+		descriptors: make(map[string]*fnpb.ProcessBundleDescriptor),
+		bundles:     make(map[string]*B),
+		responses:   make(map[string]chan *fnpb.InstructionResponse),
+	}
+	fnpb.RegisterBeamFnControlServer(wk.server, wk)
+	fnpb.RegisterBeamFnDataServer(wk.server, wk)
+	fnpb.RegisterBeamFnStateServer(wk.server, wk)
+	fnpb.RegisterBeamFnLoggingServer(wk.server, wk)
+	go wk.server.Serve(lis)
+	return wk, nil
+}
+
+// Endpoint returns the address of the Fn API services.
+func (wk *W) Endpoint() string {
+	return wk.lis.Addr().String()
+}
+
+// Stop stops serving the Fn API, which disconnects the harness.
+func (wk *W) Stop() {
+	wk.stopOnce.Do(func() {
+		close(wk.stopped)
+		wk.server.Stop()
+	})
+}
+
+func (wk *W) String() string {
+	return fmt.Sprintf("worker[%v]", wk.ID)
+}
+
+// NextInstruction returns a new unique instruction ID.
+func (wk *W) NextInstruction() string {
+	wk.mu.Lock()
+	defer wk.mu.Unlock()
+	wk.nextInst++
+	return fmt.Sprintf("inst%03d", wk.nextInst)
+}
+
+// Register registers the bundle descriptor with the harness.
+func (wk *W) Register(ctx context.Context, desc *fnpb.ProcessBundleDescriptor) error {
+	wk.mu.Lock()
+	wk.descriptors[desc.GetId()] = desc
+	wk.mu.Unlock()
+
+	_, err := wk.instruct(ctx, &fnpb.InstructionRequest{
+		InstructionId: wk.NextInstruction(),
+		Request: &fnpb.InstructionRequest_Register{
+			Register: &fnpb.RegisterRequest{
+				ProcessBundleDescriptor: []*fnpb.ProcessBundleDescriptor{desc},
+			},
+		},
+	})
+	return err
+}
+
+// Process executes the bundle on the harness, and returns its response once
+// the harness has sent all outputs.
+func (wk *W) Process(ctx context.Context, b *B) (*fnpb.ProcessBundleResponse, error) {
+	b.init(&wk.userState)
+	wk.mu.Lock()
+	wk.bundles[b.InstID] = b
+	wk.mu.Unlock()
+	defer func() {
+		wk.mu.Lock()
+		delete(wk.bundles, b.InstID)
+		wk.mu.Unlock()
+	}()
+
+	respc, err := wk.send(ctx, &fnpb.InstructionRequest{
+		InstructionId: b.InstID,
+		Request: &fnpb.InstructionRequest_ProcessBundle{
+			ProcessBundle: &fnpb.ProcessBundleRequest{
+				ProcessBundleDescriptorId: b.PBDID,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := wk.sendInput(ctx, b); err != nil {
+		return nil, err
+	}
+
+	var resp *fnpb.InstructionResponse
+	select {
+	case resp = <-respc:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-wk.stopped:
+		return nil, errors.Errorf("%v stopped", wk)
+	}
+	if resp.GetError() != "" {
+		return nil, errors.Errorf("bundle %v failed: %v", b.InstID, resp.GetError())
+	}
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-wk.stopped:
+		return nil, errors.Errorf("%v stopped", wk)
+	}
+	pb := resp.GetProcessBundle()
+	if pb.GetRequiresFinalization() {
+		if _, err := wk.instruct(ctx, &fnpb.InstructionRequest{
+			InstructionId: wk.NextInstruction(),
+			Request: &fnpb.InstructionRequest_FinalizeBundle{
+				FinalizeBundle: &fnpb.FinalizeBundleRequest{InstructionId: b.InstID},
+			},
+		}); err != nil {
+			return nil, errors.WithContextf(err, "finalizing bundle %v", b.InstID)
+		}
+	}
+	return pb, nil
+}
+
+// sendInput sends the input elements of the bundle over the data channel.
+func (wk *W) sendInput(ctx context.Context, b *B) error {
+	var buf []byte
+	flush := func(last bool) error {
+		elms := &fnpb.Elements{
+			Data: []*fnpb.Elements_Data{{
+				InstructionId: b.InstID,
+				TransformId:   b.InputTransformID,
+				Data:          buf,
+				IsLast:        last,
+			}},
+		}
+		buf = nil
+		select {
+		case wk.dataReqs <- elms:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wk.stopped:
+			return errors.Errorf("%v stopped", wk)
+		}
+	}
+	for _, elm := range b.Input {
+		buf = append(buf, elm...)
+		if len(buf) >= chunkSize {
+			if err := flush(false); err != nil {
+				return err
+			}
+		}
+	}
+	return flush(true)
+}
+
+// instruct sends the request to the harness and waits for its response.
+func (wk *W) instruct(ctx context.Context, req *fnpb.InstructionRequest) (*fnpb.InstructionResponse, error) {
+	respc, err := wk.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-respc:
+		if resp.GetError() != "" {
+			return nil, errors.Errorf("instruction %v failed: %v", req.GetInstructionId(), resp.GetError())
+		}
+		return resp, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-wk.stopped:
+		return nil, errors.Errorf("%v stopped", wk)
+	}
+}
+
+// send sends the request to the harness, and returns the channel that
+// receives its response.
+func (wk *W) send(ctx context.Context, req *fnpb.InstructionRequest) (<-chan *fnpb.InstructionResponse, error) {
+	respc := make(chan *fnpb.InstructionResponse, 1)
+	wk.mu.Lock()
+	wk.responses[req.GetInstructionId()] = respc
+	wk.mu.Unlock()
+	select {
+	case wk.instReqs <- req:
+		return respc, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-wk.stopped:
+		return nil, errors.Errorf("%v stopped", wk)
+	}
+}
+
+// Control implements the control service. It sends instructions to the
+// harness and routes the responses to their senders.
+func (wk *W) Control(stream fnpb.BeamFnControl_ControlServer) error {
+	go func() {
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			wk.mu.Lock()
+			respc, ok := wk.responses[resp.GetInstructionId()]
+			delete(wk.responses, resp.GetInstructionId())
+			wk.mu.Unlock()
+			if !ok {
+				log.Warnf(stream.Context(), "%v: response for unknown instruction %v", wk, resp.GetInstructionId())
+				continue
+			}
+			respc <- resp
+		}
+	}()
+	for {
+		select {
+		case req := <-wk.instReqs:
+			if err := stream.Send(req); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		case <-wk.stopped:
+			return nil
+		}
+	}
+}
+
+// GetProcessBundleDescriptor returns a registered bundle descriptor.
+func (wk *W) GetProcessBundleDescriptor(ctx context.Context, req *fnpb.GetProcessBundleDescriptorRequest) (*fnpb.ProcessBundleDescriptor, error) {
+	wk.mu.Lock()
+	defer wk.mu.Unlock()
+	desc, ok := wk.descriptors[req.GetProcessBundleDescriptorId()]
+	if !ok {
+		return nil, errors.Errorf("unknown bundle descriptor %v", req.GetProcessBundleDescriptorId())
+	}
+	return desc, nil
+}
+
+// Data implements the data service. It sends bundle inputs to the harness
+// and collects the bundle outputs.
+func (wk *W) Data(stream fnpb.BeamFnData_DataServer) error {
+	go func() {
+		for {
+			elms, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			for _, d := range elms.GetData() {
+				wk.mu.Lock()
+				b, ok := wk.bundles[d.GetInstructionId()]
+				wk.mu.Unlock()
+				if !ok {
+					log.Warnf(stream.Context(), "%v: data for unknown instruction %v", wk, d.GetInstructionId())
+					continue
+				}
+				b.output(d.GetTransformId(), d.GetData(), d.GetIsLast())
+			}
+		}
+	}()
+	for {
+		select {
+		case elms := <-wk.dataReqs:
+			if err := stream.Send(elms); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		case <-wk.stopped:
+			return nil
+		}
+	}
+}
+
+// State implements the state service, serving side inputs and bag user
+// state.
+func (wk *W) State(stream fnpb.BeamFnState_StateServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		wk.mu.Lock()
+		b, ok := wk.bundles[req.GetInstructionId()]
+		wk.mu.Unlock()
+		var resp *fnpb.StateResponse
+		if !ok {
+			resp = &fnpb.StateResponse{Error: fmt.Sprintf("unknown instruction %v", req.GetInstructionId())}
+		} else {
+			resp = b.state(req)
+		}
+		resp.Id = req.GetId()
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// Logging implements the logging service, passing entries to the Logger.
+func (wk *W) Logging(stream fnpb.BeamFnLogging_LoggingServer) error {
+	for {
+		list, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if wk.Logger == nil {
+			continue
+		}
+		for _, entry := range list.GetLogEntries() {
+			wk.Logger(entry)
+		}
+	}
+}
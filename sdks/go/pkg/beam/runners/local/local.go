@@ -0,0 +1,73 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package local contains a portable runner that executes pipelines in the
+// current process over the Fn API. Unlike the direct runner, pipelines are
+// translated to the portable model, and user code runs in SDK harnesses
+// exchanging encoded elements with the runner over the data and state
+// channels, as on distributed runners.
+//
+// Without an --endpoint, the runner starts an in-process job service. With
+// one, it submits to a job service started separately, such as the
+// localjobserver command. SDK harnesses run in loopback mode.
+package local
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/jobopts"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/local/internal/engine"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/local/internal/jobservices"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/universal"
+)
+
+func init() {
+	beam.RegisterRunner("local", Execute)
+	beam.RegisterRunner("LocalRunner", Execute)
+}
+
+// Execute runs the pipeline on the local runner.
+func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error) {
+	endpoint := *jobopts.Endpoint
+	if endpoint == "" {
+		s, err := NewServer(0)
+		if err != nil {
+			return nil, err
+		}
+		go s.Serve()
+		defer s.Stop()
+		endpoint = s.Endpoint()
+		log.Infof(ctx, "Started local job service at %v", endpoint)
+	}
+	o := universal.Overrides{
+		Loopback: strings.ToLower(*jobopts.EnvironmentType) != "external",
+	}
+	// Loopback harnesses run in this process, so no worker binary is built.
+	// The running binary is staged in its place.
+	if self, err := os.Executable(); err == nil {
+		o.WorkerBinary = self
+	}
+	return universal.ExecuteWithOverrides(ctx, p, endpoint, o)
+}
+
+// NewServer returns a job service on the given port, or on a free port if
+// zero, that executes jobs with the local runner.
+func NewServer(port int) (*jobservices.Server, error) {
+	return jobservices.NewServer(port, engine.Execute)
+}
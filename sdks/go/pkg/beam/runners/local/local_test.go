@@ -0,0 +1,225 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/resource"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/transforms/stats"
+)
+
+func init() {
+	beam.RegisterFunction(toKV)
+	beam.RegisterFunction(sumValues)
+	beam.RegisterFunction(addSide)
+	beam.RegisterFunction(countWords)
+	beam.RegisterFunction(failOnThree)
+	beam.RegisterFunction(withTimestamp)
+	beam.RegisterFunction(countValues)
+	beam.RegisterFunction(formatKV)
+	beam.RegisterType(reflect.TypeOf((*checkpointFn)(nil)))
+}
+
+func toKV(w string) (string, int) { return w, 1 }
+
+func sumValues(k string, vs func(*int) bool) (string, int) {
+	sum, v := 0, 0
+	for vs(&v) {
+		sum += v
+	}
+	return k, sum
+}
+
+func formatKV(k string, v int) string { return fmt.Sprintf("%v:%v", k, v) }
+
+func addSide(x int, side func(*int) bool, emit func(int)) {
+	var v int
+	for side(&v) {
+		emit(x + v)
+	}
+}
+
+func countWords(ctx context.Context, w string) string {
+	metrics.NewCounter("local", "words").Inc(ctx, 1)
+	return w
+}
+
+func failOnThree(x int) (int, error) {
+	if x == 3 {
+		return 0, errors.New("three")
+	}
+	return x, nil
+}
+
+func withTimestamp(x int, emit func(beam.EventTime, int)) {
+	emit(beam.EventTime(0).Add(time.Duration(x)*time.Minute), x)
+}
+
+func countValues(_ int, vs func(*int) bool) int {
+	n, v := 0, 0
+	for vs(&v) {
+		n++
+	}
+	return n
+}
+
+// checkpointFn emits the positions up to each element, one per bundle: it
+// claims a single position, and then checkpoints the rest of the restriction.
+type checkpointFn struct{}
+
+func (fn *checkpointFn) CreateInitialRestriction(n int) offsetrange.Restriction {
+	return offsetrange.Restriction{Start: 0, End: int64(n)}
+}
+
+func (fn *checkpointFn) SplitRestriction(_ int, rest offsetrange.Restriction) []offsetrange.Restriction {
+	return []offsetrange.Restriction{rest}
+}
+
+func (fn *checkpointFn) RestrictionSize(_ int, rest offsetrange.Restriction) float64 {
+	return rest.Size()
+}
+
+func (fn *checkpointFn) CreateTracker(rest offsetrange.Restriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(offsetrange.NewTracker(rest))
+}
+
+func (fn *checkpointFn) ProcessElement(rt *sdf.LockRTracker, _ int, emit func(int)) sdf.ProcessContinuation {
+	pos := rt.GetRestriction().(offsetrange.Restriction).Start
+	if !rt.TryClaim(pos) {
+		return sdf.StopProcessing()
+	}
+	emit(int(pos))
+	return sdf.ResumeProcessingIn(time.Millisecond)
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(s beam.Scope)
+	}{
+		{"gbk", func(s beam.Scope) {
+			words := beam.Create(s, "a", "b", "a", "c", "a")
+			sums := beam.ParDo(s, sumValues, beam.GroupByKey(s, beam.ParDo(s, toKV, words)))
+			passert.Equals(s, beam.ParDo(s, formatKV, sums), "a:3", "b:1", "c:1")
+		}},
+		{"combine", func(s beam.Scope) {
+			words := beam.Create(s, "a", "b", "a")
+			sums := stats.SumPerKey(s, beam.ParDo(s, toKV, words))
+			passert.Equals(s, beam.ParDo(s, formatKV, sums), "a:2", "b:1")
+		}},
+		{"side_input", func(s beam.Scope) {
+			main := beam.Create(s, 1, 2)
+			side := beam.Create(s, 10, 20)
+			passert.Equals(s, beam.ParDo(s, addSide, main, beam.SideInput{Input: side}), 11, 21, 12, 22)
+		}},
+		{"flatten", func(s beam.Scope) {
+			a := beam.Create(s, 1, 2)
+			b := beam.Create(s, 3)
+			passert.Equals(s, beam.Flatten(s, a, b), 1, 2, 3)
+		}},
+		{"sessions", func(s beam.Scope) {
+			stamped := beam.ParDo(s, withTimestamp, beam.Create(s, 1, 2, 3, 10, 11))
+			keyed := beam.AddFixedKey(s, beam.WindowInto(s, window.NewSessions(5*time.Minute), stamped))
+			counts := beam.ParDo(s, countValues, beam.GroupByKey(s, keyed))
+			passert.Equals(s, beam.WindowInto(s, window.NewGlobalWindows(), counts), 3, 2)
+		}},
+		{"checkpoint", func(s beam.Scope) {
+			positions := beam.ParDo(s, &checkpointFn{}, beam.Create(s, 2, 3))
+			passert.Equals(s, positions, 0, 1, 0, 1, 2)
+		}},
+		{"resource_hints", func(s beam.Scope) {
+			words := beam.Create(s, "a", "b", "a")
+			kvs := beam.ParDo(s, toKV, words, beam.WithResourceHints(resource.MinRAMBytes(1<<30)))
+			sums := beam.ParDo(s, sumValues, beam.GroupByKey(s, kvs))
+			passert.Equals(s, beam.ParDo(s, formatKV, sums), "a:2", "b:1")
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, s := beam.NewPipelineWithRoot()
+			test.build(s)
+			if _, err := Execute(context.Background(), p); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestExecute_metrics(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	beam.ParDo(s, countWords, beam.Create(s, "a", "b", "c"))
+	pr, err := Execute(context.Background(), p)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	qr := pr.Metrics().Query(func(sr metrics.SingleResult) bool {
+		return sr.Name() == "words"
+	})
+	if len(qr.Counters()) != 1 {
+		t.Fatalf("Metrics().Query(words) = %v, want one counter", qr.Counters())
+	}
+	if got, want := qr.Counters()[0].Committed, int64(3); got != want {
+		t.Errorf("words counter = %v, want %v", got, want)
+	}
+}
+
+func TestExecute_failure(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	beam.ParDo(s, failOnThree, beam.Create(s, 1, 2, 3))
+	if _, err := Execute(context.Background(), p); err == nil {
+		t.Fatal("Execute succeeded, want error")
+	}
+}
+
+func TestExecute_concurrent(t *testing.T) {
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			p, s := beam.NewPipelineWithRoot()
+			sums := stats.SumPerKey(s, beam.ParDo(s, toKV, beam.Create(s, "a", "b", "a")))
+			passert.Equals(s, beam.ParDo(s, formatKV, sums), "a:2", "b:1")
+			_, err := Execute(context.Background(), p)
+			errs <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Execute failed: %v", err)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	if !flag.Parsed() {
+		flag.Parse()
+	}
+	beam.Init()
+	os.Exit(m.Run())
+}
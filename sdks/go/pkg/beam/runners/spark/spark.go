@@ -14,13 +14,25 @@
 // limitations under the License.
 
 // Package spark contains the Spark runner.
+//
+// With --spark_job_server=auto and no job service endpoint, the runner
+// downloads the Spark job server JAR matching the SDK version, launches it,
+// submits the pipeline and stops the job server once the job completes, so the
+// pipeline result can't be used to control the job. The job server runs the
+// job on the Spark cluster given by --spark_master_url, which defaults to an
+// embedded local Spark cluster, convenient for local testing.
 package spark
 
 import (
 	"context"
+	"flag"
+	"fmt"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/jobopts"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/universal"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners/universal/runnerlib"
 )
 
 func init() {
@@ -28,8 +40,30 @@ func init() {
 	beam.RegisterRunner("SparkRunner", Execute)
 }
 
+var (
+	launchJobServer = flag.String("spark_job_server", "", "Set to auto to download and launch a Spark job server when no --endpoint is given. The job server is stopped once the job completes (optional).")
+	sparkMasterURL  = flag.String("spark_master_url", "local[4]", "Spark master URL for a launched job server, such as spark://host:port, or local[N] for an embedded cluster (optional).")
+	sparkVersion    = flag.String("spark_version", "3", "Spark major version of a launched job server (optional).")
+	jobServerJar    = flag.String("spark_job_server_jar", "", "Local Spark job server JAR to launch instead of the released one (optional).")
+)
+
 // Execute runs the given pipeline on Spark. Convenience wrapper over the
 // universal runner.
 func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error) {
+	launch, err := runnerlib.LaunchJobServer(*launchJobServer, *jobopts.Endpoint)
+	if err != nil {
+		return nil, errors.WithContext(err, "parsing --spark_job_server")
+	}
+	if launch && *jobopts.TemplateLocation == "" {
+		return universal.ExecuteWithJobServer(ctx, p, jobServer())
+	}
 	return universal.Execute(ctx, p)
 }
+
+func jobServer() *runnerlib.JobServer {
+	return &runnerlib.JobServer{
+		GradleTarget: fmt.Sprintf(":runners:spark:%v:job-server:shadowJar", *sparkVersion),
+		Jar:          *jobServerJar,
+		Args:         []string{"--spark-master-url", *sparkMasterURL},
+	}
+}
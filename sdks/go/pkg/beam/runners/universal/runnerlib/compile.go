@@ -19,11 +19,15 @@ package runnerlib
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -41,12 +45,70 @@ func IsWorkerCompatibleBinary() (string, bool) {
 
 var unique int32
 
+// BuildOptions control how worker binaries are cross-compiled.
+type BuildOptions struct {
+	// GOOS is the target operating system. Defaults to linux.
+	GOOS string
+	// GOARCH is the target architecture, such as amd64 or arm64. Defaults to
+	// amd64.
+	GOARCH string
+	// Static disables cgo to produce a statically linked binary, which does
+	// not depend on the C libraries of the worker container.
+	Static bool
+	// Tags are additional build tags.
+	Tags []string
+	// Flags are additional flags passed to the go command, such as -ldflags.
+	Flags []string
+	// CacheDir is an optional directory caching worker binaries by the hash of
+	// their sources and build options. Unchanged programs are not rebuilt.
+	CacheDir string
+}
+
+func (o BuildOptions) goos() string {
+	if o.GOOS == "" {
+		return "linux"
+	}
+	return o.GOOS
+}
+
+func (o BuildOptions) goarch() string {
+	if o.GOARCH == "" {
+		return "amd64"
+	}
+	return o.GOARCH
+}
+
+// env returns the environment of the go command.
+func (o BuildOptions) env() []string {
+	env := append(os.Environ(), "GOOS="+o.goos(), "GOARCH="+o.goarch())
+	if o.Static {
+		env = append(env, "CGO_ENABLED=0")
+	}
+	return env
+}
+
+// args returns the flags of the go command following the subcommand.
+func (o BuildOptions) args() []string {
+	var args []string
+	if len(o.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(o.Tags, ","))
+	}
+	return append(args, o.Flags...)
+}
+
 // BuildTempWorkerBinary creates a local worker binary in the tmp directory
 // for linux/amd64. Caller responsible for deleting the binary.
 func BuildTempWorkerBinary(ctx context.Context) (string, error) {
+	return BuildTempWorkerBinaryWithOptions(ctx, BuildOptions{})
+}
+
+// BuildTempWorkerBinaryWithOptions creates a local worker binary in the tmp
+// directory with the given build options. Caller responsible for deleting the
+// binary.
+func BuildTempWorkerBinaryWithOptions(ctx context.Context, opts BuildOptions) (string, error) {
 	id := atomic.AddInt32(&unique, 1)
 	filename := filepath.Join(os.TempDir(), fmt.Sprintf("worker-%v-%v", id, time.Now().UnixNano()))
-	if err := BuildWorkerBinary(ctx, filename); err != nil {
+	if err := BuildWorkerBinaryWithOptions(ctx, filename, opts); err != nil {
 		return "", err
 	}
 	return filename, nil
@@ -60,6 +122,13 @@ func BuildTempWorkerBinary(ctx context.Context) (string, error) {
 //   /usr/local/go/src/runtime/proc.go (skip: 4)      // not always present
 //   /usr/local/go/src/runtime/asm_amd64.s (skip: 4 or 5)
 func BuildWorkerBinary(ctx context.Context, filename string) error {
+	return BuildWorkerBinaryWithOptions(ctx, filename, BuildOptions{})
+}
+
+// BuildWorkerBinaryWithOptions creates a local worker binary with the given
+// build options. Like BuildWorkerBinary, it finds the user program by
+// examining the call stack.
+func BuildWorkerBinaryWithOptions(ctx context.Context, filename string, opts BuildOptions) error {
 	program := ""
 	var isTest bool
 	for i := 3; ; i++ {
@@ -75,23 +144,158 @@ func BuildWorkerBinary(ctx context.Context, filename string) error {
 	if !strings.HasSuffix(program, ".go") {
 		return errors.New("could not detect user main")
 	}
+	program = program[:strings.LastIndex(program, "/")+1]
+	return buildProgram(ctx, program+".", isTest, filename, opts)
+}
 
-	log.Infof(ctx, "Cross-compiling %v as %v", program, filename)
+// buildProgram cross-compiles the package in the given directory, such as
+// "/path/to/main/.", or its test binary, as filename. The go command runs in
+// that directory, so the package is built within its own module. It uses the
+// cache directory of the build options, if any.
+func buildProgram(ctx context.Context, program string, isTest bool, filename string, opts BuildOptions) error {
+	if opts.CacheDir == "" {
+		return compile(ctx, program, isTest, filename, opts)
+	}
+
+	key, err := buildKey(program, isTest, opts)
+	if err != nil {
+		return err
+	}
+	cached := filepath.Join(opts.CacheDir, "worker-"+key)
+	if _, err := os.Stat(cached); err == nil {
+		log.Infof(ctx, "Using cached worker binary %v for %v", cached, program)
+		return copyExe(cached, filename)
+	}
+	if err := os.MkdirAll(opts.CacheDir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create worker binary cache %v", opts.CacheDir)
+	}
+	// Build into a temporary file first, so concurrent builds never observe a
+	// partially written cache entry.
+	tmp := fmt.Sprintf("%v.tmp-%v-%v", cached, os.Getpid(), atomic.AddInt32(&unique, 1))
+	if err := compile(ctx, program, isTest, tmp, opts); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, cached); err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "failed to cache worker binary %v", cached)
+	}
+	return copyExe(cached, filename)
+}
+
+// compile runs the go command to cross-compile the program as filename.
+func compile(ctx context.Context, program string, isTest bool, filename string, opts BuildOptions) error {
+	log.Infof(ctx, "Cross-compiling %v as %v for %v/%v", program, filename, opts.goos(), opts.goarch())
 
 	// Cross-compile given go program. Not awesome.
-	program = program[:strings.LastIndex(program, "/")+1]
-	program = program + "."
 	var build []string
 	if isTest {
-		build = []string{"go", "test", "-c", "-o", filename, program}
+		build = []string{"go", "test", "-c"}
 	} else {
-		build = []string{"go", "build", "-o", filename, program}
+		build = []string{"go", "build"}
 	}
+	build = append(build, opts.args()...)
+	build = append(build, "-o", filename, program)
 
-	cmd := exec.Command(build[0], build[1:]...)
-	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=amd64")
+	cmd := exec.CommandContext(ctx, build[0], build[1:]...)
+	cmd.Dir = filepath.Dir(program)
+	cmd.Env = opts.env()
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return errors.Errorf("failed to cross-compile %v: %v\n%v", program, err, string(out))
 	}
 	return nil
 }
+
+// depsTemplate lists the source files of the non-standard packages a program
+// depends on, one per line.
+const depsTemplate = `{{if not .Standard}}{{$dir := .Dir}}` +
+	`{{range .GoFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .CgoFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .TestGoFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .XTestGoFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .EmbedFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}{{end}}`
+
+// buildKey returns a hash identifying the worker binary of the program. It
+// covers the build options, the go version and the contents of all
+// non-standard packages the program depends on.
+func buildKey(program string, isTest bool, opts BuildOptions) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%v/%v static=%v test=%v tags=%q flags=%q\n", opts.goos(), opts.goarch(), opts.Static, isTest, opts.Tags, opts.Flags)
+
+	version, err := goOutput(program, opts, "env", "GOVERSION")
+	if err != nil {
+		return "", err
+	}
+	h.Write(version)
+
+	list := []string{"list", "-deps"}
+	if isTest {
+		list = append(list, "-test")
+	}
+	list = append(list, opts.args()...)
+	list = append(list, "-f", depsTemplate, program)
+	out, err := goOutput(program, opts, list...)
+	if err != nil {
+		return "", err
+	}
+	files := strings.Split(strings.TrimSpace(string(out)), "\n")
+	sort.Strings(files)
+	for i, file := range files {
+		if file == "" || (i > 0 && files[i-1] == file) {
+			continue
+		}
+		if err := hashFile(h, file); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// goOutput runs the go command for the target of the build options and
+// returns its output.
+func goOutput(program string, opts BuildOptions, args ...string) ([]byte, error) {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = filepath.Dir(program)
+	cmd.Env = opts.env()
+	out, err := cmd.Output()
+	if err != nil {
+		var stderr []byte
+		if ee, ok := err.(*exec.ExitError); ok {
+			stderr = ee.Stderr
+		}
+		return nil, errors.Errorf("failed to inspect %v: %v\n%v", program, err, string(stderr))
+	}
+	return out, nil
+}
+
+func hashFile(h io.Writer, file string) error {
+	fd, err := os.Open(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to hash %v", file)
+	}
+	defer fd.Close()
+
+	fmt.Fprintf(h, "%v\n", file)
+	if _, err := io.Copy(h, fd); err != nil {
+		return errors.Wrapf(err, "failed to hash %v", file)
+	}
+	return nil
+}
+
+// copyExe copies an executable file.
+func copyExe(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
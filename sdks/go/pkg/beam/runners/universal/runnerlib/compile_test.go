@@ -0,0 +1,98 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnerlib
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuildOptions(t *testing.T) {
+	opts := BuildOptions{GOARCH: "arm64", Static: true, Tags: []string{"a", "b"}, Flags: []string{"-trimpath"}}
+	if got, want := opts.args(), []string{"-tags", "a,b", "-trimpath"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("args() = %v, want %v", got, want)
+	}
+	env := opts.env()
+	if got, want := env[len(env)-3:], []string{"GOOS=linux", "GOARCH=arm64", "CGO_ENABLED=0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("env() = %v, want suffix %v", got, want)
+	}
+	if got := (BuildOptions{}).args(); len(got) != 0 {
+		t.Errorf("args() = %v, want none", got)
+	}
+}
+
+func TestBuildKey(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not available")
+	}
+	dir := t.TempDir()
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/worker\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	write("package main\n\nfunc main() {}\n")
+
+	key := func(opts BuildOptions) string {
+		t.Helper()
+		k, err := buildKey(dir+"/.", false, opts)
+		if err != nil {
+			t.Fatalf("buildKey() failed: %v", err)
+		}
+		return k
+	}
+	amd64 := key(BuildOptions{})
+	if got := key(BuildOptions{GOARCH: "amd64"}); got != amd64 {
+		t.Errorf("buildKey() = %v, want %v for the same program", got, amd64)
+	}
+	if got := key(BuildOptions{GOARCH: "arm64"}); got == amd64 {
+		t.Errorf("buildKey() for arm64 = %v, want it to differ from amd64", got)
+	}
+	if got := key(BuildOptions{Static: true}); got == amd64 {
+		t.Errorf("buildKey() for static build = %v, want it to differ", got)
+	}
+	write("package main\n\nfunc main() { println() }\n")
+	if got := key(BuildOptions{}); got == amd64 {
+		t.Errorf("buildKey() after change = %v, want it to differ", got)
+	}
+
+	// Builds with a cache directory reuse the cached binary.
+	cache := filepath.Join(dir, "cache")
+	opts := BuildOptions{Static: true, CacheDir: cache}
+	for _, name := range []string{"worker1", "worker2"} {
+		if err := buildProgram(context.Background(), dir+"/.", false, filepath.Join(dir, name), opts); err != nil {
+			t.Fatalf("buildProgram() failed: %v", err)
+		}
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Mode()&0100 == 0 {
+			t.Errorf("buildProgram() didn't produce an executable %v: %v", name, err)
+		}
+	}
+	entries, err := os.ReadDir(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "worker-"+key(opts) {
+		t.Errorf("cache entries = %v, want one for the build key", entries)
+	}
+}
@@ -23,7 +23,9 @@ import (
 	"os"
 	"time"
 
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/metricsx"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/protox"
@@ -37,33 +39,55 @@ import (
 // Execute executes a pipeline on the universal runner serving the given endpoint.
 // Convenience function.
 func Execute(ctx context.Context, p *pipepb.Pipeline, endpoint string, opt *JobOptions, async bool) (*universalPipelineResult, error) {
-	// (1) Prepare job to obtain artifact staging instructions.
 	presult := &universalPipelineResult{}
 
-	bin := opt.Worker
-	if bin == "" {
-		if self, ok := IsWorkerCompatibleBinary(); ok {
-			bin = self
-			log.Infof(ctx, "Using running binary as worker binary: '%v'", bin)
-		} else {
-			// Cross-compile as last resort.
-
-			worker, err := BuildTempWorkerBinary(ctx)
-			if err != nil {
-				return presult, err
-			}
-			defer os.Remove(worker)
-
-			bin = worker
+	bin, cleanup, err := workerBinary(ctx, opt)
+	if err != nil {
+		return presult, err
+	}
+	defer cleanup()
+	if opt.Image != nil {
+		if err := BuildWorkerImage(ctx, bin, *opt.Image); err != nil {
+			return presult, err
 		}
-	} else {
-		log.Infof(ctx, "Using specified worker binary: '%v'", bin)
 	}
 	// Update pipeline's Go environment to refer to the correct binary.
 	if err := UpdateGoEnvironmentWorker(bin, p); err != nil {
 		return presult, err
 	}
 
+	raw, err := rawOptions(opt)
+	if err != nil {
+		return presult, err
+	}
+	return run(ctx, p, endpoint, raw, opt.Name, bin, async)
+}
+
+// workerBinary returns the worker binary to use for the job, and a function
+// to clean up any temporary binary.
+func workerBinary(ctx context.Context, opt *JobOptions) (string, func(), error) {
+	if opt.Worker != "" {
+		log.Infof(ctx, "Using specified worker binary: '%v'", opt.Worker)
+		return opt.Worker, func() {}, nil
+	}
+	if self, ok := IsWorkerCompatibleBinary(); ok {
+		log.Infof(ctx, "Using running binary as worker binary: '%v'", self)
+		return self, func() {}, nil
+	}
+	// Cross-compile as last resort.
+	worker, err := BuildTempWorkerBinaryWithOptions(ctx, opt.Build)
+	if err != nil {
+		return "", nil, err
+	}
+	return worker, func() { os.Remove(worker) }, nil
+}
+
+// run prepares, stages and submits the job to the job service at endpoint,
+// and waits for its completion unless async.
+func run(ctx context.Context, p *pipepb.Pipeline, endpoint string, raw runtime.RawOptionsWrapper, name, bin string, async bool) (*universalPipelineResult, error) {
+	// (1) Prepare job to obtain artifact staging instructions.
+	presult := &universalPipelineResult{}
+
 	cc, err := grpcx.Dial(ctx, endpoint, 2*time.Minute)
 	if err != nil {
 		return presult, errors.WithContextf(err, "connecting to job service")
@@ -71,7 +95,7 @@ func Execute(ctx context.Context, p *pipepb.Pipeline, endpoint string, opt *JobO
 	defer cc.Close()
 	client := jobpb.NewJobServiceClient(cc)
 
-	prepID, artifactEndpoint, st, err := Prepare(ctx, client, p, opt)
+	prepID, artifactEndpoint, st, err := prepare(ctx, client, p, raw, name)
 	if err != nil {
 		return presult, err
 	}
@@ -99,11 +123,11 @@ func Execute(ctx context.Context, p *pipepb.Pipeline, endpoint string, opt *JobO
 	// (4) Wait for completion.
 
 	if async {
-		return presult, nil
+		return &universalPipelineResult{jobID: jobID, endpoint: endpoint}, nil
 	}
 	err = WaitForCompletion(ctx, client, jobID)
 
-	res, presultErr := newUniversalPipelineResult(ctx, jobID, client, p)
+	res, presultErr := newUniversalPipelineResult(ctx, jobID, endpoint, client, p)
 	if presultErr != nil {
 		if err != nil {
 			return presult, errors.Wrap(err, presultErr.Error())
@@ -139,20 +163,21 @@ func UpdateGoEnvironmentWorker(worker string, p *pipepb.Pipeline) error {
 }
 
 type universalPipelineResult struct {
-	jobID   string
-	metrics *metrics.Results
+	jobID    string
+	metrics  *metrics.Results
+	endpoint string
 }
 
-func newUniversalPipelineResult(ctx context.Context, jobID string, client jobpb.JobServiceClient, p *pipepb.Pipeline) (*universalPipelineResult, error) {
+func newUniversalPipelineResult(ctx context.Context, jobID, endpoint string, client jobpb.JobServiceClient, p *pipepb.Pipeline) (*universalPipelineResult, error) {
 	request := &jobpb.GetJobMetricsRequest{JobId: jobID}
 	response, err := client.GetJobMetrics(ctx, request)
 	if err != nil {
-		return &universalPipelineResult{jobID, nil}, errors.Wrap(err, "failed to get metrics")
+		return &universalPipelineResult{jobID, nil, endpoint}, errors.Wrap(err, "failed to get metrics")
 	}
 
 	monitoredStates := response.GetMetrics()
 	metrics := metricsx.FromMonitoringInfos(p, monitoredStates.Attempted, monitoredStates.Committed)
-	return &universalPipelineResult{jobID, metrics}, nil
+	return &universalPipelineResult{jobID, metrics, endpoint}, nil
 }
 
 func (pr universalPipelineResult) Metrics() metrics.Results {
@@ -162,3 +187,75 @@ func (pr universalPipelineResult) Metrics() metrics.Results {
 func (pr universalPipelineResult) JobID() string {
 	return pr.jobID
 }
+
+// withClient calls fn with a client of the job service running the job.
+func (pr universalPipelineResult) withClient(ctx context.Context, fn func(client jobpb.JobServiceClient) error) error {
+	if pr.jobID == "" {
+		return errors.New("job was not submitted")
+	}
+	cc, err := grpcx.Dial(ctx, pr.endpoint, 2*time.Minute)
+	if err != nil {
+		return errors.WithContextf(err, "connecting to job service")
+	}
+	defer cc.Close()
+	return fn(jobpb.NewJobServiceClient(cc))
+}
+
+func (pr universalPipelineResult) State(ctx context.Context) (beam.JobState, error) {
+	var state beam.JobState
+	err := pr.withClient(ctx, func(client jobpb.JobServiceClient) error {
+		resp, err := client.GetState(ctx, &jobpb.GetJobStateRequest{JobId: pr.jobID})
+		if err != nil {
+			return errors.Wrapf(err, "failed to get state of job %v", pr.jobID)
+		}
+		state = beam.JobState(resp.GetState())
+		return nil
+	})
+	return state, err
+}
+
+func (pr universalPipelineResult) Cancel(ctx context.Context) error {
+	return pr.withClient(ctx, func(client jobpb.JobServiceClient) error {
+		resp, err := client.Cancel(ctx, &jobpb.CancelJobRequest{JobId: pr.jobID})
+		if err != nil {
+			return errors.Wrapf(err, "failed to cancel job %v", pr.jobID)
+		}
+		log.Infof(ctx, "Cancelling job %v: %v", pr.jobID, resp.GetState())
+		return nil
+	})
+}
+
+// Drain is not supported by the portable job API, which has no drain request.
+func (pr universalPipelineResult) Drain(ctx context.Context) error {
+	return errors.Errorf("cannot drain job %v: draining is not supported by the portable job API", pr.jobID)
+}
+
+func (pr universalPipelineResult) WaitUntilFinish(ctx context.Context, timeout time.Duration) (beam.JobState, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	state := beam.JobStateUnspecified
+	err := pr.withClient(ctx, func(client jobpb.JobServiceClient) error {
+		stream, err := client.GetStateStream(ctx, &jobpb.GetJobStateRequest{JobId: pr.jobID})
+		if err != nil {
+			return errors.Wrapf(err, "failed to get state stream of job %v", pr.jobID)
+		}
+		for !state.IsTerminal() {
+			event, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return err
+			}
+			state = beam.JobState(event.GetState())
+		}
+		return nil
+	})
+	return state, err
+}
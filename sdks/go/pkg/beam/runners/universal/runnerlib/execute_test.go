@@ -0,0 +1,85 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnerlib
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	jobpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/jobmanagement_v1"
+	"google.golang.org/grpc"
+)
+
+// fakeJobService serves the state of a single job, which is cancelled on
+// request.
+type fakeJobService struct {
+	jobpb.UnimplementedJobServiceServer
+	cancelled chan struct{}
+}
+
+func (f *fakeJobService) GetState(ctx context.Context, req *jobpb.GetJobStateRequest) (*jobpb.JobStateEvent, error) {
+	return &jobpb.JobStateEvent{State: jobpb.JobState_RUNNING}, nil
+}
+
+func (f *fakeJobService) Cancel(ctx context.Context, req *jobpb.CancelJobRequest) (*jobpb.CancelJobResponse, error) {
+	close(f.cancelled)
+	return &jobpb.CancelJobResponse{State: jobpb.JobState_CANCELLING}, nil
+}
+
+func (f *fakeJobService) GetStateStream(req *jobpb.GetJobStateRequest, stream jobpb.JobService_GetStateStreamServer) error {
+	if err := stream.Send(&jobpb.JobStateEvent{State: jobpb.JobState_RUNNING}); err != nil {
+		return err
+	}
+	select {
+	case <-f.cancelled:
+	case <-stream.Context().Done():
+		return stream.Context().Err()
+	}
+	return stream.Send(&jobpb.JobStateEvent{State: jobpb.JobState_CANCELLED})
+}
+
+func TestUniversalPipelineResult(t *testing.T) {
+	ctx := context.Background()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	jobpb.RegisterJobServiceServer(srv, &fakeJobService{cancelled: make(chan struct{})})
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	var pr beam.ControllablePipelineResult = &universalPipelineResult{jobID: "job", endpoint: lis.Addr().String()}
+
+	if state, err := pr.State(ctx); err != nil || state != beam.JobStateRunning {
+		t.Errorf("State() = %v, %v, want %v", state, err, beam.JobStateRunning)
+	}
+	if state, err := pr.WaitUntilFinish(ctx, 50*time.Millisecond); err == nil || state != beam.JobStateRunning {
+		t.Errorf("WaitUntilFinish() = %v, %v, want %v and timeout error", state, err, beam.JobStateRunning)
+	}
+	if err := pr.Drain(ctx); err == nil {
+		t.Errorf("Drain() succeeded, want unsupported error")
+	}
+	if err := pr.Cancel(ctx); err != nil {
+		t.Fatalf("Cancel() failed: %v", err)
+	}
+	if state, err := pr.WaitUntilFinish(ctx, time.Minute); err != nil || state != beam.JobStateCancelled {
+		t.Errorf("WaitUntilFinish() = %v, %v, want %v", state, err, beam.JobStateCancelled)
+	}
+}
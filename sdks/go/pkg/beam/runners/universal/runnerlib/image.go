@@ -0,0 +1,474 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnerlib
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+)
+
+// WorkerImagePath is the location of the worker binary in worker container
+// images. The boot program of the Go SDK container runs the binary at this
+// location, if present, instead of a staged worker binary.
+const WorkerImagePath = "/opt/apache/beam/worker"
+
+const (
+	ociIndexType           = "application/vnd.oci.image.index.v1+json"
+	ociManifestType        = "application/vnd.oci.image.manifest.v1+json"
+	ociConfigType          = "application/vnd.oci.image.config.v1+json"
+	ociLayerType           = "application/vnd.oci.image.layer.v1.tar+gzip"
+	dockerManifestListType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerManifestType     = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerLayerType        = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+// ImageOptions describe a worker container image, produced by layering the
+// worker binary onto the Go SDK boot container image.
+type ImageOptions struct {
+	// Base is the boot container image in OCI image layout, either as a
+	// directory or as a tar archive of one, such as produced by
+	// "docker buildx build --output type=oci".
+	Base string
+	// Output is the path of the tar archive of the OCI image layout written.
+	// It can be loaded without a Docker daemon by tools such as skopeo or
+	// crane, or by "docker load".
+	Output string
+	// Name is the optional reference name of the image, such as
+	// "beam_go_worker:latest".
+	Name string
+	// OS and Architecture select the base image for multi-platform images.
+	// They default to linux and amd64.
+	OS, Architecture string
+}
+
+type ociPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *ociPlatform      `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType,omitempty"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Config        ociDescriptor     `json:"config"`
+	Layers        []ociDescriptor   `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// blob is the content of a blob written to the image layout.
+type blob struct {
+	desc ociDescriptor
+	data []byte // nil if copied from the base image
+}
+
+// BuildWorkerImage writes a worker container image with the given worker
+// binary at WorkerImagePath. The image is built without a container engine,
+// and is reproducible: building it twice from the same inputs yields the same
+// image digest.
+func BuildWorkerImage(ctx context.Context, worker string, opts ImageOptions) error {
+	base, cleanup, err := openLayout(opts.Base)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	manifest, config, err := readBaseImage(base, opts)
+	if err != nil {
+		return errors.WithContextf(err, "reading base image %v", opts.Base)
+	}
+
+	layer, diffID, err := workerLayer(worker)
+	if err != nil {
+		return err
+	}
+	layerType := ociLayerType
+	if manifest.MediaType == dockerManifestType {
+		layerType = dockerLayerType
+	}
+	layerDesc := newDescriptor(layerType, layer)
+
+	config, err = appendConfigLayer(config, diffID)
+	if err != nil {
+		return errors.WithContextf(err, "updating config of base image %v", opts.Base)
+	}
+	configDesc := newDescriptor(manifest.Config.MediaType, config)
+	if configDesc.MediaType == "" {
+		configDesc.MediaType = ociConfigType
+	}
+
+	var blobs []blob
+	for _, l := range manifest.Layers {
+		blobs = append(blobs, blob{desc: l})
+	}
+	manifest.Config = configDesc
+	manifest.Layers = append(manifest.Layers, layerDesc)
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	manifestDesc := newDescriptor(manifest.MediaType, manifestData)
+	if manifestDesc.MediaType == "" {
+		manifestDesc.MediaType = ociManifestType
+	}
+	if opts.Name != "" {
+		manifestDesc.Annotations = map[string]string{ociRefNameAnnotation: opts.Name}
+	}
+	blobs = append(blobs,
+		blob{desc: layerDesc, data: layer},
+		blob{desc: configDesc, data: config},
+		blob{desc: manifestDesc, data: manifestData})
+
+	index, err := json.Marshal(&ociIndex{SchemaVersion: 2, MediaType: ociIndexType, Manifests: []ociDescriptor{manifestDesc}})
+	if err != nil {
+		return err
+	}
+	if err := writeLayout(base, opts.Output, index, blobs); err != nil {
+		return errors.WithContextf(err, "writing worker image %v", opts.Output)
+	}
+	log.Infof(ctx, "Wrote worker image %v with digest %v", opts.Output, manifestDesc.Digest)
+	return nil
+}
+
+// openLayout returns the OCI image layout at the given path. Tar archives are
+// extracted into a temporary directory, removed by the returned function.
+func openLayout(p string) (fs.FS, func(), error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid base image %v", p)
+	}
+	if info.IsDir() {
+		return os.DirFS(p), func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "beam-image-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	if err := untar(p, dir); err != nil {
+		cleanup()
+		return nil, nil, errors.WithContextf(err, "extracting base image %v", p)
+	}
+	return os.DirFS(dir), cleanup, nil
+}
+
+// untar extracts the regular files of a tar archive into dir.
+func untar(archive, dir string) error {
+	fd, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	r := tar.NewReader(fd)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(hdr.Name)
+		if !fs.ValidPath(name) {
+			return errors.Errorf("invalid file name %q in archive", hdr.Name)
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		w, err := os.Create(dst)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			w.Close()
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+}
+
+// readBaseImage returns the manifest and config of the base image for the
+// platform of the options.
+func readBaseImage(base fs.FS, opts ImageOptions) (*ociManifest, []byte, error) {
+	goos, arch := opts.OS, opts.Architecture
+	if goos == "" {
+		goos = "linux"
+	}
+	if arch == "" {
+		arch = "amd64"
+	}
+
+	data, err := fs.ReadFile(base, "index.json")
+	if err != nil {
+		return nil, nil, err
+	}
+	desc := ociDescriptor{MediaType: ociIndexType}
+	// Resolve nested indexes, such as multi-platform images, down to the
+	// manifest of the platform.
+	for desc.MediaType == ociIndexType || desc.MediaType == dockerManifestListType {
+		var index ociIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid image index")
+		}
+		var ok bool
+		if desc, ok = selectManifest(index.Manifests, goos, arch); !ok {
+			return nil, nil, errors.Errorf("no image for platform %v/%v", goos, arch)
+		}
+		if data, err = readBlob(base, desc); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid image manifest")
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = desc.MediaType
+	}
+	config, err := readBlob(base, manifest.Config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &manifest, config, nil
+}
+
+// selectManifest returns the descriptor matching the platform. Descriptors
+// without platform only match if they are the only one.
+func selectManifest(descs []ociDescriptor, goos, arch string) (ociDescriptor, bool) {
+	for _, d := range descs {
+		if d.Platform != nil && d.Platform.OS == goos && d.Platform.Architecture == arch {
+			return d, true
+		}
+	}
+	if len(descs) == 1 && descs[0].Platform == nil {
+		return descs[0], true
+	}
+	return ociDescriptor{}, false
+}
+
+func blobPath(digest string) (string, error) {
+	algo, hash, ok := strings.Cut(digest, ":")
+	if !ok || algo == "" || strings.ContainsAny(hash, "/.") {
+		return "", errors.Errorf("invalid digest %q", digest)
+	}
+	return path.Join("blobs", algo, hash), nil
+}
+
+func readBlob(base fs.FS, desc ociDescriptor) ([]byte, error) {
+	p, err := blobPath(desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(base, p)
+}
+
+func newDescriptor(mediaType string, data []byte) ociDescriptor {
+	sum := sha256.Sum256(data)
+	return ociDescriptor{
+		MediaType: mediaType,
+		Digest:    "sha256:" + hex.EncodeToString(sum[:]),
+		Size:      int64(len(data)),
+	}
+}
+
+// workerLayer returns a gzipped image layer holding the worker binary at
+// WorkerImagePath, and the digest of its uncompressed content. File metadata
+// is fixed, so the layer only depends on the binary.
+func workerLayer(worker string) ([]byte, string, error) {
+	bin, err := os.ReadFile(worker)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to read worker binary %v", worker)
+	}
+
+	var tarball bytes.Buffer
+	w := tar.NewWriter(&tarball)
+	mtime := time.Unix(0, 0)
+	dir := strings.TrimPrefix(path.Dir(WorkerImagePath), "/")
+	var parents []string
+	for d := dir; d != "."; d = path.Dir(d) {
+		parents = append([]string{d}, parents...)
+	}
+	for _, d := range parents {
+		hdr := &tar.Header{Typeflag: tar.TypeDir, Name: d + "/", Mode: 0755, ModTime: mtime, Format: tar.FormatPAX}
+		if err := w.WriteHeader(hdr); err != nil {
+			return nil, "", err
+		}
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     strings.TrimPrefix(WorkerImagePath, "/"),
+		Mode:     0755,
+		Size:     int64(len(bin)),
+		ModTime:  mtime,
+		Format:   tar.FormatPAX,
+	}
+	if err := w.WriteHeader(hdr); err != nil {
+		return nil, "", err
+	}
+	if _, err := w.Write(bin); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	diffID := sha256.Sum256(tarball.Bytes())
+
+	var layer bytes.Buffer
+	gz := gzip.NewWriter(&layer)
+	if _, err := gz.Write(tarball.Bytes()); err != nil {
+		return nil, "", err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, "", err
+	}
+	return layer.Bytes(), "sha256:" + hex.EncodeToString(diffID[:]), nil
+}
+
+// appendConfigLayer adds the layer with the given uncompressed digest to an
+// image config, preserving all other fields.
+func appendConfigLayer(config []byte, diffID string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(config, &fields); err != nil {
+		return nil, err
+	}
+	var rootfs struct {
+		Type    string   `json:"type"`
+		DiffIDs []string `json:"diff_ids"`
+	}
+	if err := json.Unmarshal(fields["rootfs"], &rootfs); err != nil {
+		return nil, errors.Wrap(err, "invalid rootfs")
+	}
+	rootfs.DiffIDs = append(rootfs.DiffIDs, diffID)
+
+	var history []map[string]interface{}
+	if h, ok := fields["history"]; ok {
+		if err := json.Unmarshal(h, &history); err != nil {
+			return nil, errors.Wrap(err, "invalid history")
+		}
+	}
+	history = append(history, map[string]interface{}{
+		"created_by": fmt.Sprintf("ADD worker %v", WorkerImagePath),
+	})
+
+	var err error
+	if fields["rootfs"], err = json.Marshal(rootfs); err != nil {
+		return nil, err
+	}
+	if fields["history"], err = json.Marshal(history); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// writeLayout writes a tar archive of an OCI image layout with the given
+// index and blobs. Blobs without data are copied from the base layout.
+func writeLayout(base fs.FS, output string, index []byte, blobs []blob) error {
+	fd, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	w := tar.NewWriter(fd)
+
+	write := func(name string, size int64, r io.Reader) error {
+		hdr := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: size, ModTime: time.Unix(0, 0), Format: tar.FormatPAX}
+		if err := w.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(w, r)
+		return err
+	}
+	writeBlob := func(b blob) error {
+		p, err := blobPath(b.desc.Digest)
+		if err != nil {
+			return err
+		}
+		if b.data != nil {
+			return write(p, int64(len(b.data)), bytes.NewReader(b.data))
+		}
+		r, err := base.Open(p)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return write(p, b.desc.Size, r)
+	}
+
+	layout := []byte(`{"imageLayoutVersion":"1.0.0"}`)
+	if err := write("oci-layout", int64(len(layout)), bytes.NewReader(layout)); err != nil {
+		fd.Close()
+		return err
+	}
+	if err := write("index.json", int64(len(index)), bytes.NewReader(index)); err != nil {
+		fd.Close()
+		return err
+	}
+	written := make(map[string]bool)
+	for _, b := range blobs {
+		if written[b.desc.Digest] {
+			continue
+		}
+		written[b.desc.Digest] = true
+		if err := writeBlob(b); err != nil {
+			fd.Close()
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
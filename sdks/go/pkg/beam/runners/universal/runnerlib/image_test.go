@@ -0,0 +1,225 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnerlib
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeBaseLayout writes a single layer base image in OCI image layout to dir.
+func writeBaseLayout(t *testing.T, dir string) {
+	t.Helper()
+	put := func(data []byte) ociDescriptor {
+		desc := newDescriptor("", data)
+		p, _ := blobPath(desc.Digest)
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(p)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, p), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return desc
+	}
+	layer := put([]byte("base layer"))
+	layer.MediaType = ociLayerType
+	config := put([]byte(`{"architecture":"amd64","os":"linux","config":{"Entrypoint":["/opt/apache/beam/boot"]},"rootfs":{"type":"layers","diff_ids":["sha256:base"]}}`))
+	config.MediaType = ociConfigType
+	manifest, _ := json.Marshal(&ociManifest{SchemaVersion: 2, MediaType: ociManifestType, Config: config, Layers: []ociDescriptor{layer}})
+	desc := put(manifest)
+	desc.MediaType = ociManifestType
+	desc.Platform = &ociPlatform{OS: "linux", Architecture: "amd64"}
+	index, _ := json.Marshal(&ociIndex{SchemaVersion: 2, Manifests: []ociDescriptor{desc}})
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), index, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTar returns the regular files of a tar archive by name.
+func readTar(t *testing.T, r io.Reader) map[string][]byte {
+	t.Helper()
+	ret := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return ret
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			ret[hdr.Name] = data
+		}
+		if hdr.Name == WorkerImagePath[1:] && hdr.Mode != 0755 {
+			t.Errorf("worker binary mode = %o, want 0755", hdr.Mode)
+		}
+	}
+}
+
+// tarDir writes a tar archive of the files in dir.
+func tarDir(t *testing.T, dir, archive string) {
+	t.Helper()
+	fd, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	w := tar.NewWriter(fd)
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(dir, p)
+		if err := w.WriteHeader(&tar.Header{Name: filepath.ToSlash(name), Mode: 0644, Size: int64(len(data))}); err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildWorkerImage(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base")
+	writeBaseLayout(t, base)
+	worker := filepath.Join(dir, "worker")
+	if err := os.WriteFile(worker, []byte("worker binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "image.tar")
+	opts := ImageOptions{Base: base, Output: out, Name: "beam_go_worker:latest"}
+	if err := BuildWorkerImage(context.Background(), worker, opts); err != nil {
+		t.Fatalf("BuildWorkerImage() failed: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readTar(t, bytes.NewReader(data))
+
+	for name, content := range files {
+		if name == "oci-layout" || name == "index.json" {
+			continue
+		}
+		sum := sha256.Sum256(content)
+		if want := "blobs/sha256/" + hex.EncodeToString(sum[:]); name != want {
+			t.Errorf("blob %v doesn't match its digest %v", name, want)
+		}
+	}
+	blob := func(desc ociDescriptor) []byte {
+		p, err := blobPath(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, ok := files[p]
+		if !ok {
+			t.Fatalf("blob %v missing from image", desc.Digest)
+		}
+		return b
+	}
+
+	var index ociIndex
+	if err := json.Unmarshal(files["index.json"], &index); err != nil {
+		t.Fatalf("invalid index: %v", err)
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].Annotations[ociRefNameAnnotation] != opts.Name {
+		t.Fatalf("index manifests = %+v, want one named %v", index.Manifests, opts.Name)
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(blob(index.Manifests[0]), &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+	if got, want := len(manifest.Layers), 2; got != want {
+		t.Fatalf("image has %v layers, want %v", got, want)
+	}
+	if got, want := string(blob(manifest.Layers[0])), "base layer"; got != want {
+		t.Errorf("base layer = %q, want %q", got, want)
+	}
+
+	var config struct {
+		Config struct{ Entrypoint []string }
+		RootFS struct {
+			DiffIDs []string `json:"diff_ids"`
+		} `json:"rootfs"`
+	}
+	if err := json.Unmarshal(blob(manifest.Config), &config); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	if len(config.Config.Entrypoint) != 1 {
+		t.Errorf("config entrypoint = %v, want the base entrypoint", config.Config.Entrypoint)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(blob(manifest.Layers[1])))
+	if err != nil {
+		t.Fatalf("invalid worker layer: %v", err)
+	}
+	layer, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(layer)
+	if got, want := config.RootFS.DiffIDs, []string{"sha256:base", "sha256:" + hex.EncodeToString(sum[:])}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("config diff_ids = %v, want %v", got, want)
+	}
+	if got, want := string(readTar(t, bytes.NewReader(layer))[WorkerImagePath[1:]]), "worker binary"; got != want {
+		t.Errorf("worker layer binary = %q, want %q", got, want)
+	}
+
+	// Building from a tar archive of the base image yields the same image.
+	baseTar := filepath.Join(dir, "base.tar")
+	tarDir(t, base, baseTar)
+	opts.Base = baseTar
+	if err := BuildWorkerImage(context.Background(), worker, opts); err != nil {
+		t.Fatalf("BuildWorkerImage() from archive failed: %v", err)
+	}
+	again, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Errorf("BuildWorkerImage() from archive produced a different image")
+	}
+
+	opts.Architecture = "arm64"
+	if err := BuildWorkerImage(context.Background(), worker, opts); err == nil {
+		t.Errorf("BuildWorkerImage() for arm64 succeeded, want missing platform error")
+	}
+}
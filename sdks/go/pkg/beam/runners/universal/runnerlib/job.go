@@ -27,6 +27,7 @@ import (
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
 	jobpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/jobmanagement_v1"
 	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/typedopts"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/provision"
 	"github.com/golang/protobuf/proto"
 )
@@ -43,6 +44,12 @@ type JobOptions struct {
 
 	// Worker is the worker binary override.
 	Worker string
+	// Build are the options for cross-compiling the worker binary, if not
+	// overridden.
+	Build BuildOptions
+	// Image optionally describes a worker container image to produce with
+	// the worker binary.
+	Image *ImageOptions
 
 	// RetainDocker is an option to pass to the runner.
 	RetainDocker bool
@@ -53,16 +60,32 @@ type JobOptions struct {
 // Prepare prepares a job to the given job service. It returns the preparation id
 // artifact staging endpoint, and staging token if successful.
 func Prepare(ctx context.Context, client jobpb.JobServiceClient, p *pipepb.Pipeline, opt *JobOptions) (id, endpoint, stagingToken string, err error) {
+	raw, err := rawOptions(opt)
+	if err != nil {
+		return "", "", "", err
+	}
+	return prepare(ctx, client, p, raw, opt.Name)
+}
+
+// rawOptions returns the pipeline options of the job.
+func rawOptions(opt *JobOptions) (runtime.RawOptionsWrapper, error) {
 	hooks.SerializeHooksToOptions()
 	beam.PipelineOptions.LoadOptionsFromFlags(nil)
-	raw := runtime.RawOptionsWrapper{
+	typed, err := typedopts.Export()
+	if err != nil {
+		return runtime.RawOptionsWrapper{}, errors.WithContext(err, "producing typed pipeline options")
+	}
+	return runtime.RawOptionsWrapper{
 		Options:      beam.PipelineOptions.Export(),
 		AppName:      opt.Name,
 		Experiments:  append(opt.Experiments, "beam_fn_api"),
 		RetainDocker: opt.RetainDocker,
 		Parallelism:  opt.Parallelism,
-	}
+		TypedOptions: typed,
+	}, nil
+}
 
+func prepare(ctx context.Context, client jobpb.JobServiceClient, p *pipepb.Pipeline, raw runtime.RawOptionsWrapper, name string) (id, endpoint, stagingToken string, err error) {
 	options, err := provision.OptionsToProto(raw)
 	if err != nil {
 		return "", "", "", errors.WithContext(err, "producing pipeline options")
@@ -70,7 +93,7 @@ func Prepare(ctx context.Context, client jobpb.JobServiceClient, p *pipepb.Pipel
 	req := &jobpb.PrepareJobRequest{
 		Pipeline:        p,
 		PipelineOptions: options,
-		JobName:         opt.Name,
+		JobName:         name,
 	}
 	resp, err := client.Prepare(ctx, req)
 	if err != nil {
@@ -0,0 +1,91 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnerlib
+
+import (
+	"context"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/xlangx/expansionx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+)
+
+// LaunchJobServer returns whether a runner should launch its job server, given
+// the value of its job server flag and the job service endpoint. The job server
+// is only launched if the flag is "auto", and no endpoint is given.
+func LaunchJobServer(mode, endpoint string) (bool, error) {
+	switch mode {
+	case "":
+		return false, nil
+	case "auto":
+		return endpoint == "", nil
+	default:
+		return false, errors.Errorf("invalid job server mode %q, want \"auto\" or none", mode)
+	}
+}
+
+// JobServer describes a Beam job server JAR, such as the Flink or Spark job
+// server, that runners can launch when no job service endpoint is given.
+type JobServer struct {
+	// GradleTarget is the Gradle target building the job server JAR. It is
+	// used to download the JAR released with the SDK version.
+	GradleTarget string
+	// Jar is the path of a local job server JAR. It overrides the downloaded
+	// JAR, such as for development versions of the SDK.
+	Jar string
+	// Args are additional arguments passed to the job server.
+	Args []string
+}
+
+// jar returns the path of the job server JAR, downloading it if needed.
+func (s *JobServer) jar() (string, error) {
+	if s.Jar != "" {
+		return s.Jar, nil
+	}
+	jar, err := expansionx.GetBeamJar(s.GradleTarget, core.SdkVersion)
+	if err != nil {
+		return "", errors.WithContextf(err, "getting job server JAR for %v", s.GradleTarget)
+	}
+	return jar, nil
+}
+
+// Start starts the job server on an open port, and waits until it accepts
+// connections. It returns the job service endpoint, and a function stopping
+// the job server.
+func (s *JobServer) Start(ctx context.Context) (string, func(), error) {
+	jar, err := s.jar()
+	if err != nil {
+		return "", nil, err
+	}
+	runner, err := expansionx.NewJobServerRunner(jar, "", s.Args...)
+	if err != nil {
+		return "", nil, err
+	}
+	log.Infof(ctx, "Starting job server: %v", runner)
+	if err := runner.StartService(); err != nil {
+		runner.StopService()
+		return "", nil, errors.Wrapf(err, "failed to start job server %v", jar)
+	}
+	log.Infof(ctx, "Started job server at %v", runner.Endpoint())
+
+	stop := func() {
+		if err := runner.StopService(); err != nil {
+			log.Warnf(ctx, "Failed to stop job server: %v", err)
+		}
+	}
+	return runner.Endpoint(), stop, nil
+}
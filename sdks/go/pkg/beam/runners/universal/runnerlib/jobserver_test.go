@@ -0,0 +1,44 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnerlib
+
+import (
+	"testing"
+)
+
+func TestLaunchJobServer(t *testing.T) {
+	tests := []struct {
+		mode, endpoint string
+		want           bool
+		wantErr        bool
+	}{
+		{mode: "", endpoint: "", want: false},
+		{mode: "", endpoint: "localhost:8099", want: false},
+		{mode: "auto", endpoint: "", want: true},
+		{mode: "auto", endpoint: "localhost:8099", want: false},
+		{mode: "always", endpoint: "", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := LaunchJobServer(test.mode, test.endpoint)
+		if (err != nil) != test.wantErr {
+			t.Errorf("LaunchJobServer(%q, %q) err = %v, want error %v", test.mode, test.endpoint, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("LaunchJobServer(%q, %q) = %v, want %v", test.mode, test.endpoint, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnerlib
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/valueprovider"
+	"github.com/golang/protobuf/proto"
+
+	// Importing to allow templates on the local filesystem.
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/local"
+)
+
+// Template is a built pipeline, saved to be launched later with values for
+// its runtime parameters.
+type Template struct {
+	// Name is the job name.
+	Name string `json:"name"`
+	// Pipeline is the serialized model pipeline.
+	Pipeline []byte `json:"pipeline"`
+	// Options are the pipeline options at construction time.
+	Options runtime.RawOptionsWrapper `json:"options"`
+	// Params are the runtime parameters declared by the pipeline.
+	Params []valueprovider.Param `json:"params,omitempty"`
+	// Worker is the location of the worker binary.
+	Worker string `json:"worker"`
+}
+
+// SaveTemplate saves the pipeline as a template at location, which may be any
+// registered filesystem path. The worker binary is saved alongside it, with
+// the ".worker" suffix.
+func SaveTemplate(ctx context.Context, location string, p *pipepb.Pipeline, opt *JobOptions) error {
+	bin, cleanup, err := workerBinary(ctx, opt)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	worker := location + ".worker"
+	if err := copyFile(ctx, bin, worker); err != nil {
+		return errors.WithContextf(err, "saving worker binary to %v", worker)
+	}
+
+	raw, err := rawOptions(opt)
+	if err != nil {
+		return err
+	}
+	model, err := proto.Marshal(p)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal pipeline")
+	}
+	t := &Template{
+		Name:     opt.Name,
+		Pipeline: model,
+		Options:  raw,
+		Params:   valueprovider.Params(),
+		Worker:   worker,
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode template")
+	}
+	if err := writeFile(ctx, location, data); err != nil {
+		return errors.WithContextf(err, "saving template to %v", location)
+	}
+	log.Infof(ctx, "Saved template to %v", location)
+	return nil
+}
+
+// LoadTemplate reads the template saved at location.
+func LoadTemplate(ctx context.Context, location string) (*Template, error) {
+	fs, err := filesystem.New(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Close()
+
+	data, err := filesystem.Read(ctx, fs, location)
+	if err != nil {
+		return nil, errors.WithContextf(err, "reading template %v", location)
+	}
+	var t Template
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode template %v", location)
+	}
+	return &t, nil
+}
+
+// Bind returns the pipeline options of the template with the given runtime
+// parameter values. It fails if a value is given for an undeclared parameter,
+// or a required parameter has no value, either given or at construction time.
+func (t *Template) Bind(values map[string]string) (runtime.RawOptionsWrapper, error) {
+	opts := make(map[string]string)
+	for k, v := range t.Options.Options.Options {
+		opts[k] = v
+	}
+	all := make(map[string]string)
+	for _, p := range t.Params {
+		if v := opts[p.Name]; v != "" {
+			all[p.Name] = v
+		}
+	}
+	for k, v := range values {
+		all[k] = v
+		opts[k] = v
+	}
+	if err := valueprovider.Validate(t.Params, all); err != nil {
+		return runtime.RawOptionsWrapper{}, err
+	}
+
+	raw := t.Options
+	raw.Options = runtime.RawOptions{Options: opts}
+	return raw, nil
+}
+
+// LaunchTemplate launches the template saved at location on the job service
+// at endpoint, with the given runtime parameter values. It waits for the job
+// to complete unless async.
+func LaunchTemplate(ctx context.Context, location, endpoint string, values map[string]string, async bool) (*universalPipelineResult, error) {
+	presult := &universalPipelineResult{}
+
+	t, err := LoadTemplate(ctx, location)
+	if err != nil {
+		return presult, err
+	}
+	raw, err := t.Bind(values)
+	if err != nil {
+		return presult, errors.WithContextf(err, "binding parameters of template %v", location)
+	}
+	p := &pipepb.Pipeline{}
+	if err := proto.Unmarshal(t.Pipeline, p); err != nil {
+		return presult, errors.Wrapf(err, "failed to decode pipeline of template %v", location)
+	}
+
+	f, err := os.CreateTemp("", "beam-worker-*")
+	if err != nil {
+		return presult, err
+	}
+	bin := f.Name()
+	f.Close()
+	defer os.Remove(bin)
+	if err := copyFile(ctx, t.Worker, bin); err != nil {
+		return presult, errors.WithContextf(err, "fetching worker binary %v", t.Worker)
+	}
+	if err := os.Chmod(bin, 0755); err != nil {
+		return presult, err
+	}
+	if err := UpdateGoEnvironmentWorker(bin, p); err != nil {
+		return presult, err
+	}
+	return run(ctx, p, endpoint, raw, t.Name, bin, async)
+}
+
+func writeFile(ctx context.Context, filename string, data []byte) error {
+	fs, err := filesystem.New(ctx, filename)
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	return filesystem.Write(ctx, fs, filename, data)
+}
+
+// copyFile copies a file, possibly across filesystems.
+func copyFile(ctx context.Context, src, dst string) error {
+	sfs, err := filesystem.New(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer sfs.Close()
+	dfs, err := filesystem.New(ctx, dst)
+	if err != nil {
+		return err
+	}
+	defer dfs.Close()
+
+	r, err := sfs.OpenRead(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := dfs.OpenWrite(ctx, dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
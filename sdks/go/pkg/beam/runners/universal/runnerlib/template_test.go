@@ -0,0 +1,89 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnerlib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/valueprovider"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestSaveLoadTemplate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	worker := filepath.Join(dir, "worker")
+	if err := os.WriteFile(worker, []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	valueprovider.Runtime("runnerlib_test_input", "")
+
+	p := &pipepb.Pipeline{RootTransformIds: []string{"root"}}
+	location := filepath.Join(dir, "template.json")
+	if err := SaveTemplate(ctx, location, p, &JobOptions{Name: "job", Worker: worker}); err != nil {
+		t.Fatalf("SaveTemplate failed: %v", err)
+	}
+
+	tmpl, err := LoadTemplate(ctx, location)
+	if err != nil {
+		t.Fatalf("LoadTemplate failed: %v", err)
+	}
+	if tmpl.Name != "job" {
+		t.Errorf("Name = %v, want job", tmpl.Name)
+	}
+	got := &pipepb.Pipeline{}
+	if err := proto.Unmarshal(tmpl.Pipeline, got); err != nil {
+		t.Fatalf("invalid pipeline: %v", err)
+	}
+	if !proto.Equal(got, p) {
+		t.Errorf("Pipeline = %v, want %v", got, p)
+	}
+	if data, err := os.ReadFile(tmpl.Worker); err != nil || string(data) != "binary" {
+		t.Errorf("Worker %v = %q, %v, want saved worker binary", tmpl.Worker, data, err)
+	}
+	if _, err := tmpl.Bind(nil); err == nil {
+		t.Errorf("Bind(nil) succeeded, want error for missing required parameter")
+	}
+}
+
+func TestTemplateBind(t *testing.T) {
+	tmpl := &Template{
+		Params: []valueprovider.Param{{Name: "input"}, {Name: "output", Default: "out"}},
+	}
+	tmpl.Options.Options.Options = map[string]string{"input": "construction", "other": "x"}
+
+	raw, err := tmpl.Bind(map[string]string{"output": "launch"})
+	if err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	want := map[string]string{"input": "construction", "output": "launch", "other": "x"}
+	for k, v := range want {
+		if got := raw.Options.Options[k]; got != v {
+			t.Errorf("Bind()[%v] = %v, want %v", k, got, v)
+		}
+	}
+	if _, ok := tmpl.Options.Options.Options["output"]; ok {
+		t.Errorf("Bind modified the template options")
+	}
+
+	if _, err := tmpl.Bind(map[string]string{"unknown": "x"}); err == nil {
+		t.Errorf("Bind with unknown parameter succeeded, want error")
+	}
+}
@@ -44,6 +44,63 @@ func init() {
 
 // Execute executes the pipeline on a universal beam runner.
 func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error) {
+	endpoint, err := jobopts.GetEndpoint()
+	if err != nil && *jobopts.TemplateLocation == "" {
+		return nil, err
+	}
+	return ExecuteWithEndpoint(ctx, p, endpoint)
+}
+
+// ExecuteWithJobServer executes the pipeline on a universal beam runner
+// served by the given job server, which is started before submission and
+// stopped once the job completes. Asynchronous execution is not supported.
+//
+// As the job server is stopped when it returns, the result only provides the
+// job ID and metrics of the completed job. It doesn't implement
+// beam.ControllablePipelineResult.
+func ExecuteWithJobServer(ctx context.Context, p *beam.Pipeline, js *runnerlib.JobServer) (beam.PipelineResult, error) {
+	if *jobopts.Async {
+		return nil, errors.New("asynchronous execution requires a running job server. Use --endpoint=<endpoint>")
+	}
+	endpoint, stop, err := js.Start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+	pr, err := ExecuteWithEndpoint(ctx, p, endpoint)
+	if pr != nil {
+		pr = completedPipelineResult{pr}
+	}
+	return pr, err
+}
+
+// completedPipelineResult hides the job controls of the result of a job
+// whose job server has been stopped.
+type completedPipelineResult struct {
+	beam.PipelineResult
+}
+
+// ExecuteWithEndpoint executes the pipeline on the universal beam runner
+// serving the given job service endpoint, rather than the one given by flag.
+func ExecuteWithEndpoint(ctx context.Context, p *beam.Pipeline, endpoint string) (beam.PipelineResult, error) {
+	return ExecuteWithOverrides(ctx, p, endpoint, Overrides{})
+}
+
+// Overrides are job options chosen by a runner, which take precedence over
+// the corresponding flags.
+type Overrides struct {
+	// Loopback runs the SDK harnesses in this process, regardless of
+	// --environment_type.
+	Loopback bool
+	// WorkerBinary is the worker binary to stage, if --worker_binary isn't
+	// set.
+	WorkerBinary string
+}
+
+// ExecuteWithOverrides executes the pipeline like ExecuteWithEndpoint, with
+// the given overrides. The flags are left unchanged, so concurrent pipelines
+// aren't affected.
+func ExecuteWithOverrides(ctx context.Context, p *beam.Pipeline, endpoint string, o Overrides) (beam.PipelineResult, error) {
 	if !beam.Initialized() {
 		panic("Beam has not been initialized. Call beam.Init() before pipeline construction.")
 	}
@@ -56,9 +113,8 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 		log.Info(ctx, "Strict mode validation passed.")
 	}
 
-	endpoint, err := jobopts.GetEndpoint()
-	if err != nil {
-		return nil, err
+	if endpoint == "" && *jobopts.TemplateLocation == "" {
+		return nil, errors.New("no job service endpoint specified. Use --endpoint=<endpoint>")
 	}
 
 	edges, _, err := p.Build()
@@ -68,7 +124,10 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 	envUrn := jobopts.GetEnvironmentUrn(ctx)
 	getEnvCfg := jobopts.GetEnvironmentConfig
 
-	if jobopts.IsLoopback() {
+	if o.Loopback {
+		envUrn = graphx.URNEnvExternal
+	}
+	if o.Loopback || jobopts.IsLoopback() {
 		// TODO(BEAM-10610): Allow user configuration of this port, rather than kernel selected.
 		srv, err := extworker.StartLoopback(ctx, 0)
 		if err != nil {
@@ -85,20 +144,59 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 	if err != nil {
 		return nil, errors.WithContextf(err, "generating model pipeline")
 	}
-	pipeline, err := graphx.Marshal(edges, &graphx.Options{Environment: environment})
+	hints, err := jobopts.GetResourceHints()
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := graphx.Marshal(edges, &graphx.Options{Environment: environment, ResourceHints: hints})
 	if err != nil {
 		return nil, errors.WithContextf(err, "generating model pipeline")
 	}
 
 	log.Info(ctx, proto.MarshalTextString(pipeline))
 
+	worker := *jobopts.WorkerBinary
+	if worker == "" {
+		worker = o.WorkerBinary
+	}
 	opt := &runnerlib.JobOptions{
 		Name:         jobopts.GetJobName(),
 		Experiments:  jobopts.GetExperiments(),
-		Worker:       *jobopts.WorkerBinary,
+		Worker:       worker,
 		RetainDocker: *jobopts.RetainDockerContainers,
 		Parallelism:  *jobopts.Parallelism,
+		Build: runnerlib.BuildOptions{
+			GOARCH:   *jobopts.WorkerArch,
+			Static:   *jobopts.WorkerStatic,
+			Tags:     jobopts.GetWorkerBuildTags(),
+			Flags:    jobopts.GetWorkerBuildFlags(),
+			CacheDir: *jobopts.WorkerCacheDir,
+		},
+	}
+	if *jobopts.WorkerImageOutput != "" {
+		if *jobopts.WorkerImageBase == "" {
+			return nil, errors.New("no base image for the worker image specified. Use --worker_image_base=<OCI image layout>")
+		}
+		opt.Image = &runnerlib.ImageOptions{
+			Base:         *jobopts.WorkerImageBase,
+			Output:       *jobopts.WorkerImageOutput,
+			Name:         *jobopts.WorkerImageName,
+			Architecture: *jobopts.WorkerArch,
+		}
+	}
+	if *jobopts.TemplateLocation != "" {
+		return nil, runnerlib.SaveTemplate(ctx, *jobopts.TemplateLocation, pipeline, opt)
 	}
 	presult, err := runnerlib.Execute(ctx, pipeline, endpoint, opt, *jobopts.Async)
 	return presult, err
 }
+
+// LaunchTemplate launches the template saved at location on the job service
+// given by the endpoint flag, with the given runtime parameter values.
+func LaunchTemplate(ctx context.Context, location string, params map[string]string) (beam.PipelineResult, error) {
+	endpoint, err := jobopts.GetEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	return runnerlib.LaunchTemplate(ctx, location, endpoint, params, *jobopts.Async)
+}
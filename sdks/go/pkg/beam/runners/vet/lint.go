@@ -0,0 +1,388 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vet
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window/trigger"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+)
+
+var (
+	lintReport = flag.String("vet_report", "", "Path to write the pipeline lint findings to, as JSON.")
+	lintFailOn = flag.String("vet_fail_on", "none", "Minimum severity of lint findings that fails the pipeline (info, warning, error), or none to only report them.")
+)
+
+// Severity is the severity of a lint finding.
+type Severity int
+
+const (
+	// Info findings are worth knowing, but usually harmless.
+	Info Severity = iota
+	// Warning findings are likely to cause incorrect or slow results.
+	Warning
+	// Error findings are known to cause incorrect results or failures.
+	Error
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+// String implements the Stringer interface.
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// MarshalText encodes the severity as its name, for machine-readable reports.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a severity from its name.
+func (s *Severity) UnmarshalText(b []byte) error {
+	sev, err := ParseSeverity(string(b))
+	if err != nil {
+		return err
+	}
+	*s = sev
+	return nil
+}
+
+// ParseSeverity returns the severity with the given case-insensitive name.
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return Severity(i), nil
+		}
+	}
+	return Info, errors.Errorf("unknown severity %q, want one of %v", name, severityNames)
+}
+
+// Names of the lint checks.
+const (
+	CheckKeyCoder         = "nondeterministic_key_coder"
+	CheckGlobalWindow     = "unbounded_global_window"
+	CheckLargeDoFn        = "large_dofn_field"
+	CheckSideInputWindows = "side_input_windowing"
+)
+
+// Thresholds of the serialized size of DoFn fields, in bytes.
+const (
+	largeFieldBytes = 64 << 10
+	hugeFieldBytes  = 1 << 20
+)
+
+// Finding is a single issue found in a pipeline.
+type Finding struct {
+	Check     string   `json:"check"`
+	Severity  Severity `json:"severity"`
+	Scope     string   `json:"scope"`
+	Transform string   `json:"transform"`
+	Message   string   `json:"message"`
+}
+
+// String implements the Stringer interface.
+func (f Finding) String() string {
+	return fmt.Sprintf("%v: %v/%v: %v [%v]", strings.ToUpper(f.Severity.String()), f.Scope, f.Transform, f.Message, f.Check)
+}
+
+// Report is the result of linting a pipeline.
+type Report struct {
+	Findings []Finding `json:"findings"`
+}
+
+// MaxSeverity returns the highest severity of the findings, and false if
+// there are none.
+func (r *Report) MaxSeverity() (Severity, bool) {
+	max, ok := Info, false
+	for _, f := range r.Findings {
+		if !ok || f.Severity > max {
+			max, ok = f.Severity, true
+		}
+	}
+	return max, ok
+}
+
+// AtLeast returns the findings with at least the given severity.
+func (r *Report) AtLeast(sev Severity) []Finding {
+	var ret []Finding
+	for _, f := range r.Findings {
+		if f.Severity >= sev {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+// WriteJSON writes the report as JSON to w.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// String returns the findings, one per line.
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, f := range r.Findings {
+		b.WriteString(f.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (r *Report) add(sev Severity, check string, edge *graph.MultiEdge, format string, args ...interface{}) {
+	f := Finding{
+		Check:     check,
+		Severity:  sev,
+		Transform: edge.Name(),
+		Message:   fmt.Sprintf(format, args...),
+	}
+	if s := edge.Scope(); s != nil {
+		f.Scope = s.String()
+	}
+	r.Findings = append(r.Findings, f)
+}
+
+// Lint checks the pipeline for common mistakes that cause incorrect results
+// or poor performance.
+func Lint(_ context.Context, p *beam.Pipeline) (*Report, error) {
+	edges, _, err := p.Build()
+	if err != nil {
+		return nil, errors.WithContext(err, "building pipeline to lint")
+	}
+	return lintEdges(edges), nil
+}
+
+// lintPipeline lints the pipeline, logs the findings and writes the report if
+// requested. It fails if there are findings at or above the --vet_fail_on
+// severity, unless that is none.
+func lintPipeline(ctx context.Context, p *beam.Pipeline) error {
+	r, err := Lint(ctx, p)
+	if err != nil {
+		return err
+	}
+	if *lintReport != "" {
+		f, err := os.Create(*lintReport)
+		if err != nil {
+			return errors.Wrap(err, "creating lint report")
+		}
+		if err := r.WriteJSON(f); err != nil {
+			f.Close()
+			return errors.Wrap(err, "writing lint report")
+		}
+		if err := f.Close(); err != nil {
+			return errors.Wrap(err, "writing lint report")
+		}
+	}
+	if len(r.Findings) > 0 {
+		log.Warnf(ctx, "pipeline has %d lint findings:\n%v", len(r.Findings), r)
+	}
+	if strings.EqualFold(*lintFailOn, "none") {
+		return nil
+	}
+	min, err := ParseSeverity(*lintFailOn)
+	if err != nil {
+		return errors.WithContext(err, "parsing --vet_fail_on")
+	}
+	if failed := r.AtLeast(min); len(failed) > 0 {
+		failing := &Report{Findings: failed}
+		err := errors.Errorf("pipeline has %d lint findings of severity %v or higher:\n%v", len(failed), min, failing)
+		return errors.SetTopLevelMsg(err, "pipeline failed lint checks")
+	}
+	return nil
+}
+
+// lintEdges runs all checks on the edges, and returns the findings sorted by
+// decreasing severity.
+func lintEdges(edges []*graph.MultiEdge) *Report {
+	r := &Report{}
+	for _, edge := range edges {
+		switch edge.Op {
+		case graph.CoGBK:
+			checkKeyCoders(r, edge)
+			checkGlobalWindow(r, edge)
+		case graph.ParDo:
+			checkFnFields(r, edge, edge.DoFn.Recv)
+			checkSideInputs(r, edge)
+		case graph.Combine:
+			checkFnFields(r, edge, edge.CombineFn.Recv)
+		}
+	}
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		return r.Findings[i].Severity > r.Findings[j].Severity
+	})
+	return r
+}
+
+// checkKeyCoders reports key coders of grouped inputs that may encode equal
+// keys differently, which silently splits their groups.
+func checkKeyCoders(r *Report, edge *graph.MultiEdge) {
+	for _, in := range edge.Input {
+		c := coder.SkipW(in.From.Coder)
+		if c == nil || !coder.IsKV(c) {
+			continue
+		}
+		key := c.Components[0]
+		if sev, reason, ok := nondeterministic(key, 0); ok {
+			r.add(sev, CheckKeyCoder, edge, "key coder %v of %v %v", key, in.From, reason)
+		}
+	}
+}
+
+// nondeterministic returns whether the coder may encode equal values
+// differently, and why.
+func nondeterministic(c *coder.Coder, depth int) (Severity, string, bool) {
+	if c == nil || depth > 16 {
+		return Info, "", false
+	}
+	switch c.Kind {
+	case coder.Double:
+		return Warning, "encodes floating point values, where equal values such as -0.0 and 0.0 have different encodings", true
+	case coder.Custom:
+		return Info, fmt.Sprintf("is the custom coder %v, whose determinism can't be verified", c.Custom.Name), true
+	case coder.Row:
+		if c.T == nil {
+			return Info, "", false
+		}
+		return nondeterministicType(c.T.Type(), make(map[reflect.Type]bool))
+	}
+	for _, comp := range c.Components {
+		if sev, reason, ok := nondeterministic(comp, depth+1); ok {
+			return sev, reason, ok
+		}
+	}
+	return Info, "", false
+}
+
+// nondeterministicType returns whether the schema encoding of the type may
+// encode equal values differently, and why.
+func nondeterministicType(t reflect.Type, seen map[reflect.Type]bool) (Severity, string, bool) {
+	if seen[t] {
+		return Info, "", false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Map:
+		return Error, fmt.Sprintf("encodes the map %v in random iteration order", t), true
+	case reflect.Interface:
+		return Error, fmt.Sprintf("encodes the interface %v, whose encoding depends on the dynamic type", t), true
+	case reflect.Float32, reflect.Float64:
+		return Warning, fmt.Sprintf("encodes the floating point %v, where equal values such as -0.0 and 0.0 have different encodings", t), true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return nondeterministicType(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if sev, reason, ok := nondeterministicType(t.Field(i).Type, seen); ok {
+				return sev, reason, ok
+			}
+		}
+	}
+	return Info, "", false
+}
+
+// checkGlobalWindow reports unbounded PCollections grouped in the global
+// window with the default trigger, which never produce output.
+func checkGlobalWindow(r *Report, edge *graph.MultiEdge) {
+	for _, in := range edge.Input {
+		if in.From.Bounded() || !isGlobalDefault(in.From.WindowingStrategy()) {
+			continue
+		}
+		r.add(Error, CheckGlobalWindow, edge, "unbounded %v is grouped in the global window with the default trigger, so no output is ever produced; apply windowing or a trigger first", in.From)
+	}
+}
+
+// isGlobalDefault returns whether the strategy uses the global window and the
+// default trigger.
+func isGlobalDefault(ws *window.WindowingStrategy) bool {
+	if ws == nil {
+		return true
+	}
+	if ws.Fn != nil && ws.Fn.Kind != window.GlobalWindows {
+		return false
+	}
+	switch ws.Trigger.(type) {
+	case nil, trigger.DefaultTrigger, *trigger.DefaultTrigger:
+		return true
+	}
+	return false
+}
+
+// checkFnFields reports large exported fields of structural DoFns and
+// CombineFns, which are serialized into the pipeline and sent to every
+// worker.
+func checkFnFields(r *Report, edge *graph.MultiEdge, recv interface{}) {
+	if recv == nil {
+		return
+	}
+	v := reflect.Indirect(reflect.ValueOf(recv))
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("json") == "-" {
+			continue // Unexported or ignored fields aren't serialized.
+		}
+		b, err := json.Marshal(v.Field(i).Interface())
+		if err != nil || len(b) < largeFieldBytes {
+			continue
+		}
+		sev := Warning
+		if len(b) >= hugeFieldBytes {
+			sev = Error
+		}
+		r.add(sev, CheckLargeDoFn, edge, "field %v of %v serializes to %v KiB and is sent to every worker; pass large data as a side input instead", f.Name, reflectx.SkipPtr(reflect.TypeOf(recv)), len(b)>>10)
+	}
+}
+
+// checkSideInputs reports side inputs whose windowing doesn't work, or is
+// unlikely to match, with the windowing of the main input.
+func checkSideInputs(r *Report, edge *graph.MultiEdge) {
+	if len(edge.Input) < 2 {
+		return
+	}
+	main := edge.Input[0].From.WindowingStrategy()
+	for _, in := range edge.Input[1:] {
+		ws := in.From.WindowingStrategy()
+		switch {
+		case ws != nil && ws.Fn != nil && ws.Fn.Kind == window.Sessions:
+			r.add(Error, CheckSideInputWindows, edge, "side input %v uses merging session windows, which can't be used as side inputs", in.From)
+		case !in.From.Bounded() && isGlobalDefault(ws):
+			r.add(Error, CheckSideInputWindows, edge, "unbounded side input %v is in the global window with the default trigger, so it's never ready", in.From)
+		case ws != nil && ws.Fn != nil && ws.Fn.Kind != window.GlobalWindows && main != nil && !ws.Fn.Equals(main.Fn):
+			r.add(Warning, CheckSideInputWindows, edge, "side input %v is windowed with %v, but the main input with %v; each main input window reads the side input window containing its end", in.From, ws.Fn, main.Fn)
+		}
+	}
+}
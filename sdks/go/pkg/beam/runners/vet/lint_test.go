@@ -0,0 +1,278 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window/trigger"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
+)
+
+type mapKey struct {
+	ID    string
+	Attrs map[string]string
+}
+
+type bigFn struct {
+	Lookup []string
+	Small  string
+}
+
+func (fn *bigFn) ProcessElement(x int) int { return x }
+
+func withFixedWindows(ws *window.WindowingStrategy) *window.WindowingStrategy {
+	ws.Fn = window.NewFixedWindows(time.Minute)
+	return ws
+}
+
+func TestLint_keyCoders(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   *coder.Coder
+		check bool
+		sev   Severity
+	}{
+		{"varint", coder.NewVarInt(), false, Info},
+		{"string", coder.NewString(), false, Info},
+		{"double", coder.NewDouble(), true, Warning},
+		{"nested double", coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewDouble()}), true, Warning},
+		{"row with map", &coder.Coder{Kind: coder.Row, T: typex.New(reflect.TypeOf(mapKey{}))}, true, Error},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := graph.New()
+			n := g.NewNode(typex.NewKV(test.key.T, typex.New(reflectx.Int)), window.DefaultWindowingStrategy(), true)
+			n.Coder = coder.NewKV([]*coder.Coder{test.key, coder.NewVarInt()})
+			edge, err := graph.NewCoGBK(g, g.Root(), []*graph.Node{n})
+			if err != nil {
+				t.Fatalf("NewCoGBK failed: %v", err)
+			}
+			r := lintEdges([]*graph.MultiEdge{edge})
+			if got := len(r.Findings) > 0; got != test.check {
+				t.Fatalf("lintEdges() findings = %v, want findings: %v", r.Findings, test.check)
+			}
+			if test.check {
+				if got, want := r.Findings[0].Check, CheckKeyCoder; got != want {
+					t.Errorf("finding check = %v, want %v", got, want)
+				}
+				if got, want := r.Findings[0].Severity, test.sev; got != want {
+					t.Errorf("finding severity = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLint_globalWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		ws      *window.WindowingStrategy
+		bounded bool
+		want    int
+	}{
+		{"bounded global", window.DefaultWindowingStrategy(), true, 0},
+		{"unbounded global", window.DefaultWindowingStrategy(), false, 1},
+		{"unbounded fixed", withFixedWindows(window.DefaultWindowingStrategy()), false, 0},
+		{"unbounded triggered", &window.WindowingStrategy{Fn: window.NewGlobalWindows(), Trigger: trigger.Repeat(trigger.AfterCount(1))}, false, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := graph.New()
+			n := g.NewNode(typex.NewKV(typex.New(reflectx.String), typex.New(reflectx.Int)), test.ws, test.bounded)
+			n.Coder = coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewVarInt()})
+			edge, err := graph.NewCoGBK(g, g.Root(), []*graph.Node{n})
+			if err != nil {
+				t.Fatalf("NewCoGBK failed: %v", err)
+			}
+			r := lintEdges([]*graph.MultiEdge{edge})
+			if got := len(r.Findings); got != test.want {
+				t.Fatalf("lintEdges() = %v, want %v findings", r.Findings, test.want)
+			}
+			if test.want > 0 && r.Findings[0].Severity != Error {
+				t.Errorf("finding severity = %v, want %v", r.Findings[0].Severity, Error)
+			}
+		})
+	}
+}
+
+func TestLint_sideInputs(t *testing.T) {
+	fixed := withFixedWindows(window.DefaultWindowingStrategy())
+	sessions := &window.WindowingStrategy{Fn: window.NewSessions(time.Minute), Trigger: trigger.Default()}
+	other := &window.WindowingStrategy{Fn: window.NewFixedWindows(time.Hour), Trigger: trigger.Default()}
+	tests := []struct {
+		name    string
+		ws      *window.WindowingStrategy
+		bounded bool
+		want    Severity
+		found   bool
+	}{
+		{"same windows", fixed, true, Info, false},
+		{"global", window.DefaultWindowingStrategy(), true, Info, false},
+		{"sessions", sessions, true, Error, true},
+		{"unbounded global", window.DefaultWindowingStrategy(), false, Error, true},
+		{"different windows", other, true, Warning, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := graph.New()
+			main := g.NewNode(typex.New(reflectx.Int), fixed, true)
+			side := g.NewNode(typex.New(reflectx.Int), test.ws, test.bounded)
+			fn, err := graph.NewDoFn(func(x int, side []int) int { return x })
+			if err != nil {
+				t.Fatalf("NewDoFn failed: %v", err)
+			}
+			edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{main, side}, nil, nil)
+			if err != nil {
+				t.Fatalf("NewParDo failed: %v", err)
+			}
+			r := lintEdges([]*graph.MultiEdge{edge})
+			if got := len(r.Findings) > 0; got != test.found {
+				t.Fatalf("lintEdges() findings = %v, want findings: %v", r.Findings, test.found)
+			}
+			if test.found {
+				if got := r.Findings[0]; got.Check != CheckSideInputWindows || got.Severity != test.want {
+					t.Errorf("finding = %v, want %v with severity %v", got, CheckSideInputWindows, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLint_fnFields(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		want []Severity
+	}{
+		{"small", 10, nil},
+		{"large", 2 * largeFieldBytes, []Severity{Warning}},
+		{"huge", hugeFieldBytes, []Severity{Error}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recv := &bigFn{Lookup: []string{strings.Repeat("a", test.size)}, Small: "b"}
+			fn, err := graph.NewDoFn(recv)
+			if err != nil {
+				t.Fatalf("NewDoFn failed: %v", err)
+			}
+			g := graph.New()
+			in := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+			edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{in}, nil, nil)
+			if err != nil {
+				t.Fatalf("NewParDo failed: %v", err)
+			}
+			r := lintEdges([]*graph.MultiEdge{edge})
+			var got []Severity
+			for _, f := range r.Findings {
+				if f.Check != CheckLargeDoFn {
+					t.Errorf("unexpected finding %v", f)
+				}
+				if !strings.Contains(f.Message, "Lookup") {
+					t.Errorf("finding %v doesn't name field Lookup", f)
+				}
+				got = append(got, f.Severity)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("lintEdges() severities = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLint_pipeline(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, 1.5, 2.5)
+	kvs := beam.AddFixedKey(s, col)
+	swapped := beam.SwapKV(s, kvs)
+	beam.GroupByKey(s, swapped)
+
+	r, err := Lint(context.Background(), p)
+	if err != nil {
+		t.Fatalf("Lint failed: %v", err)
+	}
+	if max, ok := r.MaxSeverity(); !ok || max != Warning {
+		t.Errorf("MaxSeverity() = %v, %v, want %v, true", max, ok, Warning)
+	}
+	if got := r.AtLeast(Error); len(got) != 0 {
+		t.Errorf("AtLeast(Error) = %v, want none", got)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("decoding report %q failed: %v", buf.String(), err)
+	}
+	if !reflect.DeepEqual(&decoded, r) {
+		t.Errorf("decoded report = %v, want %v", decoded, r)
+	}
+	if !strings.Contains(buf.String(), `"severity": "warning"`) {
+		t.Errorf("report %q doesn't encode severities by name", buf.String())
+	}
+}
+
+func TestLintPipeline_failOn(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	kvs := beam.AddFixedKey(s, beam.Create(s, 1.5, 2.5))
+	beam.GroupByKey(s, beam.SwapKV(s, kvs))
+
+	// Findings are only reported by default.
+	if got, want := flag.Lookup("vet_fail_on").DefValue, "none"; got != want {
+		t.Errorf("--vet_fail_on defaults to %v, want %v", got, want)
+	}
+	defer func(v string) { *lintFailOn = v }(*lintFailOn)
+	tests := []struct {
+		failOn  string
+		wantErr bool
+	}{
+		{"none", false},
+		{"error", false},
+		{"warning", true},
+	}
+	for _, test := range tests {
+		*lintFailOn = test.failOn
+		if err := lintPipeline(context.Background(), p); (err != nil) != test.wantErr {
+			t.Errorf("lintPipeline() with --vet_fail_on=%v = %v, want error %v", test.failOn, err, test.wantErr)
+		}
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, sev := range []Severity{Info, Warning, Error} {
+		got, err := ParseSeverity(strings.ToUpper(sev.String()))
+		if err != nil || got != sev {
+			t.Errorf("ParseSeverity(%q) = %v, %v, want %v", strings.ToUpper(sev.String()), got, err, sev)
+		}
+	}
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Error("ParseSeverity(\"fatal\") succeeded, want error")
+	}
+}
@@ -21,6 +21,12 @@
 // can use this as a sanity check on whether a given pipeline avoids known
 // performance bottlenecks.
 //
+// Before checking for performance bottlenecks, the runner lints the pipeline
+// for common correctness mistakes, such as grouping with nondeterministic key
+// coders. Findings are logged and written as JSON to the --vet_report file.
+// By default they don't fail the pipeline; findings at or above the
+// --vet_fail_on severity do, if set.
+//
 // TODO(https://github.com/apache/beam/issues/19402): Add usage documentation.
 package vet
 
@@ -59,6 +65,9 @@ func (p disabledResolver) Sym2Addr(name string) (uintptr, error) {
 
 // Execute evaluates the pipeline on whether it can run without reflection.
 func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error) {
+	if err := lintPipeline(ctx, p); err != nil {
+		return nil, errors.WithContext(err, "validating pipeline with vet runner")
+	}
 	e, err := Evaluate(ctx, p)
 	if err != nil {
 		return nil, errors.WithContext(err, "validating pipeline with vet runner")
@@ -0,0 +1,91 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package viz is a Beam runner that "runs" a pipeline by producing an HTML
+// visualization of the translated pipeline. If another runner is named with
+// --viz_runner, the pipeline is run on it first, and its metrics are overlaid
+// on the visualization:
+//
+//	go run main.go --runner=viz --viz_file=pipeline.html --viz_runner=direct
+package viz
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io/ioutil"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
+	vizlib "github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/viz"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/jobopts"
+)
+
+func init() {
+	beam.RegisterRunner("viz", Execute)
+}
+
+var (
+	vizFile   = flag.String("viz_file", "", "HTML output file to create")
+	vizRunner = flag.String("viz_runner", "", "Runner to execute the pipeline on, to visualize its metrics (optional)")
+)
+
+// Execute produces an HTML visualization of the pipeline, after running it
+// on the --viz_runner runner, if set. It returns the result of that run.
+func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error) {
+	if *vizFile == "" {
+		return nil, errors.New("must supply viz_file argument")
+	}
+	if *vizRunner == "viz" {
+		return nil, errors.New("viz_runner must be a different runner")
+	}
+
+	edges, _, err := p.Build()
+	if err != nil {
+		return nil, errors.WithContext(err, "building pipeline")
+	}
+	env, err := graphx.CreateEnvironment(ctx, jobopts.GetEnvironmentUrn(ctx), jobopts.GetEnvironmentConfig)
+	if err != nil {
+		return nil, errors.WithContext(err, "generating model pipeline")
+	}
+	hints, err := jobopts.GetResourceHints()
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := graphx.Marshal(edges, &graphx.Options{Environment: env, ResourceHints: hints})
+	if err != nil {
+		return nil, errors.WithContext(err, "generating model pipeline")
+	}
+
+	var res beam.PipelineResult
+	var qr metrics.QueryResults
+	if *vizRunner != "" {
+		res, err = beam.Run(ctx, *vizRunner, p)
+		if err != nil {
+			return nil, err
+		}
+		if res != nil {
+			qr = res.Metrics().AllMetrics()
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := vizlib.Render(&buf, pipeline, qr); err != nil {
+		return nil, err
+	}
+	return res, ioutil.WriteFile(*vizFile, buf.Bytes(), 0644)
+}
@@ -0,0 +1,69 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package viz
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/direct"
+)
+
+func init() {
+	beam.RegisterFunction(double)
+}
+
+func double(v int) int {
+	return 2 * v
+}
+
+func TestExecute(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "pipeline.html")
+	*vizFile, *vizRunner = file, "direct"
+	defer func() { *vizFile, *vizRunner = "", "" }()
+
+	p, s := beam.NewPipelineWithRoot()
+	beam.ParDo(s, double, beam.Create(s, 1, 2, 3))
+
+	res, err := Execute(context.Background(), p)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if res == nil {
+		t.Error("Execute didn't return the result of the direct runner")
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<svg", "viz.double", "beam:transform:impulse:v1"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("visualization doesn't contain %q:\n%s", want, b)
+		}
+	}
+}
+
+func TestExecute_noFile(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	beam.Create(s, 1)
+	if _, err := Execute(context.Background(), p); err == nil {
+		t.Error("Execute succeeded without viz_file, want error")
+	}
+}
@@ -0,0 +1,62 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptest
+
+import (
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/pipelinex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+)
+
+// Diff returns the changes from the before to the after pipeline. The name
+// mapping maps transform names of the before pipeline to those of the after
+// pipeline, as for an in-place update. See pipelinex.Diff.
+func Diff(before, after *beam.Pipeline, nameMapping map[string]string) (*pipelinex.PipelineDiff, error) {
+	bp, err := marshal(before)
+	if err != nil {
+		return nil, errors.WithContext(err, "marshalling before pipeline")
+	}
+	ap, err := marshal(after)
+	if err != nil {
+		return nil, errors.WithContext(err, "marshalling after pipeline")
+	}
+	return pipelinex.Diff(bp, ap, nameMapping)
+}
+
+// CheckUpdateCompatible fails the test if a running job of the before
+// pipeline can't be updated in place with the after pipeline.
+func CheckUpdateCompatible(t *testing.T, before, after *beam.Pipeline, nameMapping map[string]string) {
+	t.Helper()
+	d, err := Diff(before, after, nameMapping)
+	if err != nil {
+		t.Fatalf("Failed to diff pipelines: %v", err)
+	}
+	if err := d.Compatible(); err != nil {
+		t.Error(err)
+	}
+}
+
+func marshal(p *beam.Pipeline) (*pipepb.Pipeline, error) {
+	edges, _, err := p.Build()
+	if err != nil {
+		return nil, err
+	}
+	return graphx.Marshal(edges, &graphx.Options{Environment: &pipepb.Environment{}})
+}
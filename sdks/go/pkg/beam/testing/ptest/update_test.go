@@ -0,0 +1,65 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptest
+
+import (
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/pipelinex"
+)
+
+func toKV(x int) (string, int) { return "k", x }
+
+func toFloatKV(x int) (string, float64) { return "k", float64(x) }
+
+func updatePipeline(scope string, fn interface{}) *beam.Pipeline {
+	p, s, col := CreateList([]int{1, 2, 3})
+	kvs := beam.ParDo(s.Scope(scope), fn, col)
+	beam.GroupByKey(s, kvs)
+	return p
+}
+
+func TestCheckUpdateCompatible(t *testing.T) {
+	CheckUpdateCompatible(t, updatePipeline("Parse", toKV), updatePipeline("Parse", toKV), nil)
+	CheckUpdateCompatible(t, updatePipeline("Parse", toKV), updatePipeline("ParseV2", toKV), map[string]string{"Parse": "ParseV2"})
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		after  *beam.Pipeline
+		breaks pipelinex.ChangeKind
+	}{
+		{"renamed", updatePipeline("ParseV2", toKV), pipelinex.TransformRenamed},
+		{"coder", updatePipeline("Parse", toFloatKV), pipelinex.CoderChanged},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := Diff(updatePipeline("Parse", toKV), test.after, nil)
+			if err != nil {
+				t.Fatalf("Diff failed: %v", err)
+			}
+			found := false
+			for _, c := range d.Breaking() {
+				found = found || c.Kind == test.breaks
+			}
+			if !found {
+				t.Errorf("Diff() = %v, want breaking %v change", d.Changes, test.breaks)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harnessopts
+
+import (
+	"sort"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/hooks"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+)
+
+const (
+	logLevelsHook = "beam:go:hook:logging:levels"
+	// AllTransforms is the LogLevels key for the default log level of all
+	// transforms, and of messages not logged by a transform.
+	AllTransforms = "*"
+)
+
+// LogLevels sets the minimum severity of messages logged on workers, per
+// transform. Transforms are identified by their unique name, such as
+// "CountWords/Format", which applies to all transforms in a composite, or by
+// their PTransform ID. The most specific match applies. By default, all
+// messages are logged.
+func LogLevels(levels map[string]log.Severity) error {
+	var opts []string
+	for name, sev := range levels {
+		opts = append(opts, name+"="+sev.String())
+	}
+	sort.Strings(opts)
+	// The hook itself is defined in beam/core/runtime/harness/loglevels.go
+	return hooks.EnableHook(logLevelsHook, opts...)
+}
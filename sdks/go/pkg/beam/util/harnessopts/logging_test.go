@@ -0,0 +1,41 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harnessopts
+
+import (
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/hooks"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLogLevels(t *testing.T) {
+	err := LogLevels(map[string]log.Severity{
+		AllTransforms:       log.SevWarn,
+		"CountWords/Format": log.SevDebug,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, opts := hooks.IsEnabled(logLevelsHook)
+	if !ok {
+		t.Fatalf("Log levels hook is not enabled")
+	}
+	if want := []string{"*=WARN", "CountWords/Format=DEBUG"}; !cmp.Equal(opts, want) {
+		t.Errorf("opts = %v, want %v", opts, want)
+	}
+}
@@ -0,0 +1,38 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harnessopts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/hooks"
+)
+
+const (
+	lullTimeoutHook = "beam:go:hook:lull:timeout"
+)
+
+// LullTimeout sets how long a bundle may stay in the same processing state
+// (at least 1s) before the harness reports it as stuck, with the current
+// element and goroutine stacks. Default value is 5m.
+func LullTimeout(timeout time.Duration) error {
+	if timeout < time.Second {
+		return fmt.Errorf("lull timeout should be at least 1s, got %v", timeout)
+	}
+	// The hook itself is defined in beam/core/runtime/harness/lull.go
+	return hooks.EnableHook(lullTimeoutHook, timeout.String())
+}
@@ -0,0 +1,42 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harnessopts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/hooks"
+)
+
+func TestLullTimeout(t *testing.T) {
+	if err := LullTimeout(10 * time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	ok, opts := hooks.IsEnabled(lullTimeoutHook)
+	if !ok {
+		t.Fatalf("Lull timeout hook is not enabled")
+	}
+	if len(opts) != 1 || opts[0] != "10m0s" {
+		t.Errorf("opts = %v, want [10m0s]", opts)
+	}
+}
+
+func TestLullTimeout_Bad(t *testing.T) {
+	if err := LullTimeout(time.Millisecond); err == nil {
+		t.Error("lull timeout of less than 1s worked when it shouldn't.")
+	}
+}
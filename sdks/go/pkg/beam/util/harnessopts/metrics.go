@@ -0,0 +1,40 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harnessopts
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/hooks"
+)
+
+const (
+	openMetricsHook = "beam:go:hook:metrics:openmetrics"
+)
+
+// OpenMetricsAddress sets the address, such as ":9090", on which each worker serves
+// the metrics of the bundles it processed at /metrics, in the OpenMetrics format for
+// scraping by Prometheus and compatible monitoring systems. Metrics are cumulative over
+// the lifetime of the worker, and include user metrics labelled with their namespace,
+// name and PTransform, PTransform execution times and PCollection element counts.
+func OpenMetricsAddress(address string) error {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return fmt.Errorf("invalid metrics address %q: %v", address, err)
+	}
+	// The hook itself is defined in beam/core/runtime/harness/openmetrics.go
+	return hooks.EnableHook(openMetricsHook, address)
+}
@@ -0,0 +1,341 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otel adds OpenTelemetry tracing of bundle processing to the worker
+// harness.
+//
+// When enabled, the harness creates a span for each ProcessBundle instruction,
+// with a child span for each PTransform in the bundle carrying the execution
+// time and element counts reported in the bundle's monitoring infos. The SDK
+// only reports these in aggregate when the bundle completes, so PTransform
+// spans start with the bundle and last for the total execution time of the
+// PTransform, up to the end of the bundle, rather than covering each of its
+// calls. The bundle span is set
+// on the context passed to DoFns, so spans started by user code, such as for
+// outgoing RPCs, join the trace.
+//
+// Spans are exported over OTLP/gRPC. The hook also installs its tracer
+// provider and the W3C trace context propagator as the global defaults, so
+// that instrumentation libraries pick them up on the workers.
+package otel
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/harness"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/metricsx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/hooks"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	fnpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/fnexecution_v1"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	hookName = "otel"
+
+	// instrumentationName identifies the tracer used for harness spans.
+	instrumentationName = "github.com/apache/beam/sdks/v2/go/pkg/beam/x/hooks/otel"
+
+	defaultServiceName = "beam-go-sdk-harness"
+
+	// maxDescriptors is the number of bundle descriptors whose transforms are
+	// kept to annotate bundle spans. Bundles of evicted descriptors have
+	// their element counts recorded per PCollection instead.
+	maxDescriptors = 100
+)
+
+func init() {
+	hooks.RegisterHook(hookName, func(opts []string) hooks.Hook {
+		h := newTracingHook(opts)
+		return hooks.Hook{
+			Init: h.init,
+			Req:  h.req,
+			Resp: h.resp,
+		}
+	})
+}
+
+// EnableTracingHook enables OpenTelemetry tracing of bundle processing on
+// the workers, exporting spans over OTLP/gRPC to the collector at endpoint,
+// such as "otel-collector:4317". Further options are given as key=value
+// strings:
+//
+//	insecure=true      disables TLS for the connection to the collector.
+//	service_name=NAME  sets the service.name of the exported spans, which
+//	                   defaults to "beam-go-sdk-harness".
+func EnableTracingHook(endpoint string, opts ...string) {
+	hooks.EnableHook(hookName, append([]string{endpoint}, opts...)...)
+}
+
+// tracingHook holds the state of the hook on a worker.
+type tracingHook struct {
+	endpoint    string
+	insecure    bool
+	serviceName string
+	configErr   error
+
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+
+	mu sync.Mutex
+	// descriptors caches the transforms of bundle descriptors from Register
+	// requests, to name PTransform spans and attribute PCollection element
+	// counts to them. The oldest descriptors are evicted first.
+	descriptors map[string]map[string]*pipepb.PTransform
+	descOrder   []string
+}
+
+func newTracingHook(opts []string) *tracingHook {
+	h := &tracingHook{
+		serviceName: defaultServiceName,
+		descriptors: make(map[string]map[string]*pipepb.PTransform),
+	}
+	if len(opts) == 0 || opts[0] == "" {
+		h.configErr = errors.New("otel hook requires a collector endpoint")
+		return h
+	}
+	h.endpoint = opts[0]
+	for _, opt := range opts[1:] {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "insecure":
+			h.insecure = value == "" || value == "true"
+		case "service_name":
+			h.serviceName = value
+		default:
+			h.configErr = errors.Errorf("otel hook: unknown option %q", opt)
+		}
+	}
+	return h
+}
+
+func (h *tracingHook) init(ctx context.Context) (context.Context, error) {
+	if h.configErr != nil {
+		return ctx, h.configErr
+	}
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(h.endpoint)}
+	if h.insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return ctx, errors.Wrapf(err, "failed to create OTLP exporter for %v", h.endpoint)
+	}
+	h.provider = sdktrace.NewTracerProvider(
+		// Workers may be torn down without notice, so export promptly.
+		sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(time.Second)),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(h.serviceName),
+			semconv.TelemetrySDKLanguageGo,
+		)),
+	)
+	h.tracer = h.provider.Tracer(instrumentationName)
+	otel.SetTracerProvider(h.provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return ctx, nil
+}
+
+func (h *tracingHook) req(ctx context.Context, req *fnpb.InstructionRequest) (context.Context, error) {
+	if h.tracer == nil {
+		return ctx, nil
+	}
+	switch {
+	case req.GetRegister() != nil:
+		for _, desc := range req.GetRegister().GetProcessBundleDescriptor() {
+			h.register(desc)
+		}
+	case req.GetProcessBundle() != nil:
+		start := time.Now()
+		ctx = context.WithValue(ctx, bundleStartKey{}, start)
+		ctx, _ = h.tracer.Start(ctx, "ProcessBundle",
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithTimestamp(start),
+			trace.WithAttributes(
+				attribute.String("beam.instruction_id", req.GetInstructionId()),
+				attribute.String("beam.bundle_descriptor_id", req.GetProcessBundle().GetProcessBundleDescriptorId()),
+			))
+	}
+	return ctx, nil
+}
+
+// bundleStartKey is the context key for the start time of a bundle.
+type bundleStartKey struct{}
+
+// register caches the transforms of the descriptor, evicting the oldest
+// descriptor if there are too many.
+func (h *tracingHook) register(desc *fnpb.ProcessBundleDescriptor) {
+	transforms := make(map[string]*pipepb.PTransform, len(desc.GetTransforms()))
+	for id, pt := range desc.GetTransforms() {
+		// Only the fields used for annotation are kept, not the payloads.
+		transforms[id] = &pipepb.PTransform{UniqueName: pt.GetUniqueName(), Inputs: pt.GetInputs(), Outputs: pt.GetOutputs()}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.descriptors[desc.GetId()]; !ok {
+		if len(h.descOrder) >= maxDescriptors {
+			delete(h.descriptors, h.descOrder[0])
+			h.descOrder = h.descOrder[1:]
+		}
+		h.descOrder = append(h.descOrder, desc.GetId())
+	}
+	h.descriptors[desc.GetId()] = transforms
+}
+
+func (h *tracingHook) resp(ctx context.Context, req *fnpb.InstructionRequest, resp *fnpb.InstructionResponse) error {
+	if h.tracer == nil || req.GetProcessBundle() == nil {
+		return nil
+	}
+	span := trace.SpanFromContext(ctx)
+	if !span.SpanContext().IsValid() {
+		return nil
+	}
+	end := time.Now()
+	start, ok := ctx.Value(bundleStartKey{}).(time.Time)
+	if !ok {
+		start = end
+	}
+
+	h.mu.Lock()
+	transforms, known := h.descriptors[req.GetProcessBundle().GetProcessBundleDescriptorId()]
+	h.mu.Unlock()
+
+	stats, pcolCounts, err := parseMonitoringInfos(harness.MonitoringInfos(resp.GetProcessBundle()))
+	if err != nil {
+		span.RecordError(err)
+	}
+	for _, id := range sortedKeys(stats) {
+		st := stats[id]
+		name := id
+		attrs := []attribute.KeyValue{
+			attribute.String("beam.ptransform_id", id),
+			attribute.Int64("beam.start_bundle_msecs", st.msecs[0]),
+			attribute.Int64("beam.process_bundle_msecs", st.msecs[1]),
+			attribute.Int64("beam.finish_bundle_msecs", st.msecs[2]),
+		}
+		if pt, ok := transforms[id]; ok {
+			name = pt.GetUniqueName()
+			attrs = append(attrs,
+				attribute.String("beam.ptransform_name", pt.GetUniqueName()),
+				attribute.Int64("beam.input_elements", sumCounts(pt.GetInputs(), pcolCounts)),
+				attribute.Int64("beam.output_elements", sumCounts(pt.GetOutputs(), pcolCounts)),
+			)
+		}
+		// The msecs are sampled, so keep the span within the bundle's.
+		ptEnd := start.Add(time.Duration(st.msecs[0]+st.msecs[1]+st.msecs[2]) * time.Millisecond)
+		if ptEnd.After(end) {
+			ptEnd = end
+		}
+		_, ptSpan := h.tracer.Start(ctx, name, trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+		ptSpan.End(trace.WithTimestamp(ptEnd))
+	}
+	if !known {
+		// Without the descriptor, element counts can't be attributed to
+		// PTransforms, so they're recorded on the bundle span.
+		for _, id := range sortedKeys(pcolCounts) {
+			span.SetAttributes(attribute.Int64(fmt.Sprintf("beam.pcollection.%v.elements", id), pcolCounts[id]))
+		}
+	}
+	if msg := resp.GetError(); msg != "" {
+		span.SetStatus(codes.Error, msg)
+	}
+	span.End(trace.WithTimestamp(end))
+	return nil
+}
+
+// transformStats holds the execution msecs for the start, process and
+// finish bundle states of a PTransform.
+type transformStats struct {
+	msecs [3]int64
+}
+
+// parseMonitoringInfos extracts the per PTransform execution msecs and the
+// per PCollection element counts from the monitoring infos of a bundle.
+func parseMonitoringInfos(mons []*pipepb.MonitoringInfo) (map[string]*transformStats, map[string]int64, error) {
+	stats := make(map[string]*transformStats)
+	pcolCounts := make(map[string]int64)
+	var firstErr error
+	for _, mon := range mons {
+		var state int
+		switch mon.GetUrn() {
+		case metricsx.UrnToString(metricsx.UrnStartBundle):
+			state = 0
+		case metricsx.UrnToString(metricsx.UrnProcessBundle):
+			state = 1
+		case metricsx.UrnToString(metricsx.UrnFinishBundle):
+			state = 2
+		case metricsx.UrnToString(metricsx.UrnElementCount):
+			v, err := coder.DecodeVarInt(bytes.NewReader(mon.GetPayload()))
+			if err != nil {
+				if firstErr == nil {
+					firstErr = errors.Wrapf(err, "failed to decode %v", mon.GetUrn())
+				}
+				continue
+			}
+			pcolCounts[mon.GetLabels()["PCOLLECTION"]] += v
+			continue
+		default:
+			continue
+		}
+		v, err := coder.DecodeVarInt(bytes.NewReader(mon.GetPayload()))
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "failed to decode %v", mon.GetUrn())
+			}
+			continue
+		}
+		id := mon.GetLabels()["PTRANSFORM"]
+		st, ok := stats[id]
+		if !ok {
+			st = &transformStats{}
+			stats[id] = st
+		}
+		st.msecs[state] += v
+	}
+	return stats, pcolCounts, firstErr
+}
+
+func sumCounts(pcols map[string]string, counts map[string]int64) int64 {
+	var sum int64
+	for _, id := range pcols {
+		sum += counts[id]
+	}
+	return sum
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
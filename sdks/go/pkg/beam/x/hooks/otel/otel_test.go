@@ -0,0 +1,234 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/metricsx"
+	fnpb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/fnexecution_v1"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+)
+
+// fakeCollector is an in-process OTLP trace collector.
+type fakeCollector struct {
+	collectorpb.UnimplementedTraceServiceServer
+
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (c *fakeCollector) Export(_ context.Context, req *collectorpb.ExportTraceServiceRequest) (*collectorpb.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			c.spans = append(c.spans, ss.GetSpans()...)
+		}
+	}
+	return &collectorpb.ExportTraceServiceResponse{}, nil
+}
+
+func (c *fakeCollector) byName() map[string]*tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := make(map[string]*tracepb.Span)
+	for _, s := range c.spans {
+		m[s.GetName()] = s
+	}
+	return m
+}
+
+func startCollector(t *testing.T) (*fakeCollector, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &fakeCollector{}
+	srv := grpc.NewServer()
+	collectorpb.RegisterTraceServiceServer(srv, c)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return c, lis.Addr().String()
+}
+
+func counter(t *testing.T, urn metricsx.Urn, labels map[string]string, v int64) *pipepb.MonitoringInfo {
+	t.Helper()
+	payload, err := metricsx.Int64Counter(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pipepb.MonitoringInfo{Urn: metricsx.UrnToString(urn), Type: metricsx.UrnToType(urn), Labels: labels, Payload: payload}
+}
+
+func attrInt(attrs []*commonpb.KeyValue, key string) (int64, bool) {
+	for _, kv := range attrs {
+		if kv.GetKey() == key {
+			return kv.GetValue().GetIntValue(), true
+		}
+	}
+	return 0, false
+}
+
+func attrString(attrs []*commonpb.KeyValue, key string) string {
+	for _, kv := range attrs {
+		if kv.GetKey() == key {
+			return kv.GetValue().GetStringValue()
+		}
+	}
+	return ""
+}
+
+func TestTracingHook(t *testing.T) {
+	collector, endpoint := startCollector(t)
+	ctx := context.Background()
+
+	h := newTracingHook([]string{endpoint, "insecure=true", "service_name=test"})
+	if _, err := h.init(ctx); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+
+	register := &fnpb.InstructionRequest{
+		InstructionId: "reg",
+		Request: &fnpb.InstructionRequest_Register{
+			Register: &fnpb.RegisterRequest{
+				ProcessBundleDescriptor: []*fnpb.ProcessBundleDescriptor{{
+					Id: "desc",
+					Transforms: map[string]*pipepb.PTransform{
+						"t1": {UniqueName: "Pipeline/MyDoFn", Inputs: map[string]string{"i0": "p0"}, Outputs: map[string]string{"o0": "p1"}},
+					},
+				}},
+			},
+		},
+	}
+	if _, err := h.req(ctx, register); err != nil {
+		t.Fatalf("req(Register) failed: %v", err)
+	}
+
+	req := &fnpb.InstructionRequest{
+		InstructionId: "inst1",
+		Request: &fnpb.InstructionRequest_ProcessBundle{
+			ProcessBundle: &fnpb.ProcessBundleRequest{ProcessBundleDescriptorId: "desc"},
+		},
+	}
+	bundleCtx, err := h.req(ctx, req)
+	if err != nil {
+		t.Fatalf("req(ProcessBundle) failed: %v", err)
+	}
+	// Simulate a span started by user code in a DoFn.
+	_, userSpan := h.tracer.Start(bundleCtx, "user-rpc")
+	userSpan.End()
+
+	resp := &fnpb.InstructionResponse{
+		InstructionId: "inst1",
+		Response: &fnpb.InstructionResponse_ProcessBundle{
+			ProcessBundle: &fnpb.ProcessBundleResponse{
+				MonitoringInfos: []*pipepb.MonitoringInfo{
+					counter(t, metricsx.UrnProcessBundle, map[string]string{"PTRANSFORM": "t1"}, 25),
+					counter(t, metricsx.UrnElementCount, map[string]string{"PCOLLECTION": "p0"}, 10),
+					counter(t, metricsx.UrnElementCount, map[string]string{"PCOLLECTION": "p1"}, 20),
+				},
+			},
+		},
+	}
+	if err := h.resp(bundleCtx, req, resp); err != nil {
+		t.Fatalf("resp failed: %v", err)
+	}
+	if err := h.provider.ForceFlush(ctx); err != nil {
+		t.Fatalf("ForceFlush failed: %v", err)
+	}
+
+	var spans map[string]*tracepb.Span
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if spans = collector.byName(); len(spans) == 3 {
+			break
+		}
+	}
+	if got, want := len(spans), 3; got != want {
+		t.Errorf("exported %v spans, want %v: %v", got, want, spans)
+	}
+	bundle, ok := spans["ProcessBundle"]
+	if !ok {
+		t.Fatalf("no ProcessBundle span exported, got %v", spans)
+	}
+	for _, name := range []string{"user-rpc", "Pipeline/MyDoFn"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Fatalf("no %v span exported, got %v", name, spans)
+		}
+		if string(s.GetParentSpanId()) != string(bundle.GetSpanId()) || string(s.GetTraceId()) != string(bundle.GetTraceId()) {
+			t.Errorf("%v span is not a child of the ProcessBundle span", name)
+		}
+	}
+
+	pt := spans["Pipeline/MyDoFn"]
+	if pt.GetStartTimeUnixNano() != bundle.GetStartTimeUnixNano() || pt.GetEndTimeUnixNano() > bundle.GetEndTimeUnixNano() {
+		t.Errorf("PTransform span [%v, %v] isn't within the bundle span [%v, %v]",
+			pt.GetStartTimeUnixNano(), pt.GetEndTimeUnixNano(), bundle.GetStartTimeUnixNano(), bundle.GetEndTimeUnixNano())
+	}
+	attrs := pt.GetAttributes()
+	if got, want := attrString(attrs, "beam.ptransform_id"), "t1"; got != want {
+		t.Errorf("PTransform span attribute beam.ptransform_id = %v, want %v", got, want)
+	}
+	for key, want := range map[string]int64{"beam.process_bundle_msecs": 25, "beam.input_elements": 10, "beam.output_elements": 20} {
+		if got, ok := attrInt(attrs, key); !ok || got != want {
+			t.Errorf("PTransform span attribute %v = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestTracingHook_register(t *testing.T) {
+	h := newTracingHook([]string{"localhost:4317"})
+	for i := 0; i <= maxDescriptors; i++ {
+		h.register(&fnpb.ProcessBundleDescriptor{
+			Id: fmt.Sprint(i),
+			Transforms: map[string]*pipepb.PTransform{
+				"t1": {UniqueName: "MyDoFn", Spec: &pipepb.FunctionSpec{Payload: []byte("payload")}},
+			},
+		})
+	}
+	// Re-registering a descriptor doesn't evict another.
+	h.register(&fnpb.ProcessBundleDescriptor{Id: "1"})
+
+	if got, want := len(h.descriptors), maxDescriptors; got != want {
+		t.Errorf("len(descriptors) = %v, want %v", got, want)
+	}
+	if _, ok := h.descriptors["0"]; ok {
+		t.Error("oldest descriptor wasn't evicted")
+	}
+	pt := h.descriptors[fmt.Sprint(maxDescriptors)]["t1"]
+	if pt.GetUniqueName() != "MyDoFn" || pt.GetSpec() != nil {
+		t.Errorf("cached transform = %v, want only its name, inputs and outputs", pt)
+	}
+}
+
+func TestNewTracingHook_badOptions(t *testing.T) {
+	for _, opts := range [][]string{nil, {""}, {"localhost:4317", "bogus=1"}} {
+		if _, err := newTracingHook(opts).init(context.Background()); err == nil {
+			t.Errorf("init with options %q succeeded, want error", opts)
+		}
+	}
+}